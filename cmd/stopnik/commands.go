@@ -71,6 +71,10 @@ func readConfiguration(configurationFile *string, configLoader config.Loader) (*
 
 	currentConfig := config.GetConfigInstance()
 	logger.SetLogLevel(currentConfig.Server.LogLevel)
+	loggingError := configureLogging(currentConfig)
+	if loggingError != nil {
+		return nil, loggingError
+	}
 	logger.Info("Config loaded from %s", *configurationFile)
 	if currentConfig.GetOidc() {
 		logger.Info("OpenId Connect is enabled")
@@ -78,3 +82,32 @@ func readConfiguration(configurationFile *string, configLoader config.Loader) (*
 
 	return currentConfig, nil
 }
+
+// configureLogging configures format and output of the log and the access log.
+func configureLogging(currentConfig *config.Config) error {
+	logging := currentConfig.Server.Logging
+	logger.SetFormat(currentConfig.GetLogFormat())
+	var logFile *logger.RotatingFile
+	if logging.File.Path != "" {
+		var logFileError error
+		logFile, logFileError = logger.NewRotatingFile(logging.File.Path, logging.File.GetMaxSize(), logging.File.GetMaxBackups())
+		if logFileError != nil {
+			return logFileError
+		}
+		logger.SetOutput(logFile)
+	}
+
+	logger.SetAccessLogFormat(currentConfig.GetAccessLogFormat())
+	if logFile != nil && logging.AccessLog.File.Path == logging.File.Path {
+		// same file, share the writer to not rotate the file twice
+		logger.SetAccessLogOutput(logFile)
+	} else if logging.AccessLog.File.Path != "" {
+		accessLogFile, accessLogFileError := logger.NewRotatingFile(logging.AccessLog.File.Path, logging.AccessLog.File.GetMaxSize(), logging.AccessLog.File.GetMaxBackups())
+		if accessLogFileError != nil {
+			return accessLogFileError
+		}
+		logger.SetAccessLogOutput(accessLogFile)
+	}
+
+	return nil
+}
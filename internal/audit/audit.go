@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"github.com/google/uuid"
+	"github.com/webishdev/stopnik/log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SchemaVersion is the version of the Event schema, it changes only when fields are removed or change their meaning.
+const SchemaVersion = "1"
+
+type EventType string
+
+const (
+	EtLoginSuccess      EventType = "login.success"
+	EtLoginFailure      EventType = "login.failure"
+	EtLogout            EventType = "logout"
+	EtConsentGranted    EventType = "consent.granted"
+	EtConsentRevoked    EventType = "consent.revoked"
+	EtTokenIssued       EventType = "token.issued"
+	EtTokenRefreshed    EventType = "token.refreshed"
+	EtTokenRevoked      EventType = "token.revoked"
+	EtTokenIntrospected EventType = "token.introspected"
+	EtConfigLoaded      EventType = "config.loaded"
+)
+
+type Outcome string
+
+const (
+	OcSuccess Outcome = "success"
+	OcFailure Outcome = "failure"
+)
+
+// Event is a single entry of the audit trail.
+type Event struct {
+	SchemaVersion string            `json:"schema_version"`
+	Id            string            `json:"id"`
+	Time          time.Time         `json:"time"`
+	Type          EventType         `json:"type"`
+	Outcome       Outcome           `json:"outcome"`
+	RequestId     string            `json:"request_id,omitempty"`
+	RemoteAddr    string            `json:"remote_addr,omitempty"`
+	ClientId      string            `json:"client_id,omitempty"`
+	Username      string            `json:"username,omitempty"`
+	Details       map[string]string `json:"details,omitempty"`
+}
+
+// Sink receives audit events.
+type Sink interface {
+	Write(event *Event)
+	Close()
+}
+
+// Auditor completes events and hands them to the configured sinks.
+type Auditor struct {
+	sinks []Sink
+	now   func() time.Time
+}
+
+var auditorLock = &sync.Mutex{}
+var auditorSingleton *Auditor
+
+// Configure sets the sinks used for audit events. Providing no sinks disables the audit trail.
+func Configure(sinks ...Sink) {
+	auditorLock.Lock()
+	defer auditorLock.Unlock()
+	if auditorSingleton != nil {
+		for _, sink := range auditorSingleton.sinks {
+			sink.Close()
+		}
+		auditorSingleton = nil
+	}
+	if len(sinks) > 0 {
+		auditorSingleton = &Auditor{
+			sinks: sinks,
+			now:   time.Now,
+		}
+	}
+}
+
+// Shutdown flushes and disables the current Auditor.
+func Shutdown() {
+	Configure()
+}
+
+// GetAuditorInstance returns the current Auditor, nil when the audit trail is disabled.
+func GetAuditorInstance() *Auditor {
+	auditorLock.Lock()
+	defer auditorLock.Unlock()
+	return auditorSingleton
+}
+
+// Record completes the given event with id, time and request information and writes it to all sinks.
+// The request may be nil for events not caused by a request.
+func Record(r *http.Request, event *Event) {
+	auditor := GetAuditorInstance()
+	if auditor == nil || event == nil {
+		return
+	}
+	auditor.record(r, event)
+}
+
+func (auditor *Auditor) record(r *http.Request, event *Event) {
+	event.SchemaVersion = SchemaVersion
+	event.Id = uuid.NewString()
+	event.Time = auditor.now().UTC()
+	if r != nil {
+		event.RequestId = log.GetRequestId(r.Context())
+		event.RemoteAddr = r.RemoteAddr
+	}
+	for _, sink := range auditor.sinks {
+		sink.Write(event)
+	}
+}
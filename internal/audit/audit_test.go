@@ -0,0 +1,58 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/webishdev/stopnik/log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Record(t *testing.T) {
+	t.Run("Disabled audit", func(t *testing.T) {
+		Shutdown()
+
+		Record(nil, &Event{Type: EtConfigLoaded, Outcome: OcSuccess})
+
+		if GetAuditorInstance() != nil {
+			t.Error("expected no auditor")
+		}
+	})
+
+	t.Run("Event is completed", func(t *testing.T) {
+		var buf bytes.Buffer
+		Configure(NewWriterSink(&buf))
+		defer Shutdown()
+
+		request := httptest.NewRequest(http.MethodPost, "/token", nil)
+		request = request.WithContext(log.NewRequestContext(request.Context(), "abc-123"))
+
+		Record(request, &Event{Type: EtTokenIssued, Outcome: OcSuccess, ClientId: "foo", Username: "bar", Details: map[string]string{"grant_type": "password"}})
+
+		event := Event{}
+		unmarshalError := json.Unmarshal(buf.Bytes(), &event)
+		if unmarshalError != nil {
+			t.Fatalf("expected JSON line, got '%s'", buf.String())
+		}
+
+		if event.SchemaVersion != SchemaVersion {
+			t.Errorf("expected schema version %s, got %s", SchemaVersion, event.SchemaVersion)
+		}
+		if event.Id == "" {
+			t.Error("expected event id")
+		}
+		if event.Time.IsZero() {
+			t.Error("expected event time")
+		}
+		if event.Type != EtTokenIssued || event.Outcome != OcSuccess {
+			t.Errorf("expected %s %s, got %s %s", EtTokenIssued, OcSuccess, event.Type, event.Outcome)
+		}
+		if event.RequestId != "abc-123" || event.RemoteAddr != request.RemoteAddr {
+			t.Errorf("expected request information, got %s %s", event.RequestId, event.RemoteAddr)
+		}
+		if event.ClientId != "foo" || event.Username != "bar" || event.Details["grant_type"] != "password" {
+			t.Errorf("event did not match, %v", event)
+		}
+	})
+}
@@ -0,0 +1,4 @@
+// Package audit records security relevant events like logins, token issuance and revocation.
+// Events follow a stable schema, get a unique id and are written to an append-only
+// JSON-lines file and optionally sent to a webhook. The audit trail is separate from the diagnostic log.
+package audit
@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"encoding/json"
+	"github.com/webishdev/stopnik/internal/webhook"
+	"github.com/webishdev/stopnik/log"
+	"io"
+	"os"
+	"sync"
+)
+
+type writerSink struct {
+	writer io.Writer
+	closer io.Closer
+	mux    *sync.Mutex
+}
+
+type webhookSink struct {
+	dispatcher *webhook.Dispatcher
+}
+
+// NewFileSink opens the file at the given path for appending JSON lines.
+func NewFileSink(path string) (Sink, error) {
+	file, openError := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if openError != nil {
+		return nil, openError
+	}
+	return &writerSink{
+		writer: file,
+		closer: file,
+		mux:    &sync.Mutex{},
+	}, nil
+}
+
+// NewWriterSink writes JSON lines to the given writer.
+func NewWriterSink(writer io.Writer) Sink {
+	return &writerSink{
+		writer: writer,
+		mux:    &sync.Mutex{},
+	}
+}
+
+// NewWebhookSink sends each event as JSON to the given URL.
+// Events are delivered in the background by a webhook.Dispatcher, which retries failed deliveries
+// and logs events it could not deliver.
+func NewWebhookSink(url string, queueSize int, maxRetries int) Sink {
+	subscriptions := []*webhook.Subscription{{Url: url}}
+	return &webhookSink{
+		dispatcher: webhook.NewDispatcher(subscriptions, queueSize, maxRetries, nil),
+	}
+}
+
+func (sink *writerSink) Write(event *Event) {
+	data, marshalError := json.Marshal(event)
+	if marshalError != nil {
+		log.Error("Failed to marshal audit event", "id", event.Id, log.Err(marshalError))
+		return
+	}
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	_, writeError := sink.writer.Write(append(data, '\n'))
+	if writeError != nil {
+		log.Error("Failed to write audit event", "id", event.Id, log.Err(writeError))
+	}
+}
+
+func (sink *writerSink) Close() {
+	if sink.closer == nil {
+		return
+	}
+	sink.mux.Lock()
+	defer sink.mux.Unlock()
+	closeError := sink.closer.Close()
+	if closeError != nil {
+		log.Error("Failed to close audit log", log.Err(closeError))
+	}
+}
+
+func (sink *webhookSink) Write(event *Event) {
+	sink.dispatcher.Deliver(event.Id, webhook.EventType(event.Type), event)
+}
+
+func (sink *webhookSink) Close() {
+	sink.dispatcher.Shutdown()
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_FileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	for _, eventType := range []EventType{EtLoginSuccess, EtLogout} {
+		sink, sinkError := NewFileSink(path)
+		if sinkError != nil {
+			t.Fatalf("could not open audit file, %v", sinkError)
+		}
+		Configure(sink)
+		Record(nil, &Event{Type: eventType, Outcome: OcSuccess})
+		Shutdown()
+	}
+
+	file, openError := os.Open(path)
+	if openError != nil {
+		t.Fatal(openError)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var eventTypes []EventType
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		event := Event{}
+		unmarshalError := json.Unmarshal(scanner.Bytes(), &event)
+		if unmarshalError != nil {
+			t.Fatal(unmarshalError)
+		}
+		eventTypes = append(eventTypes, event.Type)
+	}
+
+	if len(eventTypes) != 2 || eventTypes[0] != EtLoginSuccess || eventTypes[1] != EtLogout {
+		t.Errorf("expected events to be appended, got %v", eventTypes)
+	}
+}
+
+func Test_WebhookSink(t *testing.T) {
+	received := make(chan Event, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := Event{}
+		decodeError := json.NewDecoder(r.Body).Decode(&event)
+		if decodeError != nil {
+			t.Error(decodeError)
+		}
+		received <- event
+	}))
+	defer webhook.Close()
+
+	Configure(NewWebhookSink(webhook.URL, 10, 0))
+
+	Record(nil, &Event{Type: EtConfigLoaded, Outcome: OcSuccess})
+
+	Shutdown()
+
+	event := <-received
+
+	if event.Type != EtConfigLoaded || event.Id == "" {
+		t.Errorf("event did not match, %v", event)
+	}
+}
+
+func Test_WebhookSinkClosed(t *testing.T) {
+	sink := NewWebhookSink("http://localhost:0", 10, 0)
+	sink.Close()
+
+	sink.Write(&Event{Id: "1", Type: EtConfigLoaded, Outcome: OcSuccess})
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Authentication methods as described in https://datatracker.ietf.org/doc/html/rfc8176#section-2
+const (
+	AuthenticationMethodPassword string = "pwd"
+	AuthenticationMethodOtp      string = "otp"
+)
+
+// AuthenticationLevel maps an authentication context class reference to the authentication methods needed to achieve it.
+// Levels are configured from the weakest to the strongest.
+// See https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+type AuthenticationLevel struct {
+	Acr     string   `yaml:"acr"`
+	Methods []string `yaml:"methods"`
+}
+
+// GetOtpKey returns the decoded base32 TOTP secret of the user,
+// the key is empty when no secret is configured.
+func (user *User) GetOtpKey() ([]byte, error) {
+	if user.OtpSecret == "" {
+		return nil, nil
+	}
+	secret := strings.ToUpper(strings.ReplaceAll(user.OtpSecret, " ", ""))
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+}
+
+// GetAcrValues returns the configured authentication context class references, ordered from the weakest to the strongest.
+func (config *Config) GetAcrValues() []string {
+	var acrValues []string
+	for _, authenticationLevel := range config.Server.AuthenticationLevels {
+		acrValues = append(acrValues, authenticationLevel.Acr)
+	}
+	return acrValues
+}
+
+// GetAuthenticationMethods returns the authentication methods needed to achieve the given authentication context class reference.
+func (config *Config) GetAuthenticationMethods(acr string) []string {
+	for _, authenticationLevel := range config.Server.AuthenticationLevels {
+		if authenticationLevel.Acr == acr {
+			return authenticationLevel.Methods
+		}
+	}
+	return []string{}
+}
+
+// GetAcr returns the strongest authentication context class reference achieved with the given authentication methods.
+// When no level is achieved an empty string will be returned.
+func (config *Config) GetAcr(methods []string) string {
+	acr := ""
+	for _, authenticationLevel := range config.Server.AuthenticationLevels {
+		if containsAll(methods, authenticationLevel.Methods) {
+			acr = authenticationLevel.Acr
+		}
+	}
+	return acr
+}
+
+// GetRequiredAcr returns the authentication context class reference a login must achieve for the given client.
+// The first known value of the requested acr_values is required, but never less than the minimum of the client.
+// See https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func (config *Config) GetRequiredAcr(client *Client, acrValues []string) string {
+	acrIndex := slices.Index(config.GetAcrValues(), client.MinimumAcr)
+	for _, acrValue := range acrValues {
+		requestedIndex := slices.Index(config.GetAcrValues(), acrValue)
+		if requestedIndex >= 0 {
+			acrIndex = max(acrIndex, requestedIndex)
+			break
+		}
+	}
+	if acrIndex < 0 {
+		return ""
+	}
+	return config.Server.AuthenticationLevels[acrIndex].Acr
+}
+
+// SatisfiesAcr checks whether the achieved authentication context class reference is at least as strong as the required one.
+func (config *Config) SatisfiesAcr(acr string, requiredAcr string) bool {
+	if requiredAcr == "" {
+		return true
+	}
+	acrValues := config.GetAcrValues()
+	achievedIndex := slices.Index(acrValues, acr)
+	return achievedIndex >= 0 && achievedIndex >= slices.Index(acrValues, requiredAcr)
+}
+
+func (config *Config) validateAuthenticationLevels() error {
+	var acrValues []string
+	for levelIndex, authenticationLevel := range config.Server.AuthenticationLevels {
+		if authenticationLevel.Acr == "" || slices.Contains(acrValues, authenticationLevel.Acr) {
+			return fmt.Errorf("authentication level configuration invalid for level %d, acr must be unique and not empty", levelIndex)
+		}
+		if !slices.Contains(authenticationLevel.Methods, AuthenticationMethodPassword) {
+			return fmt.Errorf("authentication level configuration invalid for level %d with acr %s, methods must contain %s", levelIndex, authenticationLevel.Acr, AuthenticationMethodPassword)
+		}
+		for _, method := range authenticationLevel.Methods {
+			if method != AuthenticationMethodPassword && method != AuthenticationMethodOtp {
+				return fmt.Errorf("authentication level configuration invalid for level %d with acr %s, unsupported method %s", levelIndex, authenticationLevel.Acr, method)
+			}
+		}
+		if levelIndex > 0 && !containsAll(authenticationLevel.Methods, config.Server.AuthenticationLevels[levelIndex-1].Methods) {
+			return errors.New("authentication levels must be ordered from the weakest to the strongest")
+		}
+		acrValues = append(acrValues, authenticationLevel.Acr)
+	}
+	return nil
+}
+
+func containsAll(values []string, expected []string) bool {
+	for _, value := range expected {
+		if !slices.Contains(values, value) {
+			return false
+		}
+	}
+	return true
+}
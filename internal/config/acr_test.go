@@ -0,0 +1,162 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func testAuthenticationLevels() []AuthenticationLevel {
+	return []AuthenticationLevel{
+		{Acr: "password", Methods: []string{AuthenticationMethodPassword}},
+		{Acr: "password+otp", Methods: []string{AuthenticationMethodPassword, AuthenticationMethodOtp}},
+	}
+}
+
+func Test_GetAcr(t *testing.T) {
+	testConfig := &Config{Server: Server{AuthenticationLevels: testAuthenticationLevels()}}
+
+	if !slices.Equal(testConfig.GetAcrValues(), []string{"password", "password+otp"}) {
+		t.Errorf("unexpected acr values %v", testConfig.GetAcrValues())
+	}
+
+	if acr := testConfig.GetAcr([]string{AuthenticationMethodPassword}); acr != "password" {
+		t.Errorf("expected password acr, got %s", acr)
+	}
+
+	if acr := testConfig.GetAcr([]string{AuthenticationMethodPassword, AuthenticationMethodOtp}); acr != "password+otp" {
+		t.Errorf("expected password+otp acr, got %s", acr)
+	}
+
+	if acr := (&Config{}).GetAcr([]string{AuthenticationMethodPassword}); acr != "" {
+		t.Errorf("expected no acr without authentication levels, got %s", acr)
+	}
+
+	if !slices.Contains(testConfig.GetAuthenticationMethods("password+otp"), AuthenticationMethodOtp) {
+		t.Error("expected otp method for password+otp")
+	}
+}
+
+func Test_GetRequiredAcr(t *testing.T) {
+	type parameter struct {
+		name       string
+		minimumAcr string
+		acrValues  []string
+		expected   string
+	}
+
+	var parameters = []parameter{
+		{"nothing requested", "", nil, ""},
+		{"unknown requested", "", []string{"foo"}, ""},
+		{"requested", "", []string{"password+otp"}, "password+otp"},
+		{"first known requested", "", []string{"foo", "password", "password+otp"}, "password"},
+		{"client minimum", "password+otp", nil, "password+otp"},
+		{"client minimum exceeds requested", "password+otp", []string{"password"}, "password+otp"},
+		{"requested exceeds client minimum", "password", []string{"password+otp"}, "password+otp"},
+	}
+
+	testConfig := &Config{Server: Server{AuthenticationLevels: testAuthenticationLevels()}}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Required acr %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			client := &Client{Id: "foo", MinimumAcr: test.minimumAcr}
+
+			if requiredAcr := testConfig.GetRequiredAcr(client, test.acrValues); requiredAcr != test.expected {
+				t.Errorf("expected required acr %s, got %s", test.expected, requiredAcr)
+			}
+		})
+	}
+}
+
+func Test_SatisfiesAcr(t *testing.T) {
+	type parameter struct {
+		acr         string
+		requiredAcr string
+		expected    bool
+	}
+
+	var parameters = []parameter{
+		{"", "", true},
+		{"password", "", true},
+		{"", "password", false},
+		{"password", "password", true},
+		{"password", "password+otp", false},
+		{"password+otp", "password", true},
+		{"foo", "password", false},
+	}
+
+	testConfig := &Config{Server: Server{AuthenticationLevels: testAuthenticationLevels()}}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Acr %s satisfies %s", test.acr, test.requiredAcr)
+		t.Run(testMessage, func(t *testing.T) {
+			if satisfies := testConfig.SatisfiesAcr(test.acr, test.requiredAcr); satisfies != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, satisfies)
+			}
+		})
+	}
+}
+
+func Test_ValidateAuthenticationLevels(t *testing.T) {
+	type parameter struct {
+		name    string
+		levels  []AuthenticationLevel
+		isValid bool
+	}
+
+	var parameters = []parameter{
+		{"none", nil, true},
+		{"ordered", testAuthenticationLevels(), true},
+		{"missing acr", []AuthenticationLevel{{Methods: []string{AuthenticationMethodPassword}}}, false},
+		{"duplicate acr", []AuthenticationLevel{{Acr: "foo", Methods: []string{AuthenticationMethodPassword}}, {Acr: "foo", Methods: []string{AuthenticationMethodPassword}}}, false},
+		{"missing password", []AuthenticationLevel{{Acr: "foo", Methods: []string{AuthenticationMethodOtp}}}, false},
+		{"unsupported method", []AuthenticationLevel{{Acr: "foo", Methods: []string{AuthenticationMethodPassword, "hwk"}}}, false},
+		{"unordered", []AuthenticationLevel{testAuthenticationLevels()[1], testAuthenticationLevels()[0]}, false},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Authentication levels %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			testConfig := &Config{Server: Server{AuthenticationLevels: test.levels}}
+
+			validationError := testConfig.validateAuthenticationLevels()
+
+			if test.isValid && validationError != nil {
+				t.Errorf("expected valid authentication levels, got %v", validationError)
+			} else if !test.isValid && validationError == nil {
+				t.Error("expected invalid authentication levels")
+			}
+		})
+	}
+}
+
+func Test_GetOtpKey(t *testing.T) {
+	type parameter struct {
+		secret   string
+		expected string
+		isValid  bool
+	}
+
+	var parameters = []parameter{
+		{"", "", true},
+		{"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", "12345678901234567890", true},
+		{"gezd gnbv gy3t qojq gezd gnbv gy3t qojq", "12345678901234567890", true},
+		{"not base32!", "", false},
+	}
+
+	for index, test := range parameters {
+		testMessage := fmt.Sprintf("OTP key %d", index)
+		t.Run(testMessage, func(t *testing.T) {
+			user := &User{Username: "foo", OtpSecret: test.secret}
+
+			otpKey, otpKeyError := user.GetOtpKey()
+
+			if test.isValid && (otpKeyError != nil || string(otpKey) != test.expected) {
+				t.Errorf("expected otp key %s, got %s %v", test.expected, otpKey, otpKeyError)
+			} else if !test.isValid && otpKeyError == nil {
+				t.Error("expected invalid otp secret")
+			}
+		})
+	}
+}
@@ -2,10 +2,14 @@ package config
 
 import (
 	"bufio"
+	"bytes"
 	"cmp"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/webishdev/stopnik/internal/endpoint"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
 	"github.com/webishdev/stopnik/internal/oauth2"
 	"github.com/webishdev/stopnik/internal/store"
@@ -14,6 +18,7 @@ import (
 	"io"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 )
@@ -26,8 +31,17 @@ type Keys struct {
 
 // TLS defines the Go like address to listen to and references the necessary Keys.
 type TLS struct {
-	Addr string `yaml:"addr"`
-	Keys Keys   `yaml:"keys"`
+	Addr       string     `yaml:"addr"`
+	Keys       Keys       `yaml:"keys"`
+	ClientAuth ClientAuth `yaml:"clientAuth"`
+}
+
+// ClientAuth defines whether the TLS listener requests client certificates,
+// CA references the certificates which issue the certificates of clients using tls_client_auth.
+// See https://datatracker.ietf.org/doc/html/rfc8705
+type ClientAuth struct {
+	Enabled bool   `yaml:"enabled"`
+	CA      string `yaml:"ca"`
 }
 
 // Cookies defines the name for HTTP cookies used by STOPnik.
@@ -47,20 +61,134 @@ type ForwardAuth struct {
 	Redirects     []string `yaml:"redirects"`
 }
 
+// Metrics defines the configuration of the Prometheus metrics endpoint.
+// When Addr is provided the endpoint will be served on a separate listener.
+type Metrics struct {
+	Enabled  bool   `yaml:"enabled"`
+	Addr     string `yaml:"addr"`
+	Endpoint string `yaml:"endpoint"`
+}
+
+// Tracing defines the configuration of OpenTelemetry tracing.
+// Exporter can either be stdout or otlp, Endpoint is only used for otlp.
+type Tracing struct {
+	Enabled     bool   `yaml:"enabled"`
+	Exporter    string `yaml:"exporter"`
+	Endpoint    string `yaml:"endpoint"`
+	ServiceName string `yaml:"serviceName"`
+}
+
+// LogFile defines a file used for log output, which will be rotated when reaching MaxSizeMB.
+type LogFile struct {
+	Path       string `yaml:"path"`
+	MaxSizeMB  int    `yaml:"maxSizeMB"`
+	MaxBackups *int   `yaml:"maxBackups"`
+}
+
+// AccessLog defines the format and output of the access log.
+// Format can either be text, json or combined for the Apache combined log format.
+type AccessLog struct {
+	Format string  `yaml:"format"`
+	File   LogFile `yaml:"file"`
+}
+
+// Logging defines the format and output of the log.
+// Format can either be text or json.
+type Logging struct {
+	Format    string    `yaml:"format"`
+	File      LogFile   `yaml:"file"`
+	AccessLog AccessLog `yaml:"accessLog"`
+}
+
+// Audit defines the audit trail for security relevant events.
+// Events are appended as JSON lines to File and, when provided, also sent to Webhook.
+type Audit struct {
+	Enabled bool   `yaml:"enabled"`
+	File    string `yaml:"file"`
+	Webhook string `yaml:"webhook"`
+}
+
+// WebhookSubscription defines an endpoint which receives events signed with Secret.
+// When no Events are provided all events will be sent.
+type WebhookSubscription struct {
+	Url    string   `yaml:"url"`
+	Secret string   `yaml:"secret"`
+	Events []string `yaml:"events"`
+}
+
+// Webhooks defines the subscriptions for authentication events and how deliveries are handled.
+// Deliveries which failed after MaxRetries will be appended to DeadLetterFile.
+type Webhooks struct {
+	Subscriptions  []WebhookSubscription `yaml:"subscriptions"`
+	MaxRetries     int                   `yaml:"maxRetries"`
+	QueueSize      int                   `yaml:"queueSize"`
+	DeadLetterFile string                `yaml:"deadLetterFile"`
+}
+
+// TrustedIssuer defines an issuer of JWT authorization grants and the PEM file with its public key.
+// Subjects are mapped to configured users, unless ServiceAccount is set, then the subject is used as service identity.
+// MaxLifetime limits the time between issuing and expiration of an assertion in minutes.
+type TrustedIssuer struct {
+	Issuer         string `yaml:"issuer"`
+	PublicKey      string `yaml:"publicKey"`
+	ServiceAccount bool   `yaml:"serviceAccount"`
+	MaxLifetime    int    `yaml:"maxLifetime"`
+}
+
+// OpaqueToken defines the prefixes of opaque access and refresh tokens,
+// which allow secret scanners to detect leaked tokens.
+type OpaqueToken struct {
+	AccessPrefix  string `yaml:"accessPrefix"`
+	RefreshPrefix string `yaml:"refreshPrefix"`
+}
+
+// DPoP defines the handling of DPoP proofs,
+// when RequireNonce is set, proofs at the token endpoint must contain a nonce provided by STOPnik.
+// See https://datatracker.ietf.org/doc/html/rfc9449
+type DPoP struct {
+	RequireNonce bool `yaml:"requireNonce"`
+}
+
+// Registration defines the dynamic client registration, which is only available when enabled.
+// Requests must either provide the InitialAccessToken or an access token with the Scope as bearer token.
+// When File is provided, registered clients are persisted and loaded again on startup.
+// Registered clients may only use the refresh_token grant type when RefreshTTL is provided.
+// See https://datatracker.ietf.org/doc/html/rfc7591
+type Registration struct {
+	Enabled            bool   `yaml:"enabled"`
+	InitialAccessToken string `yaml:"initialAccessToken"`
+	Scope              string `yaml:"scope"`
+	File               string `yaml:"file"`
+	RefreshTTL         int    `yaml:"refreshTTL"`
+}
+
 // Server defines the main STOPnik server configuration.
 type Server struct {
-	LogLevel              string      `yaml:"logLevel"`
-	Addr                  string      `yaml:"addr"`
-	Cookies               Cookies     `yaml:"cookies"`
-	Secret                string      `yaml:"secret"`
-	PrivateKey            string      `yaml:"privateKey"`
-	TLS                   TLS         `yaml:"tls"`
-	LogoutRedirect        string      `yaml:"logoutRedirect"`
-	IntrospectScope       string      `yaml:"introspectScope"`
-	RevokeScope           string      `yaml:"revokeScopeScope"`
-	SessionTimeoutSeconds int         `yaml:"sessionTimeoutSeconds"`
-	Issuer                string      `yaml:"issuer"`
-	ForwardAuth           ForwardAuth `yaml:"forwardAuth"`
+	LogLevel              string                `yaml:"logLevel"`
+	Addr                  string                `yaml:"addr"`
+	Cookies               Cookies               `yaml:"cookies"`
+	Secret                string                `yaml:"secret"`
+	PairwiseSalt          string                `yaml:"pairwiseSalt"`
+	AuthenticationLevels  []AuthenticationLevel `yaml:"authenticationLevels"`
+	PrivateKey            string                `yaml:"privateKey"`
+	EncryptionKey         string                `yaml:"encryptionKey"`
+	TLS                   TLS                   `yaml:"tls"`
+	LogoutRedirect        string                `yaml:"logoutRedirect"`
+	IntrospectScope       string                `yaml:"introspectScope"`
+	RevokeScope           string                `yaml:"revokeScopeScope"`
+	SessionTimeoutSeconds int                   `yaml:"sessionTimeoutSeconds"`
+	Issuer                string                `yaml:"issuer"`
+	ForwardAuth           ForwardAuth           `yaml:"forwardAuth"`
+	Metrics               Metrics               `yaml:"metrics"`
+	Tracing               Tracing               `yaml:"tracing"`
+	Logging               Logging               `yaml:"logging"`
+	Audit                 Audit                 `yaml:"audit"`
+	Webhooks              Webhooks              `yaml:"webhooks"`
+	TrustedIssuers        []TrustedIssuer       `yaml:"trustedIssuers"`
+	OpaqueToken           OpaqueToken           `yaml:"opaqueToken"`
+	DPoP                  DPoP                  `yaml:"dpop"`
+	Registration          Registration          `yaml:"registration"`
+	OfflineTokenFile      string                `yaml:"offlineTokenFile"`
 }
 
 // UserAddress defines the address for a specific user,
@@ -107,6 +235,7 @@ type User struct {
 	Username        string          `yaml:"username"`
 	Password        string          `yaml:"password"`
 	Salt            string          `yaml:"salt"`
+	OtpSecret       string          `yaml:"otpSecret"`
 	UserProfile     UserProfile     `yaml:"userProfile"`
 	UserInformation UserInformation `yaml:"userInformation"`
 }
@@ -128,21 +257,83 @@ type Claim interface {
 
 // Client defines the general client entry in the configuration.
 type Client struct {
-	Id                      string   `yaml:"id"`
-	ClientSecret            string   `yaml:"clientSecret"`
-	Salt                    string   `yaml:"salt"`
-	Oidc                    bool     `yaml:"oidc"`
-	AccessTTL               int      `yaml:"accessTTL"`
-	RefreshTTL              int      `yaml:"refreshTTL"`
-	IdTTL                   int      `yaml:"idTTL"`
-	Introspect              bool     `yaml:"introspect"`
-	Revoke                  bool     `yaml:"revoke"`
-	Redirects               []string `yaml:"redirects"`
-	OpaqueToken             bool     `yaml:"opaqueToken"`
-	PasswordFallbackAllowed bool     `yaml:"passwordFallbackAllowed"`
-	Audience                []string `yaml:"audience"`
-	PrivateKey              string   `yaml:"privateKey"`
-	isForwardAuth           bool
+	Id                         string             `yaml:"id"`
+	ClientSecret               string             `yaml:"clientSecret"`
+	Salt                       string             `yaml:"salt"`
+	Oidc                       bool               `yaml:"oidc"`
+	AccessTTL                  int                `yaml:"accessTTL"`
+	RefreshTTL                 int                `yaml:"refreshTTL"`
+	RefreshMaxTTL              int                `yaml:"refreshMaxTTL"`
+	RefreshSliding             bool               `yaml:"refreshSliding"`
+	IdTTL                      int                `yaml:"idTTL"`
+	Introspect                 bool               `yaml:"introspect"`
+	IntrospectJWT              bool               `yaml:"introspectJWT"`
+	Revoke                     bool               `yaml:"revoke"`
+	Redirects                  []string           `yaml:"redirects"`
+	OpaqueToken                bool               `yaml:"opaqueToken"`
+	LegacyAccessToken          bool               `yaml:"legacyAccessToken"`
+	PasswordFallbackAllowed    bool               `yaml:"passwordFallbackAllowed"`
+	Audience                   []string           `yaml:"audience"`
+	ExchangeAudiences          []string           `yaml:"exchangeAudiences"`
+	PrivateKey                 string             `yaml:"privateKey"`
+	ClientCertificate          ClientCertificate  `yaml:"clientCertificate"`
+	RequestObjectKey           string             `yaml:"requestObjectKey"`
+	RequestUris                []string           `yaml:"requestUris"`
+	RequireSignedRequestObject bool               `yaml:"requireSignedRequestObject"`
+	AllowUnsignedRequestObject bool               `yaml:"allowUnsignedRequestObject"`
+	SubjectType                string             `yaml:"subjectType"`
+	SectorIdentifierUri        string             `yaml:"sectorIdentifierUri"`
+	Encryption                 ClientEncryption   `yaml:"encryption"`
+	UserInfoSignedResponseAlg  string             `yaml:"userInfoSignedResponseAlg"`
+	MinimumAcr                 string             `yaml:"minimumAcr"`
+	AssertionIssuers           []string           `yaml:"assertionIssuers"`
+	CertificateBoundTokens     bool               `yaml:"certificateBoundTokens"`
+	GrantTypes                 []oauth2.GrantType `yaml:"grantTypes"`
+	ResponseTypes              []string           `yaml:"responseTypes"`
+	isForwardAuth              bool
+	isRegistered               bool
+}
+
+// ClientCertificate defines the TLS client certificate a Client authenticates with.
+// Either the certificate is issued by the configured CA and matches the subject DN or one of the SAN values,
+// or it is a self-signed certificate matching one of the SHA256 Thumbprints.
+// See https://datatracker.ietf.org/doc/html/rfc8705#section-2
+type ClientCertificate struct {
+	SubjectDN   string   `yaml:"subjectDN"`
+	SanDNS      string   `yaml:"sanDNS"`
+	SanURI      string   `yaml:"sanURI"`
+	SanIP       string   `yaml:"sanIP"`
+	SanEmail    string   `yaml:"sanEmail"`
+	Thumbprints []string `yaml:"thumbprints"`
+}
+
+// ClientEncryption defines the public key ID tokens and optionally userinfo responses are encrypted with for a Client.
+// Key is either a PEM encoded public key or a JWKS file, Algorithm and ContentEncryption default by the type of the key.
+// See https://openid.net/specs/openid-connect-core-1_0.html#Encryption
+type ClientEncryption struct {
+	Key               string `yaml:"key"`
+	Algorithm         string `yaml:"alg"`
+	ContentEncryption string `yaml:"enc"`
+	UserInfo          bool   `yaml:"userInfo"`
+}
+
+// Resource defines a protected resource which can be requested with the resource parameter.
+// Scopes limits the scopes of access tokens for the resource, all scopes are allowed when empty.
+// See https://datatracker.ietf.org/doc/html/rfc8707
+type Resource struct {
+	Id         string   `yaml:"id"`
+	Scopes     []string `yaml:"scopes"`
+	AccessTTL  int      `yaml:"accessTTL"`
+	PrivateKey string   `yaml:"privateKey"`
+}
+
+// AuthorizationDetailType defines a type of authorization details which can be requested by clients.
+// Requested authorization details of the type are validated against the Schema, a subset of JSON Schema.
+// See https://datatracker.ietf.org/doc/html/rfc9396
+type AuthorizationDetailType struct {
+	Type        string         `yaml:"type"`
+	Description string         `yaml:"description"`
+	Schema      map[string]any `yaml:"schema"`
 }
 
 // UI defines the general web user interface entry in the configuration.
@@ -172,17 +363,20 @@ type Classification struct {
 
 // Config defines the root entry for the configuration.
 type Config struct {
-	Server            Server           `yaml:"server"`
-	Clients           []Client         `yaml:"clients"`
-	Users             []User           `yaml:"users"`
-	UI                UI               `yaml:"ui"`
-	Classification    []Classification `yaml:"classification"`
-	generatedSecret   string
-	userMap           map[string]*User
-	clientMap         map[string]*Client
-	oidc              bool
-	logoImage         *[]byte
-	forwardAuthClient *Client
+	Server                   Server                    `yaml:"server"`
+	Clients                  []Client                  `yaml:"clients"`
+	Users                    []User                    `yaml:"users"`
+	Resources                []Resource                `yaml:"resources"`
+	AuthorizationDetailTypes []AuthorizationDetailType `yaml:"authorizationDetailTypes"`
+	UI                       UI                        `yaml:"ui"`
+	Classification           []Classification          `yaml:"classification"`
+	generatedSecret          string
+	userMap                  map[string]*User
+	clientMap                map[string]*Client
+	oidc                     bool
+	logoImage                *[]byte
+	forwardAuthClient        *Client
+	registeredClients        *registeredClients
 }
 
 var configLock = &sync.Mutex{}
@@ -203,6 +397,7 @@ func GetConfigInstance() *Config {
 // Initialize initializes a given Config.
 // Checks for OIDC configuration on given Client entries.
 // Initializes maps for faster Client and User access in the Config.
+// Loads the clients registered at runtime when a registration file exists.
 // Generates a server secret when none was provided.
 // Loads a logo image into []byte to use in the web user interface.
 // Checks for ForwardAuth settings.
@@ -231,6 +426,12 @@ func Initialize(config *Config) error {
 		return clientMapError
 	}
 
+	var registeredClientsError error
+	config.registeredClients, registeredClientsError = newRegisteredClients(config.Server.Registration.File, config.Server.Registration.RefreshTTL)
+	if registeredClientsError != nil {
+		return registeredClientsError
+	}
+
 	randomString, randomError := generateRandomString(16)
 	if randomError != nil {
 		return randomError
@@ -261,7 +462,7 @@ func Initialize(config *Config) error {
 			return bufferError
 		}
 
-		log.Info("Own logo loaded from %s", config.UI.LogoImage)
+		log.Info("Own logo loaded", "file", config.UI.LogoImage)
 		config.logoImage = &bs
 	}
 
@@ -314,6 +515,60 @@ func (config *Config) Validate() error {
 		return errors.New("external url in forward auth is missing or empty")
 	}
 
+	if config.GetLogFormat() != "text" && config.GetLogFormat() != "json" {
+		return fmt.Errorf("unsupported log format %s, use text or json", config.Server.Logging.Format)
+	}
+
+	if config.GetAccessLogFormat() != "text" && config.GetAccessLogFormat() != "json" && config.GetAccessLogFormat() != "combined" {
+		return fmt.Errorf("unsupported access log format %s, use text, json or combined", config.Server.Logging.AccessLog.Format)
+	}
+
+	if config.Server.Audit.Enabled && config.Server.Audit.File == "" && config.Server.Audit.Webhook == "" {
+		return errors.New("audit file or webhook is missing")
+	}
+
+	for _, subscription := range config.Server.Webhooks.Subscriptions {
+		if subscription.Url == "" || subscription.Secret == "" {
+			return errors.New("webhook subscription url or secret is missing")
+		}
+	}
+
+	for _, trustedIssuer := range config.Server.TrustedIssuers {
+		if trustedIssuer.Issuer == "" || trustedIssuer.PublicKey == "" {
+			return errors.New("trusted issuer or public key is missing")
+		}
+	}
+
+	for resourceIndex, resource := range config.Resources {
+		resourceURL, resourceParseError := url.Parse(resource.Id)
+		if resource.Id == "" || resourceParseError != nil || !resourceURL.IsAbs() || resourceURL.Fragment != "" {
+			invalidResource := fmt.Sprintf("resource configuration invalid for resource %d, id must be an absolute URI without fragment %v", resourceIndex, resource)
+			return errors.New(invalidResource)
+		}
+	}
+
+	for _, authorizationDetailType := range config.AuthorizationDetailTypes {
+		if authorizationDetailType.Type == "" {
+			return errors.New("authorization details type is missing")
+		}
+	}
+
+	if config.Server.Tracing.Enabled && config.GetTracingExporter() != "stdout" && config.GetTracingExporter() != "otlp" {
+		return fmt.Errorf("unsupported tracing exporter %s, use stdout or otlp", config.Server.Tracing.Exporter)
+	}
+
+	if config.Server.Registration.Enabled && config.Server.Registration.InitialAccessToken == "" && config.Server.Registration.Scope == "" {
+		return errors.New("registration initial access token or scope is missing")
+	}
+
+	if config.Server.EncryptionKey != "" && config.Server.EncryptionKey == config.Server.PrivateKey {
+		return errors.New("encryption key should not equal private key")
+	}
+
+	if config.GetOpaqueAccessTokenPrefix() == config.GetOpaqueRefreshTokenPrefix() {
+		return errors.New("opaque access token prefix should not equal opaque refresh token prefix")
+	}
+
 	if config.GetAuthCookieName() == config.GetForwardAuthCookieName() {
 		return errors.New("auth cookie name should not equal forward auth cookie name")
 	}
@@ -326,6 +581,11 @@ func (config *Config) Validate() error {
 		return errors.New("forward auth cookie name should not equal message cookie name")
 	}
 
+	authenticationLevelError := config.validateAuthenticationLevels()
+	if authenticationLevelError != nil {
+		return authenticationLevelError
+	}
+
 	if len(config.Users) == 0 {
 		return errors.New("no users configured, add at least one user")
 	}
@@ -344,6 +604,11 @@ func (config *Config) Validate() error {
 			invalidUser := fmt.Sprintf("user configuration invalid for user %d with username %s, missing password %v", userIndex, user.Username, user)
 			return errors.New(invalidUser)
 		}
+
+		if _, otpKeyError := user.GetOtpKey(); otpKeyError != nil {
+			invalidUser := fmt.Sprintf("user configuration invalid for user %d with username %s, invalid otp secret", userIndex, user.Username)
+			return errors.New(invalidUser)
+		}
 	}
 
 	for clientIndex, client := range config.Clients {
@@ -356,6 +621,65 @@ func (config *Config) Validate() error {
 			invalidClient := fmt.Sprintf("client configuration invalid, for client %d with id %s, missing redirects, %v", clientIndex, client.Id, client)
 			return errors.New(invalidClient)
 		}
+
+		pairwiseError := config.validatePairwiseSubject(&client)
+		if pairwiseError != nil {
+			return fmt.Errorf("client configuration invalid, for client %d with id %s, %w", clientIndex, client.Id, pairwiseError)
+		}
+
+		certificateError := config.validateClientCertificate(&client)
+		if certificateError != nil {
+			return fmt.Errorf("client configuration invalid, for client %d with id %s, %w", clientIndex, client.Id, certificateError)
+		}
+
+		if client.MinimumAcr != "" && !slices.Contains(config.GetAcrValues(), client.MinimumAcr) {
+			invalidClient := fmt.Sprintf("client configuration invalid, for client %d with id %s, unknown minimum acr %s", clientIndex, client.Id, client.MinimumAcr)
+			return errors.New(invalidClient)
+		}
+
+		if client.RefreshMaxTTL > 0 && client.RefreshMaxTTL < client.RefreshTTL {
+			invalidClient := fmt.Sprintf("client configuration invalid, for client %d with id %s, refreshMaxTTL must not be less than refreshTTL", clientIndex, client.Id)
+			return errors.New(invalidClient)
+		}
+
+		// signed introspection and userinfo responses are verified with the public keys, not with the server secret
+		if (client.IntrospectJWT || client.UserInfoSignedResponseAlg != "") && client.PrivateKey == "" && config.Server.PrivateKey == "" {
+			invalidClient := fmt.Sprintf("client configuration invalid, for client %d with id %s, signed responses require a private key", clientIndex, client.Id)
+			return errors.New(invalidClient)
+		}
+
+		if client.UserInfoSignedResponseAlg != "" {
+			privateKey := cmp.Or(client.PrivateKey, config.Server.PrivateKey)
+			if signingError := validateSigningAlgorithm(privateKey, client.UserInfoSignedResponseAlg); signingError != nil {
+				invalidClient := fmt.Sprintf("client configuration invalid, for client %d with id %s, %v", clientIndex, client.Id, signingError)
+				return errors.New(invalidClient)
+			}
+		}
+
+		if client.RequireSignedRequestObject && client.AllowUnsignedRequestObject {
+			invalidClient := fmt.Sprintf("client configuration invalid, for client %d with id %s, unsigned request objects can not be allowed when signed request objects are required", clientIndex, client.Id)
+			return errors.New(invalidClient)
+		}
+
+		for _, assertionIssuer := range client.AssertionIssuers {
+			if _, trustedIssuerExists := config.GetTrustedIssuer(assertionIssuer); !trustedIssuerExists {
+				invalidClient := fmt.Sprintf("client configuration invalid, for client %d with id %s, assertion issuer %s is not trusted", clientIndex, client.Id, assertionIssuer)
+				return errors.New(invalidClient)
+			}
+		}
+
+		encryption := client.Encryption
+		if encryption.Key == "" && (encryption.UserInfo || encryption.Algorithm != "" || encryption.ContentEncryption != "") {
+			invalidClient := fmt.Sprintf("client configuration invalid, for client %d with id %s, encryption requires a key", clientIndex, client.Id)
+			return errors.New(invalidClient)
+		}
+
+		if encryption.Key != "" {
+			if encryptionError := validateEncryption(&encryption); encryptionError != nil {
+				invalidClient := fmt.Sprintf("client configuration invalid, for client %d with id %s, %v", clientIndex, client.Id, encryptionError)
+				return errors.New(invalidClient)
+			}
+		}
 	}
 
 	for i := 0; i < len(config.Classification); i++ {
@@ -374,6 +698,94 @@ func (config *Config) Validate() error {
 	return nil
 }
 
+// validateSigningAlgorithm checks that the private key can be loaded and signs with the given algorithm.
+func validateSigningAlgorithm(privateKey string, algorithm string) error {
+	keyBytes, readError := os.ReadFile(privateKey)
+	if readError != nil {
+		return fmt.Errorf("private key could not be read: %w", readError)
+	}
+
+	key, parseError := jwk.ParseKey(keyBytes, jwk.WithPEM(true))
+	if parseError != nil {
+		return fmt.Errorf("private key could not be parsed: %w", parseError)
+	}
+
+	var keyAlgorithm jwa.SignatureAlgorithm
+	switch typedKey := key.(type) {
+	case jwk.RSAPrivateKey:
+		keyAlgorithm = jwa.RS256
+	case jwk.ECDSAPrivateKey:
+		switch typedKey.Crv() {
+		case jwa.P256:
+			keyAlgorithm = jwa.ES256
+		case jwa.P384:
+			keyAlgorithm = jwa.ES384
+		case jwa.P521:
+			keyAlgorithm = jwa.ES512
+		}
+	}
+
+	if keyAlgorithm == "" {
+		return errors.New("private key is not supported for signing")
+	} else if keyAlgorithm.String() != algorithm {
+		return fmt.Errorf("signing algorithm %s does not match algorithm %s of private key", algorithm, keyAlgorithm)
+	}
+
+	return nil
+}
+
+// validateEncryption checks that the encryption key can be loaded and the configured algorithms are supported for its key type.
+func validateEncryption(encryption *ClientEncryption) error {
+	keyBytes, readError := os.ReadFile(encryption.Key)
+	if readError != nil {
+		return fmt.Errorf("encryption key could not be read: %w", readError)
+	}
+
+	var keyType jwa.KeyType
+	if bytes.HasPrefix(bytes.TrimSpace(keyBytes), []byte("{")) {
+		keySet, parseError := jwk.Parse(keyBytes)
+		if parseError != nil {
+			return fmt.Errorf("encryption key could not be parsed: %w", parseError)
+		}
+		for index := 0; index < keySet.Len() && keyType == ""; index++ {
+			key, _ := keySet.Key(index)
+			if key.KeyUsage() == "" || key.KeyUsage() == string(jwk.ForEncryption) {
+				keyType = key.KeyType()
+			}
+		}
+	} else {
+		key, parseError := jwk.ParseKey(keyBytes, jwk.WithPEM(true))
+		if parseError != nil {
+			return fmt.Errorf("encryption key could not be parsed: %w", parseError)
+		}
+		keyType = key.KeyType()
+	}
+
+	switch jwa.KeyEncryptionAlgorithm(encryption.Algorithm) {
+	case "":
+		if keyType != jwa.RSA && keyType != jwa.EC && keyType != jwa.OKP {
+			return fmt.Errorf("encryption key type %s is not supported", keyType)
+		}
+	case jwa.RSA_OAEP, jwa.RSA_OAEP_256:
+		if keyType != jwa.RSA {
+			return fmt.Errorf("encryption algorithm %s requires a RSA key", encryption.Algorithm)
+		}
+	case jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW:
+		if keyType != jwa.EC && keyType != jwa.OKP {
+			return fmt.Errorf("encryption algorithm %s requires an EC key", encryption.Algorithm)
+		}
+	default:
+		return fmt.Errorf("encryption algorithm %s is not supported", encryption.Algorithm)
+	}
+
+	switch jwa.ContentEncryptionAlgorithm(encryption.ContentEncryption) {
+	case "", jwa.A128GCM, jwa.A192GCM, jwa.A256GCM, jwa.A128CBC_HS256, jwa.A192CBC_HS384, jwa.A256CBC_HS512:
+		return nil
+	default:
+		return fmt.Errorf("content encryption algorithm %s is not supported", encryption.ContentEncryption)
+	}
+}
+
 // GetUser returns a User for the given username.
 // Also returns a bool which indicates, whether the User exists or not.
 func (config *Config) GetUser(username string) (*User, bool) {
@@ -381,16 +793,89 @@ func (config *Config) GetUser(username string) (*User, bool) {
 	return value, exists
 }
 
-// GetClient returns a Client for the given clientId.
+// GetClient returns a Client for the given clientId, clients registered at runtime are included.
 // Also returns a bool which indicates, whether the Client exists or not.
 func (config *Config) GetClient(clientId string) (*Client, bool) {
 	value, exists := config.clientMap[clientId]
 	if !exists && config.forwardAuthClient != nil && config.forwardAuthClient.Id == clientId {
 		return config.forwardAuthClient, true
 	}
+	if !exists {
+		registeredClient, registeredClientExists := config.GetRegisteredClient(clientId)
+		if registeredClientExists {
+			return registeredClient.GetClient(), true
+		}
+	}
 	return value, exists
 }
 
+// GetTrustedIssuer returns a TrustedIssuer for a given issuer and a bool indicating whether the issuer is trusted or not.
+func (config *Config) GetTrustedIssuer(issuer string) (*TrustedIssuer, bool) {
+	for index := range config.Server.TrustedIssuers {
+		if config.Server.TrustedIssuers[index].Issuer == issuer {
+			return &config.Server.TrustedIssuers[index], true
+		}
+	}
+	return nil, false
+}
+
+// GetResource returns a Resource for a given resource identifier and a bool indicating whether the resource exists or not.
+func (config *Config) GetResource(id string) (*Resource, bool) {
+	for index := range config.Resources {
+		if config.Resources[index].Id == id {
+			return &config.Resources[index], true
+		}
+	}
+	return nil, false
+}
+
+// GetAuthorizationDetailType returns an AuthorizationDetailType for a given type and a bool indicating whether the type exists or not.
+func (config *Config) GetAuthorizationDetailType(authorizationDetailType string) (*AuthorizationDetailType, bool) {
+	for index := range config.AuthorizationDetailTypes {
+		if config.AuthorizationDetailTypes[index].Type == authorizationDetailType {
+			return &config.AuthorizationDetailTypes[index], true
+		}
+	}
+	return nil, false
+}
+
+// GetAuthorizationDetailTypes returns the names of all configured authorization details types.
+func (config *Config) GetAuthorizationDetailTypes() []string {
+	var result []string
+	for _, authorizationDetailType := range config.AuthorizationDetailTypes {
+		result = append(result, authorizationDetailType.Type)
+	}
+	return result
+}
+
+// GetClientAuthMethods returns the supported client authentication methods,
+// TLS client authentication is only supported when enabled.
+func (config *Config) GetClientAuthMethods() []oauth2.ClientAuthMethod {
+	result := []oauth2.ClientAuthMethod{oauth2.CamClientSecretBasic, oauth2.CamClientSecretPost}
+	clientAuth := config.Server.TLS.ClientAuth
+	if clientAuth.Enabled && clientAuth.CA != "" {
+		result = append(result, oauth2.CamTlsClientAuth)
+	}
+	if clientAuth.Enabled {
+		result = append(result, oauth2.CamSelfSignedTlsClientAuth)
+	}
+	return result
+}
+
+// GetRequireSignedRequestObject returns whether all clients must send signed request objects,
+// see https://datatracker.ietf.org/doc/html/rfc9101#section-10.5
+func (config *Config) GetRequireSignedRequestObject() bool {
+	if len(config.Clients) == 0 {
+		return false
+	}
+	for _, client := range config.Clients {
+		if !client.RequireSignedRequestObject {
+			return false
+		}
+	}
+	return true
+}
+
 // GetAuthCookieName returns the name of the authentication cookie.
 // When no name is provided a default value will be returned.
 func (config *Config) GetAuthCookieName() string {
@@ -409,6 +894,18 @@ func (config *Config) GetForwardAuthCookieName() string {
 	return cmp.Or(config.Server.Cookies.ForwardAuthName, "stopnik_forward_auth")
 }
 
+// GetOpaqueAccessTokenPrefix returns the prefix of opaque access tokens.
+// When no prefix is provided a default value will be returned.
+func (config *Config) GetOpaqueAccessTokenPrefix() string {
+	return cmp.Or(config.Server.OpaqueToken.AccessPrefix, "stpa_")
+}
+
+// GetOpaqueRefreshTokenPrefix returns the prefix of opaque refresh tokens.
+// When no prefix is provided a default value will be returned.
+func (config *Config) GetOpaqueRefreshTokenPrefix() string {
+	return cmp.Or(config.Server.OpaqueToken.RefreshPrefix, "stpr_")
+}
+
 // GetSessionTimeoutSeconds returns the session timeout in seconds.
 // When no session timeout is provided a default value will be returned.
 func (config *Config) GetSessionTimeoutSeconds() int {
@@ -507,6 +1004,90 @@ func (config *Config) GetForwardAuthParameterName() string {
 	return cmp.Or(config.Server.ForwardAuth.ParameterName, "forward_id")
 }
 
+// GetMetricsEnabled returns whether the Prometheus metrics endpoint is enabled or not.
+func (config *Config) GetMetricsEnabled() bool {
+	return config.Server.Metrics.Enabled
+}
+
+// GetMetricsAddr returns the address of a separate listener for the Prometheus metrics endpoint.
+// When no address is provided the endpoint will be served by the main server.
+func (config *Config) GetMetricsAddr() string {
+	return config.Server.Metrics.Addr
+}
+
+// GetMetricsEndpoint returns the endpoint which will be used for Prometheus metrics.
+// When no endpoint is provided a default value will be returned.
+func (config *Config) GetMetricsEndpoint() string {
+	return cmp.Or(config.Server.Metrics.Endpoint, endpoint.Metrics)
+}
+
+// GetTracingEnabled returns whether OpenTelemetry tracing is enabled or not.
+func (config *Config) GetTracingEnabled() bool {
+	return config.Server.Tracing.Enabled
+}
+
+// GetTracingExporter returns the exporter used for finished spans.
+// When no exporter is provided a default value will be returned.
+func (config *Config) GetTracingExporter() string {
+	return cmp.Or(config.Server.Tracing.Exporter, "stdout")
+}
+
+// GetTracingEndpoint returns the OTLP/HTTP endpoint spans will be sent to.
+// When no endpoint is provided a default value will be returned.
+func (config *Config) GetTracingEndpoint() string {
+	return cmp.Or(config.Server.Tracing.Endpoint, "http://localhost:4318/v1/traces")
+}
+
+// GetTracingServiceName returns the service name reported with each span.
+// When no service name is provided a default value will be returned.
+func (config *Config) GetTracingServiceName() string {
+	return cmp.Or(config.Server.Tracing.ServiceName, "stopnik")
+}
+
+// GetLogFormat returns the format of the log output.
+// When no format is provided a default value will be returned.
+func (config *Config) GetLogFormat() string {
+	return cmp.Or(config.Server.Logging.Format, "text")
+}
+
+// GetAccessLogFormat returns the format of the access log output.
+// When no format is provided a default value will be returned.
+func (config *Config) GetAccessLogFormat() string {
+	return cmp.Or(config.Server.Logging.AccessLog.Format, "text")
+}
+
+// GetMaxSize returns the size in bytes a log file can reach before it will be rotated.
+// When no size is provided a default value will be returned.
+func (logFile *LogFile) GetMaxSize() int64 {
+	return int64(cmp.Or(logFile.MaxSizeMB, 10)) * 1024 * 1024
+}
+
+// GetMaxBackups returns the number of rotated log files to keep.
+// When no number is provided a default value will be returned, zero keeps no rotated files.
+func (logFile *LogFile) GetMaxBackups() int {
+	if logFile.MaxBackups == nil {
+		return 3
+	}
+	return max(*logFile.MaxBackups, 0)
+}
+
+// GetAuditEnabled returns whether the audit trail is enabled or not.
+func (config *Config) GetAuditEnabled() bool {
+	return config.Server.Audit.Enabled
+}
+
+// GetWebhookMaxRetries returns how often a failed webhook delivery will be retried.
+// When no number is provided a default value will be returned.
+func (config *Config) GetWebhookMaxRetries() int {
+	return cmp.Or(config.Server.Webhooks.MaxRetries, 3)
+}
+
+// GetWebhookQueueSize returns the number of webhook deliveries which can be queued.
+// When no size is provided a default value will be returned.
+func (config *Config) GetWebhookQueueSize() int {
+	return cmp.Or(config.Server.Webhooks.QueueSize, 1024)
+}
+
 // GetForwardAuthClient return a Client used for Traefik Forward Auth,
 // also returns a bool indicating whether such a Client exists or not.
 func (config *Config) GetForwardAuthClient() (*Client, bool) {
@@ -572,6 +1153,12 @@ func (client *Client) GetRefreshTTL() int {
 	return cmp.Or(client.RefreshTTL, 0)
 }
 
+// GetRefreshMaxTTL returns the absolute maximum lifetime of refresh tokens issued for one grant,
+// which is not extended by sliding expiration. Zero means no maximum lifetime.
+func (client *Client) GetRefreshMaxTTL() int {
+	return cmp.Or(client.RefreshMaxTTL, 0)
+}
+
 // GetIdTTL returns id token time to live.
 // When no time to live is provided a default value will be returned.
 func (client *Client) GetIdTTL() int {
@@ -585,21 +1172,137 @@ func (client *Client) GetAudience() []string {
 }
 
 // GetClientType returns the client type value.
-// When no client secret is provided the client will be a public client, confidential otherwise.
+// When neither a client secret nor a client certificate is provided the client will be a public client, confidential otherwise.
 // See oauth2.ClientType
 func (client *Client) GetClientType() oauth2.ClientType {
-	if client.ClientSecret == "" {
+	_, certificateAuth := client.GetCertificateAuthMethod()
+	if client.ClientSecret == "" && !certificateAuth {
 		return oauth2.CtPublic
 	} else {
 		return oauth2.CtConfidential
 	}
 }
 
+// GetCertificateAuthMethod returns the authentication method of a client authenticating with a TLS client certificate,
+// see https://datatracker.ietf.org/doc/html/rfc8705#section-2
+func (client *Client) GetCertificateAuthMethod() (oauth2.ClientAuthMethod, bool) {
+	if len(client.ClientCertificate.getSubjectValues()) > 0 {
+		return oauth2.CamTlsClientAuth, true
+	} else if len(client.ClientCertificate.Thumbprints) > 0 {
+		return oauth2.CamSelfSignedTlsClientAuth, true
+	}
+	return "", false
+}
+
+// IsCertificateBound checks whether access tokens of the Client are bound to the TLS client certificate of the request,
+// which is the case for clients authenticating with a certificate or with CertificateBoundTokens set.
+// See https://datatracker.ietf.org/doc/html/rfc8705#section-3.4
+func (client *Client) IsCertificateBound() bool {
+	_, certificateAuth := client.GetCertificateAuthMethod()
+	return certificateAuth || client.CertificateBoundTokens
+}
+
+func (clientCertificate *ClientCertificate) getSubjectValues() []string {
+	var result []string
+	for _, value := range []string{clientCertificate.SubjectDN, clientCertificate.SanDNS, clientCertificate.SanURI, clientCertificate.SanIP, clientCertificate.SanEmail} {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
 // ValidateRedirect returns whether the redirect is valid for a given Client or not.
 func (client *Client) ValidateRedirect(redirect string) bool {
 	return validateRedirect(client.Id, client.Redirects, redirect)
 }
 
+// ValidateRequestUri checks whether a request_uri matches one of the registered request URIs of the client,
+// either exactly or with the same scheme and host below the registered path, see https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata
+func (client *Client) ValidateRequestUri(requestUri string) bool {
+	requestURL, parseError := url.Parse(requestUri)
+	if requestUri == "" || parseError != nil || requestURL.User != nil || requestURL.Fragment != "" {
+		return false
+	}
+	if slices.Contains(strings.Split(requestURL.Path, "/"), "..") {
+		return false
+	}
+	return slices.ContainsFunc(client.RequestUris, func(registeredRequestUri string) bool {
+		if requestUri == registeredRequestUri {
+			return true
+		}
+		registeredURL, registeredParseError := url.Parse(registeredRequestUri)
+		if registeredParseError != nil || registeredURL.RawQuery != "" {
+			return false
+		}
+		registeredPath := registeredURL.Path
+		if !strings.HasSuffix(registeredPath, "/") {
+			registeredPath = registeredPath + "/"
+		}
+		return requestURL.Scheme == registeredURL.Scheme &&
+			strings.EqualFold(requestURL.Host, registeredURL.Host) &&
+			strings.HasPrefix(requestURL.Path, registeredPath)
+	})
+}
+
+// ValidateExchangeAudience checks whether the client may exchange tokens for the given audience,
+// see https://datatracker.ietf.org/doc/html/rfc8693
+func (client *Client) ValidateExchangeAudience(audience string) bool {
+	return slices.Contains(client.ExchangeAudiences, audience)
+}
+
+// ValidateGrantType checks whether the Client may use the given grant type,
+// all grant types are allowed when no grant types are configured.
+func (client *Client) ValidateGrantType(grantType oauth2.GrantType) bool {
+	return len(client.GrantTypes) == 0 || slices.Contains(client.GrantTypes, grantType)
+}
+
+// ValidateResponseType checks whether the Client may use the given combination of response types.
+// Each response type requires the corresponding grant type and, when response types are configured,
+// the combination must be one of them in any order.
+// See https://datatracker.ietf.org/doc/html/rfc7591#section-2.1
+func (client *Client) ValidateResponseType(responseTypes []oauth2.ResponseType) bool {
+	for _, responseType := range responseTypes {
+		grantType, grantTypeExists := oauth2.ResponseTypeGrantTypes[responseType]
+		if grantTypeExists && !client.ValidateGrantType(grantType) {
+			return false
+		}
+	}
+
+	if len(client.ResponseTypes) == 0 {
+		return true
+	}
+
+	return slices.ContainsFunc(client.ResponseTypes, func(configuredResponseType string) bool {
+		values := strings.Fields(configuredResponseType)
+		if len(values) != len(responseTypes) {
+			return false
+		}
+		for _, responseType := range responseTypes {
+			if !slices.Contains(values, string(responseType)) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// ValidateAssertionIssuer checks whether the Client may use JWT authorization grants of the given trusted issuer.
+func (client *Client) ValidateAssertionIssuer(issuer string) bool {
+	return slices.Contains(client.AssertionIssuers, issuer)
+}
+
+// GetMaxLifetime returns the maximum lifetime of assertions in minutes.
+// When no maximum lifetime is provided a default value will be returned.
+func (trustedIssuer *TrustedIssuer) GetMaxLifetime() int {
+	return cmp.Or(trustedIssuer.MaxLifetime, 5)
+}
+
+// ValidateScope checks whether access tokens for the Resource may contain the given scope.
+func (resource *Resource) ValidateScope(scope string) bool {
+	return len(resource.Scopes) == 0 || slices.Contains(resource.Scopes, scope)
+}
+
 // GetPreferredUsername returns the preferred username for a given User, or just the username.
 func (user *User) GetPreferredUsername() string {
 	if user.UserProfile.PreferredUserName == "" {
@@ -660,7 +1363,7 @@ func (claim *claim) GetName() string {
 // validateRedirect validated a given redirect against an array of redirects. Given clientId is used for logging.
 func validateRedirect(clientId string, redirects []string, redirect string) bool {
 	if redirect == "" {
-		log.Error("Redirect provided for client %s was empty", clientId)
+		log.Error("Redirect provided was empty", log.ClientId(clientId))
 		return false
 	}
 
@@ -687,7 +1390,7 @@ func validateRedirect(clientId string, redirects []string, redirect string) bool
 
 		return false
 	} else {
-		log.Error("Client %s has no redirect URI(s) configured!", clientId)
+		log.Error("Client has no redirect URI(s) configured", log.ClientId(clientId))
 		return false
 	}
 }
@@ -730,3 +1433,27 @@ func mergeIntoSet[T any](values []T, value *T, valid func(*T) bool) store.Set[T]
 	}
 	return set
 }
+
+// validateClientCertificate checks that a client uses either tls_client_auth with exactly one subject value
+// or self_signed_tls_client_auth, see https://datatracker.ietf.org/doc/html/rfc8705#section-2.1.2
+func (config *Config) validateClientCertificate(client *Client) error {
+	clientCertificate := client.ClientCertificate
+	subjectValues := clientCertificate.getSubjectValues()
+	certificateAuthMethod, certificateAuth := client.GetCertificateAuthMethod()
+	if !certificateAuth {
+		return nil
+	}
+	if !config.Server.TLS.ClientAuth.Enabled {
+		return errors.New("client certificate configured, but TLS client authentication is disabled")
+	}
+	if len(subjectValues) > 1 {
+		return errors.New("client certificate must contain only one of subject DN or SAN values")
+	}
+	if len(subjectValues) > 0 && len(clientCertificate.Thumbprints) > 0 {
+		return errors.New("client certificate must not contain subject values and thumbprints")
+	}
+	if certificateAuthMethod == oauth2.CamTlsClientAuth && config.Server.TLS.ClientAuth.CA == "" {
+		return errors.New("client certificate with subject value requires a TLS client authentication CA")
+	}
+	return nil
+}
@@ -79,6 +79,10 @@ func Test_EmptyServerConfiguration(t *testing.T) {
 		t.Error("expected auth cookie name to be 'stopnik_auth'")
 	}
 
+	if config.GetOpaqueAccessTokenPrefix() != "stpa_" || config.GetOpaqueRefreshTokenPrefix() != "stpr_" {
+		t.Error("expected opaque token prefixes to be 'stpa_' and 'stpr_'")
+	}
+
 	messageCookieName := config.GetMessageCookieName()
 	if messageCookieName != "stopnik_message" {
 		t.Error("expected message cookie name to be 'stopnik_message'")
@@ -124,6 +128,83 @@ func Test_EmptyServerConfiguration(t *testing.T) {
 		t.Error("expected forward auth client to not exist")
 	}
 
+	metricsEnabled := config.GetMetricsEnabled()
+	if metricsEnabled {
+		t.Error("expected metrics enabled to be false")
+	}
+
+	metricsAddr := config.GetMetricsAddr()
+	if metricsAddr != "" {
+		t.Error("expected metrics address to be empty")
+	}
+
+	metricsEndpoint := config.GetMetricsEndpoint()
+	if metricsEndpoint != "/metrics" {
+		t.Error("expected metrics endpoint to be '/metrics'")
+	}
+
+	tracingEnabled := config.GetTracingEnabled()
+	if tracingEnabled {
+		t.Error("expected tracing enabled to be false")
+	}
+
+	tracingExporter := config.GetTracingExporter()
+	if tracingExporter != "stdout" {
+		t.Error("expected tracing exporter to be 'stdout'")
+	}
+
+	tracingEndpoint := config.GetTracingEndpoint()
+	if tracingEndpoint != "http://localhost:4318/v1/traces" {
+		t.Error("expected tracing endpoint to be 'http://localhost:4318/v1/traces'")
+	}
+
+	tracingServiceName := config.GetTracingServiceName()
+	if tracingServiceName != "stopnik" {
+		t.Error("expected tracing service name to be 'stopnik'")
+	}
+
+	auditEnabled := config.GetAuditEnabled()
+	if auditEnabled {
+		t.Error("expected audit enabled to be false")
+	}
+
+	webhookMaxRetries := config.GetWebhookMaxRetries()
+	if webhookMaxRetries != 3 {
+		t.Error("expected webhook max retries to be 3")
+	}
+
+	webhookQueueSize := config.GetWebhookQueueSize()
+	if webhookQueueSize != 1024 {
+		t.Error("expected webhook queue size to be 1024")
+	}
+
+	logFormat := config.GetLogFormat()
+	if logFormat != "text" {
+		t.Error("expected log format to be 'text'")
+	}
+
+	accessLogFormat := config.GetAccessLogFormat()
+	if accessLogFormat != "text" {
+		t.Error("expected access log format to be 'text'")
+	}
+
+	logFileMaxSize := config.Server.Logging.File.GetMaxSize()
+	if logFileMaxSize != 10*1024*1024 {
+		t.Error("expected log file max size to be 10MB")
+	}
+
+	logFileMaxBackups := config.Server.Logging.File.GetMaxBackups()
+	if logFileMaxBackups != 3 {
+		t.Error("expected log file max backups to be 3")
+	}
+
+	noBackups := 0
+	config.Server.Logging.File.MaxBackups = &noBackups
+	logFileMaxBackups = config.Server.Logging.File.GetMaxBackups()
+	if logFileMaxBackups != 0 {
+		t.Error("expected log file max backups to be 0")
+	}
+
 	oidc := config.GetOidc()
 	if oidc {
 		t.Error("expected oidc enabled to be false")
@@ -154,6 +235,17 @@ func Test_SimpleServerConfiguration(t *testing.T) {
 					ParameterName: "bla_blub",
 					Redirects:     []string{"http://foo.example.com/callback"},
 				},
+				Metrics: Metrics{
+					Enabled:  true,
+					Addr:     ":9090",
+					Endpoint: "/prometheus",
+				},
+				Tracing: Tracing{
+					Enabled:     true,
+					Exporter:    "otlp",
+					Endpoint:    "http://collector:4318/v1/traces",
+					ServiceName: "my-stopnik",
+				},
 			},
 		}
 		return nil
@@ -239,6 +331,41 @@ func Test_SimpleServerConfiguration(t *testing.T) {
 		redirects := forwardAuthClient.Redirects
 		reflect.DeepEqual(redirects, []string{"http://foo.example.com/callback"})
 	}
+
+	metricsEnabled := config.GetMetricsEnabled()
+	if !metricsEnabled {
+		t.Error("expected metrics enabled to be true")
+	}
+
+	metricsAddr := config.GetMetricsAddr()
+	if metricsAddr != ":9090" {
+		t.Error("expected metrics address to be ':9090'")
+	}
+
+	metricsEndpoint := config.GetMetricsEndpoint()
+	if metricsEndpoint != "/prometheus" {
+		t.Error("expected metrics endpoint to be '/prometheus'")
+	}
+
+	tracingEnabled := config.GetTracingEnabled()
+	if !tracingEnabled {
+		t.Error("expected tracing enabled to be true")
+	}
+
+	tracingExporter := config.GetTracingExporter()
+	if tracingExporter != "otlp" {
+		t.Error("expected tracing exporter to be 'otlp'")
+	}
+
+	tracingEndpoint := config.GetTracingEndpoint()
+	if tracingEndpoint != "http://collector:4318/v1/traces" {
+		t.Error("expected tracing endpoint to be 'http://collector:4318/v1/traces'")
+	}
+
+	tracingServiceName := config.GetTracingServiceName()
+	if tracingServiceName != "my-stopnik" {
+		t.Error("expected tracing service name to be 'my-stopnik'")
+	}
 }
 
 func Test_ForwardAuthMissingExternalUrl(t *testing.T) {
@@ -264,6 +391,481 @@ func Test_ForwardAuthMissingExternalUrl(t *testing.T) {
 	}
 }
 
+func Test_UnsupportedTracingExporter(t *testing.T) {
+	configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
+		return make([]byte, 10), nil
+	}, func(in []byte, out interface{}) (err error) {
+		origin := out.(*Config)
+		*origin = Config{
+			Server: Server{
+				Addr: ":8080",
+				Tracing: Tracing{
+					Enabled:  true,
+					Exporter: "zipkin",
+				},
+			},
+		}
+		return nil
+	})
+
+	err := configLoader.LoadConfig("foo.txt", true)
+
+	if err == nil {
+		t.Error("expected error when loading config because of unsupported tracing exporter")
+	}
+}
+
+func Test_AuditWithoutOutput(t *testing.T) {
+	configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
+		return make([]byte, 10), nil
+	}, func(in []byte, out interface{}) (err error) {
+		origin := out.(*Config)
+		*origin = Config{
+			Server: Server{
+				Addr: ":8080",
+				Audit: Audit{
+					Enabled: true,
+				},
+			},
+		}
+		return nil
+	})
+
+	err := configLoader.LoadConfig("foo.txt", true)
+
+	if err == nil {
+		t.Error("expected error when loading config because of missing audit file or webhook")
+	}
+}
+
+func Test_WebhookWithoutSecret(t *testing.T) {
+	configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
+		return make([]byte, 10), nil
+	}, func(in []byte, out interface{}) (err error) {
+		origin := out.(*Config)
+		*origin = Config{
+			Server: Server{
+				Addr: ":8080",
+				Webhooks: Webhooks{
+					Subscriptions: []WebhookSubscription{
+						{Url: "https://example.com/hook"},
+					},
+				},
+			},
+		}
+		return nil
+	})
+
+	err := configLoader.LoadConfig("foo.txt", true)
+
+	if err == nil {
+		t.Error("expected error when loading config because of missing webhook secret")
+	}
+}
+
+func Test_TrustedIssuerWithoutPublicKey(t *testing.T) {
+	configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
+		return make([]byte, 10), nil
+	}, func(in []byte, out interface{}) (err error) {
+		origin := out.(*Config)
+		*origin = Config{
+			Server: Server{
+				Addr: ":8080",
+				TrustedIssuers: []TrustedIssuer{
+					{Issuer: "https://ci.example.com"},
+				},
+			},
+		}
+		return nil
+	})
+
+	err := configLoader.LoadConfig("foo.txt", true)
+
+	if err == nil {
+		t.Error("expected error when loading config because of missing trusted issuer public key")
+	}
+}
+
+func Test_GetTrustedIssuer(t *testing.T) {
+	config := &Config{
+		Server: Server{
+			TrustedIssuers: []TrustedIssuer{
+				{Issuer: "https://ci.example.com", PublicKey: "ci.pem", ServiceAccount: true},
+			},
+		},
+	}
+
+	trustedIssuer, exists := config.GetTrustedIssuer("https://ci.example.com")
+	if !exists || trustedIssuer.PublicKey != "ci.pem" || !trustedIssuer.ServiceAccount {
+		t.Errorf("expected trusted issuer to exist, got %v", trustedIssuer)
+	}
+
+	_, exists = config.GetTrustedIssuer("https://other.example.com")
+	if exists {
+		t.Error("did not expect trusted issuer to exist")
+	}
+}
+
+func Test_InvalidResources(t *testing.T) {
+	var invalidResources = []string{"", "api", "/api", "https://api.example.com#fragment"}
+
+	for _, test := range invalidResources {
+		testMessage := fmt.Sprintf("Invalid resource %s", test)
+		t.Run(testMessage, func(t *testing.T) {
+			configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
+				return make([]byte, 10), nil
+			}, func(in []byte, out interface{}) (err error) {
+				origin := out.(*Config)
+				*origin = Config{
+					Server: Server{
+						Addr: ":8080",
+					},
+					Resources: []Resource{
+						{Id: test},
+					},
+				}
+				return nil
+			})
+
+			err := configLoader.LoadConfig("foo.txt", true)
+
+			if err == nil {
+				t.Errorf("expected error when loading config because of invalid resource %s", test)
+			}
+		})
+	}
+}
+
+func Test_GetResource(t *testing.T) {
+	config := &Config{
+		Resources: []Resource{
+			{Id: "https://api.example.com", Scopes: []string{"read"}, AccessTTL: 10},
+			{Id: "https://other.example.com"},
+		},
+	}
+
+	resource, exists := config.GetResource("https://api.example.com")
+	if !exists || resource.AccessTTL != 10 {
+		t.Errorf("expected resource to exist, got %v", resource)
+	}
+
+	if !resource.ValidateScope("read") || resource.ValidateScope("write") {
+		t.Errorf("resource scopes did not match, %v", resource.Scopes)
+	}
+
+	otherResource, exists := config.GetResource("https://other.example.com")
+	if !exists || !otherResource.ValidateScope("write") {
+		t.Errorf("expected resource without scopes to allow all scopes, got %v", otherResource)
+	}
+
+	_, exists = config.GetResource("https://unknown.example.com")
+	if exists {
+		t.Error("did not expect resource to exist")
+	}
+}
+
+func Test_AuthorizationDetailTypeWithoutType(t *testing.T) {
+	configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
+		return make([]byte, 10), nil
+	}, func(in []byte, out interface{}) (err error) {
+		origin := out.(*Config)
+		*origin = Config{
+			Server: Server{
+				Addr: ":8080",
+			},
+			AuthorizationDetailTypes: []AuthorizationDetailType{
+				{Description: "Payment initiation"},
+			},
+		}
+		return nil
+	})
+
+	err := configLoader.LoadConfig("foo.txt", true)
+
+	if err == nil {
+		t.Error("expected error when loading config because of missing authorization details type")
+	}
+}
+
+func Test_GetAuthorizationDetailType(t *testing.T) {
+	config := &Config{
+		AuthorizationDetailTypes: []AuthorizationDetailType{
+			{Type: "payment_initiation", Description: "Payment initiation"},
+			{Type: "document_sharing"},
+		},
+	}
+
+	authorizationDetailType, exists := config.GetAuthorizationDetailType("payment_initiation")
+	if !exists || authorizationDetailType.Description != "Payment initiation" {
+		t.Errorf("expected authorization details type to exist, got %v", authorizationDetailType)
+	}
+
+	_, exists = config.GetAuthorizationDetailType("account_information")
+	if exists {
+		t.Error("did not expect authorization details type to exist")
+	}
+
+	authorizationDetailTypes := config.GetAuthorizationDetailTypes()
+	if !reflect.DeepEqual(authorizationDetailTypes, []string{"payment_initiation", "document_sharing"}) {
+		t.Errorf("authorization details types did not match, %v", authorizationDetailTypes)
+	}
+}
+
+func Test_UnsupportedLogFormats(t *testing.T) {
+	type logFormatParameter struct {
+		name    string
+		logging Logging
+	}
+
+	var logFormatParameters = []logFormatParameter{
+		{name: "Log format", logging: Logging{Format: "xml"}},
+		{name: "Log format combined", logging: Logging{Format: "combined"}},
+		{name: "Access log format", logging: Logging{AccessLog: AccessLog{Format: "xml"}}},
+	}
+
+	for _, test := range logFormatParameters {
+		t.Run(test.name, func(t *testing.T) {
+			configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
+				return make([]byte, 10), nil
+			}, func(in []byte, out interface{}) (err error) {
+				origin := out.(*Config)
+				*origin = Config{
+					Server: Server{
+						Addr:    ":8080",
+						Logging: test.logging,
+					},
+				}
+				return nil
+			})
+
+			err := configLoader.LoadConfig("foo.txt", true)
+
+			if err == nil {
+				t.Error("expected error when loading config because of unsupported log format")
+			}
+		})
+	}
+}
+
+func Test_SameOpaqueTokenPrefix(t *testing.T) {
+	configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
+		return make([]byte, 10), nil
+	}, func(in []byte, out interface{}) (err error) {
+		origin := out.(*Config)
+		*origin = Config{
+			Server: Server{
+				Addr: ":8080",
+				OpaqueToken: OpaqueToken{
+					AccessPrefix:  "foo_",
+					RefreshPrefix: "foo_",
+				},
+			},
+		}
+		return nil
+	})
+
+	err := configLoader.LoadConfig("foo.txt", true)
+
+	if err == nil {
+		t.Error("expected error when loading config because of access and refresh token with same prefix")
+	}
+}
+
+func Test_SameEncryptionAndPrivateKey(t *testing.T) {
+	configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
+		return make([]byte, 10), nil
+	}, func(in []byte, out interface{}) (err error) {
+		origin := out.(*Config)
+		*origin = Config{
+			Server: Server{
+				Addr:          ":8080",
+				PrivateKey:    "key.pem",
+				EncryptionKey: "key.pem",
+			},
+		}
+		return nil
+	})
+
+	err := configLoader.LoadConfig("foo.txt", true)
+
+	if err == nil {
+		t.Error("expected error when loading config because of same encryption and private key")
+	}
+}
+
+func Test_RegistrationWithoutProtection(t *testing.T) {
+	configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
+		return make([]byte, 10), nil
+	}, func(in []byte, out interface{}) (err error) {
+		origin := out.(*Config)
+		*origin = Config{
+			Server: Server{
+				Addr: ":8080",
+				Registration: Registration{
+					Enabled: true,
+				},
+			},
+		}
+		return nil
+	})
+
+	err := configLoader.LoadConfig("foo.txt", true)
+
+	if err == nil {
+		t.Error("expected error when loading config because of registration without initial access token or scope")
+	}
+}
+
+func Test_ClientEncryptionWithoutKey(t *testing.T) {
+	configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
+		return make([]byte, 10), nil
+	}, func(in []byte, out interface{}) (err error) {
+		origin := out.(*Config)
+		*origin = Config{
+			Server: Server{
+				Addr: ":8080",
+			},
+			Users: []User{
+				{
+					Username: "foo",
+					Password: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				},
+			},
+			Clients: []Client{
+				{
+					Id:         "foo",
+					Redirects:  []string{"https://example.com/callback"},
+					Encryption: ClientEncryption{UserInfo: true},
+				},
+			},
+		}
+		return nil
+	})
+
+	err := configLoader.LoadConfig("foo.txt", true)
+
+	if err == nil {
+		t.Error("expected error when loading config because of client encryption without key")
+	}
+}
+
+func Test_ClientEncryptionKey(t *testing.T) {
+	type parameter struct {
+		encryption ClientEncryption
+		valid      bool
+	}
+
+	var parameters = []parameter{
+		{ClientEncryption{Key: "../../.test_files/rsa256pub.pem"}, true},
+		{ClientEncryption{Key: "../../.test_files/rsa256pub.pem", Algorithm: "RSA-OAEP-256", ContentEncryption: "A128CBC-HS256"}, true},
+		{ClientEncryption{Key: "../../.test_files/ecdsa256pub.pem", Algorithm: "ECDH-ES+A128KW"}, true},
+		{ClientEncryption{Key: "../../.test_files/notexisting.pem"}, false},
+		{ClientEncryption{Key: "../../.test_files/invalidkey.pem"}, false},
+		{ClientEncryption{Key: "../../.test_files/rsa256pub.pem", Algorithm: "ECDH-ES"}, false},
+		{ClientEncryption{Key: "../../.test_files/ecdsa256pub.pem", Algorithm: "RSA-OAEP"}, false},
+		{ClientEncryption{Key: "../../.test_files/rsa256pub.pem", Algorithm: "dir"}, false},
+		{ClientEncryption{Key: "../../.test_files/rsa256pub.pem", ContentEncryption: "A256KW"}, false},
+	}
+
+	for index, test := range parameters {
+		testMessage := fmt.Sprintf("Client encryption %d with %s %s %s", index, test.encryption.Key, test.encryption.Algorithm, test.encryption.ContentEncryption)
+		t.Run(testMessage, func(t *testing.T) {
+			configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
+				return make([]byte, 10), nil
+			}, func(in []byte, out interface{}) (err error) {
+				origin := out.(*Config)
+				*origin = Config{
+					Server: Server{
+						Addr: ":8080",
+					},
+					Users: []User{
+						{
+							Username: "foo",
+							Password: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+						},
+					},
+					Clients: []Client{
+						{
+							Id:           "foo",
+							ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+							Redirects:    []string{"https://example.com/callback"},
+							Encryption:   test.encryption,
+						},
+					},
+				}
+				return nil
+			})
+
+			err := configLoader.LoadConfig("foo.txt", true)
+
+			if test.valid && err != nil {
+				t.Errorf("unexpected error %v", err)
+			} else if !test.valid && err == nil {
+				t.Error("expected error when loading config because of invalid client encryption")
+			}
+		})
+	}
+}
+
+func Test_UserInfoSignedResponseAlg(t *testing.T) {
+	type parameter struct {
+		privateKey string
+		algorithm  string
+		valid      bool
+	}
+
+	var parameters = []parameter{
+		{"../../.test_files/rsa256key.pem", "RS256", true},
+		{"../../.test_files/ecdsa256key.pem", "ES256", true},
+		{"../../.test_files/ecdsa384key.pem", "ES384", true},
+		{"../../.test_files/rsa256key.pem", "ES256", false},
+		{"../../.test_files/ecdsa256key.pem", "ES384", false},
+		{"../../.test_files/rsa256key.pem", "none", false},
+		{"../../.test_files/notexisting.pem", "RS256", false},
+		{"../../.test_files/invalidkey.pem", "RS256", false},
+	}
+
+	for index, test := range parameters {
+		testMessage := fmt.Sprintf("Userinfo signing %d with %s %s", index, test.privateKey, test.algorithm)
+		t.Run(testMessage, func(t *testing.T) {
+			configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
+				return make([]byte, 10), nil
+			}, func(in []byte, out interface{}) (err error) {
+				origin := out.(*Config)
+				*origin = Config{
+					Server: Server{
+						Addr: ":8080",
+					},
+					Users: []User{
+						{
+							Username: "foo",
+							Password: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+						},
+					},
+					Clients: []Client{
+						{
+							Id:                        "foo",
+							ClientSecret:              "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+							Redirects:                 []string{"https://example.com/callback"},
+							PrivateKey:                test.privateKey,
+							UserInfoSignedResponseAlg: test.algorithm,
+						},
+					},
+				}
+				return nil
+			})
+
+			err := configLoader.LoadConfig("foo.txt", true)
+
+			if test.valid && err != nil {
+				t.Errorf("unexpected error %v", err)
+			} else if !test.valid && err == nil {
+				t.Error("expected error when loading config because of invalid userinfo signing algorithm")
+			}
+		})
+	}
+}
+
 func Test_SameCookieNameAuthAndMessage(t *testing.T) {
 	configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
 		return make([]byte, 10), nil
@@ -979,6 +1581,10 @@ func Test_InvalidClients(t *testing.T) {
 		{Id: "empty", ClientSecret: ""},
 		{Id: "", ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181"},
 		{Id: "no_redirects", ClientSecret: "3c9909afec25354d551dae21590bb26e38d53f2173b8d3dc3eee4c047e7ab1c1eb8b85103e3be7ba613b31bb5c9c36214dc9f14a42fd7a2fdb84856bca5c44c2"},
+		{Id: "refresh_max", ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181", Redirects: []string{"https://example.com/callback"}, RefreshTTL: 60, RefreshMaxTTL: 30},
+		{Id: "introspect_jwt", ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181", Redirects: []string{"https://example.com/callback"}, IntrospectJWT: true},
+		{Id: "unsigned_request_object", ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181", Redirects: []string{"https://example.com/callback"}, RequireSignedRequestObject: true, AllowUnsignedRequestObject: true},
+		{Id: "assertion_issuer", ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181", Redirects: []string{"https://example.com/callback"}, AssertionIssuers: []string{"https://ci.example.com"}},
 	}
 
 	for _, client := range invalidClientParameters {
@@ -1291,6 +1897,98 @@ func Test_TLSWithoutCertificate(t *testing.T) {
 	}
 }
 
+func Test_InvalidClientCertificates(t *testing.T) {
+	type parameter struct {
+		name              string
+		clientAuth        ClientAuth
+		clientCertificate ClientCertificate
+	}
+
+	var parameters = []parameter{
+		{"without TLS client authentication", ClientAuth{}, ClientCertificate{SubjectDN: "CN=foo"}},
+		{"without CA", ClientAuth{Enabled: true}, ClientCertificate{SubjectDN: "CN=foo"}},
+		{"with multiple subject values", ClientAuth{Enabled: true, CA: "ca.crt"}, ClientCertificate{SubjectDN: "CN=foo", SanDNS: "foo.example.com"}},
+		{"with subject value and thumbprint", ClientAuth{Enabled: true, CA: "ca.crt"}, ClientCertificate{SanDNS: "foo.example.com", Thumbprints: []string{"abc"}}},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Client certificate %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
+				return make([]byte, 10), nil
+			}, func(in []byte, out interface{}) (err error) {
+				origin := out.(*Config)
+				*origin = Config{
+					Server: Server{
+						Addr: ":8080",
+						TLS: TLS{
+							ClientAuth: test.clientAuth,
+						},
+					},
+					Users: []User{
+						{
+							Username: "foo",
+							Password: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+						},
+					},
+					Clients: []Client{
+						{
+							Id:                "foo",
+							Redirects:         []string{"https://example.com/callback"},
+							ClientCertificate: test.clientCertificate,
+						},
+					},
+				}
+				return nil
+			})
+
+			err := configLoader.LoadConfig("foo.txt", true)
+
+			if err == nil {
+				t.Error("expected error when loading config")
+			}
+		})
+	}
+}
+
+func Test_GetCertificateAuthMethod(t *testing.T) {
+	type parameter struct {
+		name                    string
+		client                  Client
+		expectedAuthMethod      oauth2.ClientAuthMethod
+		expectedCertificateAuth bool
+		expectedClientType      oauth2.ClientType
+		expectedBound           bool
+	}
+
+	var parameters = []parameter{
+		{"subject DN", Client{ClientCertificate: ClientCertificate{SubjectDN: "CN=foo"}}, oauth2.CamTlsClientAuth, true, oauth2.CtConfidential, true},
+		{"SAN", Client{ClientCertificate: ClientCertificate{SanURI: "https://foo.example.com"}}, oauth2.CamTlsClientAuth, true, oauth2.CtConfidential, true},
+		{"thumbprint", Client{ClientCertificate: ClientCertificate{Thumbprints: []string{"abc"}}}, oauth2.CamSelfSignedTlsClientAuth, true, oauth2.CtConfidential, true},
+		{"bound tokens", Client{ClientSecret: "abc", CertificateBoundTokens: true}, "", false, oauth2.CtConfidential, true},
+		{"none", Client{}, "", false, oauth2.CtPublic, false},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Certificate auth method with %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			authMethod, certificateAuth := test.client.GetCertificateAuthMethod()
+
+			if authMethod != test.expectedAuthMethod || certificateAuth != test.expectedCertificateAuth {
+				t.Errorf("expected certificate auth method %s, got %s", test.expectedAuthMethod, authMethod)
+			}
+
+			if test.client.GetClientType() != test.expectedClientType {
+				t.Errorf("expected client type %s, got %s", test.expectedClientType, test.client.GetClientType())
+			}
+
+			if test.client.IsCertificateBound() != test.expectedBound {
+				t.Errorf("expected certificate bound %v", test.expectedBound)
+			}
+		})
+	}
+}
+
 func Test_MinimalConfiguration(t *testing.T) {
 	configLoader := NewConfigLoader(func(filename string) ([]byte, error) {
 		return make([]byte, 10), nil
@@ -1384,6 +2082,121 @@ func Test_NoRedirects(t *testing.T) {
 	}
 }
 
+func Test_ValidateExchangeAudience(t *testing.T) {
+	client := &Client{
+		Id:                "foo",
+		ExchangeAudiences: []string{"https://api.example.com", "billing"},
+	}
+
+	var parameters = []struct {
+		audience string
+		expected bool
+	}{
+		{"https://api.example.com", true},
+		{"billing", true},
+		{"https://other.example.com", false},
+		{"", false},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Validate exchange audience %s", test.audience)
+		t.Run(testMessage, func(t *testing.T) {
+			if client.ValidateExchangeAudience(test.audience) != test.expected {
+				t.Error("Exchange audience validation did not match")
+			}
+		})
+	}
+}
+
+func Test_ValidateGrantAndResponseType(t *testing.T) {
+	unrestricted := &Client{Id: "foo"}
+	codeOnly := &Client{Id: "bar", GrantTypes: []oauth2.GrantType{oauth2.GtAuthorizationCode, oauth2.GtRefreshToken}, ResponseTypes: []string{"code"}}
+	hybrid := &Client{Id: "moo", GrantTypes: []oauth2.GrantType{oauth2.GtAuthorizationCode, oauth2.GtImplicit}, ResponseTypes: []string{"code id_token"}}
+
+	var parameters = []struct {
+		name                 string
+		client               *Client
+		grantType            oauth2.GrantType
+		responseTypes        []oauth2.ResponseType
+		expectedGrantType    bool
+		expectedResponseType bool
+	}{
+		{"unrestricted", unrestricted, oauth2.GtPassword, []oauth2.ResponseType{oauth2.RtToken}, true, true},
+		{"code only", codeOnly, oauth2.GtRefreshToken, []oauth2.ResponseType{oauth2.RtCode}, true, true},
+		{"code only with implicit", codeOnly, oauth2.GtClientCredentials, []oauth2.ResponseType{oauth2.RtToken}, false, false},
+		{"hybrid in other order", hybrid, oauth2.GtAuthorizationCode, []oauth2.ResponseType{oauth2.RtIdToken, oauth2.RtCode}, true, true},
+		{"hybrid with code only", hybrid, oauth2.GtRefreshToken, []oauth2.ResponseType{oauth2.RtCode}, false, false},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Validate grant and response type %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			if test.client.ValidateGrantType(test.grantType) != test.expectedGrantType {
+				t.Errorf("expected grant type %s validation to be %v", test.grantType, test.expectedGrantType)
+			}
+			if test.client.ValidateResponseType(test.responseTypes) != test.expectedResponseType {
+				t.Errorf("expected response type %v validation to be %v", test.responseTypes, test.expectedResponseType)
+			}
+		})
+	}
+}
+
+func Test_ValidateRequestUri(t *testing.T) {
+	client := &Client{
+		Id:          "foo",
+		RequestUris: []string{"https://example.com/request/", "https://example.com/object"},
+	}
+
+	var parameters = []struct {
+		requestUri string
+		expected   bool
+	}{
+		{"https://example.com/request/abc", true},
+		{"https://example.com/request/", true},
+		{"https://example.com/object", true},
+		{"https://example.com/object/abc", true},
+		{"https://example.com/objects", false},
+		{"https://example.com/request/../admin", false},
+		{"https://example.com/request.evil.com/abc", false},
+		{"https://example.com.evil.com/request/abc", false},
+		{"http://example.com/request/abc", false},
+		{"https://user@example.com/request/abc", false},
+		{"https://example.com/other/abc", false},
+		{"", false},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Validate request_uri %s", test.requestUri)
+		t.Run(testMessage, func(t *testing.T) {
+			if client.ValidateRequestUri(test.requestUri) != test.expected {
+				t.Errorf("expected request_uri %s validation to be %v", test.requestUri, test.expected)
+			}
+		})
+	}
+}
+
+func Test_GetRequireSignedRequestObject(t *testing.T) {
+	var parameters = []struct {
+		name     string
+		clients  []Client
+		expected bool
+	}{
+		{"no clients", []Client{}, false},
+		{"all clients", []Client{{Id: "foo", RequireSignedRequestObject: true}, {Id: "bar", RequireSignedRequestObject: true}}, true},
+		{"some clients", []Client{{Id: "foo", RequireSignedRequestObject: true}, {Id: "bar"}}, false},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Require signed request object with %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			config := &Config{Clients: test.clients}
+			if config.GetRequireSignedRequestObject() != test.expected {
+				t.Errorf("expected require signed request object to be %v", test.expected)
+			}
+		})
+	}
+}
+
 func Test_RemoveLeadingSlash(t *testing.T) {
 	type parameter struct {
 		value          string
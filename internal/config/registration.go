@@ -0,0 +1,147 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/internal/store"
+	"github.com/webishdev/stopnik/internal/system"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// RegisteredClient defines a client registered at runtime by its metadata,
+// ClientSecret and RegistrationAccessToken only contain hashes of the issued values.
+// See https://datatracker.ietf.org/doc/html/rfc7591 and https://datatracker.ietf.org/doc/html/rfc7592
+type RegisteredClient struct {
+	ClientId                string                `json:"client_id"`
+	ClientSecret            string                `json:"client_secret,omitempty"`
+	Salt                    string                `json:"salt,omitempty"`
+	RegistrationAccessToken string                `json:"registration_access_token"`
+	IssuedAt                int64                 `json:"client_id_issued_at"`
+	Metadata                oauth2.ClientMetadata `json:"metadata"`
+	client                  *Client
+}
+
+type registeredClients struct {
+	file       string
+	refreshTTL int
+	store      store.Store[RegisteredClient]
+	mux        *sync.Mutex
+}
+
+func newRegisteredClients(file string, refreshTTL int) (*registeredClients, error) {
+	result := &registeredClients{
+		file:       file,
+		refreshTTL: refreshTTL,
+		store:      store.NewStore[RegisteredClient](),
+		mux:        &sync.Mutex{},
+	}
+
+	if file == "" {
+		return result, nil
+	}
+
+	data, readError := os.ReadFile(file)
+	if errors.Is(readError, os.ErrNotExist) {
+		return result, nil
+	} else if readError != nil {
+		return nil, readError
+	}
+
+	var clients []*RegisteredClient
+	unmarshalError := json.Unmarshal(data, &clients)
+	if unmarshalError != nil {
+		return nil, unmarshalError
+	}
+
+	for _, registeredClient := range clients {
+		registeredClient.client = registeredClient.newClient(refreshTTL)
+		result.store.Set(registeredClient.ClientId, registeredClient)
+	}
+
+	return result, nil
+}
+
+// GetClient returns the Client which is created from the metadata of the RegisteredClient.
+func (registeredClient *RegisteredClient) GetClient() *Client {
+	return registeredClient.client
+}
+
+// newClient creates the Client from the metadata, the refresh token lifetime is only applied
+// when the client registered the refresh_token grant type.
+func (registeredClient *RegisteredClient) newClient(refreshTTL int) *Client {
+	metadata := registeredClient.Metadata
+	if !slices.Contains(metadata.GrantTypes, oauth2.GtRefreshToken) {
+		refreshTTL = 0
+	}
+	oidc := slices.Contains(strings.Fields(metadata.Scope), "openid") || slices.ContainsFunc(metadata.ResponseTypes, func(responseType string) bool {
+		return slices.Contains(strings.Fields(responseType), string(oauth2.RtIdToken))
+	})
+	return &Client{
+		Id:                      registeredClient.ClientId,
+		ClientSecret:            registeredClient.ClientSecret,
+		Salt:                    registeredClient.Salt,
+		Oidc:                    oidc,
+		Redirects:               metadata.RedirectUris,
+		PasswordFallbackAllowed: metadata.TokenEndpointAuthMethod == oauth2.CamClientSecretPost,
+		SubjectType:             metadata.SubjectType,
+		SectorIdentifierUri:     metadata.SectorIdentifierUri,
+		GrantTypes:              metadata.GrantTypes,
+		ResponseTypes:           metadata.ResponseTypes,
+		RefreshTTL:              refreshTTL,
+		isRegistered:            true,
+	}
+}
+
+// IsRegistered returns whether the Client was registered at runtime instead of being configured.
+func (client *Client) IsRegistered() bool {
+	return client.isRegistered
+}
+
+// SetRegisteredClient adds or replaces a client registered at runtime,
+// the registered clients are written to the registration file when configured.
+func (config *Config) SetRegisteredClient(registeredClient *RegisteredClient) error {
+	if _, exists := config.clientMap[registeredClient.ClientId]; exists {
+		return errors.New("client id is already configured")
+	}
+	registeredClient.client = registeredClient.newClient(config.registeredClients.refreshTTL)
+
+	config.registeredClients.mux.Lock()
+	defer config.registeredClients.mux.Unlock()
+	config.registeredClients.store.Set(registeredClient.ClientId, registeredClient)
+	return config.registeredClients.persist()
+}
+
+// GetRegisteredClient returns a client registered at runtime for the given clientId.
+// Also returns a bool which indicates, whether the client exists or not.
+func (config *Config) GetRegisteredClient(clientId string) (*RegisteredClient, bool) {
+	if config.registeredClients == nil {
+		return nil, false
+	}
+	return config.registeredClients.store.Get(clientId)
+}
+
+// DeleteRegisteredClient removes a client registered at runtime,
+// the registered clients are written to the registration file when configured.
+func (config *Config) DeleteRegisteredClient(clientId string) error {
+	config.registeredClients.mux.Lock()
+	defer config.registeredClients.mux.Unlock()
+	config.registeredClients.store.Delete(clientId)
+	return config.registeredClients.persist()
+}
+
+func (registeredClients *registeredClients) persist() error {
+	if registeredClients.file == "" {
+		return nil
+	}
+
+	data, marshalError := json.MarshalIndent(registeredClients.store.GetValues(), "", "  ")
+	if marshalError != nil {
+		return marshalError
+	}
+
+	return system.WriteFile(registeredClients.file, data, 0o600)
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_RegisteredClients(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "clients.json")
+	testConfig := &Config{
+		Server: Server{
+			Registration: Registration{
+				Enabled:            true,
+				InitialAccessToken: "initial",
+				File:               file,
+				RefreshTTL:         60,
+			},
+		},
+		Clients: []Client{
+			{
+				Id:        "foo",
+				Redirects: []string{"https://example.com/callback"},
+			},
+		},
+	}
+
+	initializationError := Initialize(testConfig)
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+
+	registeredClient := &RegisteredClient{
+		ClientId:     "bar",
+		ClientSecret: "hash",
+		Metadata: oauth2.ClientMetadata{
+			RedirectUris:            []string{"https://example.com/bar"},
+			TokenEndpointAuthMethod: oauth2.CamClientSecretPost,
+			GrantTypes:              []oauth2.GrantType{oauth2.GtAuthorizationCode, oauth2.GtRefreshToken},
+			Scope:                   "openid profile",
+		},
+	}
+
+	setError := testConfig.SetRegisteredClient(registeredClient)
+	if setError != nil {
+		t.Fatal(setError)
+	}
+
+	client, clientExists := testConfig.GetClient("bar")
+	if !clientExists {
+		t.Fatal("registered client did not exist")
+	}
+
+	if !client.IsRegistered() || client.ClientSecret != "hash" || !client.Oidc || !client.PasswordFallbackAllowed || !client.ValidateRedirect("https://example.com/bar") {
+		t.Errorf("registered client was not created from metadata %v", client)
+	}
+
+	if client.GetRefreshTTL() != 60 {
+		t.Errorf("refresh token lifetime was not applied, got %d", client.GetRefreshTTL())
+	}
+
+	withoutRefresh := &RegisteredClient{ClientId: "moo", Metadata: oauth2.ClientMetadata{GrantTypes: []oauth2.GrantType{oauth2.GtClientCredentials}}}
+	if withoutRefresh.newClient(60).GetRefreshTTL() != 0 {
+		t.Error("refresh token lifetime should only be applied with refresh_token grant type")
+	}
+
+	duplicateError := testConfig.SetRegisteredClient(&RegisteredClient{ClientId: "foo"})
+	if duplicateError == nil {
+		t.Error("expected error when registering a configured client id")
+	}
+
+	loaded, loadError := newRegisteredClients(file, 60)
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+
+	loadedClient, loadedClientExists := loaded.store.Get("bar")
+	if !loadedClientExists || loadedClient.GetClient().Id != "bar" || loadedClient.GetClient().GetRefreshTTL() != 60 {
+		t.Errorf("registered client was not persisted")
+	}
+
+	deleteError := testConfig.DeleteRegisteredClient("bar")
+	if deleteError != nil {
+		t.Fatal(deleteError)
+	}
+
+	_, clientExists = testConfig.GetClient("bar")
+	if clientExists {
+		t.Error("registered client still exists after delete")
+	}
+
+	data, readError := os.ReadFile(file)
+	if readError != nil {
+		t.Fatal(readError)
+	}
+
+	if string(data) != "[]" {
+		t.Errorf("registered clients file was not updated, got %s", string(data))
+	}
+}
+
+func Test_InvalidRegisteredClientsFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "clients.json")
+	writeError := os.WriteFile(file, []byte("invalid"), 0o600)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+
+	_, loadError := newRegisteredClients(file, 0)
+	if loadError == nil {
+		t.Error("expected error when loading invalid registered clients file")
+	}
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"slices"
+)
+
+// Subject identifier types as described in https://openid.net/specs/openid-connect-core-1_0.html#SubjectIDTypes
+const (
+	SubjectTypePublic   string = "public"
+	SubjectTypePairwise string = "pairwise"
+)
+
+// GetSubjectType returns the subject identifier type of the client.
+// When no subject type is provided the public type will be returned.
+func (client *Client) GetSubjectType() string {
+	return cmp.Or(client.SubjectType, SubjectTypePublic)
+}
+
+// GetSectorIdentifier returns the host of the sector identifier URI,
+// when no sector identifier URI is provided the host of the first redirect will be returned.
+// See https://openid.net/specs/openid-connect-core-1_0.html#PairwiseAlg
+func (client *Client) GetSectorIdentifier() string {
+	if client.SectorIdentifierUri != "" {
+		return getHost(client.SectorIdentifierUri)
+	}
+	if len(client.Redirects) > 0 {
+		return getHost(client.Redirects[0])
+	}
+	return client.Id
+}
+
+// GetSubjectTypes returns the supported subject identifier types,
+// pairwise subjects are only supported when a stable salt is configured.
+func (config *Config) GetSubjectTypes() []string {
+	if config.Server.PairwiseSalt == "" && config.Server.Secret == "" {
+		return []string{SubjectTypePublic}
+	}
+	return []string{SubjectTypePublic, SubjectTypePairwise}
+}
+
+// GetPairwiseSalt returns the salt for pairwise subject identifiers.
+// When no salt is provided the server secret will be returned.
+func (config *Config) GetPairwiseSalt() string {
+	return cmp.Or(config.Server.PairwiseSalt, config.GetServerSecret())
+}
+
+// GetSubject returns the subject identifier of a user for the given client.
+// For pairwise clients the subject is calculated from the sector identifier, the username and the pairwise salt,
+// so that clients of different sectors can not correlate users, otherwise the username is returned.
+// See https://openid.net/specs/openid-connect-core-1_0.html#PairwiseAlg
+func (config *Config) GetSubject(client *Client, username string) string {
+	if username == "" || client == nil || client.GetSubjectType() != SubjectTypePairwise {
+		return username
+	}
+	hash := sha256.Sum256([]byte(client.GetSectorIdentifier() + "/" + username + "/" + config.GetPairwiseSalt()))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// GetUserBySubject returns the User for a subject identifier of the given client, pairwise subjects are mapped back to the user.
+// Also returns a bool which indicates, whether the User exists or not.
+func (config *Config) GetUserBySubject(client *Client, subject string) (*User, bool) {
+	if client == nil || client.GetSubjectType() != SubjectTypePairwise {
+		return config.GetUser(subject)
+	}
+	for username, user := range config.userMap {
+		if config.GetSubject(client, username) == subject {
+			return user, true
+		}
+	}
+	return nil, false
+}
+
+// validatePairwiseSubject checks the subject type of a client,
+// pairwise clients need a sector identifier URI when their redirects use different hosts.
+// See https://openid.net/specs/openid-connect-registration-1_0.html#SectorIdentifierValidation
+func (config *Config) validatePairwiseSubject(client *Client) error {
+	subjectType := client.GetSubjectType()
+	if subjectType != SubjectTypePublic && subjectType != SubjectTypePairwise {
+		return errors.New("unsupported subject type, use public or pairwise")
+	}
+
+	if subjectType == SubjectTypePublic {
+		return nil
+	}
+
+	if !slices.Contains(config.GetSubjectTypes(), SubjectTypePairwise) {
+		return errors.New("pairwise subjects require a pairwise salt or a server secret")
+	}
+
+	if client.SectorIdentifierUri != "" {
+		sectorIdentifierUri, parseError := url.Parse(client.SectorIdentifierUri)
+		if parseError != nil || sectorIdentifierUri.Scheme != "https" || sectorIdentifierUri.Host == "" {
+			return errors.New("sector identifier uri must be a https uri")
+		}
+		return nil
+	}
+
+	for _, redirect := range client.Redirects {
+		if getHost(redirect) != client.GetSectorIdentifier() {
+			return errors.New("redirects with different hosts require a sector identifier uri")
+		}
+	}
+
+	return nil
+}
+
+func getHost(uri string) string {
+	parsedUri, parseError := url.Parse(uri)
+	if parseError != nil {
+		return ""
+	}
+	return parsedUri.Hostname()
+}
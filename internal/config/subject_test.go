@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func Test_GetSubject(t *testing.T) {
+	testConfig := &Config{
+		Server: Server{
+			PairwiseSalt: "salt",
+		},
+		Users: []User{
+			{Username: "foo"},
+			{Username: "bar"},
+		},
+	}
+
+	initializationError := Initialize(testConfig)
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+
+	publicClient := &Client{Id: "public", Redirects: []string{"https://example.com/callback"}}
+	pairwiseClient := &Client{Id: "pairwise", SubjectType: SubjectTypePairwise, Redirects: []string{"https://example.com/callback"}}
+	sameSectorClient := &Client{Id: "same", SubjectType: SubjectTypePairwise, Redirects: []string{"https://example.com/other"}}
+	otherSectorClient := &Client{Id: "other", SubjectType: SubjectTypePairwise, Redirects: []string{"https://example.org/callback"}}
+
+	if subject := testConfig.GetSubject(publicClient, "foo"); subject != "foo" {
+		t.Errorf("expected username as public subject, got %s", subject)
+	}
+
+	pairwiseSubject := testConfig.GetSubject(pairwiseClient, "foo")
+	if pairwiseSubject == "foo" || pairwiseSubject == "" {
+		t.Errorf("expected pairwise subject, got %s", pairwiseSubject)
+	}
+
+	if testConfig.GetSubject(pairwiseClient, "foo") != pairwiseSubject {
+		t.Error("expected stable pairwise subject")
+	}
+
+	if testConfig.GetSubject(sameSectorClient, "foo") != pairwiseSubject {
+		t.Error("expected same pairwise subject within a sector")
+	}
+
+	if testConfig.GetSubject(otherSectorClient, "foo") == pairwiseSubject {
+		t.Error("expected different pairwise subject for another sector")
+	}
+
+	if testConfig.GetSubject(pairwiseClient, "bar") == pairwiseSubject {
+		t.Error("expected different pairwise subject for another user")
+	}
+
+	user, userExists := testConfig.GetUserBySubject(pairwiseClient, pairwiseSubject)
+	if !userExists || user.Username != "foo" {
+		t.Errorf("expected pairwise subject to be mapped to user foo, got %v", user)
+	}
+
+	_, userExists = testConfig.GetUserBySubject(pairwiseClient, "foo")
+	if userExists {
+		t.Error("expected username not to be a pairwise subject")
+	}
+
+	user, userExists = testConfig.GetUserBySubject(publicClient, "bar")
+	if !userExists || user.Username != "bar" {
+		t.Errorf("expected public subject to be mapped to user bar, got %v", user)
+	}
+}
+
+func Test_GetSectorIdentifier(t *testing.T) {
+	type parameter struct {
+		client   Client
+		expected string
+	}
+
+	var parameters = []parameter{
+		{Client{Id: "foo", SectorIdentifierUri: "https://sector.example.com/redirects.json", Redirects: []string{"https://example.com/callback"}}, "sector.example.com"},
+		{Client{Id: "foo", Redirects: []string{"https://example.com:8443/callback"}}, "example.com"},
+		{Client{Id: "foo"}, "foo"},
+	}
+
+	for index, test := range parameters {
+		testMessage := fmt.Sprintf("Sector identifier %d", index)
+		t.Run(testMessage, func(t *testing.T) {
+			sectorIdentifier := test.client.GetSectorIdentifier()
+
+			if sectorIdentifier != test.expected {
+				t.Errorf("expected sector identifier %s, got %s", test.expected, sectorIdentifier)
+			}
+		})
+	}
+}
+
+func Test_GetSubjectTypes(t *testing.T) {
+	publicConfig := &Config{}
+	if slices.Contains(publicConfig.GetSubjectTypes(), SubjectTypePairwise) {
+		t.Error("expected no pairwise subject type without salt")
+	}
+
+	pairwiseConfig := &Config{Server: Server{Secret: "secret"}}
+	if !slices.Equal(pairwiseConfig.GetSubjectTypes(), []string{SubjectTypePublic, SubjectTypePairwise}) {
+		t.Errorf("expected pairwise subject type with server secret, got %v", pairwiseConfig.GetSubjectTypes())
+	}
+}
+
+func Test_ValidatePairwiseSubject(t *testing.T) {
+	type parameter struct {
+		name    string
+		server  Server
+		client  Client
+		isValid bool
+	}
+
+	var parameters = []parameter{
+		{"public", Server{}, Client{}, true},
+		{"unsupported", Server{}, Client{SubjectType: "foo"}, false},
+		{"pairwise without salt", Server{}, Client{SubjectType: SubjectTypePairwise}, false},
+		{"pairwise with salt", Server{PairwiseSalt: "salt"}, Client{SubjectType: SubjectTypePairwise, Redirects: []string{"https://example.com/a", "https://example.com/b"}}, true},
+		{"pairwise with different hosts", Server{PairwiseSalt: "salt"}, Client{SubjectType: SubjectTypePairwise, Redirects: []string{"https://example.com/a", "https://example.org/b"}}, false},
+		{"pairwise with sector identifier", Server{Secret: "secret"}, Client{SubjectType: SubjectTypePairwise, SectorIdentifierUri: "https://example.com/redirects.json", Redirects: []string{"https://example.com/a", "https://example.org/b"}}, true},
+		{"pairwise with http sector identifier", Server{Secret: "secret"}, Client{SubjectType: SubjectTypePairwise, SectorIdentifierUri: "http://example.com/redirects.json"}, false},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Subject type %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			testConfig := &Config{Server: test.server}
+
+			validationError := testConfig.validatePairwiseSubject(&test.client)
+
+			if test.isValid && validationError != nil {
+				t.Errorf("expected valid subject type, got %v", validationError)
+			} else if !test.isValid && validationError == nil {
+				t.Error("expected invalid subject type")
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"os"
+)
+
+// LoadCertificatePool loads all PEM encoded certificates from a given filename.
+func LoadCertificatePool(filename string) (*x509.CertPool, error) {
+	certificateBytes, readError := os.ReadFile(filename)
+	if readError != nil {
+		return nil, readError
+	}
+
+	certificatePool := x509.NewCertPool()
+	if !certificatePool.AppendCertsFromPEM(certificateBytes) {
+		return nil, errors.New("failed to decode certificates")
+	}
+
+	return certificatePool, nil
+}
+
+// LoadCertificate loads the first PEM encoded certificate from a given filename.
+func LoadCertificate(filename string) (*x509.Certificate, error) {
+	certificateBytes, readError := os.ReadFile(filename)
+	if readError != nil {
+		return nil, readError
+	}
+
+	certificatePem, _ := pem.Decode(certificateBytes) // we do not use the 2nd return value "rest"
+	if certificatePem == nil || certificatePem.Type != "CERTIFICATE" {
+		return nil, errors.New("failed to decode certificate")
+	}
+
+	return x509.ParseCertificate(certificatePem.Bytes)
+}
+
+// CertificateThumbprint returns the base64url encoded SHA256 hash of the DER encoded certificate,
+// see https://datatracker.ietf.org/doc/html/rfc8705#section-3.1
+func CertificateThumbprint(certificate *x509.Certificate) string {
+	thumbprint := sha256.Sum256(certificate.Raw)
+	return base64.RawURLEncoding.EncodeToString(thumbprint[:])
+}
@@ -0,0 +1,48 @@
+package crypto
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_LoadCertificatePool(t *testing.T) {
+	type parameter struct {
+		fileName string
+		valid    bool
+	}
+
+	var parameters = []parameter{
+		{"server.crt", true},
+		{"rsa256pub.pem", false},
+		{"foo-bar.pem", false},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Load certificate pool %s", test.fileName)
+		t.Run(testMessage, func(t *testing.T) {
+			certificatePool, err := LoadCertificatePool("../../.test_files/" + test.fileName)
+
+			if (err == nil) != test.valid || (certificatePool != nil) != test.valid {
+				t.Errorf("expected certificate pool valid to be %v, %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func Test_CertificateThumbprint(t *testing.T) {
+	certificate, err := LoadCertificate("../../.test_files/server.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thumbprint := CertificateThumbprint(certificate)
+
+	if thumbprint != "I49wZc2xHZ7e_4N46QX17FG7rs7vkITmQ2AQ9fAsOtU" {
+		t.Errorf("unexpected certificate thumbprint %s", thumbprint)
+	}
+
+	_, err = LoadCertificate("../../.test_files/rsa256pub.pem")
+	if err == nil {
+		t.Error("public key should not be loaded as certificate")
+	}
+}
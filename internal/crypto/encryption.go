@@ -0,0 +1,120 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/webishdev/stopnik/internal/config"
+	"os"
+	"slices"
+)
+
+// KeyEncryptionAlgorithms contains the supported algorithms to encrypt the content encryption key of a JWE.
+var KeyEncryptionAlgorithms = []jwa.KeyEncryptionAlgorithm{
+	jwa.RSA_OAEP, jwa.RSA_OAEP_256,
+	jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW,
+}
+
+// ContentEncryptionAlgorithms contains the supported algorithms to encrypt the content of a JWE.
+var ContentEncryptionAlgorithms = []jwa.ContentEncryptionAlgorithm{
+	jwa.A128GCM, jwa.A192GCM, jwa.A256GCM,
+	jwa.A128CBC_HS256, jwa.A192CBC_HS384, jwa.A256CBC_HS512,
+}
+
+// EncryptionKey defines a public key of a client with the algorithms used to encrypt objects sent to the client.
+type EncryptionKey struct {
+	Key                        jwk.Key
+	KeyEncryptionAlgorithm     jwa.KeyEncryptionAlgorithm
+	ContentEncryptionAlgorithm jwa.ContentEncryptionAlgorithm
+}
+
+// NewEncryptionKey loads the key of a config.ClientEncryption and checks the configured algorithms.
+// RSA keys default to RSA-OAEP and EC keys to ECDH-ES, the content is encrypted with A256GCM by default.
+func NewEncryptionKey(encryption *config.ClientEncryption) (*EncryptionKey, error) {
+	key, loadError := LoadEncryptionKey(encryption.Key)
+	if loadError != nil {
+		return nil, loadError
+	}
+
+	keyEncryptionAlgorithm := jwa.KeyEncryptionAlgorithm(encryption.Algorithm)
+	if keyEncryptionAlgorithm == "" {
+		keyEncryptionAlgorithm = jwa.ECDH_ES
+		if key.KeyType() == jwa.RSA {
+			keyEncryptionAlgorithm = jwa.RSA_OAEP
+		}
+	}
+
+	contentEncryptionAlgorithm := jwa.ContentEncryptionAlgorithm(encryption.ContentEncryption)
+	if contentEncryptionAlgorithm == "" {
+		contentEncryptionAlgorithm = jwa.A256GCM
+	}
+
+	if !slices.Contains(KeyEncryptionAlgorithms, keyEncryptionAlgorithm) {
+		return nil, fmt.Errorf("unsupported key encryption algorithm %s", keyEncryptionAlgorithm)
+	}
+
+	if !slices.Contains(ContentEncryptionAlgorithms, contentEncryptionAlgorithm) {
+		return nil, fmt.Errorf("unsupported content encryption algorithm %s", contentEncryptionAlgorithm)
+	}
+
+	rsaAlgorithm := keyEncryptionAlgorithm == jwa.RSA_OAEP || keyEncryptionAlgorithm == jwa.RSA_OAEP_256
+	if rsaAlgorithm != (key.KeyType() == jwa.RSA) {
+		return nil, fmt.Errorf("key encryption algorithm %s does not match key type %s", keyEncryptionAlgorithm, key.KeyType())
+	}
+
+	return &EncryptionKey{
+		Key:                        key,
+		KeyEncryptionAlgorithm:     keyEncryptionAlgorithm,
+		ContentEncryptionAlgorithm: contentEncryptionAlgorithm,
+	}, nil
+}
+
+// LoadEncryptionKey loads a public key from a given filename, which is either PEM encoded or contains a JWKS.
+// From a JWKS the first key intended for encryption is used.
+func LoadEncryptionKey(filename string) (jwk.Key, error) {
+	keyBytes, readError := os.ReadFile(filename)
+	if readError != nil {
+		return nil, readError
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(keyBytes), []byte("{")) {
+		publicKey, loadError := LoadPublicKey(filename)
+		if loadError != nil {
+			return nil, loadError
+		}
+		return jwk.FromRaw(publicKey)
+	}
+
+	keySet, parseError := jwk.Parse(keyBytes)
+	if parseError != nil {
+		return nil, parseError
+	}
+
+	for index := 0; index < keySet.Len(); index++ {
+		key, _ := keySet.Key(index)
+		if key.KeyUsage() == "" || key.KeyUsage() == string(jwk.ForEncryption) {
+			return jwk.PublicKeyOf(key)
+		}
+	}
+
+	return nil, errors.New("no encryption key in key set")
+}
+
+// Encrypt encrypts the payload as compact JWE, the content type is added as cty header when provided.
+func (encryptionKey *EncryptionKey) Encrypt(payload []byte, contentType string) ([]byte, error) {
+	headers := jwe.NewHeaders()
+	if contentType != "" {
+		if setError := headers.Set(jwe.ContentTypeKey, contentType); setError != nil {
+			return nil, setError
+		}
+	}
+
+	return jwe.Encrypt(payload,
+		jwe.WithKey(encryptionKey.KeyEncryptionAlgorithm, encryptionKey.Key),
+		jwe.WithContentEncryption(encryptionKey.ContentEncryptionAlgorithm),
+		jwe.WithProtectedHeaders(headers),
+	)
+}
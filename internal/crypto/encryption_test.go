@@ -0,0 +1,129 @@
+package crypto
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/webishdev/stopnik/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_EncryptionKey(t *testing.T) {
+	jwksFile := testCreateJwksFile(t, "../../.test_files/ecdsa256pub.pem")
+
+	type parameter struct {
+		encryption                 config.ClientEncryption
+		keyEncryptionAlgorithm     jwa.KeyEncryptionAlgorithm
+		contentEncryptionAlgorithm jwa.ContentEncryptionAlgorithm
+		privateKey                 string
+	}
+
+	var parameters = []parameter{
+		{config.ClientEncryption{Key: "../../.test_files/rsa256pub.pem"}, jwa.RSA_OAEP, jwa.A256GCM, "../../.test_files/rsa256key.pem"},
+		{config.ClientEncryption{Key: "../../.test_files/rsa256pub.pem", Algorithm: "RSA-OAEP-256", ContentEncryption: "A128CBC-HS256"}, jwa.RSA_OAEP_256, jwa.A128CBC_HS256, "../../.test_files/rsa256key.pem"},
+		{config.ClientEncryption{Key: "../../.test_files/ecdsa256pub.pem"}, jwa.ECDH_ES, jwa.A256GCM, "../../.test_files/ecdsa256key.pem"},
+		{config.ClientEncryption{Key: jwksFile, Algorithm: "ECDH-ES+A256KW"}, jwa.ECDH_ES_A256KW, jwa.A256GCM, "../../.test_files/ecdsa256key.pem"},
+	}
+
+	for index, test := range parameters {
+		testMessage := fmt.Sprintf("Encryption key %d with %s", index, test.encryption.Key)
+		t.Run(testMessage, func(t *testing.T) {
+			encryptionKey, loadError := NewEncryptionKey(&test.encryption)
+			if loadError != nil {
+				t.Fatal(loadError)
+			}
+
+			if encryptionKey.KeyEncryptionAlgorithm != test.keyEncryptionAlgorithm || encryptionKey.ContentEncryptionAlgorithm != test.contentEncryptionAlgorithm {
+				t.Errorf("unexpected algorithms %s %s", encryptionKey.KeyEncryptionAlgorithm, encryptionKey.ContentEncryptionAlgorithm)
+			}
+
+			encrypted, encryptError := encryptionKey.Encrypt([]byte("foo"), "JWT")
+			if encryptError != nil {
+				t.Fatal(encryptError)
+			}
+
+			message, parseError := jwe.Parse(encrypted)
+			if parseError != nil {
+				t.Fatal(parseError)
+			}
+
+			if message.ProtectedHeaders().ContentType() != "JWT" {
+				t.Errorf("unexpected content type %s", message.ProtectedHeaders().ContentType())
+			}
+
+			privateKey, privateKeyError := LoadPrivateKey(test.privateKey)
+			if privateKeyError != nil {
+				t.Fatal(privateKeyError)
+			}
+
+			decrypted, decryptError := jwe.Decrypt(encrypted, jwe.WithKey(test.keyEncryptionAlgorithm, privateKey.PrivateKey))
+			if decryptError != nil {
+				t.Fatal(decryptError)
+			}
+
+			if string(decrypted) != "foo" {
+				t.Errorf("decrypted payload does not match, expected foo but got %s", decrypted)
+			}
+		})
+	}
+}
+
+func Test_InvalidEncryptionKey(t *testing.T) {
+	var parameters = []config.ClientEncryption{
+		{Key: "../../.test_files/notexisting.pem"},
+		{Key: "../../.test_files/invalidkey.pem"},
+		{Key: "../../.test_files/rsa256pub.pem", Algorithm: "dir"},
+		{Key: "../../.test_files/rsa256pub.pem", ContentEncryption: "A256KW"},
+		{Key: "../../.test_files/rsa256pub.pem", Algorithm: "ECDH-ES"},
+		{Key: "../../.test_files/ecdsa256pub.pem", Algorithm: "RSA-OAEP"},
+	}
+
+	for index, test := range parameters {
+		testMessage := fmt.Sprintf("Invalid encryption key %d", index)
+		t.Run(testMessage, func(t *testing.T) {
+			_, loadError := NewEncryptionKey(&test)
+
+			if loadError == nil {
+				t.Error("expected error when loading encryption key")
+			}
+		})
+	}
+}
+
+func testCreateJwksFile(t *testing.T, publicKeyFile string) string {
+	publicKey, loadError := LoadPublicKey(publicKeyFile)
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+
+	key, keyError := jwk.FromRaw(publicKey)
+	if keyError != nil {
+		t.Fatal(keyError)
+	}
+
+	if setError := key.Set(jwk.KeyUsageKey, jwk.ForEncryption); setError != nil {
+		t.Fatal(setError)
+	}
+
+	keySet := jwk.NewSet()
+	if addError := keySet.AddKey(key); addError != nil {
+		t.Fatal(addError)
+	}
+
+	keySetBytes, marshalError := json.Marshal(keySet)
+	if marshalError != nil {
+		t.Fatal(marshalError)
+	}
+
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	writeError := os.WriteFile(file, keySetBytes, 0o600)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+
+	return file
+}
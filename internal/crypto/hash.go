@@ -2,6 +2,7 @@ package crypto
 
 import (
 	"crypto/sha1"
+	"crypto/sha256"
 	"crypto/sha512"
 	"fmt"
 )
@@ -21,6 +22,11 @@ func Sha512SaltedHash(value string, salt string) string {
 	}
 }
 
+// Sha256Hash returns a SHA256 hash for the given value.
+func Sha256Hash(value string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(value)))
+}
+
 // Sha1Hash returns a SHA1 hash for the given value.
 func Sha1Hash(value string) string {
 	return fmt.Sprintf("%x", sha1.Sum([]byte(value)))
@@ -72,3 +72,25 @@ func Test_Sha1Hash(t *testing.T) {
 		})
 	}
 }
+
+func Test_Sha256Hash(t *testing.T) {
+	type parameter struct {
+		value    string
+		expected string
+	}
+
+	var parameters = []parameter{
+		{"foo", "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"},
+		{"bar", "fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9"},
+		{"moo123", "d185f0f0bb6dc814420df16811453a91692b7cc24c3cbd1fa284cb09fd6d9f46"},
+	}
+
+	for _, test := range parameters {
+		testMessage := test.value
+		t.Run(testMessage, func(t *testing.T) {
+			if output := Sha256Hash(test.value); output != test.expected {
+				t.Errorf("Output %s not equal to expected %s", output, test.expected)
+			}
+		})
+	}
+}
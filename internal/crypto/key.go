@@ -28,19 +28,21 @@ type SigningPrivateKey struct {
 	HashAlgorithm      HashAlgorithm
 }
 
-// ManagedKey defines a combination of keys defined for config.Client.
+// ManagedKey defines a combination of keys defined for config.Client and config.Resource.
 type ManagedKey struct {
 	Id            string
 	Clients       []*config.Client
+	Resources     []*config.Resource
 	Server        bool
+	Encryption    bool
 	Key           *jwk.Key
 	HashAlgorithm HashAlgorithm
 }
 
 // ServerSecretLoader defines how to receive a private server key.
 type ServerSecretLoader interface {
-	// GetServerKey returns the private key of the server.
-	GetServerKey() jwt.SignEncryptParseOption
+	// GetServerKey returns the private key of the server, suboptions are passed to jwt.WithKey.
+	GetServerKey(suboptions ...jwt.Option) jwt.SignEncryptParseOption
 }
 
 type serverSecret struct {
@@ -51,6 +53,10 @@ type serverSecret struct {
 type KeyLoader interface {
 	// LoadKeys returns a ManagedKey for a specific client and a bool indicating whether a key exists or not.
 	LoadKeys(client *config.Client) (*ManagedKey, bool)
+	// LoadResourceKeys returns a ManagedKey for a specific resource and a bool indicating whether a key exists or not.
+	LoadResourceKeys(resource *config.Resource) (*ManagedKey, bool)
+	// LoadEncryptionKey returns an EncryptionKey for a specific client and a bool indicating whether a key exists or not.
+	LoadEncryptionKey(client *config.Client) (*EncryptionKey, bool)
 	ServerSecretLoader
 }
 
@@ -61,8 +67,8 @@ func NewServerSecretLoader() ServerSecretLoader {
 }
 
 // GetServerKey returns the server secret as jwa.HS256 key.
-func (s *serverSecret) GetServerKey() jwt.SignEncryptParseOption {
-	return jwt.WithKey(jwa.HS256, []byte(s.secret))
+func (s *serverSecret) GetServerKey(suboptions ...jwt.Option) jwt.SignEncryptParseOption {
+	return jwt.WithKey(jwa.HS256, []byte(s.secret), suboptions...)
 }
 
 // LoadPrivateKey loads a private key from a given filename.
@@ -127,3 +133,29 @@ func LoadPrivateKey(filename string) (*SigningPrivateKey, error) {
 
 	return nil, errors.New("invalid private key")
 }
+
+// LoadPublicKey loads a PKIX or PKCS #1 public key or the public key of a certificate from a given filename.
+func LoadPublicKey(filename string) (interface{}, error) {
+	publicKeyBytes, readError := os.ReadFile(filename)
+	if readError != nil {
+		return nil, readError
+	}
+
+	publicPem, _ := pem.Decode(publicKeyBytes) // we do not use the 2nd return value "rest"
+	if publicPem == nil {
+		return nil, errors.New("failed to decode public key")
+	}
+
+	switch publicPem.Type {
+	case "CERTIFICATE":
+		certificate, certificateError := x509.ParseCertificate(publicPem.Bytes)
+		if certificateError != nil {
+			return nil, certificateError
+		}
+		return certificate.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(publicPem.Bytes)
+	default:
+		return x509.ParsePKIXPublicKey(publicPem.Bytes)
+	}
+}
@@ -28,6 +28,8 @@ func Test_Key(t *testing.T) {
 	testLoadInvalidPrivateKey(t)
 
 	testLoadUnsupportedCurvePrivateKey(t)
+
+	testLoadPublicKey(t)
 }
 
 func testServerKeyLoader(t *testing.T) {
@@ -121,3 +123,33 @@ func testLoadUnsupportedCurvePrivateKey(t *testing.T) {
 		}
 	})
 }
+
+func testLoadPublicKey(t *testing.T) {
+	type parameter struct {
+		fileName string
+		valid    bool
+	}
+
+	var parameters = []parameter{
+		{"rsa256pub.pem", true},
+		{"ecdsa256pub.pem", true},
+		{"server.crt", true},
+		{"invalidkey.pem", false},
+		{"foo-bar.pem", false},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Load public key from file %v", test.fileName)
+		t.Run(testMessage, func(t *testing.T) {
+			publicKey, err := LoadPublicKey("../../.test_files/" + test.fileName)
+
+			if test.valid && (err != nil || publicKey == nil) {
+				t.Errorf("Could not load public key, %v", err)
+			}
+
+			if !test.valid && err == nil {
+				t.Errorf("Loaded public key from invalid file")
+			}
+		})
+	}
+}
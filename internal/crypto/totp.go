@@ -0,0 +1,50 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+const totpTimeStep = 30 * time.Second
+
+// Totp returns the six digit time-based one-time password of a key for the given time,
+// see https://datatracker.ietf.org/doc/html/rfc6238#section-4
+func Totp(key []byte, at time.Time) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(TotpStep(at)))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	// https://datatracker.ietf.org/doc/html/rfc4226#section-5.3
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%06d", code%1000000)
+}
+
+// TotpStep returns the time step of the given time, which is the counter used for the one-time password.
+func TotpStep(at time.Time) int64 {
+	return at.Unix() / int64(totpTimeStep/time.Second)
+}
+
+// ValidateTotp checks a time-based one-time password against a key,
+// the previous and the next time step are accepted to allow some clock drift.
+// Also returns the time step the password was valid for, which allows callers to reject reused passwords.
+// See https://datatracker.ietf.org/doc/html/rfc6238#section-5.2
+func ValidateTotp(key []byte, code string, at time.Time) (int64, bool) {
+	if len(key) == 0 || len(code) != 6 {
+		return 0, false
+	}
+	for _, drift := range []time.Duration{0, -totpTimeStep, totpTimeStep} {
+		if subtle.ConstantTimeCompare([]byte(Totp(key, at.Add(drift))), []byte(code)) == 1 {
+			return TotpStep(at.Add(drift)), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,70 @@
+package crypto
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_Totp(t *testing.T) {
+	type parameter struct {
+		unix     int64
+		expected string
+	}
+
+	// https://datatracker.ietf.org/doc/html/rfc6238#appendix-B
+	var parameters = []parameter{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	key := []byte("12345678901234567890")
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("TOTP at %d", test.unix)
+		t.Run(testMessage, func(t *testing.T) {
+			if output := Totp(key, time.Unix(test.unix, 0)); output != test.expected {
+				t.Errorf("Output %s not equal to expected %s", output, test.expected)
+			}
+		})
+	}
+}
+
+func Test_ValidateTotp(t *testing.T) {
+	type parameter struct {
+		name     string
+		key      []byte
+		code     string
+		at       time.Time
+		step     int64
+		expected bool
+	}
+
+	key := []byte("12345678901234567890")
+	now := time.Unix(1111111111, 0)
+
+	var parameters = []parameter{
+		{"current", key, Totp(key, now), now, TotpStep(now), true},
+		{"previous step", key, Totp(key, now.Add(-totpTimeStep)), now, TotpStep(now) - 1, true},
+		{"next step", key, Totp(key, now.Add(totpTimeStep)), now, TotpStep(now) + 1, true},
+		{"expired", key, Totp(key, now.Add(-3*totpTimeStep)), now, 0, false},
+		{"wrong length", key, "12345", now, 0, false},
+		{"no key", nil, Totp(nil, now), now, 0, false},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Validate TOTP %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			step, output := ValidateTotp(test.key, test.code, test.at)
+			if output != test.expected {
+				t.Errorf("Output %v not equal to expected %v", output, test.expected)
+			}
+			if step != test.step {
+				t.Errorf("Step %d not equal to expected %d", step, test.step)
+			}
+		})
+	}
+}
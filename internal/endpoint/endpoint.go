@@ -12,4 +12,6 @@ const (
 	Keys          string = "/keys"
 	OidcDiscovery string = "/.well-known/openid-configuration"
 	OidcUserInfo  string = "/userinfo"
+	Metrics       string = "/metrics"
+	Registration  string = "/register"
 )
@@ -20,6 +20,8 @@ func Test_Endpoint(t *testing.T) {
 		{Introspect, "/introspect"},
 		{Revoke, "/revoke"},
 		{Metadata, "/.well-known/oauth-authorization-server"},
+		{Metrics, "/metrics"},
+		{Registration, "/register"},
 	}
 
 	for _, test := range endpointParameters {
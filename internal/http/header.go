@@ -8,17 +8,24 @@ const (
 	ETag                     string = "ETag"
 	Authorization            string = "Authorization"
 	AccessControlAllowOrigin string = "Access-Control-Allow-Origin"
+	Accept                   string = "Accept"
 	AcceptEncoding           string = "Accept-Encoding"
 	XForwardProtocol         string = "X-Forwarded-Proto"
 	XForwardHost             string = "X-Forwarded-Host"
 	XForwardUri              string = "X-Forwarded-Uri"
+	XRequestId               string = "X-Request-Id"
+	DPoP                     string = "DPoP"
+	DPoPNonce                string = "DPoP-Nonce"
 )
 
 const (
 	AuthBasic  string = "Basic"
 	AuthBearer string = "Bearer"
+	AuthDPoP   string = "DPoP"
 )
 
 const (
-	ContentTypeJSON string = "application/json"
+	ContentTypeJSON                  string = "application/json"
+	ContentTypeJWT                   string = "application/jwt"
+	ContentTypeTokenIntrospectionJWT string = "application/token-introspection+jwt"
 )
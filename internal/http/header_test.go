@@ -19,6 +19,9 @@ func Test_HTTPHeaders(t *testing.T) {
 		{AuthBasic, "Basic"},
 		{AuthBearer, "Bearer"},
 		{ContentTypeJSON, "application/json"},
+		{ContentTypeJWT, "application/jwt"},
+		{Accept, "Accept"},
+		{ContentTypeTokenIntrospectionJWT, "application/token-introspection+jwt"},
 	}
 
 	for _, test := range headerParameters {
@@ -0,0 +1,25 @@
+package http
+
+import (
+	"net/http"
+)
+
+// SendJwt writes a serialized JWT with the given content type as response.
+func SendJwt(token string, contentType string, w http.ResponseWriter, r *http.Request) error {
+	requestData := NewRequestData(r)
+	responseWriter := NewResponseWriter(w, requestData)
+
+	responseWriter.SetEncodingHeader()
+
+	w.Header().Set(ContentType, contentType)
+	w.Header().Set(CacheControl, "private, no-store")
+	w.Header().Set(AccessControlAllowOrigin, "*")
+	w.WriteHeader(http.StatusOK)
+
+	_, writeError := responseWriter.Write([]byte(token))
+	if writeError != nil {
+		return writeError
+	}
+
+	return nil
+}
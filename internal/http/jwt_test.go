@@ -0,0 +1,34 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_SendJwt(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	request := httptest.NewRequest(http.MethodGet, "https://example.com/foo", nil)
+	err := SendJwt("header.payload.signature", ContentTypeTokenIntrospectionJWT, rr, request)
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	contentType := rr.Header().Get(ContentType)
+
+	if contentType != ContentTypeTokenIntrospectionJWT {
+		t.Errorf("content type should be %s", ContentTypeTokenIntrospectionJWT)
+	}
+
+	cacheControl := rr.Header().Get(CacheControl)
+
+	if cacheControl != "private, no-store" {
+		t.Errorf("cache control should be %s, but was %s", "private, no-store", cacheControl)
+	}
+
+	if rr.Body.String() != "header.payload.signature" {
+		t.Errorf("body should be %s, but was %s", "header.payload.signature", rr.Body.String())
+	}
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"crypto/x509"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -65,6 +66,14 @@ func NewRequestData(r *http.Request) *RequestData {
 	}
 }
 
+// GetClientCertificate returns the TLS client certificate of a request, when present.
+func GetClientCertificate(r *http.Request) (*x509.Certificate, bool) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, false
+	}
+	return r.TLS.PeerCertificates[0], true
+}
+
 func (r *RequestData) IssuerString() string {
 	return fmt.Sprintf("%s://%s", r.scheme, r.host)
 }
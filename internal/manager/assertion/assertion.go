@@ -0,0 +1,183 @@
+package assertion
+
+import (
+	"context"
+	"fmt"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
+	"github.com/webishdev/stopnik/internal/store"
+	"github.com/webishdev/stopnik/internal/system"
+	"github.com/webishdev/stopnik/internal/tracing"
+	"github.com/webishdev/stopnik/log"
+	"slices"
+	"sync"
+	"time"
+)
+
+// acceptableSkew is the allowed clock difference between STOPnik and a trusted issuer.
+const acceptableSkew = time.Minute
+
+// Grant contains the values of a validated JWT authorization grant.
+// User is only set when the subject is mapped to a configured user.
+type Grant struct {
+	Issuer    string
+	Subject   string
+	User      *config.User
+	ExpiresAt time.Time
+}
+
+type Manager struct {
+	config   *config.Config
+	keys     map[string]interface{}
+	jtiStore *store.ExpiringStore[string]
+	mux      *sync.Mutex
+}
+
+var assertionManagerLock = &sync.Mutex{}
+var assertionManagerSingleton *Manager
+
+func GetAssertionManagerInstance() *Manager {
+	assertionManagerLock.Lock()
+	defer assertionManagerLock.Unlock()
+	if assertionManagerSingleton == nil {
+		assertionManagerSingleton = newAssertionManager(config.GetConfigInstance())
+	}
+	return assertionManagerSingleton
+}
+
+func newAssertionManager(currentConfig *config.Config) *Manager {
+	jtiStore := store.NewDefaultTimedStore[string]()
+	assertionManager := &Manager{
+		config:   currentConfig,
+		keys:     make(map[string]interface{}),
+		jtiStore: &jtiStore,
+		mux:      &sync.Mutex{},
+	}
+
+	for _, trustedIssuer := range currentConfig.Server.TrustedIssuers {
+		publicKey, loadError := crypto.LoadPublicKey(trustedIssuer.PublicKey)
+		if loadError != nil {
+			system.Error(fmt.Errorf("could not load public key of trusted issuer %s: %w", trustedIssuer.Issuer, loadError))
+			continue
+		}
+		assertionManager.keys[trustedIssuer.Issuer] = publicKey
+	}
+
+	return assertionManager
+}
+
+// ValidateAssertion validates a JWT authorization grant signed by a trusted issuer the client may use,
+// the audience of the assertion must contain one of the given values identifying STOPnik.
+// Assertions living longer than the maximum lifetime of the issuer are rejected,
+// each jti is only accepted once until the assertion expires.
+// Implements https://datatracker.ietf.org/doc/html/rfc7523#section-3
+func (assertionManager *Manager) ValidateAssertion(ctx context.Context, assertion string, audience []string, client *config.Client) (*Grant, bool) {
+	_, span := tracing.Start(ctx, "assertion.Manager.ValidateAssertion")
+	defer span.End()
+	unverifiedToken, parseError := jwt.ParseInsecure([]byte(assertion))
+	if parseError != nil {
+		log.DebugContext(ctx, "Invalid assertion", log.Err(parseError))
+		return nil, false
+	}
+
+	issuer := unverifiedToken.Issuer()
+	trustedIssuer, trustedIssuerExists := assertionManager.config.GetTrustedIssuer(issuer)
+	publicKey, publicKeyExists := assertionManager.keys[issuer]
+	if !trustedIssuerExists || !publicKeyExists {
+		log.DebugContext(ctx, "Assertion from untrusted issuer", "issuer", issuer)
+		return nil, false
+	}
+
+	if !client.ValidateAssertionIssuer(issuer) {
+		log.DebugContext(ctx, "Assertion issuer not allowed for client", "issuer", issuer)
+		return nil, false
+	}
+
+	signatureAlgorithm, algorithmExists := getSignatureAlgorithm(assertion)
+	if !algorithmExists {
+		return nil, false
+	}
+
+	token, verifyError := jwt.Parse([]byte(assertion),
+		jwt.WithKey(signatureAlgorithm, publicKey),
+		jwt.WithIssuer(issuer),
+		jwt.WithAcceptableSkew(acceptableSkew),
+		jwt.WithRequiredClaim(jwt.SubjectKey),
+		jwt.WithRequiredClaim(jwt.ExpirationKey),
+		jwt.WithRequiredClaim(jwt.IssuedAtKey),
+		jwt.WithRequiredClaim(jwt.JwtIDKey),
+	)
+	if verifyError != nil {
+		log.DebugContext(ctx, "Invalid assertion", "issuer", issuer, log.Err(verifyError))
+		return nil, false
+	}
+
+	maxLifetime := time.Minute * time.Duration(trustedIssuer.GetMaxLifetime())
+	if token.Expiration().Sub(token.IssuedAt()) > maxLifetime {
+		log.DebugContext(ctx, "Assertion exceeds maximum lifetime", "issuer", issuer, "max_lifetime", maxLifetime)
+		return nil, false
+	}
+
+	validAudience := slices.ContainsFunc(token.Audience(), func(value string) bool {
+		return slices.Contains(audience, value)
+	})
+	if !validAudience {
+		log.DebugContext(ctx, "Invalid assertion audience", "audience", token.Audience())
+		return nil, false
+	}
+
+	if !assertionManager.useJwtId(issuer, token.JwtID(), token.Expiration()) {
+		log.DebugContext(ctx, "Replayed assertion", "issuer", issuer)
+		return nil, false
+	}
+
+	grant := &Grant{
+		Issuer:    issuer,
+		Subject:   token.Subject(),
+		ExpiresAt: token.Expiration(),
+	}
+
+	if !trustedIssuer.ServiceAccount {
+		user, userExists := assertionManager.config.GetUser(token.Subject())
+		if !userExists {
+			log.DebugContext(ctx, "Assertion subject is no user", "subject", token.Subject())
+			return nil, false
+		}
+		grant.User = user
+	}
+
+	return grant, true
+}
+
+// useJwtId remembers a jti until the assertion expires and returns false when it was already used.
+func (assertionManager *Manager) useJwtId(issuer string, jwtId string, expiresAt time.Time) bool {
+	assertionManager.mux.Lock()
+	defer assertionManager.mux.Unlock()
+	jtiStore := *assertionManager.jtiStore
+	key := issuer + " " + jwtId
+	_, used := jtiStore.Get(key)
+	if used {
+		return false
+	}
+	jtiStore.SetWithDuration(key, &jwtId, time.Until(expiresAt)+acceptableSkew)
+	return true
+}
+
+// getSignatureAlgorithm returns the asymmetric signature algorithm of an assertion,
+// symmetric algorithms are not supported because trusted issuers are configured by public key.
+func getSignatureAlgorithm(assertion string) (jwa.SignatureAlgorithm, bool) {
+	message, parseError := jws.Parse([]byte(assertion))
+	if parseError != nil || len(message.Signatures()) != 1 {
+		return "", false
+	}
+	signatureAlgorithm := message.Signatures()[0].ProtectedHeaders().Algorithm()
+	switch signatureAlgorithm {
+	case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512, jwa.ES256, jwa.ES384, jwa.ES512, jwa.EdDSA:
+		return signatureAlgorithm, true
+	default:
+		return "", false
+	}
+}
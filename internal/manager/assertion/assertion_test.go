@@ -0,0 +1,156 @@
+package assertion
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
+	"testing"
+	"time"
+)
+
+const testAudience = "https://stopnik.example.com/token"
+
+func Test_ValidateAssertion(t *testing.T) {
+	testConfig := &config.Config{
+		Server: config.Server{
+			TrustedIssuers: []config.TrustedIssuer{
+				{Issuer: "https://ci.example.com", PublicKey: "../../../.test_files/rsa256pub.pem", ServiceAccount: true},
+				{Issuer: "https://idp.example.com", PublicKey: "../../../.test_files/ecdsa256pub.pem"},
+			},
+		},
+		Clients: []config.Client{
+			{
+				Id:               "foo",
+				ClientSecret:     "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				AssertionIssuers: []string{"https://ci.example.com", "https://idp.example.com"},
+			},
+			{
+				Id:               "bar",
+				ClientSecret:     "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				AssertionIssuers: []string{"https://idp.example.com"},
+			},
+		},
+		Users: []config.User{
+			{
+				Username: "foo",
+				Password: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+			},
+		},
+	}
+	initializationError := config.Initialize(testConfig)
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+
+	type assertionParameter struct {
+		name            string
+		clientId        string
+		keyFile         string
+		issuer          string
+		subject         string
+		audience        string
+		jwtId           string
+		expiresIn       time.Duration
+		valid           bool
+		expectedUser    bool
+		expectedSubject string
+	}
+
+	var assertionParameters = []assertionParameter{
+		{"service account", "foo", "rsa256key.pem", "https://ci.example.com", "deploy-bot", testAudience, uuid.NewString(), time.Minute, true, false, "deploy-bot"},
+		{"user subject", "foo", "ecdsa256key.pem", "https://idp.example.com", "foo", testAudience, uuid.NewString(), time.Minute, true, true, "foo"},
+		{"unknown user subject", "foo", "ecdsa256key.pem", "https://idp.example.com", "bar", testAudience, uuid.NewString(), time.Minute, false, false, ""},
+		{"untrusted issuer", "foo", "rsa256key.pem", "https://other.example.com", "deploy-bot", testAudience, uuid.NewString(), time.Minute, false, false, ""},
+		{"issuer not allowed for client", "bar", "rsa256key.pem", "https://ci.example.com", "deploy-bot", testAudience, uuid.NewString(), time.Minute, false, false, ""},
+		{"wrong key", "foo", "ecdsa256key.pem", "https://ci.example.com", "deploy-bot", testAudience, uuid.NewString(), time.Minute, false, false, ""},
+		{"wrong audience", "foo", "rsa256key.pem", "https://ci.example.com", "deploy-bot", "https://other.example.com/token", uuid.NewString(), time.Minute, false, false, ""},
+		{"expired", "foo", "rsa256key.pem", "https://ci.example.com", "deploy-bot", testAudience, uuid.NewString(), -time.Hour, false, false, ""},
+		{"lifetime exceeded", "foo", "rsa256key.pem", "https://ci.example.com", "deploy-bot", testAudience, uuid.NewString(), time.Hour, false, false, ""},
+		{"missing jti", "foo", "rsa256key.pem", "https://ci.example.com", "deploy-bot", testAudience, "", time.Minute, false, false, ""},
+	}
+
+	assertionManager := newAssertionManager(testConfig)
+
+	for _, test := range assertionParameters {
+		testMessage := fmt.Sprintf("Validate assertion %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			client, _ := testConfig.GetClient(test.clientId)
+			assertion := testCreateAssertion(t, test.keyFile, test.issuer, test.subject, test.audience, test.jwtId, test.expiresIn)
+
+			grant, valid := assertionManager.ValidateAssertion(context.Background(), assertion, []string{"https://stopnik.example.com", testAudience}, client)
+
+			if valid != test.valid {
+				t.Fatalf("expected assertion valid to be %v", test.valid)
+			}
+
+			if !test.valid {
+				return
+			}
+
+			if grant.Subject != test.expectedSubject || grant.Issuer != test.issuer {
+				t.Errorf("grant subject or issuer did not match, %v", grant)
+			}
+
+			if test.expectedUser != (grant.User != nil) {
+				t.Errorf("grant user did not match, %v", grant.User)
+			}
+
+			_, replayed := assertionManager.ValidateAssertion(context.Background(), assertion, []string{testAudience}, client)
+			if replayed {
+				t.Error("replayed assertion should not be valid")
+			}
+		})
+	}
+
+	t.Run("Validate assertion with symmetric algorithm", func(t *testing.T) {
+		client, _ := testConfig.GetClient("foo")
+		token := testCreateAssertionToken(t, "https://ci.example.com", "deploy-bot", testAudience, uuid.NewString(), time.Minute)
+		signedToken, signError := jwt.Sign(token, jwt.WithKey(jwa.HS256, []byte("secret")))
+		if signError != nil {
+			t.Fatal(signError)
+		}
+
+		_, valid := assertionManager.ValidateAssertion(context.Background(), string(signedToken), []string{testAudience}, client)
+		if valid {
+			t.Error("assertion with symmetric algorithm should not be valid")
+		}
+	})
+}
+
+func testCreateAssertion(t *testing.T, keyFile string, issuer string, subject string, audience string, jwtId string, expiresIn time.Duration) string {
+	signingPrivateKey, loadError := crypto.LoadPrivateKey("../../../.test_files/" + keyFile)
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+
+	token := testCreateAssertionToken(t, issuer, subject, audience, jwtId, expiresIn)
+	signedToken, signError := jwt.Sign(token, jwt.WithKey(signingPrivateKey.SignatureAlgorithm, signingPrivateKey.PrivateKey))
+	if signError != nil {
+		t.Fatal(signError)
+	}
+
+	return string(signedToken)
+}
+
+func testCreateAssertionToken(t *testing.T, issuer string, subject string, audience string, jwtId string, expiresIn time.Duration) jwt.Token {
+	builder := jwt.NewBuilder().
+		Issuer(issuer).
+		Subject(subject).
+		Audience([]string{audience}).
+		IssuedAt(time.Now()).
+		Expiration(time.Now().Add(expiresIn))
+	if jwtId != "" {
+		builder.JwtID(jwtId)
+	}
+
+	token, buildError := builder.Build()
+	if buildError != nil {
+		t.Fatal(buildError)
+	}
+
+	return token
+}
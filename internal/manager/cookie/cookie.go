@@ -1,13 +1,16 @@
 package cookie
 
 import (
+	"context"
 	"fmt"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/webishdev/stopnik/internal/config"
 	"github.com/webishdev/stopnik/internal/crypto"
 	"github.com/webishdev/stopnik/internal/manager/session"
+	"github.com/webishdev/stopnik/internal/tracing"
 	"github.com/webishdev/stopnik/log"
 	"net/http"
+	"slices"
 	"sync"
 	"time"
 )
@@ -45,7 +48,7 @@ func newCookieManagerWithTime(now Now) *Manager {
 
 func (cookieManager *Manager) CreateMessageCookie(message string) http.Cookie {
 	messageCookieName := cookieManager.config.GetMessageCookieName()
-	log.Debug("Creating %s message cookie", message)
+	log.Debug("Creating message cookie", "message", message)
 	return http.Cookie{
 		Name:     messageCookieName,
 		Value:    message,
@@ -79,30 +82,95 @@ func (cookieManager *Manager) DeleteAuthCookie() http.Cookie {
 
 func (cookieManager *Manager) CreateAuthCookie(username string, loginSessionId string) (http.Cookie, error) {
 	authCookieName := cookieManager.config.GetAuthCookieName()
-	log.Debug("Creating %s auth cookie", authCookieName)
+	log.Debug("Creating auth cookie", "cookie", authCookieName)
 	return cookieManager.createAuthCookie(authCookieName, username, loginSessionId)
 }
 
+// AddAuthCookieSession creates an auth cookie with the given login session as current session,
+// the login sessions of other users kept by the existing auth cookie stay available for account selection.
+func (cookieManager *Manager) AddAuthCookieSession(r *http.Request, username string, loginSessionId string) (http.Cookie, error) {
+	ctx, span := tracing.Start(r.Context(), "cookie.Manager.AddAuthCookieSession")
+	defer span.End()
+	r = r.WithContext(ctx)
+	authCookieName := cookieManager.config.GetAuthCookieName()
+	log.DebugContext(r.Context(), "Adding login session to auth cookie", "cookie", authCookieName)
+	loginSessionIds := []string{loginSessionId}
+	for _, loginSession := range cookieManager.GetAuthCookieSessions(r) {
+		if loginSession.Username != username {
+			loginSessionIds = append(loginSessionIds, loginSession.Id)
+		}
+	}
+	return cookieManager.createAuthCookie(authCookieName, username, loginSessionIds...)
+}
+
+// GetAuthCookieSessions returns all valid login sessions of the auth cookie, the current login session is returned first.
+func (cookieManager *Manager) GetAuthCookieSessions(r *http.Request) []*session.LoginSession {
+	cookie, cookieError := r.Cookie(cookieManager.config.GetAuthCookieName())
+	if cookieError != nil {
+		return []*session.LoginSession{}
+	}
+
+	options := cookieManager.keyFallback.GetServerKey()
+	token, err := jwt.Parse([]byte(cookie.Value), options)
+	if err != nil {
+		return []*session.LoginSession{}
+	}
+
+	loginClaim, loginClaimExists := token.Get("login")
+	if !loginClaimExists {
+		return []*session.LoginSession{}
+	}
+
+	loginSessionIds := []string{fmt.Sprintf("%s", loginClaim)}
+	if loginsClaim, loginsClaimExists := token.Get("logins"); loginsClaimExists {
+		if logins, isList := loginsClaim.([]any); isList {
+			for _, login := range logins {
+				loginSessionIds = append(loginSessionIds, fmt.Sprintf("%s", login))
+			}
+		}
+	}
+
+	var loginSessions []*session.LoginSession
+	var usernames []string
+	for _, loginSessionId := range loginSessionIds {
+		loginSession, loginSessionExists := cookieManager.loginSession.GetSession(r.Context(), loginSessionId)
+		if !loginSessionExists || slices.Contains(usernames, loginSession.Username) {
+			continue
+		}
+		if _, userExists := cookieManager.config.GetUser(loginSession.Username); userExists {
+			loginSessions = append(loginSessions, loginSession)
+			usernames = append(usernames, loginSession.Username)
+		}
+	}
+	return loginSessions
+}
+
 func (cookieManager *Manager) ValidateAuthCookie(r *http.Request) (*config.User, *session.LoginSession, bool) {
+	ctx, span := tracing.Start(r.Context(), "cookie.Manager.ValidateAuthCookie")
+	defer span.End()
+	r = r.WithContext(ctx)
 	authCookieName := cookieManager.config.GetAuthCookieName()
-	log.Debug("Validating %s auth cookie", authCookieName)
+	log.DebugContext(r.Context(), "Validating auth cookie", "cookie", authCookieName)
 	return cookieManager.validateAuthCookie(authCookieName, r)
 }
 
 func (cookieManager *Manager) CreateForwardAuthCookie(username string, loginSessionId string) (http.Cookie, error) {
 	forwardAuthCookieName := cookieManager.config.GetForwardAuthCookieName()
-	log.Debug("Creating %s forward auth cookie", forwardAuthCookieName)
+	log.Debug("Creating forward auth cookie", "cookie", forwardAuthCookieName)
 	return cookieManager.createAuthCookie(forwardAuthCookieName, username, loginSessionId)
 }
 
 func (cookieManager *Manager) ValidateForwardAuthCookie(r *http.Request) (*config.User, *session.LoginSession, bool) {
+	ctx, span := tracing.Start(r.Context(), "cookie.Manager.ValidateForwardAuthCookie")
+	defer span.End()
+	r = r.WithContext(ctx)
 	forwardAuthCookieName := cookieManager.config.GetForwardAuthCookieName()
-	log.Debug("Validating %s forward auth cookie", forwardAuthCookieName)
+	log.DebugContext(r.Context(), "Validating forward auth cookie", "cookie", forwardAuthCookieName)
 	return cookieManager.validateAuthCookie(forwardAuthCookieName, r)
 }
 
-func (cookieManager *Manager) createAuthCookie(name string, username string, loginSessionId string) (http.Cookie, error) {
-	value, err := cookieManager.generateAuthCookieValue(username, loginSessionId)
+func (cookieManager *Manager) createAuthCookie(name string, username string, loginSessionIds ...string) (http.Cookie, error) {
+	value, err := cookieManager.generateAuthCookieValue(username, loginSessionIds)
 	if err != nil {
 		return http.Cookie{}, err
 	}
@@ -121,11 +189,11 @@ func (cookieManager *Manager) validateAuthCookie(name string, r *http.Request) (
 	if cookieError != nil {
 		return &config.User{}, &session.LoginSession{}, false
 	} else {
-		return cookieManager.validateAuthCookieValue(cookie)
+		return cookieManager.validateAuthCookieValue(r.Context(), cookie)
 	}
 }
 
-func (cookieManager *Manager) validateAuthCookieValue(cookie *http.Cookie) (*config.User, *session.LoginSession, bool) {
+func (cookieManager *Manager) validateAuthCookieValue(ctx context.Context, cookie *http.Cookie) (*config.User, *session.LoginSession, bool) {
 	options := cookieManager.keyFallback.GetServerKey()
 	token, err := jwt.Parse([]byte(cookie.Value), options)
 	if err != nil {
@@ -139,7 +207,7 @@ func (cookieManager *Manager) validateAuthCookieValue(cookie *http.Cookie) (*con
 
 	loginSessionId := fmt.Sprintf("%s", loginClaim)
 
-	loginSession, loginSessionExists := cookieManager.loginSession.GetSession(loginSessionId)
+	loginSession, loginSessionExists := cookieManager.loginSession.GetSession(ctx, loginSessionId)
 	if !loginSessionExists {
 		return &config.User{}, &session.LoginSession{}, false
 	}
@@ -150,13 +218,16 @@ func (cookieManager *Manager) validateAuthCookieValue(cookie *http.Cookie) (*con
 	return user, loginSession, userExists
 }
 
-func (cookieManager *Manager) generateAuthCookieValue(username string, loginSessionId string) (string, error) {
+func (cookieManager *Manager) generateAuthCookieValue(username string, loginSessionIds []string) (string, error) {
 	sessionTimeout := cookieManager.config.GetSessionTimeoutSeconds()
-	token, builderError := jwt.NewBuilder().
+	builder := jwt.NewBuilder().
 		Subject(username).
-		Claim("login", loginSessionId).
-		Expiration(cookieManager.now().Add(time.Second * time.Duration(sessionTimeout))).
-		Build()
+		Claim("login", loginSessionIds[0]).
+		Expiration(cookieManager.now().Add(time.Second * time.Duration(sessionTimeout)))
+	if len(loginSessionIds) > 1 {
+		builder.Claim("logins", loginSessionIds[1:])
+	}
+	token, builderError := builder.Build()
 	if builderError != nil {
 		return "", builderError
 	}
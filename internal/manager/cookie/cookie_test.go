@@ -1,6 +1,7 @@
 package cookie
 
 import (
+	"context"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/webishdev/stopnik/internal/config"
@@ -22,6 +23,7 @@ func Test_Cookie(t *testing.T) {
 	testConfig := &config.Config{
 		Users: []config.User{
 			{Username: "foo", Password: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181"},
+			{Username: "moo", Password: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181"},
 		},
 	}
 	initializationError := config.Initialize(testConfig)
@@ -37,7 +39,7 @@ func Test_Cookie(t *testing.T) {
 			Id:       uuid.NewString(),
 			Username: "foo",
 		}
-		loginSessionManager.StartSession(loginSession)
+		loginSessionManager.StartSession(context.Background(), loginSession)
 
 		cookie, cookieError := cookieManager.CreateAuthCookie("foo", loginSession.Id)
 
@@ -124,6 +126,44 @@ func Test_Cookie(t *testing.T) {
 		}
 	})
 
+	t.Run("Add login sessions to auth cookie", func(t *testing.T) {
+		cookieManager := GetCookieManagerInstance()
+		loginSessionManager := session.GetLoginSessionManagerInstance()
+
+		fooSession := &session.LoginSession{Id: uuid.NewString(), Username: "foo"}
+		mooSession := &session.LoginSession{Id: uuid.NewString(), Username: "moo"}
+		otherFooSession := &session.LoginSession{Id: uuid.NewString(), Username: "foo"}
+		loginSessionManager.StartSession(context.Background(), fooSession)
+		loginSessionManager.StartSession(context.Background(), mooSession)
+		loginSessionManager.StartSession(context.Background(), otherFooSession)
+
+		httpRequest := httptest.NewRequest(http.MethodGet, endpoint.Authorization, nil)
+		for _, loginSession := range []*session.LoginSession{fooSession, mooSession, otherFooSession} {
+			cookie, cookieError := cookieManager.AddAuthCookieSession(httpRequest, loginSession.Username, loginSession.Id)
+			if cookieError != nil {
+				t.Fatal(cookieError)
+			}
+			testAuthCookieValues(t, cookie, 3600)
+			httpRequest = httptest.NewRequest(http.MethodGet, endpoint.Authorization, nil)
+			httpRequest.AddCookie(&cookie)
+		}
+
+		loginSessions := cookieManager.GetAuthCookieSessions(httpRequest)
+		if len(loginSessions) != 2 || loginSessions[0].Id != otherFooSession.Id || loginSessions[1].Id != mooSession.Id {
+			t.Errorf("expected current foo session and moo session, got %v", loginSessions)
+		}
+
+		user, loginSession, exists := cookieManager.ValidateAuthCookie(httpRequest)
+		if !exists || user.Username != "foo" || loginSession.Id != otherFooSession.Id {
+			t.Errorf("expected current session of foo, got %v", loginSession)
+		}
+
+		loginSessionManager.DeleteSession(context.Background(), mooSession.Id)
+		if loginSessions = cookieManager.GetAuthCookieSessions(httpRequest); len(loginSessions) != 1 {
+			t.Errorf("expected closed login session to be removed, got %v", loginSessions)
+		}
+	})
+
 	t.Run("Delete auth cookie", func(t *testing.T) {
 		cookieManager := GetCookieManagerInstance()
 
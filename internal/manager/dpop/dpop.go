@@ -0,0 +1,239 @@
+package dpop
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/webishdev/stopnik/internal/config"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/store"
+	"github.com/webishdev/stopnik/log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+// proofType is the typ header of DPoP proofs, see https://datatracker.ietf.org/doc/html/rfc9449#section-4.2
+const proofType = "dpop+jwt"
+
+const (
+	claimHttpMethod      = "htm"
+	claimHttpUri         = "htu"
+	claimNonce           = "nonce"
+	claimAccessTokenHash = "ath"
+)
+
+// proofWindow is the allowed difference between the iat of a proof and the current time.
+const proofWindow = time.Minute
+
+// nonceDuration is the time a nonce provided by STOPnik is accepted.
+const nonceDuration = 5 * time.Minute
+
+var (
+	ErrInvalidProof = errors.New("invalid DPoP proof")
+	ErrUseNonce     = errors.New("DPoP proof without valid nonce")
+)
+
+// SigningAlgorithms contains the asymmetric algorithms supported for DPoP proofs.
+var SigningAlgorithms = []jwa.SignatureAlgorithm{
+	jwa.RS256, jwa.RS384, jwa.RS512,
+	jwa.PS256, jwa.PS384, jwa.PS512,
+	jwa.ES256, jwa.ES384, jwa.ES512,
+	jwa.EdDSA,
+}
+
+type Manager struct {
+	config     *config.Config
+	jtiStore   *store.ExpiringStore[string]
+	nonceStore *store.ExpiringStore[string]
+	mux        *sync.Mutex
+}
+
+var dpopManagerLock = &sync.Mutex{}
+var dpopManagerSingleton *Manager
+
+func GetDPoPManagerInstance() *Manager {
+	dpopManagerLock.Lock()
+	defer dpopManagerLock.Unlock()
+	if dpopManagerSingleton == nil {
+		dpopManagerSingleton = newDPoPManager(config.GetConfigInstance())
+	}
+	return dpopManagerSingleton
+}
+
+func newDPoPManager(currentConfig *config.Config) *Manager {
+	jtiStore := store.NewTimedStore[string](2 * proofWindow)
+	nonceStore := store.NewTimedStore[string](nonceDuration)
+	return &Manager{
+		config:     currentConfig,
+		jtiStore:   &jtiStore,
+		nonceStore: &nonceStore,
+		mux:        &sync.Mutex{},
+	}
+}
+
+// HasProof returns whether a request contains a DPoP header.
+func HasProof(r *http.Request) bool {
+	return len(r.Header.Values(internalHttp.DPoP)) > 0
+}
+
+// ValidateTokenRequestProof validates the DPoP proof of a token request and returns the JWK thumbprint of its key.
+// A nonce is required when configured, see https://datatracker.ietf.org/doc/html/rfc9449#section-8
+func (dpopManager *Manager) ValidateTokenRequestProof(r *http.Request) (string, error) {
+	return dpopManager.validateProof(r, "", dpopManager.config.Server.DPoP.RequireNonce)
+}
+
+// ValidateResourceRequestProof validates the DPoP proof of a request to a protected resource
+// and returns the JWK thumbprint of its key. The proof must contain the hash of the access token.
+// See https://datatracker.ietf.org/doc/html/rfc9449#section-7
+func (dpopManager *Manager) ValidateResourceRequestProof(r *http.Request, accessToken string) (string, error) {
+	return dpopManager.validateProof(r, accessToken, false)
+}
+
+// NewNonce returns a nonce which clients must use in their next DPoP proofs.
+func (dpopManager *Manager) NewNonce() string {
+	nonce := uuid.NewString()
+	nonceStore := *dpopManager.nonceStore
+	nonceStore.Set(nonce, &nonce)
+	return nonce
+}
+
+// validateProof implements https://datatracker.ietf.org/doc/html/rfc9449#section-4.3
+func (dpopManager *Manager) validateProof(r *http.Request, accessToken string, requireNonce bool) (string, error) {
+	proofs := r.Header.Values(internalHttp.DPoP)
+	if len(proofs) != 1 {
+		log.DebugContext(r.Context(), "Expected exactly one DPoP proof", "proofs", len(proofs))
+		return "", ErrInvalidProof
+	}
+	proof := []byte(proofs[0])
+
+	message, parseError := jws.Parse(proof)
+	if parseError != nil || len(message.Signatures()) != 1 {
+		log.DebugContext(r.Context(), "Invalid DPoP proof", log.Err(parseError))
+		return "", ErrInvalidProof
+	}
+
+	headers := message.Signatures()[0].ProtectedHeaders()
+	signatureAlgorithm := headers.Algorithm()
+	publicKey := headers.JWK()
+	if headers.Type() != proofType || !slices.Contains(SigningAlgorithms, signatureAlgorithm) || publicKey == nil {
+		log.DebugContext(r.Context(), "Invalid DPoP proof header")
+		return "", ErrInvalidProof
+	}
+
+	asymmetricKey, isAsymmetric := publicKey.(jwk.AsymmetricKey)
+	if !isAsymmetric || asymmetricKey.IsPrivate() {
+		log.DebugContext(r.Context(), "DPoP proof must contain a public key")
+		return "", ErrInvalidProof
+	}
+
+	token, verifyError := jwt.Parse(proof,
+		jwt.WithKey(signatureAlgorithm, publicKey),
+		jwt.WithAcceptableSkew(proofWindow),
+		jwt.WithRequiredClaim(jwt.IssuedAtKey),
+		jwt.WithRequiredClaim(jwt.JwtIDKey),
+	)
+	if verifyError != nil {
+		log.DebugContext(r.Context(), "Invalid DPoP proof", log.Err(verifyError))
+		return "", ErrInvalidProof
+	}
+
+	if time.Since(token.IssuedAt()) > proofWindow {
+		log.DebugContext(r.Context(), "DPoP proof issued at invalid time", "iat", token.IssuedAt())
+		return "", ErrInvalidProof
+	}
+
+	httpMethod, _ := token.Get(claimHttpMethod)
+	httpUri, _ := token.Get(claimHttpUri)
+	if httpMethod != r.Method || !dpopManager.validHttpUri(r, httpUri) {
+		log.DebugContext(r.Context(), "DPoP proof does not match request", "htm", httpMethod, "htu", httpUri)
+		return "", ErrInvalidProof
+	}
+
+	if accessToken != "" {
+		accessTokenHash, _ := token.Get(claimAccessTokenHash)
+		if accessTokenHash != hashAccessToken(accessToken) {
+			log.DebugContext(r.Context(), "DPoP proof does not match access token")
+			return "", ErrInvalidProof
+		}
+	}
+
+	if requireNonce && !dpopManager.validNonce(token) {
+		return "", ErrUseNonce
+	}
+
+	thumbprint, thumbprintError := publicKey.Thumbprint(crypto.SHA256)
+	if thumbprintError != nil {
+		log.DebugContext(r.Context(), "Could not calculate JWK thumbprint", log.Err(thumbprintError))
+		return "", ErrInvalidProof
+	}
+	jwkThumbprint := base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	if !dpopManager.useJwtId(jwkThumbprint, token.JwtID()) {
+		log.DebugContext(r.Context(), "Replayed DPoP proof")
+		return "", ErrInvalidProof
+	}
+
+	return jwkThumbprint, nil
+}
+
+// validHttpUri compares the htu claim without query and fragment with the URL of the request,
+// derived from either the request or the configured issuer.
+func (dpopManager *Manager) validHttpUri(r *http.Request, httpUri any) bool {
+	httpUriValue, isString := httpUri.(string)
+	if !isString {
+		return false
+	}
+	parsedUri, parseError := url.Parse(httpUriValue)
+	if parseError != nil {
+		return false
+	}
+	parsedUri.RawQuery = ""
+	parsedUri.Fragment = ""
+	requestData := internalHttp.NewRequestData(r)
+	requestUris := []string{
+		requestData.IssuerString() + r.URL.Path,
+		dpopManager.config.GetIssuer(requestData) + r.URL.Path,
+	}
+	return slices.Contains(requestUris, parsedUri.String())
+}
+
+func (dpopManager *Manager) validNonce(token jwt.Token) bool {
+	nonce, nonceExists := token.Get(claimNonce)
+	nonceValue, isString := nonce.(string)
+	if !nonceExists || !isString {
+		return false
+	}
+	nonceStore := *dpopManager.nonceStore
+	_, valid := nonceStore.Get(nonceValue)
+	return valid
+}
+
+// useJwtId remembers the jti of a proof and returns false when it was already used.
+func (dpopManager *Manager) useJwtId(jwkThumbprint string, jwtId string) bool {
+	dpopManager.mux.Lock()
+	defer dpopManager.mux.Unlock()
+	jtiStore := *dpopManager.jtiStore
+	key := jwkThumbprint + " " + jwtId
+	_, used := jtiStore.Get(key)
+	if used {
+		return false
+	}
+	jtiStore.Set(key, &jwtId)
+	return true
+}
+
+// hashAccessToken returns the ath value of an access token,
+// see https://datatracker.ietf.org/doc/html/rfc9449#section-4.2
+func hashAccessToken(accessToken string) string {
+	hashed := sha256.Sum256([]byte(accessToken))
+	return base64.RawURLEncoding.EncodeToString(hashed[:])
+}
@@ -0,0 +1,243 @@
+package dpop
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testTokenUri = "http://example.com/token"
+
+type testProof struct {
+	typ        string
+	method     string
+	uri        string
+	issuedAt   time.Time
+	jwtId      string
+	ath        string
+	nonce      string
+	privateJwk bool
+}
+
+func Test_ValidateTokenRequestProof(t *testing.T) {
+	initializationError := config.Initialize(&config.Config{})
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+	dpopManager := newDPoPManager(config.GetConfigInstance())
+
+	type proofParameter struct {
+		name  string
+		proof testProof
+		valid bool
+	}
+
+	var proofParameters = []proofParameter{
+		{"valid", testProof{proofType, http.MethodPost, testTokenUri, time.Now(), uuid.NewString(), "", "", false}, true},
+		{"valid with query", testProof{proofType, http.MethodPost, testTokenUri + "?foo=bar", time.Now(), uuid.NewString(), "", "", false}, true},
+		{"wrong type", testProof{"JWT", http.MethodPost, testTokenUri, time.Now(), uuid.NewString(), "", "", false}, false},
+		{"wrong method", testProof{proofType, http.MethodGet, testTokenUri, time.Now(), uuid.NewString(), "", "", false}, false},
+		{"wrong uri", testProof{proofType, http.MethodPost, "http://example.com/other", time.Now(), uuid.NewString(), "", "", false}, false},
+		{"old proof", testProof{proofType, http.MethodPost, testTokenUri, time.Now().Add(-time.Hour), uuid.NewString(), "", "", false}, false},
+		{"future proof", testProof{proofType, http.MethodPost, testTokenUri, time.Now().Add(time.Hour), uuid.NewString(), "", "", false}, false},
+		{"missing jti", testProof{proofType, http.MethodPost, testTokenUri, time.Now(), "", "", "", false}, false},
+		{"private key", testProof{proofType, http.MethodPost, testTokenUri, time.Now(), uuid.NewString(), "", "", true}, false},
+	}
+
+	for _, test := range proofParameters {
+		testMessage := fmt.Sprintf("DPoP proof %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			proof := testCreateProof(t, test.proof)
+			request := httptest.NewRequest(http.MethodPost, testTokenUri, nil)
+			request.Header.Set(internalHttp.DPoP, proof)
+
+			jwkThumbprint, proofError := dpopManager.ValidateTokenRequestProof(request)
+
+			if (proofError == nil) != test.valid {
+				t.Fatalf("expected proof valid to be %v, %v", test.valid, proofError)
+			}
+
+			if !test.valid {
+				return
+			}
+
+			if jwkThumbprint == "" {
+				t.Error("expected JWK thumbprint")
+			}
+
+			_, replayError := dpopManager.ValidateTokenRequestProof(request)
+			if replayError == nil {
+				t.Error("replayed proof should not be valid")
+			}
+		})
+	}
+
+	t.Run("DPoP proof missing", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPost, testTokenUri, nil)
+
+		if HasProof(request) {
+			t.Error("request should not have a proof")
+		}
+
+		_, proofError := dpopManager.ValidateTokenRequestProof(request)
+		if proofError == nil {
+			t.Error("missing proof should not be valid")
+		}
+	})
+
+	t.Run("DPoP proof with symmetric algorithm", func(t *testing.T) {
+		token := testCreateProofToken(t, testProof{proofType, http.MethodPost, testTokenUri, time.Now(), uuid.NewString(), "", "", false})
+		headers := jws.NewHeaders()
+		_ = headers.Set(jws.TypeKey, proofType)
+		signedToken, signError := jwt.Sign(token, jwt.WithKey(jwa.HS256, []byte("secret"), jws.WithProtectedHeaders(headers)))
+		if signError != nil {
+			t.Fatal(signError)
+		}
+		request := httptest.NewRequest(http.MethodPost, testTokenUri, nil)
+		request.Header.Set(internalHttp.DPoP, string(signedToken))
+
+		_, proofError := dpopManager.ValidateTokenRequestProof(request)
+		if proofError == nil {
+			t.Error("proof with symmetric algorithm should not be valid")
+		}
+	})
+}
+
+func Test_ValidateTokenRequestProofNonce(t *testing.T) {
+	testConfig := &config.Config{
+		Server: config.Server{
+			DPoP: config.DPoP{RequireNonce: true},
+		},
+	}
+	dpopManager := newDPoPManager(testConfig)
+	nonce := dpopManager.NewNonce()
+
+	type nonceParameter struct {
+		name          string
+		nonce         string
+		expectedError error
+	}
+
+	var nonceParameters = []nonceParameter{
+		{"without nonce", "", ErrUseNonce},
+		{"with unknown nonce", "foo", ErrUseNonce},
+		{"with nonce", nonce, nil},
+	}
+
+	for _, test := range nonceParameters {
+		testMessage := fmt.Sprintf("DPoP proof %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			proof := testCreateProof(t, testProof{proofType, http.MethodPost, testTokenUri, time.Now(), uuid.NewString(), "", test.nonce, false})
+			request := httptest.NewRequest(http.MethodPost, testTokenUri, nil)
+			request.Header.Set(internalHttp.DPoP, proof)
+
+			_, proofError := dpopManager.ValidateTokenRequestProof(request)
+
+			if !errors.Is(proofError, test.expectedError) {
+				t.Errorf("expected error %v, got %v", test.expectedError, proofError)
+			}
+		})
+	}
+}
+
+func Test_ValidateResourceRequestProof(t *testing.T) {
+	initializationError := config.Initialize(&config.Config{})
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+	dpopManager := newDPoPManager(config.GetConfigInstance())
+	resourceUri := "http://example.com/userinfo"
+
+	type resourceParameter struct {
+		name  string
+		ath   string
+		valid bool
+	}
+
+	var resourceParameters = []resourceParameter{
+		{"with access token hash", hashAccessToken("foo"), true},
+		{"with other access token hash", hashAccessToken("bar"), false},
+		{"without access token hash", "", false},
+	}
+
+	for _, test := range resourceParameters {
+		testMessage := fmt.Sprintf("DPoP proof %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			proof := testCreateProof(t, testProof{proofType, http.MethodGet, resourceUri, time.Now(), uuid.NewString(), test.ath, "", false})
+			request := httptest.NewRequest(http.MethodGet, resourceUri, nil)
+			request.Header.Set(internalHttp.DPoP, proof)
+
+			_, proofError := dpopManager.ValidateResourceRequestProof(request, "foo")
+
+			if (proofError == nil) != test.valid {
+				t.Errorf("expected proof valid to be %v, %v", test.valid, proofError)
+			}
+		})
+	}
+}
+
+func testCreateProof(t *testing.T, proof testProof) string {
+	signingPrivateKey, loadError := crypto.LoadPrivateKey("../../../.test_files/ecdsa256key.pem")
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+
+	privateKey, keyError := jwk.FromRaw(signingPrivateKey.PrivateKey)
+	if keyError != nil {
+		t.Fatal(keyError)
+	}
+
+	headerKey := privateKey
+	if !proof.privateJwk {
+		headerKey, keyError = jwk.PublicKeyOf(privateKey)
+		if keyError != nil {
+			t.Fatal(keyError)
+		}
+	}
+
+	headers := jws.NewHeaders()
+	_ = headers.Set(jws.TypeKey, proof.typ)
+	_ = headers.Set(jws.JWKKey, headerKey)
+
+	token := testCreateProofToken(t, proof)
+	signedToken, signError := jwt.Sign(token, jwt.WithKey(signingPrivateKey.SignatureAlgorithm, signingPrivateKey.PrivateKey, jws.WithProtectedHeaders(headers)))
+	if signError != nil {
+		t.Fatal(signError)
+	}
+
+	return string(signedToken)
+}
+
+func testCreateProofToken(t *testing.T, proof testProof) jwt.Token {
+	builder := jwt.NewBuilder().
+		IssuedAt(proof.issuedAt).
+		Claim(claimHttpMethod, proof.method).
+		Claim(claimHttpUri, proof.uri)
+	if proof.jwtId != "" {
+		builder.JwtID(proof.jwtId)
+	}
+	if proof.ath != "" {
+		builder.Claim(claimAccessTokenHash, proof.ath)
+	}
+	if proof.nonce != "" {
+		builder.Claim(claimNonce, proof.nonce)
+	}
+
+	token, buildError := builder.Build()
+	if buildError != nil {
+		t.Fatal(buildError)
+	}
+
+	return token
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"fmt"
+	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwk"
 	"github.com/webishdev/stopnik/internal/config"
 	"github.com/webishdev/stopnik/internal/crypto"
@@ -15,7 +16,9 @@ import (
 )
 
 type Manger struct {
-	keyStore *store.Store[crypto.ManagedKey]
+	keyStore             *store.Store[crypto.ManagedKey]
+	encryptionKey        *crypto.ManagedKey
+	clientEncryptionKeys map[string]*crypto.EncryptionKey
 }
 
 var keyManagerLock = &sync.Mutex{}
@@ -28,7 +31,8 @@ func GetKeyMangerInstance() *Manger {
 		currentConfig := config.GetConfigInstance()
 		newStore := store.NewStore[crypto.ManagedKey]()
 		keyManager := &Manger{
-			keyStore: &newStore,
+			keyStore:             &newStore,
+			clientEncryptionKeys: make(map[string]*crypto.EncryptionKey),
 		}
 
 		serverKeyError := keyManager.addSeverKey(currentConfig)
@@ -41,6 +45,21 @@ func GetKeyMangerInstance() *Manger {
 			system.Error(clientKeyError)
 		}
 
+		resourceKeyError := keyManager.addResourceKeys(currentConfig)
+		if resourceKeyError != nil {
+			system.Error(resourceKeyError)
+		}
+
+		encryptionKeyError := keyManager.addServerEncryptionKey(currentConfig)
+		if encryptionKeyError != nil {
+			system.Error(encryptionKeyError)
+		}
+
+		clientEncryptionKeyError := keyManager.addClientEncryptionKeys(currentConfig)
+		if clientEncryptionKeyError != nil {
+			system.Error(clientEncryptionKeyError)
+		}
+
 		keyManagerSingleton = keyManager
 	}
 
@@ -64,9 +83,36 @@ func (km *Manger) getClientKey(c *config.Client) *crypto.ManagedKey {
 	return result
 }
 
+// getResourceKey returns the key of a resource, there is no fallback to the server key.
+func (km *Manger) getResourceKey(r *config.Resource) *crypto.ManagedKey {
+	for _, mangedKey := range km.GetAllKeys() {
+		for _, resource := range mangedKey.Resources {
+			if resource.Id == r.Id {
+				return mangedKey
+			}
+		}
+	}
+
+	return nil
+}
+
+// getClientEncryptionKey returns the public key objects sent to a client are encrypted with.
+func (km *Manger) getClientEncryptionKey(c *config.Client) *crypto.EncryptionKey {
+	return km.clientEncryptionKeys[c.Id]
+}
+
 func (km *Manger) GetAllKeys() []*crypto.ManagedKey {
 	keyStore := *km.keyStore
-	return keyStore.GetValues()
+	keys := keyStore.GetValues()
+	if km.encryptionKey != nil {
+		keys = append(keys, km.encryptionKey)
+	}
+	return keys
+}
+
+// GetEncryptionKey returns the key clients use to encrypt objects sent to STOPnik, e.g. request objects.
+func (km *Manger) GetEncryptionKey() (*crypto.ManagedKey, bool) {
+	return km.encryptionKey, km.encryptionKey != nil
 }
 
 func (km *Manger) addSeverKey(c *config.Config) error {
@@ -109,15 +155,87 @@ func (km *Manger) addClientKeys(c *config.Config) error {
 	return nil
 }
 
+func (km *Manger) addResourceKeys(c *config.Config) error {
+
+	for _, resource := range c.Resources {
+		if resource.PrivateKey != "" {
+			signingPrivateKey, loadError := crypto.LoadPrivateKey(resource.PrivateKey)
+			if loadError != nil {
+				return loadError
+			}
+			managedKey, convertError := km.convert(signingPrivateKey)
+			if convertError != nil {
+				return convertError
+			}
+
+			managedKey.Resources = []*config.Resource{&resource}
+			km.addManagedKey(managedKey)
+		}
+	}
+
+	return nil
+}
+
+// addServerEncryptionKey adds the server encryption key, which is kept apart from the signing keys.
+// RSA keys are used with RSA-OAEP-256 and EC keys with ECDH-ES.
+func (km *Manger) addServerEncryptionKey(c *config.Config) error {
+	if c.Server.EncryptionKey != "" {
+		privateKey, loadError := crypto.LoadPrivateKey(c.Server.EncryptionKey)
+		if loadError != nil {
+			return loadError
+		}
+
+		managedKey, convertError := km.convert(privateKey)
+		if convertError != nil {
+			return convertError
+		}
+
+		encryptionAlgorithm := jwa.ECDH_ES
+		if _, isRSA := privateKey.PrivateKey.(*rsa.PrivateKey); isRSA {
+			encryptionAlgorithm = jwa.RSA_OAEP_256
+		}
+
+		key := *managedKey.Key
+		if setError := key.Set(jwk.AlgorithmKey, encryptionAlgorithm); setError != nil {
+			return setError
+		}
+		if setError := key.Set(jwk.KeyUsageKey, "enc"); setError != nil {
+			return setError
+		}
+
+		managedKey.Encryption = true
+		km.encryptionKey = managedKey
+	}
+
+	return nil
+}
+
+// addClientEncryptionKeys adds the public keys of clients, which are not published as they belong to the clients.
+func (km *Manger) addClientEncryptionKeys(c *config.Config) error {
+	for _, client := range c.Clients {
+		if client.Encryption.Key != "" {
+			encryptionKey, loadError := crypto.NewEncryptionKey(&client.Encryption)
+			if loadError != nil {
+				return fmt.Errorf("could not load encryption key of client %s: %w", client.Id, loadError)
+			}
+			km.clientEncryptionKeys[client.Id] = encryptionKey
+		}
+	}
+
+	return nil
+}
+
 func (km *Manger) addManagedKey(managedKey *crypto.ManagedKey) {
 	keyStore := *km.keyStore
 	existingKey, exists := keyStore.Get(managedKey.Id)
 	if exists {
 		mergedKey := &crypto.ManagedKey{
-			Id:      managedKey.Id,
-			Key:     managedKey.Key,
-			Server:  managedKey.Server || existingKey.Server,
-			Clients: append(managedKey.Clients, existingKey.Clients...),
+			Id:            managedKey.Id,
+			Key:           managedKey.Key,
+			HashAlgorithm: managedKey.HashAlgorithm,
+			Server:        managedKey.Server || existingKey.Server,
+			Clients:       append(managedKey.Clients, existingKey.Clients...),
+			Resources:     append(managedKey.Resources, existingKey.Resources...),
 		}
 		keyStore.Set(mergedKey.Id, mergedKey)
 	} else {
@@ -157,6 +275,7 @@ func (km *Manger) convert(signingPrivateKey *crypto.SigningPrivateKey) (*crypto.
 		Key:           &key,
 		HashAlgorithm: signingPrivateKey.HashAlgorithm,
 		Clients:       []*config.Client{},
+		Resources:     []*config.Resource{},
 	}
 
 	return managedKey, nil
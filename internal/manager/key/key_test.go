@@ -1,6 +1,7 @@
 package key
 
 import (
+	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/webishdev/stopnik/internal/config"
 	"testing"
 )
@@ -14,6 +15,12 @@ func Test_Keys(t *testing.T) {
 	testServerAndClientKeyConfigKeyManager(t)
 
 	testLoadClientKeys(t)
+
+	testLoadResourceKeys(t)
+
+	testLoadClientEncryptionKeys(t)
+
+	testServerEncryptionKeyConfigKeyManager(t)
 }
 
 func testEmptyConfigKeyManager(t *testing.T) {
@@ -56,6 +63,40 @@ func testServerKeyConfigKeyManager(t *testing.T) {
 	})
 }
 
+func testServerEncryptionKeyConfigKeyManager(t *testing.T) {
+	testConfig := &config.Config{
+		Server: config.Server{
+			PrivateKey:    "../../../.test_files/rsa256key.pem",
+			EncryptionKey: "../../../.test_files/ecdsa256key.pem",
+		},
+	}
+	err := config.Initialize(testConfig)
+	if err != nil {
+		t.Error(err)
+	}
+
+	t.Run("Server encryption key exists", func(t *testing.T) {
+		resetKeyManager()
+		keyManger := GetKeyMangerInstance()
+
+		keys := keyManger.GetAllKeys()
+
+		if len(keys) != 2 {
+			t.Error("Two keys should exists")
+		}
+
+		encryptionKey, encryptionKeyExists := keyManger.GetEncryptionKey()
+		if !encryptionKeyExists || !encryptionKey.Encryption || encryptionKey.Server {
+			t.Fatal("Encryption key should exist")
+		}
+
+		key := *encryptionKey.Key
+		if key.KeyUsage() != "enc" || key.Algorithm() != jwa.ECDH_ES {
+			t.Errorf("unexpected encryption key usage %s and algorithm %s", key.KeyUsage(), key.Algorithm())
+		}
+	})
+}
+
 func testServerAndClientKeyConfigKeyManager(t *testing.T) {
 	testSetupTestConfig(t)
 	t.Run("Server and client keys exists", func(t *testing.T) {
@@ -64,7 +105,7 @@ func testServerAndClientKeyConfigKeyManager(t *testing.T) {
 
 		keys := keyManger.GetAllKeys()
 
-		if len(keys) != 3 {
+		if len(keys) != 4 {
 			t.Error("Multiple keys should exists")
 		}
 	})
@@ -93,6 +134,72 @@ func testLoadClientKeys(t *testing.T) {
 	})
 }
 
+func testLoadResourceKeys(t *testing.T) {
+	testSetupTestConfig(t)
+	testConfig := config.GetConfigInstance()
+	t.Run("Load specific resource key", func(t *testing.T) {
+		resetKeyManager()
+		keyLoader := GetDefaultKeyLoaderInstance()
+
+		resource, resourceExists := testConfig.GetResource("https://api.example.com")
+		if !resourceExists {
+			t.Error("Resource should exist")
+		}
+
+		managedKey, mangedKeyExists := keyLoader.LoadResourceKeys(resource)
+		if !mangedKeyExists {
+			t.Error("Managed key should exist")
+		}
+
+		if managedKey.Server {
+			t.Error("Managed key should not match server key")
+		}
+
+		otherResource, otherResourceExists := testConfig.GetResource("https://other.example.com")
+		if !otherResourceExists {
+			t.Error("Resource should exist")
+		}
+
+		_, mangedKeyExists = keyLoader.LoadResourceKeys(otherResource)
+		if mangedKeyExists {
+			t.Error("Managed key should not exist for resource without private key")
+		}
+	})
+}
+
+func testLoadClientEncryptionKeys(t *testing.T) {
+	testSetupTestConfig(t)
+	testConfig := config.GetConfigInstance()
+	t.Run("Load client encryption key", func(t *testing.T) {
+		resetKeyManager()
+		keyLoader := GetDefaultKeyLoaderInstance()
+
+		client, clientExists := testConfig.GetClient("bar")
+		if !clientExists {
+			t.Error("Client should exist")
+		}
+
+		encryptionKey, encryptionKeyExists := keyLoader.LoadEncryptionKey(client)
+		if !encryptionKeyExists {
+			t.Fatal("Encryption key should exist")
+		}
+
+		if encryptionKey.KeyEncryptionAlgorithm != jwa.ECDH_ES || encryptionKey.ContentEncryptionAlgorithm != jwa.A256GCM {
+			t.Errorf("unexpected encryption algorithms %s and %s", encryptionKey.KeyEncryptionAlgorithm, encryptionKey.ContentEncryptionAlgorithm)
+		}
+
+		otherClient, otherClientExists := testConfig.GetClient("foo")
+		if !otherClientExists {
+			t.Error("Client should exist")
+		}
+
+		_, encryptionKeyExists = keyLoader.LoadEncryptionKey(otherClient)
+		if encryptionKeyExists {
+			t.Error("Encryption key should not exist for client without encryption")
+		}
+	})
+}
+
 func testSetupTestConfig(t *testing.T) {
 	testConfig := &config.Config{
 		Server: config.Server{
@@ -110,6 +217,9 @@ func testSetupTestConfig(t *testing.T) {
 				ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
 				Redirects:    []string{"https://example.com/callback"},
 				PrivateKey:   "../../../.test_files/rsa256key.pem",
+				Encryption: config.ClientEncryption{
+					Key: "../../../.test_files/ecdsa256pub.pem",
+				},
 			},
 			{
 				Id:           "moo",
@@ -118,6 +228,15 @@ func testSetupTestConfig(t *testing.T) {
 				PrivateKey:   "../../../.test_files/ecdsa521key.pem",
 			},
 		},
+		Resources: []config.Resource{
+			{
+				Id:         "https://api.example.com",
+				PrivateKey: "../../../.test_files/ecdsa384key.pem",
+			},
+			{
+				Id: "https://other.example.com",
+			},
+		},
 	}
 
 	initializationError := config.Initialize(testConfig)
@@ -37,6 +37,24 @@ func (defaultKeyLoader *defaultKeyLoader) LoadKeys(client *config.Client) (*cryp
 	return key, true
 }
 
-func (defaultKeyLoader *defaultKeyLoader) GetServerKey() jwt.SignEncryptParseOption {
-	return defaultKeyLoader.keyFallback.GetServerKey()
+func (defaultKeyLoader *defaultKeyLoader) LoadResourceKeys(resource *config.Resource) (*crypto.ManagedKey, bool) {
+	key := defaultKeyLoader.keyManager.getResourceKey(resource)
+	if key == nil {
+		return nil, false
+	}
+
+	return key, true
+}
+
+func (defaultKeyLoader *defaultKeyLoader) LoadEncryptionKey(client *config.Client) (*crypto.EncryptionKey, bool) {
+	key := defaultKeyLoader.keyManager.getClientEncryptionKey(client)
+	if key == nil {
+		return nil, false
+	}
+
+	return key, true
+}
+
+func (defaultKeyLoader *defaultKeyLoader) GetServerKey(suboptions ...jwt.Option) jwt.SignEncryptParseOption {
+	return defaultKeyLoader.keyFallback.GetServerKey(suboptions...)
 }
@@ -0,0 +1,303 @@
+package requestobject
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/manager/key"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/internal/oidc"
+	"github.com/webishdev/stopnik/log"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// contentType is the media type of request objects, see https://datatracker.ietf.org/doc/html/rfc9101#section-10.2
+const contentType = "application/oauth-authz-req+jwt"
+
+// acceptableSkew is the allowed clock difference between STOPnik and a client.
+const acceptableSkew = time.Minute
+
+// fetchTimeout and fetchLimit restrict requests to a request_uri.
+const (
+	fetchTimeout = 5 * time.Second
+	fetchLimit   = 64 * 1024
+)
+
+var (
+	ErrInvalidRequestObject = errors.New("invalid request object")
+	ErrInvalidRequestUri    = errors.New("invalid request_uri")
+)
+
+// SigningAlgorithms contains the algorithms supported for request objects,
+// none is only accepted from clients explicitly allowing unsigned request objects.
+var SigningAlgorithms = []jwa.SignatureAlgorithm{
+	jwa.RS256, jwa.RS384, jwa.RS512,
+	jwa.PS256, jwa.PS384, jwa.PS512,
+	jwa.ES256, jwa.ES384, jwa.ES512,
+	jwa.NoSignature,
+}
+
+// registeredClaims are JWT claims which are no authorization request parameters.
+var registeredClaims = []string{
+	jwt.IssuerKey, jwt.AudienceKey, jwt.ExpirationKey, jwt.NotBeforeKey, jwt.IssuedAtKey, jwt.JwtIDKey,
+}
+
+type Manager struct {
+	encryptionKey *crypto.ManagedKey
+	keys          map[string]interface{}
+	httpClient    *http.Client
+	mux           *sync.Mutex
+}
+
+var requestObjectManagerLock = &sync.Mutex{}
+var requestObjectManagerSingleton *Manager
+
+func GetRequestObjectManagerInstance() *Manager {
+	requestObjectManagerLock.Lock()
+	defer requestObjectManagerLock.Unlock()
+	if requestObjectManagerSingleton == nil {
+		encryptionKey, _ := key.GetKeyMangerInstance().GetEncryptionKey()
+		requestObjectManagerSingleton = newRequestObjectManager(encryptionKey)
+	}
+	return requestObjectManagerSingleton
+}
+
+func newRequestObjectManager(encryptionKey *crypto.ManagedKey) *Manager {
+	return &Manager{
+		encryptionKey: encryptionKey,
+		keys:          make(map[string]interface{}),
+		httpClient:    newFetchClient(),
+		mux:           &sync.Mutex{},
+	}
+}
+
+// newFetchClient creates the client for requests to a request_uri, which does not follow redirects,
+// because the request object must be provided by the registered request_uri itself and not by a redirect target.
+func newFetchClient() *http.Client {
+	return &http.Client{
+		Timeout: fetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Parse validates a request object of a client and returns the contained authorization request parameters.
+// Encrypted request objects are decrypted with the server encryption key first.
+// Implements https://datatracker.ietf.org/doc/html/rfc9101#section-6
+func (requestObjectManager *Manager) Parse(client *config.Client, requestObject string, issuer string) (url.Values, error) {
+	payload := []byte(requestObject)
+
+	if strings.Count(requestObject, ".") == 4 {
+		decrypted, decryptError := requestObjectManager.decrypt(payload)
+		if decryptError != nil {
+			log.Debug("Could not decrypt request object", log.ClientId(client.Id), log.Err(decryptError))
+			return nil, ErrInvalidRequestObject
+		}
+		payload = decrypted
+	}
+
+	message, parseError := jws.Parse(payload)
+	if parseError != nil || len(message.Signatures()) != 1 {
+		log.Debug("Invalid request object", log.ClientId(client.Id), log.Err(parseError))
+		return nil, ErrInvalidRequestObject
+	}
+
+	signatureAlgorithm := message.Signatures()[0].ProtectedHeaders().Algorithm()
+	if !slices.Contains(SigningAlgorithms, signatureAlgorithm) {
+		log.Debug("Unsupported request object algorithm", "alg", signatureAlgorithm)
+		return nil, ErrInvalidRequestObject
+	}
+
+	var token jwt.Token
+	var verifyError error
+	if signatureAlgorithm == jwa.NoSignature {
+		if !client.AllowUnsignedRequestObject {
+			log.Debug("Client does not allow unsigned request objects", log.ClientId(client.Id))
+			return nil, ErrInvalidRequestObject
+		}
+		token, verifyError = jwt.Parse(payload, jwt.WithVerify(false), jwt.WithAcceptableSkew(acceptableSkew))
+	} else {
+		publicKey, publicKeyExists := requestObjectManager.getPublicKey(client)
+		if !publicKeyExists {
+			return nil, ErrInvalidRequestObject
+		}
+		token, verifyError = jwt.Parse(payload, jwt.WithKey(signatureAlgorithm, publicKey), jwt.WithAcceptableSkew(acceptableSkew))
+	}
+	if verifyError != nil {
+		log.Debug("Invalid request object", log.ClientId(client.Id), log.Err(verifyError))
+		return nil, ErrInvalidRequestObject
+	}
+
+	if token.Issuer() != "" && token.Issuer() != client.Id {
+		log.Debug("Invalid request object issuer", "issuer", token.Issuer())
+		return nil, ErrInvalidRequestObject
+	}
+
+	if len(token.Audience()) > 0 && !slices.Contains(token.Audience(), issuer) {
+		log.Debug("Invalid request object audience", "audience", token.Audience())
+		return nil, ErrInvalidRequestObject
+	}
+
+	values, convertError := toValues(token)
+	if convertError != nil {
+		log.Debug("Could not convert request object claims", log.Err(convertError))
+		return nil, ErrInvalidRequestObject
+	}
+
+	if values.Has(oidc.ParameterRequest) || values.Has(oidc.ParameterRequestUri) {
+		log.Debug("Request object must not contain request or request_uri")
+		return nil, ErrInvalidRequestObject
+	}
+
+	if values.Has(oauth2.ParameterClientId) && values.Get(oauth2.ParameterClientId) != client.Id {
+		log.Debug("Request object client_id does not match client", "request_client_id", values.Get(oauth2.ParameterClientId), log.ClientId(client.Id))
+		return nil, ErrInvalidRequestObject
+	}
+
+	return values, nil
+}
+
+// Fetch loads a request object from a request_uri registered for the client.
+// Implements https://datatracker.ietf.org/doc/html/rfc9101#section-5.2.3
+func (requestObjectManager *Manager) Fetch(client *config.Client, requestUri string) (string, error) {
+	if !client.ValidateRequestUri(requestUri) {
+		log.Debug("request_uri is not registered for client", "request_uri", requestUri, log.ClientId(client.Id))
+		return "", ErrInvalidRequestUri
+	}
+
+	request, requestError := http.NewRequest(http.MethodGet, requestUri, nil)
+	if requestError != nil {
+		log.Debug("Invalid request_uri", "request_uri", requestUri, log.Err(requestError))
+		return "", ErrInvalidRequestUri
+	}
+	request.Header.Set(internalHttp.Accept, contentType)
+
+	response, responseError := requestObjectManager.httpClient.Do(request)
+	if responseError != nil {
+		log.Debug("Could not fetch request_uri", "request_uri", requestUri, log.Err(responseError))
+		return "", ErrInvalidRequestUri
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		log.Debug("Fetching request_uri returned unexpected status", "request_uri", requestUri, "status", response.StatusCode)
+		return "", ErrInvalidRequestUri
+	}
+
+	body, readError := io.ReadAll(io.LimitReader(response.Body, fetchLimit))
+	if readError != nil {
+		log.Debug("Could not read request_uri", "request_uri", requestUri, log.Err(readError))
+		return "", ErrInvalidRequestUri
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+// getPublicKey loads the request object key of a client once and keeps it by filename.
+func (requestObjectManager *Manager) getPublicKey(client *config.Client) (interface{}, bool) {
+	if client.RequestObjectKey == "" {
+		log.Debug("No request object key for client", log.ClientId(client.Id))
+		return nil, false
+	}
+
+	requestObjectManager.mux.Lock()
+	defer requestObjectManager.mux.Unlock()
+	publicKey, publicKeyExists := requestObjectManager.keys[client.RequestObjectKey]
+	if publicKeyExists {
+		return publicKey, true
+	}
+
+	publicKey, loadError := crypto.LoadPublicKey(client.RequestObjectKey)
+	if loadError != nil {
+		log.Error("Could not load request object key", log.ClientId(client.Id), log.Err(loadError))
+		return nil, false
+	}
+	requestObjectManager.keys[client.RequestObjectKey] = publicKey
+	return publicKey, true
+}
+
+func (requestObjectManager *Manager) decrypt(payload []byte) ([]byte, error) {
+	if requestObjectManager.encryptionKey == nil {
+		return nil, errors.New("no encryption key configured")
+	}
+
+	message, parseError := jwe.Parse(payload)
+	if parseError != nil {
+		return nil, parseError
+	}
+
+	keyEncryptionAlgorithm := message.ProtectedHeaders().Algorithm()
+	contentEncryptionAlgorithm := message.ProtectedHeaders().ContentEncryption()
+	if !slices.Contains(crypto.KeyEncryptionAlgorithms, keyEncryptionAlgorithm) || !slices.Contains(crypto.ContentEncryptionAlgorithms, contentEncryptionAlgorithm) {
+		return nil, fmt.Errorf("unsupported encryption %s %s", keyEncryptionAlgorithm, contentEncryptionAlgorithm)
+	}
+
+	return jwe.Decrypt(payload, jwe.WithKey(keyEncryptionAlgorithm, *requestObjectManager.encryptionKey.Key))
+}
+
+// toValues converts the claims of a request object into authorization request parameters,
+// JSON objects like claims or authorization_details are passed on as JSON strings.
+func toValues(token jwt.Token) (url.Values, error) {
+	claims, claimsError := token.AsMap(context.Background())
+	if claimsError != nil {
+		return nil, claimsError
+	}
+
+	values := url.Values{}
+	for name, claim := range claims {
+		if slices.Contains(registeredClaims, name) {
+			continue
+		}
+		switch value := claim.(type) {
+		case string:
+			values.Set(name, value)
+		case float64:
+			values.Set(name, strconv.FormatFloat(value, 'f', -1, 64))
+		case bool:
+			values.Set(name, strconv.FormatBool(value))
+		default:
+			if arrayValue, isArray := value.([]interface{}); isArray && name != oauth2.ParameterAuthorizationDetails {
+				if stringValues, isStringArray := toStrings(arrayValue); isStringArray {
+					values[name] = stringValues
+					continue
+				}
+			}
+			jsonValue, marshalError := json.Marshal(value)
+			if marshalError != nil {
+				return nil, marshalError
+			}
+			values.Set(name, string(jsonValue))
+		}
+	}
+	return values, nil
+}
+
+func toStrings(values []interface{}) ([]string, bool) {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		stringValue, isString := value.(string)
+		if !isString {
+			return nil, false
+		}
+		result = append(result, stringValue)
+	}
+	return result, true
+}
@@ -0,0 +1,256 @@
+package requestobject
+
+import (
+	"errors"
+	"fmt"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testIssuer = "http://localhost:8082"
+
+type testRequestObject struct {
+	keyFile  string
+	issuer   string
+	audience string
+	clientId string
+	claims   map[string]interface{}
+	expires  time.Time
+}
+
+func Test_ParseRequestObject(t *testing.T) {
+	client := &config.Client{Id: "foo", RequestObjectKey: "../../../.test_files/rsa256pub.pem"}
+	signedClient := &config.Client{Id: "foo", RequestObjectKey: "../../../.test_files/rsa256pub.pem", RequireSignedRequestObject: true}
+	unsignedClient := &config.Client{Id: "foo", RequestObjectKey: "../../../.test_files/rsa256pub.pem", AllowUnsignedRequestObject: true}
+
+	requestObjectManager := newRequestObjectManager(nil)
+
+	type parseParameter struct {
+		name          string
+		client        *config.Client
+		requestObject testRequestObject
+		valid         bool
+	}
+
+	var parseParameters = []parseParameter{
+		{"signed", client, testRequestObject{"rsa256key.pem", "foo", testIssuer, "foo", nil, time.Now().Add(time.Minute)}, true},
+		{"signed without optional claims", client, testRequestObject{"rsa256key.pem", "", "", "", nil, time.Now().Add(time.Minute)}, true},
+		{"unsigned", unsignedClient, testRequestObject{"", "foo", testIssuer, "foo", nil, time.Now().Add(time.Minute)}, true},
+		{"unsigned but not allowed", client, testRequestObject{"", "foo", testIssuer, "foo", nil, time.Now().Add(time.Minute)}, false},
+		{"unsigned but signing required", signedClient, testRequestObject{"", "foo", testIssuer, "foo", nil, time.Now().Add(time.Minute)}, false},
+		{"wrong key", client, testRequestObject{"ecdsa256key.pem", "foo", testIssuer, "foo", nil, time.Now().Add(time.Minute)}, false},
+		{"no client key", &config.Client{Id: "foo"}, testRequestObject{"rsa256key.pem", "foo", testIssuer, "foo", nil, time.Now().Add(time.Minute)}, false},
+		{"wrong issuer", client, testRequestObject{"rsa256key.pem", "bar", testIssuer, "foo", nil, time.Now().Add(time.Minute)}, false},
+		{"wrong audience", client, testRequestObject{"rsa256key.pem", "foo", "http://example.com", "foo", nil, time.Now().Add(time.Minute)}, false},
+		{"wrong client_id", client, testRequestObject{"rsa256key.pem", "foo", testIssuer, "bar", nil, time.Now().Add(time.Minute)}, false},
+		{"expired", client, testRequestObject{"rsa256key.pem", "foo", testIssuer, "foo", nil, time.Now().Add(-time.Hour)}, false},
+		{"nested request", client, testRequestObject{"rsa256key.pem", "foo", testIssuer, "foo", map[string]interface{}{"request": "abc"}, time.Now().Add(time.Minute)}, false},
+		{"nested request_uri", client, testRequestObject{"rsa256key.pem", "foo", testIssuer, "foo", map[string]interface{}{"request_uri": "http://example.com"}, time.Now().Add(time.Minute)}, false},
+	}
+
+	for _, test := range parseParameters {
+		testMessage := fmt.Sprintf("Parse request object %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			requestObject := testCreateRequestObject(t, test.requestObject)
+
+			values, parseError := requestObjectManager.Parse(test.client, requestObject, testIssuer)
+
+			if test.valid && parseError != nil {
+				t.Errorf("expected valid request object, got %v", parseError)
+			}
+			if !test.valid && !errors.Is(parseError, ErrInvalidRequestObject) {
+				t.Errorf("expected invalid request object, got %v", parseError)
+			}
+			if test.valid && values.Get("redirect_uri") != "https://example.com/callback" {
+				t.Errorf("expected redirect_uri from request object, got %v", values)
+			}
+		})
+	}
+}
+
+func Test_ParseRequestObjectValues(t *testing.T) {
+	client := &config.Client{Id: "foo", RequestObjectKey: "../../../.test_files/rsa256pub.pem"}
+
+	requestObjectManager := newRequestObjectManager(nil)
+
+	claims := map[string]interface{}{
+		"max_age":  300,
+		"resource": []string{"https://a.example.com", "https://b.example.com"},
+		"claims":   map[string]interface{}{"userinfo": map[string]interface{}{"email": nil}},
+	}
+	requestObject := testCreateRequestObject(t, testRequestObject{"rsa256key.pem", "foo", testIssuer, "foo", claims, time.Now().Add(time.Minute)})
+
+	values, parseError := requestObjectManager.Parse(client, requestObject, testIssuer)
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+
+	if values.Get("max_age") != "300" {
+		t.Errorf("expected max_age 300, got %s", values.Get("max_age"))
+	}
+
+	if !reflect.DeepEqual(values["resource"], []string{"https://a.example.com", "https://b.example.com"}) {
+		t.Errorf("expected two resources, got %v", values["resource"])
+	}
+
+	if values.Get("claims") != `{"userinfo":{"email":null}}` {
+		t.Errorf("expected claims as JSON, got %s", values.Get("claims"))
+	}
+
+	if values.Has("exp") || values.Has("iss") || values.Has("aud") {
+		t.Errorf("expected no registered claims, got %v", values)
+	}
+}
+
+func Test_ParseEncryptedRequestObject(t *testing.T) {
+	client := &config.Client{Id: "foo", RequestObjectKey: "../../../.test_files/rsa256pub.pem"}
+
+	encryptionPrivateKey, loadError := crypto.LoadPrivateKey("../../../.test_files/rsa256key.pem")
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+	encryptionJwk, jwkError := jwk.FromRaw(encryptionPrivateKey.PrivateKey)
+	if jwkError != nil {
+		t.Fatal(jwkError)
+	}
+	encryptionPublicJwk, jwkError := jwk.PublicKeyOf(encryptionJwk)
+	if jwkError != nil {
+		t.Fatal(jwkError)
+	}
+
+	requestObject := testCreateRequestObject(t, testRequestObject{"rsa256key.pem", "foo", testIssuer, "foo", nil, time.Now().Add(time.Minute)})
+	encrypted, encryptError := jwe.Encrypt([]byte(requestObject), jwe.WithKey(jwa.RSA_OAEP_256, encryptionPublicJwk), jwe.WithContentEncryption(jwa.A256GCM))
+	if encryptError != nil {
+		t.Fatal(encryptError)
+	}
+
+	t.Run("Parse encrypted request object", func(t *testing.T) {
+		requestObjectManager := newRequestObjectManager(&crypto.ManagedKey{Key: &encryptionJwk, Encryption: true})
+
+		values, parseError := requestObjectManager.Parse(client, string(encrypted), testIssuer)
+		if parseError != nil {
+			t.Fatal(parseError)
+		}
+		if values.Get("redirect_uri") != "https://example.com/callback" {
+			t.Errorf("expected redirect_uri from request object, got %v", values)
+		}
+	})
+
+	t.Run("Parse encrypted request object without encryption key", func(t *testing.T) {
+		requestObjectManager := newRequestObjectManager(nil)
+
+		_, parseError := requestObjectManager.Parse(client, string(encrypted), testIssuer)
+		if !errors.Is(parseError, ErrInvalidRequestObject) {
+			t.Errorf("expected invalid request object, got %v", parseError)
+		}
+	})
+}
+
+func Test_FetchRequestObject(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/request/redirect" {
+			http.Redirect(w, r, "/request/abc", http.StatusFound)
+			return
+		}
+		if r.URL.Path != "/request/abc" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Accept") != contentType {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write([]byte("header.payload.signature\n"))
+	}))
+	defer server.Close()
+
+	client := &config.Client{Id: "foo", RequestUris: []string{server.URL + "/request/"}}
+
+	requestObjectManager := newRequestObjectManager(nil)
+
+	type fetchParameter struct {
+		requestUri    string
+		requestObject string
+		valid         bool
+	}
+
+	var fetchParameters = []fetchParameter{
+		{server.URL + "/request/abc", "header.payload.signature", true},
+		{server.URL + "/request/other", "", false},
+		{server.URL + "/request/redirect", "", false},
+		{server.URL + "/other/abc", "", false},
+		{"http://example.com/request/abc", "", false},
+	}
+
+	for _, test := range fetchParameters {
+		testMessage := fmt.Sprintf("Fetch request object from %s", test.requestUri)
+		t.Run(testMessage, func(t *testing.T) {
+			requestObject, fetchError := requestObjectManager.Fetch(client, test.requestUri)
+
+			if test.valid && fetchError != nil {
+				t.Errorf("expected request object, got %v", fetchError)
+			}
+			if !test.valid && !errors.Is(fetchError, ErrInvalidRequestUri) {
+				t.Errorf("expected invalid request_uri, got %v", fetchError)
+			}
+			if requestObject != test.requestObject {
+				t.Errorf("expected request object %s, got %s", test.requestObject, requestObject)
+			}
+		})
+	}
+}
+
+func testCreateRequestObject(t *testing.T, requestObject testRequestObject) string {
+	builder := jwt.NewBuilder().
+		Expiration(requestObject.expires).
+		Claim("response_type", "code").
+		Claim("redirect_uri", "https://example.com/callback").
+		Claim("scope", "openid")
+	if requestObject.issuer != "" {
+		builder.Issuer(requestObject.issuer)
+	}
+	if requestObject.audience != "" {
+		builder.Audience([]string{requestObject.audience})
+	}
+	if requestObject.clientId != "" {
+		builder.Claim("client_id", requestObject.clientId)
+	}
+	for name, value := range requestObject.claims {
+		builder.Claim(name, value)
+	}
+
+	token, buildError := builder.Build()
+	if buildError != nil {
+		t.Fatal(buildError)
+	}
+
+	if requestObject.keyFile == "" {
+		unsignedToken, signError := jwt.Sign(token, jwt.WithInsecureNoSignature())
+		if signError != nil {
+			t.Fatal(signError)
+		}
+		return string(unsignedToken)
+	}
+
+	signingPrivateKey, loadError := crypto.LoadPrivateKey("../../../.test_files/" + requestObject.keyFile)
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+
+	signedToken, signError := jwt.Sign(token, jwt.WithKey(signingPrivateKey.SignatureAlgorithm, signingPrivateKey.PrivateKey))
+	if signError != nil {
+		t.Fatal(signError)
+	}
+
+	return string(signedToken)
+}
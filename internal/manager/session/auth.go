@@ -1,28 +1,42 @@
 package session
 
 import (
+	"context"
 	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/metrics"
 	"github.com/webishdev/stopnik/internal/oauth2"
 	"github.com/webishdev/stopnik/internal/oidc"
 	"github.com/webishdev/stopnik/internal/store"
+	"github.com/webishdev/stopnik/internal/tracing"
 	"sync"
 	"time"
 )
 
 type AuthSession struct {
-	Id                  string
-	Redirect            string
-	AuthURI             string
-	CodeChallenge       string
-	CodeChallengeMethod string
-	ResponseTypes       []oauth2.ResponseType
-	Username            string
-	ClientId            string
-	Scopes              []string
-	State               string
-	Nonce               string // OpenId Connect
-	RequestedClaims     *oidc.ClaimsParameter
-	AuthTime            time.Time
+	Id                   string
+	Redirect             string
+	AuthURI              string
+	CodeChallenge        string
+	CodeChallengeMethod  string
+	ResponseTypes        []oauth2.ResponseType
+	ResponseMode         oauth2.ResponseMode
+	Username             string
+	ClientId             string
+	Scopes               []string
+	State                string
+	Nonce                string           // OpenId Connect
+	LoginHint            string           // OpenId Connect
+	OfflineAccess        bool             // OpenId Connect
+	OfflineConsent       bool             // OpenId Connect
+	Prompt               *oidc.PromptType // OpenId Connect
+	MaxAge               *int             // OpenId Connect
+	RequestedClaims      *oidc.ClaimsParameter
+	AuthTime             time.Time
+	RequiredAcr          string
+	Acr                  string
+	Amr                  []string
+	Resources            []string
+	AuthorizationDetails []oauth2.AuthorizationDetail
 }
 
 type AuthManager struct {
@@ -43,21 +57,30 @@ func GetAuthSessionManagerInstance() Manager[AuthSession] {
 			config:           currentConfig,
 			authSessionStore: &authSessionStore,
 		}
+		metrics.StoreSize.SetFunc(func() float64 {
+			return float64(authSessionStore.Size())
+		}, "auth_session")
 	}
 	return authSessionManagerSingleton
 }
 
-func (authManager *AuthManager) StartSession(authSession *AuthSession) {
+func (authManager *AuthManager) StartSession(ctx context.Context, authSession *AuthSession) {
+	_, span := tracing.Start(ctx, "session.AuthManager.StartSession")
+	defer span.End()
 	authSessionStore := *authManager.authSessionStore
 	authSessionStore.Set(authSession.Id, authSession)
 }
 
-func (authManager *AuthManager) GetSession(id string) (*AuthSession, bool) {
+func (authManager *AuthManager) GetSession(ctx context.Context, id string) (*AuthSession, bool) {
+	_, span := tracing.Start(ctx, "session.AuthManager.GetSession")
+	defer span.End()
 	authSessionStore := *authManager.authSessionStore
 	return authSessionStore.Get(id)
 }
 
-func (authManager *AuthManager) DeleteSession(id string) {
+func (authManager *AuthManager) DeleteSession(ctx context.Context, id string) {
+	_, span := tracing.Start(ctx, "session.AuthManager.DeleteSession")
+	defer span.End()
 	authSessionStore := *authManager.authSessionStore
 	authSessionStore.Delete(id)
 }
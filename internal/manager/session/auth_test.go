@@ -1,9 +1,15 @@
 package session
 
 import (
+	"bytes"
+	"context"
 	"github.com/webishdev/stopnik/internal/config"
 	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/internal/tracing"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -29,9 +35,9 @@ func Test_AuthSession(t *testing.T) {
 			Scopes:              []string{"abc", "def"},
 			State:               "789",
 		}
-		sessionManager.StartSession(authSession)
+		sessionManager.StartSession(context.Background(), authSession)
 
-		session, sessionExits := sessionManager.GetSession("foo")
+		session, sessionExits := sessionManager.GetSession(context.Background(), "foo")
 
 		if !sessionExits {
 			t.Errorf("expected auth session to exists")
@@ -49,12 +55,37 @@ func Test_AuthSession(t *testing.T) {
 			Id:       "foo",
 			Redirect: "bar",
 		}
-		sessionManager.StartSession(authSession)
+		sessionManager.StartSession(context.Background(), authSession)
 
-		_, sessionExits := sessionManager.GetSession("bar")
+		_, sessionExits := sessionManager.GetSession(context.Background(), "bar")
 
 		if sessionExits {
 			t.Errorf("expected auth session not to exists")
 		}
 	})
+
+	t.Run("Auth session spans", func(t *testing.T) {
+		var buf bytes.Buffer
+		tracing.Configure("stopnik", tracing.NewWriterExporter(&buf))
+		defer tracing.Shutdown()
+
+		sessionManager := GetAuthSessionManagerInstance()
+
+		request := httptest.NewRequest(http.MethodGet, "/authorize", nil)
+		ctx, serverSpan := tracing.StartServerSpan(request)
+		sessionManager.StartSession(ctx, &AuthSession{Id: "foo"})
+		sessionManager.GetSession(ctx, "foo")
+		sessionManager.DeleteSession(ctx, "foo")
+		serverSpan.End()
+
+		traceId := serverSpan.SpanContext().TraceId.String()
+		for _, name := range []string{"session.AuthManager.StartSession", "session.AuthManager.GetSession", "session.AuthManager.DeleteSession"} {
+			if !strings.Contains(buf.String(), `"name":"`+name+`"`) {
+				t.Errorf("expected span %s", name)
+			}
+		}
+		if strings.Count(buf.String(), traceId) != 4 {
+			t.Errorf("expected session spans in trace %s, got %s", traceId, buf.String())
+		}
+	})
 }
@@ -1,8 +1,11 @@
 package session
 
 import (
+	"context"
 	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/metrics"
 	"github.com/webishdev/stopnik/internal/store"
+	"github.com/webishdev/stopnik/internal/tracing"
 	"sync"
 )
 
@@ -31,21 +34,30 @@ func GetForwardSessionManagerInstance() Manager[ForwardSession] {
 			config:              currentConfig,
 			forwardSessionStore: &forwardSessionStore,
 		}
+		metrics.StoreSize.SetFunc(func() float64 {
+			return float64(forwardSessionStore.Size())
+		}, "forward_session")
 	}
 	return forwardSessionManagerSingleton
 }
 
-func (forwardManager *ForwardManager) StartSession(forwardSession *ForwardSession) {
+func (forwardManager *ForwardManager) StartSession(ctx context.Context, forwardSession *ForwardSession) {
+	_, span := tracing.Start(ctx, "session.ForwardManager.StartSession")
+	defer span.End()
 	authSessionStore := *forwardManager.forwardSessionStore
 	authSessionStore.Set(forwardSession.Id, forwardSession)
 }
 
-func (forwardManager *ForwardManager) GetSession(id string) (*ForwardSession, bool) {
+func (forwardManager *ForwardManager) GetSession(ctx context.Context, id string) (*ForwardSession, bool) {
+	_, span := tracing.Start(ctx, "session.ForwardManager.GetSession")
+	defer span.End()
 	forwardSessionStore := *forwardManager.forwardSessionStore
 	return forwardSessionStore.Get(id)
 }
 
-func (forwardManager *ForwardManager) DeleteSession(id string) {
+func (forwardManager *ForwardManager) DeleteSession(ctx context.Context, id string) {
+	_, span := tracing.Start(ctx, "session.ForwardManager.DeleteSession")
+	defer span.End()
 	forwardSessionStore := *forwardManager.forwardSessionStore
 	forwardSessionStore.Delete(id)
 }
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"context"
 	"github.com/webishdev/stopnik/internal/config"
 	"reflect"
 	"testing"
@@ -19,9 +20,9 @@ func Test_ForwardSession(t *testing.T) {
 		forwardSession := &ForwardSession{
 			Id: "foo",
 		}
-		sessionManager.StartSession(forwardSession)
+		sessionManager.StartSession(context.Background(), forwardSession)
 
-		session, sessionExits := sessionManager.GetSession("foo")
+		session, sessionExits := sessionManager.GetSession(context.Background(), "foo")
 
 		if !sessionExits {
 			t.Errorf("expected forward session to exists")
@@ -38,9 +39,9 @@ func Test_ForwardSession(t *testing.T) {
 		forwardSession := &ForwardSession{
 			Id: "foo",
 		}
-		sessionManager.StartSession(forwardSession)
+		sessionManager.StartSession(context.Background(), forwardSession)
 
-		_, sessionExits := sessionManager.GetSession("bar")
+		_, sessionExits := sessionManager.GetSession(context.Background(), "bar")
 
 		if sessionExits {
 			t.Errorf("expected forward session not to exists")
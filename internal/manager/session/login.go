@@ -1,8 +1,11 @@
 package session
 
 import (
+	"context"
 	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/metrics"
 	"github.com/webishdev/stopnik/internal/store"
+	"github.com/webishdev/stopnik/internal/tracing"
 	"github.com/webishdev/stopnik/log"
 	"sync"
 	"time"
@@ -12,6 +15,8 @@ type LoginSession struct {
 	Id        string
 	Username  string
 	StartTime time.Time
+	Acr       string
+	Amr       []string
 }
 
 type loginManager struct {
@@ -21,8 +26,8 @@ type loginManager struct {
 
 type LoginManager[T LoginSession] interface {
 	Manager[T]
-	CloseSession(id string, all bool)
-	SearchSession(username string) ([]*T, bool)
+	CloseSession(ctx context.Context, id string, all bool)
+	SearchSession(ctx context.Context, username string) ([]*T, bool)
 }
 
 var loginSessionManagerLock = &sync.Mutex{}
@@ -39,31 +44,44 @@ func GetLoginSessionManagerInstance() LoginManager[LoginSession] {
 			config:            currentConfig,
 			loginSessionStore: &loginSessionStore,
 		}
+		loginSessionSize := func() float64 {
+			return float64(loginSessionStore.Size())
+		}
+		metrics.ActiveLoginSessions.SetFunc(loginSessionSize)
+		metrics.StoreSize.SetFunc(loginSessionSize, "login_session")
 	}
 	return loginSessionManagerSingleton
 }
 
-func (loginManager *loginManager) StartSession(loginSession *LoginSession) {
+func (loginManager *loginManager) StartSession(ctx context.Context, loginSession *LoginSession) {
+	_, span := tracing.Start(ctx, "session.LoginManager.StartSession")
+	defer span.End()
 	loginSessionStore := *loginManager.loginSessionStore
 	loginSession.StartTime = time.Now()
 	loginSessionStore.Set(loginSession.Id, loginSession)
 }
 
-func (loginManager *loginManager) GetSession(id string) (*LoginSession, bool) {
+func (loginManager *loginManager) GetSession(ctx context.Context, id string) (*LoginSession, bool) {
+	_, span := tracing.Start(ctx, "session.LoginManager.GetSession")
+	defer span.End()
 	loginSessionStore := *loginManager.loginSessionStore
 	return loginSessionStore.Get(id)
 }
 
-func (loginManager *loginManager) DeleteSession(id string) {
+func (loginManager *loginManager) DeleteSession(ctx context.Context, id string) {
+	_, span := tracing.Start(ctx, "session.LoginManager.DeleteSession")
+	defer span.End()
 	loginSessionStore := *loginManager.loginSessionStore
 	loginSessionStore.Delete(id)
 }
 
-func (loginManager *loginManager) CloseSession(id string, all bool) {
+func (loginManager *loginManager) CloseSession(ctx context.Context, id string, all bool) {
+	_, span := tracing.Start(ctx, "session.LoginManager.CloseSession")
+	defer span.End()
 	loginSessionStore := *loginManager.loginSessionStore
 	loginSession, loginSessionExists := loginSessionStore.Get(id)
 	if loginSessionExists {
-		log.Debug("Closing main login session with id %s", id)
+		log.DebugContext(ctx, "Closing main login session", "id", id)
 		loginSessionStore.Delete(id)
 		if all {
 			username := loginSession.Username
@@ -74,7 +92,7 @@ func (loginManager *loginManager) CloseSession(id string, all bool) {
 				}
 			}
 			for _, otherSessionId := range userSessionIds {
-				log.Debug("Closing login session with id %s", otherSessionId)
+				log.DebugContext(ctx, "Closing login session", "id", otherSessionId)
 				loginSessionStore.Delete(otherSessionId)
 			}
 		}
@@ -82,7 +100,9 @@ func (loginManager *loginManager) CloseSession(id string, all bool) {
 
 }
 
-func (loginManager *loginManager) SearchSession(username string) ([]*LoginSession, bool) {
+func (loginManager *loginManager) SearchSession(ctx context.Context, username string) ([]*LoginSession, bool) {
+	_, span := tracing.Start(ctx, "session.LoginManager.SearchSession")
+	defer span.End()
 	loginSessionStore := *loginManager.loginSessionStore
 	var userSessions []*LoginSession
 	exists := false
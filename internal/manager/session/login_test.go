@@ -1,6 +1,7 @@
 package session
 
 import (
+	"context"
 	"github.com/webishdev/stopnik/internal/config"
 	"reflect"
 	"testing"
@@ -19,9 +20,9 @@ func Test_LoginSession(t *testing.T) {
 		loginSession := &LoginSession{
 			Id: "foo",
 		}
-		sessionManager.StartSession(loginSession)
+		sessionManager.StartSession(context.Background(), loginSession)
 
-		session, sessionExits := sessionManager.GetSession("foo")
+		session, sessionExits := sessionManager.GetSession(context.Background(), "foo")
 
 		if !sessionExits {
 			t.Errorf("expected login session to exists")
@@ -38,9 +39,9 @@ func Test_LoginSession(t *testing.T) {
 		loginSession := &LoginSession{
 			Id: "foo",
 		}
-		sessionManager.StartSession(loginSession)
+		sessionManager.StartSession(context.Background(), loginSession)
 
-		_, sessionExits := sessionManager.GetSession("bar")
+		_, sessionExits := sessionManager.GetSession(context.Background(), "bar")
 
 		if sessionExits {
 			t.Errorf("expected login session not to exists")
@@ -54,35 +55,35 @@ func Test_LoginSession(t *testing.T) {
 			Id:       "foo",
 			Username: "foo",
 		}
-		sessionManager.StartSession(loginSessionFoo)
+		sessionManager.StartSession(context.Background(), loginSessionFoo)
 
 		loginSessionBar := &LoginSession{
 			Id:       "bar",
 			Username: "foo",
 		}
-		sessionManager.StartSession(loginSessionBar)
+		sessionManager.StartSession(context.Background(), loginSessionBar)
 
-		_, sessionExits := sessionManager.GetSession("foo")
+		_, sessionExits := sessionManager.GetSession(context.Background(), "foo")
 
 		if !sessionExits {
 			t.Errorf("expected login session to exists")
 		}
 
-		_, sessionExits = sessionManager.GetSession("bar")
+		_, sessionExits = sessionManager.GetSession(context.Background(), "bar")
 
 		if !sessionExits {
 			t.Errorf("expected login session to exists")
 		}
 
-		sessionManager.CloseSession("foo", true)
+		sessionManager.CloseSession(context.Background(), "foo", true)
 
-		_, sessionExits = sessionManager.GetSession("foo")
+		_, sessionExits = sessionManager.GetSession(context.Background(), "foo")
 
 		if sessionExits {
 			t.Errorf("expected login session not to exists")
 		}
 
-		_, sessionExits = sessionManager.GetSession("bar")
+		_, sessionExits = sessionManager.GetSession(context.Background(), "bar")
 
 		if sessionExits {
 			t.Errorf("expected login session not to exists")
@@ -96,21 +97,21 @@ func Test_LoginSession(t *testing.T) {
 			Id:       "foo",
 			Username: "foo",
 		}
-		sessionManager.StartSession(loginSessionFoo)
+		sessionManager.StartSession(context.Background(), loginSessionFoo)
 
 		loginSessionBar := &LoginSession{
 			Id:       "bar",
 			Username: "foo",
 		}
-		sessionManager.StartSession(loginSessionBar)
+		sessionManager.StartSession(context.Background(), loginSessionBar)
 
-		_, sessionExits := sessionManager.SearchSession("foo")
+		_, sessionExits := sessionManager.SearchSession(context.Background(), "foo")
 
 		if !sessionExits {
 			t.Errorf("expected login session to exists")
 		}
 
-		_, sessionExits = sessionManager.SearchSession("bar")
+		_, sessionExits = sessionManager.SearchSession(context.Background(), "bar")
 
 		if sessionExits {
 			t.Errorf("expected login session to not exists")
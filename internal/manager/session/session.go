@@ -1,7 +1,11 @@
 package session
 
+import (
+	"context"
+)
+
 type Manager[T any] interface {
-	StartSession(session *T)
-	GetSession(id string) (*T, bool)
-	DeleteSession(id string)
+	StartSession(ctx context.Context, session *T)
+	GetSession(ctx context.Context, id string) (*T, bool)
+	DeleteSession(ctx context.Context, id string)
 }
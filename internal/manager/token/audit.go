@@ -0,0 +1,89 @@
+package token
+
+import (
+	"github.com/webishdev/stopnik/internal/audit"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/internal/webhook"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Reasons recorded in the audit trail when tokens are revoked by STOPnik itself.
+const (
+	revokeReasonInvalidAuthorizationCode = "invalid_authorization_code"
+	revokeReasonRotated                  = "rotated"
+	revokeReasonConsentRevoked           = "consent_revoked"
+)
+
+// recordTokenIssued records an issued access token in the audit trail and notifies webhooks.
+func recordTokenIssued(r *http.Request, accessToken *oauth2.AccessToken, grantType oauth2.GrantType, scopes []string, details map[string]string) {
+	scope := strings.Join(scopes, " ")
+	details["grant_type"] = string(grantType)
+	details["scope"] = scope
+	if accessToken.Confirmation != nil && accessToken.Confirmation.JwkThumbprint != "" {
+		details["dpop_jkt"] = accessToken.Confirmation.JwkThumbprint
+	}
+	if accessToken.Confirmation != nil && accessToken.Confirmation.CertificateThumbprint != "" {
+		details["x5t#S256"] = accessToken.Confirmation.CertificateThumbprint
+	}
+	audit.Record(r, &audit.Event{
+		Type:     audit.EtTokenIssued,
+		Outcome:  audit.OcSuccess,
+		ClientId: accessToken.ClientId,
+		Username: accessToken.Username,
+		Details:  details,
+	})
+	webhook.Publish(r, webhook.EtTokenIssued, webhook.Data{ClientId: accessToken.ClientId, Username: accessToken.Username, GrantType: string(grantType), Scope: scope})
+}
+
+// getGrantDetails returns the audit details of the requested resources and authorization details of a grant.
+func getGrantDetails(grantInput *GrantInput) map[string]string {
+	details := map[string]string{}
+	if grantInput.AssertionIssuer != "" {
+		details["assertion_issuer"] = grantInput.AssertionIssuer
+	}
+	if len(grantInput.RequestedResources) > 0 {
+		details["resource"] = strings.Join(grantInput.RequestedResources, " ")
+	}
+	authorizationDetails := grantInput.RequestedAuthorizationDetails
+	if len(authorizationDetails) == 0 {
+		authorizationDetails = grantInput.AuthorizationDetails
+	}
+	if len(authorizationDetails) > 0 {
+		var authorizationDetailTypes []string
+		for _, authorizationDetail := range authorizationDetails {
+			authorizationDetailTypes = append(authorizationDetailTypes, authorizationDetail.GetType())
+		}
+		details["authorization_details"] = strings.Join(authorizationDetailTypes, " ")
+	}
+	return details
+}
+
+// recordTokenRefreshed records the use of a refresh token in the audit trail.
+func recordTokenRefreshed(r *http.Request, refreshToken *oauth2.RefreshToken, rotated bool) {
+	audit.Record(r, &audit.Event{
+		Type:     audit.EtTokenRefreshed,
+		Outcome:  audit.OcSuccess,
+		ClientId: refreshToken.ClientId,
+		Username: refreshToken.Username,
+		Details:  map[string]string{"rotated": strconv.FormatBool(rotated)},
+	})
+}
+
+// recordTokenRevoked records a revoked token in the audit trail and notifies webhooks.
+// The reason is empty when the revocation was requested by a client.
+func recordTokenRevoked(r *http.Request, tokenType oauth2.IntrospectTokenType, clientId string, username string, reason string) {
+	details := map[string]string{"token_type": string(tokenType)}
+	if reason != "" {
+		details["reason"] = reason
+	}
+	audit.Record(r, &audit.Event{
+		Type:     audit.EtTokenRevoked,
+		Outcome:  audit.OcSuccess,
+		ClientId: clientId,
+		Username: username,
+		Details:  details,
+	})
+	webhook.Publish(r, webhook.EtTokenRevoked, webhook.Data{ClientId: clientId, Username: username, TokenType: string(tokenType)})
+}
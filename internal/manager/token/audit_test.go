@@ -0,0 +1,74 @@
+package token
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/webishdev/stopnik/internal/audit"
+	"github.com/webishdev/stopnik/internal/endpoint"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_AuditEvents(t *testing.T) {
+	testConfig := createTestConfig(t, false, 100, 0, "")
+	tokenManager := GetTokenManagerInstance()
+	client, clientExists := testConfig.GetClient("foo")
+	if !clientExists {
+		t.Fatal("client does not exist")
+	}
+
+	var auditBuffer bytes.Buffer
+	audit.Configure(audit.NewWriterSink(&auditBuffer))
+	defer audit.Shutdown()
+
+	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+	accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{"abc"}, nil, "", "", &GrantInput{GrantType: oauth2.GtPassword})
+	refreshToken, refreshTokenExists := tokenManager.GetRefreshToken(context.Background(), accessTokenResponse.RefreshTokenValue)
+	if !refreshTokenExists {
+		t.Fatal("refresh token should exist")
+	}
+	refreshedResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, refreshToken.Scopes, nil, "", "", &GrantInput{GrantType: oauth2.GtRefreshToken, RefreshToken: refreshToken})
+	accessToken, accessTokenExists := tokenManager.GetAccessToken(context.Background(), refreshedResponse.AccessTokenValue)
+	if !accessTokenExists {
+		t.Fatal("access token should exist")
+	}
+	tokenManager.RevokeAccessToken(request, accessToken)
+
+	var events []audit.Event
+	scanner := bufio.NewScanner(&auditBuffer)
+	for scanner.Scan() {
+		event := audit.Event{}
+		unmarshalError := json.Unmarshal(scanner.Bytes(), &event)
+		if unmarshalError != nil {
+			t.Fatal(unmarshalError)
+		}
+		events = append(events, event)
+	}
+
+	expectedEvents := []struct {
+		eventType audit.EventType
+		detail    string
+		value     string
+	}{
+		{audit.EtTokenIssued, "grant_type", string(oauth2.GtPassword)},
+		{audit.EtTokenRevoked, "reason", "rotated"},
+		{audit.EtTokenRefreshed, "rotated", "true"},
+		{audit.EtTokenIssued, "grant_type", string(oauth2.GtRefreshToken)},
+		{audit.EtTokenRevoked, "token_type", string(oauth2.ItAccessToken)},
+	}
+
+	if len(events) != len(expectedEvents) {
+		t.Fatalf("expected %d audit events, got %v", len(expectedEvents), events)
+	}
+
+	for index, expected := range expectedEvents {
+		event := events[index]
+		if event.Type != expected.eventType || event.ClientId != "foo" || event.Username != "foo" || event.Details[expected.detail] != expected.value {
+			t.Errorf("audit event %d did not match, %v", index, event)
+		}
+	}
+}
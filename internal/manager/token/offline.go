@@ -0,0 +1,187 @@
+package token
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/internal/system"
+	"github.com/webishdev/stopnik/internal/tracing"
+	"github.com/webishdev/stopnik/log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// offlineTokensLock guards signaling and stopping the offline token writer.
+var offlineTokensLock = &sync.Mutex{}
+
+// getRefreshTokenExpiration returns the expiration of a new refresh token and the time of its original grant.
+// Refreshed tokens keep the expiration of the used refresh token, unless the client uses sliding expiration,
+// the maximum lifetime of the client is never exceeded.
+func getRefreshTokenExpiration(client *config.Client, now time.Time, usedRefreshToken *oauth2.RefreshToken) (time.Time, time.Time) {
+	grantedAt := now
+	expiresAt := now.Add(time.Minute * time.Duration(client.GetRefreshTTL()))
+	if usedRefreshToken != nil {
+		grantedAt = usedRefreshToken.GrantedAt
+		if grantedAt.IsZero() {
+			grantedAt = usedRefreshToken.IssuedAt
+		}
+		if !client.RefreshSliding {
+			expiresAt = usedRefreshToken.ExpiresAt
+		}
+	}
+
+	if client.GetRefreshMaxTTL() > 0 {
+		maxExpiresAt := grantedAt.Add(time.Minute * time.Duration(client.GetRefreshMaxTTL()))
+		if expiresAt.After(maxExpiresAt) {
+			expiresAt = maxExpiresAt
+		}
+	}
+
+	return expiresAt, grantedAt
+}
+
+// GetOfflineTokens returns the offline refresh tokens issued for the given user.
+func (tokenManager *Manager) GetOfflineTokens(username string) []*oauth2.RefreshToken {
+	return tokenManager.getOfflineTokens(func(refreshToken *oauth2.RefreshToken) bool {
+		return refreshToken.Username == username
+	})
+}
+
+// RevokeOfflineTokens revokes the offline access the given user granted to the client with the given id,
+// all offline refresh tokens of the user for that client are removed.
+func (tokenManager *Manager) RevokeOfflineTokens(r *http.Request, username string, clientId string) {
+	ctx, span := tracing.Start(r.Context(), "token.Manager.RevokeOfflineTokens")
+	defer span.End()
+	r = r.WithContext(ctx)
+	offlineTokens := tokenManager.getOfflineTokens(func(refreshToken *oauth2.RefreshToken) bool {
+		return refreshToken.Username == username && refreshToken.ClientId == clientId
+	})
+	for _, currentClientStores := range tokenManager.allClientStores() {
+		refreshTokenStore := *currentClientStores.refreshTokenStore
+		for _, refreshToken := range offlineTokens {
+			refreshTokenStore.Delete(refreshToken.Key)
+		}
+	}
+	tokenManager.persistOfflineTokens()
+	for _, refreshToken := range offlineTokens {
+		recordTokenRevoked(r, oauth2.ItRefreshToken, refreshToken.ClientId, refreshToken.Username, revokeReasonConsentRevoked)
+	}
+}
+
+func (tokenManager *Manager) getOfflineTokens(filter func(refreshToken *oauth2.RefreshToken) bool) []*oauth2.RefreshToken {
+	now := time.Now()
+	var result []*oauth2.RefreshToken
+	for _, currentClientStores := range tokenManager.allClientStores() {
+		refreshTokenStore := *currentClientStores.refreshTokenStore
+		for _, refreshToken := range refreshTokenStore.GetValues() {
+			if refreshToken.Offline && refreshToken.ExpiresAt.After(now) && filter(refreshToken) {
+				result = append(result, refreshToken)
+			}
+		}
+	}
+	return result
+}
+
+// loadOfflineTokens adds the refresh tokens of the offline token file to the client stores,
+// so offline access survives a restart. Expired tokens and tokens of unknown clients are skipped.
+func (tokenManager *Manager) loadOfflineTokens() error {
+	file := tokenManager.config.Server.OfflineTokenFile
+	if file == "" {
+		return nil
+	}
+
+	data, readError := os.ReadFile(file)
+	if errors.Is(readError, os.ErrNotExist) {
+		return nil
+	} else if readError != nil {
+		return readError
+	}
+
+	var refreshTokens []*oauth2.RefreshToken
+	unmarshalError := json.Unmarshal(data, &refreshTokens)
+	if unmarshalError != nil {
+		return unmarshalError
+	}
+
+	now := time.Now()
+	for _, refreshToken := range refreshTokens {
+		client, clientExists := tokenManager.config.GetClient(refreshToken.ClientId)
+		if clientExists && refreshToken.ExpiresAt.After(now) {
+			refreshTokenStore := *tokenManager.getClientStores(client).refreshTokenStore
+			refreshTokenStore.SetWithDuration(refreshToken.Key, refreshToken, refreshToken.ExpiresAt.Sub(now))
+		}
+	}
+
+	return nil
+}
+
+// startOfflineTokenWriter starts writing the offline token file in the background, when configured.
+// Issuing and revoking offline tokens only signals a change, changes during a write are combined into the next write.
+func (tokenManager *Manager) startOfflineTokenWriter() {
+	if tokenManager.config.Server.OfflineTokenFile == "" {
+		return
+	}
+
+	changed := make(chan struct{}, 1)
+	written := make(chan struct{})
+	tokenManager.offlineTokensChanged = changed
+	tokenManager.offlineTokensWritten = written
+	go func() {
+		defer close(written)
+		for range changed {
+			tokenManager.writeOfflineTokens()
+		}
+	}()
+}
+
+// stopOfflineTokenWriter stops the background writer after pending changes are written.
+func (tokenManager *Manager) stopOfflineTokenWriter() {
+	offlineTokensLock.Lock()
+	changed := tokenManager.offlineTokensChanged
+	tokenManager.offlineTokensChanged = nil
+	offlineTokensLock.Unlock()
+
+	if changed != nil {
+		close(changed)
+		<-tokenManager.offlineTokensWritten
+	}
+}
+
+// persistOfflineTokens signals the background writer that offline refresh tokens changed.
+func (tokenManager *Manager) persistOfflineTokens() {
+	offlineTokensLock.Lock()
+	defer offlineTokensLock.Unlock()
+
+	if tokenManager.offlineTokensChanged == nil {
+		return
+	}
+
+	select {
+	case tokenManager.offlineTokensChanged <- struct{}{}:
+	default:
+		// a write is already pending and will contain this change
+	}
+}
+
+// writeOfflineTokens writes all offline refresh tokens to the offline token file,
+// the file is replaced at once so a crash while writing does not leave a truncated file behind.
+func (tokenManager *Manager) writeOfflineTokens() {
+	file := tokenManager.config.Server.OfflineTokenFile
+
+	offlineTokens := tokenManager.getOfflineTokens(func(refreshToken *oauth2.RefreshToken) bool {
+		return true
+	})
+	data, marshalError := json.MarshalIndent(offlineTokens, "", "  ")
+	if marshalError != nil {
+		log.Error("Could not persist offline tokens", log.Err(marshalError))
+		return
+	}
+
+	writeError := system.WriteFile(file, data, 0o600)
+	if writeError != nil {
+		log.Error("Could not persist offline tokens", "file", file, log.Err(writeError))
+	}
+}
@@ -0,0 +1,168 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/endpoint"
+	"github.com/webishdev/stopnik/internal/manager/dpop"
+	"github.com/webishdev/stopnik/internal/manager/key"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/internal/oidc"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_RefreshTokenExpiration(t *testing.T) {
+	now := time.Now()
+	grantedAt := now.Add(-time.Hour)
+	usedRefreshToken := &oauth2.RefreshToken{IssuedAt: now.Add(-time.Minute), GrantedAt: grantedAt, ExpiresAt: now.Add(time.Minute * 5)}
+
+	type parameter struct {
+		name              string
+		client            config.Client
+		usedRefreshToken  *oauth2.RefreshToken
+		expectedExpiresAt time.Time
+		expectedGrantedAt time.Time
+	}
+
+	var parameters = []parameter{
+		{"new grant", config.Client{RefreshTTL: 10}, nil, now.Add(time.Minute * 10), now},
+		{"new grant with maximum", config.Client{RefreshTTL: 10, RefreshMaxTTL: 20}, nil, now.Add(time.Minute * 10), now},
+		{"fixed expiration", config.Client{RefreshTTL: 10}, usedRefreshToken, now.Add(time.Minute * 5), grantedAt},
+		{"sliding expiration", config.Client{RefreshTTL: 10, RefreshSliding: true}, usedRefreshToken, now.Add(time.Minute * 10), grantedAt},
+		{"sliding expiration with maximum", config.Client{RefreshTTL: 10, RefreshMaxTTL: 65, RefreshSliding: true}, usedRefreshToken, grantedAt.Add(time.Minute * 65), grantedAt},
+		{"without granted at", config.Client{RefreshTTL: 10, RefreshMaxTTL: 5, RefreshSliding: true}, &oauth2.RefreshToken{IssuedAt: now}, now.Add(time.Minute * 5), now},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Refresh token expiration, %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			expiresAt, grantedAt := getRefreshTokenExpiration(&test.client, now, test.usedRefreshToken)
+
+			if !expiresAt.Equal(test.expectedExpiresAt) {
+				t.Errorf("expected expiration %v, got %v", test.expectedExpiresAt, expiresAt)
+			}
+
+			if !grantedAt.Equal(test.expectedGrantedAt) {
+				t.Errorf("expected granted at %v, got %v", test.expectedGrantedAt, grantedAt)
+			}
+		})
+	}
+}
+
+func Test_RefreshTokenRotation(t *testing.T) {
+	testConfig := createTestConfig(t, false, 100, 100, "")
+	client, clientExists := testConfig.GetClient("foo")
+	if !clientExists {
+		t.Fatal("client does not exist")
+	}
+	client.RefreshSliding = false
+
+	tokenManager := testNewManager(testConfig)
+	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+
+	offlineResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{oidc.ScopeOpenId, oidc.ScopeOfflineAccess}, nil, "", "", &GrantInput{OfflineAccess: true})
+	offlineToken, offlineTokenExists := tokenManager.GetRefreshToken(context.Background(), offlineResponse.RefreshTokenValue)
+	if !offlineTokenExists {
+		t.Fatal("expected offline refresh token")
+	}
+
+	refreshedResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, offlineToken.Scopes, nil, "", "", &GrantInput{RefreshToken: offlineToken})
+	refreshedToken, refreshedTokenExists := tokenManager.GetRefreshToken(context.Background(), refreshedResponse.RefreshTokenValue)
+	if !refreshedTokenExists || !refreshedToken.ExpiresAt.Equal(offlineToken.ExpiresAt) {
+		t.Fatalf("expected rotated token with the same expiration, got %v", refreshedToken)
+	}
+
+	if _, usedTokenExists := tokenManager.GetRefreshToken(context.Background(), offlineResponse.RefreshTokenValue); usedTokenExists {
+		t.Error("expected used refresh token to be revoked without sliding expiration")
+	}
+}
+
+func Test_OfflineTokens(t *testing.T) {
+	testConfig := createTestConfig(t, false, 100, 100, "")
+	testConfig.Server.OfflineTokenFile = filepath.Join(t.TempDir(), "offline.json")
+	client, clientExists := testConfig.GetClient("foo")
+	if !clientExists {
+		t.Fatal("client does not exist")
+	}
+	client.RefreshSliding = true
+
+	tokenManager := testNewManager(testConfig)
+	tokenManager.startOfflineTokenWriter()
+	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+
+	onlineResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{oidc.ScopeOpenId}, nil, "", "", nil)
+	if onlineResponse.RefreshTokenValue != "" {
+		t.Error("expected no refresh token without offline access")
+	}
+
+	withoutConsentResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{oidc.ScopeOpenId, oidc.ScopeOfflineAccess}, nil, "", "", &GrantInput{GrantType: oauth2.GtPassword})
+	if withoutConsentResponse.RefreshTokenValue != "" || withoutConsentResponse.Scope != oidc.ScopeOpenId {
+		t.Errorf("expected offline access to be removed without consent, got %v", withoutConsentResponse)
+	}
+
+	offlineResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{oidc.ScopeOpenId, oidc.ScopeOfflineAccess}, nil, "", "", &GrantInput{OfflineAccess: true})
+	offlineToken, offlineTokenExists := tokenManager.GetRefreshToken(context.Background(), offlineResponse.RefreshTokenValue)
+	if !offlineTokenExists || !offlineToken.Offline {
+		t.Fatalf("expected offline refresh token, got %v", offlineToken)
+	}
+
+	refreshedResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, offlineToken.Scopes, nil, "", "", &GrantInput{RefreshToken: offlineToken})
+	refreshedToken, refreshedTokenExists := tokenManager.GetRefreshToken(context.Background(), refreshedResponse.RefreshTokenValue)
+	if !refreshedTokenExists || !refreshedToken.GrantedAt.Equal(offlineToken.GrantedAt) {
+		t.Fatalf("expected refreshed token of the same grant, got %v", refreshedToken)
+	}
+
+	if _, usedTokenExists := tokenManager.GetRefreshToken(context.Background(), offlineResponse.RefreshTokenValue); usedTokenExists {
+		t.Error("expected used refresh token to be replaced with sliding expiration")
+	}
+
+	if len(tokenManager.GetOfflineTokens("foo")) != 1 {
+		t.Errorf("expected one offline token, got %d", len(tokenManager.GetOfflineTokens("foo")))
+	}
+
+	tokenManager.stopOfflineTokenWriter()
+
+	restartedTokenManager := testNewManager(testConfig)
+	loadError := restartedTokenManager.loadOfflineTokens()
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+
+	if _, loadedTokenExists := restartedTokenManager.GetRefreshToken(context.Background(), refreshedResponse.RefreshTokenValue); !loadedTokenExists {
+		t.Fatal("expected offline token to be loaded from file")
+	}
+
+	restartedTokenManager.startOfflineTokenWriter()
+	restartedTokenManager.RevokeOfflineTokens(request, "foo", client.Id)
+	restartedTokenManager.stopOfflineTokenWriter()
+
+	if _, revokedTokenExists := restartedTokenManager.GetRefreshToken(context.Background(), refreshedResponse.RefreshTokenValue); revokedTokenExists {
+		t.Error("expected offline token to be revoked")
+	}
+
+	revokedTokenManager := testNewManager(testConfig)
+	loadError = revokedTokenManager.loadOfflineTokens()
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+
+	if len(revokedTokenManager.GetOfflineTokens("foo")) != 0 {
+		t.Error("expected revoked offline tokens to be removed from file")
+	}
+}
+
+func testNewManager(testConfig *config.Config) *Manager {
+	return &Manager{
+		config:          testConfig,
+		keyLoader:       key.GetDefaultKeyLoaderInstance(),
+		dpopManager:     dpop.GetDPoPManagerInstance(),
+		clientStores:    make(map[string]*clientStores),
+		clientStoresMux: &sync.RWMutex{},
+	}
+}
@@ -0,0 +1,74 @@
+package token
+
+import (
+	"crypto/rand"
+	"github.com/webishdev/stopnik/internal/crypto"
+	"github.com/webishdev/stopnik/internal/system"
+	"hash/crc32"
+	"math/big"
+	"strings"
+)
+
+// opaqueTokenAlphabet contains the base62 characters of opaque tokens, which never need to be encoded.
+const opaqueTokenAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const opaqueTokenRandomLength = 30
+
+// opaqueTokenChecksumLength is enough to encode a CRC32 checksum with base62.
+const opaqueTokenChecksumLength = 6
+
+// generateOpaqueToken returns a random token in the format <prefix><random><checksum>,
+// the prefix allows secret scanners to detect leaked tokens and
+// the CRC32 checksum of prefix and random part allows to reject mistyped tokens without a store lookup.
+func generateOpaqueToken(prefix string) string {
+	alphabetLength := big.NewInt(int64(len(opaqueTokenAlphabet)))
+	random := make([]byte, opaqueTokenRandomLength)
+	for index := range random {
+		number, randomError := rand.Int(rand.Reader, alphabetLength)
+		if randomError != nil {
+			system.Error(randomError)
+		}
+		random[index] = opaqueTokenAlphabet[number.Int64()]
+	}
+	value := prefix + string(random)
+	return value + encodeChecksum(value)
+}
+
+// validOpaqueToken checks the prefix and the checksum of an opaque token.
+func validOpaqueToken(token string, prefix string) bool {
+	if !strings.HasPrefix(token, prefix) || len(token) != len(prefix)+opaqueTokenRandomLength+opaqueTokenChecksumLength {
+		return false
+	}
+	checksumStart := len(token) - opaqueTokenChecksumLength
+	return encodeChecksum(token[:checksumStart]) == token[checksumStart:]
+}
+
+// encodeChecksum returns the base62 encoded CRC32 checksum of a value, padded to a fixed length.
+func encodeChecksum(value string) string {
+	checksum := crc32.ChecksumIEEE([]byte(value))
+	alphabetLength := uint32(len(opaqueTokenAlphabet))
+	encoded := make([]byte, opaqueTokenChecksumLength)
+	for index := opaqueTokenChecksumLength - 1; index >= 0; index-- {
+		encoded[index] = opaqueTokenAlphabet[checksum%alphabetLength]
+		checksum /= alphabetLength
+	}
+	return string(encoded)
+}
+
+// validTokenFormat rejects opaque tokens with an invalid checksum before any store lookup,
+// tokens without a known prefix, like JWTs, are looked up as they are.
+func (tokenManager *Manager) validTokenFormat(token string) bool {
+	prefixes := []string{tokenManager.config.GetOpaqueAccessTokenPrefix(), tokenManager.config.GetOpaqueRefreshTokenPrefix()}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(token, prefix) {
+			return validOpaqueToken(token, prefix)
+		}
+	}
+	return true
+}
+
+// getTokenKey returns the key of a token inside the token stores,
+// only a hash is stored so a dump of the stores does not contain usable tokens.
+func getTokenKey(token string) string {
+	return crypto.Sha256Hash(token)
+}
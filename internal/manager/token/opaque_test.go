@@ -0,0 +1,87 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"github.com/webishdev/stopnik/internal/endpoint"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_OpaqueToken(t *testing.T) {
+	token := generateOpaqueToken("stpa_")
+
+	if !strings.HasPrefix(token, "stpa_") || len(token) != len("stpa_")+opaqueTokenRandomLength+opaqueTokenChecksumLength {
+		t.Fatalf("unexpected opaque token format %s", token)
+	}
+
+	if generateOpaqueToken("stpa_") == token {
+		t.Error("expected opaque tokens to be random")
+	}
+
+	lastCharacter := token[len(token)-1:]
+	changedCharacter := "0"
+	if lastCharacter == changedCharacter {
+		changedCharacter = "1"
+	}
+
+	type opaqueTokenParameter struct {
+		name   string
+		token  string
+		prefix string
+		valid  bool
+	}
+
+	var opaqueTokenParameters = []opaqueTokenParameter{
+		{"valid", token, "stpa_", true},
+		{"other prefix", token, "stpr_", false},
+		{"changed checksum", token[:len(token)-1] + changedCharacter, "stpa_", false},
+		{"changed random", "stpa_" + strings.Repeat("a", opaqueTokenRandomLength) + token[len(token)-opaqueTokenChecksumLength:], "stpa_", false},
+		{"truncated", token[:len(token)-1], "stpa_", false},
+	}
+
+	for _, test := range opaqueTokenParameters {
+		testMessage := fmt.Sprintf("Opaque token %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			if validOpaqueToken(test.token, test.prefix) != test.valid {
+				t.Errorf("expected opaque token %s to be valid %v", test.token, test.valid)
+			}
+		})
+	}
+}
+
+func Test_OpaqueTokenStorage(t *testing.T) {
+	testConfig := createTestConfig(t, true, 100, 0, "")
+	tokenManager := GetTokenManagerInstance()
+	client, clientExists := testConfig.GetClient("foo")
+	if !clientExists {
+		t.Fatal("client does not exist")
+	}
+
+	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+	accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{"abc"}, nil, "", "", nil)
+
+	if !strings.HasPrefix(accessTokenResponse.AccessTokenValue, "stpa_") || !strings.HasPrefix(accessTokenResponse.RefreshTokenValue, "stpr_") {
+		t.Fatalf("unexpected opaque token prefixes %v", accessTokenResponse)
+	}
+
+	accessTokenStore := *tokenManager.clientStores[client.Id].accessTokenStore
+	if _, rawValueStored := accessTokenStore.Get(accessTokenResponse.AccessTokenValue); rawValueStored {
+		t.Error("access token should only be stored as hash")
+	}
+
+	if _, accessTokenExists := tokenManager.GetAccessToken(context.Background(), accessTokenResponse.AccessTokenValue); !accessTokenExists {
+		t.Error("access token should exist")
+	}
+
+	mistyped := accessTokenResponse.AccessTokenValue[:len(accessTokenResponse.AccessTokenValue)-1]
+	if _, accessTokenExists := tokenManager.GetAccessToken(context.Background(), mistyped); accessTokenExists {
+		t.Error("mistyped access token should not exist")
+	}
+
+	if _, refreshTokenExists := tokenManager.GetRefreshToken(context.Background(), accessTokenResponse.RefreshTokenValue); !refreshTokenExists {
+		t.Error("refresh token should exist")
+	}
+}
@@ -1,21 +1,29 @@
 package token
 
 import (
+	"cmp"
+	"context"
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/base64"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/lestrrat-go/jwx/v2/jwt/openid"
 	"github.com/webishdev/stopnik/internal/config"
 	"github.com/webishdev/stopnik/internal/crypto"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/manager/dpop"
 	"github.com/webishdev/stopnik/internal/manager/key"
+	"github.com/webishdev/stopnik/internal/metrics"
 	"github.com/webishdev/stopnik/internal/oauth2"
 	"github.com/webishdev/stopnik/internal/oidc"
 	"github.com/webishdev/stopnik/internal/store"
 	"github.com/webishdev/stopnik/internal/system"
+	"github.com/webishdev/stopnik/internal/tracing"
 	"github.com/webishdev/stopnik/log"
 	"net/http"
 	"slices"
@@ -38,9 +46,13 @@ type ValidAccessToken struct {
 }
 
 type Manager struct {
-	config       *config.Config
-	keyLoader    crypto.KeyLoader
-	clientStores map[string]*clientStores
+	config               *config.Config
+	keyLoader            crypto.KeyLoader
+	dpopManager          *dpop.Manager
+	clientStores         map[string]*clientStores
+	clientStoresMux      *sync.RWMutex
+	offlineTokensChanged chan struct{}
+	offlineTokensWritten chan struct{}
 }
 
 type IdTokenInput struct {
@@ -52,6 +64,50 @@ type IdTokenInput struct {
 	Nonce           string
 	AtHash          string
 	AuthTime        time.Time
+	Acr             string
+	Amr             []string
+}
+
+// ExchangeInput contains the validated values of a token exchange request,
+// see https://datatracker.ietf.org/doc/html/rfc8693#section-2.1
+type ExchangeInput struct {
+	Username  string
+	Scopes    []string
+	Audience  []string
+	Actor     *oauth2.Actor
+	ExpiresAt time.Time
+	// JwkThumbprint of a DPoP proof the token is bound to
+	JwkThumbprint string
+	// CertificateThumbprint of a TLS client certificate the token is bound to
+	CertificateThumbprint string
+}
+
+// GrantInput contains the granted and requested resources, scopes and authorization details of a token request,
+// see https://datatracker.ietf.org/doc/html/rfc8707 and https://datatracker.ietf.org/doc/html/rfc9396
+// Granted values are kept with the refresh token, the access token is issued for the requested values,
+// which fall back to the granted values when empty.
+type GrantInput struct {
+	Resources                     []string
+	RequestedResources            []string
+	Scopes                        []string
+	AuthorizationDetails          []oauth2.AuthorizationDetail
+	RequestedAuthorizationDetails []oauth2.AuthorizationDetail
+	// JwkThumbprint of a DPoP proof the tokens are bound to, see https://datatracker.ietf.org/doc/html/rfc9449#section-5
+	JwkThumbprint string
+	// CertificateThumbprint of a TLS client certificate the access token is bound to,
+	// see https://datatracker.ietf.org/doc/html/rfc8705#section-3
+	CertificateThumbprint string
+	// Acr and Amr of the resource owner authentication, see https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+	Acr string
+	Amr []string
+	// RefreshToken used for a refresh token grant, see https://datatracker.ietf.org/doc/html/rfc6749#section-6
+	RefreshToken *oauth2.RefreshToken
+	// OfflineAccess the user consented to for an authorization code, refreshed offline tokens keep their offline access
+	OfflineAccess bool
+	// GrantType the access token is issued for, the issuance is only recorded in the audit trail when provided
+	GrantType oauth2.GrantType
+	// AssertionIssuer of a JWT authorization grant, see https://datatracker.ietf.org/doc/html/rfc7523#section-3
+	AssertionIssuer string
 }
 
 var tokenManagerLock = &sync.Mutex{}
@@ -64,9 +120,11 @@ func GetTokenManagerInstance() *Manager {
 		currentConfig := config.GetConfigInstance()
 		keyLoader := key.GetDefaultKeyLoaderInstance()
 		tokenManagerSingleton = &Manager{
-			config:       currentConfig,
-			keyLoader:    keyLoader,
-			clientStores: make(map[string]*clientStores),
+			config:          currentConfig,
+			keyLoader:       keyLoader,
+			dpopManager:     dpop.GetDPoPManagerInstance(),
+			clientStores:    make(map[string]*clientStores),
+			clientStoresMux: &sync.RWMutex{},
 		}
 
 		var allClients []config.Client
@@ -79,26 +137,120 @@ func GetTokenManagerInstance() *Manager {
 		}
 
 		for _, client := range allClients {
-			accessStoreTime := time.Minute*time.Duration(client.GetAccessTTL()) + time.Minute*time.Duration(1)
-			refreshStoreTime := time.Minute*time.Duration(client.GetRefreshTTL()) + time.Minute*time.Duration(1)
-			accessTokenStore := store.NewTimedStore[oauth2.AccessToken](accessStoreTime)
-			refreshTokenStore := store.NewTimedStore[oauth2.RefreshToken](refreshStoreTime)
-			authorizationCodeStore := store.NewDefaultTimedStore[string]()
-			stores := &clientStores{
-				accessTokenStore:       &accessTokenStore,
-				refreshTokenStore:      &refreshTokenStore,
-				authorizationCodeStore: &authorizationCodeStore,
-			}
-			tokenManagerSingleton.clientStores[client.Id] = stores
+			tokenManagerSingleton.clientStores[client.Id] = newClientStores(&client)
+		}
+
+		registerStoreSizeMetrics(tokenManagerSingleton)
+
+		loadError := tokenManagerSingleton.loadOfflineTokens()
+		if loadError != nil {
+			log.Error("Could not load offline tokens", "file", currentConfig.Server.OfflineTokenFile, log.Err(loadError))
 		}
+		tokenManagerSingleton.startOfflineTokenWriter()
 	}
 	return tokenManagerSingleton
 }
 
-func (tokenManager *Manager) GetAccessToken(token string) (*oauth2.AccessToken, bool) {
-	for _, currentClientStores := range tokenManager.clientStores {
+// Shutdown writes pending changes of offline tokens, when the token manager was created.
+func Shutdown() {
+	tokenManagerLock.Lock()
+	defer tokenManagerLock.Unlock()
+	if tokenManagerSingleton != nil {
+		tokenManagerSingleton.stopOfflineTokenWriter()
+	}
+}
+
+func newClientStores(client *config.Client) *clientStores {
+	accessStoreTime := time.Minute*time.Duration(client.GetAccessTTL()) + time.Minute*time.Duration(1)
+	refreshStoreTime := time.Minute*time.Duration(client.GetRefreshTTL()) + time.Minute*time.Duration(1)
+	accessTokenStore := store.NewTimedStore[oauth2.AccessToken](accessStoreTime)
+	refreshTokenStore := store.NewTimedStore[oauth2.RefreshToken](refreshStoreTime)
+	authorizationCodeStore := store.NewDefaultTimedStore[string]()
+	return &clientStores{
+		accessTokenStore:       &accessTokenStore,
+		refreshTokenStore:      &refreshTokenStore,
+		authorizationCodeStore: &authorizationCodeStore,
+	}
+}
+
+// getClientStores returns the stores of a client,
+// stores for clients registered at runtime are created on first use.
+func (tokenManager *Manager) getClientStores(client *config.Client) *clientStores {
+	tokenManager.clientStoresMux.RLock()
+	stores, storesExist := tokenManager.clientStores[client.Id]
+	tokenManager.clientStoresMux.RUnlock()
+	if storesExist {
+		return stores
+	}
+
+	tokenManager.clientStoresMux.Lock()
+	defer tokenManager.clientStoresMux.Unlock()
+	stores, storesExist = tokenManager.clientStores[client.Id]
+	if !storesExist {
+		stores = newClientStores(client)
+		tokenManager.clientStores[client.Id] = stores
+	}
+	return stores
+}
+
+func (tokenManager *Manager) allClientStores() []*clientStores {
+	tokenManager.clientStoresMux.RLock()
+	defer tokenManager.clientStoresMux.RUnlock()
+	result := make([]*clientStores, 0, len(tokenManager.clientStores))
+	for _, stores := range tokenManager.clientStores {
+		result = append(result, stores)
+	}
+	return result
+}
+
+// DeleteClientStores removes all tokens of the client with the given id,
+// used when a client registered at runtime is deleted.
+func (tokenManager *Manager) DeleteClientStores(clientId string) {
+	tokenManager.clientStoresMux.Lock()
+	delete(tokenManager.clientStores, clientId)
+	tokenManager.clientStoresMux.Unlock()
+	tokenManager.persistOfflineTokens()
+}
+
+func registerStoreSizeMetrics(tokenManager *Manager) {
+	metrics.StoreSize.SetFunc(func() float64 {
+		return tokenManager.storeSize(func(stores *clientStores) int {
+			return (*stores.accessTokenStore).Size()
+		})
+	}, "access_token")
+	metrics.StoreSize.SetFunc(func() float64 {
+		return tokenManager.storeSize(func(stores *clientStores) int {
+			return (*stores.refreshTokenStore).Size()
+		})
+	}, "refresh_token")
+	metrics.StoreSize.SetFunc(func() float64 {
+		return tokenManager.storeSize(func(stores *clientStores) int {
+			return (*stores.authorizationCodeStore).Size()
+		})
+	}, "authorization_code")
+}
+
+func (tokenManager *Manager) storeSize(size func(stores *clientStores) int) float64 {
+	result := 0
+	for _, currentClientStores := range tokenManager.allClientStores() {
+		result += size(currentClientStores)
+	}
+	return float64(result)
+}
+
+func (tokenManager *Manager) GetAccessToken(ctx context.Context, token string) (*oauth2.AccessToken, bool) {
+	_, span := tracing.Start(ctx, "token.Manager.GetAccessToken")
+	defer span.End()
+	if !tokenManager.validTokenFormat(token) {
+		return nil, false
+	}
+	return tokenManager.getAccessToken(getTokenKey(token))
+}
+
+func (tokenManager *Manager) getAccessToken(key string) (*oauth2.AccessToken, bool) {
+	for _, currentClientStores := range tokenManager.allClientStores() {
 		accessTokenStore := *currentClientStores.accessTokenStore
-		accessToken, accessTokenExists := accessTokenStore.Get(token)
+		accessToken, accessTokenExists := accessTokenStore.Get(key)
 		if accessTokenExists {
 			return accessToken, true
 		}
@@ -106,19 +258,33 @@ func (tokenManager *Manager) GetAccessToken(token string) (*oauth2.AccessToken,
 	return nil, false
 }
 
-func (tokenManager *Manager) RevokeAccessToken(accessToken *oauth2.AccessToken) {
+func (tokenManager *Manager) RevokeAccessToken(r *http.Request, accessToken *oauth2.AccessToken) {
+	ctx, span := tracing.Start(r.Context(), "token.Manager.RevokeAccessToken")
+	defer span.End()
+	r = r.WithContext(ctx)
+	tokenManager.revokeAccessToken(r, accessToken, "")
+}
+
+func (tokenManager *Manager) revokeAccessToken(r *http.Request, accessToken *oauth2.AccessToken, reason string) {
 	if accessToken != nil {
-		for _, currentClientStores := range tokenManager.clientStores {
+		for _, currentClientStores := range tokenManager.allClientStores() {
 			accessTokenStore := *currentClientStores.accessTokenStore
 			accessTokenStore.Delete(accessToken.Key)
 		}
+		recordTokenRevoked(r, oauth2.ItAccessToken, accessToken.ClientId, accessToken.Username, reason)
 	}
 }
 
-func (tokenManager *Manager) GetRefreshToken(token string) (*oauth2.RefreshToken, bool) {
-	for _, currentClientStores := range tokenManager.clientStores {
+func (tokenManager *Manager) GetRefreshToken(ctx context.Context, token string) (*oauth2.RefreshToken, bool) {
+	_, span := tracing.Start(ctx, "token.Manager.GetRefreshToken")
+	defer span.End()
+	if !tokenManager.validTokenFormat(token) {
+		return nil, false
+	}
+	key := getTokenKey(token)
+	for _, currentClientStores := range tokenManager.allClientStores() {
 		refreshTokenStore := *currentClientStores.refreshTokenStore
-		refreshToken, refreshTokenExists := refreshTokenStore.Get(token)
+		refreshToken, refreshTokenExists := refreshTokenStore.Get(key)
 		if refreshTokenExists {
 			return refreshToken, true
 		}
@@ -126,65 +292,195 @@ func (tokenManager *Manager) GetRefreshToken(token string) (*oauth2.RefreshToken
 	return nil, false
 }
 
-func (tokenManager *Manager) RevokeRefreshToken(refreshToken *oauth2.RefreshToken) {
+func (tokenManager *Manager) RevokeRefreshToken(r *http.Request, refreshToken *oauth2.RefreshToken) {
+	ctx, span := tracing.Start(r.Context(), "token.Manager.RevokeRefreshToken")
+	defer span.End()
+	r = r.WithContext(ctx)
+	tokenManager.revokeRefreshToken(r, refreshToken, "")
+}
+
+func (tokenManager *Manager) revokeRefreshToken(r *http.Request, refreshToken *oauth2.RefreshToken, reason string) {
 	if refreshToken != nil {
-		for _, currentClientStores := range tokenManager.clientStores {
+		for _, currentClientStores := range tokenManager.allClientStores() {
 			refreshTokenStore := *currentClientStores.refreshTokenStore
 			refreshTokenStore.Delete(refreshToken.Key)
 		}
+		if refreshToken.Offline {
+			tokenManager.persistOfflineTokens()
+		}
+		recordTokenRevoked(r, oauth2.ItRefreshToken, refreshToken.ClientId, refreshToken.Username, reason)
 	}
 }
 
-func (tokenManager *Manager) RevokeAccessTokenByAuthorizationCode(authorizationCode string) {
-	for _, currentClientStores := range tokenManager.clientStores {
+func (tokenManager *Manager) RevokeAccessTokenByAuthorizationCode(r *http.Request, authorizationCode string) {
+	ctx, span := tracing.Start(r.Context(), "token.Manager.RevokeAccessTokenByAuthorizationCode")
+	defer span.End()
+	r = r.WithContext(ctx)
+	for _, currentClientStores := range tokenManager.allClientStores() {
 		authorizationCodeStore := *currentClientStores.authorizationCodeStore
 		accessTokenKey, accessTokenKeyExists := authorizationCodeStore.Get(authorizationCode)
 		if accessTokenKeyExists {
-			accessToken, accessTokenExists := tokenManager.GetAccessToken(*accessTokenKey)
+			accessToken, accessTokenExists := tokenManager.getAccessToken(*accessTokenKey)
 			if accessTokenExists {
-				tokenManager.RevokeAccessToken(accessToken)
+				tokenManager.revokeAccessToken(r, accessToken, revokeReasonInvalidAuthorizationCode)
 			}
 		}
 	}
 }
 
-func (tokenManager *Manager) CreateAccessTokenResponse(r *http.Request, username string, client *config.Client, authTime *time.Time, scopes []string, requestedClaims *oidc.ClaimsParameter, nonce string, authorizationCode string) oauth2.AccessTokenResponse {
-	log.Debug("Creating new access token for %s, access TTL %d, refresh TTL %d", client.Id, client.GetAccessTTL(), client.GetRefreshTTL())
+// CreateAccessTokenResponse issues an access token and, depending on the client and scopes, a refresh and ID token.
+// Nothing is stored when the ID token can not be created.
+func (tokenManager *Manager) CreateAccessTokenResponse(r *http.Request, username string, client *config.Client, authTime *time.Time, scopes []string, requestedClaims *oidc.ClaimsParameter, nonce string, authorizationCode string, grantInput *GrantInput) (oauth2.AccessTokenResponse, error) {
+	log.DebugContext(r.Context(), "Creating new access token", log.ClientId(client.Id), "access_ttl", client.GetAccessTTL(), "refresh_ttl", client.GetRefreshTTL())
+	ctx, span := tracing.Start(r.Context(), "token.Manager.CreateAccessTokenResponse")
+	span.SetAttribute("client.id", client.Id)
+	defer span.End()
+	r = r.WithContext(ctx)
 
 	requestData := internalHttp.NewRequestData(r)
-	accessTokenStore := *tokenManager.clientStores[client.Id].accessTokenStore
-	refreshTokenStore := *tokenManager.clientStores[client.Id].refreshTokenStore
-	authorizationCodeStore := *tokenManager.clientStores[client.Id].authorizationCodeStore
+	stores := tokenManager.getClientStores(client)
+	accessTokenStore := *stores.accessTokenStore
+	refreshTokenStore := *stores.refreshTokenStore
+	authorizationCodeStore := *stores.authorizationCodeStore
 
+	now := time.Now()
+	issuer := tokenManager.config.GetIssuer(requestData)
 	accessTokenDuration := time.Minute * time.Duration(client.GetAccessTTL())
-	accessTokenKey := tokenManager.CreateAccessToken(r, username, client, scopes, accessTokenDuration)
+	accessScopes := scopes
+	audience := client.GetAudience()
+	var grantedResources []string
+	var resources []*config.Resource
+	var grantedAuthorizationDetails []oauth2.AuthorizationDetail
+	var authorizationDetails []oauth2.AuthorizationDetail
+	var jwkThumbprint string
+	var certificateThumbprint string
+	var usedRefreshToken *oauth2.RefreshToken
+	var offlineAccess bool
+	var acr string
+	var amr []string
+	if grantInput != nil {
+		acr = grantInput.Acr
+		amr = grantInput.Amr
+		jwkThumbprint = grantInput.JwkThumbprint
+		usedRefreshToken = grantInput.RefreshToken
+		offlineAccess = grantInput.OfflineAccess || (usedRefreshToken != nil && usedRefreshToken.Offline)
+		certificateThumbprint = grantInput.CertificateThumbprint
+		grantedResources = grantInput.Resources
+		if len(grantInput.Scopes) > 0 {
+			accessScopes = grantInput.Scopes
+		}
+		resources = tokenManager.getResources(grantInput)
+		grantedAuthorizationDetails = grantInput.AuthorizationDetails
+		authorizationDetails = grantInput.AuthorizationDetails
+		if len(grantInput.RequestedAuthorizationDetails) > 0 {
+			authorizationDetails = grantInput.RequestedAuthorizationDetails
+		}
+	}
+	// OpenId Connect clients only receive refresh tokens for offline access the user consented to,
+	// the offline_access scope is removed for all other grants, see https://openid.net/specs/openid-connect-core-1_0.html#OfflineAccess
+	offline := client.Oidc && offlineAccess && oidc.HasOfflineAccessScope(scopes)
+	requestedScopes := scopes
+	if !offline {
+		scopes = oidc.RemoveOfflineAccessScope(scopes)
+		accessScopes = oidc.RemoveOfflineAccessScope(accessScopes)
+	}
+	if len(resources) > 0 {
+		audience = getResourceIds(resources)
+		accessScopes = filterResourceScopes(resources, accessScopes)
+		accessTokenDuration = getResourceAccessDuration(resources, accessTokenDuration)
+	}
+	tokenType, confirmation := getConfirmation(jwkThumbprint, certificateThumbprint)
+	_, refreshConfirmation := getConfirmation(jwkThumbprint, "")
 	accessToken := &oauth2.AccessToken{
-		Key:       accessTokenKey,
-		TokenType: oauth2.TtBearer,
-		Username:  username,
-		ClientId:  client.Id,
-		Scopes:    scopes,
+		Id:                   uuid.NewString(),
+		TokenType:            tokenType,
+		Username:             username,
+		ClientId:             client.Id,
+		Scopes:               accessScopes,
+		Issuer:               issuer,
+		Audience:             audience,
+		IssuedAt:             now,
+		NotBefore:            now,
+		ExpiresAt:            now.Add(accessTokenDuration),
+		Resources:            getResourceIds(resources),
+		AuthorizationDetails: authorizationDetails,
+		Confirmation:         confirmation,
+		Acr:                  acr,
+		Amr:                  amr,
+	}
+	if authTime != nil {
+		accessToken.AuthTime = *authTime
 	}
 	if client.Oidc && requestedClaims != nil {
 		accessToken.RequestedClaims = requestedClaims
 	}
+	accessTokenValue := tokenManager.generateAccessToken(client, accessToken)
+	accessToken.Key = getTokenKey(accessTokenValue)
+
+	var idTokenValue string
+	if client.Oidc && oidc.HasOidcScope(scopes) {
+		user, userExists := tokenManager.config.GetUser(username)
+		if userExists {
+			accessTokenHash := tokenManager.CreateAccessTokenHash(client, accessTokenValue)
+			idTokenInput := IdTokenInput{
+				Username: user.Username,
+				User:     user,
+				Client:   client,
+				Scopes:   scopes,
+				Nonce:    nonce,
+				AtHash:   accessTokenHash,
+				Acr:      acr,
+				Amr:      amr,
+			}
+			if requestedClaims != nil {
+				idTokenInput.RequestedClaims = requestedClaims
+			}
+			if authTime != nil {
+				idTokenInput.AuthTime = *authTime
+			}
+			var idTokenError error
+			idTokenValue, idTokenError = tokenManager.createIdToken(r, idTokenInput)
+			if idTokenError != nil {
+				return oauth2.AccessTokenResponse{}, idTokenError
+			}
+		}
+	}
 
 	accessTokenStore.SetWithDuration(accessToken.Key, accessToken, accessTokenDuration)
 
 	accessTokenResponse := oauth2.AccessTokenResponse{
-		AccessTokenValue: accessToken.Key,
-		TokenType:        oauth2.TtBearer,
-		ExpiresIn:        int(accessTokenDuration / time.Second),
+		AccessTokenValue:     accessTokenValue,
+		TokenType:            tokenType,
+		ExpiresIn:            int(accessTokenDuration / time.Second),
+		IdTokenValue:         idTokenValue,
+		AuthorizationDetails: authorizationDetails,
+	}
+
+	// https://datatracker.ietf.org/doc/html/rfc6749#section-5.1
+	if !slices.Equal(accessScopes, requestedScopes) {
+		accessTokenResponse.Scope = strings.Join(accessScopes, " ")
 	}
 
-	if (!client.Oidc && client.GetRefreshTTL() > 0) || (client.Oidc && oidc.HasOfflineAccessScope(scopes) && client.GetRefreshTTL() > 0) {
-		refreshTokenDuration := time.Minute * time.Duration(client.GetRefreshTTL())
-		refreshTokenKey := tokenManager.generateRefreshToken(requestData, username, client, scopes, refreshTokenDuration)
+	refreshTokenExpiresAt, grantedAt := getRefreshTokenExpiration(client, now, usedRefreshToken)
+	if client.GetRefreshTTL() > 0 && (!client.Oidc || offline) && refreshTokenExpiresAt.After(now) {
+		refreshTokenDuration := refreshTokenExpiresAt.Sub(now)
 		refreshToken := &oauth2.RefreshToken{
-			Key:      refreshTokenKey,
-			Username: username,
-			ClientId: client.Id,
-			Scopes:   scopes,
+			Id:                   uuid.NewString(),
+			Username:             username,
+			ClientId:             client.Id,
+			Scopes:               scopes,
+			Issuer:               issuer,
+			Audience:             client.GetAudience(),
+			IssuedAt:             now,
+			NotBefore:            now,
+			ExpiresAt:            refreshTokenExpiresAt,
+			Resources:            grantedResources,
+			AuthorizationDetails: grantedAuthorizationDetails,
+			Confirmation:         refreshConfirmation,
+			Acr:                  acr,
+			Amr:                  amr,
+			Offline:              offline,
+			GrantedAt:            grantedAt,
 		}
 
 		if authTime != nil {
@@ -193,31 +489,19 @@ func (tokenManager *Manager) CreateAccessTokenResponse(r *http.Request, username
 		if client.Oidc && requestedClaims != nil {
 			refreshToken.RequestedClaims = requestedClaims
 		}
+		refreshTokenValue := tokenManager.generateRefreshToken(client, refreshToken)
+		refreshToken.Key = getTokenKey(refreshTokenValue)
 
 		refreshTokenStore.SetWithDuration(refreshToken.Key, refreshToken, refreshTokenDuration)
 
-		accessTokenResponse.RefreshTokenValue = refreshToken.Key
-	}
+		accessTokenResponse.RefreshTokenValue = refreshTokenValue
 
-	if client.Oidc && oidc.HasOidcScope(scopes) {
-		user, userExists := tokenManager.config.GetUser(username)
-		if userExists {
-			accessTokenHash := tokenManager.CreateAccessTokenHash(client, accessToken.Key)
-			idTokenInput := IdTokenInput{
-				Username: user.Username,
-				User:     user,
-				Client:   client,
-				Scopes:   scopes,
-				Nonce:    nonce,
-				AtHash:   accessTokenHash,
-			}
-			if requestedClaims != nil {
-				idTokenInput.RequestedClaims = requestedClaims
-			}
-			if authTime != nil {
-				idTokenInput.AuthTime = *authTime
-			}
-			accessTokenResponse.IdTokenValue = tokenManager.createIdToken(r, idTokenInput)
+		// the used refresh token is replaced by the new one, which keeps its expiration without sliding expiration
+		if usedRefreshToken != nil {
+			tokenManager.revokeRefreshToken(r, usedRefreshToken, revokeReasonRotated)
+		}
+		if offline {
+			tokenManager.persistOfflineTokens()
 		}
 	}
 
@@ -225,20 +509,108 @@ func (tokenManager *Manager) CreateAccessTokenResponse(r *http.Request, username
 		authorizationCodeStore.Set(authorizationCode, &accessToken.Key)
 	}
 
-	return accessTokenResponse
+	if usedRefreshToken != nil {
+		recordTokenRefreshed(r, usedRefreshToken, accessTokenResponse.RefreshTokenValue != "")
+	}
+	if grantInput != nil && grantInput.GrantType != "" {
+		recordTokenIssued(r, accessToken, grantInput.GrantType, scopes, getGrantDetails(grantInput))
+	}
+
+	return accessTokenResponse, nil
 }
 
-func (tokenManager *Manager) createIdToken(r *http.Request, idTokenInput IdTokenInput) string {
+// CreateExchangedAccessTokenResponse issues an access token for a token exchange,
+// the token never outlives the subject token and no refresh token is issued.
+// Implements https://datatracker.ietf.org/doc/html/rfc8693#section-2.2
+func (tokenManager *Manager) CreateExchangedAccessTokenResponse(r *http.Request, client *config.Client, exchangeInput ExchangeInput) oauth2.AccessTokenResponse {
+	log.DebugContext(r.Context(), "Creating exchanged access token", log.ClientId(client.Id))
+	ctx, span := tracing.Start(r.Context(), "token.Manager.CreateExchangedAccessTokenResponse")
+	span.SetAttribute("client.id", client.Id)
+	defer span.End()
+	r = r.WithContext(ctx)
+
+	requestData := internalHttp.NewRequestData(r)
+	accessTokenStore := *tokenManager.getClientStores(client).accessTokenStore
+
+	now := time.Now()
+	expiresAt := now.Add(time.Minute * time.Duration(client.GetAccessTTL()))
+	if !exchangeInput.ExpiresAt.IsZero() && exchangeInput.ExpiresAt.Before(expiresAt) {
+		expiresAt = exchangeInput.ExpiresAt
+	}
+	accessTokenDuration := expiresAt.Sub(now)
+	tokenType, confirmation := getConfirmation(exchangeInput.JwkThumbprint, exchangeInput.CertificateThumbprint)
+	accessToken := &oauth2.AccessToken{
+		Id:           uuid.NewString(),
+		TokenType:    tokenType,
+		Username:     exchangeInput.Username,
+		ClientId:     client.Id,
+		Scopes:       exchangeInput.Scopes,
+		Issuer:       tokenManager.config.GetIssuer(requestData),
+		Audience:     exchangeInput.Audience,
+		IssuedAt:     now,
+		NotBefore:    now,
+		ExpiresAt:    expiresAt,
+		Actor:        exchangeInput.Actor,
+		Confirmation: confirmation,
+	}
+	accessTokenValue := tokenManager.generateAccessToken(client, accessToken)
+	accessToken.Key = getTokenKey(accessTokenValue)
+
+	accessTokenStore.SetWithDuration(accessToken.Key, accessToken, accessTokenDuration)
+
+	details := map[string]string{"audience": strings.Join(exchangeInput.Audience, " ")}
+	if exchangeInput.Actor != nil {
+		details["actor"] = exchangeInput.Actor.Subject
+	}
+	recordTokenIssued(r, accessToken, oauth2.GtTokenExchange, exchangeInput.Scopes, details)
+
+	return oauth2.AccessTokenResponse{
+		AccessTokenValue: accessTokenValue,
+		TokenType:        tokenType,
+		ExpiresIn:        int(accessTokenDuration / time.Second),
+		IssuedTokenType:  oauth2.TtiAccessToken,
+		Scope:            strings.Join(exchangeInput.Scopes, " "),
+	}
+}
+
+// getConfirmation returns the token type and the confirmation of tokens bound to the key of a DPoP proof
+// or a TLS client certificate, tokens without a JWK thumbprint are bearer tokens.
+// Refresh tokens are only bound to DPoP keys, because confidential clients authenticate with their certificate anyway,
+// see https://datatracker.ietf.org/doc/html/rfc8705#section-4
+func getConfirmation(jwkThumbprint string, certificateThumbprint string) (oauth2.TokenType, *oauth2.Confirmation) {
+	tokenType := oauth2.TtBearer
+	if jwkThumbprint != "" {
+		tokenType = oauth2.TtDPoP
+	}
+	if jwkThumbprint == "" && certificateThumbprint == "" {
+		return tokenType, nil
+	}
+	return tokenType, &oauth2.Confirmation{JwkThumbprint: jwkThumbprint, CertificateThumbprint: certificateThumbprint}
+}
+
+// getResources returns the configured resources an access token is issued for,
+// the requested resources are used when present, the granted resources otherwise.
+func (tokenManager *Manager) getResources(grantInput *GrantInput) []*config.Resource {
+	resourceIds := grantInput.RequestedResources
+	if len(resourceIds) == 0 {
+		resourceIds = grantInput.Resources
+	}
+	var resources []*config.Resource
+	for _, resourceId := range resourceIds {
+		resource, resourceExists := tokenManager.config.GetResource(resourceId)
+		if resourceExists {
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
+
+func (tokenManager *Manager) createIdToken(r *http.Request, idTokenInput IdTokenInput) (string, error) {
 	if idTokenInput.Client.Oidc && oidc.HasOidcScope(idTokenInput.Scopes) {
 		requestData := internalHttp.NewRequestData(r)
 		return tokenManager.generateIdToken(requestData, idTokenInput)
 	}
-	return ""
-}
-
-func (tokenManager *Manager) CreateAccessToken(r *http.Request, username string, client *config.Client, scopes []string, accessTokenDuration time.Duration) string {
-	requestData := internalHttp.NewRequestData(r)
-	return tokenManager.generateAccessToken(requestData, username, client, scopes, accessTokenDuration)
+	return "", nil
 }
 
 func (tokenManager *Manager) CreateAccessTokenHash(client *config.Client, accessTokenKey string) string {
@@ -253,34 +625,52 @@ func (tokenManager *Manager) CreateAccessTokenHash(client *config.Client, access
 
 // ValidateAccessTokenRequest  implements https://datatracker.ietf.org/doc/html/rfc6750#section-2
 func (tokenManager *Manager) ValidateAccessTokenRequest(r *http.Request) (*ValidAccessToken, bool) {
+	ctx, span := tracing.Start(r.Context(), "token.Manager.ValidateAccessTokenRequest")
+	defer span.End()
+	r = r.WithContext(ctx)
 	// https://datatracker.ietf.org/doc/html/rfc6750#section-2.1
-	log.Debug("Checking authorization request header field")
+	log.DebugContext(r.Context(), "Checking authorization request header field")
 	authorizationHeader := r.Header.Get(internalHttp.Authorization)
+	var validAccessToken *ValidAccessToken
+	var valid bool
 	if authorizationHeader == "" {
 		// https://datatracker.ietf.org/doc/html/rfc6750#section-2.2
-		log.Debug("Checking form-encoded body parameter")
+		log.DebugContext(r.Context(), "Checking form-encoded body parameter")
 		accessTokenValue := r.PostFormValue("access_token")
-		return tokenManager.validateAccessToken(accessTokenValue)
+		validAccessToken, valid = tokenManager.validateAccessToken(r, accessTokenValue, false)
 	} else {
-		return tokenManager.validateAccessTokenHeader(authorizationHeader)
+		validAccessToken, valid = tokenManager.validateAccessTokenHeader(r, authorizationHeader)
 	}
+	if valid {
+		log.AddRequestAttributes(r, log.ClientId(validAccessToken.Client.Id), log.Username(validAccessToken.User.Username))
+	}
+	return validAccessToken, valid
 }
 
-func (tokenManager *Manager) validateAccessTokenHeader(authorizationHeader string) (*ValidAccessToken, bool) {
+func (tokenManager *Manager) validateAccessTokenHeader(r *http.Request, authorizationHeader string) (*ValidAccessToken, bool) {
+	// https://datatracker.ietf.org/doc/html/rfc9449#section-7.1
+	scheme, dpopValue, found := strings.Cut(authorizationHeader, " ")
+	if found && scheme == internalHttp.AuthDPoP {
+		return tokenManager.validateAccessToken(r, dpopValue, true)
+	}
 	headerValue := getAuthorizationHeaderValue(authorizationHeader)
 	if headerValue == nil {
 		return &ValidAccessToken{}, false
 	}
-	return tokenManager.validateAccessToken(*headerValue)
+	return tokenManager.validateAccessToken(r, *headerValue, false)
 }
 
-func (tokenManager *Manager) validateAccessToken(accessTokenValue string) (*ValidAccessToken, bool) {
-	log.Debug("Validating access token")
-	accessToken, accessTokenExists := tokenManager.GetAccessToken(accessTokenValue)
+func (tokenManager *Manager) validateAccessToken(r *http.Request, accessTokenValue string, dpopScheme bool) (*ValidAccessToken, bool) {
+	log.DebugContext(r.Context(), "Validating access token")
+	accessToken, accessTokenExists := tokenManager.GetAccessToken(r.Context(), accessTokenValue)
 	if !accessTokenExists {
 		return &ValidAccessToken{}, false
 	}
 
+	if !tokenManager.validSenderConstraint(r, accessToken, accessTokenValue, dpopScheme) {
+		return &ValidAccessToken{}, false
+	}
+
 	username := accessToken.Username
 	user, userExists := tokenManager.config.GetUser(username)
 
@@ -305,41 +695,111 @@ func (tokenManager *Manager) validateAccessToken(accessTokenValue string) (*Vali
 	return validAccessToken, true
 }
 
-func (tokenManager *Manager) generateIdToken(requestData *internalHttp.RequestData, idTokenInput IdTokenInput) string {
+// validSenderConstraint checks that DPoP bound access tokens are only used with the DPoP scheme
+// and a proof of the bound key, bearer tokens must not be used with the DPoP scheme.
+// See https://datatracker.ietf.org/doc/html/rfc9449#section-7
+func (tokenManager *Manager) validSenderConstraint(r *http.Request, accessToken *oauth2.AccessToken, accessTokenValue string, dpopScheme bool) bool {
+	if !validCertificateBinding(r, accessToken) {
+		log.DebugContext(r.Context(), "Certificate bound access token used without bound client certificate")
+		return false
+	}
+	if accessToken.Confirmation == nil || accessToken.Confirmation.JwkThumbprint == "" {
+		return !dpopScheme
+	}
+	if !dpopScheme || tokenManager.dpopManager == nil {
+		log.DebugContext(r.Context(), "DPoP bound access token used without DPoP scheme")
+		return false
+	}
+	jwkThumbprint, proofError := tokenManager.dpopManager.ValidateResourceRequestProof(r, accessTokenValue)
+	return proofError == nil && jwkThumbprint == accessToken.Confirmation.JwkThumbprint
+}
+
+// validCertificateBinding checks that certificate bound access tokens are only used with the bound TLS client certificate,
+// see https://datatracker.ietf.org/doc/html/rfc8705#section-3
+func validCertificateBinding(r *http.Request, accessToken *oauth2.AccessToken) bool {
+	if accessToken.Confirmation == nil || accessToken.Confirmation.CertificateThumbprint == "" {
+		return true
+	}
+	certificate, certificateExists := internalHttp.GetClientCertificate(r)
+	return certificateExists && crypto.CertificateThumbprint(certificate) == accessToken.Confirmation.CertificateThumbprint
+}
+
+func (tokenManager *Manager) generateIdToken(requestData *internalHttp.RequestData, idTokenInput IdTokenInput) (string, error) {
 	client := idTokenInput.Client
 	idToken := generateIdToken(requestData, tokenManager.config, idTokenInput)
-	return tokenManager.generateJWTToken(client, idToken)
+	signedIdToken := tokenManager.generateJWTToken(client, idToken)
+	encryptedIdToken, encrypted, encryptionError := tokenManager.EncryptToken(client, []byte(signedIdToken), "JWT")
+	if encryptionError != nil {
+		return "", encryptionError
+	} else if encrypted {
+		return encryptedIdToken, nil
+	}
+	return signedIdToken, nil
 }
 
-func (tokenManager *Manager) generateAccessToken(requestData *internalHttp.RequestData, username string, client *config.Client, scopes []string, duration time.Duration) string {
-	tokenId := uuid.New()
+func (tokenManager *Manager) generateAccessToken(client *config.Client, accessToken *oauth2.AccessToken) string {
 	if client.OpaqueToken {
-		return tokenManager.generateOpaqueToken(tokenId.String())
+		return generateOpaqueToken(tokenManager.config.GetOpaqueAccessTokenPrefix())
 	}
-	accessToken := generateAccessToken(requestData, tokenManager.config, client, tokenId.String(), duration, username, scopes)
-	return tokenManager.generateJWTToken(client, accessToken)
+	token := generateAccessToken(tokenManager.config, client, accessToken)
+	headers := getAccessTokenHeaders(client)
+	if len(accessToken.Resources) == 1 {
+		resource, resourceExists := tokenManager.config.GetResource(accessToken.Resources[0])
+		if resourceExists {
+			managedKey, keyExists := tokenManager.keyLoader.LoadResourceKeys(resource)
+			if keyExists {
+				var suboptions []jwt.Option
+				if headers != nil {
+					suboptions = append(suboptions, jws.WithProtectedHeaders(headers))
+				}
+				return signToken(managedKey, token, suboptions)
+			}
+		}
+	}
+	return tokenManager.SignToken(client, token, headers)
 }
 
-func (tokenManager *Manager) generateRefreshToken(requestData *internalHttp.RequestData, username string, client *config.Client, scopes []string, duration time.Duration) string {
-	tokenId := uuid.New()
-	if client.OpaqueToken {
-		return tokenManager.generateOpaqueToken(tokenId.String())
+// getAccessTokenHeaders returns the typ header of JWT access tokens,
+// clients with legacy access tokens are issued tokens without it.
+// See https://datatracker.ietf.org/doc/html/rfc9068#section-2.1
+func getAccessTokenHeaders(client *config.Client) jws.Headers {
+	if client.LegacyAccessToken {
+		return nil
+	}
+	headers := jws.NewHeaders()
+	headerError := headers.Set(jws.TypeKey, oauth2.JwtTypeAccessToken)
+	if headerError != nil {
+		system.Error(headerError)
 	}
-	accessToken := generateRefreshToken(requestData, tokenManager.config, client, tokenId.String(), duration, username, scopes)
-	return tokenManager.generateJWTToken(client, accessToken)
+	return headers
 }
 
-func (tokenManager *Manager) generateOpaqueToken(tokenId string) string {
-	return base64.RawURLEncoding.EncodeToString([]byte(tokenId))
+func (tokenManager *Manager) generateRefreshToken(client *config.Client, refreshToken *oauth2.RefreshToken) string {
+	if client.OpaqueToken {
+		return generateOpaqueToken(tokenManager.config.GetOpaqueRefreshTokenPrefix())
+	}
+	token := generateRefreshToken(tokenManager.config, client, refreshToken)
+	return tokenManager.generateJWTToken(client, token)
 }
 
 func (tokenManager *Manager) generateJWTToken(client *config.Client, token jwt.Token) string {
+	return tokenManager.SignToken(client, token, nil)
+}
+
+// SignToken signs a token with the key of the given client, the server key is used when no client key exists.
+// Without any private key the token is signed with the server secret, so only STOPnik itself can verify it.
+// Additional protected headers will be added to the signature when provided.
+func (tokenManager *Manager) SignToken(client *config.Client, token jwt.Token, headers jws.Headers) string {
+	var suboptions []jwt.Option
+	if headers != nil {
+		suboptions = append(suboptions, jws.WithProtectedHeaders(headers))
+	}
 
 	loader := tokenManager.keyLoader
 	managedKey, keyExists := loader.LoadKeys(client)
 
 	if !keyExists {
-		options := loader.GetServerKey()
+		options := loader.GetServerKey(suboptions...)
 		tokenString, tokenError := jwt.Sign(token, options)
 		if tokenError != nil {
 			system.Error(tokenError)
@@ -347,18 +807,99 @@ func (tokenManager *Manager) generateJWTToken(client *config.Client, token jwt.T
 
 		return string(tokenString)
 	} else {
-		currentKey := *managedKey.Key
+		return signToken(managedKey, token, suboptions)
+	}
 
-		options := jwt.WithKey(currentKey.Algorithm(), currentKey)
+}
 
-		tokenString, tokenError := jwt.Sign(token, options)
-		if tokenError != nil {
-			system.Error(tokenError)
+// HasPrivateKey checks whether a private key of the client or the server exists,
+// so tokens for the client can be signed without the server secret.
+func (tokenManager *Manager) HasPrivateKey(client *config.Client) bool {
+	_, keyExists := tokenManager.keyLoader.LoadKeys(client)
+	return keyExists
+}
+
+// SignTokenWithPrivateKey signs a token like SignToken, but never falls back to the server secret.
+// It must be used for tokens verified by others with the keys of the /keys endpoint.
+func (tokenManager *Manager) SignTokenWithPrivateKey(client *config.Client, token jwt.Token, headers jws.Headers) (string, error) {
+	managedKey, keyExists := tokenManager.keyLoader.LoadKeys(client)
+	if !keyExists {
+		return "", fmt.Errorf("no private key to sign tokens for client %s", client.Id)
+	}
+	var suboptions []jwt.Option
+	if headers != nil {
+		suboptions = append(suboptions, jws.WithProtectedHeaders(headers))
+	}
+	return signToken(managedKey, token, suboptions), nil
+}
+
+// ParseIdTokenHint verifies the signature and issuer of an ID token previously issued to the given client,
+// expired ID tokens are accepted as hint about the user.
+// Also returns a bool which indicates, whether the ID token is valid or not.
+// See https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func (tokenManager *Manager) ParseIdTokenHint(r *http.Request, client *config.Client, idTokenHint string) (jwt.Token, bool) {
+	var options jwt.ParseOption
+	managedKey, keyExists := tokenManager.keyLoader.LoadKeys(client)
+	if keyExists {
+		publicKey, publicKeyError := jwk.PublicKeyOf(*managedKey.Key)
+		if publicKeyError != nil {
+			return nil, false
 		}
+		options = jwt.WithKey(publicKey.Algorithm(), publicKey)
+	} else {
+		options = tokenManager.keyLoader.GetServerKey()
+	}
 
-		return string(tokenString)
+	idToken, parseError := jwt.Parse([]byte(idTokenHint), options, jwt.WithValidate(false))
+	if parseError != nil || !slices.Contains(idToken.Audience(), client.Id) {
+		return nil, false
+	}
+
+	if idToken.Issuer() != tokenManager.config.GetIssuer(internalHttp.NewRequestData(r)) {
+		return nil, false
+	}
+
+	return idToken, true
+}
+
+// GetSigningAlgorithm returns the algorithm tokens of the given client are signed with,
+// which is the algorithm of the client key or HS256 for the server secret.
+func (tokenManager *Manager) GetSigningAlgorithm(client *config.Client) jwa.SignatureAlgorithm {
+	managedKey, keyExists := tokenManager.keyLoader.LoadKeys(client)
+	if !keyExists {
+		return jwa.HS256
+	}
+	return jwa.SignatureAlgorithm((*managedKey.Key).Algorithm().String())
+}
+
+// EncryptToken encrypts a payload with the encryption key of the given client, the content type is added as cty header when provided.
+// Signed tokens are nested into the encrypted token, see https://openid.net/specs/openid-connect-core-1_0.html#SigningOrder
+// Also returns a bool which indicates, whether the client has an encryption key or not.
+func (tokenManager *Manager) EncryptToken(client *config.Client, payload []byte, contentType string) (string, bool, error) {
+	encryptionKey, keyExists := tokenManager.keyLoader.LoadEncryptionKey(client)
+	if !keyExists {
+		return "", false, nil
+	}
+
+	encryptedToken, encryptionError := encryptionKey.Encrypt(payload, contentType)
+	if encryptionError != nil {
+		return "", true, fmt.Errorf("could not encrypt token for client %s: %w", client.Id, encryptionError)
 	}
 
+	return string(encryptedToken), true, nil
+}
+
+func signToken(managedKey *crypto.ManagedKey, token jwt.Token, suboptions []jwt.Option) string {
+	currentKey := *managedKey.Key
+
+	options := jwt.WithKey(currentKey.Algorithm(), currentKey, suboptions...)
+
+	tokenString, tokenError := jwt.Sign(token, options)
+	if tokenError != nil {
+		system.Error(tokenError)
+	}
+
+	return string(tokenString)
 }
 
 func generateIdToken(requestData *internalHttp.RequestData, config *config.Config, idTokenInput IdTokenInput) jwt.Token {
@@ -381,7 +922,7 @@ func generateIdToken(requestData *internalHttp.RequestData, config *config.Confi
 	builder.Issuer(config.GetIssuer(requestData))
 
 	// https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.2
-	builder.Subject(user.Username)
+	builder.Subject(config.GetSubject(client, user.Username))
 
 	// https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.3
 	audience := append(client.GetAudience(), client.Id)
@@ -391,6 +932,10 @@ func generateIdToken(requestData *internalHttp.RequestData, config *config.Confi
 	addStringClaimOpenId(builder, oidc.ClaimAtHash, atHash)
 	addStringClaimOpenId(builder, oidc.ClaimNonce, nonce)
 	builder.Claim(oidc.ClaimAuthTime, authTime.Unix())
+	addStringClaimOpenId(builder, oidc.ClaimAcr, idTokenInput.Acr)
+	if len(idTokenInput.Amr) > 0 {
+		builder.Claim(oidc.ClaimAmr, idTokenInput.Amr)
+	}
 
 	if slices.Contains(scopes, oidc.ScopeProfile) {
 		builder.Name(user.GetName())
@@ -451,24 +996,64 @@ func generateIdToken(requestData *internalHttp.RequestData, config *config.Confi
 	return token
 }
 
-func generateAccessToken(requestData *internalHttp.RequestData, config *config.Config, client *config.Client, tokenId string, duration time.Duration, username string, scopes []string) jwt.Token {
+func generateAccessToken(config *config.Config, client *config.Client, accessToken *oauth2.AccessToken) jwt.Token {
 	builder := jwt.NewBuilder().
-		Expiration(time.Now().Add(duration)). // https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.4
-		IssuedAt(time.Now())                  // https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.6
+		Expiration(accessToken.ExpiresAt). // https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.4
+		NotBefore(accessToken.NotBefore).  // https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.5
+		IssuedAt(accessToken.IssuedAt)     // https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.6
 
 	// https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.7
-	builder.JwtID(tokenId)
+	builder.JwtID(accessToken.Id)
 
 	// https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.1
-	builder.Issuer(config.GetIssuer(requestData))
+	builder.Issuer(accessToken.Issuer)
 
 	// https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.2
-	builder.Subject(username)
+	if client.LegacyAccessToken {
+		builder.Subject(config.GetSubject(client, accessToken.Username))
+	} else {
+		// the client is the subject when no resource owner is involved, https://datatracker.ietf.org/doc/html/rfc9068#section-2.2
+		builder.Subject(cmp.Or(config.GetSubject(client, accessToken.Username), accessToken.ClientId))
+	}
 
 	// https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.3
-	builder.Audience(client.GetAudience())
+	builder.Audience(accessToken.Audience)
+
+	// https://datatracker.ietf.org/doc/html/rfc9068#section-2.2
+	if !client.LegacyAccessToken {
+		builder.Claim(oauth2.ClaimClientId, accessToken.ClientId)
+		if !accessToken.AuthTime.IsZero() {
+			builder.Claim(oidc.ClaimAuthTime, accessToken.AuthTime.Unix())
+		}
+		// https://datatracker.ietf.org/doc/html/rfc9068#section-2.2.1
+		if accessToken.Acr != "" {
+			builder.Claim(oidc.ClaimAcr, accessToken.Acr)
+		}
+		if len(accessToken.Amr) > 0 {
+			builder.Claim(oidc.ClaimAmr, accessToken.Amr)
+		}
+		scope := strings.TrimSpace(strings.Join(accessToken.Scopes, " "))
+		if scope != "" {
+			builder.Claim(oauth2.ClaimScope, scope)
+		}
+	}
+
+	// https://datatracker.ietf.org/doc/html/rfc9449#section-6.1
+	if accessToken.Confirmation != nil {
+		builder.Claim(oauth2.ClaimConfirmation, accessToken.Confirmation)
+	}
+
+	// https://datatracker.ietf.org/doc/html/rfc8693#section-4.1
+	if accessToken.Actor != nil {
+		builder.Claim(oauth2.ClaimActor, accessToken.Actor)
+	}
+
+	// https://datatracker.ietf.org/doc/html/rfc9396#section-9.1
+	if len(accessToken.AuthorizationDetails) > 0 {
+		builder.Claim(oauth2.ClaimAuthorizationDetails, accessToken.AuthorizationDetails)
+	}
 
-	claims := config.GetClaims(username, client.Id, scopes)
+	claims := config.GetClaims(accessToken.Username, client.Id, accessToken.Scopes)
 	for _, claim := range claims {
 		currentClaim := *claim
 		name := currentClaim.GetName()
@@ -485,22 +1070,23 @@ func generateAccessToken(requestData *internalHttp.RequestData, config *config.C
 	return token
 }
 
-func generateRefreshToken(requestData *internalHttp.RequestData, config *config.Config, client *config.Client, tokenId string, duration time.Duration, username string, scopes []string) jwt.Token {
+func generateRefreshToken(config *config.Config, client *config.Client, refreshToken *oauth2.RefreshToken) jwt.Token {
 	builder := jwt.NewBuilder().
-		Expiration(time.Now().Add(duration)). // https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.4
-		IssuedAt(time.Now())                  // https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.6
+		Expiration(refreshToken.ExpiresAt). // https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.4
+		NotBefore(refreshToken.NotBefore).  // https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.5
+		IssuedAt(refreshToken.IssuedAt)     // https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.6
 
 	// https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.7
-	builder.JwtID(tokenId)
+	builder.JwtID(refreshToken.Id)
 
 	// https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.1
-	builder.Issuer(config.GetIssuer(requestData))
+	builder.Issuer(refreshToken.Issuer)
 
 	// https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.2
-	builder.Subject(username)
+	builder.Subject(config.GetSubject(client, refreshToken.Username))
 
 	// https://www.rfc-editor.org/rfc/rfc7519.html#section-4.1.3
-	builder.Audience(client.GetAudience())
+	builder.Audience(refreshToken.Audience)
 
 	token, builderError := builder.Build()
 
@@ -536,6 +1122,41 @@ func addStringAddressClaim(builder *openid.AddressClaim, claimName string, claim
 	}
 }
 
+func getResourceIds(resources []*config.Resource) []string {
+	var resourceIds []string
+	for _, resource := range resources {
+		resourceIds = append(resourceIds, resource.Id)
+	}
+	return resourceIds
+}
+
+// filterResourceScopes removes scopes which are not allowed by any of the given resources.
+func filterResourceScopes(resources []*config.Resource, scopes []string) []string {
+	var result []string
+	for _, scope := range scopes {
+		allowed := slices.ContainsFunc(resources, func(resource *config.Resource) bool {
+			return resource.ValidateScope(scope)
+		})
+		if allowed {
+			result = append(result, scope)
+		}
+	}
+	return result
+}
+
+// getResourceAccessDuration returns the shortest access token time to live of the given resources,
+// the default duration is used when no resource defines a time to live.
+func getResourceAccessDuration(resources []*config.Resource, defaultDuration time.Duration) time.Duration {
+	var result time.Duration
+	for _, resource := range resources {
+		resourceDuration := time.Minute * time.Duration(resource.AccessTTL)
+		if resource.AccessTTL > 0 && (result == 0 || resourceDuration < result) {
+			result = resourceDuration
+		}
+	}
+	return cmp.Or(result, defaultDuration)
+}
+
 func hashToken(algorithm crypto.HashAlgorithm, token string) string {
 	tokenBytes := []byte(token)
 
@@ -1,7 +1,18 @@
 package token
 
 import (
+	"context"
+	gocrypto "crypto"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"fmt"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/webishdev/stopnik/internal/config"
 	"github.com/webishdev/stopnik/internal/crypto"
@@ -14,6 +25,7 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 )
 
 type tokenTestParameter struct {
@@ -57,7 +69,7 @@ func Test_AccessTokenResponse(t *testing.T) {
 			}
 
 			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
-			accessTokenResponse := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, requestScopes, nil, "", test.authCode)
+			accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, requestScopes, nil, "", test.authCode, &GrantInput{OfflineAccess: true})
 
 			assertTokenResponse(t, accessTokenResponse, test, client)
 
@@ -65,17 +77,17 @@ func Test_AccessTokenResponse(t *testing.T) {
 
 			assertAuthorizationHeader(t, tokenManager, authorizationHeader, requestScopes)
 
-			accessToken, accessTokenExists := tokenManager.GetAccessToken(accessTokenResponse.AccessTokenValue)
+			accessToken, accessTokenExists := tokenManager.GetAccessToken(context.Background(), accessTokenResponse.AccessTokenValue)
 
 			if !accessTokenExists {
 				t.Error("access token does not exist")
 			}
 
-			if accessToken.Key != accessTokenResponse.AccessTokenValue {
+			if accessToken.Key != getTokenKey(accessTokenResponse.AccessTokenValue) {
 				t.Error("wrong access token")
 			}
 
-			refreshToken, refreshTokenExists := tokenManager.GetRefreshToken(accessTokenResponse.RefreshTokenValue)
+			refreshToken, refreshTokenExists := tokenManager.GetRefreshToken(context.Background(), accessTokenResponse.RefreshTokenValue)
 
 			if test.refreshTokenTTL == 0 && refreshTokenExists {
 				t.Error("refresh token should not exists")
@@ -85,26 +97,26 @@ func Test_AccessTokenResponse(t *testing.T) {
 				t.Error("refresh token should exists")
 			}
 
-			if test.refreshTokenTTL > 0 && refreshToken.Key != accessTokenResponse.RefreshTokenValue {
+			if test.refreshTokenTTL > 0 && refreshToken.Key != getTokenKey(accessTokenResponse.RefreshTokenValue) {
 				t.Error("wrong refresh token")
 			}
 
 			if test.authCode == "" {
 
-				tokenManager.RevokeRefreshToken(refreshToken)
-				_, refreshTokenExists = tokenManager.GetRefreshToken(accessTokenResponse.RefreshTokenValue)
+				tokenManager.RevokeRefreshToken(request, refreshToken)
+				_, refreshTokenExists = tokenManager.GetRefreshToken(context.Background(), accessTokenResponse.RefreshTokenValue)
 				if refreshTokenExists {
 					t.Error("refresh token should not exists")
 				}
 
-				tokenManager.RevokeAccessToken(accessToken)
-				_, accessTokenExists = tokenManager.GetAccessToken(accessTokenResponse.AccessTokenValue)
+				tokenManager.RevokeAccessToken(request, accessToken)
+				_, accessTokenExists = tokenManager.GetAccessToken(context.Background(), accessTokenResponse.AccessTokenValue)
 				if accessTokenExists {
 					t.Error("access token should not exists")
 				}
 			} else {
-				tokenManager.RevokeAccessTokenByAuthorizationCode(test.authCode)
-				_, accessTokenExists = tokenManager.GetAccessToken(accessTokenResponse.AccessTokenValue)
+				tokenManager.RevokeAccessTokenByAuthorizationCode(request, test.authCode)
+				_, accessTokenExists = tokenManager.GetAccessToken(context.Background(), accessTokenResponse.AccessTokenValue)
 				if accessTokenExists {
 					t.Error("access token should not exists")
 				}
@@ -150,7 +162,7 @@ func Test_ForwardAuthClientId(t *testing.T) {
 			}
 
 			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
-			accessTokenResponse := tokenManager.CreateAccessTokenResponse(request, "foo", forwardAuthClient, nil, requestScopes, nil, "", test.authCode)
+			accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", forwardAuthClient, nil, requestScopes, nil, "", test.authCode, nil)
 
 			assertTokenResponse(t, accessTokenResponse, test, forwardAuthClient)
 		})
@@ -166,9 +178,9 @@ func Test_InvalidUserInToken(t *testing.T) {
 	}
 
 	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
-	accessTokenResponse := tokenManager.CreateAccessTokenResponse(request, "bar", client, nil, []string{"abc", "def"}, nil, "", "")
+	accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "bar", client, nil, []string{"abc", "def"}, nil, "", "", nil)
 
-	_, valid := tokenManager.validateAccessTokenHeader(fmt.Sprintf("%s %s", internalHttp.AuthBearer, accessTokenResponse.AccessTokenValue))
+	_, valid := tokenManager.validateAccessTokenHeader(httptest.NewRequest(http.MethodGet, endpoint.Health, nil), fmt.Sprintf("%s %s", internalHttp.AuthBearer, accessTokenResponse.AccessTokenValue))
 
 	if valid {
 		t.Error("should not be valid")
@@ -180,7 +192,7 @@ func Test_ValidAccessToken(t *testing.T) {
 		createTestConfig(t, false, 0, 0, "")
 		tokenManager := GetTokenManagerInstance()
 
-		_, valid := tokenManager.validateAccessTokenHeader("foooo")
+		_, valid := tokenManager.validateAccessTokenHeader(httptest.NewRequest(http.MethodGet, endpoint.Health, nil), "foooo")
 
 		if valid {
 			t.Error("should not be valid")
@@ -191,7 +203,7 @@ func Test_ValidAccessToken(t *testing.T) {
 		createTestConfig(t, false, 0, 0, "")
 		tokenManager := GetTokenManagerInstance()
 
-		_, valid := tokenManager.validateAccessTokenHeader(fmt.Sprintf("%s %s", internalHttp.AuthBearer, "foo"))
+		_, valid := tokenManager.validateAccessTokenHeader(httptest.NewRequest(http.MethodGet, endpoint.Health, nil), fmt.Sprintf("%s %s", internalHttp.AuthBearer, "foo"))
 
 		if valid {
 			t.Error("should not be valid")
@@ -199,6 +211,570 @@ func Test_ValidAccessToken(t *testing.T) {
 	})
 }
 
+func Test_RegisteredClientStores(t *testing.T) {
+	createTestConfig(t, false, 0, 0, "")
+	tokenManager := GetTokenManagerInstance()
+	client := &config.Client{
+		Id:        uuid.NewString(),
+		Redirects: []string{"https://example.com/callback"},
+	}
+
+	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+	accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{"abc"}, nil, "", "", nil)
+
+	if _, accessTokenExists := tokenManager.GetAccessToken(context.Background(), accessTokenResponse.AccessTokenValue); !accessTokenExists {
+		t.Error("access token of registered client should exist")
+	}
+
+	tokenManager.DeleteClientStores(client.Id)
+
+	if _, accessTokenExists := tokenManager.GetAccessToken(context.Background(), accessTokenResponse.AccessTokenValue); accessTokenExists {
+		t.Error("access token of deleted client should not exist")
+	}
+}
+
+func Test_ExchangedAccessTokenResponse(t *testing.T) {
+	type exchangeParameter struct {
+		name              string
+		expiresAt         time.Time
+		actor             *oauth2.Actor
+		expectedExpiresIn int
+	}
+
+	var exchangeParameters = []exchangeParameter{
+		{"without actor", time.Time{}, nil, 300},
+		{"with actor", time.Time{}, &oauth2.Actor{Subject: "gateway", ClientId: "gateway"}, 300},
+		{"with subject expiry", time.Now().Add(time.Minute), nil, 59},
+	}
+
+	for _, test := range exchangeParameters {
+		testMessage := fmt.Sprintf("Exchanged access token %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			testConfig := createTestConfig(t, false, 100, 0, "")
+			tokenManager := GetTokenManagerInstance()
+			client, clientExists := testConfig.GetClient("foo")
+			if !clientExists {
+				t.Fatal("client does not exist")
+			}
+
+			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+			exchangeInput := ExchangeInput{
+				Username:  "foo",
+				Scopes:    []string{"abc"},
+				Audience:  []string{"https://api.example.com"},
+				Actor:     test.actor,
+				ExpiresAt: test.expiresAt,
+			}
+			accessTokenResponse := tokenManager.CreateExchangedAccessTokenResponse(request, client, exchangeInput)
+
+			if accessTokenResponse.IssuedTokenType != oauth2.TtiAccessToken || accessTokenResponse.Scope != "abc" || accessTokenResponse.RefreshTokenValue != "" {
+				t.Errorf("unexpected response %v", accessTokenResponse)
+			}
+
+			if accessTokenResponse.ExpiresIn < test.expectedExpiresIn-1 || accessTokenResponse.ExpiresIn > test.expectedExpiresIn {
+				t.Errorf("expected expires in to be %d, got %d", test.expectedExpiresIn, accessTokenResponse.ExpiresIn)
+			}
+
+			parsedToken, parseError := jwt.ParseInsecure([]byte(accessTokenResponse.AccessTokenValue))
+			if parseError != nil {
+				t.Fatal(parseError)
+			}
+
+			if !reflect.DeepEqual(parsedToken.Audience(), exchangeInput.Audience) {
+				t.Errorf("expected audience to be %v, got %v", exchangeInput.Audience, parsedToken.Audience())
+			}
+
+			act, actExists := parsedToken.Get(oauth2.ClaimActor)
+			if test.actor == nil && actExists {
+				t.Errorf("act claim should not exist, %v", act)
+			}
+			if test.actor != nil {
+				actClaim, valid := act.(map[string]any)
+				if !actExists || !valid || actClaim["sub"] != test.actor.Subject {
+					t.Errorf("act claim did not match, %v", act)
+				}
+			}
+		})
+	}
+}
+
+func Test_ResourceAccessTokenResponse(t *testing.T) {
+	type resourceParameter struct {
+		name              string
+		grantInput        *GrantInput
+		expectedAudience  []string
+		expectedScopes    []string
+		expectedScope     string
+		expectedExpiresIn int
+		resourceKey       bool
+	}
+
+	apiResource := "https://api.example.com"
+	otherResource := "https://other.example.com"
+	requestScopes := []string{"abc", "def"}
+
+	var resourceParameters = []resourceParameter{
+		{"without resource", nil, []string{"all"}, requestScopes, "", 300, false},
+		{"granted resource", &GrantInput{Resources: []string{apiResource}}, []string{apiResource}, []string{"abc"}, "abc", 120, true},
+		{"requested resource", &GrantInput{Resources: []string{apiResource, otherResource}, RequestedResources: []string{otherResource}}, []string{otherResource}, requestScopes, "", 300, false},
+		{"multiple resources", &GrantInput{Resources: []string{apiResource, otherResource}}, []string{apiResource, otherResource}, requestScopes, "", 120, false},
+		{"down-scoped resource", &GrantInput{Resources: []string{otherResource}, Scopes: []string{"def"}}, []string{otherResource}, []string{"def"}, "def", 300, false},
+	}
+
+	for _, test := range resourceParameters {
+		testMessage := fmt.Sprintf("Resource access token %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			testConfig := createTestConfig(t, false, 100, 0, "../../../.test_files/ecdsa521key.pem")
+			tokenManager := GetTokenManagerInstance()
+			client, clientExists := testConfig.GetClient("foo")
+			if !clientExists {
+				t.Fatal("client does not exist")
+			}
+
+			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+			accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, requestScopes, nil, "", "", test.grantInput)
+
+			if accessTokenResponse.Scope != test.expectedScope || accessTokenResponse.ExpiresIn != test.expectedExpiresIn {
+				t.Errorf("unexpected response %v", accessTokenResponse)
+			}
+
+			parsedToken, parseError := jwt.ParseInsecure([]byte(accessTokenResponse.AccessTokenValue))
+			if parseError != nil {
+				t.Fatal(parseError)
+			}
+
+			if !reflect.DeepEqual(parsedToken.Audience(), test.expectedAudience) {
+				t.Errorf("expected audience to be %v, got %v", test.expectedAudience, parsedToken.Audience())
+			}
+
+			accessToken, accessTokenExists := tokenManager.GetAccessToken(context.Background(), accessTokenResponse.AccessTokenValue)
+			if !accessTokenExists || !reflect.DeepEqual(accessToken.Scopes, test.expectedScopes) {
+				t.Errorf("expected access token scopes to be %v, got %v", test.expectedScopes, accessToken)
+			}
+
+			resource, _ := testConfig.GetResource(apiResource)
+			managedKey, _ := tokenManager.keyLoader.LoadResourceKeys(resource)
+			publicKey, publicKeyError := jwk.PublicKeyOf(*managedKey.Key)
+			if publicKeyError != nil {
+				t.Fatal(publicKeyError)
+			}
+			_, verifyError := jwt.Parse([]byte(accessTokenResponse.AccessTokenValue), jwt.WithKey(publicKey.Algorithm(), publicKey))
+			if test.resourceKey != (verifyError == nil) {
+				t.Errorf("expected resource key signature to be %v, %v", test.resourceKey, verifyError)
+			}
+
+			refreshToken, refreshTokenExists := tokenManager.GetRefreshToken(context.Background(), accessTokenResponse.RefreshTokenValue)
+			if !refreshTokenExists || !reflect.DeepEqual(refreshToken.Scopes, requestScopes) {
+				t.Fatalf("expected refresh token with scopes %v, got %v", requestScopes, refreshToken)
+			}
+
+			if test.grantInput != nil && !reflect.DeepEqual(refreshToken.Resources, test.grantInput.Resources) {
+				t.Errorf("expected refresh token resources to be %v, got %v", test.grantInput.Resources, refreshToken.Resources)
+			}
+		})
+	}
+}
+
+func Test_AccessTokenProfile(t *testing.T) {
+	type profileParameter struct {
+		name             string
+		clientId         string
+		username         string
+		authTime         *time.Time
+		expectedType     string
+		expectedSubject  string
+		expectedClientId bool
+		expectedAuthTime bool
+	}
+
+	authTime := time.Now().Add(-time.Minute)
+
+	var profileParameters = []profileParameter{
+		{"user", "foo", "foo", &authTime, oauth2.JwtTypeAccessToken, "foo", true, true},
+		{"without user", "foo", "", nil, oauth2.JwtTypeAccessToken, "foo", true, false},
+		{"legacy", "legacy", "foo", &authTime, "JWT", "foo", false, false},
+	}
+
+	for _, test := range profileParameters {
+		testMessage := fmt.Sprintf("Access token profile %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			testConfig := createTestConfig(t, false, 100, 0, "")
+			tokenManager := GetTokenManagerInstance()
+			client, clientExists := testConfig.GetClient(test.clientId)
+			if !clientExists {
+				t.Fatal("client does not exist")
+			}
+
+			var grantInput *GrantInput
+			if test.authTime != nil {
+				grantInput = &GrantInput{Amr: []string{config.AuthenticationMethodPassword}}
+			}
+
+			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+			accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, test.username, client, test.authTime, []string{"abc", "def"}, nil, "", "", grantInput)
+
+			message, parseError := jws.Parse([]byte(accessTokenResponse.AccessTokenValue))
+			if parseError != nil {
+				t.Fatal(parseError)
+			}
+
+			tokenType := message.Signatures()[0].ProtectedHeaders().Type()
+			if tokenType != test.expectedType {
+				t.Errorf("expected typ header %s, got %s", test.expectedType, tokenType)
+			}
+
+			parsedToken, parseError := jwt.ParseInsecure([]byte(accessTokenResponse.AccessTokenValue))
+			if parseError != nil {
+				t.Fatal(parseError)
+			}
+
+			if parsedToken.Subject() != test.expectedSubject || parsedToken.JwtID() == "" {
+				t.Errorf("expected subject %s and jti, got %s %s", test.expectedSubject, parsedToken.Subject(), parsedToken.JwtID())
+			}
+
+			clientId, clientIdExists := parsedToken.Get(oauth2.ClaimClientId)
+			if clientIdExists != test.expectedClientId || (clientIdExists && clientId != test.clientId) {
+				t.Errorf("unexpected client_id claim %v", clientId)
+			}
+
+			scope, scopeExists := parsedToken.Get(oauth2.ClaimScope)
+			if scopeExists != test.expectedClientId || (scopeExists && scope != "abc def") {
+				t.Errorf("unexpected scope claim %v", scope)
+			}
+
+			_, authTimeExists := parsedToken.Get(oidc.ClaimAuthTime)
+			if authTimeExists != test.expectedAuthTime {
+				t.Errorf("expected auth_time claim to be %v", test.expectedAuthTime)
+			}
+
+			_, amrExists := parsedToken.Get(oidc.ClaimAmr)
+			if amrExists != test.expectedAuthTime {
+				t.Errorf("expected amr claim to be %v", test.expectedAuthTime)
+			}
+		})
+	}
+}
+
+func Test_DPoPAccessToken(t *testing.T) {
+	testConfig := createTestConfig(t, false, 100, 0, "")
+	tokenManager := GetTokenManagerInstance()
+	client, clientExists := testConfig.GetClient("foo")
+	if !clientExists {
+		t.Fatal("client does not exist")
+	}
+
+	jwkThumbprint := testJwkThumbprint(t, "ecdsa256key.pem")
+	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+	accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{"abc"}, nil, "", "", &GrantInput{JwkThumbprint: jwkThumbprint})
+
+	if accessTokenResponse.TokenType != oauth2.TtDPoP {
+		t.Errorf("expected token type %s, got %s", oauth2.TtDPoP, accessTokenResponse.TokenType)
+	}
+
+	parsedToken, parseError := jwt.ParseInsecure([]byte(accessTokenResponse.AccessTokenValue))
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+
+	confirmation, confirmationExists := parsedToken.Get(oauth2.ClaimConfirmation)
+	if !confirmationExists || !reflect.DeepEqual(confirmation, map[string]any{"jkt": jwkThumbprint}) {
+		t.Errorf("unexpected cnf claim %v", confirmation)
+	}
+
+	refreshToken, refreshTokenExists := tokenManager.GetRefreshToken(context.Background(), accessTokenResponse.RefreshTokenValue)
+	if !refreshTokenExists || refreshToken.Confirmation == nil || refreshToken.Confirmation.JwkThumbprint != jwkThumbprint {
+		t.Errorf("expected refresh token to be bound to %s", jwkThumbprint)
+	}
+
+	type dpopParameter struct {
+		name    string
+		scheme  string
+		keyFile string
+		valid   bool
+	}
+
+	var dpopParameters = []dpopParameter{
+		{"with DPoP proof", internalHttp.AuthDPoP, "ecdsa256key.pem", true},
+		{"with DPoP proof of other key", internalHttp.AuthDPoP, "ecdsa384key.pem", false},
+		{"with bearer scheme", internalHttp.AuthBearer, "ecdsa256key.pem", false},
+		{"without DPoP proof", internalHttp.AuthDPoP, "", false},
+	}
+
+	for _, test := range dpopParameters {
+		testMessage := fmt.Sprintf("DPoP access token %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			resourceRequest := httptest.NewRequest(http.MethodGet, "http://example.com"+endpoint.Health, nil)
+			resourceRequest.Header.Set(internalHttp.Authorization, fmt.Sprintf("%s %s", test.scheme, accessTokenResponse.AccessTokenValue))
+			if test.keyFile != "" {
+				proof := testCreateDPoPProof(t, test.keyFile, http.MethodGet, "http://example.com"+endpoint.Health, accessTokenResponse.AccessTokenValue)
+				resourceRequest.Header.Set(internalHttp.DPoP, proof)
+			}
+
+			_, valid := tokenManager.ValidateAccessTokenRequest(resourceRequest)
+
+			if valid != test.valid {
+				t.Errorf("expected access token request valid to be %v", test.valid)
+			}
+		})
+	}
+}
+
+func Test_CertificateBoundAccessToken(t *testing.T) {
+	testConfig := createTestConfig(t, false, 100, 0, "")
+	tokenManager := GetTokenManagerInstance()
+	client, clientExists := testConfig.GetClient("foo")
+	if !clientExists {
+		t.Fatal("client does not exist")
+	}
+
+	certificate, loadError := crypto.LoadCertificate("../../../.test_files/server.crt")
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+	certificateThumbprint := crypto.CertificateThumbprint(certificate)
+
+	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+	accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{"abc"}, nil, "", "", &GrantInput{CertificateThumbprint: certificateThumbprint})
+
+	if accessTokenResponse.TokenType != oauth2.TtBearer {
+		t.Errorf("expected token type %s, got %s", oauth2.TtBearer, accessTokenResponse.TokenType)
+	}
+
+	parsedToken, parseError := jwt.ParseInsecure([]byte(accessTokenResponse.AccessTokenValue))
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+
+	confirmation, confirmationExists := parsedToken.Get(oauth2.ClaimConfirmation)
+	if !confirmationExists || !reflect.DeepEqual(confirmation, map[string]any{"x5t#S256": certificateThumbprint}) {
+		t.Errorf("unexpected cnf claim %v", confirmation)
+	}
+
+	refreshToken, refreshTokenExists := tokenManager.GetRefreshToken(context.Background(), accessTokenResponse.RefreshTokenValue)
+	if !refreshTokenExists || refreshToken.Confirmation != nil {
+		t.Error("expected refresh token not to be bound to the certificate")
+	}
+
+	otherCertificate := *certificate
+	otherCertificate.Raw = []byte("other")
+
+	type certificateParameter struct {
+		name        string
+		certificate *x509.Certificate
+		valid       bool
+	}
+
+	var certificateParameters = []certificateParameter{
+		{"with bound certificate", certificate, true},
+		{"with other certificate", &otherCertificate, false},
+		{"without certificate", nil, false},
+	}
+
+	for _, test := range certificateParameters {
+		testMessage := fmt.Sprintf("Certificate bound access token %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			resourceRequest := httptest.NewRequest(http.MethodGet, endpoint.Health, nil)
+			resourceRequest.Header.Set(internalHttp.Authorization, fmt.Sprintf("%s %s", internalHttp.AuthBearer, accessTokenResponse.AccessTokenValue))
+			if test.certificate != nil {
+				resourceRequest.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{test.certificate}}
+			}
+
+			_, valid := tokenManager.ValidateAccessTokenRequest(resourceRequest)
+
+			if valid != test.valid {
+				t.Errorf("expected access token request valid to be %v", test.valid)
+			}
+		})
+	}
+}
+
+type encryptionKeyLoader struct {
+	crypto.KeyLoader
+	encryptionKey *crypto.EncryptionKey
+}
+
+func (loader *encryptionKeyLoader) LoadEncryptionKey(_ *config.Client) (*crypto.EncryptionKey, bool) {
+	return loader.encryptionKey, true
+}
+
+func Test_EncryptedIdToken(t *testing.T) {
+	testConfig := createTestConfig(t, false, 0, 100, "")
+	client, clientExists := testConfig.GetClient("foo")
+	if !clientExists {
+		t.Fatal("client does not exist")
+	}
+	user, userExists := testConfig.GetUser("foo")
+	if !userExists {
+		t.Fatal("user does not exist")
+	}
+
+	encryptionKey, encryptionKeyError := crypto.NewEncryptionKey(&config.ClientEncryption{Key: "../../../.test_files/rsa256pub.pem"})
+	if encryptionKeyError != nil {
+		t.Fatal(encryptionKeyError)
+	}
+
+	keyLoader := key.GetDefaultKeyLoaderInstance()
+	tokenManager := &Manager{
+		config:    testConfig,
+		keyLoader: &encryptionKeyLoader{KeyLoader: keyLoader, encryptionKey: encryptionKey},
+	}
+
+	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+	idToken, idTokenError := tokenManager.generateIdToken(internalHttp.NewRequestData(request), IdTokenInput{
+		User:   user,
+		Client: client,
+		Scopes: []string{oidc.ScopeOpenId},
+		Nonce:  "abc",
+	})
+	if idTokenError != nil {
+		t.Fatal(idTokenError)
+	}
+
+	message, parseError := jwe.Parse([]byte(idToken))
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+
+	if message.ProtectedHeaders().Algorithm() != jwa.RSA_OAEP || message.ProtectedHeaders().ContentEncryption() != jwa.A256GCM || message.ProtectedHeaders().ContentType() != "JWT" {
+		t.Errorf("unexpected encryption headers %v", message.ProtectedHeaders())
+	}
+
+	privateKey, privateKeyError := crypto.LoadPrivateKey("../../../.test_files/rsa256key.pem")
+	if privateKeyError != nil {
+		t.Fatal(privateKeyError)
+	}
+
+	signedIdToken, decryptError := jwe.Decrypt([]byte(idToken), jwe.WithKey(jwa.RSA_OAEP, privateKey.PrivateKey))
+	if decryptError != nil {
+		t.Fatal(decryptError)
+	}
+
+	parsedIdToken, idTokenParseError := jwt.Parse(signedIdToken, keyLoader.GetServerKey())
+	if idTokenParseError != nil {
+		t.Fatal(idTokenParseError)
+	}
+
+	if parsedIdToken.Subject() != "foo" {
+		t.Errorf("expected subject to be 'foo', got %s", parsedIdToken.Subject())
+	}
+}
+
+func Test_EncryptedIdTokenError(t *testing.T) {
+	testConfig := createTestConfig(t, false, 0, 100, "")
+	client, _ := testConfig.GetClient("foo")
+	user, _ := testConfig.GetUser("foo")
+
+	encryptionKey, encryptionKeyError := crypto.NewEncryptionKey(&config.ClientEncryption{Key: "../../../.test_files/rsa256pub.pem"})
+	if encryptionKeyError != nil {
+		t.Fatal(encryptionKeyError)
+	}
+	encryptionKey.KeyEncryptionAlgorithm = jwa.ECDH_ES
+
+	tokenManager := &Manager{
+		config:    testConfig,
+		keyLoader: &encryptionKeyLoader{KeyLoader: key.GetDefaultKeyLoaderInstance(), encryptionKey: encryptionKey},
+	}
+
+	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+	_, idTokenError := tokenManager.generateIdToken(internalHttp.NewRequestData(request), IdTokenInput{
+		User:   user,
+		Client: client,
+		Scopes: []string{oidc.ScopeOpenId},
+	})
+	if idTokenError == nil {
+		t.Error("expected error when encryption fails")
+	}
+}
+
+func Test_AuthenticationContextClaims(t *testing.T) {
+	testConfig := createTestConfig(t, false, 100, 100, "")
+	tokenManager := GetTokenManagerInstance()
+	client, clientExists := testConfig.GetClient("foo")
+	if !clientExists {
+		t.Fatal("client does not exist")
+	}
+
+	amr := []string{config.AuthenticationMethodPassword, config.AuthenticationMethodOtp}
+	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+	accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{oidc.ScopeOpenId, oidc.ScopeOfflineAccess}, nil, "", "", &GrantInput{Acr: "password+otp", Amr: amr, OfflineAccess: true})
+
+	for _, tokenValue := range []string{accessTokenResponse.AccessTokenValue, accessTokenResponse.IdTokenValue} {
+		parsedToken, parseError := jwt.ParseInsecure([]byte(tokenValue))
+		if parseError != nil {
+			t.Fatal(parseError)
+		}
+
+		acr, acrExists := parsedToken.Get(oidc.ClaimAcr)
+		if !acrExists || acr != "password+otp" {
+			t.Errorf("expected acr claim password+otp, got %v", acr)
+		}
+
+		tokenAmr, amrExists := parsedToken.Get(oidc.ClaimAmr)
+		if !amrExists || !reflect.DeepEqual(tokenAmr, []any{config.AuthenticationMethodPassword, config.AuthenticationMethodOtp}) {
+			t.Errorf("expected amr claim %v, got %v", amr, tokenAmr)
+		}
+	}
+
+	refreshToken, refreshTokenExists := tokenManager.GetRefreshToken(context.Background(), accessTokenResponse.RefreshTokenValue)
+	if !refreshTokenExists || refreshToken.Acr != "password+otp" || !reflect.DeepEqual(refreshToken.Amr, amr) {
+		t.Errorf("expected refresh token to keep acr and amr, got %v", refreshToken)
+	}
+}
+
+func Test_ParseIdTokenHint(t *testing.T) {
+	testConfig := createTestConfig(t, false, 0, 100, "")
+	tokenManager := GetTokenManagerInstance()
+	client, clientExists := testConfig.GetClient("foo")
+	if !clientExists {
+		t.Fatal("client does not exist")
+	}
+	legacyClient, legacyClientExists := testConfig.GetClient("legacy")
+	if !legacyClientExists {
+		t.Fatal("client does not exist")
+	}
+
+	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+	accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{oidc.ScopeOpenId}, nil, "", "", nil)
+	idTokenValue := accessTokenResponse.IdTokenValue
+
+	otherIssuerRequest := httptest.NewRequest(http.MethodGet, "https://other.example.com"+endpoint.Authorization, nil)
+	otherIssuerResponse, _ := tokenManager.CreateAccessTokenResponse(otherIssuerRequest, "foo", client, nil, []string{oidc.ScopeOpenId}, nil, "", "", nil)
+	if otherIssuerResponse.IdTokenValue == "" {
+		t.Fatal("expected ID token for other issuer")
+	}
+
+	type parameter struct {
+		name          string
+		client        *config.Client
+		idTokenHint   string
+		expectedValid bool
+	}
+
+	var parameters = []parameter{
+		{"valid", client, idTokenValue, true},
+		{"tampered", client, idTokenValue[:len(idTokenValue)-4] + "abcd", false},
+		{"other audience", legacyClient, idTokenValue, false},
+		{"other issuer", client, otherIssuerResponse.IdTokenValue, false},
+		{"no token", client, "foo", false},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("ID token hint %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			hintRequest := httptest.NewRequest(http.MethodGet, endpoint.Authorization, nil)
+			idToken, valid := tokenManager.ParseIdTokenHint(hintRequest, test.client, test.idTokenHint)
+
+			if valid != test.expectedValid {
+				t.Fatalf("expected ID token hint valid to be %v", test.expectedValid)
+			}
+
+			if valid && idToken.Subject() != "foo" {
+				t.Errorf("expected subject to be 'foo', got %s", idToken.Subject())
+			}
+		})
+	}
+}
+
 func Test_HashToken(t *testing.T) {
 	type hashTokenParameter struct {
 		token         string
@@ -225,7 +801,7 @@ func Test_HashToken(t *testing.T) {
 }
 
 func assertAuthorizationHeader(t *testing.T, tokenManager *Manager, authorizationHeader string, requestScopes []string) {
-	validAccessToken, valid := tokenManager.validateAccessTokenHeader(authorizationHeader)
+	validAccessToken, valid := tokenManager.validateAccessTokenHeader(httptest.NewRequest(http.MethodGet, endpoint.Health, nil), authorizationHeader)
 	if !valid {
 		t.Error("user does not exist")
 	}
@@ -302,6 +878,22 @@ func createTestConfig(t *testing.T, opaque bool, refreshTokenTTL int, idTTokenTT
 				Oidc:         isOidc,
 				PrivateKey:   keyPath,
 			},
+			{
+				Id:                "legacy",
+				ClientSecret:      "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				LegacyAccessToken: true,
+			},
+		},
+		Resources: []config.Resource{
+			{
+				Id:         "https://api.example.com",
+				Scopes:     []string{"abc"},
+				AccessTTL:  2,
+				PrivateKey: "../../../.test_files/ecdsa384key.pem",
+			},
+			{
+				Id: "https://other.example.com",
+			},
 		},
 		Users: []config.User{
 			{
@@ -329,3 +921,58 @@ func createTestConfig(t *testing.T, opaque bool, refreshTokenTTL int, idTTokenTT
 
 	return testConfig
 }
+
+func testJwkThumbprint(t *testing.T, keyFile string) string {
+	publicKey := testDPoPPublicKey(t, keyFile)
+	thumbprint, thumbprintError := publicKey.Thumbprint(gocrypto.SHA256)
+	if thumbprintError != nil {
+		t.Fatal(thumbprintError)
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint)
+}
+
+func testDPoPPublicKey(t *testing.T, keyFile string) jwk.Key {
+	signingPrivateKey, loadError := crypto.LoadPrivateKey("../../../.test_files/" + keyFile)
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+	publicKey, keyError := jwk.PublicRawKeyOf(signingPrivateKey.PrivateKey)
+	if keyError != nil {
+		t.Fatal(keyError)
+	}
+	key, keyError := jwk.FromRaw(publicKey)
+	if keyError != nil {
+		t.Fatal(keyError)
+	}
+	return key
+}
+
+func testCreateDPoPProof(t *testing.T, keyFile string, method string, uri string, accessToken string) string {
+	signingPrivateKey, loadError := crypto.LoadPrivateKey("../../../.test_files/" + keyFile)
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+
+	headers := jws.NewHeaders()
+	_ = headers.Set(jws.TypeKey, "dpop+jwt")
+	_ = headers.Set(jws.JWKKey, testDPoPPublicKey(t, keyFile))
+
+	accessTokenHash := sha256.Sum256([]byte(accessToken))
+	token, buildError := jwt.NewBuilder().
+		IssuedAt(time.Now()).
+		JwtID(uuid.NewString()).
+		Claim("htm", method).
+		Claim("htu", uri).
+		Claim("ath", base64.RawURLEncoding.EncodeToString(accessTokenHash[:])).
+		Build()
+	if buildError != nil {
+		t.Fatal(buildError)
+	}
+
+	signedToken, signError := jwt.Sign(token, jwt.WithKey(signingPrivateKey.SignatureAlgorithm, signingPrivateKey.PrivateKey, jws.WithProtectedHeaders(headers)))
+	if signError != nil {
+		t.Fatal(signError)
+	}
+
+	return string(signedToken)
+}
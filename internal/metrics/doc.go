@@ -0,0 +1,3 @@
+// Package metrics implements a small registry for counters, gauges and histograms
+// which can be exposed in the Prometheus text exposition format.
+package metrics
@@ -0,0 +1,359 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the default histogram buckets in seconds, same as used by Prometheus client libraries.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metricType string
+
+const (
+	counterType   metricType = "counter"
+	gaugeType     metricType = "gauge"
+	histogramType metricType = "histogram"
+)
+
+type collector interface {
+	describe() (string, string, metricType)
+	write(w io.Writer) error
+}
+
+// Registry holds all registered metrics and writes them in the Prometheus text exposition format.
+type Registry struct {
+	collectors []collector
+	mux        *sync.RWMutex
+}
+
+// CounterVec defines a counter metric partitioned by label values.
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+	values     map[string]*counterValue
+	mux        *sync.RWMutex
+}
+
+type counterValue struct {
+	labelValues []string
+	value       float64
+}
+
+// GaugeVec defines a gauge metric partitioned by label values, each value is provided by a function.
+type GaugeVec struct {
+	name       string
+	help       string
+	labelNames []string
+	values     map[string]*gaugeValue
+	mux        *sync.RWMutex
+}
+
+type gaugeValue struct {
+	labelValues []string
+	value       func() float64
+}
+
+// HistogramVec defines a histogram metric partitioned by label values.
+type HistogramVec struct {
+	name       string
+	help       string
+	labelNames []string
+	buckets    []float64
+	values     map[string]*histogramValue
+	mux        *sync.RWMutex
+}
+
+type histogramValue struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+var registryLock = &sync.Mutex{}
+var registrySingleton *Registry
+
+// GetRegistryInstance returns the Registry used by STOPnik.
+func GetRegistryInstance() *Registry {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if registrySingleton == nil {
+		registrySingleton = NewRegistry()
+	}
+	return registrySingleton
+}
+
+// NewRegistry creates a new and empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make([]collector, 0),
+		mux:        &sync.RWMutex{},
+	}
+}
+
+// NewCounterVec creates and registers a new CounterVec.
+func (registry *Registry) NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	counterVec := &CounterVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		values:     make(map[string]*counterValue),
+		mux:        &sync.RWMutex{},
+	}
+	registry.register(counterVec)
+	return counterVec
+}
+
+// NewGaugeVec creates and registers a new GaugeVec.
+func (registry *Registry) NewGaugeVec(name string, help string, labelNames ...string) *GaugeVec {
+	gaugeVec := &GaugeVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		values:     make(map[string]*gaugeValue),
+		mux:        &sync.RWMutex{},
+	}
+	registry.register(gaugeVec)
+	return gaugeVec
+}
+
+// NewHistogramVec creates and registers a new HistogramVec with the given buckets.
+func (registry *Registry) NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	sortedBuckets := slices.Clone(buckets)
+	slices.Sort(sortedBuckets)
+	histogramVec := &HistogramVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		buckets:    sortedBuckets,
+		values:     make(map[string]*histogramValue),
+		mux:        &sync.RWMutex{},
+	}
+	registry.register(histogramVec)
+	return histogramVec
+}
+
+// Write writes all registered metrics in the Prometheus text exposition format.
+func (registry *Registry) Write(w io.Writer) error {
+	registry.mux.RLock()
+	defer registry.mux.RUnlock()
+	for _, current := range registry.collectors {
+		name, help, currentType := current.describe()
+		if _, writeError := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, currentType); writeError != nil {
+			return writeError
+		}
+		if writeError := current.write(w); writeError != nil {
+			return writeError
+		}
+	}
+	return nil
+}
+
+func (registry *Registry) register(c collector) {
+	registry.mux.Lock()
+	defer registry.mux.Unlock()
+	registry.collectors = append(registry.collectors, c)
+}
+
+// Inc increments the counter for the given label values by one.
+func (counterVec *CounterVec) Inc(labelValues ...string) {
+	counterVec.Add(1, labelValues...)
+}
+
+// Add adds the given value to the counter for the given label values. Negative values are ignored.
+func (counterVec *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 || len(labelValues) != len(counterVec.labelNames) {
+		return
+	}
+	counterVec.mux.Lock()
+	defer counterVec.mux.Unlock()
+	key := labelKey(labelValues)
+	current, exists := counterVec.values[key]
+	if !exists {
+		current = &counterValue{labelValues: slices.Clone(labelValues)}
+		counterVec.values[key] = current
+	}
+	current.value += value
+}
+
+// Value returns the current value of the counter for the given label values.
+func (counterVec *CounterVec) Value(labelValues ...string) float64 {
+	counterVec.mux.RLock()
+	defer counterVec.mux.RUnlock()
+	current, exists := counterVec.values[labelKey(labelValues)]
+	if !exists {
+		return 0
+	}
+	return current.value
+}
+
+func (counterVec *CounterVec) describe() (string, string, metricType) {
+	return counterVec.name, counterVec.help, counterType
+}
+
+func (counterVec *CounterVec) write(w io.Writer) error {
+	counterVec.mux.RLock()
+	defer counterVec.mux.RUnlock()
+	for _, key := range sortedKeys(counterVec.values) {
+		current := counterVec.values[key]
+		labels := formatLabels(counterVec.labelNames, current.labelValues)
+		if _, writeError := fmt.Fprintf(w, "%s%s %s\n", counterVec.name, labels, formatValue(current.value)); writeError != nil {
+			return writeError
+		}
+	}
+	return nil
+}
+
+// SetFunc sets the function which provides the gauge value for the given label values.
+func (gaugeVec *GaugeVec) SetFunc(value func() float64, labelValues ...string) {
+	if value == nil || len(labelValues) != len(gaugeVec.labelNames) {
+		return
+	}
+	gaugeVec.mux.Lock()
+	defer gaugeVec.mux.Unlock()
+	gaugeVec.values[labelKey(labelValues)] = &gaugeValue{labelValues: slices.Clone(labelValues), value: value}
+}
+
+// Value returns the current value of the gauge for the given label values.
+func (gaugeVec *GaugeVec) Value(labelValues ...string) float64 {
+	gaugeVec.mux.RLock()
+	defer gaugeVec.mux.RUnlock()
+	current, exists := gaugeVec.values[labelKey(labelValues)]
+	if !exists {
+		return 0
+	}
+	return current.value()
+}
+
+func (gaugeVec *GaugeVec) describe() (string, string, metricType) {
+	return gaugeVec.name, gaugeVec.help, gaugeType
+}
+
+func (gaugeVec *GaugeVec) write(w io.Writer) error {
+	gaugeVec.mux.RLock()
+	defer gaugeVec.mux.RUnlock()
+	for _, key := range sortedKeys(gaugeVec.values) {
+		current := gaugeVec.values[key]
+		labels := formatLabels(gaugeVec.labelNames, current.labelValues)
+		if _, writeError := fmt.Fprintf(w, "%s%s %s\n", gaugeVec.name, labels, formatValue(current.value())); writeError != nil {
+			return writeError
+		}
+	}
+	return nil
+}
+
+// Observe adds a single observation to the histogram for the given label values.
+func (histogramVec *HistogramVec) Observe(value float64, labelValues ...string) {
+	if len(labelValues) != len(histogramVec.labelNames) {
+		return
+	}
+	histogramVec.mux.Lock()
+	defer histogramVec.mux.Unlock()
+	key := labelKey(labelValues)
+	current, exists := histogramVec.values[key]
+	if !exists {
+		current = &histogramValue{labelValues: slices.Clone(labelValues), counts: make([]uint64, len(histogramVec.buckets))}
+		histogramVec.values[key] = current
+	}
+	for index, upperBound := range histogramVec.buckets {
+		if value <= upperBound {
+			current.counts[index]++
+		}
+	}
+	current.count++
+	current.sum += value
+}
+
+// Count returns the number of observations for the given label values.
+func (histogramVec *HistogramVec) Count(labelValues ...string) uint64 {
+	histogramVec.mux.RLock()
+	defer histogramVec.mux.RUnlock()
+	current, exists := histogramVec.values[labelKey(labelValues)]
+	if !exists {
+		return 0
+	}
+	return current.count
+}
+
+func (histogramVec *HistogramVec) describe() (string, string, metricType) {
+	return histogramVec.name, histogramVec.help, histogramType
+}
+
+func (histogramVec *HistogramVec) write(w io.Writer) error {
+	histogramVec.mux.RLock()
+	defer histogramVec.mux.RUnlock()
+	bucketLabelNames := append(slices.Clone(histogramVec.labelNames), "le")
+	for _, key := range sortedKeys(histogramVec.values) {
+		current := histogramVec.values[key]
+		for index, upperBound := range histogramVec.buckets {
+			labels := formatLabels(bucketLabelNames, append(slices.Clone(current.labelValues), formatValue(upperBound)))
+			if _, writeError := fmt.Fprintf(w, "%s_bucket%s %d\n", histogramVec.name, labels, current.counts[index]); writeError != nil {
+				return writeError
+			}
+		}
+		infLabels := formatLabels(bucketLabelNames, append(slices.Clone(current.labelValues), "+Inf"))
+		labels := formatLabels(histogramVec.labelNames, current.labelValues)
+		if _, writeError := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+			histogramVec.name, infLabels, current.count,
+			histogramVec.name, labels, formatValue(current.sum),
+			histogramVec.name, labels, current.count); writeError != nil {
+			return writeError
+		}
+	}
+	return nil
+}
+
+func labelKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func sortedKeys[T any](values map[string]T) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func formatLabels(labelNames []string, labelValues []string) string {
+	if len(labelNames) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labelNames))
+	for index, labelName := range labelNames {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labelName, escapeLabelValue(labelValues[index])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(value)
+}
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_Counter(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounterVec("test_total", "A test counter.", "foo", "bar")
+
+	counter.Inc("a", "b")
+	counter.Inc("a", "b")
+	counter.Add(3, "a", "c")
+	counter.Add(-1, "a", "c")
+	counter.Inc("a")
+
+	if counter.Value("a", "b") != 2 {
+		t.Errorf("counter value should be 2, but was %v", counter.Value("a", "b"))
+	}
+
+	if counter.Value("a", "c") != 3 {
+		t.Errorf("counter value should be 3, but was %v", counter.Value("a", "c"))
+	}
+
+	if counter.Value("x", "y") != 0 {
+		t.Errorf("counter value should be 0, but was %v", counter.Value("x", "y"))
+	}
+}
+
+func Test_Gauge(t *testing.T) {
+	registry := NewRegistry()
+	gauge := registry.NewGaugeVec("test_gauge", "A test gauge.", "store")
+
+	value := 1.0
+	gauge.SetFunc(func() float64 {
+		return value
+	}, "foo")
+
+	if gauge.Value("foo") != 1 {
+		t.Errorf("gauge value should be 1, but was %v", gauge.Value("foo"))
+	}
+
+	value = 5
+
+	if gauge.Value("foo") != 5 {
+		t.Errorf("gauge value should be 5, but was %v", gauge.Value("foo"))
+	}
+}
+
+func Test_Write(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounterVec("test_total", "A test counter.", "foo")
+	gauge := registry.NewGaugeVec("test_gauge", "A test gauge.")
+	histogram := registry.NewHistogramVec("test_seconds", "A test histogram.", []float64{1, 0.5}, "bar")
+
+	counter.Inc("a\"b")
+	gauge.SetFunc(func() float64 {
+		return 42
+	})
+	histogram.Observe(0.2, "x")
+	histogram.Observe(0.7, "x")
+	histogram.Observe(3, "x")
+
+	var buf bytes.Buffer
+	writeError := registry.Write(&buf)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+
+	expected := `# HELP test_total A test counter.
+# TYPE test_total counter
+test_total{foo="a\"b"} 1
+# HELP test_gauge A test gauge.
+# TYPE test_gauge gauge
+test_gauge 42
+# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{bar="x",le="0.5"} 1
+test_seconds_bucket{bar="x",le="1"} 2
+test_seconds_bucket{bar="x",le="+Inf"} 3
+test_seconds_sum{bar="x"} 3.9
+test_seconds_count{bar="x"} 3
+`
+
+	if buf.String() != expected {
+		t.Errorf("metrics output did not match, expected\n%s\ngot\n%s", expected, buf.String())
+	}
+
+	if histogram.Count("x") != 3 {
+		t.Errorf("histogram count should be 3, but was %v", histogram.Count("x"))
+	}
+}
+
+func Test_StatusRecorder(t *testing.T) {
+	type parameter struct {
+		name         string
+		handler      func(w http.ResponseWriter)
+		expected     int
+		expectedSize int
+	}
+
+	var parameters = []parameter{
+		{name: "nothing written", handler: func(w http.ResponseWriter) {}, expected: http.StatusOK},
+		{name: "body written", handler: func(w http.ResponseWriter) { _, _ = w.Write([]byte("foo")) }, expected: http.StatusOK, expectedSize: 3},
+		{name: "status written", handler: func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) }, expected: http.StatusNotFound},
+	}
+
+	for _, test := range parameters {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := NewStatusRecorder(httptest.NewRecorder())
+			test.handler(recorder)
+			if recorder.Status() != test.expected {
+				t.Errorf("status should be %d, but was %d", test.expected, recorder.Status())
+			}
+			if recorder.Size() != test.expectedSize {
+				t.Errorf("size should be %d, but was %d", test.expectedSize, recorder.Size())
+			}
+		})
+	}
+}
+
+func Test_ObserveRequest(t *testing.T) {
+	ObserveRequest("", http.MethodGet, http.StatusNotFound, time.Millisecond)
+
+	if Requests.Value("unmatched", http.MethodGet, "404") < 1 {
+		t.Error("request should be counted as unmatched")
+	}
+
+	if RequestDuration.Count("unmatched", http.MethodGet) < 1 {
+		t.Error("request duration should be observed")
+	}
+}
+
+func Test_ClientLabel(t *testing.T) {
+	if ClientLabel("foo", false) != "foo" {
+		t.Error("configured client should be labelled by its id")
+	}
+
+	if ClientLabel("foo", true) != ClientDynamic {
+		t.Error("registered client should be labelled as dynamic")
+	}
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"cmp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// Requests counts HTTP requests by endpoint, method and status code.
+	Requests = GetRegistryInstance().NewCounterVec("stopnik_http_requests_total", "Total number of HTTP requests.", "endpoint", "method", "status")
+	// RequestDuration observes the HTTP request latency by endpoint and method.
+	RequestDuration = GetRegistryInstance().NewHistogramVec("stopnik_http_request_duration_seconds", "HTTP request latency in seconds.", DefaultBuckets, "endpoint", "method")
+	// Logins counts login attempts by result.
+	Logins = GetRegistryInstance().NewCounterVec("stopnik_logins_total", "Total number of login attempts.", "result")
+	// TokensIssued counts issued access tokens by grant type and client, see ClientLabel.
+	TokensIssued = GetRegistryInstance().NewCounterVec("stopnik_tokens_issued_total", "Total number of issued access tokens.", "grant_type", "client_id")
+	// Refreshes counts refresh token grants by client, see ClientLabel.
+	Refreshes = GetRegistryInstance().NewCounterVec("stopnik_token_refreshes_total", "Total number of refresh token grants.", "client_id")
+	// Revocations counts token revocations by token type and result.
+	Revocations = GetRegistryInstance().NewCounterVec("stopnik_token_revocations_total", "Total number of token revocations.", "token_type", "result")
+	// Introspections counts token introspections by token type and result.
+	Introspections = GetRegistryInstance().NewCounterVec("stopnik_token_introspections_total", "Total number of token introspections.", "token_type", "result")
+	// ActiveLoginSessions provides the number of active login sessions.
+	ActiveLoginSessions = GetRegistryInstance().NewGaugeVec("stopnik_active_login_sessions", "Number of active login sessions.")
+	// StoreSize provides the number of entries in each store.
+	StoreSize = GetRegistryInstance().NewGaugeVec("stopnik_store_size", "Number of entries in a store.", "store")
+)
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	ResultHit     = "hit"
+	ResultMiss    = "miss"
+	ClientDynamic = "dynamic"
+)
+
+// ClientLabel returns the client_id label value for a client,
+// clients registered at runtime share the ClientDynamic value to keep the number of label values bounded.
+func ClientLabel(clientId string, registered bool) string {
+	if registered {
+		return ClientDynamic
+	}
+	return clientId
+}
+
+// StatusRecorder wraps a http.ResponseWriter and records the written status code and response size.
+type StatusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+// NewStatusRecorder creates a new StatusRecorder for the given http.ResponseWriter.
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w}
+}
+
+func (recorder *StatusRecorder) WriteHeader(statusCode int) {
+	if recorder.status == 0 {
+		recorder.status = statusCode
+	}
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (recorder *StatusRecorder) Write(b []byte) (int, error) {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	n, writeError := recorder.ResponseWriter.Write(b)
+	recorder.size += n
+	return n, writeError
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, used by http.ResponseController.
+func (recorder *StatusRecorder) Unwrap() http.ResponseWriter {
+	return recorder.ResponseWriter
+}
+
+// Status returns the recorded status code.
+// When nothing was written yet http.StatusOK will be returned.
+func (recorder *StatusRecorder) Status() int {
+	return cmp.Or(recorder.status, http.StatusOK)
+}
+
+// Size returns the number of bytes written to the response body.
+func (recorder *StatusRecorder) Size() int {
+	return recorder.size
+}
+
+// ObserveRequest records a finished HTTP request for the given endpoint.
+// Requests not matching any endpoint are recorded as unmatched.
+func ObserveRequest(endpoint string, method string, status int, duration time.Duration) {
+	endpointLabel := cmp.Or(endpoint, "unmatched")
+	Requests.Inc(endpointLabel, method, strconv.Itoa(status))
+	RequestDuration.Observe(duration.Seconds(), endpointLabel, method)
+}
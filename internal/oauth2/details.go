@@ -0,0 +1,50 @@
+package oauth2
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+)
+
+// ClaimAuthorizationDetails as described in https://datatracker.ietf.org/doc/html/rfc9396#section-9.1
+const ClaimAuthorizationDetails = "authorization_details"
+
+// AuthorizationDetail as described in https://datatracker.ietf.org/doc/html/rfc9396#section-2,
+// besides the type all fields are defined by the authorization details type.
+type AuthorizationDetail map[string]any
+
+// GetType returns the type of the AuthorizationDetail.
+func (authorizationDetail AuthorizationDetail) GetType() string {
+	value, _ := authorizationDetail["type"].(string)
+	return value
+}
+
+// ParseAuthorizationDetails parses the JSON array of the authorization_details parameter,
+// returns false when the value is no array or an entry has no type.
+func ParseAuthorizationDetails(value string) ([]AuthorizationDetail, bool) {
+	var authorizationDetails []AuthorizationDetail
+	if unmarshalError := json.Unmarshal([]byte(value), &authorizationDetails); unmarshalError != nil {
+		return nil, false
+	}
+
+	for _, authorizationDetail := range authorizationDetails {
+		if authorizationDetail.GetType() == "" {
+			return nil, false
+		}
+	}
+
+	return authorizationDetails, true
+}
+
+// ContainsAuthorizationDetails checks whether each requested AuthorizationDetail equals one of the granted ones.
+func ContainsAuthorizationDetails(granted []AuthorizationDetail, requested []AuthorizationDetail) bool {
+	for _, authorizationDetail := range requested {
+		contained := slices.ContainsFunc(granted, func(grantedDetail AuthorizationDetail) bool {
+			return reflect.DeepEqual(grantedDetail, authorizationDetail)
+		})
+		if !contained {
+			return false
+		}
+	}
+	return true
+}
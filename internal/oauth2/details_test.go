@@ -0,0 +1,71 @@
+package oauth2
+
+import (
+	"fmt"
+	"testing"
+)
+
+func Test_ParseAuthorizationDetails(t *testing.T) {
+	type parameter struct {
+		value         string
+		valid         bool
+		expectedTypes []string
+	}
+
+	var authorizationDetailsParameters = []parameter{
+		{`[{"type":"payment_initiation","actions":["initiate"]}]`, true, []string{"payment_initiation"}},
+		{`[{"type":"payment_initiation"},{"type":"document_sharing"}]`, true, []string{"payment_initiation", "document_sharing"}},
+		{`[]`, true, []string{}},
+		{`[{"actions":["initiate"]}]`, false, nil},
+		{`[{"type":1}]`, false, nil},
+		{`{"type":"payment_initiation"}`, false, nil},
+		{`payment_initiation`, false, nil},
+	}
+
+	for _, test := range authorizationDetailsParameters {
+		testMessage := fmt.Sprintf("Authorization details %s %v", test.value, test.valid)
+		t.Run(testMessage, func(t *testing.T) {
+			authorizationDetails, valid := ParseAuthorizationDetails(test.value)
+			if valid != test.valid {
+				t.Fatalf("expected valid to be %v", test.valid)
+			}
+
+			if len(authorizationDetails) != len(test.expectedTypes) {
+				t.Fatalf("expected %d authorization details, got %d", len(test.expectedTypes), len(authorizationDetails))
+			}
+
+			for index, authorizationDetail := range authorizationDetails {
+				if authorizationDetail.GetType() != test.expectedTypes[index] {
+					t.Errorf("expected type %s, got %s", test.expectedTypes[index], authorizationDetail.GetType())
+				}
+			}
+		})
+	}
+}
+
+func Test_ContainsAuthorizationDetails(t *testing.T) {
+	granted, _ := ParseAuthorizationDetails(`[{"type":"payment_initiation","actions":["initiate"]},{"type":"document_sharing"}]`)
+
+	type parameter struct {
+		value    string
+		expected bool
+	}
+
+	var containsParameters = []parameter{
+		{`[{"type":"document_sharing"}]`, true},
+		{`[{"type":"payment_initiation","actions":["initiate"]}]`, true},
+		{`[]`, true},
+		{`[{"type":"payment_initiation","actions":["status"]}]`, false},
+		{`[{"type":"account_information"}]`, false},
+	}
+
+	for _, test := range containsParameters {
+		testMessage := fmt.Sprintf("Contains authorization details %s %v", test.value, test.expected)
+		t.Run(testMessage, func(t *testing.T) {
+			requested, _ := ParseAuthorizationDetails(test.value)
+			if ContainsAuthorizationDetails(granted, requested) != test.expected {
+				t.Errorf("expected contains to be %v", test.expected)
+			}
+		})
+	}
+}
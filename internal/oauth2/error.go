@@ -37,25 +37,31 @@ const (
 	AuthorizationEtRequestNotSupported      AuthorizationErrorType = "request_not_supported"
 	AuthorizationEtRequestUriNotSupported   AuthorizationErrorType = "request_uri_not_supported"
 	AuthorizationEtRegistrationNotSupported AuthorizationErrorType = "registration_not_supported"
+	// AuthorizationEtInvalidTarget https://datatracker.ietf.org/doc/html/rfc8707#section-2
+	AuthorizationEtInvalidTarget AuthorizationErrorType = "invalid_target"
+	// AuthorizationEtInvalidAuthorizationDetails https://datatracker.ietf.org/doc/html/rfc9396#section-5
+	AuthorizationEtInvalidAuthorizationDetails AuthorizationErrorType = "invalid_authorization_details"
 )
 
 var authorizationErrorTypeMap = map[string]AuthorizationErrorType{
-	"invalid_request":            AuthorizationEtInvalidRequest,
-	"unauthorized_client":        AuthorizationEtUnauthorizedClient,
-	"access_denied":              AuthorizationEtAccessDenied,
-	"unsupported_response_type":  AuthorizationEtUnsupportedResponseType,
-	"invalid_scope":              AuthorizationEtInvalidScope,
-	"server_error":               AuthorizationEtServerError,
-	"temporarily_unavailable":    AuthorizationEtTemporaryUnavailable,
-	"interaction_required":       AuthorizationEtInteractionRequired,
-	"login_required":             AuthorizationEtLoginRequired,
-	"account_selection_required": AuthorizationEtAccountSelectionRequired,
-	"consent_required":           AuthorizationEtConsentRequired,
-	"invalid_request_uri":        AuthorizationEtInvalidRequestUri,
-	"invalid_request_object":     AuthorizationEtInvalidRequestObject,
-	"request_not_supported":      AuthorizationEtRequestNotSupported,
-	"request_uri_not_supported":  AuthorizationEtRequestUriNotSupported,
-	"registration_not_supported": AuthorizationEtRegistrationNotSupported,
+	"invalid_request":               AuthorizationEtInvalidRequest,
+	"unauthorized_client":           AuthorizationEtUnauthorizedClient,
+	"access_denied":                 AuthorizationEtAccessDenied,
+	"unsupported_response_type":     AuthorizationEtUnsupportedResponseType,
+	"invalid_scope":                 AuthorizationEtInvalidScope,
+	"server_error":                  AuthorizationEtServerError,
+	"temporarily_unavailable":       AuthorizationEtTemporaryUnavailable,
+	"interaction_required":          AuthorizationEtInteractionRequired,
+	"login_required":                AuthorizationEtLoginRequired,
+	"account_selection_required":    AuthorizationEtAccountSelectionRequired,
+	"consent_required":              AuthorizationEtConsentRequired,
+	"invalid_request_uri":           AuthorizationEtInvalidRequestUri,
+	"invalid_request_object":        AuthorizationEtInvalidRequestObject,
+	"request_not_supported":         AuthorizationEtRequestNotSupported,
+	"request_uri_not_supported":     AuthorizationEtRequestUriNotSupported,
+	"registration_not_supported":    AuthorizationEtRegistrationNotSupported,
+	"invalid_target":                AuthorizationEtInvalidTarget,
+	"invalid_authorization_details": AuthorizationEtInvalidAuthorizationDetails,
 }
 
 // TokenErrorType as described in multiple places e.g. https://datatracker.ietf.org/doc/html/rfc6749#section-5.2
@@ -77,18 +83,44 @@ const (
 	TokenEtInvalidScope         TokenErrorType = "invalid_scope"
 	// TokenEtUnsupportedTokenType https://datatracker.ietf.org/doc/html/rfc7009#section-2.2.1
 	TokenEtUnsupportedTokenType TokenErrorType = "unsupported_token_type"
+	// TokenEtInvalidTarget https://datatracker.ietf.org/doc/html/rfc8693#section-2.2.2
+	TokenEtInvalidTarget TokenErrorType = "invalid_target"
+	// TokenEtInvalidAuthorizationDetails https://datatracker.ietf.org/doc/html/rfc9396#section-6.2
+	TokenEtInvalidAuthorizationDetails TokenErrorType = "invalid_authorization_details"
+	// TokenEtInvalidDPoPProof https://datatracker.ietf.org/doc/html/rfc9449#section-5
+	TokenEtInvalidDPoPProof TokenErrorType = "invalid_dpop_proof"
+	// TokenEtUseDPoPNonce https://datatracker.ietf.org/doc/html/rfc9449#section-8
+	TokenEtUseDPoPNonce TokenErrorType = "use_dpop_nonce"
 )
 
 var tokenErrorTypeMap = map[string]TokenErrorType{
-	"invalid_request":        TokenEtInvalidRequest,
-	"invalid_client":         TokenEtInvalidClient,
-	"invalid_grant":          TokenEtInvalidGrant,
-	"unauthorized_client":    TokenEtUnauthorizedClient,
-	"unsupported_grant_type": TokenEtUnsupportedGrandType,
-	"invalid_scope":          TokenEtInvalidScope,
-	"unsupported_token_type": TokenEtUnsupportedTokenType,
+	"invalid_request":               TokenEtInvalidRequest,
+	"invalid_client":                TokenEtInvalidClient,
+	"invalid_grant":                 TokenEtInvalidGrant,
+	"unauthorized_client":           TokenEtUnauthorizedClient,
+	"unsupported_grant_type":        TokenEtUnsupportedGrandType,
+	"invalid_scope":                 TokenEtInvalidScope,
+	"unsupported_token_type":        TokenEtUnsupportedTokenType,
+	"invalid_target":                TokenEtInvalidTarget,
+	"invalid_authorization_details": TokenEtInvalidAuthorizationDetails,
+	"invalid_dpop_proof":            TokenEtInvalidDPoPProof,
+	"use_dpop_nonce":                TokenEtUseDPoPNonce,
 }
 
+// RegistrationErrorType as described in https://datatracker.ietf.org/doc/html/rfc7591#section-3.2.2
+type RegistrationErrorType string
+
+// RegistrationErrorResponseParameter related to https://datatracker.ietf.org/doc/html/rfc7591#section-3.2.2
+type RegistrationErrorResponseParameter struct {
+	Error       RegistrationErrorType `json:"error"`
+	Description string                `json:"error_description,omitempty"`
+}
+
+const (
+	RegistrationEtInvalidRedirectUri    RegistrationErrorType = "invalid_redirect_uri"
+	RegistrationEtInvalidClientMetadata RegistrationErrorType = "invalid_client_metadata"
+)
+
 func AuthorizationErrorTypeFromString(value string) (AuthorizationErrorType, bool) {
 	result, ok := authorizationErrorTypeMap[strings.ToLower(value)]
 	return result, ok
@@ -99,7 +131,9 @@ func TokenErrorTypeFromString(value string) (TokenErrorType, bool) {
 	return result, ok
 }
 
-func AuthorizationErrorResponseHandler(w http.ResponseWriter, redirectURL *url.URL, state string, errorResponseParameter *AuthorizationErrorResponseParameter) {
+// AuthorizationErrorResponseHandler redirects an error response, the issuer is added when provided,
+// see https://datatracker.ietf.org/doc/html/rfc9207#section-2
+func AuthorizationErrorResponseHandler(w http.ResponseWriter, redirectURL *url.URL, issuer string, state string, errorResponseParameter *AuthorizationErrorResponseParameter) {
 	if redirectURL == nil {
 		sendStatus(w, http.StatusInternalServerError, "No redirect URL")
 		return
@@ -119,6 +153,9 @@ func AuthorizationErrorResponseHandler(w http.ResponseWriter, redirectURL *url.U
 	if state != "" {
 		query.Set(ParameterState, state)
 	}
+	if issuer != "" {
+		query.Set(ParameterIssuer, issuer)
+	}
 	redirectURL.RawQuery = query.Encode()
 	w.Header().Set(internalHttp.Location, redirectURL.String())
 	w.WriteHeader(http.StatusFound)
@@ -135,10 +172,17 @@ func TokenErrorStatusResponseHandler(w http.ResponseWriter, r *http.Request, sta
 	}
 }
 
+func RegistrationErrorResponseHandler(w http.ResponseWriter, r *http.Request, errorResponseParameter *RegistrationErrorResponseParameter) {
+	err := internalHttp.SendJsonWithStatus(errorResponseParameter, http.StatusBadRequest, w, r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
 func sendStatus(w http.ResponseWriter, status int, message string) {
 	w.WriteHeader(status)
 	_, err := w.Write([]byte(message))
 	if err != nil {
-		log.Error("Could not send status message: %v", err)
+		log.Error("Could not send status message", log.Err(err))
 	}
 }
@@ -13,7 +13,7 @@ func Test_ErrorNoRedirectUri(t *testing.T) {
 	t.Run("No redirect uri provided", func(t *testing.T) {
 		rr := httptest.NewRecorder()
 
-		AuthorizationErrorResponseHandler(rr, nil, "foo", nil)
+		AuthorizationErrorResponseHandler(rr, nil, "https://issuer.example.com", "foo", nil)
 
 		if rr.Code != http.StatusInternalServerError {
 			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusInternalServerError)
@@ -46,6 +46,8 @@ func Test_AuthorizationErrorTypeFromString(t *testing.T) {
 		{string(AuthorizationEtRequestNotSupported), true, "request_not_supported"},
 		{string(AuthorizationEtRequestUriNotSupported), true, "request_uri_not_supported"},
 		{string(AuthorizationEtRegistrationNotSupported), true, "registration_not_supported"},
+		{string(AuthorizationEtInvalidTarget), true, "invalid_target"},
+		{string(AuthorizationEtInvalidAuthorizationDetails), true, "invalid_authorization_details"},
 		{"foo", false, ""},
 	}
 
@@ -93,7 +95,7 @@ func Test_AuthorizationErrorResponseHandler(t *testing.T) {
 
 			rr := httptest.NewRecorder()
 
-			AuthorizationErrorResponseHandler(rr, redirectURL, test.state, test.errorResponseParameter)
+			AuthorizationErrorResponseHandler(rr, redirectURL, "https://issuer.example.com", test.state, test.errorResponseParameter)
 
 			if rr.Code != http.StatusFound {
 				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusFound)
@@ -134,6 +136,12 @@ func Test_AuthorizationErrorResponseHandler(t *testing.T) {
 			if errorUri != test.expectedErrorUri {
 				t.Errorf("error uri %v did not match %v", errorUri, test.expectedErrorUri)
 			}
+
+			issuer := location.Query().Get(ParameterIssuer)
+
+			if issuer != "https://issuer.example.com" {
+				t.Errorf("issuer %v did not match", issuer)
+			}
 		})
 	}
 }
@@ -180,6 +188,10 @@ func Test_TokenErrorTypeFromString(t *testing.T) {
 		{string(TokenEtUnauthorizedClient), true, "unauthorized_client"},
 		{string(TokenEtUnsupportedGrandType), true, "unsupported_grant_type"},
 		{string(TokenEtInvalidScope), true, "invalid_scope"},
+		{string(TokenEtInvalidTarget), true, "invalid_target"},
+		{string(TokenEtInvalidAuthorizationDetails), true, "invalid_authorization_details"},
+		{string(TokenEtInvalidDPoPProof), true, "invalid_dpop_proof"},
+		{string(TokenEtUseDPoPNonce), true, "use_dpop_nonce"},
 		{"foo", false, ""},
 	}
 
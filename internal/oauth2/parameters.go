@@ -21,3 +21,29 @@ const (
 	ParameterErrorDescription string = "error_description"
 	ParameterErrorUri         string = "error_uri"
 )
+
+// Parameters of the token exchange request as described in https://datatracker.ietf.org/doc/html/rfc8693#section-2.1
+const (
+	ParameterResource           string = "resource"
+	ParameterAudience           string = "audience"
+	ParameterRequestedTokenType string = "requested_token_type"
+	ParameterSubjectToken       string = "subject_token"
+	ParameterSubjectTokenType   string = "subject_token_type"
+	ParameterActorToken         string = "actor_token"
+	ParameterActorTokenType     string = "actor_token_type"
+)
+
+// ParameterAssertion of the JWT authorization grant as described in https://datatracker.ietf.org/doc/html/rfc7523#section-2.1
+const ParameterAssertion string = "assertion"
+
+// ParameterResponseMode as described in https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#ResponseModes
+const ParameterResponseMode string = "response_mode"
+
+// ParameterResponse contains the JWT of an authorization response as described in https://openid.net/specs/oauth-v2-jarm.html#section-2.3
+const ParameterResponse string = "response"
+
+// ParameterIssuer identifies the authorization server in authorization responses as described in https://datatracker.ietf.org/doc/html/rfc9207#section-2
+const ParameterIssuer string = "iss"
+
+// ParameterAuthorizationDetails as described in https://datatracker.ietf.org/doc/html/rfc9396#section-2
+const ParameterAuthorizationDetails string = "authorization_details"
@@ -28,6 +28,15 @@ func Test_OAuth2Parameters(t *testing.T) {
 		{ParameterPassword, "password"},
 		{ParameterToken, "token"},
 		{ParameterTokenTypeHint, "token_type_hint"},
+		{ParameterResource, "resource"},
+		{ParameterAudience, "audience"},
+		{ParameterRequestedTokenType, "requested_token_type"},
+		{ParameterSubjectToken, "subject_token"},
+		{ParameterSubjectTokenType, "subject_token_type"},
+		{ParameterActorToken, "actor_token"},
+		{ParameterActorTokenType, "actor_token_type"},
+		{ParameterAssertion, "assertion"},
+		{ParameterAuthorizationDetails, "authorization_details"},
 	}
 
 	for _, test := range oauth2Parameters {
@@ -0,0 +1,34 @@
+package oauth2
+
+// ClientMetadata as described in https://datatracker.ietf.org/doc/html/rfc7591#section-2
+type ClientMetadata struct {
+	RedirectUris            []string         `json:"redirect_uris,omitempty"`
+	TokenEndpointAuthMethod ClientAuthMethod `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes              []GrantType      `json:"grant_types,omitempty"`
+	ResponseTypes           []string         `json:"response_types,omitempty"`
+	ClientName              string           `json:"client_name,omitempty"`
+	ClientUri               string           `json:"client_uri,omitempty"`
+	LogoUri                 string           `json:"logo_uri,omitempty"`
+	Scope                   string           `json:"scope,omitempty"`
+	Contacts                []string         `json:"contacts,omitempty"`
+	TosUri                  string           `json:"tos_uri,omitempty"`
+	PolicyUri               string           `json:"policy_uri,omitempty"`
+	SoftwareId              string           `json:"software_id,omitempty"`
+	SoftwareVersion         string           `json:"software_version,omitempty"`
+	// SubjectType and SectorIdentifierUri https://openid.net/specs/openid-connect-registration-1_0.html#ClientMetadata
+	SubjectType         string `json:"subject_type,omitempty"`
+	SectorIdentifierUri string `json:"sector_identifier_uri,omitempty"`
+}
+
+// ClientInformation as described in
+// - https://datatracker.ietf.org/doc/html/rfc7591#section-3.2.1
+// - https://datatracker.ietf.org/doc/html/rfc7592#section-3
+type ClientInformation struct {
+	ClientId                string `json:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty"`
+	ClientIdIssuedAt        int64  `json:"client_id_issued_at,omitempty"`
+	ClientSecretExpiresAt   int64  `json:"client_secret_expires_at"`
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientUri   string `json:"registration_client_uri,omitempty"`
+	ClientMetadata
+}
@@ -5,21 +5,85 @@ import (
 	"time"
 )
 
+// AccessToken contains the values of an issued access token,
+// the metadata is the same for JWT and opaque tokens.
 type AccessToken struct {
-	Key             string
-	TokenType       TokenType
-	Username        string
-	ClientId        string
-	Scopes          []string
-	RequestedClaims *oidc.ClaimsParameter
+	Key                  string
+	Id                   string
+	TokenType            TokenType
+	Username             string
+	ClientId             string
+	Scopes               []string
+	RequestedClaims      *oidc.ClaimsParameter
+	Issuer               string
+	Audience             []string
+	IssuedAt             time.Time
+	NotBefore            time.Time
+	ExpiresAt            time.Time
+	AuthTime             time.Time
+	Acr                  string
+	Amr                  []string
+	Actor                *Actor
+	Resources            []string
+	AuthorizationDetails []AuthorizationDetail
+	Confirmation         *Confirmation
 }
+
+// ClaimActor as described in https://datatracker.ietf.org/doc/html/rfc8693#section-4.1
+const ClaimActor = "act"
+
+// ClaimClientId as described in https://datatracker.ietf.org/doc/html/rfc9068#section-2.2
+const ClaimClientId = "client_id"
+
+// ClaimScope as described in https://datatracker.ietf.org/doc/html/rfc9068#section-2.2.3
+const ClaimScope = "scope"
+
+// JwtTypeAccessToken is the typ header of JWT access tokens,
+// as described in https://datatracker.ietf.org/doc/html/rfc9068#section-2.1
+const JwtTypeAccessToken = "at+jwt"
+
+// Actor identifies the acting party of a delegated token,
+// as described in https://datatracker.ietf.org/doc/html/rfc8693#section-4.1
+type Actor struct {
+	Subject  string `json:"sub"`
+	ClientId string `json:"client_id,omitempty"`
+	Actor    *Actor `json:"act,omitempty"`
+}
+
+// RefreshToken contains the values of an issued refresh token,
+// the metadata is the same for JWT and opaque tokens.
 type RefreshToken struct {
-	Key             string
-	Username        string
-	ClientId        string
-	Scopes          []string
-	RequestedClaims *oidc.ClaimsParameter
-	AuthTime        time.Time
+	Key                  string
+	Id                   string
+	Username             string
+	ClientId             string
+	Scopes               []string
+	RequestedClaims      *oidc.ClaimsParameter
+	Issuer               string
+	Audience             []string
+	IssuedAt             time.Time
+	NotBefore            time.Time
+	ExpiresAt            time.Time
+	AuthTime             time.Time
+	Acr                  string
+	Amr                  []string
+	Resources            []string
+	AuthorizationDetails []AuthorizationDetail
+	Confirmation         *Confirmation
+	// Offline refresh tokens are issued for the offline_access scope, GrantedAt is the time of the original grant.
+	Offline   bool
+	GrantedAt time.Time
+}
+
+// ClaimConfirmation as described in https://datatracker.ietf.org/doc/html/rfc7800#section-3.1
+const ClaimConfirmation = "cnf"
+
+// Confirmation binds a token to a key of the client,
+// JwkThumbprint as described in https://datatracker.ietf.org/doc/html/rfc9449#section-6.1
+// CertificateThumbprint as described in https://datatracker.ietf.org/doc/html/rfc8705#section-3.1
+type Confirmation struct {
+	JwkThumbprint         string `json:"jkt,omitempty"`
+	CertificateThumbprint string `json:"x5t#S256,omitempty"`
 }
 
 // AccessTokenResponse as described in https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.4
@@ -29,4 +93,9 @@ type AccessTokenResponse struct {
 	ExpiresIn         int       `json:"expires_in,omitempty"` // seconds
 	RefreshTokenValue string    `json:"refresh_token,omitempty"`
 	IdTokenValue      string    `json:"id_token,omitempty"` // https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+	// IssuedTokenType https://datatracker.ietf.org/doc/html/rfc8693#section-2.2.1
+	IssuedTokenType TokenTypeIdentifier `json:"issued_token_type,omitempty"`
+	Scope           string              `json:"scope,omitempty"`
+	// AuthorizationDetails https://datatracker.ietf.org/doc/html/rfc9396#section-7.1
+	AuthorizationDetails []AuthorizationDetail `json:"authorization_details,omitempty"`
 }
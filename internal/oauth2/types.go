@@ -12,7 +12,9 @@ const (
 	GtClientCredentials GrantType = "client_credentials"
 	GtPassword          GrantType = "password"
 	GtRefreshToken      GrantType = "refresh_token"
-	GtImplicit          GrantType = "implicit" // RFC7591
+	GtImplicit          GrantType = "implicit"                                        // RFC7591
+	GtTokenExchange     GrantType = "urn:ietf:params:oauth:grant-type:token-exchange" // RFC8693
+	GtJwtBearer         GrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"     // RFC7523
 )
 
 var grantTypeMap = map[string]GrantType{
@@ -21,6 +23,8 @@ var grantTypeMap = map[string]GrantType{
 	"password":           GtPassword,
 	"refresh_token":      GtRefreshToken,
 	"implicit":           GtImplicit, // RFC7591
+	"urn:ietf:params:oauth:grant-type:token-exchange": GtTokenExchange, // RFC8693
+	"urn:ietf:params:oauth:grant-type:jwt-bearer":     GtJwtBearer,     // RFC7523
 }
 
 // ResponseType as described in https://datatracker.ietf.org/doc/html/rfc6749#appendix-A.3
@@ -42,6 +46,40 @@ var responseTypeMap = map[string]ResponseType{
 	"id_token":           RtIdToken,
 }
 
+// ResponseTypeGrantTypes maps response types to the grant type a client must be allowed to use for them,
+// see https://datatracker.ietf.org/doc/html/rfc7591#section-2.1
+var ResponseTypeGrantTypes = map[ResponseType]GrantType{
+	RtCode:    GtAuthorizationCode,
+	RtToken:   GtImplicit,
+	RtIdToken: GtImplicit,
+}
+
+// ResponseMode as described in
+// - https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#ResponseModes
+// - https://openid.net/specs/oauth-v2-form-post-response-mode-1_0.html#FormPostResponseMode
+// - https://openid.net/specs/oauth-v2-jarm.html#section-2.3
+type ResponseMode string
+
+const (
+	RmQuery       ResponseMode = "query"
+	RmFragment    ResponseMode = "fragment"
+	RmFormPost    ResponseMode = "form_post"
+	RmJwt         ResponseMode = "jwt"           // JARM
+	RmQueryJwt    ResponseMode = "query.jwt"     // JARM
+	RmFragmentJwt ResponseMode = "fragment.jwt"  // JARM
+	RmFormPostJwt ResponseMode = "form_post.jwt" // JARM
+)
+
+var responseModeMap = map[string]ResponseMode{
+	"query":         RmQuery,
+	"fragment":      RmFragment,
+	"form_post":     RmFormPost,
+	"jwt":           RmJwt,         // JARM
+	"query.jwt":     RmQueryJwt,    // JARM
+	"fragment.jwt":  RmFragmentJwt, // JARM
+	"form_post.jwt": RmFormPostJwt, // JARM
+}
+
 // ClientType as described in https://datatracker.ietf.org/doc/html/rfc6749#section-2.1
 type ClientType string
 
@@ -55,17 +93,40 @@ var clientTypeMap = map[string]ClientType{
 	"public":       CtPublic,
 }
 
+// ClientAuthMethod as described in
+// - https://datatracker.ietf.org/doc/html/rfc7591#section-2
+// - https://datatracker.ietf.org/doc/html/rfc8705#section-2.1.1
+type ClientAuthMethod string
+
+const (
+	CamClientSecretBasic       ClientAuthMethod = "client_secret_basic"
+	CamClientSecretPost        ClientAuthMethod = "client_secret_post"
+	CamNone                    ClientAuthMethod = "none"
+	CamTlsClientAuth           ClientAuthMethod = "tls_client_auth"             // RFC8705
+	CamSelfSignedTlsClientAuth ClientAuthMethod = "self_signed_tls_client_auth" // RFC8705
+)
+
+var clientAuthMethodMap = map[string]ClientAuthMethod{
+	"client_secret_basic":         CamClientSecretBasic,
+	"client_secret_post":          CamClientSecretPost,
+	"none":                        CamNone,
+	"tls_client_auth":             CamTlsClientAuth,           // RFC8705
+	"self_signed_tls_client_auth": CamSelfSignedTlsClientAuth, // RFC8705
+}
+
 // TokenType as described in https://datatracker.ietf.org/doc/html/rfc6749#section-7.1
 type TokenType string
 
 const (
 	TtBearer TokenType = "Bearer" // https://www.rfc-editor.org/rfc/rfc6750#section-6.1.1
 	TtMAC    TokenType = "mac"    // https://datatracker.ietf.org/doc/html/draft-ietf-oauth-v2-http-mac-05#section-9.3.1
+	TtDPoP   TokenType = "DPoP"   // https://datatracker.ietf.org/doc/html/rfc9449#section-5
 )
 
 var tokenTypeMap = map[string]TokenType{
 	"bearer": TtBearer,
 	"mac":    TtMAC,
+	"dpop":   TtDPoP,
 }
 
 // IntrospectTokenType as described in https://datatracker.ietf.org/doc/html/rfc7662#section-2.1
@@ -81,6 +142,23 @@ var introspectTokenTypeMap = map[string]IntrospectTokenType{
 	"refresh_token": ItRefreshToken,
 }
 
+// TokenTypeIdentifier as described in https://datatracker.ietf.org/doc/html/rfc8693#section-3
+type TokenTypeIdentifier string
+
+const (
+	TtiAccessToken  TokenTypeIdentifier = "urn:ietf:params:oauth:token-type:access_token"
+	TtiRefreshToken TokenTypeIdentifier = "urn:ietf:params:oauth:token-type:refresh_token"
+	TtiIdToken      TokenTypeIdentifier = "urn:ietf:params:oauth:token-type:id_token"
+	TtiJWT          TokenTypeIdentifier = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+var tokenTypeIdentifierMap = map[string]TokenTypeIdentifier{
+	"urn:ietf:params:oauth:token-type:access_token":  TtiAccessToken,
+	"urn:ietf:params:oauth:token-type:refresh_token": TtiRefreshToken,
+	"urn:ietf:params:oauth:token-type:id_token":      TtiIdToken,
+	"urn:ietf:params:oauth:token-type:jwt":           TtiJWT,
+}
+
 func GrantTypeFromString(value string) (GrantType, bool) {
 	result, ok := grantTypeMap[strings.ToLower(value)]
 	return result, ok
@@ -96,6 +174,16 @@ func ClientTypeFromString(value string) (ClientType, bool) {
 	return result, ok
 }
 
+func ResponseModeFromString(value string) (ResponseMode, bool) {
+	result, ok := responseModeMap[strings.ToLower(value)]
+	return result, ok
+}
+
+func ClientAuthMethodFromString(value string) (ClientAuthMethod, bool) {
+	result, ok := clientAuthMethodMap[strings.ToLower(value)]
+	return result, ok
+}
+
 func TokenTypeFromString(value string) (TokenType, bool) {
 	result, ok := tokenTypeMap[strings.ToLower(value)]
 	return result, ok
@@ -105,3 +193,8 @@ func IntrospectTokenTypeFromString(value string) (IntrospectTokenType, bool) {
 	result, ok := introspectTokenTypeMap[strings.ToLower(value)]
 	return result, ok
 }
+
+func TokenTypeIdentifierFromString(value string) (TokenTypeIdentifier, bool) {
+	result, ok := tokenTypeIdentifierMap[value]
+	return result, ok
+}
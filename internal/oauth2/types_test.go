@@ -18,6 +18,8 @@ func Test_GrantTypeFromString(t *testing.T) {
 		{string(GtPassword), true, "password"},
 		{string(GtRefreshToken), true, "refresh_token"},
 		{string(GtImplicit), true, "implicit"},
+		{string(GtTokenExchange), true, "urn:ietf:params:oauth:grant-type:token-exchange"},
+		{string(GtJwtBearer), true, "urn:ietf:params:oauth:grant-type:jwt-bearer"},
 		{"foo", false, ""},
 	}
 
@@ -81,6 +83,60 @@ func Test_ClientTypeFromString(t *testing.T) {
 	}
 }
 
+func Test_ResponseModeFromString(t *testing.T) {
+	type parameter struct {
+		value    string
+		exists   bool
+		expected string
+	}
+
+	var responseModeParameters = []parameter{
+		{string(RmQuery), true, "query"},
+		{string(RmFragment), true, "fragment"},
+		{string(RmFormPost), true, "form_post"},
+		{string(RmJwt), true, "jwt"},
+		{string(RmQueryJwt), true, "query.jwt"},
+		{string(RmFragmentJwt), true, "fragment.jwt"},
+		{string(RmFormPostJwt), true, "form_post.jwt"},
+		{"foo", false, ""},
+	}
+
+	for _, test := range responseModeParameters {
+		testMessage := fmt.Sprintf("Response mode %s %v", test.value, test.exists)
+		t.Run(testMessage, func(t *testing.T) {
+			if responseMode, exits := ResponseModeFromString(test.value); exits != test.exists || string(responseMode) != test.expected {
+				t.Errorf("Response mode %s not found,", test.value)
+			}
+		})
+	}
+}
+
+func Test_ClientAuthMethodFromString(t *testing.T) {
+	type parameter struct {
+		value    string
+		exists   bool
+		expected string
+	}
+
+	var clientAuthMethodParameters = []parameter{
+		{string(CamClientSecretBasic), true, "client_secret_basic"},
+		{string(CamClientSecretPost), true, "client_secret_post"},
+		{string(CamNone), true, "none"},
+		{string(CamTlsClientAuth), true, "tls_client_auth"},
+		{string(CamSelfSignedTlsClientAuth), true, "self_signed_tls_client_auth"},
+		{"foo", false, ""},
+	}
+
+	for _, test := range clientAuthMethodParameters {
+		testMessage := fmt.Sprintf("Client auth method %s %v", test.value, test.exists)
+		t.Run(testMessage, func(t *testing.T) {
+			if clientAuthMethod, exits := ClientAuthMethodFromString(test.value); exits != test.exists || string(clientAuthMethod) != test.expected {
+				t.Errorf("Client auth method %s not found,", test.value)
+			}
+		})
+	}
+}
+
 func Test_TokenTypeFromString(t *testing.T) {
 	type parameter struct {
 		value    string
@@ -91,6 +147,7 @@ func Test_TokenTypeFromString(t *testing.T) {
 	var tokenTypeParameters = []parameter{
 		{string(TtBearer), true, "Bearer"},
 		{string(TtMAC), true, "mac"},
+		{string(TtDPoP), true, "DPoP"},
 		{"foo", false, ""},
 	}
 
@@ -126,3 +183,29 @@ func Test_IntrospectTokenTypeFromString(t *testing.T) {
 		})
 	}
 }
+
+func Test_TokenTypeIdentifierFromString(t *testing.T) {
+	type parameter struct {
+		value    string
+		exists   bool
+		expected string
+	}
+
+	var tokenTypeIdentifierParameters = []parameter{
+		{string(TtiAccessToken), true, "urn:ietf:params:oauth:token-type:access_token"},
+		{string(TtiRefreshToken), true, "urn:ietf:params:oauth:token-type:refresh_token"},
+		{string(TtiIdToken), true, "urn:ietf:params:oauth:token-type:id_token"},
+		{string(TtiJWT), true, "urn:ietf:params:oauth:token-type:jwt"},
+		{"access_token", false, ""},
+	}
+
+	for _, test := range tokenTypeIdentifierParameters {
+		testMessage := fmt.Sprintf("Token type identifier %s %v", test.value, test.exists)
+		t.Run(testMessage, func(t *testing.T) {
+			tokenTypeIdentifier, exists := TokenTypeIdentifierFromString(test.value)
+			if exists != test.exists || string(tokenTypeIdentifier) != test.expected {
+				t.Errorf("Token type identifier %s did not match", test.value)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ const (
 	ClaimAuthorizedParty      string = "azp"
 	ClaimAtHash               string = "at_hash"
 	ClaimAuthTime             string = "auth_time"
+	ClaimAcr                  string = "acr"
+	ClaimAmr                  string = "amr"
 	ClaimName                 string = "name"
 	ClaimGivenName            string = "given_name"
 	ClaimMiddleName           string = "middle_name"
@@ -1,10 +1,14 @@
 package oidc
 
 const (
-	ParameterNonce   string = "nonce"
-	ParameterIdToken string = "id_token"
-	ParameterPrompt  string = "prompt"
-	ParameterMaxAge  string = "max_age"
-	ParameterRequest string = "request"
-	ParameterClaims  string = "claims"
+	ParameterNonce       string = "nonce"
+	ParameterIdToken     string = "id_token"
+	ParameterPrompt      string = "prompt"
+	ParameterMaxAge      string = "max_age"
+	ParameterAcrValues   string = "acr_values"
+	ParameterLoginHint   string = "login_hint"
+	ParameterIdTokenHint string = "id_token_hint"
+	ParameterRequest     string = "request"
+	ParameterRequestUri  string = "request_uri"
+	ParameterClaims      string = "claims"
 )
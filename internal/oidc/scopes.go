@@ -18,3 +18,13 @@ func HasOidcScope(scopes []string) bool {
 func HasOfflineAccessScope(scopes []string) bool {
 	return slices.Contains(scopes, ScopeOfflineAccess)
 }
+
+// RemoveOfflineAccessScope returns the scopes without offline_access, the given scopes are not modified.
+func RemoveOfflineAccessScope(scopes []string) []string {
+	if !HasOfflineAccessScope(scopes) {
+		return scopes
+	}
+	return slices.DeleteFunc(slices.Clone(scopes), func(scope string) bool {
+		return scope == ScopeOfflineAccess
+	})
+}
@@ -2,6 +2,7 @@ package oidc
 
 import (
 	"fmt"
+	"slices"
 	"testing"
 )
 
@@ -81,3 +82,31 @@ func Test_HasOfflineAccessScope(t *testing.T) {
 		})
 	}
 }
+
+func Test_RemoveOfflineAccessScope(t *testing.T) {
+	type removeScopeParameter struct {
+		values   []string
+		expected []string
+	}
+
+	var removeScopeParameters = []removeScopeParameter{
+		{[]string{ScopeOpenId}, []string{ScopeOpenId}},
+		{[]string{ScopeOpenId, ScopeOfflineAccess}, []string{ScopeOpenId}},
+		{[]string{ScopeOfflineAccess, "foo", ScopeOfflineAccess}, []string{"foo"}},
+		{[]string{ScopeOfflineAccess}, []string{}},
+	}
+
+	for _, test := range removeScopeParameters {
+		testMessage := fmt.Sprintf("Remove OfflineAccess scope %s", test.values)
+		t.Run(testMessage, func(t *testing.T) {
+			values := slices.Clone(test.values)
+			result := RemoveOfflineAccessScope(values)
+			if !slices.Equal(result, test.expected) {
+				t.Errorf("result %v did not match expected %v", result, test.expected)
+			}
+			if !slices.Equal(values, test.values) {
+				t.Errorf("input %v should not be changed", values)
+			}
+		})
+	}
+}
@@ -0,0 +1,4 @@
+// Package schema validates JSON values against a subset of JSON Schema.
+// Supported keywords are type, enum, properties, required, additionalProperties and items,
+// which is enough to describe the authorization details types of https://datatracker.ietf.org/doc/html/rfc9396
+package schema
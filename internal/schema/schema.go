@@ -0,0 +1,153 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+)
+
+// Validate validates a decoded JSON value against the given schema,
+// the schema is usually decoded from the YAML configuration.
+func Validate(schema map[string]any, value any) error {
+	return validate(schema, value, "$")
+}
+
+func validate(schema map[string]any, value any, path string) error {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	if schemaType, typeExists := schema["type"]; typeExists {
+		typeName, validTypeName := schemaType.(string)
+		if !validTypeName {
+			return fmt.Errorf("%s: invalid schema type %v", path, schemaType)
+		}
+		if !matchesType(typeName, value) {
+			return fmt.Errorf("%s: expected %s", path, typeName)
+		}
+	}
+
+	if enum, enumExists := schema["enum"]; enumExists {
+		values, validEnum := enum.([]any)
+		if !validEnum {
+			return fmt.Errorf("%s: invalid schema enum %v", path, enum)
+		}
+		matchesEnum := slices.ContainsFunc(values, func(enumValue any) bool {
+			return equals(enumValue, value)
+		})
+		if !matchesEnum {
+			return fmt.Errorf("%s: value %v is not allowed", path, value)
+		}
+	}
+
+	switch currentValue := value.(type) {
+	case map[string]any:
+		return validateObject(schema, currentValue, path)
+	case []any:
+		return validateArray(schema, currentValue, path)
+	}
+
+	return nil
+}
+
+func validateObject(schema map[string]any, value map[string]any, path string) error {
+	properties, _ := schema["properties"].(map[string]any)
+
+	if required, requiredExists := schema["required"].([]any); requiredExists {
+		for _, name := range required {
+			if _, exists := value[fmt.Sprintf("%v", name)]; !exists {
+				return fmt.Errorf("%s: missing required property %v", path, name)
+			}
+		}
+	}
+
+	for name, propertyValue := range value {
+		propertyPath := fmt.Sprintf("%s.%s", path, name)
+		propertySchema, propertyExists := properties[name]
+		if !propertyExists {
+			if additionalProperties, additionalExists := schema["additionalProperties"].(bool); additionalExists && !additionalProperties {
+				return fmt.Errorf("%s: property is not allowed", propertyPath)
+			}
+			continue
+		}
+		currentSchema, validSchema := propertySchema.(map[string]any)
+		if !validSchema {
+			return fmt.Errorf("%s: invalid schema %v", propertyPath, propertySchema)
+		}
+		if propertyError := validate(currentSchema, propertyValue, propertyPath); propertyError != nil {
+			return propertyError
+		}
+	}
+
+	return nil
+}
+
+func validateArray(schema map[string]any, value []any, path string) error {
+	items, itemsExists := schema["items"].(map[string]any)
+	if !itemsExists {
+		return nil
+	}
+
+	for index, item := range value {
+		if itemError := validate(items, item, fmt.Sprintf("%s[%d]", path, index)); itemError != nil {
+			return itemError
+		}
+	}
+
+	return nil
+}
+
+func matchesType(typeName string, value any) bool {
+	switch typeName {
+	case "object":
+		_, valid := value.(map[string]any)
+		return valid
+	case "array":
+		_, valid := value.([]any)
+		return valid
+	case "string":
+		_, valid := value.(string)
+		return valid
+	case "boolean":
+		_, valid := value.(bool)
+		return valid
+	case "number":
+		_, valid := toFloat(value)
+		return valid
+	case "integer":
+		number, valid := toFloat(value)
+		return valid && number == math.Trunc(number)
+	case "null":
+		return value == nil
+	default:
+		return false
+	}
+}
+
+// equals compares values decoded from YAML and JSON, where numbers may differ in their type.
+func equals(expected any, value any) bool {
+	expectedNumber, expectedIsNumber := toFloat(expected)
+	number, isNumber := toFloat(value)
+	if expectedIsNumber && isNumber {
+		return expectedNumber == number
+	}
+	return reflect.DeepEqual(expected, value)
+}
+
+func toFloat(value any) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case float32:
+		return float64(number), true
+	case int:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case uint64:
+		return float64(number), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,86 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"testing"
+)
+
+const testSchema = `
+type: object
+required: [type, actions]
+additionalProperties: false
+properties:
+  type:
+    type: string
+  actions:
+    type: array
+    items:
+      type: string
+      enum: [initiate, status]
+  instructedAmount:
+    type: object
+    required: [currency, amount]
+    properties:
+      currency:
+        type: string
+        enum: [EUR, USD]
+      amount:
+        type: number
+  retries:
+    type: integer
+    enum: [1, 2]
+`
+
+func Test_Validate(t *testing.T) {
+	schema := make(map[string]any)
+	unmarshalError := yaml.Unmarshal([]byte(testSchema), &schema)
+	if unmarshalError != nil {
+		t.Fatal(unmarshalError)
+	}
+
+	type validateParameter struct {
+		value string
+		valid bool
+	}
+
+	var validateParameters = []validateParameter{
+		{`{"type":"payment","actions":["initiate"]}`, true},
+		{`{"type":"payment","actions":["initiate","status"],"instructedAmount":{"currency":"EUR","amount":123.5}}`, true},
+		{`{"type":"payment","actions":[],"retries":2}`, true},
+		{`{"type":"payment"}`, false},
+		{`{"type":"payment","actions":["delete"]}`, false},
+		{`{"type":"payment","actions":"initiate"}`, false},
+		{`{"type":"payment","actions":[],"instructedAmount":{"currency":"GBP","amount":1}}`, false},
+		{`{"type":"payment","actions":[],"instructedAmount":{"currency":"EUR","amount":"1"}}`, false},
+		{`{"type":"payment","actions":[],"instructedAmount":{"currency":"EUR"}}`, false},
+		{`{"type":"payment","actions":[],"retries":1.5}`, false},
+		{`{"type":"payment","actions":[],"retries":3}`, false},
+		{`{"type":"payment","actions":[],"other":true}`, false},
+		{`["payment"]`, false},
+	}
+
+	for _, test := range validateParameters {
+		testMessage := fmt.Sprintf("Validate %s", test.value)
+		t.Run(testMessage, func(t *testing.T) {
+			var value any
+			jsonError := json.Unmarshal([]byte(test.value), &value)
+			if jsonError != nil {
+				t.Fatal(jsonError)
+			}
+
+			validateError := Validate(schema, value)
+			if test.valid != (validateError == nil) {
+				t.Errorf("expected valid to be %v, got %v", test.valid, validateError)
+			}
+		})
+	}
+}
+
+func Test_ValidateEmptySchema(t *testing.T) {
+	validateError := Validate(nil, map[string]any{"type": "payment"})
+	if validateError != nil {
+		t.Errorf("empty schema should allow all values, %v", validateError)
+	}
+}
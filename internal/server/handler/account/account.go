@@ -2,20 +2,26 @@ package account
 
 import (
 	"github.com/google/uuid"
+	"github.com/webishdev/stopnik/internal/audit"
+	"github.com/webishdev/stopnik/internal/config"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
 	"github.com/webishdev/stopnik/internal/manager/cookie"
 	"github.com/webishdev/stopnik/internal/manager/session"
+	"github.com/webishdev/stopnik/internal/manager/token"
 	"github.com/webishdev/stopnik/internal/server/handler/error"
 	"github.com/webishdev/stopnik/internal/server/validation"
 	"github.com/webishdev/stopnik/internal/template"
 	"github.com/webishdev/stopnik/log"
 	"net/http"
+	"slices"
 )
 
 type Handler struct {
+	config              *config.Config
 	validator           *validation.RequestValidator
 	cookieManager       *cookie.Manager
 	loginSessionManager session.LoginManager[session.LoginSession]
+	tokenManager        *token.Manager
 	templateManager     *template.Manager
 	errorHandler        *error.Handler
 }
@@ -24,12 +30,15 @@ func NewAccountHandler(
 	validator *validation.RequestValidator,
 	cookieManager *cookie.Manager,
 	loginSessionManager session.LoginManager[session.LoginSession],
+	tokenManager *token.Manager,
 	templateManager *template.Manager,
 ) *Handler {
 	return &Handler{
+		config:              config.GetConfigInstance(),
 		validator:           validator,
 		cookieManager:       cookieManager,
 		loginSessionManager: loginSessionManager,
+		tokenManager:        tokenManager,
 		templateManager:     templateManager,
 		errorHandler:        error.NewErrorHandler(),
 	}
@@ -38,9 +47,10 @@ func NewAccountHandler(
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.AccessLogRequest(r)
 	if r.Method == http.MethodGet {
-		user, _, validCookie := h.cookieManager.ValidateAuthCookie(r)
+		user, loginSession, validCookie := h.cookieManager.ValidateAuthCookie(r)
 		if validCookie {
-			logoutTemplate := h.templateManager.LogoutTemplate(user.Username, r.RequestURI)
+			revokeToken := h.validator.NewLoginToken(loginSession.Id)
+			logoutTemplate := h.templateManager.LogoutTemplate(user.Username, r.RequestURI, revokeToken, h.getOfflineClientIds(user.Username))
 
 			requestData := internalHttp.NewRequestData(r)
 			responseWriter := internalHttp.NewResponseWriter(w, requestData)
@@ -57,7 +67,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 			id := uuid.NewString()
 			loginToken := h.validator.NewLoginToken(id)
-			loginTemplate := h.templateManager.LoginTemplate(loginToken, "account", message)
+			loginTemplate := h.templateManager.LoginTemplate(loginToken, "account", message, "", false, false)
 
 			requestData := internalHttp.NewRequestData(r)
 			responseWriter := internalHttp.NewResponseWriter(w, requestData)
@@ -71,6 +81,12 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	} else if r.Method == http.MethodPost {
+		revokeClientId := r.PostFormValue("stopnik_revoke_client")
+		if revokeClientId != "" {
+			h.revokeOfflineAccess(w, r, revokeClientId)
+			return
+		}
+
 		// Handle POST from login
 		user, loginError := h.validator.ValidateFormLogin(r)
 		if loginError != nil {
@@ -82,12 +98,17 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		validation.RecordLoginSuccess(r, user)
+
+		amr := []string{config.AuthenticationMethodPassword}
 		loginSession := &session.LoginSession{
 			Id:       uuid.NewString(),
 			Username: user.Username,
+			Acr:      h.config.GetAcr(amr),
+			Amr:      amr,
 		}
-		h.loginSessionManager.StartSession(loginSession)
-		authCookie, authCookieError := h.cookieManager.CreateAuthCookie(user.Username, loginSession.Id)
+		h.loginSessionManager.StartSession(r.Context(), loginSession)
+		authCookie, authCookieError := h.cookieManager.AddAuthCookieSession(r, user.Username, loginSession.Id)
 		if authCookieError != nil {
 			h.errorHandler.InternalServerErrorHandler(w, r, authCookieError)
 			return
@@ -104,3 +125,36 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// revokeOfflineAccess revokes the offline access the current user granted to a client,
+// the form must provide a token issued for the current login session.
+func (h *Handler) revokeOfflineAccess(w http.ResponseWriter, r *http.Request, clientId string) {
+	user, loginSession, validCookie := h.cookieManager.ValidateAuthCookie(r)
+	if !validCookie {
+		h.errorHandler.ForbiddenHandler(w, r)
+		return
+	}
+
+	revokeToken, revokeTokenError := h.validator.GetLoginToken(r.PostFormValue("stopnik_auth_session"))
+	if revokeTokenError != nil || revokeToken.Subject() != loginSession.Id {
+		h.errorHandler.ForbiddenHandler(w, r)
+		return
+	}
+
+	h.tokenManager.RevokeOfflineTokens(r, user.Username, clientId)
+	audit.Record(r, &audit.Event{Type: audit.EtConsentRevoked, Outcome: audit.OcSuccess, ClientId: clientId, Username: user.Username})
+
+	w.Header().Set(internalHttp.Location, r.RequestURI)
+	w.WriteHeader(http.StatusSeeOther)
+}
+
+func (h *Handler) getOfflineClientIds(username string) []string {
+	var clientIds []string
+	for _, refreshToken := range h.tokenManager.GetOfflineTokens(username) {
+		if !slices.Contains(clientIds, refreshToken.ClientId) {
+			clientIds = append(clientIds, refreshToken.ClientId)
+		}
+	}
+	slices.Sort(clientIds)
+	return clientIds
+}
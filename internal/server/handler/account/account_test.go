@@ -1,6 +1,7 @@
 package account
 
 import (
+	"context"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/webishdev/stopnik/internal/config"
@@ -8,6 +9,8 @@ import (
 	internalHttp "github.com/webishdev/stopnik/internal/http"
 	"github.com/webishdev/stopnik/internal/manager/cookie"
 	"github.com/webishdev/stopnik/internal/manager/session"
+	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/oidc"
 	"github.com/webishdev/stopnik/internal/server/validation"
 	"github.com/webishdev/stopnik/internal/template"
 	"io"
@@ -30,10 +33,10 @@ func Test_AccountWithCookie(t *testing.T) {
 		Id:       uuid.NewString(),
 		Username: user.Username,
 	}
-	loginSessionManager.StartSession(loginSession)
+	loginSessionManager.StartSession(context.Background(), loginSession)
 	authCookie, _ := cookieManager.CreateAuthCookie(user.Username, loginSession.Id)
 
-	accountHandler := NewAccountHandler(requestValidator, cookieManager, loginSessionManager, templateManager)
+	accountHandler := NewAccountHandler(requestValidator, cookieManager, loginSessionManager, token.GetTokenManagerInstance(), templateManager)
 
 	rr := httptest.NewRecorder()
 
@@ -72,7 +75,7 @@ func Test_AccountWithoutCookie(t *testing.T) {
 	loginSessionManager := session.GetLoginSessionManagerInstance()
 	templateManager := template.GetTemplateManagerInstance()
 
-	accountHandler := NewAccountHandler(requestValidator, cookieManager, loginSessionManager, templateManager)
+	accountHandler := NewAccountHandler(requestValidator, cookieManager, loginSessionManager, token.GetTokenManagerInstance(), templateManager)
 
 	rr := httptest.NewRecorder()
 
@@ -121,7 +124,7 @@ func Test_AccountLogin(t *testing.T) {
 			loginSessionManager := session.GetLoginSessionManagerInstance()
 			templateManager := template.GetTemplateManagerInstance()
 
-			accountHandler := NewAccountHandler(requestValidator, cookieManager, loginSessionManager, templateManager)
+			accountHandler := NewAccountHandler(requestValidator, cookieManager, loginSessionManager, token.GetTokenManagerInstance(), templateManager)
 
 			loginToken := requestValidator.NewLoginToken(uuid.NewString())
 
@@ -166,7 +169,7 @@ func Test_AccountLogin(t *testing.T) {
 				t.Error("Should not have auth cookie after login")
 			}
 
-			_, loginSessionExists := loginSessionManager.SearchSession(test.username)
+			_, loginSessionExists := loginSessionManager.SearchSession(context.Background(), test.username)
 			if test.success != loginSessionExists {
 				t.Error("Login session state does not match")
 			}
@@ -185,7 +188,7 @@ func Test_AccountNotAllowedHttpMethods(t *testing.T) {
 		testMessage := fmt.Sprintf("Account with unsupported method %s", method)
 		t.Run(testMessage, func(t *testing.T) {
 			loginSessionManager := session.GetLoginSessionManagerInstance()
-			accountHandler := NewAccountHandler(&validation.RequestValidator{}, &cookie.Manager{}, loginSessionManager, &template.Manager{})
+			accountHandler := NewAccountHandler(&validation.RequestValidator{}, &cookie.Manager{}, loginSessionManager, &token.Manager{}, &template.Manager{})
 
 			rr := httptest.NewRecorder()
 
@@ -198,6 +201,82 @@ func Test_AccountNotAllowedHttpMethods(t *testing.T) {
 	}
 }
 
+func Test_AccountRevokeOfflineAccess(t *testing.T) {
+	testConfig := testInitializeConfig(t)
+
+	requestValidator := validation.NewRequestValidator()
+	cookieManager := cookie.GetCookieManagerInstance()
+	loginSessionManager := session.GetLoginSessionManagerInstance()
+	tokenManager := token.GetTokenManagerInstance()
+	templateManager := template.GetTemplateManagerInstance()
+
+	user, _ := testConfig.GetUser("foo")
+	loginSession := &session.LoginSession{
+		Id:       uuid.NewString(),
+		Username: user.Username,
+	}
+	loginSessionManager.StartSession(context.Background(), loginSession)
+	authCookie, _ := cookieManager.CreateAuthCookie(user.Username, loginSession.Id)
+
+	offlineClient := &config.Client{Id: "foo", Oidc: true, RefreshTTL: 60}
+	tokenRequest := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+	accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(tokenRequest, user.Username, offlineClient, nil, []string{oidc.ScopeOpenId, oidc.ScopeOfflineAccess}, nil, "", "", &token.GrantInput{OfflineAccess: true})
+
+	accountHandler := NewAccountHandler(requestValidator, cookieManager, loginSessionManager, tokenManager, templateManager)
+
+	rr := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, endpoint.Account, nil)
+	request.AddCookie(&authCookie)
+
+	accountHandler.ServeHTTP(rr, request)
+
+	body, bodyReadErr := io.ReadAll(rr.Result().Body)
+	if bodyReadErr != nil {
+		t.Fatal(bodyReadErr)
+	}
+
+	if !strings.Contains(string(body), "name=\"stopnik_revoke_client\" value=\"foo\"") {
+		t.Error("expected offline access of client foo on account page")
+	}
+
+	type parameter struct {
+		name           string
+		token          string
+		expectedStatus int
+	}
+
+	var parameters = []parameter{
+		{"invalid token", requestValidator.NewLoginToken(uuid.NewString()), http.StatusForbidden},
+		{"valid token", requestValidator.NewLoginToken(loginSession.Id), http.StatusSeeOther},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Revoke offline access with %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			bodyString := testCreateBody(
+				"stopnik_auth_session", test.token,
+				"stopnik_revoke_client", "foo",
+			)
+
+			rr := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, endpoint.Account, strings.NewReader(bodyString))
+			request.Header.Add(internalHttp.ContentType, "application/x-www-form-urlencoded")
+			request.AddCookie(&authCookie)
+
+			accountHandler.ServeHTTP(rr, request)
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+
+			_, refreshTokenExists := tokenManager.GetRefreshToken(context.Background(), accessTokenResponse.RefreshTokenValue)
+			if refreshTokenExists != (rr.Code != http.StatusSeeOther) {
+				t.Errorf("expected refresh token to exist %v", rr.Code != http.StatusSeeOther)
+			}
+		})
+	}
+}
+
 func testInitializeConfig(t *testing.T) *config.Config {
 	testConfig := &config.Config{
 		Clients: []config.Client{
@@ -69,7 +69,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		_, writeError := responseWriter.Write(result)
 		if writeError != nil {
-			log.Error("Could not send compressed data: %v", writeError)
+			log.ErrorContext(r.Context(), "Could not send compressed data", log.Err(writeError))
 		}
 	} else {
 		h.errorHandler.MethodNotAllowedHandler(w, r)
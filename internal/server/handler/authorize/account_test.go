@@ -0,0 +1,272 @@
+package authorize
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/endpoint"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/manager/cookie"
+	"github.com/webishdev/stopnik/internal/manager/session"
+	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/internal/oidc"
+	"github.com/webishdev/stopnik/internal/server/validation"
+	"github.com/webishdev/stopnik/internal/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_AuthorizeMultipleSessions(t *testing.T) {
+	testConfig := createAccountTestConfig(t)
+	client, _ := testConfig.GetClient("bar")
+
+	tokenRequest := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+	accessTokenResponse, _ := token.GetTokenManagerInstance().CreateAccessTokenResponse(tokenRequest, "foo", client, nil, []string{oidc.ScopeOpenId}, nil, "", "", nil)
+
+	type parameter struct {
+		name             string
+		cookieUsers      []string
+		values           map[string]string
+		expectedStatus   int
+		expectedError    string
+		expectedUsername string
+		expectedBody     []string
+	}
+
+	var parameters = []parameter{
+		{"current session", []string{"foo", "moo"}, nil, http.StatusFound, "", "moo", nil},
+		{"prompt none with multiple sessions", []string{"foo", "moo"}, map[string]string{oidc.ParameterPrompt: string(oidc.PtNone)}, http.StatusFound, string(oauth2.AuthorizationEtAccountSelectionRequired), "", nil},
+		{"prompt none with login hint", []string{"foo", "moo"}, map[string]string{oidc.ParameterPrompt: string(oidc.PtNone), oidc.ParameterLoginHint: "foo"}, http.StatusFound, "", "foo", nil},
+		{"prompt none with id token hint", []string{"foo", "moo"}, map[string]string{oidc.ParameterPrompt: string(oidc.PtNone), oidc.ParameterIdTokenHint: accessTokenResponse.IdTokenValue}, http.StatusFound, "", "foo", nil},
+		{"prompt none with id token hint of other user", []string{"moo"}, map[string]string{oidc.ParameterPrompt: string(oidc.PtNone), oidc.ParameterIdTokenHint: accessTokenResponse.IdTokenValue}, http.StatusFound, string(oauth2.AuthorizationEtLoginRequired), "", nil},
+		{"invalid id token hint", []string{"foo"}, map[string]string{oidc.ParameterIdTokenHint: "foo"}, http.StatusFound, string(oauth2.AuthorizationEtInvalidRequest), "", nil},
+		{"conflicting hints", []string{"foo"}, map[string]string{oidc.ParameterLoginHint: "moo", oidc.ParameterIdTokenHint: accessTokenResponse.IdTokenValue}, http.StatusFound, string(oauth2.AuthorizationEtInvalidRequest), "", nil},
+		{"login hint without session", []string{"moo"}, map[string]string{oidc.ParameterLoginHint: "boo"}, http.StatusOK, "", "", []string{`value="boo"`}},
+		{"select account", []string{"foo", "moo"}, map[string]string{oidc.ParameterPrompt: string(oidc.PtSelectAccount)}, http.StatusOK, "", "", []string{`value="foo"`, `value="moo"`}},
+		{"select account without session", nil, map[string]string{oidc.ParameterPrompt: string(oidc.PtSelectAccount)}, http.StatusOK, "", "", []string{"stopnik_password"}},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Multiple sessions, %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			parsedUri := createUri(t, endpoint.Authorization, func(query url.Values) {
+				query.Set(oauth2.ParameterClientId, "bar")
+				query.Set(oauth2.ParameterRedirectUri, "https://example.com/callback")
+				query.Set(oauth2.ParameterResponseType, oauth2.ParameterCode)
+				query.Set(oauth2.ParameterScope, oidc.ScopeOpenId)
+				for key, value := range test.values {
+					query.Set(key, value)
+				}
+			})
+			requestValidator := validation.NewRequestValidator()
+			authSessionManager := session.GetAuthSessionManagerInstance()
+			cookieManager := cookie.GetCookieManagerInstance()
+			loginSessionManager := session.GetLoginSessionManagerInstance()
+			tokenManager := token.GetTokenManagerInstance()
+
+			authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, template.GetTemplateManagerInstance())
+
+			rr := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, parsedUri.String(), nil)
+			if len(test.cookieUsers) > 0 {
+				authCookie := testCreateSessionsCookie(t, test.cookieUsers...)
+				request.AddCookie(&authCookie)
+			}
+
+			authorizeHandler.ServeHTTP(rr, request)
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+
+			if rr.Code == http.StatusOK {
+				body, bodyReadErr := io.ReadAll(rr.Result().Body)
+				if bodyReadErr != nil {
+					t.Fatal(bodyReadErr)
+				}
+				for _, expectedBody := range test.expectedBody {
+					if !strings.Contains(string(body), expectedBody) {
+						t.Errorf("expected %s in response body", expectedBody)
+					}
+				}
+				return
+			}
+
+			location, locationError := rr.Result().Location()
+			if locationError != nil {
+				t.Fatalf("location was not provied: %v", locationError)
+			}
+
+			if location.Query().Get(oauth2.ParameterError) != test.expectedError {
+				t.Fatalf("expected error %s, got %s", test.expectedError, location.Query().Get(oauth2.ParameterError))
+			}
+
+			if test.expectedUsername != "" {
+				authSession, sessionExists := authSessionManager.GetSession(context.Background(), location.Query().Get(oauth2.ParameterCode))
+				if !sessionExists || authSession.Username != test.expectedUsername {
+					t.Errorf("expected auth session for user %s, got %v", test.expectedUsername, authSession)
+				}
+			}
+		})
+	}
+}
+
+func Test_AuthorizeSelectAccountPost(t *testing.T) {
+	createAccountTestConfig(t)
+
+	type parameter struct {
+		name           string
+		account        string
+		prompt         *oidc.PromptType
+		maxAge         *int
+		expectedStatus int
+		expectedBody   string
+	}
+
+	promptLogin := oidc.PtLogin
+	maxAgeZero := 0
+	maxAgeHour := 3600
+
+	var parameters = []parameter{
+		{"existing account", "foo", nil, nil, http.StatusFound, ""},
+		{"existing account within max age", "foo", nil, &maxAgeHour, http.StatusFound, ""},
+		{"existing account exceeding max age", "foo", nil, &maxAgeZero, http.StatusOK, `value="foo"`},
+		{"existing account with prompt login", "foo", &promptLogin, nil, http.StatusOK, `value="foo"`},
+		{"another account", "", nil, nil, http.StatusOK, "stopnik_password"},
+		{"account without session", "boo", nil, nil, http.StatusOK, `value="boo"`},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Select account, %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			parsedUri := createUri(t, endpoint.Authorization, func(query url.Values) {
+				query.Set(oauth2.ParameterClientId, "bar")
+				query.Set(oauth2.ParameterRedirectUri, "https://example.com/callback")
+				query.Set(oauth2.ParameterResponseType, oauth2.ParameterCode)
+				query.Set(oauth2.ParameterScope, oidc.ScopeOpenId)
+				query.Set(oidc.ParameterPrompt, string(oidc.PtSelectAccount))
+			})
+
+			id := uuid.NewString()
+			authSession := &session.AuthSession{
+				Id:            id,
+				Redirect:      "https://example.com/callback",
+				AuthURI:       parsedUri.RequestURI(),
+				ClientId:      "bar",
+				ResponseTypes: []oauth2.ResponseType{oauth2.RtCode},
+				Scopes:        []string{oidc.ScopeOpenId},
+				Prompt:        test.prompt,
+				MaxAge:        test.maxAge,
+			}
+
+			requestValidator := validation.NewRequestValidator()
+			authSessionManager := session.GetAuthSessionManagerInstance()
+			loginSessionManager := session.GetLoginSessionManagerInstance()
+			cookieManager := cookie.GetCookieManagerInstance()
+			tokenManager := token.GetTokenManagerInstance()
+			authSessionManager.StartSession(context.Background(), authSession)
+
+			authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, template.GetTemplateManagerInstance())
+
+			bodyString := testCreateBody(
+				"stopnik_auth_session", requestValidator.NewLoginToken(id),
+				"stopnik_account", test.account,
+			)
+
+			rr := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, parsedUri.String(), strings.NewReader(bodyString))
+			request.Header.Add(internalHttp.ContentType, "application/x-www-form-urlencoded")
+			authCookie := testCreateSessionsCookie(t, "foo", "moo")
+			request.AddCookie(&authCookie)
+
+			authorizeHandler.ServeHTTP(rr, request)
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+
+			if rr.Code == http.StatusOK {
+				body, bodyReadErr := io.ReadAll(rr.Result().Body)
+				if bodyReadErr != nil {
+					t.Fatal(bodyReadErr)
+				}
+				if !strings.Contains(string(body), test.expectedBody) {
+					t.Errorf("expected %s in response body", test.expectedBody)
+				}
+				return
+			}
+
+			if authSession.Username != test.account {
+				t.Errorf("expected auth session for user %s, got %s", test.account, authSession.Username)
+			}
+
+			if len(rr.Result().Cookies()) != 1 {
+				t.Errorf("expected auth cookie to be set")
+			}
+		})
+	}
+}
+
+func testCreateSessionsCookie(t *testing.T, usernames ...string) http.Cookie {
+	cookieManager := cookie.GetCookieManagerInstance()
+	loginSessionManager := session.GetLoginSessionManagerInstance()
+
+	var authCookie http.Cookie
+	for _, username := range usernames {
+		loginSession := &session.LoginSession{
+			Id:       uuid.NewString(),
+			Username: username,
+		}
+		loginSessionManager.StartSession(context.Background(), loginSession)
+
+		request := httptest.NewRequest(http.MethodGet, endpoint.Authorization, nil)
+		if authCookie.Name != "" {
+			request.AddCookie(&authCookie)
+		}
+
+		var cookieError error
+		authCookie, cookieError = cookieManager.AddAuthCookieSession(request, username, loginSession.Id)
+		if cookieError != nil {
+			t.Fatal(cookieError)
+		}
+	}
+
+	return authCookie
+}
+
+func createAccountTestConfig(t *testing.T) *config.Config {
+	testConfig := &config.Config{
+		Server: config.Server{
+			PrivateKey: "../../../../.test_files/rsa256key.pem",
+		},
+		Clients: []config.Client{
+			{
+				Id:           "bar",
+				ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				Redirects:    []string{"https://example.com/callback"},
+				Oidc:         true,
+			},
+		},
+		Users: []config.User{
+			{
+				Username: "foo",
+				Password: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+			},
+			{
+				Username: "moo",
+				Password: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+			},
+		},
+	}
+	initializationError := config.Initialize(testConfig)
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+	return testConfig
+}
@@ -0,0 +1,255 @@
+package authorize
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/webishdev/stopnik/internal/audit"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
+	"github.com/webishdev/stopnik/internal/endpoint"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/manager/cookie"
+	"github.com/webishdev/stopnik/internal/manager/session"
+	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/internal/oidc"
+	"github.com/webishdev/stopnik/internal/server/validation"
+	"github.com/webishdev/stopnik/internal/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_AuthorizeStepUp(t *testing.T) {
+	testConfig := createAcrTestConfig(t)
+
+	type parameter struct {
+		name           string
+		clientId       string
+		sessionAcr     string
+		acrValues      string
+		expectedStatus int
+	}
+
+	var parameters = []parameter{
+		{"no acr requested", "bar", "password", "", http.StatusFound},
+		{"unknown acr requested", "bar", "password", "foo", http.StatusFound},
+		{"achieved acr requested", "bar", "password+otp", "password+otp", http.StatusFound},
+		{"weaker acr requested", "bar", "password+otp", "password", http.StatusFound},
+		{"stronger acr requested", "bar", "password", "password+otp", http.StatusOK},
+		{"client minimum", "strict", "password", "", http.StatusOK},
+		{"client minimum achieved", "strict", "password+otp", "password", http.StatusFound},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Step-up authentication, %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			parsedUri := createUri(t, endpoint.Authorization, func(query url.Values) {
+				query.Set(oauth2.ParameterClientId, test.clientId)
+				query.Set(oauth2.ParameterRedirectUri, "https://example.com/callback")
+				query.Set(oauth2.ParameterResponseType, oauth2.ParameterCode)
+				query.Set(oauth2.ParameterScope, oidc.ScopeOpenId)
+				if test.acrValues != "" {
+					query.Set(oidc.ParameterAcrValues, test.acrValues)
+				}
+			})
+			requestValidator := validation.NewRequestValidator()
+			authSessionManager := session.GetAuthSessionManagerInstance()
+			cookieManager := cookie.GetCookieManagerInstance()
+			loginSessionManager := session.GetLoginSessionManagerInstance()
+			tokenManager := token.GetTokenManagerInstance()
+
+			user, _ := testConfig.GetUser("foo")
+			loginSession := &session.LoginSession{
+				Id:       uuid.NewString(),
+				Username: user.Username,
+				Acr:      test.sessionAcr,
+				Amr:      testConfig.GetAuthenticationMethods(test.sessionAcr),
+			}
+			loginSessionManager.StartSession(context.Background(), loginSession)
+			authCookie, _ := cookieManager.CreateAuthCookie(user.Username, loginSession.Id)
+
+			authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, template.GetTemplateManagerInstance())
+
+			rr := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, parsedUri.String(), nil)
+			request.AddCookie(&authCookie)
+
+			authorizeHandler.ServeHTTP(rr, request)
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+
+			if rr.Code == http.StatusOK {
+				body, bodyReadErr := io.ReadAll(rr.Result().Body)
+				if bodyReadErr != nil {
+					t.Fatal(bodyReadErr)
+				}
+				if !strings.Contains(string(body), "stopnik_otp") {
+					t.Error("expected one-time password input on login page")
+				}
+				return
+			}
+
+			location, locationError := rr.Result().Location()
+			if locationError != nil {
+				t.Fatalf("location was not provied: %v", locationError)
+			}
+
+			authSession, sessionExists := authSessionManager.GetSession(context.Background(), location.Query().Get(oauth2.ParameterCode))
+			if !sessionExists || authSession.Acr != test.sessionAcr {
+				t.Errorf("expected auth session with acr %s, got %v", test.sessionAcr, authSession)
+			}
+		})
+	}
+}
+
+func Test_AuthorizeLoginAcr(t *testing.T) {
+	testConfig := createAcrTestConfig(t)
+
+	user, _ := testConfig.GetUser("foo")
+	otpKey, _ := user.GetOtpKey()
+
+	type parameter struct {
+		name           string
+		requiredAcr    string
+		otp            string
+		expectedStatus int
+		expectedAcr    string
+		expectedAmr    []string
+		expectedEvents []string
+	}
+
+	var parameters = []parameter{
+		{"password", "", "", http.StatusFound, "password", []string{config.AuthenticationMethodPassword}, []string{"login.success"}},
+		{"password with otp", "", crypto.Totp(otpKey, time.Now()), http.StatusFound, "password+otp", []string{config.AuthenticationMethodPassword, config.AuthenticationMethodOtp}, []string{"login.success"}},
+		{"otp reused", "password+otp", crypto.Totp(otpKey, time.Now()), http.StatusSeeOther, "", nil, []string{"login.failure reused_otp", "login.failure insufficient_acr"}},
+		{"otp required", "password+otp", crypto.Totp(otpKey, time.Now().Add(30*time.Second)), http.StatusFound, "password+otp", []string{config.AuthenticationMethodPassword, config.AuthenticationMethodOtp}, []string{"login.success"}},
+		{"otp required but missing", "password+otp", "", http.StatusSeeOther, "", nil, []string{"login.failure insufficient_acr"}},
+		{"otp required but invalid", "password+otp", "000000", http.StatusSeeOther, "", nil, []string{"login.failure invalid_otp", "login.failure insufficient_acr"}},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Login authentication level, %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			parsedUri := createUri(t, endpoint.Authorization, func(query url.Values) {
+				query.Set(oauth2.ParameterClientId, "bar")
+				query.Set(oauth2.ParameterRedirectUri, "https://example.com/callback")
+				query.Set(oauth2.ParameterResponseType, oauth2.ParameterCode)
+				query.Set(oauth2.ParameterScope, oidc.ScopeOpenId)
+			})
+
+			id := uuid.NewString()
+			authSession := &session.AuthSession{
+				Id:            id,
+				Redirect:      "https://example.com/callback",
+				AuthURI:       parsedUri.RequestURI(),
+				ClientId:      "bar",
+				ResponseTypes: []oauth2.ResponseType{oauth2.RtCode},
+				Scopes:        []string{oidc.ScopeOpenId},
+				RequiredAcr:   test.requiredAcr,
+			}
+
+			requestValidator := validation.NewRequestValidator()
+			authSessionManager := session.GetAuthSessionManagerInstance()
+			loginSessionManager := session.GetLoginSessionManagerInstance()
+			cookieManager := cookie.GetCookieManagerInstance()
+			tokenManager := token.GetTokenManagerInstance()
+			authSessionManager.StartSession(context.Background(), authSession)
+
+			authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, &template.Manager{})
+
+			bodyString := testCreateBody(
+				"stopnik_auth_session", requestValidator.NewLoginToken(id),
+				"stopnik_username", "foo",
+				"stopnik_password", "bar",
+				"stopnik_otp", test.otp,
+			)
+
+			var auditBuffer bytes.Buffer
+			audit.Configure(audit.NewWriterSink(&auditBuffer))
+			defer audit.Shutdown()
+
+			rr := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, parsedUri.String(), strings.NewReader(bodyString))
+			request.Header.Add(internalHttp.ContentType, "application/x-www-form-urlencoded")
+
+			authorizeHandler.ServeHTTP(rr, request)
+
+			var loginEvents []string
+			scanner := bufio.NewScanner(&auditBuffer)
+			for scanner.Scan() {
+				auditEvent := audit.Event{}
+				unmarshalError := json.Unmarshal(scanner.Bytes(), &auditEvent)
+				if unmarshalError != nil {
+					t.Fatal(unmarshalError)
+				}
+				if auditEvent.Type != audit.EtLoginSuccess && auditEvent.Type != audit.EtLoginFailure {
+					continue
+				}
+				loginEvents = append(loginEvents, strings.TrimSpace(fmt.Sprintf("%s %s", auditEvent.Type, auditEvent.Details["reason"])))
+			}
+			if !slices.Equal(loginEvents, test.expectedEvents) {
+				t.Errorf("login audit events did not match: got %v want %v", loginEvents, test.expectedEvents)
+			}
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+
+			if rr.Code == http.StatusFound && (authSession.Acr != test.expectedAcr || !slices.Equal(authSession.Amr, test.expectedAmr)) {
+				t.Errorf("expected acr %s and amr %v, got %s %v", test.expectedAcr, test.expectedAmr, authSession.Acr, authSession.Amr)
+			}
+		})
+	}
+}
+
+func createAcrTestConfig(t *testing.T) *config.Config {
+	testConfig := &config.Config{
+		Server: config.Server{
+			PrivateKey: "../../../../.test_files/rsa256key.pem",
+			AuthenticationLevels: []config.AuthenticationLevel{
+				{Acr: "password", Methods: []string{config.AuthenticationMethodPassword}},
+				{Acr: "password+otp", Methods: []string{config.AuthenticationMethodPassword, config.AuthenticationMethodOtp}},
+			},
+		},
+		Clients: []config.Client{
+			{
+				Id:           "bar",
+				ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				Redirects:    []string{"https://example.com/callback"},
+				Oidc:         true,
+			},
+			{
+				Id:           "strict",
+				ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				Redirects:    []string{"https://example.com/callback"},
+				Oidc:         true,
+				MinimumAcr:   "password+otp",
+			},
+		},
+		Users: []config.User{
+			{
+				Username:  "foo",
+				Password:  "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				OtpSecret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+			},
+		},
+	}
+
+	initializationError := config.Initialize(testConfig)
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+	return testConfig
+}
@@ -5,13 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
-	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/webishdev/stopnik/internal/audit"
 	"github.com/webishdev/stopnik/internal/config"
 	"github.com/webishdev/stopnik/internal/endpoint"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
 	"github.com/webishdev/stopnik/internal/manager/cookie"
+	"github.com/webishdev/stopnik/internal/manager/requestobject"
 	"github.com/webishdev/stopnik/internal/manager/session"
 	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/metrics"
 	"github.com/webishdev/stopnik/internal/oauth2"
 	"github.com/webishdev/stopnik/internal/oidc"
 	"github.com/webishdev/stopnik/internal/pkce"
@@ -29,28 +31,38 @@ import (
 )
 
 type authorizeRequestValues struct {
-	clientIdParameter            string
-	redirectParameter            string
-	responseTypeParameter        string
-	stateParameter               string
-	codeChallengeParameter       string
-	codeChallengeMethodParameter string
-	nonceParameter               string
-	promptParameter              string
-	maxAgeParameter              string
-	requestedScopes              []string
-	requestedClaims              *oidc.ClaimsParameter
+	clientIdParameter             string
+	redirectParameter             string
+	responseTypeParameter         string
+	stateParameter                string
+	codeChallengeParameter        string
+	codeChallengeMethodParameter  string
+	nonceParameter                string
+	promptParameter               string
+	maxAgeParameter               string
+	acrValuesParameter            string
+	loginHintParameter            string
+	idTokenHintParameter          string
+	requestedScopes               []string
+	requestedClaims               *oidc.ClaimsParameter
+	resourceParameters            []string
+	authorizationDetailsParameter string
+	requestParameter              string
+	requestUriParameter           string
+	responseModeParameter         string
+	responseMode                  oauth2.ResponseMode
 }
 
 type Handler struct {
-	config              *config.Config
-	validator           *validation.RequestValidator
-	cookieManager       *cookie.Manager
-	authSessionManager  session.Manager[session.AuthSession]
-	loginSessionManager session.Manager[session.LoginSession]
-	tokenManager        *token.Manager
-	templateManager     *template.Manager
-	errorHandler        *error.Handler
+	config               *config.Config
+	validator            *validation.RequestValidator
+	cookieManager        *cookie.Manager
+	authSessionManager   session.Manager[session.AuthSession]
+	loginSessionManager  session.Manager[session.LoginSession]
+	tokenManager         *token.Manager
+	requestObjectManager *requestobject.Manager
+	templateManager      *template.Manager
+	errorHandler         *error.Handler
 }
 
 func NewAuthorizeHandler(
@@ -62,14 +74,15 @@ func NewAuthorizeHandler(
 	templateManager *template.Manager) *Handler {
 	currentConfig := config.GetConfigInstance()
 	return &Handler{
-		config:              currentConfig,
-		validator:           validator,
-		cookieManager:       cookieManager,
-		authSessionManager:  authSessionManager,
-		loginSessionManager: loginSessionManager,
-		tokenManager:        tokenManager,
-		templateManager:     templateManager,
-		errorHandler:        error.NewErrorHandler(),
+		config:               currentConfig,
+		validator:            validator,
+		cookieManager:        cookieManager,
+		authSessionManager:   authSessionManager,
+		loginSessionManager:  loginSessionManager,
+		tokenManager:         tokenManager,
+		requestObjectManager: requestobject.GetRequestObjectManagerInstance(),
+		templateManager:      templateManager,
+		errorHandler:         error.NewErrorHandler(),
 	}
 }
 
@@ -99,11 +112,22 @@ func (h *Handler) handlePostRequest(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		authSessionId := loginToken.Subject()
-		authSession, authSessionExists := h.authSessionManager.GetSession(authSessionId)
+		authSession, authSessionExists := h.authSessionManager.GetSession(r.Context(), authSessionId)
 		if !authSessionExists {
 			h.sendRetryLocation(w, r, "")
 			return
 		}
+		log.AddRequestAttributes(r, log.ClientId(authSession.ClientId))
+
+		if r.PostForm.Has("stopnik_account") {
+			h.handleSelectAccount(w, r, authSession)
+			return
+		}
+
+		if r.PostForm.Has("stopnik_consent") && !r.PostForm.Has("stopnik_username") {
+			h.handleConsent(w, r, authSession)
+			return
+		}
 
 		user, loginError := h.validator.ValidateFormLogin(r)
 		if loginError != nil {
@@ -111,55 +135,139 @@ func (h *Handler) handlePostRequest(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		loginSession := &session.LoginSession{
-			Id:       uuid.NewString(),
-			Username: user.Username,
+		// https://datatracker.ietf.org/doc/html/rfc8176#section-2
+		amr := []string{config.AuthenticationMethodPassword}
+		if h.validator.ValidateFormOtp(r, user) {
+			amr = append(amr, config.AuthenticationMethodOtp)
 		}
-		h.loginSessionManager.StartSession(loginSession)
-		authCookie, authCookieError := h.cookieManager.CreateAuthCookie(user.Username, loginSession.Id)
-		if authCookieError != nil {
-			h.errorHandler.InternalServerErrorHandler(w, r, authCookieError)
+		acr := h.config.GetAcr(amr)
+		if !h.config.SatisfiesAcr(acr, authSession.RequiredAcr) {
+			log.ErrorContext(r.Context(), "Authentication level does not satisfy required level", "acr", acr, "required_acr", authSession.RequiredAcr)
+			metrics.Logins.Inc(metrics.ResultFailure)
+			validation.RecordLoginFailure(r, user.Username, "insufficient_acr")
+			h.sendDifferentRetryLocation(w, r, authSession.AuthURI, h.config.GetInvalidCredentialsMessage())
 			return
 		}
 
-		authSession.Username = user.Username
-		authSession.AuthTime = loginSession.StartTime
-		redirectURL, urlParseError := url.Parse(authSession.Redirect)
-		if urlParseError != nil {
-			h.errorHandler.InternalServerErrorHandler(w, r, urlParseError)
-			return
+		validation.RecordLoginSuccess(r, user)
+		applyOfflineConsent(r, authSession)
+
+		loginSession := &session.LoginSession{
+			Id:       uuid.NewString(),
+			Username: user.Username,
+			Acr:      acr,
+			Amr:      amr,
 		}
+		h.loginSessionManager.StartSession(r.Context(), loginSession)
 
-		http.SetCookie(w, &authCookie)
+		h.sendLoginResponse(w, r, authSession, user, loginSession)
+	} else {
+		authorizeRequest := h.parseRequest(r)
+		h.handleAuthorizeRequest(w, r, authorizeRequest)
+	}
 
-		responseTypes := authSession.ResponseTypes
+}
 
-		query := redirectURL.Query()
-		if slices.Contains(responseTypes, oauth2.RtToken) {
-			client, clientExists := h.validator.ValidateClientId(authSession.ClientId)
-			if !clientExists {
-				h.errorHandler.BadRequestHandler(w, r)
+// handleSelectAccount continues with the login session selected on the account selection page,
+// the login page is shown when another account is selected or the login session would force a login,
+// because it does not satisfy the required level, max_age or prompt=login of the authorization request.
+func (h *Handler) handleSelectAccount(w http.ResponseWriter, r *http.Request, authSession *session.AuthSession) {
+	username := r.PostFormValue("stopnik_account")
+	if username != "" {
+		for _, loginSession := range h.cookieManager.GetAuthCookieSessions(r) {
+			user, userExists := h.config.GetUser(loginSession.Username)
+			if userExists && user.Username == username && !h.forceLogin(loginSession, authSession.Prompt, authSession.MaxAge, authSession.RequiredAcr) {
+				h.sendLoginResponse(w, r, authSession, user, loginSession)
 				return
 			}
-			accessTokenResponse := h.tokenManager.CreateAccessTokenResponse(r, user.Username, client, &loginSession.StartTime, authSession.Scopes, authSession.RequestedClaims, authSession.Nonce, "")
-			setImplicitGrantParameter(query, accessTokenResponse)
-		} else if slices.Contains(responseTypes, oauth2.RtCode) {
-			setAuthorizationGrantParameter(query, authSession.Id)
-		} else {
-			oauth2.AuthorizationErrorResponseHandler(w, redirectURL, authSession.State, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtUnsupportedResponseType})
-			return
 		}
+	}
+
+	authSession.LoginHint = username
+	h.sendLogin(w, r, authSession)
+}
 
-		if authSession.State != "" {
-			query.Set(oauth2.ParameterState, authSession.State)
+// handleConsent continues with the current login session after the user decided about the requested offline access,
+// the login page is shown when the login session would force a login.
+func (h *Handler) handleConsent(w http.ResponseWriter, r *http.Request, authSession *session.AuthSession) {
+	loginSession, validCookie := selectLoginSession(h.cookieManager.GetAuthCookieSessions(r), authSession.LoginHint)
+	if validCookie && !h.forceLogin(loginSession, authSession.Prompt, authSession.MaxAge, authSession.RequiredAcr) {
+		user, userExists := h.config.GetUser(loginSession.Username)
+		if userExists {
+			applyOfflineConsent(r, authSession)
+			h.sendLoginResponse(w, r, authSession, user, loginSession)
+			return
 		}
+	}
+
+	h.sendLogin(w, r, authSession)
+}
+
+// applyOfflineConsent stores the consent to requested offline access in the auth session,
+// without an explicit approval the offline_access scope is removed.
+func applyOfflineConsent(r *http.Request, authSession *session.AuthSession) {
+	if !authSession.OfflineAccess {
+		return
+	}
+
+	authSession.OfflineConsent = r.PostFormValue("stopnik_consent") == "approve"
+	if !authSession.OfflineConsent {
+		authSession.Scopes = oidc.RemoveOfflineAccessScope(authSession.Scopes)
+	}
+}
+
+// sendLoginResponse makes the login session the current login session of the auth cookie and sends the authorization response.
+func (h *Handler) sendLoginResponse(w http.ResponseWriter, r *http.Request, authSession *session.AuthSession, user *config.User, loginSession *session.LoginSession) {
+	authCookie, authCookieError := h.cookieManager.AddAuthCookieSession(r, user.Username, loginSession.Id)
+	if authCookieError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, authCookieError)
+		return
+	}
+
+	authSession.Username = user.Username
+	authSession.AuthTime = loginSession.StartTime
+	authSession.Acr = loginSession.Acr
+	authSession.Amr = loginSession.Amr
+	redirectURL, urlParseError := url.Parse(authSession.Redirect)
+	if urlParseError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, urlParseError)
+		return
+	}
+
+	http.SetCookie(w, &authCookie)
 
-		sendFound(w, redirectURL, query)
+	responseTypes := authSession.ResponseTypes
+
+	query := url.Values{}
+	if slices.Contains(responseTypes, oauth2.RtToken) {
+		client, clientExists := h.validator.ValidateClientId(authSession.ClientId)
+		if !clientExists {
+			h.errorHandler.BadRequestHandler(w, r)
+			return
+		}
+		accessTokenResponse, tokenError := h.tokenManager.CreateAccessTokenResponse(r, user.Username, client, &loginSession.StartTime, authSession.Scopes, authSession.RequestedClaims, authSession.Nonce, "", &token.GrantInput{Resources: authSession.Resources, AuthorizationDetails: authSession.AuthorizationDetails, Acr: loginSession.Acr, Amr: loginSession.Amr, GrantType: oauth2.GtImplicit})
+		if tokenError != nil {
+			log.ErrorContext(r.Context(), "Could not create tokens", log.Err(tokenError))
+			errorParameters := getErrorParameters(authSession.State, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtServerError})
+			h.sendAuthorizationResponse(w, r, authSession.ClientId, redirectURL, authSession.ResponseMode, errorParameters)
+			return
+		}
+		metrics.TokensIssued.Inc(string(oauth2.GtImplicit), metrics.ClientLabel(client.Id, client.IsRegistered()))
+		setImplicitGrantParameter(query, accessTokenResponse)
+	} else if slices.Contains(responseTypes, oauth2.RtCode) {
+		setAuthorizationGrantParameter(query, authSession.Id)
 	} else {
-		authorizeRequest := h.parseRequest(r)
-		h.handleAuthorizeRequest(w, r, authorizeRequest)
+		errorParameters := getErrorParameters(authSession.State, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtUnsupportedResponseType})
+		h.sendAuthorizationResponse(w, r, authSession.ClientId, redirectURL, authSession.ResponseMode, errorParameters)
+		return
 	}
 
+	if authSession.State != "" {
+		query.Set(oauth2.ParameterState, authSession.State)
+	}
+
+	recordConsentGranted(r, authSession.ClientId, user.Username, authSession.Scopes)
+	h.sendAuthorizationResponse(w, r, authSession.ClientId, redirectURL, authSession.ResponseMode, query)
 }
 
 func (h *Handler) handleAuthorizeRequest(w http.ResponseWriter, r *http.Request, authorizeRequest *authorizeRequestValues) {
@@ -169,185 +277,369 @@ func (h *Handler) handleAuthorizeRequest(w http.ResponseWriter, r *http.Request,
 	}
 	client, exists := h.validator.ValidateClientId(authorizeRequest.clientIdParameter)
 	if !exists {
-		log.Error("Invalid client id %s", authorizeRequest.clientIdParameter)
+		log.ErrorContext(r.Context(), "Invalid client id", log.ClientId(authorizeRequest.clientIdParameter))
 		h.errorHandler.BadRequestHandler(w, r)
 		return
 	}
 
+	log.AddRequestAttributes(r, log.ClientId(client.Id))
+
+	authorizeRequest, invalidRequestObjectHandler := h.resolveRequestObject(r, client, authorizeRequest)
+	if invalidRequestObjectHandler != nil {
+		invalidRequestObjectHandler.ServeHTTP(w, r)
+		return
+	}
+
 	redirectURL, urlParseError := url.Parse(authorizeRequest.redirectParameter)
 	if urlParseError != nil {
-		log.Error("Could not parse redirect URI %s for client %s", authorizeRequest.redirectParameter, client.Id)
+		log.ErrorContext(r.Context(), "Could not parse redirect URI", "redirect_uri", authorizeRequest.redirectParameter)
 		h.errorHandler.BadRequestHandler(w, r)
 		return
 	}
 
-	invalidRedirectErrorHandler := h.validateRedirect(client, authorizeRequest.redirectParameter)
+	invalidRedirectErrorHandler := h.validateRedirect(r, client, authorizeRequest.redirectParameter)
 	if invalidRedirectErrorHandler != nil {
 		invalidRedirectErrorHandler.ServeHTTP(w, r)
 		return
 	}
 
-	responseTypes, invalidResponseTypeHandler := h.validResponseTypes(authorizeRequest, client, redirectURL)
+	responseTypes, invalidResponseTypeHandler := h.validResponseTypes(r, authorizeRequest, client, redirectURL)
 	if invalidResponseTypeHandler != nil {
 		invalidResponseTypeHandler.ServeHTTP(w, r)
 		return
 	}
 
+	invalidResponseModeHandler := h.validResponseMode(r, authorizeRequest, client, responseTypes, redirectURL)
+	if invalidResponseModeHandler != nil {
+		invalidResponseModeHandler.ServeHTTP(w, r)
+		return
+	}
+
 	invalidCodeChallengeHandler := h.validateCodeChallenge(responseTypes, authorizeRequest, redirectURL)
 	if invalidCodeChallengeHandler != nil {
 		invalidCodeChallengeHandler.ServeHTTP(w, r)
 		return
 	}
 
+	invalidResourceHandler := h.validateResources(r, authorizeRequest, redirectURL)
+	if invalidResourceHandler != nil {
+		invalidResourceHandler.ServeHTTP(w, r)
+		return
+	}
+
+	authorizationDetails, invalidAuthorizationDetailsHandler := h.validateAuthorizationDetails(r, authorizeRequest, redirectURL)
+	if invalidAuthorizationDetailsHandler != nil {
+		invalidAuthorizationDetailsHandler.ServeHTTP(w, r)
+		return
+	}
+
 	if log.IsDebug() {
-		log.Debug("Response types: %v", responseTypes)
-		log.Debug("Redirect URI: %s", authorizeRequest.redirectParameter)
-		log.Debug("State: %s", authorizeRequest.stateParameter)
-		log.Debug("Scope: %v", authorizeRequest.requestedScopes)
+		log.DebugContext(r.Context(), "Authorization request", "response_types", responseTypes, "redirect_uri", authorizeRequest.redirectParameter, "state", authorizeRequest.stateParameter, "scope", authorizeRequest.requestedScopes)
 	}
 
 	id := uuid.NewString()
 	authSession := createAuthSession(id, authorizeRequest, r, responseTypes)
+	authSession.AuthorizationDetails = authorizationDetails
 
-	invalidNonceHandler := h.validateNonce(client, authorizeRequest, authSession, redirectURL)
+	invalidNonceHandler := h.validateNonce(r, client, authorizeRequest, authSession, redirectURL)
 	if invalidNonceHandler != nil {
 		invalidNonceHandler.ServeHTTP(w, r)
 		return
 	}
 
-	invalidRequestedClaimsHandler := h.validateRequestedClaims(client, authorizeRequest, authSession, redirectURL)
+	invalidRequestedClaimsHandler := h.validateRequestedClaims(r, client, authorizeRequest, authSession, redirectURL)
 	if invalidRequestedClaimsHandler != nil {
 		invalidRequestedClaimsHandler.ServeHTTP(w, r)
 		return
 	}
 
+	invalidAcrValuesHandler := h.validateAcrValues(r, client, authorizeRequest, authSession, redirectURL)
+	if invalidAcrValuesHandler != nil {
+		invalidAcrValuesHandler.ServeHTTP(w, r)
+		return
+	}
+
+	invalidHintHandler := h.validateHints(r, client, authorizeRequest, authSession, redirectURL)
+	if invalidHintHandler != nil {
+		invalidHintHandler.ServeHTTP(w, r)
+		return
+	}
+
 	idTokenRequest := slices.Contains(responseTypes, oauth2.RtIdToken) && len(responseTypes) == 1
 
 	if !idTokenRequest {
-		h.authSessionManager.StartSession(authSession)
+		h.authSessionManager.StartSession(r.Context(), authSession)
 	}
 
-	user, loginSession, validCookie := h.cookieManager.ValidateAuthCookie(r)
+	loginSessions := h.cookieManager.GetAuthCookieSessions(r)
+	loginSession, validCookie := selectLoginSession(loginSessions, authSession.LoginHint)
 
-	promptType, invalidPromptTypeHandler := h.validatePromptType(client, authorizeRequest, validCookie, redirectURL)
+	promptType, invalidPromptTypeHandler := h.validatePromptType(r, client, authorizeRequest, validCookie, redirectURL)
 	if invalidPromptTypeHandler != nil {
 		invalidPromptTypeHandler.ServeHTTP(w, r)
 		return
 	}
 
-	maxAge, invalidMaxAgeHandler := h.validateMaxAge(client, authorizeRequest, redirectURL)
+	maxAge, invalidMaxAgeHandler := h.validateMaxAge(r, client, authorizeRequest, redirectURL)
 	if invalidMaxAgeHandler != nil {
 		invalidMaxAgeHandler.ServeHTTP(w, r)
 		return
 	}
+	authSession.Prompt = promptType
+	authSession.MaxAge = maxAge
+
+	h.validateOfflineAccess(r, client, authorizeRequest, authSession, promptType)
+
+	forceLogin := !validCookie || h.forceLogin(loginSession, promptType, maxAge, authSession.RequiredAcr)
+	if promptType != nil && *promptType == oidc.PtNone {
+		authorizeError := getPromptNoneError(forceLogin, authSession.LoginHint == "" && len(loginSessions) > 1)
+		if authorizeError != nil {
+			h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, authorizeError)
+			return
+		}
+	}
 
-	if validCookie && !h.forceLogin(loginSession, promptType, maxAge) {
+	if promptType != nil && *promptType == oidc.PtSelectAccount && len(loginSessions) > 0 {
+		h.sendSelectAccount(w, r, authSession, loginSessions)
+	} else if !forceLogin && authSession.OfflineAccess {
+		h.sendConsent(w, r, authSession, loginSession.Username)
+	} else if !forceLogin {
+		user, _ := h.config.GetUser(loginSession.Username)
 		authSession.Username = user.Username
 		authSession.AuthTime = loginSession.StartTime
+		authSession.Acr = loginSession.Acr
+		authSession.Amr = loginSession.Amr
 
-		query, authorizationErrorResponse := h.createLocationResponseQuery(r, redirectURL, user, client, authorizeRequest.requestedScopes, authSession, loginSession, responseTypes, authSession.Id, idTokenRequest, authorizeRequest.stateParameter)
+		query, authorizationErrorResponse := h.createLocationResponseQuery(r, user, client, authorizeRequest.requestedScopes, authSession, loginSession, responseTypes, authSession.Id, idTokenRequest, authorizeRequest.stateParameter)
 		if authorizationErrorResponse != nil {
-			oauth2.AuthorizationErrorResponseHandler(w, redirectURL, authorizeRequest.stateParameter, authorizationErrorResponse)
+			h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, authorizationErrorResponse)
 			return
 		}
 
-		sendFound(w, redirectURL, query)
+		recordConsentGranted(r, client.Id, user.Username, authorizeRequest.requestedScopes)
+		h.sendAuthorizationResponse(w, r, client.Id, redirectURL, authorizeRequest.responseMode, query)
 	} else {
 		// Show login page
-		h.sendLogin(w, r, authSession.Id)
+		h.sendLogin(w, r, authSession)
 	}
 }
 
-func (h *Handler) validateMaxAge(client *config.Client, authorizeRequest *authorizeRequestValues, redirectURL *url.URL) (*int, http.Handler) {
+func (h *Handler) validateMaxAge(r *http.Request, client *config.Client, authorizeRequest *authorizeRequestValues, redirectURL *url.URL) (*int, http.Handler) {
 	var maxAge *int
 	if client.Oidc && oidc.HasOidcScope(authorizeRequest.requestedScopes) && authorizeRequest.maxAgeParameter != "" {
 		maxAgeResult, maxAgeError := strconv.Atoi(authorizeRequest.maxAgeParameter)
 		if maxAgeError != nil {
 			return nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				oauth2.AuthorizationErrorResponseHandler(w, redirectURL, authorizeRequest.stateParameter, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest})
+				h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest})
 			})
 		}
 		maxAge = &maxAgeResult
 	} else if authorizeRequest.maxAgeParameter != "" {
-		log.Error("Max age used without OpenID Connect setting for client with id %s", client.Id)
+		log.ErrorContext(r.Context(), "Max age used without OpenID Connect setting")
 		return nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			oauth2.AuthorizationErrorResponseHandler(w, redirectURL, authorizeRequest.stateParameter, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest})
+			h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest})
 		})
 	}
 	return maxAge, nil
 }
 
-func (h *Handler) validatePromptType(client *config.Client, authorizeRequest *authorizeRequestValues, validCookie bool, redirectURL *url.URL) (*oidc.PromptType, http.Handler) {
+func (h *Handler) validatePromptType(r *http.Request, client *config.Client, authorizeRequest *authorizeRequestValues, validCookie bool, redirectURL *url.URL) (*oidc.PromptType, http.Handler) {
 	var promptType *oidc.PromptType
 	if client.Oidc && oidc.HasOidcScope(authorizeRequest.requestedScopes) && authorizeRequest.promptParameter != "" {
 		var authorizationErrorResponse *oauth2.AuthorizationErrorResponseParameter
 		promptType, authorizationErrorResponse = h.getPromptType(validCookie, authorizeRequest.promptParameter)
 		if authorizationErrorResponse != nil {
 			return nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				oauth2.AuthorizationErrorResponseHandler(w, redirectURL, authorizeRequest.stateParameter, authorizationErrorResponse)
+				h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, authorizationErrorResponse)
 			})
 		}
 	} else if authorizeRequest.promptParameter != "" {
-		log.Error("Prompt used without OpenID Connect setting for client with id %s", client.Id)
+		log.ErrorContext(r.Context(), "Prompt used without OpenID Connect setting")
 		return nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			oauth2.AuthorizationErrorResponseHandler(w, redirectURL, authorizeRequest.stateParameter, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest})
+			h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest})
 		})
 	}
 	return promptType, nil
 }
 
-func (h *Handler) validateRequestedClaims(client *config.Client, authorizeRequest *authorizeRequestValues, authSession *session.AuthSession, redirectURL *url.URL) http.Handler {
+func (h *Handler) validateRequestedClaims(r *http.Request, client *config.Client, authorizeRequest *authorizeRequestValues, authSession *session.AuthSession, redirectURL *url.URL) http.Handler {
 	if client.Oidc && oidc.HasOidcScope(authorizeRequest.requestedScopes) && authSession.RequestedClaims != nil {
 		authSession.RequestedClaims = authorizeRequest.requestedClaims
 	} else if authSession.RequestedClaims != nil {
-		log.Error("Requested claims used without OpenID Connect setting for client with id %s", client.Id)
+		log.ErrorContext(r.Context(), "Requested claims used without OpenID Connect setting")
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest})
+		})
+	}
+	return nil
+}
+
+// validateAcrValues determines the authentication level a login must achieve,
+// which is the first known requested value but never less than the minimum of the client.
+// See https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func (h *Handler) validateAcrValues(r *http.Request, client *config.Client, authorizeRequest *authorizeRequestValues, authSession *session.AuthSession, redirectURL *url.URL) http.Handler {
+	var acrValues []string
+	if client.Oidc && oidc.HasOidcScope(authorizeRequest.requestedScopes) && authorizeRequest.acrValuesParameter != "" {
+		acrValues = strings.Fields(authorizeRequest.acrValuesParameter)
+	} else if authorizeRequest.acrValuesParameter != "" {
+		log.ErrorContext(r.Context(), "ACR values used without OpenID Connect setting")
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest})
+		})
+	}
+	authSession.RequiredAcr = h.config.GetRequiredAcr(client, acrValues)
+	return nil
+}
+
+// validateHints resolves the user identified by login_hint or id_token_hint into the login hint of the auth session.
+// Only the login session of the hinted user is used and the username is prefilled on the login page.
+// The subject of the ID token is mapped back to the user.
+// See https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func (h *Handler) validateHints(r *http.Request, client *config.Client, authorizeRequest *authorizeRequestValues, authSession *session.AuthSession, redirectURL *url.URL) http.Handler {
+	loginHint := authorizeRequest.loginHintParameter
+	idTokenHint := authorizeRequest.idTokenHintParameter
+	if loginHint == "" && idTokenHint == "" {
+		return nil
+	}
+	if !client.Oidc || !oidc.HasOidcScope(authorizeRequest.requestedScopes) {
+		log.ErrorContext(r.Context(), "Login hints used without OpenID Connect setting")
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			oauth2.AuthorizationErrorResponseHandler(w, redirectURL, authorizeRequest.stateParameter, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest})
+			h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest})
 		})
 	}
+
+	if loginHint != "" {
+		authSession.LoginHint = loginHint
+		user, userExists := h.config.GetUser(loginHint)
+		if !userExists {
+			user, userExists = h.config.GetUserBySubject(client, loginHint)
+		}
+		if userExists {
+			authSession.LoginHint = user.Username
+		}
+	}
+
+	if idTokenHint != "" {
+		var user *config.User
+		userExists := false
+		idToken, validIdToken := h.tokenManager.ParseIdTokenHint(r, client, idTokenHint)
+		if validIdToken {
+			user, userExists = h.config.GetUserBySubject(client, idToken.Subject())
+		}
+		if !userExists || (loginHint != "" && authSession.LoginHint != user.Username) {
+			log.ErrorContext(r.Context(), "Invalid parameter", "parameter", oidc.ParameterIdTokenHint)
+			errorMessage := fmt.Sprintf("Invalid %s parameter value", oidc.ParameterIdTokenHint)
+			authorizeError := &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest, Description: errorMessage}
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, authorizeError)
+			})
+		}
+		authSession.LoginHint = user.Username
+	}
+
 	return nil
 }
 
-func (h *Handler) validateNonce(client *config.Client, authorizeRequest *authorizeRequestValues, authSession *session.AuthSession, redirectURL *url.URL) http.Handler {
+// validateOfflineAccess keeps the offline_access scope of OpenId Connect requests only for authorization codes requested with prompt=consent,
+// the user approves or denies the offline access on the login or consent page, otherwise the scope is ignored.
+// See https://openid.net/specs/openid-connect-core-1_0.html#OfflineAccess
+func (h *Handler) validateOfflineAccess(r *http.Request, client *config.Client, authorizeRequest *authorizeRequestValues, authSession *session.AuthSession, promptType *oidc.PromptType) {
+	if !client.Oidc || !oidc.HasOfflineAccessScope(authorizeRequest.requestedScopes) {
+		return
+	}
+
+	if slices.Contains(authSession.ResponseTypes, oauth2.RtCode) && promptType != nil && *promptType == oidc.PtConsent {
+		authSession.OfflineAccess = true
+		return
+	}
+
+	log.DebugContext(r.Context(), "Ignoring scope without consent", "scope", oidc.ScopeOfflineAccess)
+	authorizeRequest.requestedScopes = oidc.RemoveOfflineAccessScope(authorizeRequest.requestedScopes)
+	authSession.Scopes = authorizeRequest.requestedScopes
+}
+
+func (h *Handler) validateNonce(r *http.Request, client *config.Client, authorizeRequest *authorizeRequestValues, authSession *session.AuthSession, redirectURL *url.URL) http.Handler {
 	if client.Oidc && oidc.HasOidcScope(authorizeRequest.requestedScopes) && authorizeRequest.nonceParameter != "" {
 		authSession.Nonce = authorizeRequest.nonceParameter
 	} else if authorizeRequest.nonceParameter != "" {
-		log.Error("Nonce used without OpenID Connect setting for client with id %s", client.Id)
+		log.ErrorContext(r.Context(), "Nonce used without OpenID Connect setting")
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			oauth2.AuthorizationErrorResponseHandler(w, redirectURL, authorizeRequest.stateParameter, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest})
+			h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest})
 		})
 	}
 	return nil
 }
 
+// validateResources checks that each requested resource is configured,
+// see https://datatracker.ietf.org/doc/html/rfc8707#section-2
+func (h *Handler) validateResources(r *http.Request, authorizeRequest *authorizeRequestValues, redirectURL *url.URL) http.Handler {
+	for _, resourceId := range authorizeRequest.resourceParameters {
+		_, resourceExists := h.config.GetResource(resourceId)
+		if !resourceExists {
+			log.ErrorContext(r.Context(), "Invalid parameter", "parameter", oauth2.ParameterResource, "value", resourceId)
+			errorMessage := fmt.Sprintf("Invalid %s parameter value", oauth2.ParameterResource)
+			authorizeError := &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidTarget, Description: errorMessage}
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, authorizeError)
+			})
+		}
+	}
+	return nil
+}
+
+// validateAuthorizationDetails validates the authorization_details parameter,
+// see https://datatracker.ietf.org/doc/html/rfc9396#section-5
+func (h *Handler) validateAuthorizationDetails(r *http.Request, authorizeRequest *authorizeRequestValues, redirectURL *url.URL) ([]oauth2.AuthorizationDetail, http.Handler) {
+	if authorizeRequest.authorizationDetailsParameter == "" {
+		return nil, nil
+	}
+	authorizationDetails, validAuthorizationDetails := h.validator.ValidateAuthorizationDetails(authorizeRequest.authorizationDetailsParameter)
+	if !validAuthorizationDetails {
+		log.ErrorContext(r.Context(), "Invalid parameter", "parameter", oauth2.ParameterAuthorizationDetails)
+		errorMessage := fmt.Sprintf("Invalid %s parameter value", oauth2.ParameterAuthorizationDetails)
+		authorizeError := &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidAuthorizationDetails, Description: errorMessage}
+		return nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, authorizeError)
+		})
+	}
+	return authorizationDetails, nil
+}
+
 func (h *Handler) validateCodeChallenge(responseTypes []oauth2.ResponseType, authorizeRequest *authorizeRequestValues, redirectURL *url.URL) http.Handler {
 	isUnexpectedCodeChallenge := !slices.Contains(responseTypes, oauth2.RtCode) && authorizeRequest.codeChallengeParameter != "" && authorizeRequest.codeChallengeMethodParameter != ""
 	if isUnexpectedCodeChallenge {
 		errorMessage := fmt.Sprintf("Code challenge should only be used for response type %s", oauth2.RtCode)
 		authorizeError := &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest, Description: errorMessage}
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			oauth2.AuthorizationErrorResponseHandler(w, redirectURL, authorizeRequest.stateParameter, authorizeError)
+			h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, authorizeError)
 		})
 	}
 	return nil
 }
 
-func (h *Handler) validResponseTypes(authorizeRequest *authorizeRequestValues, client *config.Client, redirectURL *url.URL) ([]oauth2.ResponseType, http.Handler) {
+func (h *Handler) validResponseTypes(r *http.Request, authorizeRequest *authorizeRequestValues, client *config.Client, redirectURL *url.URL) ([]oauth2.ResponseType, http.Handler) {
 	responseTypes, validResponseTypes := h.getResponseTypes(authorizeRequest.responseTypeParameter)
 	if !validResponseTypes {
-		log.Error("Invalid %s parameter with value %s for client %s", oauth2.ParameterResponseType, authorizeRequest.responseTypeParameter, client.Id)
+		log.ErrorContext(r.Context(), "Invalid parameter", "parameter", oauth2.ParameterResponseType, "value", authorizeRequest.responseTypeParameter)
 		errorMessage := fmt.Sprintf("Invalid %s parameter value", oauth2.ParameterResponseType)
 		authorizeError := &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest, Description: errorMessage}
 		return nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			oauth2.AuthorizationErrorResponseHandler(w, redirectURL, authorizeRequest.stateParameter, authorizeError)
+			h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, authorizeError)
+		})
+	}
+	if !client.ValidateResponseType(responseTypes) {
+		log.ErrorContext(r.Context(), "Response type not allowed", "response_type", authorizeRequest.responseTypeParameter)
+		authorizeError := &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtUnauthorizedClient}
+		return nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, authorizeError)
 		})
 	}
 	return responseTypes, nil
 }
 
-func (h *Handler) validateRedirect(client *config.Client, redirect string) http.Handler {
+func (h *Handler) validateRedirect(r *http.Request, client *config.Client, redirect string) http.Handler {
 	if redirect == "" {
-		log.Error("Redirect provided for client %s was empty", client.Id)
+		log.ErrorContext(r.Context(), "Redirect provided was empty")
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			h.sendErrorPage(w, r, "No redirect provided")
 		})
@@ -355,7 +647,7 @@ func (h *Handler) validateRedirect(client *config.Client, redirect string) http.
 
 	validRedirect := client.ValidateRedirect(redirect)
 	if !validRedirect {
-		log.Error("Invalid redirect to %s for client %s", redirect, client.Id)
+		log.ErrorContext(r.Context(), "Invalid redirect", "redirect_uri", redirect)
 		message := fmt.Sprintf("Invalid redirect: %s", redirect)
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			h.sendErrorPage(w, r, message)
@@ -365,12 +657,22 @@ func (h *Handler) validateRedirect(client *config.Client, redirect string) http.
 	return nil
 }
 
-func (h *Handler) createLocationResponseQuery(r *http.Request, redirectURL *url.URL, user *config.User, client *config.Client, scopes []string, authSession *session.AuthSession, loginSession *session.LoginSession, responseTypes []oauth2.ResponseType, id string, idTokenRequest bool, stateParameter string) (url.Values, *oauth2.AuthorizationErrorResponseParameter) {
-	query := redirectURL.Query()
+func (h *Handler) createLocationResponseQuery(r *http.Request, user *config.User, client *config.Client, scopes []string, authSession *session.AuthSession, loginSession *session.LoginSession, responseTypes []oauth2.ResponseType, id string, idTokenRequest bool, stateParameter string) (url.Values, *oauth2.AuthorizationErrorResponseParameter) {
+	query := url.Values{}
 
 	var idToken string
-	accessTokenResponse := h.tokenManager.CreateAccessTokenResponse(r, user.Username, client, &loginSession.StartTime, scopes, authSession.RequestedClaims, authSession.Nonce, "")
+	grantInput := &token.GrantInput{Resources: authSession.Resources, AuthorizationDetails: authSession.AuthorizationDetails, Acr: loginSession.Acr, Amr: loginSession.Amr}
+	if slices.Contains(responseTypes, oauth2.RtToken) || !slices.Contains(responseTypes, oauth2.RtCode) {
+		// tokens of the authorization code flow are recorded when the code is exchanged
+		grantInput.GrantType = oauth2.GtImplicit
+	}
+	accessTokenResponse, tokenError := h.tokenManager.CreateAccessTokenResponse(r, user.Username, client, &loginSession.StartTime, scopes, authSession.RequestedClaims, authSession.Nonce, "", grantInput)
+	if tokenError != nil {
+		log.ErrorContext(r.Context(), "Could not create tokens", log.Err(tokenError))
+		return nil, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtServerError}
+	}
 	if slices.Contains(responseTypes, oauth2.RtToken) {
+		metrics.TokensIssued.Inc(string(oauth2.GtImplicit), metrics.ClientLabel(client.Id, client.IsRegistered()))
 		setImplicitGrantParameter(query, accessTokenResponse)
 	} else if slices.Contains(responseTypes, oauth2.RtCode) {
 		setAuthorizationGrantParameter(query, id)
@@ -380,7 +682,7 @@ func (h *Handler) createLocationResponseQuery(r *http.Request, redirectURL *url.
 		}
 		idToken = accessTokenResponse.IdTokenValue
 	} else {
-		log.Error("Invalid response type %v", responseTypes)
+		log.ErrorContext(r.Context(), "Invalid response type", "response_types", responseTypes)
 		return nil, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtUnsupportedResponseType}
 	}
 
@@ -411,10 +713,36 @@ func (h *Handler) getPromptType(validCookie bool, promptQueryParameter string) (
 	return &promptType, nil
 }
 
-func (h *Handler) forceLogin(loginSession *session.LoginSession, promptType *oidc.PromptType, maxAge *int) bool {
+// selectLoginSession returns the login session of the hinted user, without hint the current login session is returned.
+func selectLoginSession(loginSessions []*session.LoginSession, loginHint string) (*session.LoginSession, bool) {
+	for _, loginSession := range loginSessions {
+		if loginHint == "" || loginSession.Username == loginHint {
+			return loginSession, true
+		}
+	}
+	return nil, false
+}
+
+// getPromptNoneError returns the error for prompt=none, when the user would have to log in or select an account,
+// see https://openid.net/specs/openid-connect-core-1_0.html#AuthError
+func getPromptNoneError(forceLogin bool, selectAccount bool) *oauth2.AuthorizationErrorResponseParameter {
+	if forceLogin {
+		return &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtLoginRequired, Description: "Requested to skip login, but login is required"}
+	}
+	if selectAccount {
+		return &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtAccountSelectionRequired, Description: "Requested to skip account selection for multiple accounts"}
+	}
+	return nil
+}
+
+func (h *Handler) forceLogin(loginSession *session.LoginSession, promptType *oidc.PromptType, maxAge *int, requiredAcr string) bool {
 	if loginSession == nil {
 		return true
 	}
+	// step-up authentication, when the current session did not achieve the required level
+	if !h.config.SatisfiesAcr(loginSession.Acr, requiredAcr) {
+		return true
+	}
 	if promptType != nil {
 		switch *promptType {
 		case oidc.PtLogin:
@@ -461,12 +789,13 @@ func setIdTokenParameter(query url.Values, idToken string) {
 	query.Set(oidc.ParameterIdToken, idToken)
 }
 
-func (h *Handler) sendLogin(w http.ResponseWriter, r *http.Request, authSessionId string) {
+func (h *Handler) sendLogin(w http.ResponseWriter, r *http.Request, authSession *session.AuthSession) {
 	message := h.cookieManager.GetMessageCookieValue(r)
 
 	formAction := endpoint.Authorization[1:]
-	loginToken := h.validator.NewLoginToken(authSessionId)
-	loginTemplate := h.templateManager.LoginTemplate(loginToken, formAction, message)
+	loginToken := h.validator.NewLoginToken(authSession.Id)
+	otp := slices.Contains(h.config.GetAuthenticationMethods(authSession.RequiredAcr), config.AuthenticationMethodOtp)
+	loginTemplate := h.templateManager.LoginTemplate(loginToken, formAction, message, authSession.LoginHint, otp, authSession.OfflineAccess, authSession.AuthorizationDetails...)
 
 	requestData := internalHttp.NewRequestData(r)
 	responseWriter := internalHttp.NewResponseWriter(w, requestData)
@@ -480,6 +809,46 @@ func (h *Handler) sendLogin(w http.ResponseWriter, r *http.Request, authSessionI
 	}
 }
 
+// sendConsent shows the consent page for the requested offline access to the user of the current login session.
+func (h *Handler) sendConsent(w http.ResponseWriter, r *http.Request, authSession *session.AuthSession, username string) {
+	formAction := endpoint.Authorization[1:]
+	loginToken := h.validator.NewLoginToken(authSession.Id)
+	consentTemplate := h.templateManager.ConsentTemplate(loginToken, formAction, username)
+
+	requestData := internalHttp.NewRequestData(r)
+	responseWriter := internalHttp.NewResponseWriter(w, requestData)
+
+	responseWriter.SetEncodingHeader()
+
+	_, writeError := responseWriter.Write(consentTemplate.Bytes())
+	if writeError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, writeError)
+		return
+	}
+}
+
+func (h *Handler) sendSelectAccount(w http.ResponseWriter, r *http.Request, authSession *session.AuthSession, loginSessions []*session.LoginSession) {
+	var usernames []string
+	for _, loginSession := range loginSessions {
+		usernames = append(usernames, loginSession.Username)
+	}
+
+	formAction := endpoint.Authorization[1:]
+	loginToken := h.validator.NewLoginToken(authSession.Id)
+	selectAccountTemplate := h.templateManager.SelectAccountTemplate(loginToken, formAction, usernames)
+
+	requestData := internalHttp.NewRequestData(r)
+	responseWriter := internalHttp.NewResponseWriter(w, requestData)
+
+	responseWriter.SetEncodingHeader()
+
+	_, writeError := responseWriter.Write(selectAccountTemplate.Bytes())
+	if writeError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, writeError)
+		return
+	}
+}
+
 func (h *Handler) sendErrorPage(w http.ResponseWriter, r *http.Request, message string) {
 	errorTemplate := h.templateManager.ErrorTemplate(message)
 
@@ -513,6 +882,18 @@ func (h *Handler) sendDifferentRetryLocation(w http.ResponseWriter, r *http.Requ
 	w.WriteHeader(http.StatusSeeOther)
 }
 
+// recordConsentGranted records the authorization granted to a client,
+// there is no separate consent page, the authorization response itself is the grant.
+func recordConsentGranted(r *http.Request, clientId string, username string, scopes []string) {
+	audit.Record(r, &audit.Event{
+		Type:     audit.EtConsentGranted,
+		Outcome:  audit.OcSuccess,
+		ClientId: clientId,
+		Username: username,
+		Details:  map[string]string{"scope": strings.Join(scopes, " ")},
+	})
+}
+
 func sendFound(w http.ResponseWriter, redirectURL *url.URL, query url.Values) {
 	redirectURL.RawQuery = query.Encode()
 
@@ -521,117 +902,64 @@ func sendFound(w http.ResponseWriter, redirectURL *url.URL, query url.Values) {
 }
 
 func (h *Handler) parseRequest(r *http.Request) *authorizeRequestValues {
-	var clientIdParameter string
-	var stateParameter string
-	var responseTypeParameter string
-	var redirectParameter string
-	var scopeParameter string
-	var codeChallengeParameter string
-	var codeChallengeMethodParameter string
-	var nonceParameter string
-	var promptParameter string
-	var maxAgeParameter string
-	var requestParameter string
-	var claimsParameter string
-	var requestedClaims *oidc.ClaimsParameter
-
+	var values url.Values
 	if r.Method == http.MethodGet {
-		// OAuth2
-		clientIdParameter = r.URL.Query().Get(oauth2.ParameterClientId)
-		stateParameter = r.URL.Query().Get(oauth2.ParameterState)
-		responseTypeParameter = r.URL.Query().Get(oauth2.ParameterResponseType)
-		redirectParameter = r.URL.Query().Get(oauth2.ParameterRedirectUri)
-		scopeParameter = r.URL.Query().Get(oauth2.ParameterScope)
-
-		// PKCE
-		codeChallengeParameter = r.URL.Query().Get(pkce.ParameterCodeChallenge)
-		codeChallengeMethodParameter = r.URL.Query().Get(pkce.ParameterCodeChallengeMethod)
-
-		// OpenId Connect
-		nonceParameter = r.URL.Query().Get(oidc.ParameterNonce)
-		promptParameter = r.URL.Query().Get(oidc.ParameterPrompt)
-		maxAgeParameter = r.URL.Query().Get(oidc.ParameterMaxAge)
-		claimsParameter = r.URL.Query().Get(oidc.ParameterClaims)
-
-		// https://openid.net/specs/openid-connect-core-1_0.html#RequestObject
-		requestParameter = r.URL.Query().Get(oidc.ParameterRequest)
+		values = r.URL.Query()
 	} else if r.Method == http.MethodPost {
-		// OAuth2
-		clientIdParameter = r.PostFormValue(oauth2.ParameterClientId)
-		stateParameter = r.PostFormValue(oauth2.ParameterState)
-		responseTypeParameter = r.PostFormValue(oauth2.ParameterResponseType)
-		redirectParameter = r.PostFormValue(oauth2.ParameterRedirectUri)
-		scopeParameter = r.PostFormValue(oauth2.ParameterScope)
-
-		// PKCE
-		codeChallengeParameter = r.PostFormValue(pkce.ParameterCodeChallenge)
-		codeChallengeMethodParameter = r.PostFormValue(pkce.ParameterCodeChallengeMethod)
-
-		// OpenId Connect
-		nonceParameter = r.PostFormValue(oidc.ParameterNonce)
-		promptParameter = r.PostFormValue(oidc.ParameterPrompt)
-		maxAgeParameter = r.PostFormValue(oidc.ParameterMaxAge)
-		claimsParameter = r.PostFormValue(oidc.ParameterClaims)
-
-		// https://openid.net/specs/openid-connect-core-1_0.html#RequestObject
-		requestParameter = r.PostFormValue(oidc.ParameterRequest)
-	}
-
-	scopes := strings.Split(scopeParameter, " ")
-
-	if h.config.GetOidc() && oidc.HasOidcScope(scopes) && requestParameter != "" {
-		parsedRequestToken, requestParameterParseError := jwt.Parse([]byte(requestParameter), jwt.WithVerify(true))
-		if requestParameterParseError == nil {
-			// OAuth2
-			clientIdParameter = getClaimFromToken(parsedRequestToken, oauth2.ParameterClientId, clientIdParameter)
-			stateParameter = getClaimFromToken(parsedRequestToken, oauth2.ParameterState, stateParameter)
-			responseTypeParameter = getClaimFromToken(parsedRequestToken, oauth2.ParameterResponseType, responseTypeParameter)
-			redirectParameter = getClaimFromToken(parsedRequestToken, oauth2.ParameterRedirectUri, redirectParameter)
-			scopeParameter = getClaimFromToken(parsedRequestToken, oauth2.ParameterScope, scopeParameter)
-
-			// PKCE
-			codeChallengeParameter = getClaimFromToken(parsedRequestToken, pkce.ParameterCodeChallenge, codeChallengeParameter)
-			codeChallengeMethodParameter = getClaimFromToken(parsedRequestToken, pkce.ParameterCodeChallengeMethod, codeChallengeMethodParameter)
-
-			// OpenId Connect
-			nonceParameter = getClaimFromToken(parsedRequestToken, oidc.ParameterNonce, nonceParameter)
-			promptParameter = getClaimFromToken(parsedRequestToken, oidc.ParameterPrompt, promptParameter)
-			maxAgeParameter = getClaimFromToken(parsedRequestToken, oidc.ParameterMaxAge, maxAgeParameter)
-
-			requestedClaimsValue, requestedClaimsValueExists := parsedRequestToken.Get(oidc.ParameterClaims)
-			if requestedClaimsValueExists {
-				parameter, ok := requestedClaimsValue.(oidc.ClaimsParameter)
-				if ok {
-					requestedClaims = &parameter
-				} else {
-					log.Error("Could not extract claims from request object")
-				}
-			}
+		parseError := r.ParseForm()
+		if parseError != nil {
+			log.ErrorContext(r.Context(), "Could not parse form", log.Err(parseError))
 		}
+		values = r.PostForm
 	}
+	return h.parseRequestValues(r, values)
+}
+
+func (h *Handler) parseRequestValues(r *http.Request, values url.Values) *authorizeRequestValues {
+	var requestedClaims *oidc.ClaimsParameter
 
-	scopes = strings.Split(scopeParameter, " ")
+	scopes := strings.Split(values.Get(oauth2.ParameterScope), " ")
 
+	claimsParameter := values.Get(oidc.ParameterClaims)
 	if h.config.GetOidc() && claimsParameter != "" {
 		requestedClaims = &oidc.ClaimsParameter{}
 		claimsParameterParseError := json.Unmarshal([]byte(claimsParameter), requestedClaims)
 		if claimsParameterParseError != nil {
-			log.Error("Could not parse claims parameter %v", claimsParameterParseError)
+			log.ErrorContext(r.Context(), "Could not parse claims parameter", log.Err(claimsParameterParseError))
 		}
 	}
 
 	return &authorizeRequestValues{
-		clientIdParameter:            clientIdParameter,
-		redirectParameter:            redirectParameter,
-		responseTypeParameter:        responseTypeParameter,
-		stateParameter:               stateParameter,
-		codeChallengeParameter:       codeChallengeParameter,
-		codeChallengeMethodParameter: codeChallengeMethodParameter,
-		nonceParameter:               nonceParameter,
-		promptParameter:              promptParameter,
-		maxAgeParameter:              maxAgeParameter,
-		requestedScopes:              scopes,
-		requestedClaims:              requestedClaims,
+		// OAuth2
+		clientIdParameter:     values.Get(oauth2.ParameterClientId),
+		redirectParameter:     values.Get(oauth2.ParameterRedirectUri),
+		responseTypeParameter: values.Get(oauth2.ParameterResponseType),
+		responseModeParameter: values.Get(oauth2.ParameterResponseMode),
+		stateParameter:        values.Get(oauth2.ParameterState),
+		requestedScopes:       scopes,
+
+		// https://datatracker.ietf.org/doc/html/rfc8707#section-2.1
+		resourceParameters: values[oauth2.ParameterResource],
+
+		// https://datatracker.ietf.org/doc/html/rfc9396#section-2
+		authorizationDetailsParameter: values.Get(oauth2.ParameterAuthorizationDetails),
+
+		// PKCE
+		codeChallengeParameter:       values.Get(pkce.ParameterCodeChallenge),
+		codeChallengeMethodParameter: values.Get(pkce.ParameterCodeChallengeMethod),
+
+		// OpenId Connect
+		nonceParameter:       values.Get(oidc.ParameterNonce),
+		promptParameter:      values.Get(oidc.ParameterPrompt),
+		maxAgeParameter:      values.Get(oidc.ParameterMaxAge),
+		acrValuesParameter:   values.Get(oidc.ParameterAcrValues),
+		loginHintParameter:   values.Get(oidc.ParameterLoginHint),
+		idTokenHintParameter: values.Get(oidc.ParameterIdTokenHint),
+		requestedClaims:      requestedClaims,
+
+		// https://datatracker.ietf.org/doc/html/rfc9101#section-4
+		requestParameter:    values.Get(oidc.ParameterRequest),
+		requestUriParameter: values.Get(oidc.ParameterRequestUri),
 	}
 }
 
@@ -646,17 +974,8 @@ func createAuthSession(id string, authorizeRequest *authorizeRequestValues, r *h
 		ResponseTypes:       responseTypes,
 		Scopes:              authorizeRequest.requestedScopes,
 		State:               authorizeRequest.stateParameter,
+		ResponseMode:        authorizeRequest.responseMode,
 		RequestedClaims:     authorizeRequest.requestedClaims,
+		Resources:           authorizeRequest.resourceParameters,
 	}
 }
-
-func getClaimFromToken(token jwt.Token, claim string, defaultValue string) string {
-	value, exists := token.Get(claim)
-	if exists {
-		valueAsString := fmt.Sprintf("%s", value)
-		if valueAsString != "" {
-			return valueAsString
-		}
-	}
-	return defaultValue
-}
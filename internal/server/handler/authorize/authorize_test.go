@@ -1,6 +1,7 @@
 package authorize
 
 import (
+	"context"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/webishdev/stopnik/internal/config"
@@ -9,6 +10,7 @@ import (
 	"github.com/webishdev/stopnik/internal/manager/cookie"
 	"github.com/webishdev/stopnik/internal/manager/session"
 	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/metrics"
 	"github.com/webishdev/stopnik/internal/oauth2"
 	"github.com/webishdev/stopnik/internal/oidc"
 	"github.com/webishdev/stopnik/internal/pkce"
@@ -18,6 +20,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -455,6 +458,114 @@ func Test_AuthorizeInvalidResponseType(t *testing.T) {
 	}
 }
 
+func Test_AuthorizeResponseTypeNotAllowed(t *testing.T) {
+	createTestConfig(t)
+	parsedUri := createUri(t, endpoint.Authorization, func(query url.Values) {
+		query.Set(oauth2.ParameterClientId, "code_only")
+		query.Set(oauth2.ParameterRedirectUri, "https://example.com/callback")
+		query.Set(oauth2.ParameterResponseType, oauth2.ParameterToken)
+	})
+	requestValidator := validation.NewRequestValidator()
+	loginSessionManager := session.GetLoginSessionManagerInstance()
+
+	authorizeHandler := NewAuthorizeHandler(requestValidator, &cookie.Manager{}, &session.AuthManager{}, loginSessionManager, &token.Manager{}, &template.Manager{})
+
+	rr := httptest.NewRecorder()
+
+	authorizeHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, parsedUri.String(), nil))
+
+	if rr.Code != http.StatusFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusFound)
+	}
+
+	location, locationError := rr.Result().Location()
+	if locationError != nil {
+		t.Fatalf("location was not provied: %v", locationError)
+	}
+
+	if location.Query().Get(oauth2.ParameterError) != string(oauth2.AuthorizationEtUnauthorizedClient) {
+		t.Errorf("error type was not unauthorized_client: %v", location)
+	}
+}
+
+func Test_AuthorizeInvalidResource(t *testing.T) {
+	createTestConfig(t)
+	parsedUri := createUri(t, endpoint.Authorization, func(query url.Values) {
+		query.Set(oauth2.ParameterClientId, "foo")
+		query.Set(oauth2.ParameterRedirectUri, "https://example.com/callback")
+		query.Set(oauth2.ParameterResponseType, oauth2.ParameterCode)
+		query.Set(oauth2.ParameterResource, "https://unknown.example.com")
+	})
+	requestValidator := validation.NewRequestValidator()
+	loginSessionManager := session.GetLoginSessionManagerInstance()
+
+	authorizeHandler := NewAuthorizeHandler(requestValidator, &cookie.Manager{}, &session.AuthManager{}, loginSessionManager, &token.Manager{}, &template.Manager{})
+
+	rr := httptest.NewRecorder()
+
+	authorizeHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, parsedUri.String(), nil))
+
+	if rr.Code != http.StatusFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusFound)
+	}
+
+	location, locationError := rr.Result().Location()
+	if locationError != nil {
+		t.Errorf("location was not provied: %v", locationError)
+	}
+
+	errorQueryParameter := location.Query().Get(oauth2.ParameterError)
+
+	errorType, errorTypeExists := oauth2.AuthorizationErrorTypeFromString(errorQueryParameter)
+
+	if !errorTypeExists {
+		t.Errorf("error type could not be parsed: %v", errorQueryParameter)
+	}
+
+	if errorType != oauth2.AuthorizationEtInvalidTarget {
+		t.Errorf("error type was not invalid target: %v", errorQueryParameter)
+	}
+}
+
+func Test_AuthorizeInvalidAuthorizationDetails(t *testing.T) {
+	createTestConfig(t)
+	parsedUri := createUri(t, endpoint.Authorization, func(query url.Values) {
+		query.Set(oauth2.ParameterClientId, "foo")
+		query.Set(oauth2.ParameterRedirectUri, "https://example.com/callback")
+		query.Set(oauth2.ParameterResponseType, oauth2.ParameterCode)
+		query.Set(oauth2.ParameterAuthorizationDetails, `[{"type":"unknown"}]`)
+	})
+	requestValidator := validation.NewRequestValidator()
+	loginSessionManager := session.GetLoginSessionManagerInstance()
+
+	authorizeHandler := NewAuthorizeHandler(requestValidator, &cookie.Manager{}, &session.AuthManager{}, loginSessionManager, &token.Manager{}, &template.Manager{})
+
+	rr := httptest.NewRecorder()
+
+	authorizeHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, parsedUri.String(), nil))
+
+	if rr.Code != http.StatusFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusFound)
+	}
+
+	location, locationError := rr.Result().Location()
+	if locationError != nil {
+		t.Errorf("location was not provied: %v", locationError)
+	}
+
+	errorQueryParameter := location.Query().Get(oauth2.ParameterError)
+
+	errorType, errorTypeExists := oauth2.AuthorizationErrorTypeFromString(errorQueryParameter)
+
+	if !errorTypeExists {
+		t.Errorf("error type could not be parsed: %v", errorQueryParameter)
+	}
+
+	if errorType != oauth2.AuthorizationEtInvalidAuthorizationDetails {
+		t.Errorf("error type was not invalid authorization details: %v", errorQueryParameter)
+	}
+}
+
 func Test_AuthorizeInvalidRedirect(t *testing.T) {
 	createTestConfig(t)
 	type redirectTest struct {
@@ -539,22 +650,26 @@ func Test_AuthorizeAuthorizationGrant(t *testing.T) {
 		scope                   string
 		pkceCodeChallenge       string
 		pkceCodeChallengeMethod *pkce.CodeChallengeMethod
+		resource                string
+		authorizationDetails    string
 	}
 
 	ccmS256 := pkce.S256
 	ccmPlain := pkce.PLAIN
 
 	var authorizationGrantParameters = []authorizationGrantParameter{
-		{"", "", "", nil},
-		{"abc", "", "", nil},
-		{"", "foo:moo", "", nil},
-		{"abc", "foo:moo", "", nil},
-		{"abc", "foo:moo", uuid.New().String(), &ccmS256},
-		{"abc", "foo:moo", uuid.New().String(), &ccmPlain},
+		{"", "", "", nil, "", ""},
+		{"abc", "", "", nil, "", ""},
+		{"", "foo:moo", "", nil, "", ""},
+		{"abc", "foo:moo", "", nil, "", ""},
+		{"abc", "foo:moo", uuid.New().String(), &ccmS256, "", ""},
+		{"abc", "foo:moo", uuid.New().String(), &ccmPlain, "", ""},
+		{"abc", "foo:moo", "", nil, "https://api.example.com", ""},
+		{"abc", "foo:moo", "", nil, "", `[{"type":"payment_initiation"}]`},
 	}
 
 	for _, test := range authorizationGrantParameters {
-		testMessage := fmt.Sprintf("Cookie exists, authorization code grant with state %v scope %v code challenge %v", test.state, test.scope, test.pkceCodeChallenge)
+		testMessage := fmt.Sprintf("Cookie exists, authorization code grant with state %v scope %v code challenge %v resource %v authorization details %v", test.state, test.scope, test.pkceCodeChallenge, test.resource, test.authorizationDetails)
 		t.Run(testMessage, func(t *testing.T) {
 			pkceCodeChallenge := ""
 			parsedUri := createUri(t, endpoint.Authorization, func(query url.Values) {
@@ -571,6 +686,12 @@ func Test_AuthorizeAuthorizationGrant(t *testing.T) {
 					pkceCodeChallenge = pkce.CalculatePKCE(*test.pkceCodeChallengeMethod, test.pkceCodeChallenge)
 					query.Set(pkce.ParameterCodeChallenge, pkceCodeChallenge)
 				}
+				if test.resource != "" {
+					query.Set(oauth2.ParameterResource, test.resource)
+				}
+				if test.authorizationDetails != "" {
+					query.Set(oauth2.ParameterAuthorizationDetails, test.authorizationDetails)
+				}
 			})
 			requestValidator := validation.NewRequestValidator()
 			authSessionManager := session.GetAuthSessionManagerInstance()
@@ -583,7 +704,7 @@ func Test_AuthorizeAuthorizationGrant(t *testing.T) {
 				Id:       uuid.NewString(),
 				Username: user.Username,
 			}
-			loginSessionManager.StartSession(loginSession)
+			loginSessionManager.StartSession(context.Background(), loginSession)
 			authCookie, _ := cookieManager.CreateAuthCookie(user.Username, loginSession.Id)
 
 			authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, &template.Manager{})
@@ -615,7 +736,7 @@ func Test_AuthorizeAuthorizationGrant(t *testing.T) {
 				t.Errorf("state parameter %v did not match: %v", stateQueryParameter, test.state)
 			}
 
-			authSession, sessionExists := authSessionManager.GetSession(codeQueryParameter)
+			authSession, sessionExists := authSessionManager.GetSession(context.Background(), codeQueryParameter)
 			if !sessionExists {
 				t.Errorf("session does not exist: %v", codeQueryParameter)
 			}
@@ -624,6 +745,14 @@ func Test_AuthorizeAuthorizationGrant(t *testing.T) {
 				t.Errorf("session code challenge %v did not match: %v", authSession.CodeChallenge, pkceCodeChallenge)
 			}
 
+			if test.resource != "" && !slices.Contains(authSession.Resources, test.resource) {
+				t.Errorf("session resources %v did not contain: %v", authSession.Resources, test.resource)
+			}
+
+			if test.authorizationDetails != "" && len(authSession.AuthorizationDetails) != 1 {
+				t.Errorf("session authorization details %v did not match: %v", authSession.AuthorizationDetails, test.authorizationDetails)
+			}
+
 			if pkceCodeChallenge != "" {
 				validatePKCE := pkce.ValidatePKCE(*test.pkceCodeChallengeMethod, pkceCodeChallenge, test.pkceCodeChallenge)
 				if !validatePKCE {
@@ -660,7 +789,7 @@ func Test_AuthorizeAuthorizationGrantPromptLogin(t *testing.T) {
 		Id:       uuid.NewString(),
 		Username: user.Username,
 	}
-	loginSessionManager.StartSession(loginSession)
+	loginSessionManager.StartSession(context.Background(), loginSession)
 	authCookie, _ := cookieManager.CreateAuthCookie(user.Username, loginSession.Id)
 
 	authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, templateManager)
@@ -719,7 +848,7 @@ func Test_AuthorizeAuthorizationGrantUnknownPrompt(t *testing.T) {
 		Id:       uuid.NewString(),
 		Username: user.Username,
 	}
-	loginSessionManager.StartSession(loginSession)
+	loginSessionManager.StartSession(context.Background(), loginSession)
 	authCookie, _ := cookieManager.CreateAuthCookie(user.Username, loginSession.Id)
 
 	authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, templateManager)
@@ -789,7 +918,7 @@ func Test_AuthorizeImplicitGrant(t *testing.T) {
 				Id:       uuid.NewString(),
 				Username: user.Username,
 			}
-			loginSessionManager.StartSession(loginSession)
+			loginSessionManager.StartSession(context.Background(), loginSession)
 			authCookie, _ := cookieManager.CreateAuthCookie(user.Username, loginSession.Id)
 
 			authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, &template.Manager{})
@@ -809,19 +938,24 @@ func Test_AuthorizeImplicitGrant(t *testing.T) {
 				t.Errorf("location was not provied: %v", locationError)
 			}
 
-			accessTokenQueryParameter := location.Query().Get(oauth2.ParameterAccessToken)
+			fragment, fragmentError := url.ParseQuery(location.Fragment)
+			if fragmentError != nil {
+				t.Errorf("fragment was not parsed: %v", fragmentError)
+			}
+
+			accessTokenQueryParameter := fragment.Get(oauth2.ParameterAccessToken)
 
 			if accessTokenQueryParameter == "" {
 				t.Errorf("access token query parameter was not set")
 			}
 
-			tokenTypeQueryParameter := location.Query().Get(oauth2.ParameterTokenType)
+			tokenTypeQueryParameter := fragment.Get(oauth2.ParameterTokenType)
 
 			if tokenTypeQueryParameter != string(oauth2.TtBearer) {
 				t.Errorf("token type parameter %v did not match: %v", tokenTypeQueryParameter, oauth2.TtBearer)
 			}
 
-			expiresInTypeQueryParameter := location.Query().Get(oauth2.ParameterExpiresIn)
+			expiresInTypeQueryParameter := fragment.Get(oauth2.ParameterExpiresIn)
 			expiresIn, expiresParseError := strconv.Atoi(expiresInTypeQueryParameter)
 			if expiresParseError != nil {
 				t.Errorf("expires query parameter was not parsed: %v", expiresParseError)
@@ -837,6 +971,66 @@ func Test_AuthorizeImplicitGrant(t *testing.T) {
 	}
 }
 
+func Test_AuthorizeImplicitGrantMetrics(t *testing.T) {
+	testConfig := createTestConfig(t)
+
+	type implicitGrantMetricsParameter struct {
+		responseType string
+		expected     float64
+	}
+
+	var implicitGrantMetricsParameters = []implicitGrantMetricsParameter{
+		{oauth2.ParameterToken, 1},
+		{oidc.ParameterIdToken, 0},
+		{fmt.Sprintf("%s %s", oidc.ParameterIdToken, oauth2.ParameterToken), 1},
+	}
+
+	for _, test := range implicitGrantMetricsParameters {
+		testMessage := fmt.Sprintf("Issued tokens for response type %s", test.responseType)
+		t.Run(testMessage, func(t *testing.T) {
+			parsedUri := createUri(t, endpoint.Authorization, func(query url.Values) {
+				query.Set(oauth2.ParameterClientId, "bar")
+				query.Set(oauth2.ParameterRedirectUri, "https://example.com/callback")
+				query.Set(oauth2.ParameterResponseType, test.responseType)
+				query.Set(oauth2.ParameterScope, oidc.ScopeOpenId)
+				query.Set(oidc.ParameterNonce, "abc")
+			})
+			requestValidator := validation.NewRequestValidator()
+			authSessionManager := session.GetAuthSessionManagerInstance()
+			loginSessionManager := session.GetLoginSessionManagerInstance()
+			cookieManager := cookie.GetCookieManagerInstance()
+			tokenManager := token.GetTokenManagerInstance()
+
+			user, _ := testConfig.GetUser("foo")
+			loginSession := &session.LoginSession{
+				Id:       uuid.NewString(),
+				Username: user.Username,
+			}
+			loginSessionManager.StartSession(context.Background(), loginSession)
+			authCookie, _ := cookieManager.CreateAuthCookie(user.Username, loginSession.Id)
+
+			authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, &template.Manager{})
+
+			issuedBefore := metrics.TokensIssued.Value(string(oauth2.GtImplicit), "bar")
+
+			rr := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, parsedUri.String(), nil)
+			request.AddCookie(&authCookie)
+
+			authorizeHandler.ServeHTTP(rr, request)
+
+			if rr.Code != http.StatusFound {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusFound)
+			}
+
+			issued := metrics.TokensIssued.Value(string(oauth2.GtImplicit), "bar") - issuedBefore
+			if issued != test.expected {
+				t.Errorf("expected %v issued tokens, got %v", test.expected, issued)
+			}
+		})
+	}
+}
+
 func Test_AuthorizeValidLoginAuthorizationGrant(t *testing.T) {
 	testConfig := createTestConfig(t)
 
@@ -886,7 +1080,7 @@ func Test_AuthorizeValidLoginAuthorizationGrant(t *testing.T) {
 			loginSessionManager := session.GetLoginSessionManagerInstance()
 			cookieManager := cookie.GetCookieManagerInstance()
 			tokenManager := token.GetTokenManagerInstance()
-			authSessionManager.StartSession(authSession)
+			authSessionManager.StartSession(context.Background(), authSession)
 
 			authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, &template.Manager{})
 
@@ -979,7 +1173,7 @@ func Test_AuthorizeValidLoginImplicitGrant(t *testing.T) {
 			loginSessionManager := session.GetLoginSessionManagerInstance()
 			cookieManager := cookie.GetCookieManagerInstance()
 			tokenManager := token.GetTokenManagerInstance()
-			authSessionManager.StartSession(authSession)
+			authSessionManager.StartSession(context.Background(), authSession)
 
 			authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, &template.Manager{})
 
@@ -1038,6 +1232,9 @@ func Test_AuthorizeValidLoginImplicitGrant(t *testing.T) {
 
 func createTestConfig(t *testing.T) *config.Config {
 	testConfig := &config.Config{
+		Server: config.Server{
+			PrivateKey: "../../../../.test_files/rsa256key.pem",
+		},
 		Clients: []config.Client{
 			{
 				Id:           "foo",
@@ -1050,6 +1247,12 @@ func createTestConfig(t *testing.T) *config.Config {
 				Redirects:    []string{"https://example.com/callback"},
 				Oidc:         true,
 			},
+			{
+				Id:           "code_only",
+				ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				Redirects:    []string{"https://example.com/callback"},
+				GrantTypes:   []oauth2.GrantType{oauth2.GtAuthorizationCode},
+			},
 		},
 		Users: []config.User{
 			{
@@ -1057,6 +1260,12 @@ func createTestConfig(t *testing.T) *config.Config {
 				Password: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
 			},
 		},
+		Resources: []config.Resource{
+			{Id: "https://api.example.com"},
+		},
+		AuthorizationDetailTypes: []config.AuthorizationDetailType{
+			{Type: "payment_initiation"},
+		},
 	}
 
 	initializationError := config.Initialize(testConfig)
@@ -0,0 +1,175 @@
+package authorize
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/webishdev/stopnik/internal/endpoint"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/manager/cookie"
+	"github.com/webishdev/stopnik/internal/manager/session"
+	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/internal/oidc"
+	"github.com/webishdev/stopnik/internal/server/validation"
+	"github.com/webishdev/stopnik/internal/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_AuthorizeOfflineAccess(t *testing.T) {
+	createAccountTestConfig(t)
+
+	type parameter struct {
+		name            string
+		scope           string
+		prompt          string
+		expectedStatus  int
+		expectedOffline bool
+	}
+
+	var parameters = []parameter{
+		{"without offline access", oidc.ScopeOpenId, "", http.StatusFound, false},
+		{"offline access without consent", oidc.ScopeOpenId + " " + oidc.ScopeOfflineAccess, "", http.StatusFound, false},
+		{"offline access with consent", oidc.ScopeOpenId + " " + oidc.ScopeOfflineAccess, string(oidc.PtConsent), http.StatusOK, true},
+		{"consent without offline access", oidc.ScopeOpenId, string(oidc.PtConsent), http.StatusFound, false},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Offline access, %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			parsedUri := createUri(t, endpoint.Authorization, func(query url.Values) {
+				query.Set(oauth2.ParameterClientId, "bar")
+				query.Set(oauth2.ParameterRedirectUri, "https://example.com/callback")
+				query.Set(oauth2.ParameterResponseType, oauth2.ParameterCode)
+				query.Set(oauth2.ParameterScope, test.scope)
+				if test.prompt != "" {
+					query.Set(oidc.ParameterPrompt, test.prompt)
+				}
+			})
+			requestValidator := validation.NewRequestValidator()
+			authSessionManager := session.GetAuthSessionManagerInstance()
+			cookieManager := cookie.GetCookieManagerInstance()
+			loginSessionManager := session.GetLoginSessionManagerInstance()
+			tokenManager := token.GetTokenManagerInstance()
+
+			authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, template.GetTemplateManagerInstance())
+
+			rr := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, parsedUri.String(), nil)
+			authCookie := testCreateSessionsCookie(t, "foo")
+			request.AddCookie(&authCookie)
+
+			authorizeHandler.ServeHTTP(rr, request)
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+
+			if rr.Code == http.StatusOK {
+				body, bodyReadErr := io.ReadAll(rr.Result().Body)
+				if bodyReadErr != nil {
+					t.Fatal(bodyReadErr)
+				}
+				if !strings.Contains(string(body), "Requested offline access") {
+					t.Error("expected offline access consent on login page")
+				}
+				return
+			}
+
+			location, locationError := rr.Result().Location()
+			if locationError != nil {
+				t.Fatalf("location was not provied: %v", locationError)
+			}
+
+			authSession, sessionExists := authSessionManager.GetSession(context.Background(), location.Query().Get(oauth2.ParameterCode))
+			if !sessionExists || slices.Contains(authSession.Scopes, oidc.ScopeOfflineAccess) != test.expectedOffline {
+				t.Errorf("expected offline access %v, got %v", test.expectedOffline, authSession)
+			}
+		})
+	}
+}
+
+func Test_AuthorizeOfflineConsentPost(t *testing.T) {
+	createAccountTestConfig(t)
+
+	type parameter struct {
+		consent         string
+		expectedOffline bool
+	}
+
+	var parameters = []parameter{
+		{"approve", true},
+		{"deny", false},
+		{"", false},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Offline consent, %q", test.consent)
+		t.Run(testMessage, func(t *testing.T) {
+			parsedUri := createUri(t, endpoint.Authorization, func(query url.Values) {
+				query.Set(oauth2.ParameterClientId, "bar")
+				query.Set(oauth2.ParameterRedirectUri, "https://example.com/callback")
+				query.Set(oauth2.ParameterResponseType, oauth2.ParameterCode)
+				query.Set(oauth2.ParameterScope, oidc.ScopeOpenId+" "+oidc.ScopeOfflineAccess)
+				query.Set(oidc.ParameterPrompt, string(oidc.PtConsent))
+			})
+
+			id := uuid.NewString()
+			authSession := &session.AuthSession{
+				Id:            id,
+				Redirect:      "https://example.com/callback",
+				AuthURI:       parsedUri.RequestURI(),
+				ClientId:      "bar",
+				ResponseTypes: []oauth2.ResponseType{oauth2.RtCode},
+				Scopes:        []string{oidc.ScopeOpenId, oidc.ScopeOfflineAccess},
+				OfflineAccess: true,
+			}
+
+			requestValidator := validation.NewRequestValidator()
+			authSessionManager := session.GetAuthSessionManagerInstance()
+			loginSessionManager := session.GetLoginSessionManagerInstance()
+			cookieManager := cookie.GetCookieManagerInstance()
+			tokenManager := token.GetTokenManagerInstance()
+			authSessionManager.StartSession(context.Background(), authSession)
+
+			authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, template.GetTemplateManagerInstance())
+
+			bodyString := testCreateBody(
+				"stopnik_auth_session", requestValidator.NewLoginToken(id),
+				"stopnik_consent", test.consent,
+			)
+
+			rr := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, parsedUri.String(), strings.NewReader(bodyString))
+			request.Header.Add(internalHttp.ContentType, "application/x-www-form-urlencoded")
+			authCookie := testCreateSessionsCookie(t, "foo")
+			request.AddCookie(&authCookie)
+
+			authorizeHandler.ServeHTTP(rr, request)
+
+			if rr.Code != http.StatusFound {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusFound)
+			}
+
+			location, locationError := rr.Result().Location()
+			if locationError != nil {
+				t.Fatalf("location was not provied: %v", locationError)
+			}
+
+			codeSession, sessionExists := authSessionManager.GetSession(context.Background(), location.Query().Get(oauth2.ParameterCode))
+			if !sessionExists {
+				t.Fatal("expected auth session for code")
+			}
+
+			if codeSession.OfflineConsent != test.expectedOffline || slices.Contains(codeSession.Scopes, oidc.ScopeOfflineAccess) != test.expectedOffline {
+				t.Errorf("expected offline access %v, got %v", test.expectedOffline, codeSession)
+			}
+		})
+	}
+}
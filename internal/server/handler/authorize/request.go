@@ -0,0 +1,75 @@
+package authorize
+
+import (
+	"github.com/webishdev/stopnik/internal/config"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/log"
+	"net/http"
+	"net/url"
+)
+
+// resolveRequestObject replaces the authorization request parameters with the parameters of a request object,
+// passed either by value or by reference. Only client_id and response_type are taken from the request itself.
+// Implements https://datatracker.ietf.org/doc/html/rfc9101#section-5
+func (h *Handler) resolveRequestObject(r *http.Request, client *config.Client, authorizeRequest *authorizeRequestValues) (*authorizeRequestValues, http.Handler) {
+	if authorizeRequest.requestParameter == "" && authorizeRequest.requestUriParameter == "" {
+		if client.RequireSignedRequestObject {
+			log.ErrorContext(r.Context(), "Client requires a signed request object")
+			return nil, h.requestObjectErrorHandler(client, authorizeRequest, oauth2.AuthorizationEtInvalidRequest)
+		}
+		return authorizeRequest, nil
+	}
+
+	if authorizeRequest.requestParameter != "" && authorizeRequest.requestUriParameter != "" {
+		log.ErrorContext(r.Context(), "Both request and request_uri provided")
+		return nil, h.requestObjectErrorHandler(client, authorizeRequest, oauth2.AuthorizationEtInvalidRequest)
+	}
+
+	requestObject := authorizeRequest.requestParameter
+	if authorizeRequest.requestUriParameter != "" {
+		fetchedRequestObject, fetchError := h.requestObjectManager.Fetch(client, authorizeRequest.requestUriParameter)
+		if fetchError != nil {
+			return nil, h.requestObjectErrorHandler(client, authorizeRequest, oauth2.AuthorizationEtInvalidRequestUri)
+		}
+		requestObject = fetchedRequestObject
+	}
+
+	issuer := h.config.GetIssuer(internalHttp.NewRequestData(r))
+	values, parseError := h.requestObjectManager.Parse(client, requestObject, issuer)
+	if parseError != nil {
+		return nil, h.requestObjectErrorHandler(client, authorizeRequest, oauth2.AuthorizationEtInvalidRequestObject)
+	}
+
+	// https://openid.net/specs/openid-connect-core-1_0.html#RequestObject
+	if values.Has(oauth2.ParameterClientId) && values.Get(oauth2.ParameterClientId) != authorizeRequest.clientIdParameter {
+		log.ErrorContext(r.Context(), "Request object client_id does not match the request")
+		return nil, h.requestObjectErrorHandler(client, authorizeRequest, oauth2.AuthorizationEtInvalidRequestObject)
+	}
+	if values.Has(oauth2.ParameterResponseType) && authorizeRequest.responseTypeParameter != "" && values.Get(oauth2.ParameterResponseType) != authorizeRequest.responseTypeParameter {
+		log.ErrorContext(r.Context(), "Request object response_type does not match the request")
+		return nil, h.requestObjectErrorHandler(client, authorizeRequest, oauth2.AuthorizationEtInvalidRequestObject)
+	}
+
+	values.Set(oauth2.ParameterClientId, authorizeRequest.clientIdParameter)
+	if !values.Has(oauth2.ParameterResponseType) {
+		values.Set(oauth2.ParameterResponseType, authorizeRequest.responseTypeParameter)
+	}
+
+	return h.parseRequestValues(r, values), nil
+}
+
+// requestObjectErrorHandler redirects errors to the redirect_uri of the request when it is valid for the client,
+// with the response mode of the request, otherwise the request is rejected.
+func (h *Handler) requestObjectErrorHandler(client *config.Client, authorizeRequest *authorizeRequestValues, errorType oauth2.AuthorizationErrorType) http.Handler {
+	redirectURL, urlParseError := url.Parse(authorizeRequest.redirectParameter)
+	if authorizeRequest.redirectParameter == "" || urlParseError != nil || !client.ValidateRedirect(authorizeRequest.redirectParameter) {
+		return http.HandlerFunc(h.errorHandler.BadRequestHandler)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// an invalid response mode falls back to the default response mode of the response types
+		responseTypes, _ := h.getResponseTypes(authorizeRequest.responseTypeParameter)
+		_ = h.validResponseMode(r, authorizeRequest, client, responseTypes, redirectURL)
+		h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, &oauth2.AuthorizationErrorResponseParameter{Error: errorType})
+	})
+}
@@ -0,0 +1,201 @@
+package authorize
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
+	"github.com/webishdev/stopnik/internal/endpoint"
+	"github.com/webishdev/stopnik/internal/manager/cookie"
+	"github.com/webishdev/stopnik/internal/manager/session"
+	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/internal/oidc"
+	"github.com/webishdev/stopnik/internal/server/validation"
+	"github.com/webishdev/stopnik/internal/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_AuthorizeRequestObject(t *testing.T) {
+	signedRequestObject := testCreateRequestObject(t, "rsa256key.pem", "foo", oauth2.ParameterCode)
+	wrongKeyRequestObject := testCreateRequestObject(t, "ecdsa256key.pem", "foo", oauth2.ParameterCode)
+	otherResponseTypeRequestObject := testCreateRequestObject(t, "rsa256key.pem", "foo", oauth2.ParameterToken)
+	unsignedRequestObject := testCreateRequestObject(t, "", "baz", oauth2.ParameterCode)
+
+	requestUriServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(signedRequestObject))
+	}))
+	defer requestUriServer.Close()
+
+	testConfig := &config.Config{
+		Server: config.Server{
+			PrivateKey: "../../../../.test_files/rsa256key.pem",
+		},
+		Clients: []config.Client{
+			{
+				Id:               "foo",
+				ClientSecret:     "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				Redirects:        []string{"https://example.com/callback"},
+				RequestObjectKey: "../../../../.test_files/rsa256pub.pem",
+				RequestUris:      []string{requestUriServer.URL + "/request/"},
+			},
+			{
+				Id:                         "baz",
+				ClientSecret:               "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				Redirects:                  []string{"https://example.com/callback"},
+				RequireSignedRequestObject: true,
+			},
+		},
+		Users: []config.User{
+			{
+				Username: "foo",
+				Password: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+			},
+		},
+	}
+	initializationError := config.Initialize(testConfig)
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+
+	type requestObjectParameter struct {
+		name         string
+		clientId     string
+		request      string
+		requestUri   string
+		responseMode string
+		errorType    oauth2.AuthorizationErrorType
+	}
+
+	var requestObjectParameters = []requestObjectParameter{
+		{"by value", "foo", signedRequestObject, "", "", ""},
+		{"by reference", "foo", "", requestUriServer.URL + "/request/abc", "", ""},
+		{"with wrong key", "foo", wrongKeyRequestObject, "", "", oauth2.AuthorizationEtInvalidRequestObject},
+		{"with other response_type", "foo", otherResponseTypeRequestObject, "", "", oauth2.AuthorizationEtInvalidRequestObject},
+		{"with unregistered request_uri", "foo", "", requestUriServer.URL + "/other/abc", "", oauth2.AuthorizationEtInvalidRequestUri},
+		{"with request_uri sharing the prefix", "foo", "", requestUriServer.URL + "/request-other/abc", "", oauth2.AuthorizationEtInvalidRequestUri},
+		{"by value and by reference", "foo", signedRequestObject, requestUriServer.URL + "/request/abc", "", oauth2.AuthorizationEtInvalidRequest},
+		{"missing but required", "baz", "", "", "", oauth2.AuthorizationEtInvalidRequest},
+		{"with wrong key and fragment response mode", "foo", wrongKeyRequestObject, "", string(oauth2.RmFragment), oauth2.AuthorizationEtInvalidRequestObject},
+		{"unsigned but signing required", "baz", unsignedRequestObject, "", "", oauth2.AuthorizationEtInvalidRequestObject},
+	}
+
+	for _, test := range requestObjectParameters {
+		testMessage := fmt.Sprintf("Authorization request with request object %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			parsedUri := createUri(t, endpoint.Authorization, func(query url.Values) {
+				query.Set(oauth2.ParameterClientId, test.clientId)
+				query.Set(oauth2.ParameterRedirectUri, "https://example.com/callback")
+				query.Set(oauth2.ParameterResponseType, oauth2.ParameterCode)
+				query.Set(oauth2.ParameterState, "query")
+				if test.request != "" {
+					query.Set(oidc.ParameterRequest, test.request)
+				}
+				if test.requestUri != "" {
+					query.Set(oidc.ParameterRequestUri, test.requestUri)
+				}
+				if test.responseMode != "" {
+					query.Set(oauth2.ParameterResponseMode, test.responseMode)
+				}
+			})
+			requestValidator := validation.NewRequestValidator()
+			authSessionManager := session.GetAuthSessionManagerInstance()
+			cookieManager := cookie.GetCookieManagerInstance()
+			loginSessionManager := session.GetLoginSessionManagerInstance()
+			tokenManager := token.GetTokenManagerInstance()
+
+			user, _ := testConfig.GetUser("foo")
+			loginSession := &session.LoginSession{
+				Id:       uuid.NewString(),
+				Username: user.Username,
+			}
+			loginSessionManager.StartSession(context.Background(), loginSession)
+			authCookie, _ := cookieManager.CreateAuthCookie(user.Username, loginSession.Id)
+
+			authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, &template.Manager{})
+
+			rr := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, parsedUri.String(), nil)
+			request.AddCookie(&authCookie)
+
+			authorizeHandler.ServeHTTP(rr, request)
+
+			if rr.Code != http.StatusFound {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusFound)
+			}
+
+			location, locationError := rr.Result().Location()
+			if locationError != nil {
+				t.Fatalf("location was not provied: %v", locationError)
+			}
+
+			if test.errorType != "" {
+				errorParameters := location.Query()
+				if test.responseMode == string(oauth2.RmFragment) {
+					errorParameters, _ = url.ParseQuery(location.Fragment)
+				}
+				errorQueryParameter := errorParameters.Get(oauth2.ParameterError)
+				if errorQueryParameter != string(test.errorType) {
+					t.Errorf("error type %v did not match: %v", errorQueryParameter, test.errorType)
+				}
+				return
+			}
+
+			codeQueryParameter := location.Query().Get(oauth2.ParameterCode)
+			authSession, sessionExists := authSessionManager.GetSession(context.Background(), codeQueryParameter)
+			if !sessionExists {
+				t.Fatalf("session does not exist: %v", codeQueryParameter)
+			}
+
+			stateQueryParameter := location.Query().Get(oauth2.ParameterState)
+			if stateQueryParameter != "object" {
+				t.Errorf("state parameter %v was not taken from request object", stateQueryParameter)
+			}
+
+			if len(authSession.Scopes) != 1 || authSession.Scopes[0] != "foo:moo" {
+				t.Errorf("session scopes %v were not taken from request object", authSession.Scopes)
+			}
+		})
+	}
+}
+
+func testCreateRequestObject(t *testing.T, keyFile string, clientId string, responseType string) string {
+	token, buildError := jwt.NewBuilder().
+		Issuer(clientId).
+		Expiration(time.Now().Add(time.Minute)).
+		Claim(oauth2.ParameterClientId, clientId).
+		Claim(oauth2.ParameterResponseType, responseType).
+		Claim(oauth2.ParameterRedirectUri, "https://example.com/callback").
+		Claim(oauth2.ParameterScope, "foo:moo").
+		Claim(oauth2.ParameterState, "object").
+		Build()
+	if buildError != nil {
+		t.Fatal(buildError)
+	}
+
+	if keyFile == "" {
+		unsignedToken, signError := jwt.Sign(token, jwt.WithInsecureNoSignature())
+		if signError != nil {
+			t.Fatal(signError)
+		}
+		return string(unsignedToken)
+	}
+
+	signingPrivateKey, loadError := crypto.LoadPrivateKey("../../../../.test_files/" + keyFile)
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+
+	signedToken, signError := jwt.Sign(token, jwt.WithKey(signingPrivateKey.SignatureAlgorithm, signingPrivateKey.PrivateKey))
+	if signError != nil {
+		t.Fatal(signError)
+	}
+
+	return string(signedToken)
+}
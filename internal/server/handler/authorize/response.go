@@ -0,0 +1,175 @@
+package authorize
+
+import (
+	"errors"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/webishdev/stopnik/internal/config"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// responseJwtDuration is the lifetime of JWT-secured authorization responses,
+// see https://openid.net/specs/oauth-v2-jarm.html#section-2.1
+const responseJwtDuration = 10 * time.Minute
+
+// jwtSuffix marks JWT-secured response modes, see https://openid.net/specs/oauth-v2-jarm.html#section-2.3
+const jwtSuffix = ".jwt"
+
+// getResponseMode returns the requested response mode or the default response mode of the response types.
+// Responses containing tokens must not be encoded in the query,
+// see https://openid.net/specs/oauth-v2-multiple-response-types-1_0.html#Encoding
+func getResponseMode(responseModeParameter string, responseTypes []oauth2.ResponseType) (oauth2.ResponseMode, bool) {
+	tokenResponse := slices.ContainsFunc(responseTypes, func(responseType oauth2.ResponseType) bool {
+		return responseType != oauth2.RtCode
+	})
+
+	defaultResponseMode := oauth2.RmQuery
+	if tokenResponse {
+		defaultResponseMode = oauth2.RmFragment
+	}
+
+	if responseModeParameter == "" {
+		return defaultResponseMode, true
+	}
+
+	responseMode, validResponseMode := oauth2.ResponseModeFromString(responseModeParameter)
+	if !validResponseMode {
+		return defaultResponseMode, false
+	}
+
+	if responseMode == oauth2.RmJwt {
+		responseMode = defaultResponseMode + jwtSuffix
+	}
+
+	if tokenResponse && (responseMode == oauth2.RmQuery || responseMode == oauth2.RmQueryJwt) {
+		return defaultResponseMode, false
+	}
+
+	return responseMode, true
+}
+
+func (h *Handler) validResponseMode(r *http.Request, authorizeRequest *authorizeRequestValues, client *config.Client, responseTypes []oauth2.ResponseType, redirectURL *url.URL) http.Handler {
+	responseMode, validResponseMode := getResponseMode(authorizeRequest.responseModeParameter, responseTypes)
+	if validResponseMode && strings.HasSuffix(string(responseMode), jwtSuffix) && !h.tokenManager.HasPrivateKey(client) {
+		// JWT-secured responses must be verifiable by the client, which is not possible with the server secret
+		log.ErrorContext(r.Context(), "No private key for parameter", "parameter", oauth2.ParameterResponseMode, "value", authorizeRequest.responseModeParameter)
+		responseMode = oauth2.ResponseMode(strings.TrimSuffix(string(responseMode), jwtSuffix))
+		validResponseMode = false
+	}
+	authorizeRequest.responseMode = responseMode
+	if !validResponseMode {
+		log.ErrorContext(r.Context(), "Invalid parameter", "parameter", oauth2.ParameterResponseMode, "value", authorizeRequest.responseModeParameter, "response_types", responseTypes)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.sendAuthorizationError(w, r, authorizeRequest, redirectURL, &oauth2.AuthorizationErrorResponseParameter{Error: oauth2.AuthorizationEtInvalidRequest})
+		})
+	}
+	return nil
+}
+
+// sendAuthorizationError sends an error response with the response mode of the authorization request.
+func (h *Handler) sendAuthorizationError(w http.ResponseWriter, r *http.Request, authorizeRequest *authorizeRequestValues, redirectURL *url.URL, errorResponseParameter *oauth2.AuthorizationErrorResponseParameter) {
+	parameters := getErrorParameters(authorizeRequest.stateParameter, errorResponseParameter)
+	h.sendAuthorizationResponse(w, r, authorizeRequest.clientIdParameter, redirectURL, authorizeRequest.responseMode, parameters)
+}
+
+// sendAuthorizationResponse sends the parameters of an authorization response to the redirect URI.
+// For JWT-secured response modes the parameters are sent as signed JWT in the response parameter,
+// implements https://openid.net/specs/oauth-v2-jarm.html#section-2.3
+// The issuer is added to each response, as JWT claim or as parameter,
+// implements https://datatracker.ietf.org/doc/html/rfc9207#section-2
+func (h *Handler) sendAuthorizationResponse(w http.ResponseWriter, r *http.Request, clientId string, redirectURL *url.URL, responseMode oauth2.ResponseMode, parameters url.Values) {
+	parameters.Set(oauth2.ParameterIssuer, h.config.GetIssuer(internalHttp.NewRequestData(r)))
+
+	if strings.HasSuffix(string(responseMode), jwtSuffix) {
+		responseJwt, jwtError := h.createResponseJwt(r, clientId, parameters)
+		if jwtError != nil {
+			h.errorHandler.InternalServerErrorHandler(w, r, jwtError)
+			return
+		}
+		parameters = url.Values{oauth2.ParameterResponse: {responseJwt}}
+		responseMode = oauth2.ResponseMode(strings.TrimSuffix(string(responseMode), jwtSuffix))
+	}
+
+	switch responseMode {
+	case oauth2.RmFragment:
+		location := *redirectURL
+		location.Fragment = ""
+		location.RawFragment = ""
+		w.Header().Set(internalHttp.Location, location.String()+"#"+parameters.Encode())
+		w.WriteHeader(http.StatusFound)
+	case oauth2.RmFormPost:
+		h.sendFormPost(w, r, redirectURL, parameters)
+	default:
+		query := redirectURL.Query()
+		for name, values := range parameters {
+			query[name] = values
+		}
+		sendFound(w, redirectURL, query)
+	}
+}
+
+// sendFormPost implements https://openid.net/specs/oauth-v2-form-post-response-mode-1_0.html#FormPostResponseMode
+func (h *Handler) sendFormPost(w http.ResponseWriter, r *http.Request, redirectURL *url.URL, parameters url.Values) {
+	formPostTemplate := h.templateManager.FormPostTemplate(redirectURL.String(), parameters)
+
+	requestData := internalHttp.NewRequestData(r)
+	responseWriter := internalHttp.NewResponseWriter(w, requestData)
+
+	responseWriter.SetEncodingHeader()
+	w.Header().Set(internalHttp.CacheControl, "no-store")
+
+	_, writeError := responseWriter.Write(formPostTemplate.Bytes())
+	if writeError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, writeError)
+		return
+	}
+}
+
+func (h *Handler) createResponseJwt(r *http.Request, clientId string, parameters url.Values) (string, error) {
+	client, clientExists := h.config.GetClient(clientId)
+	if !clientExists {
+		return "", errors.New("no client for JWT-secured authorization response")
+	}
+
+	builder := jwt.NewBuilder().
+		Issuer(h.config.GetIssuer(internalHttp.NewRequestData(r))).
+		Audience([]string{clientId}).
+		Expiration(time.Now().Add(responseJwtDuration))
+	for name := range parameters {
+		if name != oauth2.ParameterIssuer {
+			builder.Claim(name, parameters.Get(name))
+		}
+	}
+
+	responseToken, buildError := builder.Build()
+	if buildError != nil {
+		return "", buildError
+	}
+
+	return h.tokenManager.SignTokenWithPrivateKey(client, responseToken, nil)
+}
+
+func getErrorParameters(state string, errorResponseParameter *oauth2.AuthorizationErrorResponseParameter) url.Values {
+	parameters := url.Values{}
+	if errorResponseParameter == nil {
+		parameters.Set(oauth2.ParameterError, string(oauth2.AuthorizationEtServerError))
+	} else {
+		parameters.Set(oauth2.ParameterError, string(errorResponseParameter.Error))
+		if errorResponseParameter.Description != "" {
+			parameters.Set(oauth2.ParameterErrorDescription, errorResponseParameter.Description)
+		}
+		if errorResponseParameter.Uri != "" {
+			parameters.Set(oauth2.ParameterErrorUri, errorResponseParameter.Uri)
+		}
+	}
+	if state != "" {
+		parameters.Set(oauth2.ParameterState, state)
+	}
+	return parameters
+}
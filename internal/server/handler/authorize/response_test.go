@@ -0,0 +1,219 @@
+package authorize
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/webishdev/stopnik/internal/endpoint"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/manager/cookie"
+	"github.com/webishdev/stopnik/internal/manager/session"
+	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/internal/server/validation"
+	"github.com/webishdev/stopnik/internal/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_GetResponseMode(t *testing.T) {
+	type responseModeParameter struct {
+		responseModeParameter string
+		responseTypes         []oauth2.ResponseType
+		expected              oauth2.ResponseMode
+		valid                 bool
+	}
+
+	var responseModeParameters = []responseModeParameter{
+		{"", []oauth2.ResponseType{oauth2.RtCode}, oauth2.RmQuery, true},
+		{"", []oauth2.ResponseType{oauth2.RtToken}, oauth2.RmFragment, true},
+		{"", []oauth2.ResponseType{oauth2.RtIdToken}, oauth2.RmFragment, true},
+		{"fragment", []oauth2.ResponseType{oauth2.RtCode}, oauth2.RmFragment, true},
+		{"form_post", []oauth2.ResponseType{oauth2.RtToken}, oauth2.RmFormPost, true},
+		{"jwt", []oauth2.ResponseType{oauth2.RtCode}, oauth2.RmQueryJwt, true},
+		{"jwt", []oauth2.ResponseType{oauth2.RtToken}, oauth2.RmFragmentJwt, true},
+		{"form_post.jwt", []oauth2.ResponseType{oauth2.RtCode}, oauth2.RmFormPostJwt, true},
+		{"query", []oauth2.ResponseType{oauth2.RtToken}, oauth2.RmFragment, false},
+		{"query.jwt", []oauth2.ResponseType{oauth2.RtIdToken}, oauth2.RmFragment, false},
+		{"foo", []oauth2.ResponseType{oauth2.RtCode}, oauth2.RmQuery, false},
+	}
+
+	for _, test := range responseModeParameters {
+		testMessage := fmt.Sprintf("Response mode %s with response types %v", test.responseModeParameter, test.responseTypes)
+		t.Run(testMessage, func(t *testing.T) {
+			responseMode, valid := getResponseMode(test.responseModeParameter, test.responseTypes)
+
+			if responseMode != test.expected || valid != test.valid {
+				t.Errorf("expected response mode %s %v, got %s %v", test.expected, test.valid, responseMode, valid)
+			}
+		})
+	}
+}
+
+func Test_AuthorizeResponseMode(t *testing.T) {
+	testConfig := createTestConfig(t)
+
+	type responseModeParameter struct {
+		responseType oauth2.ResponseType
+		responseMode oauth2.ResponseMode
+	}
+
+	var responseModeParameters = []responseModeParameter{
+		{oauth2.RtCode, oauth2.RmQuery},
+		{oauth2.RtCode, oauth2.RmFragment},
+		{oauth2.RtCode, oauth2.RmFormPost},
+		{oauth2.RtToken, oauth2.RmFragment},
+		{oauth2.RtToken, oauth2.RmFormPost},
+		{oauth2.RtCode, oauth2.RmQueryJwt},
+		{oauth2.RtCode, oauth2.RmFragmentJwt},
+		{oauth2.RtToken, oauth2.RmFormPostJwt},
+	}
+
+	for _, test := range responseModeParameters {
+		testMessage := fmt.Sprintf("Cookie exists, response type %s with response mode %s", test.responseType, test.responseMode)
+		t.Run(testMessage, func(t *testing.T) {
+			parsedUri := createUri(t, endpoint.Authorization, func(query url.Values) {
+				query.Set(oauth2.ParameterClientId, "foo")
+				query.Set(oauth2.ParameterRedirectUri, "https://example.com/callback")
+				query.Set(oauth2.ParameterResponseType, string(test.responseType))
+				query.Set(oauth2.ParameterResponseMode, string(test.responseMode))
+				query.Set(oauth2.ParameterState, "abc")
+			})
+			requestValidator := validation.NewRequestValidator()
+			authSessionManager := session.GetAuthSessionManagerInstance()
+			cookieManager := cookie.GetCookieManagerInstance()
+			loginSessionManager := session.GetLoginSessionManagerInstance()
+			tokenManager := token.GetTokenManagerInstance()
+
+			user, _ := testConfig.GetUser("foo")
+			loginSession := &session.LoginSession{
+				Id:       uuid.NewString(),
+				Username: user.Username,
+			}
+			loginSessionManager.StartSession(context.Background(), loginSession)
+			authCookie, _ := cookieManager.CreateAuthCookie(user.Username, loginSession.Id)
+
+			authorizeHandler := NewAuthorizeHandler(requestValidator, cookieManager, authSessionManager, loginSessionManager, tokenManager, template.GetTemplateManagerInstance())
+
+			rr := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, parsedUri.String(), nil)
+			request.AddCookie(&authCookie)
+
+			authorizeHandler.ServeHTTP(rr, request)
+
+			parameters := testResponseParameters(t, rr, test.responseMode)
+			issuer := testConfig.GetIssuer(internalHttp.NewRequestData(request))
+
+			if strings.HasSuffix(string(test.responseMode), jwtSuffix) {
+				responseToken, parseError := jwt.ParseInsecure([]byte(parameters.Get(oauth2.ParameterResponse)))
+				if parseError != nil {
+					t.Fatalf("response JWT was not parsed: %v", parseError)
+				}
+				if !slices.Contains(responseToken.Audience(), "foo") {
+					t.Errorf("response JWT audience %v did not contain client", responseToken.Audience())
+				}
+				if responseToken.Issuer() != issuer {
+					t.Errorf("response JWT issuer %v did not match %v", responseToken.Issuer(), issuer)
+				}
+				parameters = url.Values{oauth2.ParameterIssuer: {responseToken.Issuer()}}
+				for name, value := range responseToken.PrivateClaims() {
+					parameters.Set(name, fmt.Sprintf("%v", value))
+				}
+			}
+
+			if test.responseType == oauth2.RtCode && parameters.Get(oauth2.ParameterCode) == "" {
+				t.Errorf("code parameter was not set in %v", parameters)
+			}
+
+			if test.responseType == oauth2.RtToken && parameters.Get(oauth2.ParameterAccessToken) == "" {
+				t.Errorf("access token parameter was not set in %v", parameters)
+			}
+
+			if parameters.Get(oauth2.ParameterState) != "abc" {
+				t.Errorf("state parameter was not set in %v", parameters)
+			}
+
+			if parameters.Get(oauth2.ParameterIssuer) != issuer {
+				t.Errorf("iss parameter %v did not match %v", parameters.Get(oauth2.ParameterIssuer), issuer)
+			}
+		})
+	}
+}
+
+func Test_AuthorizeInvalidResponseMode(t *testing.T) {
+	createTestConfig(t)
+	parsedUri := createUri(t, endpoint.Authorization, func(query url.Values) {
+		query.Set(oauth2.ParameterClientId, "foo")
+		query.Set(oauth2.ParameterRedirectUri, "https://example.com/callback")
+		query.Set(oauth2.ParameterResponseType, oauth2.ParameterToken)
+		query.Set(oauth2.ParameterResponseMode, string(oauth2.RmQuery))
+	})
+	requestValidator := validation.NewRequestValidator()
+	loginSessionManager := session.GetLoginSessionManagerInstance()
+
+	authorizeHandler := NewAuthorizeHandler(requestValidator, &cookie.Manager{}, &session.AuthManager{}, loginSessionManager, &token.Manager{}, &template.Manager{})
+
+	rr := httptest.NewRecorder()
+
+	authorizeHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, parsedUri.String(), nil))
+
+	parameters := testResponseParameters(t, rr, oauth2.RmFragment)
+
+	if parameters.Get(oauth2.ParameterError) != string(oauth2.AuthorizationEtInvalidRequest) {
+		t.Errorf("error type was not Invalid: %v", parameters)
+	}
+}
+
+func testResponseParameters(t *testing.T, rr *httptest.ResponseRecorder, responseMode oauth2.ResponseMode) url.Values {
+	responseMode = oauth2.ResponseMode(strings.TrimSuffix(string(responseMode), jwtSuffix))
+
+	if responseMode == oauth2.RmFormPost {
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		if rr.Header().Get(internalHttp.CacheControl) != "no-store" {
+			t.Errorf("cache control was not no-store")
+		}
+		body, readError := io.ReadAll(rr.Body)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		parameters := url.Values{}
+		for _, line := range strings.Split(string(body), "\n") {
+			var name, value string
+			_, scanError := fmt.Sscanf(strings.TrimSpace(line), "<input type=\"hidden\" name=%q value=%q />", &name, &value)
+			if scanError == nil {
+				parameters.Set(name, value)
+			}
+		}
+		return parameters
+	}
+
+	if rr.Code != http.StatusFound {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusFound)
+	}
+
+	location, locationError := rr.Result().Location()
+	if locationError != nil {
+		t.Fatalf("location was not provied: %v", locationError)
+	}
+
+	if responseMode == oauth2.RmFragment {
+		if location.RawQuery != "" {
+			t.Errorf("query %s was set for fragment response mode", location.RawQuery)
+		}
+		parameters, parseError := url.ParseQuery(location.Fragment)
+		if parseError != nil {
+			t.Fatal(parseError)
+		}
+		return parameters
+	}
+
+	return location.Query()
+}
@@ -24,7 +24,7 @@ func (h *Handler) ForbiddenHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) InternalServerErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
-	log.Error("Internal server error %s", err.Error())
+	log.ErrorContext(r.Context(), "Internal server error", log.Err(err))
 	h.sendStatus(http.StatusInternalServerError, "500 Internal Server Error", w, r)
 }
 
@@ -45,6 +45,6 @@ func (h *Handler) sendStatus(status int, message string, w http.ResponseWriter,
 	w.WriteHeader(status)
 	_, err := w.Write([]byte(message))
 	if err != nil {
-		log.Error("Could not send status message: %v", err)
+		log.ErrorContext(r.Context(), "Could not send status message", log.Err(err))
 	}
 }
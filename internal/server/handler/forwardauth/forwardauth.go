@@ -1,6 +1,8 @@
 package forwardauth
 
 import (
+	"cmp"
+	"context"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/webishdev/stopnik/internal/config"
@@ -72,6 +74,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	codeParameter := forwardUri.Query().Get(oauth2.ParameterCode)
 	stateParameter := forwardUri.Query().Get(oauth2.ParameterState)
 	forwardIdParameter := forwardUri.Query().Get(forwardAuthParameterName)
+	issParameter := forwardUri.Query().Get(oauth2.ParameterIssuer)
 
 	_, _, validCookie := h.cookieManager.ValidateForwardAuthCookie(r)
 
@@ -79,7 +82,11 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		return
 	} else if validCookie && codeParameter != "" && forwardIdParameter != "" && stateParameter != "" {
-		_, forwardSession, valid := h.validatePKCEAndState(codeParameter, stateParameter, forwardIdParameter)
+		if !h.validIssuer(issParameter) {
+			h.errorHandler.BadRequestHandler(w, r)
+			return
+		}
+		_, forwardSession, valid := h.validatePKCEAndState(r.Context(), codeParameter, stateParameter, forwardIdParameter)
 		if !valid {
 			h.errorHandler.BadRequestHandler(w, r)
 			return
@@ -91,7 +98,11 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if codeParameter != "" && forwardIdParameter != "" && stateParameter != "" {
-		authCookie, forwardSession, valid := h.validateAndCreateAuthCookie(codeParameter, stateParameter, forwardIdParameter)
+		if !h.validIssuer(issParameter) {
+			h.errorHandler.BadRequestHandler(w, r)
+			return
+		}
+		authCookie, forwardSession, valid := h.validateAndCreateAuthCookie(r.Context(), codeParameter, stateParameter, forwardIdParameter)
 		if !valid {
 			h.errorHandler.BadRequestHandler(w, r)
 			return
@@ -116,7 +127,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Info("Will redirect to %s", redirectUri.String())
+	log.InfoContext(r.Context(), "Will redirect", "redirect_uri", redirectUri.String())
 
 	parsedUri, parsedUriError := createUri(h.config.Server.ForwardAuth.ExternalUrl, endpoint.Authorization, func(query url.Values) {
 		query.Set(oauth2.ParameterResponseType, string(oauth2.RtCode))
@@ -138,17 +149,17 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		State:                 forwardSessionState,
 	}
 
-	h.forwardSessionManager.StartSession(forwardSession)
+	h.forwardSessionManager.StartSession(r.Context(), forwardSession)
 
 	w.Header().Set(internalHttp.Location, parsedUri.String())
 	w.WriteHeader(http.StatusTemporaryRedirect)
 }
 
-func (h *Handler) validatePKCEAndState(code string, state string, forwardSessionId string) (*session.AuthSession, *session.ForwardSession, bool) {
-	authSession, authSessionExists := h.authSessionManager.GetSession(code)
+func (h *Handler) validatePKCEAndState(ctx context.Context, code string, state string, forwardSessionId string) (*session.AuthSession, *session.ForwardSession, bool) {
+	authSession, authSessionExists := h.authSessionManager.GetSession(ctx, code)
 	if authSessionExists {
 		codeChallengeMethod, codeChallengeMethodExists := pkce.CodeChallengeMethodFromString(authSession.CodeChallengeMethod)
-		forwardSession, forwardSessionExists := h.forwardSessionManager.GetSession(forwardSessionId)
+		forwardSession, forwardSessionExists := h.forwardSessionManager.GetSession(ctx, forwardSessionId)
 		if codeChallengeMethodExists && forwardSessionExists && forwardSession.State == state {
 			validatePKCE := pkce.ValidatePKCE(codeChallengeMethod, forwardSession.CodeChallengeVerifier, authSession.CodeChallenge)
 			if validatePKCE {
@@ -159,14 +170,16 @@ func (h *Handler) validatePKCEAndState(code string, state string, forwardSession
 	return nil, nil, false
 }
 
-func (h *Handler) validateAndCreateAuthCookie(code string, state string, forwardSessionId string) (*http.Cookie, *session.ForwardSession, bool) {
-	authSession, forwardSession, valid := h.validatePKCEAndState(code, state, forwardSessionId)
+func (h *Handler) validateAndCreateAuthCookie(ctx context.Context, code string, state string, forwardSessionId string) (*http.Cookie, *session.ForwardSession, bool) {
+	authSession, forwardSession, valid := h.validatePKCEAndState(ctx, code, state, forwardSessionId)
 	if valid {
 		loginSession := &session.LoginSession{
 			Id:       uuid.NewString(),
 			Username: authSession.Username,
+			Acr:      authSession.Acr,
+			Amr:      authSession.Amr,
 		}
-		h.loginSessionManager.StartSession(loginSession)
+		h.loginSessionManager.StartSession(ctx, loginSession)
 		forwardAuthCookie, forwardAuthCookieError := h.cookieManager.CreateForwardAuthCookie(authSession.Username, loginSession.Id)
 		if forwardAuthCookieError != nil {
 			return nil, nil, false
@@ -177,6 +190,18 @@ func (h *Handler) validateAndCreateAuthCookie(code string, state string, forward
 	return nil, nil, false
 }
 
+// validIssuer checks the iss parameter of the authorization response, see RFC 9207.
+// The expected issuer is the configured one, or the origin of the external URL
+// which is used for the authorization request.
+func (h *Handler) validIssuer(iss string) bool {
+	externalUri, externalUriError := url.Parse(h.config.Server.ForwardAuth.ExternalUrl)
+	if externalUriError != nil {
+		return false
+	}
+	expectedIssuer := cmp.Or(h.config.Server.Issuer, fmt.Sprintf("%s://%s", externalUri.Scheme, externalUri.Host))
+	return iss == expectedIssuer
+}
+
 func createUri(uri string, path string, handler func(query url.Values)) (*url.URL, error) {
 	parsedUri, parseError := url.Parse(uri)
 	if parseError != nil {
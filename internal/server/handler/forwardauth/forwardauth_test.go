@@ -1,14 +1,19 @@
 package forwardauth
 
 import (
+	"context"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/webishdev/stopnik/internal/config"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
 	"github.com/webishdev/stopnik/internal/manager/cookie"
 	"github.com/webishdev/stopnik/internal/manager/session"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/internal/pkce"
 	"github.com/webishdev/stopnik/internal/template"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 )
 
@@ -53,7 +58,7 @@ func Test_ForwardAuthWithCookie(t *testing.T) {
 		Id:       uuid.NewString(),
 		Username: user.Username,
 	}
-	loginSessionManager.StartSession(loginSession)
+	loginSessionManager.StartSession(context.Background(), loginSession)
 	authCookie, _ := cookieManager.CreateForwardAuthCookie(user.Username, loginSession.Id)
 
 	forwardAuthHandler := NewForwardAuthHandler(cookieManager, authSessionManager, forwardSessionManager, loginSessionManager, templateManager)
@@ -120,6 +125,68 @@ func Test_ForwardAuthInvalidHeaders(t *testing.T) {
 	}
 }
 
+func Test_ForwardAuthIssuer(t *testing.T) {
+	testConfig := createTestConfig(t)
+	cookieManager := cookie.GetCookieManagerInstance()
+	authSessionManager := session.GetAuthSessionManagerInstance()
+	forwardSessionManager := session.GetForwardSessionManagerInstance()
+	loginSessionManager := session.GetLoginSessionManagerInstance()
+	templateManager := template.GetTemplateManagerInstance()
+
+	type issuerTest struct {
+		iss            string
+		expectedStatus int
+	}
+
+	var issuerTests = []issuerTest{
+		{"http://foo.com", http.StatusTemporaryRedirect},
+		{"http://bar.com", http.StatusBadRequest},
+		{"", http.StatusBadRequest},
+	}
+
+	for _, test := range issuerTests {
+		testMessage := fmt.Sprintf("Forward auth with issuer %s", test.iss)
+		t.Run(testMessage, func(t *testing.T) {
+			codeChallenge := uuid.NewString()
+			authSession := &session.AuthSession{
+				Id:                  uuid.NewString(),
+				CodeChallenge:       codeChallenge,
+				CodeChallengeMethod: string(pkce.S256),
+				Username:            "foo",
+			}
+			authSessionManager.StartSession(context.Background(), authSession)
+			forwardSession := &session.ForwardSession{
+				Id:                    uuid.NewString(),
+				CodeChallengeVerifier: pkce.CalculatePKCE(pkce.S256, codeChallenge),
+				RedirectUri:           "http://localhost:8080/blabla",
+				State:                 uuid.NewString(),
+			}
+			forwardSessionManager.StartSession(context.Background(), forwardSession)
+
+			forwardAuthHandler := NewForwardAuthHandler(cookieManager, authSessionManager, forwardSessionManager, loginSessionManager, templateManager)
+
+			query := url.Values{
+				oauth2.ParameterCode:                     {authSession.Id},
+				oauth2.ParameterState:                    {forwardSession.State},
+				oauth2.ParameterIssuer:                   {test.iss},
+				testConfig.GetForwardAuthParameterName(): {forwardSession.Id},
+			}
+
+			rr := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, testConfig.GetForwardAuthEndpoint(), nil)
+			request.Header.Set(internalHttp.XForwardProtocol, "http")
+			request.Header.Set(internalHttp.XForwardHost, "localhost:8080")
+			request.Header.Set(internalHttp.XForwardUri, "/blabla?"+query.Encode())
+
+			forwardAuthHandler.ServeHTTP(rr, request)
+
+			if rr.Code != test.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
 func createTestConfig(t *testing.T) *config.Config {
 	testConfig := &config.Config{
 		Server: config.Server{
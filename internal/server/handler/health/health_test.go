@@ -40,7 +40,7 @@ func Test_HealthWithToken(t *testing.T) {
 	}
 
 	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
-	tokenResponse := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{"a:foo", "b:bar"}, nil, "", "")
+	tokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{"a:foo", "b:bar"}, nil, "", "", nil)
 
 	healthHandler := NewHealthHandler(tokenManager)
 
@@ -1,9 +1,13 @@
 package introspect
 
 import (
+	"cmp"
+	"context"
+	"github.com/webishdev/stopnik/internal/audit"
 	"github.com/webishdev/stopnik/internal/config"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
 	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/metrics"
 	"github.com/webishdev/stopnik/internal/oauth2"
 	"github.com/webishdev/stopnik/internal/server/handler/error"
 	"github.com/webishdev/stopnik/internal/server/validation"
@@ -13,15 +17,6 @@ import (
 	"strings"
 )
 
-// response as described in https://datatracker.ietf.org/doc/html/rfc7662#section-2.2
-type response struct {
-	Active    bool             `json:"active"`
-	Scope     string           `json:"scope,omitempty"`
-	ClientId  string           `json:"client_id,omitempty"`
-	Username  string           `json:"username,omitempty"`
-	TokenType oauth2.TokenType `json:"token_type,omitempty"`
-}
-
 type Handler struct {
 	config       *config.Config
 	validator    *validation.RequestValidator
@@ -54,6 +49,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				oauth2.TokenErrorStatusResponseHandler(w, r, http.StatusUnauthorized, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidRequest})
 				return
 			}
+			client = validAccessToken.Client
 
 			scopes := validAccessToken.Scopes
 
@@ -78,14 +74,31 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		introspectResponse := response{}
 
 		if !tokenTypeHintExists {
-			accessTokenExists := h.checkAccessToken(tokenParameter, &introspectResponse)
+			accessTokenExists := h.checkAccessToken(r.Context(), tokenParameter, &introspectResponse)
 			if !accessTokenExists {
-				h.checkRefreshToken(tokenParameter, &introspectResponse)
+				h.checkRefreshToken(r.Context(), tokenParameter, &introspectResponse)
 			}
 		} else if tokenTypeHint == oauth2.ItAccessToken {
-			h.checkAccessToken(tokenParameter, &introspectResponse)
+			h.checkAccessToken(r.Context(), tokenParameter, &introspectResponse)
 		} else if tokenTypeHint == oauth2.ItRefreshToken {
-			h.checkRefreshToken(tokenParameter, &introspectResponse)
+			h.checkRefreshToken(r.Context(), tokenParameter, &introspectResponse)
+		}
+
+		outcome := audit.OcSuccess
+		if !introspectResponse.Active {
+			metrics.Introspections.Inc(cmp.Or(string(tokenTypeHint), "unknown"), metrics.ResultMiss)
+			outcome = audit.OcFailure
+		}
+		audit.Record(r, &audit.Event{
+			Type:     audit.EtTokenIntrospected,
+			Outcome:  outcome,
+			ClientId: client.Id,
+			Details:  map[string]string{"token_type": cmp.Or(string(tokenTypeHint), "unknown"), "token_client_id": introspectResponse.ClientId},
+		})
+
+		if client.IntrospectJWT && acceptsJwt(r) {
+			h.sendJwt(w, r, client, introspectResponse)
+			return
 		}
 
 		jsonError := internalHttp.SendJson(introspectResponse, w, r)
@@ -99,31 +112,94 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *Handler) checkRefreshToken(token string, introspectResponse *response) bool {
-	refreshToken, tokenExists := h.tokenManager.GetRefreshToken(token)
+// Implements https://datatracker.ietf.org/doc/html/rfc9701
+func (h *Handler) sendJwt(w http.ResponseWriter, r *http.Request, client *config.Client, introspectResponse response) {
+	requestData := internalHttp.NewRequestData(r)
+	responseToken, headers, tokenError := newResponseToken(h.config.GetIssuer(requestData), client.Id, introspectResponse)
+	if tokenError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, tokenError)
+		return
+	}
+
+	signedToken, signError := h.tokenManager.SignTokenWithPrivateKey(client, responseToken, headers)
+	if signError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, signError)
+		return
+	}
+
+	jwtError := internalHttp.SendJwt(signedToken, internalHttp.ContentTypeTokenIntrospectionJWT, w, r)
+	if jwtError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, jwtError)
+		return
+	}
+}
+
+func (h *Handler) checkRefreshToken(ctx context.Context, token string, introspectResponse *response) bool {
+	refreshToken, tokenExists := h.tokenManager.GetRefreshToken(ctx, token)
 
 	introspectResponse.Active = tokenExists
 
 	if tokenExists {
+		metrics.Introspections.Inc(string(oauth2.ItRefreshToken), metrics.ResultHit)
 		introspectResponse.Username = refreshToken.Username
 		introspectResponse.ClientId = refreshToken.ClientId
 		introspectResponse.Scope = strings.Join(refreshToken.Scopes, " ")
+		introspectResponse.ExpiresAt = refreshToken.ExpiresAt.Unix()
+		introspectResponse.IssuedAt = refreshToken.IssuedAt.Unix()
+		introspectResponse.NotBefore = refreshToken.NotBefore.Unix()
+		introspectResponse.Subject = h.getSubject(refreshToken.Username, refreshToken.ClientId)
+		introspectResponse.Audience = refreshToken.Audience
+		introspectResponse.Issuer = refreshToken.Issuer
+		introspectResponse.JwtId = refreshToken.Id
+		introspectResponse.claims = h.getClaims(refreshToken.Username, refreshToken.ClientId, refreshToken.Scopes)
 	}
 
 	return tokenExists
 }
 
-func (h *Handler) checkAccessToken(token string, introspectResponse *response) bool {
-	accessToken, tokenExists := h.tokenManager.GetAccessToken(token)
+func (h *Handler) checkAccessToken(ctx context.Context, token string, introspectResponse *response) bool {
+	accessToken, tokenExists := h.tokenManager.GetAccessToken(ctx, token)
 
 	introspectResponse.Active = tokenExists
 
 	if tokenExists {
+		metrics.Introspections.Inc(string(oauth2.ItAccessToken), metrics.ResultHit)
 		introspectResponse.Username = accessToken.Username
 		introspectResponse.ClientId = accessToken.ClientId
 		introspectResponse.Scope = strings.Join(accessToken.Scopes, " ")
 		introspectResponse.TokenType = accessToken.TokenType
+		introspectResponse.ExpiresAt = accessToken.ExpiresAt.Unix()
+		introspectResponse.IssuedAt = accessToken.IssuedAt.Unix()
+		introspectResponse.NotBefore = accessToken.NotBefore.Unix()
+		introspectResponse.Subject = h.getSubject(accessToken.Username, accessToken.ClientId)
+		introspectResponse.Audience = accessToken.Audience
+		introspectResponse.Issuer = accessToken.Issuer
+		introspectResponse.JwtId = accessToken.Id
+		introspectResponse.Actor = accessToken.Actor
+		introspectResponse.AuthorizationDetails = accessToken.AuthorizationDetails
+		introspectResponse.Confirmation = accessToken.Confirmation
+		introspectResponse.claims = h.getClaims(accessToken.Username, accessToken.ClientId, accessToken.Scopes)
 	}
 
 	return tokenExists
 }
+
+// getSubject returns the subject identifier of the user for the client the token was issued to,
+// pairwise clients receive their pairwise subject instead of the username.
+func (h *Handler) getSubject(username string, clientId string) string {
+	client, _ := h.config.GetClient(clientId)
+	return h.config.GetSubject(client, username)
+}
+
+func (h *Handler) getClaims(username string, clientId string, scopes []string) map[string]any {
+	claims := make(map[string]any)
+	for _, claim := range h.config.GetClaims(username, clientId, scopes) {
+		currentClaim := *claim
+		claims[currentClaim.GetName()] = currentClaim.GetValues()
+	}
+	return claims
+}
+
+func acceptsJwt(r *http.Request) bool {
+	return strings.Contains(r.Header.Get(internalHttp.Accept), internalHttp.ContentTypeTokenIntrospectionJWT)
+}
@@ -1,11 +1,16 @@
 package introspect
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
 	"github.com/webishdev/stopnik/internal/endpoint"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
 	"github.com/webishdev/stopnik/internal/manager/session"
@@ -37,6 +42,15 @@ func Test_Introspect(t *testing.T) {
 				RefreshTTL:   100,
 				Introspect:   false,
 			},
+			{
+				Id:            "moo",
+				ClientSecret:  "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				Redirects:     []string{"https://example.com/callback"},
+				RefreshTTL:    100,
+				Introspect:    true,
+				IntrospectJWT: true,
+				PrivateKey:    "../../../../.test_files/rsa256key.pem",
+			},
 		},
 		Users: []config.User{
 			{
@@ -56,6 +70,8 @@ func Test_Introspect(t *testing.T) {
 	testIntrospectWithoutHint(t, testConfig)
 
 	testIntrospectDisabled(t, testConfig)
+
+	testIntrospectJWT(t, testConfig)
 }
 
 func Test_IntrospectMissingClientCredentials(t *testing.T) {
@@ -247,9 +263,9 @@ func testIntrospect(t *testing.T, testConfig *config.Config) {
 			requestValidator := validation.NewRequestValidator()
 			sessionManager := session.GetAuthSessionManagerInstance()
 			tokenManager := token.GetTokenManagerInstance()
-			sessionManager.StartSession(authSession)
+			sessionManager.StartSession(context.Background(), authSession)
 			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
-			accessTokenResponse := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "")
+			accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "", nil)
 
 			introspectHandler := NewIntrospectHandler(requestValidator, tokenManager)
 
@@ -283,6 +299,18 @@ func testIntrospect(t *testing.T, testConfig *config.Config) {
 			if !introspectResponse.Active {
 				t.Errorf("Token should be active")
 			}
+
+			if introspectResponse.Subject != user.Username || introspectResponse.ClientId != client.Id || introspectResponse.Scope != "foo:bar moo:abc" {
+				t.Errorf("Token subject, client or scope did not match, %v", introspectResponse)
+			}
+
+			if introspectResponse.JwtId == "" || introspectResponse.Issuer == "" || len(introspectResponse.Audience) == 0 {
+				t.Errorf("Token id, issuer or audience missing, %v", introspectResponse)
+			}
+
+			if introspectResponse.IssuedAt == 0 || introspectResponse.NotBefore != introspectResponse.IssuedAt || introspectResponse.ExpiresAt <= introspectResponse.IssuedAt {
+				t.Errorf("Token timing did not match, %v", introspectResponse)
+			}
 		})
 	}
 }
@@ -320,9 +348,9 @@ func testIntrospectWithoutHint(t *testing.T, testConfig *config.Config) {
 			requestValidator := validation.NewRequestValidator()
 			sessionManager := session.GetAuthSessionManagerInstance()
 			tokenManager := token.GetTokenManagerInstance()
-			sessionManager.StartSession(authSession)
+			sessionManager.StartSession(context.Background(), authSession)
 			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
-			accessTokenResponse := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "")
+			accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "", nil)
 
 			introspectHandler := NewIntrospectHandler(requestValidator, tokenManager)
 
@@ -359,6 +387,96 @@ func testIntrospectWithoutHint(t *testing.T, testConfig *config.Config) {
 	}
 }
 
+func testIntrospectJWT(t *testing.T, testConfig *config.Config) {
+	type introspectParameter struct {
+		clientId    string
+		accept      string
+		contentType string
+	}
+
+	var introspectParameters = []introspectParameter{
+		{"moo", internalHttp.ContentTypeTokenIntrospectionJWT, internalHttp.ContentTypeTokenIntrospectionJWT},
+		{"moo", internalHttp.ContentTypeJSON, internalHttp.ContentTypeJSON},
+		{"foo", internalHttp.ContentTypeTokenIntrospectionJWT, internalHttp.ContentTypeJSON},
+	}
+
+	for _, test := range introspectParameters {
+		testMessage := fmt.Sprintf("Introspect for client %s accepting %s", test.clientId, test.accept)
+		t.Run(testMessage, func(t *testing.T) {
+			client, _ := testConfig.GetClient(test.clientId)
+			user, _ := testConfig.GetUser("foo")
+			scopes := []string{"foo:bar"}
+
+			requestValidator := validation.NewRequestValidator()
+			tokenManager := token.GetTokenManagerInstance()
+			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+			accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "", nil)
+
+			introspectHandler := NewIntrospectHandler(requestValidator, tokenManager)
+
+			rr := httptest.NewRecorder()
+
+			bodyString := testCreateBody(
+				oauth2.ParameterToken, accessTokenResponse.AccessTokenValue,
+			)
+			body := strings.NewReader(bodyString)
+
+			request = httptest.NewRequest(http.MethodPost, endpoint.Introspect, body)
+			request.Header.Add(internalHttp.Authorization, fmt.Sprintf("Basic %s", testTokenCreateBasicAuth(test.clientId, "bar")))
+			request.Header.Add(internalHttp.ContentType, "application/x-www-form-urlencoded")
+			request.Header.Add(internalHttp.Accept, test.accept)
+
+			introspectHandler.ServeHTTP(rr, request)
+
+			if rr.Code != http.StatusOK {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+			}
+
+			contentType := rr.Header().Get(internalHttp.ContentType)
+			if contentType != test.contentType {
+				t.Fatalf("content type did not match, got %s want %s", contentType, test.contentType)
+			}
+
+			if test.contentType == internalHttp.ContentTypeJSON {
+				return
+			}
+
+			message, parseError := jws.Parse(rr.Body.Bytes())
+			if parseError != nil {
+				t.Fatal(parseError)
+			}
+
+			if message.Signatures()[0].ProtectedHeaders().Type() != "token-introspection+jwt" {
+				t.Errorf("typ header did not match, %s", message.Signatures()[0].ProtectedHeaders().Type())
+			}
+
+			publicKey, loadError := crypto.LoadPublicKey("../../../../.test_files/rsa256pub.pem")
+			if loadError != nil {
+				t.Fatal(loadError)
+			}
+
+			responseToken, verifyError := jwt.Parse(rr.Body.Bytes(), jwt.WithKey(jwa.RS256, publicKey))
+			if verifyError != nil {
+				t.Fatal(verifyError)
+			}
+
+			if len(responseToken.Audience()) != 1 || responseToken.Audience()[0] != test.clientId || responseToken.Issuer() == "" {
+				t.Errorf("audience or issuer did not match, %v %s", responseToken.Audience(), responseToken.Issuer())
+			}
+
+			tokenIntrospection, exists := responseToken.Get("token_introspection")
+			if !exists {
+				t.Fatal("token_introspection claim missing")
+			}
+
+			introspection, valid := tokenIntrospection.(map[string]any)
+			if !valid || introspection["active"] != true || introspection["client_id"] != test.clientId {
+				t.Errorf("token_introspection did not match, %v", tokenIntrospection)
+			}
+		})
+	}
+}
+
 func testIntrospectDisabled(t *testing.T, testConfig *config.Config) {
 	type introspectParameter struct {
 		tokenHint oauth2.IntrospectTokenType
@@ -392,9 +510,9 @@ func testIntrospectDisabled(t *testing.T, testConfig *config.Config) {
 			requestValidator := validation.NewRequestValidator()
 			sessionManager := session.GetAuthSessionManagerInstance()
 			tokenManager := token.GetTokenManagerInstance()
-			sessionManager.StartSession(authSession)
+			sessionManager.StartSession(context.Background(), authSession)
 			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
-			accessTokenResponse := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "")
+			accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "", nil)
 
 			introspectHandler := NewIntrospectHandler(requestValidator, tokenManager)
 
@@ -0,0 +1,83 @@
+package introspect
+
+import (
+	"encoding/json"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"slices"
+	"time"
+)
+
+// tokenIntrospectionType is the typ header of a JWT response as described in https://datatracker.ietf.org/doc/html/rfc9701#section-5
+const tokenIntrospectionType = "token-introspection+jwt"
+
+// claimTokenIntrospection contains the introspection response inside the JWT response.
+const claimTokenIntrospection = "token_introspection"
+
+// response as described in https://datatracker.ietf.org/doc/html/rfc7662#section-2.2
+type response struct {
+	Active    bool             `json:"active"`
+	Scope     string           `json:"scope,omitempty"`
+	ClientId  string           `json:"client_id,omitempty"`
+	Username  string           `json:"username,omitempty"`
+	TokenType oauth2.TokenType `json:"token_type,omitempty"`
+	ExpiresAt int64            `json:"exp,omitempty"`
+	IssuedAt  int64            `json:"iat,omitempty"`
+	NotBefore int64            `json:"nbf,omitempty"`
+	Subject   string           `json:"sub,omitempty"`
+	Audience  []string         `json:"aud,omitempty"`
+	Issuer    string           `json:"iss,omitempty"`
+	JwtId     string           `json:"jti,omitempty"`
+	Actor     *oauth2.Actor    `json:"act,omitempty"`
+	// AuthorizationDetails as described in https://datatracker.ietf.org/doc/html/rfc9396#section-9.2
+	AuthorizationDetails []oauth2.AuthorizationDetail `json:"authorization_details,omitempty"`
+	// Confirmation as described in https://datatracker.ietf.org/doc/html/rfc9449#section-6.2
+	Confirmation *oauth2.Confirmation `json:"cnf,omitempty"`
+	claims       map[string]any
+}
+
+type plainResponse response
+
+// MarshalJSON adds the classification claims to the response,
+// claims must not override the members defined in https://datatracker.ietf.org/doc/html/rfc7662#section-2.2
+func (introspectResponse response) MarshalJSON() ([]byte, error) {
+	data, marshalError := json.Marshal(plainResponse(introspectResponse))
+	if marshalError != nil || len(introspectResponse.claims) == 0 {
+		return data, marshalError
+	}
+	values := make(map[string]any)
+	unmarshalError := json.Unmarshal(data, &values)
+	if unmarshalError != nil {
+		return nil, unmarshalError
+	}
+	for name, value := range introspectResponse.claims {
+		if _, exists := values[name]; !exists && !slices.Contains(reservedMembers, name) {
+			values[name] = value
+		}
+	}
+	return json.Marshal(values)
+}
+
+var reservedMembers = []string{"active", "scope", "client_id", "username", "token_type", "exp", "iat", "nbf", "sub", "aud", "iss", "jti", "act"}
+
+// newResponseToken creates a JWT response as described in https://datatracker.ietf.org/doc/html/rfc9701#section-5
+func newResponseToken(issuer string, clientId string, introspectResponse response) (jwt.Token, jws.Headers, error) {
+	token, builderError := jwt.NewBuilder().
+		Issuer(issuer).
+		Audience([]string{clientId}).
+		IssuedAt(time.Now()).
+		Claim(claimTokenIntrospection, introspectResponse).
+		Build()
+	if builderError != nil {
+		return nil, nil, builderError
+	}
+
+	headers := jws.NewHeaders()
+	headerError := headers.Set(jws.TypeKey, tokenIntrospectionType)
+	if headerError != nil {
+		return nil, nil, headerError
+	}
+
+	return token, headers, nil
+}
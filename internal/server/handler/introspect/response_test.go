@@ -0,0 +1,50 @@
+package introspect
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_ResponseWithClaims(t *testing.T) {
+	introspectResponse := response{
+		Active:   true,
+		Subject:  "foo",
+		Audience: []string{"all"},
+		claims: map[string]any{
+			"department": []string{"development"},
+			"sub":        "bar",
+			"active":     false,
+		},
+	}
+
+	data, marshalError := json.Marshal(introspectResponse)
+	if marshalError != nil {
+		t.Fatal(marshalError)
+	}
+
+	values := make(map[string]any)
+	unmarshalError := json.Unmarshal(data, &values)
+	if unmarshalError != nil {
+		t.Fatal(unmarshalError)
+	}
+
+	if values["active"] != true || values["sub"] != "foo" {
+		t.Errorf("claims should not override response members, got %s", string(data))
+	}
+
+	department, ok := values["department"].([]any)
+	if !ok || len(department) != 1 || department[0] != "development" {
+		t.Errorf("expected department claim, got %s", string(data))
+	}
+}
+
+func Test_InactiveResponse(t *testing.T) {
+	data, marshalError := json.Marshal(response{})
+	if marshalError != nil {
+		t.Fatal(marshalError)
+	}
+
+	if string(data) != `{"active":false}` {
+		t.Errorf("expected only active member, got %s", string(data))
+	}
+}
@@ -1,10 +1,12 @@
 package logout
 
 import (
+	"github.com/webishdev/stopnik/internal/audit"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
 	"github.com/webishdev/stopnik/internal/manager/cookie"
 	"github.com/webishdev/stopnik/internal/manager/session"
 	"github.com/webishdev/stopnik/internal/server/handler/error"
+	"github.com/webishdev/stopnik/internal/webhook"
 	"github.com/webishdev/stopnik/log"
 	"net/http"
 )
@@ -27,12 +29,17 @@ func NewLogoutHandler(cookieManager *cookie.Manager, loginSessionManager session
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.AccessLogRequest(r)
 	if r.Method == http.MethodPost {
-		_, loginSession, validCookie := h.cookieManager.ValidateAuthCookie(r)
+		_, _, validCookie := h.cookieManager.ValidateAuthCookie(r)
 		if !validCookie {
 			h.errorHandler.ForbiddenHandler(w, r)
 			return
 		}
-		h.loginSessionManager.CloseSession(loginSession.Id, true)
+		// all login sessions of the browser are closed, not only the current one
+		for _, loginSession := range h.cookieManager.GetAuthCookieSessions(r) {
+			h.loginSessionManager.CloseSession(r.Context(), loginSession.Id, true)
+			audit.Record(r, &audit.Event{Type: audit.EtLogout, Outcome: audit.OcSuccess, Username: loginSession.Username})
+			webhook.Publish(r, webhook.EtSessionClosed, webhook.Data{Username: loginSession.Username})
+		}
 		authCookie := h.cookieManager.DeleteAuthCookie()
 
 		http.SetCookie(w, &authCookie)
@@ -1,6 +1,7 @@
 package logout
 
 import (
+	"context"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/webishdev/stopnik/internal/config"
@@ -94,7 +95,7 @@ func testLogout(t *testing.T, testConfig *config.Config) {
 				Id:       uuid.NewString(),
 				Username: user.Username,
 			}
-			loginSessionManager.StartSession(loginSession)
+			loginSessionManager.StartSession(context.Background(), loginSession)
 			authCookie, _ := cookieManager.CreateAuthCookie(user.Username, loginSession.Id)
 
 			logoutHandler := NewLogoutHandler(cookieManager, loginSessionManager, test.handlerRedirect)
@@ -2,8 +2,11 @@ package metadata
 
 import (
 	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/webishdev/stopnik/internal/config"
 	"github.com/webishdev/stopnik/internal/endpoint"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/manager/dpop"
+	"github.com/webishdev/stopnik/internal/manager/requestobject"
 	"github.com/webishdev/stopnik/internal/oauth2"
 	"github.com/webishdev/stopnik/internal/pkce"
 	errorHandler "github.com/webishdev/stopnik/internal/server/handler/error"
@@ -19,29 +22,42 @@ type response struct {
 	RegistrationEndpoint                               string                     `json:"registration_endpoint,omitempty"`
 	ScopesSupported                                    []string                   `json:"scopes_supported,omitempty"`
 	ResponseTypesSupported                             []oauth2.ResponseType      `json:"response_types_supported,omitempty"`
-	ResponseModesSupported                             []string                   `json:"response_modes_supported,omitempty"`
+	ResponseModesSupported                             []oauth2.ResponseMode      `json:"response_modes_supported,omitempty"`
 	GrantTypesSupported                                []oauth2.GrantType         `json:"grant_types_supported,omitempty"`
-	TokenEndpointAuthMethodsSupported                  []string                   `json:"token_endpoint_auth_methods_supported,omitempty"`
+	TokenEndpointAuthMethodsSupported                  []oauth2.ClientAuthMethod  `json:"token_endpoint_auth_methods_supported,omitempty"`
 	TokenEndpointAuthSigningAlgValuesSupported         []jwa.SignatureAlgorithm   `json:"token_endpoint_auth_signing_alg_values_supported,omitempty"`
 	ServiceDocumentation                               string                     `json:"service_documentation,omitempty"`
 	UILocalesSupported                                 []string                   `json:"ui_locales_supported,omitempty"`
 	OpPolicyUri                                        string                     `json:"op_policy_uri,omitempty"`
 	OpTosUri                                           string                     `json:"op_tos_uri,omitempty"`
 	RevocationEndpoint                                 string                     `json:"revocation_endpoint,omitempty"`
-	RevocationEndpointAuthMethodsSupported             []string                   `json:"revocation_endpoint_auth_methods_supported,omitempty"`
+	RevocationEndpointAuthMethodsSupported             []oauth2.ClientAuthMethod  `json:"revocation_endpoint_auth_methods_supported,omitempty"`
 	RevocationEndpointAuthSigningAlgValuesSupported    []jwa.SignatureAlgorithm   `json:"revocation_endpoint_auth_signing_alg_values_supported,omitempty"`
 	IntrospectionEndpoint                              string                     `json:"introspection_endpoint,omitempty"`
-	IntrospectionEndpointAuthMethodsSupported          []string                   `json:"introspection_endpoint_auth_methods_supported,omitempty"`
+	IntrospectionEndpointAuthMethodsSupported          []oauth2.ClientAuthMethod  `json:"introspection_endpoint_auth_methods_supported,omitempty"`
 	IntrospectionEndpointAuthSigningAlgValuesSupported []jwa.SignatureAlgorithm   `json:"introspection_endpoint_auth_signing_alg_values_supported,omitempty"`
+	IntrospectionSigningAlgValuesSupported             []jwa.SignatureAlgorithm   `json:"introspection_signing_alg_values_supported,omitempty"`
 	CodeChallengeMethodsSupported                      []pkce.CodeChallengeMethod `json:"code_challenge_methods_supported,omitempty"`
+	AuthorizationDetailsTypesSupported                 []string                   `json:"authorization_details_types_supported,omitempty"`
+	DPoPSigningAlgValuesSupported                      []jwa.SignatureAlgorithm   `json:"dpop_signing_alg_values_supported,omitempty"`
+	AuthorizationSigningAlgValuesSupported             []jwa.SignatureAlgorithm   `json:"authorization_signing_alg_values_supported,omitempty"`
+	AuthorizationResponseIssParameterSupported         bool                       `json:"authorization_response_iss_parameter_supported,omitempty"`
+	TlsClientCertificateBoundAccessTokens              bool                       `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+	RequestParameterSupported                          bool                       `json:"request_parameter_supported,omitempty"`
+	RequestUriParameterSupported                       bool                       `json:"request_uri_parameter_supported,omitempty"`
+	RequireSignedRequestObject                         bool                       `json:"require_signed_request_object,omitempty"`
+	RequestObjectSigningAlgValuesSupported             []jwa.SignatureAlgorithm   `json:"request_object_signing_alg_values_supported,omitempty"`
 }
 
 type Handler struct {
+	config       *config.Config
 	errorHandler *errorHandler.Handler
 }
 
 func NewMetadataHandler() *Handler {
+	currentConfig := config.GetConfigInstance()
 	return &Handler{
+		config:       currentConfig,
 		errorHandler: errorHandler.NewErrorHandler(),
 	}
 }
@@ -65,10 +81,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		revokeEndpoint := urlFromRequest.JoinPath(endpoint.Revoke)
 		keysEndpoint := urlFromRequest.JoinPath(endpoint.Keys)
 
-		authMethodsSupported := []string{
-			"client_secret_basic",
-			"client_secret_post",
-		}
+		authMethodsSupported := h.config.GetClientAuthMethods()
 
 		signatureAlgorithmSupported := []jwa.SignatureAlgorithm{
 			jwa.RS256,
@@ -96,22 +109,43 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				oauth2.GtPassword,
 				oauth2.GtRefreshToken,
 				oauth2.GtImplicit,
+				oauth2.GtTokenExchange,
+				oauth2.GtJwtBearer,
 			},
 			ResponseTypesSupported: []oauth2.ResponseType{
 				oauth2.RtCode,
 				oauth2.RtToken,
 			},
-			ResponseModesSupported: []string{
-				"query",
-				"fragment",
+			ResponseModesSupported: []oauth2.ResponseMode{
+				oauth2.RmQuery,
+				oauth2.RmFragment,
+				oauth2.RmFormPost,
+				oauth2.RmJwt,
+				oauth2.RmQueryJwt,
+				oauth2.RmFragmentJwt,
+				oauth2.RmFormPostJwt,
 			},
 			TokenEndpointAuthMethodsSupported:                  authMethodsSupported,
 			TokenEndpointAuthSigningAlgValuesSupported:         signatureAlgorithmSupported,
 			IntrospectionEndpointAuthMethodsSupported:          authMethodsSupported,
 			IntrospectionEndpointAuthSigningAlgValuesSupported: signatureAlgorithmSupported,
+			IntrospectionSigningAlgValuesSupported:             signatureAlgorithmSupported,
 			RevocationEndpointAuthMethodsSupported:             authMethodsSupported,
 			RevocationEndpointAuthSigningAlgValuesSupported:    signatureAlgorithmSupported,
+			AuthorizationDetailsTypesSupported:                 h.config.GetAuthorizationDetailTypes(),
+			DPoPSigningAlgValuesSupported:                      dpop.SigningAlgorithms,
+			TlsClientCertificateBoundAccessTokens:              h.config.Server.TLS.ClientAuth.Enabled,
+			AuthorizationSigningAlgValuesSupported:             signatureAlgorithmSupported,
+			AuthorizationResponseIssParameterSupported:         true,
+			RequestParameterSupported:                          true,
+			RequestUriParameterSupported:                       true,
+			RequireSignedRequestObject:                         h.config.GetRequireSignedRequestObject(),
+			RequestObjectSigningAlgValuesSupported:             requestobject.SigningAlgorithms,
+		}
+		if h.config.Server.Registration.Enabled {
+			metadataResponse.RegistrationEndpoint = urlFromRequest.JoinPath(endpoint.Registration).String()
 		}
+
 		jsonError := internalHttp.SendJson(metadataResponse, w, r)
 		if jsonError != nil {
 			h.errorHandler.InternalServerErrorHandler(w, r, jsonError)
@@ -3,14 +3,27 @@ package metadata
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/webishdev/stopnik/internal/config"
 	"github.com/webishdev/stopnik/internal/endpoint"
+	"github.com/webishdev/stopnik/internal/oauth2"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
 )
 
 func Test_Metadata(t *testing.T) {
+	testConfig := &config.Config{
+		AuthorizationDetailTypes: []config.AuthorizationDetailType{
+			{Type: "payment_initiation"},
+		},
+	}
+	initializationError := config.Initialize(testConfig)
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+
 	metadataHandler := NewMetadataHandler()
 
 	rr := httptest.NewRecorder()
@@ -46,6 +59,10 @@ func Test_Metadata(t *testing.T) {
 		t.Error("metadata introspection_endpoint did not match")
 	}
 
+	if len(metadata.IntrospectionSigningAlgValuesSupported) == 0 {
+		t.Error("metadata introspection_signing_alg_values_supported is missing")
+	}
+
 	if metadata.RevocationEndpoint != "http://example.com/revoke" {
 		t.Error("metadata revocation_endpoint did not match")
 	}
@@ -53,9 +70,116 @@ func Test_Metadata(t *testing.T) {
 	if metadata.ServiceDocumentation != "https://stopnik.webish.dev" {
 		t.Error("metadata service_documentation did not match")
 	}
+
+	if len(metadata.DPoPSigningAlgValuesSupported) == 0 {
+		t.Error("metadata dpop_signing_alg_values_supported is missing")
+	}
+
+	if len(metadata.AuthorizationDetailsTypesSupported) != 1 || metadata.AuthorizationDetailsTypesSupported[0] != "payment_initiation" {
+		t.Errorf("metadata authorization_details_types_supported did not match, %v", metadata.AuthorizationDetailsTypesSupported)
+	}
+
+	if !reflect.DeepEqual(metadata.TokenEndpointAuthMethodsSupported, []oauth2.ClientAuthMethod{oauth2.CamClientSecretBasic, oauth2.CamClientSecretPost}) {
+		t.Errorf("metadata token_endpoint_auth_methods_supported did not match, %v", metadata.TokenEndpointAuthMethodsSupported)
+	}
+
+	if metadata.TlsClientCertificateBoundAccessTokens {
+		t.Error("metadata tls_client_certificate_bound_access_tokens should not be set")
+	}
+}
+
+func Test_MetadataTlsClientAuth(t *testing.T) {
+	testConfig := &config.Config{
+		Server: config.Server{
+			TLS: config.TLS{
+				ClientAuth: config.ClientAuth{Enabled: true, CA: "ca.crt"},
+			},
+		},
+	}
+	initializationError := config.Initialize(testConfig)
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+
+	metadataHandler := NewMetadataHandler()
+
+	rr := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, endpoint.Metadata, nil)
+
+	metadataHandler.ServeHTTP(rr, request)
+
+	metadata := testMetadataParse(t, rr.Result())
+
+	expectedAuthMethods := []oauth2.ClientAuthMethod{oauth2.CamClientSecretBasic, oauth2.CamClientSecretPost, oauth2.CamTlsClientAuth, oauth2.CamSelfSignedTlsClientAuth}
+	if !reflect.DeepEqual(metadata.TokenEndpointAuthMethodsSupported, expectedAuthMethods) {
+		t.Errorf("metadata token_endpoint_auth_methods_supported did not match, %v", metadata.TokenEndpointAuthMethodsSupported)
+	}
+
+	if !metadata.TlsClientCertificateBoundAccessTokens {
+		t.Error("metadata tls_client_certificate_bound_access_tokens is missing")
+	}
+}
+
+func Test_MetadataRequestObject(t *testing.T) {
+	testConfig := &config.Config{
+		Clients: []config.Client{
+			{Id: "foo", Redirects: []string{"https://example.com/callback"}, RequireSignedRequestObject: true},
+		},
+	}
+	initializationError := config.Initialize(testConfig)
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+
+	metadataHandler := NewMetadataHandler()
+
+	rr := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, endpoint.Metadata, nil)
+
+	metadataHandler.ServeHTTP(rr, request)
+
+	metadata := testMetadataParse(t, rr.Result())
+
+	if !metadata.RequestParameterSupported || !metadata.RequestUriParameterSupported {
+		t.Error("metadata request_parameter_supported or request_uri_parameter_supported is missing")
+	}
+
+	if !metadata.RequireSignedRequestObject {
+		t.Error("metadata require_signed_request_object is missing")
+	}
+}
+
+func Test_MetadataRegistration(t *testing.T) {
+	testConfig := &config.Config{
+		Server: config.Server{
+			Registration: config.Registration{Enabled: true, InitialAccessToken: "initial"},
+		},
+	}
+	initializationError := config.Initialize(testConfig)
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+
+	metadataHandler := NewMetadataHandler()
+
+	rr := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, endpoint.Metadata, nil)
+
+	metadataHandler.ServeHTTP(rr, request)
+
+	metadata := testMetadataParse(t, rr.Result())
+
+	if metadata.RegistrationEndpoint != "http://example.com"+endpoint.Registration {
+		t.Errorf("metadata registration_endpoint did not match, %v", metadata.RegistrationEndpoint)
+	}
 }
 
 func Test_MetadataNotAllowedHttpMethods(t *testing.T) {
+	initializationError := config.Initialize(&config.Config{})
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+
 	var testInvalidMetadataHttpMethods = []string{
 		http.MethodPost,
 		http.MethodPut,
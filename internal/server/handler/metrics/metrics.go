@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/metrics"
+	"github.com/webishdev/stopnik/internal/server/handler/error"
+	"github.com/webishdev/stopnik/log"
+	"net/http"
+)
+
+type Handler struct {
+	registry     *metrics.Registry
+	errorHandler *error.Handler
+}
+
+func NewMetricsHandler(registry *metrics.Registry) *Handler {
+	return &Handler{
+		registry:     registry,
+		errorHandler: error.NewErrorHandler(),
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.AccessLogRequest(r)
+	if r.Method == http.MethodGet {
+		w.Header().Set(internalHttp.ContentType, metrics.ContentType)
+		writeError := h.registry.Write(w)
+		if writeError != nil {
+			log.ErrorContext(r.Context(), "Failed to write metrics", log.Err(writeError))
+			return
+		}
+	} else {
+		h.errorHandler.MethodNotAllowedHandler(w, r)
+		return
+	}
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"fmt"
+	"github.com/webishdev/stopnik/internal/endpoint"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Metrics(t *testing.T) {
+	registry := metrics.NewRegistry()
+	counter := registry.NewCounterVec("foo_total", "Foo counter.", "bar")
+	counter.Inc("baz")
+
+	metricsHandler := NewMetricsHandler(registry)
+
+	rr := httptest.NewRecorder()
+
+	metricsHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, endpoint.Metrics, nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	contentType := rr.Header().Get(internalHttp.ContentType)
+
+	if contentType != metrics.ContentType {
+		t.Errorf("content type should be %s", metrics.ContentType)
+	}
+
+	if !strings.Contains(rr.Body.String(), `foo_total{bar="baz"} 1`) {
+		t.Errorf("metrics output did not contain counter, was %s", rr.Body.String())
+	}
+}
+
+func Test_MetricsNotAllowedHttpMethods(t *testing.T) {
+	var testInvalidMetricsHttpMethods = []string{
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+	}
+
+	for _, method := range testInvalidMetricsHttpMethods {
+		testMessage := fmt.Sprintf("Metrics with unsupported method %s", method)
+		t.Run(testMessage, func(t *testing.T) {
+			metricsHandler := NewMetricsHandler(metrics.NewRegistry())
+
+			rr := httptest.NewRecorder()
+
+			metricsHandler.ServeHTTP(rr, httptest.NewRequest(method, endpoint.Metrics, nil))
+
+			if rr.Code != http.StatusMethodNotAllowed {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusMethodNotAllowed)
+			}
+		})
+	}
+}
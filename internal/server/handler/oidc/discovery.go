@@ -2,8 +2,12 @@ package oidc
 
 import (
 	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
 	"github.com/webishdev/stopnik/internal/endpoint"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/manager/dpop"
+	"github.com/webishdev/stopnik/internal/manager/requestobject"
 	"github.com/webishdev/stopnik/internal/oauth2"
 	"github.com/webishdev/stopnik/internal/oidc"
 	"github.com/webishdev/stopnik/internal/pkce"
@@ -13,56 +17,64 @@ import (
 )
 
 type oidcConfigurationResponse struct {
-	Issuer                                             string                     `json:"issuer"`
-	AuthorizationEndpoint                              string                     `json:"authorization_endpoint"`
-	TokenEndpoint                                      string                     `json:"token_endpoint"`
-	UserInfoEndpoint                                   string                     `json:"userinfo_endpoint,omitempty"`
-	JWKsUri                                            string                     `json:"jwks_uri,omitempty"`
-	RegistrationEndpoint                               string                     `json:"registration_endpoint,omitempty"`
-	ScopesSupported                                    []string                   `json:"scopes_supported,omitempty"`
-	ResponseTypesSupported                             []oauth2.ResponseType      `json:"response_types_supported"`
-	ResponseModesSupported                             []string                   `json:"response_modes_supported,omitempty"`
-	GrantTypesSupported                                []oauth2.GrantType         `json:"grant_types_supported,omitempty"`
-	AcrValuesSupported                                 []string                   `json:"acr_values_supported,omitempty"`
-	SubjectTypesSupported                              []string                   `json:"subject_types_supported"`
-	IdTokenSigningAlgValuesSupported                   []jwa.SignatureAlgorithm   `json:"id_token_signing_alg_values_supported"`
-	IdTokenEncryptionAlgValuesSupported                []string                   `json:"id_token_encryption_alg_values_supported,omitempty"`
-	IdTokenEncryptionEncValuesSupported                []string                   `json:"id_token_encryption_enc_values_supported,omitempty"`
-	UserInfoSigningAlgValuesSupported                  []jwa.SignatureAlgorithm   `json:"userinfo_signing_alg_values_supported,omitempty"`
-	UserInfoEncryptionAlgValuesSupported               []string                   `json:"userinfo_encryption_alg_values_supported,omitempty"`
-	UserInfoEncryptionEncValuesSupported               []string                   `json:"userinfo_encryption_enc_values_supported,omitempty"`
-	RequestObjectSigningAlgValuesSupported             []jwa.SignatureAlgorithm   `json:"request_object_signing_alg_values_supported,omitempty"`
-	RequestObjectEncryptionAlgValuesSupported          []string                   `json:"request_object_encryption_alg_values_supported,omitempty"`
-	RequestObjectEncryptionEncValuesSupported          []string                   `json:"request_object_encryption_enc_values_supported,omitempty"`
-	DisplayValuesSupported                             []string                   `json:"display_values_supported,omitempty"`
-	ClaimTypesSupported                                []string                   `json:"claim_types_supported,omitempty"`
-	ClaimsSupported                                    []string                   `json:"claims_supported,omitempty"`
-	ClaimsLocalesSupported                             []string                   `json:"claims_locales_supported,omitempty"`
-	ClaimsParameterSupported                           []string                   `json:"claims_parameter_supported,omitempty"`
-	RequestParameterSupported                          bool                       `json:"request_parameter_supported,omitempty"`
-	RequestUriParameterSupported                       []string                   `json:"request_uri_parameter_supported,omitempty"`
-	RequireRequestUriRegistration                      []string                   `json:"require_request_uri_registration,omitempty"`
-	TokenEndpointAuthMethodsSupported                  []string                   `json:"token_endpoint_auth_methods_supported,omitempty"`
-	TokenEndpointAuthSigningAlgValuesSupported         []jwa.SignatureAlgorithm   `json:"token_endpoint_auth_signing_alg_values_supported,omitempty"`
-	ServiceDocumentation                               string                     `json:"service_documentation,omitempty"`
-	UILocalesSupported                                 []string                   `json:"ui_locales_supported,omitempty"`
-	OpPolicyUri                                        string                     `json:"op_policy_uri,omitempty"`
-	OpTosUri                                           string                     `json:"op_tos_uri,omitempty"`
-	RevocationEndpoint                                 string                     `json:"revocation_endpoint,omitempty"`
-	RevocationEndpointAuthMethodsSupported             []string                   `json:"revocation_endpoint_auth_methods_supported,omitempty"`
-	RevocationEndpointAuthSigningAlgValuesSupported    []jwa.SignatureAlgorithm   `json:"revocation_endpoint_auth_signing_alg_values_supported,omitempty"`
-	IntrospectionEndpoint                              string                     `json:"introspection_endpoint,omitempty"`
-	IntrospectionEndpointAuthMethodsSupported          []string                   `json:"introspection_endpoint_auth_methods_supported,omitempty"`
-	IntrospectionEndpointAuthSigningAlgValuesSupported []jwa.SignatureAlgorithm   `json:"introspection_endpoint_auth_signing_alg_values_supported,omitempty"`
-	CodeChallengeMethodsSupported                      []pkce.CodeChallengeMethod `json:"code_challenge_methods_supported,omitempty"`
+	Issuer                                             string                           `json:"issuer"`
+	AuthorizationEndpoint                              string                           `json:"authorization_endpoint"`
+	TokenEndpoint                                      string                           `json:"token_endpoint"`
+	UserInfoEndpoint                                   string                           `json:"userinfo_endpoint,omitempty"`
+	JWKsUri                                            string                           `json:"jwks_uri,omitempty"`
+	RegistrationEndpoint                               string                           `json:"registration_endpoint,omitempty"`
+	ScopesSupported                                    []string                         `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported                             []oauth2.ResponseType            `json:"response_types_supported"`
+	ResponseModesSupported                             []oauth2.ResponseMode            `json:"response_modes_supported,omitempty"`
+	GrantTypesSupported                                []oauth2.GrantType               `json:"grant_types_supported,omitempty"`
+	AcrValuesSupported                                 []string                         `json:"acr_values_supported,omitempty"`
+	SubjectTypesSupported                              []string                         `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported                   []jwa.SignatureAlgorithm         `json:"id_token_signing_alg_values_supported"`
+	IdTokenEncryptionAlgValuesSupported                []jwa.KeyEncryptionAlgorithm     `json:"id_token_encryption_alg_values_supported,omitempty"`
+	IdTokenEncryptionEncValuesSupported                []jwa.ContentEncryptionAlgorithm `json:"id_token_encryption_enc_values_supported,omitempty"`
+	UserInfoSigningAlgValuesSupported                  []jwa.SignatureAlgorithm         `json:"userinfo_signing_alg_values_supported,omitempty"`
+	UserInfoEncryptionAlgValuesSupported               []jwa.KeyEncryptionAlgorithm     `json:"userinfo_encryption_alg_values_supported,omitempty"`
+	UserInfoEncryptionEncValuesSupported               []jwa.ContentEncryptionAlgorithm `json:"userinfo_encryption_enc_values_supported,omitempty"`
+	RequestObjectSigningAlgValuesSupported             []jwa.SignatureAlgorithm         `json:"request_object_signing_alg_values_supported,omitempty"`
+	RequestObjectEncryptionAlgValuesSupported          []jwa.KeyEncryptionAlgorithm     `json:"request_object_encryption_alg_values_supported,omitempty"`
+	RequestObjectEncryptionEncValuesSupported          []jwa.ContentEncryptionAlgorithm `json:"request_object_encryption_enc_values_supported,omitempty"`
+	DisplayValuesSupported                             []string                         `json:"display_values_supported,omitempty"`
+	ClaimTypesSupported                                []string                         `json:"claim_types_supported,omitempty"`
+	ClaimsSupported                                    []string                         `json:"claims_supported,omitempty"`
+	ClaimsLocalesSupported                             []string                         `json:"claims_locales_supported,omitempty"`
+	ClaimsParameterSupported                           []string                         `json:"claims_parameter_supported,omitempty"`
+	RequestParameterSupported                          bool                             `json:"request_parameter_supported,omitempty"`
+	RequestUriParameterSupported                       bool                             `json:"request_uri_parameter_supported,omitempty"`
+	RequireRequestUriRegistration                      bool                             `json:"require_request_uri_registration,omitempty"`
+	TokenEndpointAuthMethodsSupported                  []oauth2.ClientAuthMethod        `json:"token_endpoint_auth_methods_supported,omitempty"`
+	TokenEndpointAuthSigningAlgValuesSupported         []jwa.SignatureAlgorithm         `json:"token_endpoint_auth_signing_alg_values_supported,omitempty"`
+	ServiceDocumentation                               string                           `json:"service_documentation,omitempty"`
+	UILocalesSupported                                 []string                         `json:"ui_locales_supported,omitempty"`
+	OpPolicyUri                                        string                           `json:"op_policy_uri,omitempty"`
+	OpTosUri                                           string                           `json:"op_tos_uri,omitempty"`
+	RevocationEndpoint                                 string                           `json:"revocation_endpoint,omitempty"`
+	RevocationEndpointAuthMethodsSupported             []oauth2.ClientAuthMethod        `json:"revocation_endpoint_auth_methods_supported,omitempty"`
+	RevocationEndpointAuthSigningAlgValuesSupported    []jwa.SignatureAlgorithm         `json:"revocation_endpoint_auth_signing_alg_values_supported,omitempty"`
+	IntrospectionEndpoint                              string                           `json:"introspection_endpoint,omitempty"`
+	IntrospectionEndpointAuthMethodsSupported          []oauth2.ClientAuthMethod        `json:"introspection_endpoint_auth_methods_supported,omitempty"`
+	IntrospectionEndpointAuthSigningAlgValuesSupported []jwa.SignatureAlgorithm         `json:"introspection_endpoint_auth_signing_alg_values_supported,omitempty"`
+	CodeChallengeMethodsSupported                      []pkce.CodeChallengeMethod       `json:"code_challenge_methods_supported,omitempty"`
+	DPoPSigningAlgValuesSupported                      []jwa.SignatureAlgorithm         `json:"dpop_signing_alg_values_supported,omitempty"`
+	AuthorizationSigningAlgValuesSupported             []jwa.SignatureAlgorithm         `json:"authorization_signing_alg_values_supported,omitempty"`
+	AuthorizationResponseIssParameterSupported         bool                             `json:"authorization_response_iss_parameter_supported,omitempty"`
+	TlsClientCertificateBoundAccessTokens              bool                             `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+	RequireSignedRequestObject                         bool                             `json:"require_signed_request_object,omitempty"`
 }
 
 type DiscoveryHandler struct {
+	config       *config.Config
 	errorHandler *errorHandler.Handler
 }
 
 func NewOidcDiscoveryHandler() *DiscoveryHandler {
+	currentConfig := config.GetConfigInstance()
 	return &DiscoveryHandler{
+		config:       currentConfig,
 		errorHandler: errorHandler.NewErrorHandler(),
 	}
 }
@@ -89,10 +101,7 @@ func (h *DiscoveryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// OIDC 1.0 Core
 		userInfoEndpoint := urlFromRequest.JoinPath(endpoint.OidcUserInfo)
 
-		authMethodsSupported := []string{
-			"client_secret_basic",
-			"client_secret_post",
-		}
+		authMethodsSupported := h.config.GetClientAuthMethods()
 
 		signatureAlgorithmSupported := []jwa.SignatureAlgorithm{
 			jwa.RS256,
@@ -126,9 +135,14 @@ func (h *DiscoveryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				oauth2.RtCode,
 				oauth2.RtToken,
 			},
-			ResponseModesSupported: []string{
-				"query",
-				"fragment",
+			ResponseModesSupported: []oauth2.ResponseMode{
+				oauth2.RmQuery,
+				oauth2.RmFragment,
+				oauth2.RmFormPost,
+				oauth2.RmJwt,
+				oauth2.RmQueryJwt,
+				oauth2.RmFragmentJwt,
+				oauth2.RmFormPostJwt,
 			},
 			TokenEndpointAuthMethodsSupported:                  authMethodsSupported,
 			TokenEndpointAuthSigningAlgValuesSupported:         signatureAlgorithmSupported,
@@ -136,11 +150,33 @@ func (h *DiscoveryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			IntrospectionEndpointAuthSigningAlgValuesSupported: signatureAlgorithmSupported,
 			RevocationEndpointAuthMethodsSupported:             authMethodsSupported,
 			RevocationEndpointAuthSigningAlgValuesSupported:    signatureAlgorithmSupported,
+			DPoPSigningAlgValuesSupported:                      dpop.SigningAlgorithms,
+			TlsClientCertificateBoundAccessTokens:              h.config.Server.TLS.ClientAuth.Enabled,
+			AuthorizationSigningAlgValuesSupported:             signatureAlgorithmSupported,
+			AuthorizationResponseIssParameterSupported:         true,
 			IdTokenSigningAlgValuesSupported:                   signatureAlgorithmSupported,
+			IdTokenEncryptionAlgValuesSupported:                crypto.KeyEncryptionAlgorithms,
+			UserInfoSigningAlgValuesSupported:                  signatureAlgorithmSupported,
+			IdTokenEncryptionEncValuesSupported:                crypto.ContentEncryptionAlgorithms,
+			UserInfoEncryptionAlgValuesSupported:               crypto.KeyEncryptionAlgorithms,
+			UserInfoEncryptionEncValuesSupported:               crypto.ContentEncryptionAlgorithms,
 			RequestParameterSupported:                          true,
-			SubjectTypesSupported:                              []string{"public"},
+			RequestUriParameterSupported:                       true,
+			RequireRequestUriRegistration:                      true,
+			RequestObjectSigningAlgValuesSupported:             requestobject.SigningAlgorithms,
+			RequireSignedRequestObject:                         h.config.GetRequireSignedRequestObject(),
+			SubjectTypesSupported:                              h.config.GetSubjectTypes(),
+			AcrValuesSupported:                                 h.config.GetAcrValues(),
 			ScopesSupported:                                    []string{oidc.ScopeOpenId, oidc.ScopeProfile, oidc.ScopeAddress, oidc.ScopeEmail, oidc.ScopePhone, oidc.ScopeOfflineAccess},
 		}
+		if h.config.Server.EncryptionKey != "" {
+			metadataResponse.RequestObjectEncryptionAlgValuesSupported = crypto.KeyEncryptionAlgorithms
+			metadataResponse.RequestObjectEncryptionEncValuesSupported = crypto.ContentEncryptionAlgorithms
+		}
+		if h.config.Server.Registration.Enabled {
+			metadataResponse.RegistrationEndpoint = urlFromRequest.JoinPath(endpoint.Registration).String()
+		}
+
 		jsonError := internalHttp.SendJson(metadataResponse, w, r)
 		if jsonError != nil {
 			h.errorHandler.InternalServerErrorHandler(w, r, jsonError)
@@ -3,14 +3,30 @@ package oidc
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
 	"github.com/webishdev/stopnik/internal/endpoint"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
 )
 
 func Test_OidcConfiguration(t *testing.T) {
+	initializationError := config.Initialize(&config.Config{
+		Server: config.Server{
+			AuthenticationLevels: []config.AuthenticationLevel{
+				{Acr: "password", Methods: []string{config.AuthenticationMethodPassword}},
+				{Acr: "password+otp", Methods: []string{config.AuthenticationMethodPassword, config.AuthenticationMethodOtp}},
+			},
+		},
+	})
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+
 	oidcDiscoveryHandler := NewOidcDiscoveryHandler()
 
 	rr := httptest.NewRecorder()
@@ -53,9 +69,30 @@ func Test_OidcConfiguration(t *testing.T) {
 	if oidcConfigurationParse.ServiceDocumentation != "https://stopnik.webish.dev" {
 		t.Error("oidcConfigurationParse service_documentation did not match")
 	}
+
+	if !slices.Equal(oidcConfigurationParse.SubjectTypesSupported, []string{config.SubjectTypePublic}) {
+		t.Errorf("oidcConfigurationParse subject_types_supported did not match %v", oidcConfigurationParse.SubjectTypesSupported)
+	}
+
+	if !slices.Equal(oidcConfigurationParse.AcrValuesSupported, []string{"password", "password+otp"}) {
+		t.Errorf("oidcConfigurationParse acr_values_supported did not match %v", oidcConfigurationParse.AcrValuesSupported)
+	}
+
+	if !slices.Equal(oidcConfigurationParse.IdTokenEncryptionAlgValuesSupported, crypto.KeyEncryptionAlgorithms) || !slices.Equal(oidcConfigurationParse.UserInfoEncryptionEncValuesSupported, crypto.ContentEncryptionAlgorithms) {
+		t.Error("oidcConfigurationParse encryption algorithms did not match")
+	}
+
+	if !slices.Contains(oidcConfigurationParse.UserInfoSigningAlgValuesSupported, jwa.RS256) {
+		t.Error("oidcConfigurationParse userinfo_signing_alg_values_supported did not match")
+	}
 }
 
 func Test_OidcConfigurationNotAllowedHttpMethods(t *testing.T) {
+	initializationError := config.Initialize(&config.Config{})
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+
 	var testInvalidOidcConfigurationHttpMethods = []string{
 		http.MethodPost,
 		http.MethodPut,
@@ -2,6 +2,9 @@ package oidc
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/webishdev/stopnik/internal/config"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
 	"github.com/webishdev/stopnik/internal/manager/token"
@@ -43,7 +46,7 @@ func (h *UserInfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			client := validAccessToken.Client
 			scopes := validAccessToken.Scopes
 			requestedClaims := validAccessToken.RequestedClaims
-			response.Subject = user.Username
+			response.Subject = h.config.GetSubject(client, user.Username)
 
 			applyProfileClaims(user, scopes, requestedClaims, response)
 			applyAddressClaims(user, scopes, requestedClaims, response)
@@ -64,6 +67,11 @@ func (h *UserInfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 
+			if client.UserInfoSignedResponseAlg != "" || client.Encryption.UserInfo {
+				h.sendJwt(w, r, client, result)
+				return
+			}
+
 			jsonError := internalHttp.SendJson(result, w, r)
 			if jsonError != nil {
 				h.errorHandler.InternalServerErrorHandler(w, r, jsonError)
@@ -80,6 +88,77 @@ func (h *UserInfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// sendJwt sends the userinfo response signed and or encrypted for the client,
+// signed responses are nested into the JWE encrypted with the encryption key of the client.
+// See https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse
+func (h *UserInfoHandler) sendJwt(w http.ResponseWriter, r *http.Request, client *config.Client, result interface{}) {
+	payload, marshalError := json.Marshal(result)
+	if marshalError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, marshalError)
+		return
+	}
+
+	contentType := ""
+	if client.UserInfoSignedResponseAlg != "" {
+		signedResponse, signError := h.sign(r, client, payload)
+		if signError != nil {
+			h.errorHandler.InternalServerErrorHandler(w, r, signError)
+			return
+		}
+		payload = []byte(signedResponse)
+		contentType = "JWT"
+	}
+
+	if client.Encryption.UserInfo {
+		encryptedResponse, encrypted, encryptionError := h.tokenManager.EncryptToken(client, payload, contentType)
+		if encryptionError != nil {
+			h.errorHandler.InternalServerErrorHandler(w, r, encryptionError)
+			return
+		} else if !encrypted {
+			h.errorHandler.InternalServerErrorHandler(w, r, errors.New("no encryption key for client"))
+			return
+		}
+		payload = []byte(encryptedResponse)
+	}
+
+	jwtError := internalHttp.SendJwt(string(payload), internalHttp.ContentTypeJWT, w, r)
+	if jwtError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, jwtError)
+	}
+}
+
+// sign signs the userinfo claims with the key of the client, the claims are extended by iss and aud.
+// The configured algorithm must match the key of the client.
+func (h *UserInfoHandler) sign(r *http.Request, client *config.Client, payload []byte) (string, error) {
+	signingAlgorithm := h.tokenManager.GetSigningAlgorithm(client)
+	if signingAlgorithm.String() != client.UserInfoSignedResponseAlg {
+		return "", fmt.Errorf("userinfo signing algorithm %s of client %s does not match key algorithm %s", client.UserInfoSignedResponseAlg, client.Id, signingAlgorithm)
+	}
+
+	var claims map[string]any
+	unmarshalError := json.Unmarshal(payload, &claims)
+	if unmarshalError != nil {
+		return "", unmarshalError
+	}
+
+	token := jwt.New()
+	for name, value := range claims {
+		if setError := token.Set(name, value); setError != nil {
+			return "", setError
+		}
+	}
+
+	// https://openid.net/specs/openid-connect-core-1_0.html#UserInfoResponse
+	if setError := token.Set(jwt.IssuerKey, h.config.GetIssuer(internalHttp.NewRequestData(r))); setError != nil {
+		return "", setError
+	}
+	if setError := token.Set(jwt.AudienceKey, client.Id); setError != nil {
+		return "", setError
+	}
+
+	return h.tokenManager.SignTokenWithPrivateKey(client, token, nil)
+}
+
 func applyPhoneClaims(user *config.User, scopes []string, requestedClaims *oidc.ClaimsParameter, response *UserInfoResponse) {
 	applyClaim(scopes, oidc.ScopePhone, requestedClaims, oidc.ClaimPhoneNumber, func() {
 		response.PhoneNumber = user.UserInformation.PhoneNumber
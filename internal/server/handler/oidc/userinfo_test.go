@@ -3,7 +3,11 @@ package oidc
 import (
 	"encoding/json"
 	"fmt"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwe"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
 	"github.com/webishdev/stopnik/internal/endpoint"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
 	"github.com/webishdev/stopnik/internal/manager/token"
@@ -17,12 +21,43 @@ import (
 func Test_UserInfo(t *testing.T) {
 
 	testConfig := &config.Config{
+		Server: config.Server{
+			PairwiseSalt: "salt",
+		},
 		Clients: []config.Client{
 			{
 				Id:           "foo",
 				ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
 				Redirects:    []string{"https://example.com/callback"},
 			},
+			{
+				Id:           "bar",
+				ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				Redirects:    []string{"https://example.com/callback"},
+				SubjectType:  config.SubjectTypePairwise,
+			},
+			{
+				Id:           "moo",
+				ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				Redirects:    []string{"https://example.com/callback"},
+				Encryption: config.ClientEncryption{
+					Key:      "../../../../.test_files/rsa256pub.pem",
+					UserInfo: true,
+				},
+			},
+			{
+				Id:                        "signed",
+				ClientSecret:              "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				Redirects:                 []string{"https://example.com/callback"},
+				PrivateKey:                "../../../../.test_files/rsa256key.pem",
+				UserInfoSignedResponseAlg: "RS256",
+			},
+			{
+				Id:                        "mismatch",
+				ClientSecret:              "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				Redirects:                 []string{"https://example.com/callback"},
+				UserInfoSignedResponseAlg: "ES256",
+			},
 		},
 		Users: []config.User{
 			{
@@ -57,6 +92,12 @@ func Test_UserInfo(t *testing.T) {
 
 	testOidcUserInfo(t, testConfig)
 
+	testOidcUserInfoPairwise(t, testConfig)
+
+	testOidcUserInfoEncrypted(t, testConfig)
+
+	testOidcUserInfoSigned(t, testConfig)
+
 	testOidcUserInfoNotAllowedHttpMethods(t)
 }
 
@@ -70,7 +111,7 @@ func testOidcUserInfo(t *testing.T, testConfig *config.Config) {
 		}
 
 		request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
-		tokenResponse := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{"a:foo", "b:bar", oidc.ScopeProfile, oidc.ScopeEmail, oidc.ScopePhone, oidc.ScopeAddress}, nil, "", "")
+		tokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{"a:foo", "b:bar", oidc.ScopeProfile, oidc.ScopeEmail, oidc.ScopePhone, oidc.ScopeAddress}, nil, "", "", nil)
 
 		oidcDiscoveryHandler := NewOidcUserInfoHandler(tokenManager)
 
@@ -146,6 +187,155 @@ func testOidcUserInfo(t *testing.T, testConfig *config.Config) {
 	})
 }
 
+func testOidcUserInfoPairwise(t *testing.T, testConfig *config.Config) {
+	t.Run("OIDC UserInfo with pairwise subject", func(t *testing.T) {
+		tokenManager := token.GetTokenManagerInstance()
+
+		client, clientExists := testConfig.GetClient("bar")
+		if !clientExists {
+			t.Error("client should exist")
+		}
+
+		request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+		tokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{oidc.ScopeProfile}, nil, "", "", nil)
+
+		oidcUserInfoHandler := NewOidcUserInfoHandler(tokenManager)
+
+		httpRequest := httptest.NewRequest(http.MethodGet, endpoint.OidcUserInfo, nil)
+		httpRequest.Header.Set(internalHttp.Authorization, "Bearer "+tokenResponse.AccessTokenValue)
+		rr := httptest.NewRecorder()
+
+		oidcUserInfoHandler.ServeHTTP(rr, httpRequest)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+
+		userProfile := testOidcUserInfoParse(t, rr.Result())
+
+		if userProfile.Subject == "foo" || userProfile.Subject != testConfig.GetSubject(client, "foo") {
+			t.Errorf("userinfo subject was not pairwise %s", userProfile.Subject)
+		}
+
+		user, userExists := testConfig.GetUserBySubject(client, userProfile.Subject)
+		if !userExists || user.Username != "foo" {
+			t.Errorf("userinfo subject could not be mapped to user")
+		}
+	})
+}
+
+func testOidcUserInfoEncrypted(t *testing.T, testConfig *config.Config) {
+	t.Run("OIDC UserInfo encrypted", func(t *testing.T) {
+		tokenManager := token.GetTokenManagerInstance()
+
+		client, clientExists := testConfig.GetClient("moo")
+		if !clientExists {
+			t.Error("client should exist")
+		}
+
+		request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+		tokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{oidc.ScopeProfile}, nil, "", "", nil)
+
+		oidcUserInfoHandler := NewOidcUserInfoHandler(tokenManager)
+
+		httpRequest := httptest.NewRequest(http.MethodGet, endpoint.OidcUserInfo, nil)
+		httpRequest.Header.Set(internalHttp.Authorization, "Bearer "+tokenResponse.AccessTokenValue)
+		rr := httptest.NewRecorder()
+
+		oidcUserInfoHandler.ServeHTTP(rr, httpRequest)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+
+		if rr.Header().Get(internalHttp.ContentType) != internalHttp.ContentTypeJWT {
+			t.Errorf("userinfo content type did not match %s", rr.Header().Get(internalHttp.ContentType))
+		}
+
+		privateKey, privateKeyError := crypto.LoadPrivateKey("../../../../.test_files/rsa256key.pem")
+		if privateKeyError != nil {
+			t.Fatal(privateKeyError)
+		}
+
+		decrypted, decryptError := jwe.Decrypt(rr.Body.Bytes(), jwe.WithKey(jwa.RSA_OAEP, privateKey.PrivateKey))
+		if decryptError != nil {
+			t.Fatal(decryptError)
+		}
+
+		userProfile := UserInfoResponse{}
+		jsonParseError := json.Unmarshal(decrypted, &userProfile)
+		if jsonParseError != nil {
+			t.Fatal(jsonParseError)
+		}
+
+		if userProfile.Subject != "foo" || userProfile.GivenName != "John" {
+			t.Errorf("userinfo did not match %v", userProfile)
+		}
+	})
+}
+
+func testOidcUserInfoSigned(t *testing.T, testConfig *config.Config) {
+	type parameter struct {
+		clientId       string
+		expectedStatus int
+	}
+
+	var parameters = []parameter{
+		{"signed", http.StatusOK},
+		{"mismatch", http.StatusInternalServerError},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("OIDC UserInfo signed for client %s", test.clientId)
+		t.Run(testMessage, func(t *testing.T) {
+			tokenManager := token.GetTokenManagerInstance()
+
+			client, clientExists := testConfig.GetClient(test.clientId)
+			if !clientExists {
+				t.Error("client should exist")
+			}
+
+			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+			tokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "foo", client, nil, []string{oidc.ScopeProfile}, nil, "", "", nil)
+
+			oidcUserInfoHandler := NewOidcUserInfoHandler(tokenManager)
+
+			httpRequest := httptest.NewRequest(http.MethodGet, endpoint.OidcUserInfo, nil)
+			httpRequest.Header.Set(internalHttp.Authorization, "Bearer "+tokenResponse.AccessTokenValue)
+			rr := httptest.NewRecorder()
+
+			oidcUserInfoHandler.ServeHTTP(rr, httpRequest)
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+
+			if rr.Header().Get(internalHttp.ContentType) != internalHttp.ContentTypeJWT {
+				t.Errorf("userinfo content type did not match %s", rr.Header().Get(internalHttp.ContentType))
+			}
+
+			publicKey, publicKeyError := crypto.LoadPublicKey("../../../../.test_files/rsa256pub.pem")
+			if publicKeyError != nil {
+				t.Fatal(publicKeyError)
+			}
+
+			parsedToken, parseError := jwt.Parse(rr.Body.Bytes(), jwt.WithKey(jwa.RS256, publicKey), jwt.WithIssuer("http://example.com"), jwt.WithAudience(test.clientId))
+			if parseError != nil {
+				t.Fatal(parseError)
+			}
+
+			givenName, _ := parsedToken.Get(oidc.ClaimGivenName)
+			if parsedToken.Subject() != "foo" || givenName != "John" {
+				t.Errorf("userinfo claims did not match %v", parsedToken)
+			}
+		})
+	}
+}
+
 func testOidcUserInfoNotAllowedHttpMethods(t *testing.T) {
 	var testInvalidOidcUserInfoHttpMethods = []string{
 		http.MethodPut,
@@ -0,0 +1,116 @@
+package registration
+
+import (
+	"fmt"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+var supportedAuthMethods = []oauth2.ClientAuthMethod{
+	oauth2.CamClientSecretBasic,
+	oauth2.CamClientSecretPost,
+	oauth2.CamNone,
+}
+
+// supportedGrantTypes contains the grant types clients may register for themselves,
+// the password, token exchange and JWT bearer grant types are only available to configured clients.
+var supportedGrantTypes = []oauth2.GrantType{
+	oauth2.GtAuthorizationCode,
+	oauth2.GtClientCredentials,
+	oauth2.GtRefreshToken,
+	oauth2.GtImplicit,
+}
+
+// validateMetadata applies the default values to the given metadata and checks whether the values are supported and consistent,
+// the refresh_token grant type is only supported when a refresh token lifetime is provided for registered clients.
+// See https://datatracker.ietf.org/doc/html/rfc7591#section-2
+func validateMetadata(metadata *oauth2.ClientMetadata, refreshTTL int) *oauth2.RegistrationErrorResponseParameter {
+	if metadata.TokenEndpointAuthMethod == "" {
+		metadata.TokenEndpointAuthMethod = oauth2.CamClientSecretBasic
+	}
+	if len(metadata.GrantTypes) == 0 {
+		metadata.GrantTypes = []oauth2.GrantType{oauth2.GtAuthorizationCode}
+	}
+	if len(metadata.ResponseTypes) == 0 && slices.Contains(metadata.GrantTypes, oauth2.GtAuthorizationCode) {
+		metadata.ResponseTypes = []string{string(oauth2.RtCode)}
+	}
+
+	if !slices.Contains(supportedAuthMethods, metadata.TokenEndpointAuthMethod) {
+		return invalidClientMetadata(fmt.Sprintf("unsupported token_endpoint_auth_method %s", metadata.TokenEndpointAuthMethod))
+	}
+
+	for _, grantType := range metadata.GrantTypes {
+		if !slices.Contains(supportedGrantTypes, grantType) {
+			return invalidClientMetadata(fmt.Sprintf("unsupported grant type %s", grantType))
+		}
+	}
+
+	if refreshTTL <= 0 && slices.Contains(metadata.GrantTypes, oauth2.GtRefreshToken) {
+		return invalidClientMetadata("refresh_token grant type is not available for registered clients")
+	}
+
+	if metadata.TokenEndpointAuthMethod == oauth2.CamNone && slices.Contains(metadata.GrantTypes, oauth2.GtClientCredentials) {
+		return invalidClientMetadata("client_credentials grant type requires client authentication")
+	}
+
+	for _, responseType := range metadata.ResponseTypes {
+		values := strings.Fields(responseType)
+		if len(values) == 0 {
+			return invalidClientMetadata("empty response type")
+		}
+		for _, value := range values {
+			grantType, supported := oauth2.ResponseTypeGrantTypes[oauth2.ResponseType(value)]
+			if !supported {
+				return invalidClientMetadata(fmt.Sprintf("unsupported response type %s", value))
+			}
+			if !slices.Contains(metadata.GrantTypes, grantType) {
+				return invalidClientMetadata(fmt.Sprintf("response type %s requires grant type %s", value, grantType))
+			}
+		}
+	}
+
+	redirectRequired := slices.Contains(metadata.GrantTypes, oauth2.GtAuthorizationCode) || slices.Contains(metadata.GrantTypes, oauth2.GtImplicit)
+	if redirectRequired && len(metadata.RedirectUris) == 0 {
+		return &oauth2.RegistrationErrorResponseParameter{Error: oauth2.RegistrationEtInvalidRedirectUri, Description: "redirect_uris are missing"}
+	}
+	for _, redirectUri := range metadata.RedirectUris {
+		if !validRedirectUri(redirectUri) {
+			return &oauth2.RegistrationErrorResponseParameter{Error: oauth2.RegistrationEtInvalidRedirectUri, Description: fmt.Sprintf("invalid redirect uri %s", redirectUri)}
+		}
+	}
+
+	for _, uri := range []string{metadata.ClientUri, metadata.LogoUri, metadata.TosUri, metadata.PolicyUri} {
+		if uri != "" && !validUri(uri) {
+			return invalidClientMetadata(fmt.Sprintf("invalid uri %s", uri))
+		}
+	}
+
+	return nil
+}
+
+func invalidClientMetadata(description string) *oauth2.RegistrationErrorResponseParameter {
+	return &oauth2.RegistrationErrorResponseParameter{Error: oauth2.RegistrationEtInvalidClientMetadata, Description: description}
+}
+
+// validRedirectUri checks for absolute URIs without fragment, see https://datatracker.ietf.org/doc/html/rfc6749#section-3.1.2
+// Besides http and https only private-use schemes of native apps in reverse domain notation are accepted,
+// see https://datatracker.ietf.org/doc/html/rfc8252#section-7.1
+func validRedirectUri(value string) bool {
+	parsedUri, parseError := url.Parse(value)
+	if parseError != nil || !parsedUri.IsAbs() || parsedUri.Fragment != "" {
+		return false
+	}
+	return isWebScheme(parsedUri.Scheme) || strings.Contains(parsedUri.Scheme, ".")
+}
+
+// validUri checks for absolute http or https URIs without fragment, which are shown to users or fetched.
+func validUri(value string) bool {
+	parsedUri, parseError := url.Parse(value)
+	return parseError == nil && isWebScheme(parsedUri.Scheme) && parsedUri.Host != "" && parsedUri.Fragment == ""
+}
+
+func isWebScheme(scheme string) bool {
+	return scheme == "https" || scheme == "http"
+}
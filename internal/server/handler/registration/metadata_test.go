@@ -0,0 +1,68 @@
+package registration
+
+import (
+	"fmt"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"testing"
+)
+
+func Test_ValidateMetadata(t *testing.T) {
+	type metadataParameter struct {
+		name     string
+		metadata oauth2.ClientMetadata
+		expected oauth2.RegistrationErrorType
+	}
+
+	var metadataParameters = []metadataParameter{
+		{"minimal", oauth2.ClientMetadata{RedirectUris: []string{"https://example.com/callback"}}, ""},
+		{"public", oauth2.ClientMetadata{RedirectUris: []string{"https://example.com/callback"}, TokenEndpointAuthMethod: oauth2.CamNone}, ""},
+		{"hybrid", oauth2.ClientMetadata{RedirectUris: []string{"https://example.com/callback"}, GrantTypes: []oauth2.GrantType{oauth2.GtAuthorizationCode, oauth2.GtImplicit}, ResponseTypes: []string{"code id_token"}}, ""},
+		{"client credentials", oauth2.ClientMetadata{GrantTypes: []oauth2.GrantType{oauth2.GtClientCredentials}}, ""},
+		{"missing redirect", oauth2.ClientMetadata{}, oauth2.RegistrationEtInvalidRedirectUri},
+		{"relative redirect", oauth2.ClientMetadata{RedirectUris: []string{"/callback"}}, oauth2.RegistrationEtInvalidRedirectUri},
+		{"redirect with fragment", oauth2.ClientMetadata{RedirectUris: []string{"https://example.com/callback#foo"}}, oauth2.RegistrationEtInvalidRedirectUri},
+		{"native app redirect", oauth2.ClientMetadata{RedirectUris: []string{"com.example.app:/callback"}}, ""},
+		{"javascript redirect", oauth2.ClientMetadata{RedirectUris: []string{"javascript:alert(1)"}}, oauth2.RegistrationEtInvalidRedirectUri},
+		{"data redirect", oauth2.ClientMetadata{RedirectUris: []string{"data:text/html,foo"}}, oauth2.RegistrationEtInvalidRedirectUri},
+		{"unsupported auth method", oauth2.ClientMetadata{RedirectUris: []string{"https://example.com/callback"}, TokenEndpointAuthMethod: oauth2.CamTlsClientAuth}, oauth2.RegistrationEtInvalidClientMetadata},
+		{"unsupported grant type", oauth2.ClientMetadata{GrantTypes: []oauth2.GrantType{"foo"}}, oauth2.RegistrationEtInvalidClientMetadata},
+		{"password grant type", oauth2.ClientMetadata{GrantTypes: []oauth2.GrantType{oauth2.GtPassword}}, oauth2.RegistrationEtInvalidClientMetadata},
+		{"token exchange grant type", oauth2.ClientMetadata{GrantTypes: []oauth2.GrantType{oauth2.GtTokenExchange}}, oauth2.RegistrationEtInvalidClientMetadata},
+		{"jwt bearer grant type", oauth2.ClientMetadata{GrantTypes: []oauth2.GrantType{oauth2.GtJwtBearer}}, oauth2.RegistrationEtInvalidClientMetadata},
+		{"refresh token", oauth2.ClientMetadata{RedirectUris: []string{"https://example.com/callback"}, GrantTypes: []oauth2.GrantType{oauth2.GtAuthorizationCode, oauth2.GtRefreshToken}}, ""},
+		{"public client credentials", oauth2.ClientMetadata{GrantTypes: []oauth2.GrantType{oauth2.GtClientCredentials}, TokenEndpointAuthMethod: oauth2.CamNone}, oauth2.RegistrationEtInvalidClientMetadata},
+		{"unsupported response type", oauth2.ClientMetadata{RedirectUris: []string{"https://example.com/callback"}, ResponseTypes: []string{"foo"}}, oauth2.RegistrationEtInvalidClientMetadata},
+		{"inconsistent response type", oauth2.ClientMetadata{RedirectUris: []string{"https://example.com/callback"}, ResponseTypes: []string{"token"}}, oauth2.RegistrationEtInvalidClientMetadata},
+		{"invalid client uri", oauth2.ClientMetadata{RedirectUris: []string{"https://example.com/callback"}, ClientUri: "example.com"}, oauth2.RegistrationEtInvalidClientMetadata},
+		{"javascript logo uri", oauth2.ClientMetadata{RedirectUris: []string{"https://example.com/callback"}, LogoUri: "javascript:alert(1)"}, oauth2.RegistrationEtInvalidClientMetadata},
+		{"file policy uri", oauth2.ClientMetadata{RedirectUris: []string{"https://example.com/callback"}, PolicyUri: "file:///etc/passwd"}, oauth2.RegistrationEtInvalidClientMetadata},
+	}
+
+	for _, test := range metadataParameters {
+		testMessage := fmt.Sprintf("Validate metadata %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			metadata := test.metadata
+			errorParameter := validateMetadata(&metadata, 60)
+
+			if test.expected == "" && errorParameter != nil {
+				t.Errorf("metadata should be valid, got %v", errorParameter)
+			} else if test.expected != "" && (errorParameter == nil || errorParameter.Error != test.expected) {
+				t.Errorf("expected error %s, got %v", test.expected, errorParameter)
+			}
+
+			if test.expected == "" && metadata.TokenEndpointAuthMethod == "" {
+				t.Error("default token endpoint auth method was not set")
+			}
+		})
+	}
+}
+
+func Test_ValidateMetadataRefreshTokenWithoutLifetime(t *testing.T) {
+	metadata := oauth2.ClientMetadata{RedirectUris: []string{"https://example.com/callback"}, GrantTypes: []oauth2.GrantType{oauth2.GtAuthorizationCode, oauth2.GtRefreshToken}}
+
+	errorParameter := validateMetadata(&metadata, 0)
+
+	if errorParameter == nil || errorParameter.Error != oauth2.RegistrationEtInvalidClientMetadata {
+		t.Errorf("refresh_token grant type should not be available without refresh token lifetime, got %v", errorParameter)
+	}
+}
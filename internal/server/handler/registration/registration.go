@@ -0,0 +1,284 @@
+package registration
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
+	"github.com/webishdev/stopnik/internal/endpoint"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	internalError "github.com/webishdev/stopnik/internal/server/handler/error"
+	"github.com/webishdev/stopnik/log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// fetchTimeout and fetchLimit restrict requests to a sector_identifier_uri.
+const (
+	fetchTimeout = 5 * time.Second
+	fetchLimit   = 64 * 1024
+)
+
+type Handler struct {
+	config       *config.Config
+	tokenManager *token.Manager
+	httpClient   *http.Client
+	errorHandler *internalError.Handler
+}
+
+func NewRegistrationHandler(tokenManager *token.Manager) *Handler {
+	currentConfig := config.GetConfigInstance()
+	return &Handler{
+		config:       currentConfig,
+		tokenManager: tokenManager,
+		httpClient:   newFetchClient(),
+		errorHandler: internalError.NewErrorHandler(),
+	}
+}
+
+// Implements https://datatracker.ietf.org/doc/html/rfc7591 and https://datatracker.ietf.org/doc/html/rfc7592
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.AccessLogRequest(r)
+
+	clientId := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, endpoint.Registration), "/")
+	if clientId == "" {
+		if r.Method == http.MethodPost {
+			h.register(w, r)
+		} else {
+			h.errorHandler.MethodNotAllowedHandler(w, r)
+		}
+		return
+	}
+
+	// https://datatracker.ietf.org/doc/html/rfc7592#section-2
+	registeredClient, validRegistrationAccessToken := h.validateRegistrationAccessToken(r, clientId)
+	if !validRegistrationAccessToken {
+		oauth2.TokenErrorStatusResponseHandler(w, r, http.StatusUnauthorized, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidRequest})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.read(w, r, registeredClient)
+	case http.MethodPut:
+		h.update(w, r, registeredClient)
+	case http.MethodDelete:
+		h.delete(w, r, registeredClient)
+	default:
+		h.errorHandler.MethodNotAllowedHandler(w, r)
+	}
+}
+
+// https://datatracker.ietf.org/doc/html/rfc7591#section-3.1
+func (h *Handler) register(w http.ResponseWriter, r *http.Request) {
+	if !h.validateInitialAccessToken(r) {
+		oauth2.TokenErrorStatusResponseHandler(w, r, http.StatusUnauthorized, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidRequest})
+		return
+	}
+
+	clientInformation, decodeError := decodeClientInformation(r)
+	if decodeError != nil {
+		oauth2.RegistrationErrorResponseHandler(w, r, invalidClientMetadata(decodeError.Error()))
+		return
+	}
+
+	metadata := clientInformation.ClientMetadata
+	metadataError := validateMetadata(&metadata, h.config.Server.Registration.RefreshTTL)
+	if metadataError == nil {
+		metadataError = h.validateSubjectType(&metadata)
+	}
+	if metadataError != nil {
+		oauth2.RegistrationErrorResponseHandler(w, r, metadataError)
+		return
+	}
+
+	registeredClient := &config.RegisteredClient{
+		ClientId: uuid.NewString(),
+		IssuedAt: time.Now().Unix(),
+		Metadata: metadata,
+	}
+	clientSecret := issueClientSecret(registeredClient)
+	registrationAccessToken := issueRegistrationAccessToken(registeredClient)
+
+	setError := h.config.SetRegisteredClient(registeredClient)
+	if setError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, setError)
+		return
+	}
+
+	log.InfoContext(r.Context(), "Client registered", log.ClientId(registeredClient.ClientId))
+	h.sendClientInformation(w, r, http.StatusCreated, registeredClient, clientSecret, registrationAccessToken)
+}
+
+// The registration access token stays valid and is not returned, as only its hash is kept.
+// See https://datatracker.ietf.org/doc/html/rfc7592#section-2.1
+func (h *Handler) read(w http.ResponseWriter, r *http.Request, registeredClient *config.RegisteredClient) {
+	h.sendClientInformation(w, r, http.StatusOK, registeredClient, "", "")
+}
+
+// The registration access token is rotated with each update.
+// See https://datatracker.ietf.org/doc/html/rfc7592#section-2.2
+func (h *Handler) update(w http.ResponseWriter, r *http.Request, current *config.RegisteredClient) {
+	clientInformation, decodeError := decodeClientInformation(r)
+	if decodeError != nil {
+		oauth2.RegistrationErrorResponseHandler(w, r, invalidClientMetadata(decodeError.Error()))
+		return
+	}
+
+	if clientInformation.ClientId != current.ClientId {
+		oauth2.RegistrationErrorResponseHandler(w, r, invalidClientMetadata("client_id does not match"))
+		return
+	}
+
+	if clientInformation.ClientSecret != "" && !validClientSecret(current, clientInformation.ClientSecret) {
+		oauth2.RegistrationErrorResponseHandler(w, r, invalidClientMetadata("client_secret does not match"))
+		return
+	}
+
+	metadata := clientInformation.ClientMetadata
+	metadataError := validateMetadata(&metadata, h.config.Server.Registration.RefreshTTL)
+	if metadataError == nil {
+		metadataError = h.validateSubjectType(&metadata)
+	}
+	if metadataError != nil {
+		oauth2.RegistrationErrorResponseHandler(w, r, metadataError)
+		return
+	}
+
+	registeredClient := *current
+	registeredClient.Metadata = metadata
+	clientSecret := ""
+	if metadata.TokenEndpointAuthMethod == oauth2.CamNone {
+		registeredClient.ClientSecret = ""
+		registeredClient.Salt = ""
+	} else if registeredClient.ClientSecret == "" {
+		clientSecret = issueClientSecret(&registeredClient)
+	}
+	registrationAccessToken := issueRegistrationAccessToken(&registeredClient)
+
+	setError := h.config.SetRegisteredClient(&registeredClient)
+	if setError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, setError)
+		return
+	}
+
+	log.InfoContext(r.Context(), "Client updated", log.ClientId(registeredClient.ClientId))
+	h.sendClientInformation(w, r, http.StatusOK, &registeredClient, clientSecret, registrationAccessToken)
+}
+
+// https://datatracker.ietf.org/doc/html/rfc7592#section-2.3
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, registeredClient *config.RegisteredClient) {
+	deleteError := h.config.DeleteRegisteredClient(registeredClient.ClientId)
+	if deleteError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, deleteError)
+		return
+	}
+	h.tokenManager.DeleteClientStores(registeredClient.ClientId)
+
+	log.InfoContext(r.Context(), "Client deleted", log.ClientId(registeredClient.ClientId))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) sendClientInformation(w http.ResponseWriter, r *http.Request, statusCode int, registeredClient *config.RegisteredClient, clientSecret string, registrationAccessToken string) {
+	registrationClientUri, joinError := url.JoinPath(internalHttp.NewRequestData(r).IssuerString(), endpoint.Registration, registeredClient.ClientId)
+	if joinError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, joinError)
+		return
+	}
+
+	clientInformation := &oauth2.ClientInformation{
+		ClientId:                registeredClient.ClientId,
+		ClientSecret:            clientSecret,
+		ClientIdIssuedAt:        registeredClient.IssuedAt,
+		RegistrationAccessToken: registrationAccessToken,
+		RegistrationClientUri:   registrationClientUri,
+		ClientMetadata:          registeredClient.Metadata,
+	}
+
+	w.Header().Set(internalHttp.CacheControl, "no-store")
+	sendError := internalHttp.SendJsonWithStatus(clientInformation, statusCode, w, r)
+	if sendError != nil {
+		h.errorHandler.InternalServerErrorHandler(w, r, sendError)
+	}
+}
+
+// validateInitialAccessToken checks for the configured initial access token
+// or an access token with the configured registration scope.
+// See https://datatracker.ietf.org/doc/html/rfc7591#section-3
+func (h *Handler) validateInitialAccessToken(r *http.Request) bool {
+	registration := h.config.Server.Registration
+	bearerToken := getBearerToken(r)
+	if registration.InitialAccessToken != "" && subtle.ConstantTimeCompare([]byte(bearerToken), []byte(registration.InitialAccessToken)) == 1 {
+		return true
+	}
+
+	if registration.Scope != "" {
+		validAccessToken, valid := h.tokenManager.ValidateAccessTokenRequest(r)
+		return valid && slices.Contains(validAccessToken.Scopes, registration.Scope)
+	}
+
+	return false
+}
+
+func (h *Handler) validateRegistrationAccessToken(r *http.Request, clientId string) (*config.RegisteredClient, bool) {
+	bearerToken := getBearerToken(r)
+	registeredClient, registeredClientExists := h.config.GetRegisteredClient(clientId)
+	if bearerToken == "" || !registeredClientExists {
+		return nil, false
+	}
+
+	tokenHash := crypto.Sha256Hash(bearerToken)
+	if subtle.ConstantTimeCompare([]byte(tokenHash), []byte(registeredClient.RegistrationAccessToken)) != 1 {
+		return nil, false
+	}
+
+	return registeredClient, true
+}
+
+func decodeClientInformation(r *http.Request) (*oauth2.ClientInformation, error) {
+	clientInformation := &oauth2.ClientInformation{}
+	decodeError := json.NewDecoder(r.Body).Decode(clientInformation)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+	return clientInformation, nil
+}
+
+func validClientSecret(registeredClient *config.RegisteredClient, clientSecret string) bool {
+	if registeredClient.ClientSecret == "" {
+		return false
+	}
+	secretHash := crypto.Sha512SaltedHash(clientSecret, registeredClient.Salt)
+	return subtle.ConstantTimeCompare([]byte(secretHash), []byte(registeredClient.ClientSecret)) == 1
+}
+
+func issueClientSecret(registeredClient *config.RegisteredClient) string {
+	if registeredClient.Metadata.TokenEndpointAuthMethod == oauth2.CamNone {
+		return ""
+	}
+	clientSecret := rand.Text()
+	registeredClient.Salt = rand.Text()
+	registeredClient.ClientSecret = crypto.Sha512SaltedHash(clientSecret, registeredClient.Salt)
+	return clientSecret
+}
+
+func issueRegistrationAccessToken(registeredClient *config.RegisteredClient) string {
+	registrationAccessToken := rand.Text()
+	registeredClient.RegistrationAccessToken = crypto.Sha256Hash(registrationAccessToken)
+	return registrationAccessToken
+}
+
+func getBearerToken(r *http.Request) string {
+	scheme, value, found := strings.Cut(r.Header.Get(internalHttp.Authorization), " ")
+	if !found || scheme != internalHttp.AuthBearer {
+		return ""
+	}
+	return value
+}
@@ -0,0 +1,220 @@
+package registration
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
+	"github.com/webishdev/stopnik/internal/endpoint"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_Registration(t *testing.T) {
+	testConfig := createTestConfig(t)
+	registrationHandler := NewRegistrationHandler(token.GetTokenManagerInstance())
+
+	rr := httptest.NewRecorder()
+	request := createRequest(http.MethodPost, endpoint.Registration, "initial", `{"redirect_uris":["https://example.com/callback"],"client_name":"Preview"}`)
+
+	registrationHandler.ServeHTTP(rr, request)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusCreated)
+	}
+
+	registered := readClientInformation(t, rr)
+
+	if registered.ClientId == "" || registered.ClientSecret == "" || registered.RegistrationAccessToken == "" {
+		t.Fatalf("client information is incomplete %v", registered)
+	}
+
+	if registered.TokenEndpointAuthMethod != oauth2.CamClientSecretBasic || registered.ClientName != "Preview" {
+		t.Errorf("client metadata was not returned %v", registered)
+	}
+
+	registrationClientUri, parseError := url.Parse(registered.RegistrationClientUri)
+	if parseError != nil || registrationClientUri.Path != endpoint.Registration+"/"+registered.ClientId {
+		t.Errorf("invalid registration client uri %s", registered.RegistrationClientUri)
+	}
+
+	client, clientExists := testConfig.GetClient(registered.ClientId)
+	if !clientExists {
+		t.Fatal("registered client does not exist")
+	}
+
+	if client.ClientSecret != crypto.Sha512SaltedHash(registered.ClientSecret, client.Salt) || !client.ValidateRedirect("https://example.com/callback") {
+		t.Errorf("registered client does not match client information %v", client)
+	}
+
+	t.Run("Read", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		request := createRequest(http.MethodGet, registrationClientUri.Path, registered.RegistrationAccessToken, "")
+
+		registrationHandler.ServeHTTP(rr, request)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+
+		read := readClientInformation(t, rr)
+		if read.ClientId != registered.ClientId || read.ClientSecret != "" || read.RegistrationAccessToken != "" || read.RegistrationClientUri != registered.RegistrationClientUri {
+			t.Errorf("unexpected client information %v", read)
+		}
+	})
+
+	t.Run("Update with wrong client secret", func(t *testing.T) {
+		body := fmt.Sprintf(`{"client_id":"%s","client_secret":"wrong","redirect_uris":["https://example.com/other"]}`, registered.ClientId)
+		rr := httptest.NewRecorder()
+		request := createRequest(http.MethodPut, registrationClientUri.Path, registered.RegistrationAccessToken, body)
+
+		registrationHandler.ServeHTTP(rr, request)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		body := fmt.Sprintf(`{"client_id":"%s","client_secret":"%s","redirect_uris":["https://example.com/other"],"token_endpoint_auth_method":"none"}`, registered.ClientId, registered.ClientSecret)
+		rr := httptest.NewRecorder()
+		request := createRequest(http.MethodPut, registrationClientUri.Path, registered.RegistrationAccessToken, body)
+
+		registrationHandler.ServeHTTP(rr, request)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+
+		updated := readClientInformation(t, rr)
+		if updated.RegistrationAccessToken == "" || updated.RegistrationAccessToken == registered.RegistrationAccessToken {
+			t.Errorf("registration access token was not rotated %v", updated)
+		}
+		registered.RegistrationAccessToken = updated.RegistrationAccessToken
+
+		client, clientExists := testConfig.GetClient(registered.ClientId)
+		if !clientExists || client.GetClientType() != oauth2.CtPublic || !client.ValidateRedirect("https://example.com/other") {
+			t.Errorf("registered client was not updated %v", client)
+		}
+	})
+
+	t.Run("Update other client id", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		request := createRequest(http.MethodPut, registrationClientUri.Path, registered.RegistrationAccessToken, `{"client_id":"foo","redirect_uris":["https://example.com/other"]}`)
+
+		registrationHandler.ServeHTTP(rr, request)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("Invalid registration access token", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		request := createRequest(http.MethodGet, registrationClientUri.Path, "invalid", "")
+
+		registrationHandler.ServeHTTP(rr, request)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		request := createRequest(http.MethodDelete, registrationClientUri.Path, registered.RegistrationAccessToken, "")
+
+		registrationHandler.ServeHTTP(rr, request)
+
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNoContent)
+		}
+
+		if _, clientExists := testConfig.GetClient(registered.ClientId); clientExists {
+			t.Error("registered client still exists")
+		}
+	})
+}
+
+func Test_RegistrationInvalid(t *testing.T) {
+	createTestConfig(t)
+	registrationHandler := NewRegistrationHandler(token.GetTokenManagerInstance())
+
+	type invalidParameter struct {
+		name           string
+		method         string
+		token          string
+		body           string
+		expectedStatus int
+	}
+
+	var invalidParameters = []invalidParameter{
+		{"missing initial access token", http.MethodPost, "", `{"redirect_uris":["https://example.com/callback"]}`, http.StatusUnauthorized},
+		{"invalid initial access token", http.MethodPost, "invalid", `{"redirect_uris":["https://example.com/callback"]}`, http.StatusUnauthorized},
+		{"invalid json", http.MethodPost, "initial", `{"redirect_uris":`, http.StatusBadRequest},
+		{"invalid metadata", http.MethodPost, "initial", `{"redirect_uris":["/callback"]}`, http.StatusBadRequest},
+		{"not allowed method", http.MethodGet, "initial", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range invalidParameters {
+		testMessage := fmt.Sprintf("Registration %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			request := createRequest(test.method, endpoint.Registration, test.token, test.body)
+
+			registrationHandler.ServeHTTP(rr, request)
+
+			if rr.Code != test.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+		})
+	}
+}
+
+func createRequest(method string, path string, bearerToken string, body string) *http.Request {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set(internalHttp.ContentType, internalHttp.ContentTypeJSON)
+	if bearerToken != "" {
+		request.Header.Set(internalHttp.Authorization, fmt.Sprintf("%s %s", internalHttp.AuthBearer, bearerToken))
+	}
+	return request
+}
+
+func readClientInformation(t *testing.T, rr *httptest.ResponseRecorder) *oauth2.ClientInformation {
+	clientInformation := &oauth2.ClientInformation{}
+	unmarshalError := json.Unmarshal(rr.Body.Bytes(), clientInformation)
+	if unmarshalError != nil {
+		t.Fatal(unmarshalError)
+	}
+	return clientInformation
+}
+
+func createTestConfig(t *testing.T) *config.Config {
+	testConfig := &config.Config{
+		Server: config.Server{
+			Registration: config.Registration{
+				Enabled:            true,
+				InitialAccessToken: "initial",
+			},
+		},
+		Clients: []config.Client{
+			{
+				Id:           "foo",
+				ClientSecret: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				Redirects:    []string{"https://example.com/callback"},
+			},
+		},
+	}
+
+	initializationError := config.Initialize(testConfig)
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+	return testConfig
+}
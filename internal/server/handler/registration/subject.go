@@ -0,0 +1,106 @@
+package registration
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"github.com/webishdev/stopnik/internal/config"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/log"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// validateSubjectType checks the requested subject type and the sector identifier of the given metadata.
+// A sector_identifier_uri must provide a JSON array which contains all redirect_uris,
+// without a sector_identifier_uri pairwise clients must use a single redirect host.
+// See https://openid.net/specs/openid-connect-registration-1_0.html#SectorIdentifierValidation
+func (h *Handler) validateSubjectType(metadata *oauth2.ClientMetadata) *oauth2.RegistrationErrorResponseParameter {
+	subjectType := cmp.Or(metadata.SubjectType, config.SubjectTypePublic)
+	if !slices.Contains(h.config.GetSubjectTypes(), subjectType) {
+		return invalidClientMetadata(fmt.Sprintf("unsupported subject_type %s", subjectType))
+	}
+
+	if metadata.SectorIdentifierUri == "" {
+		if subjectType == config.SubjectTypePairwise && !singleHost(metadata.RedirectUris) {
+			return invalidClientMetadata("redirect_uris with different hosts require a sector_identifier_uri")
+		}
+		return nil
+	}
+
+	sectorIdentifierUri, parseError := url.Parse(metadata.SectorIdentifierUri)
+	if parseError != nil || sectorIdentifierUri.Scheme != "https" || sectorIdentifierUri.Host == "" {
+		return invalidClientMetadata("sector_identifier_uri must be a https uri")
+	}
+
+	redirectUris, fetchError := h.fetchSectorIdentifier(metadata.SectorIdentifierUri)
+	if fetchError != nil {
+		log.Debug("Could not fetch sector_identifier_uri", "sector_identifier_uri", metadata.SectorIdentifierUri, log.Err(fetchError))
+		return invalidClientMetadata("sector_identifier_uri could not be retrieved")
+	}
+
+	for _, redirectUri := range metadata.RedirectUris {
+		if !slices.Contains(redirectUris, redirectUri) {
+			return &oauth2.RegistrationErrorResponseParameter{Error: oauth2.RegistrationEtInvalidRedirectUri, Description: fmt.Sprintf("redirect uri %s is not included in sector_identifier_uri", redirectUri)}
+		}
+	}
+
+	return nil
+}
+
+// newFetchClient creates the client for requests to a sector_identifier_uri, which does not follow redirects,
+// because the response must be provided by the https uri itself and not by an arbitrary redirect target.
+func newFetchClient() *http.Client {
+	return &http.Client{
+		Timeout: fetchTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func (h *Handler) fetchSectorIdentifier(sectorIdentifierUri string) ([]string, error) {
+	request, requestError := http.NewRequest(http.MethodGet, sectorIdentifierUri, nil)
+	if requestError != nil {
+		return nil, requestError
+	}
+	if request.URL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %s", request.URL.Scheme)
+	}
+	request.Header.Set(internalHttp.Accept, internalHttp.ContentTypeJSON)
+
+	response, responseError := h.httpClient.Do(request)
+	if responseError != nil {
+		return nil, responseError
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+
+	var redirectUris []string
+	decodeError := json.NewDecoder(io.LimitReader(response.Body, fetchLimit)).Decode(&redirectUris)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	return redirectUris, nil
+}
+
+func singleHost(uris []string) bool {
+	hosts := make(map[string]bool)
+	for _, uri := range uris {
+		parsedUri, parseError := url.Parse(uri)
+		if parseError != nil {
+			return false
+		}
+		hosts[parsedUri.Hostname()] = true
+	}
+	return len(hosts) <= 1
+}
@@ -0,0 +1,67 @@
+package registration
+
+import (
+	"fmt"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/endpoint"
+	"github.com/webishdev/stopnik/internal/manager/token"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_RegistrationPairwise(t *testing.T) {
+	testConfig := createTestConfig(t)
+	testConfig.Server.PairwiseSalt = "salt"
+
+	sectorServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/redirects.json", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte(`["https://example.com/callback","https://example.org/callback"]`))
+	}))
+	defer sectorServer.Close()
+
+	registrationHandler := NewRegistrationHandler(token.GetTokenManagerInstance())
+	registrationHandler.httpClient.Transport = sectorServer.Client().Transport
+
+	type parameter struct {
+		name           string
+		body           string
+		expectedStatus int
+	}
+
+	var parameters = []parameter{
+		{"public", `{"redirect_uris":["https://example.com/callback"],"subject_type":"public"}`, http.StatusCreated},
+		{"unsupported", `{"redirect_uris":["https://example.com/callback"],"subject_type":"foo"}`, http.StatusBadRequest},
+		{"pairwise", `{"redirect_uris":["https://example.com/callback","https://example.com/other"],"subject_type":"pairwise"}`, http.StatusCreated},
+		{"pairwise with different hosts", `{"redirect_uris":["https://example.com/callback","https://example.org/callback"],"subject_type":"pairwise"}`, http.StatusBadRequest},
+		{"pairwise with sector identifier", fmt.Sprintf(`{"redirect_uris":["https://example.com/callback","https://example.org/callback"],"subject_type":"pairwise","sector_identifier_uri":"%s/redirects.json"}`, sectorServer.URL), http.StatusCreated},
+		{"pairwise with missing redirect in sector identifier", fmt.Sprintf(`{"redirect_uris":["https://example.net/callback"],"subject_type":"pairwise","sector_identifier_uri":"%s/redirects.json"}`, sectorServer.URL), http.StatusBadRequest},
+		{"pairwise with redirected sector identifier", fmt.Sprintf(`{"redirect_uris":["https://example.com/callback"],"subject_type":"pairwise","sector_identifier_uri":"%s/redirect"}`, sectorServer.URL), http.StatusBadRequest},
+		{"pairwise with http sector identifier", `{"redirect_uris":["https://example.com/callback"],"subject_type":"pairwise","sector_identifier_uri":"http://example.com/redirects.json"}`, http.StatusBadRequest},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Registration subject type %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			request := createRequest(http.MethodPost, endpoint.Registration, "initial", test.body)
+
+			registrationHandler.ServeHTTP(rr, request)
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+
+			if rr.Code == http.StatusCreated {
+				registered := readClientInformation(t, rr)
+				client, clientExists := testConfig.GetClient(registered.ClientId)
+				if !clientExists || (client.GetSubjectType() == config.SubjectTypePairwise) != (registered.SubjectType == config.SubjectTypePairwise) {
+					t.Errorf("registered client has wrong subject type %v", client)
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package revoke
 
 import (
+	"github.com/webishdev/stopnik/internal/audit"
 	"github.com/webishdev/stopnik/internal/config"
 	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/metrics"
 	"github.com/webishdev/stopnik/internal/oauth2"
 	"github.com/webishdev/stopnik/internal/server/handler/error"
 	"github.com/webishdev/stopnik/internal/server/validation"
@@ -43,6 +45,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				oauth2.TokenErrorStatusResponseHandler(w, r, http.StatusUnauthorized, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidRequest})
 				return
 			}
+			client = validAccessToken.Client
 
 			scopes := validAccessToken.Scopes
 
@@ -65,15 +68,35 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		tokenTypeHint, tokenTypeHintExists := oauth2.IntrospectTokenTypeFromString(tokenTypeHintParameter)
 
+		revokedTokenType := "unknown"
+		revoked := false
 		if !tokenTypeHintExists {
-			accessTokenRevoked := h.revokeAccessToken(tokenParameter)
-			if !accessTokenRevoked {
-				h.revokeRefreshToken(tokenParameter)
+			revoked = h.revokeAccessToken(r, tokenParameter)
+			if revoked {
+				revokedTokenType = string(oauth2.ItAccessToken)
+			} else {
+				revoked = h.revokeRefreshToken(r, tokenParameter)
+				if revoked {
+					revokedTokenType = string(oauth2.ItRefreshToken)
+				}
 			}
 		} else if tokenTypeHint == oauth2.ItAccessToken {
-			h.revokeAccessToken(tokenParameter)
+			revokedTokenType = string(oauth2.ItAccessToken)
+			revoked = h.revokeAccessToken(r, tokenParameter)
 		} else if tokenTypeHint == oauth2.ItRefreshToken {
-			h.revokeRefreshToken(tokenParameter)
+			revokedTokenType = string(oauth2.ItRefreshToken)
+			revoked = h.revokeRefreshToken(r, tokenParameter)
+		}
+
+		// successful revocations are recorded by the token manager
+		if !revoked {
+			metrics.Revocations.Inc(revokedTokenType, metrics.ResultFailure)
+			audit.Record(r, &audit.Event{
+				Type:     audit.EtTokenRevoked,
+				Outcome:  audit.OcFailure,
+				ClientId: client.Id,
+				Details:  map[string]string{"token_type": revokedTokenType},
+			})
 		}
 
 		w.WriteHeader(http.StatusOK)
@@ -84,21 +107,23 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *Handler) revokeRefreshToken(token string) bool {
-	refreshToken, tokenExists := h.tokenManager.GetRefreshToken(token)
+func (h *Handler) revokeRefreshToken(r *http.Request, token string) bool {
+	refreshToken, tokenExists := h.tokenManager.GetRefreshToken(r.Context(), token)
 
 	if tokenExists {
-		h.tokenManager.RevokeRefreshToken(refreshToken)
+		h.tokenManager.RevokeRefreshToken(r, refreshToken)
+		metrics.Revocations.Inc(string(oauth2.ItRefreshToken), metrics.ResultSuccess)
 	}
 
 	return tokenExists
 }
 
-func (h *Handler) revokeAccessToken(token string) bool {
-	accessToken, tokenExists := h.tokenManager.GetAccessToken(token)
+func (h *Handler) revokeAccessToken(r *http.Request, token string) bool {
+	accessToken, tokenExists := h.tokenManager.GetAccessToken(r.Context(), token)
 
 	if tokenExists {
-		h.tokenManager.RevokeAccessToken(accessToken)
+		h.tokenManager.RevokeAccessToken(r, accessToken)
+		metrics.Revocations.Inc(string(oauth2.ItAccessToken), metrics.ResultSuccess)
 	}
 
 	return tokenExists
@@ -1,9 +1,13 @@
 package revoke
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/webishdev/stopnik/internal/audit"
 	"github.com/webishdev/stopnik/internal/config"
 	"github.com/webishdev/stopnik/internal/endpoint"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
@@ -200,9 +204,9 @@ func testRevoke(t *testing.T, testConfig *config.Config) {
 			requestValidator := validation.NewRequestValidator()
 			sessionManager := session.GetAuthSessionManagerInstance()
 			tokenManager := token.GetTokenManagerInstance()
-			sessionManager.StartSession(authSession)
+			sessionManager.StartSession(context.Background(), authSession)
 			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
-			accessTokenResponse := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "")
+			accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "", nil)
 
 			revokeHandler := NewRevokeHandler(requestValidator, tokenManager)
 
@@ -223,19 +227,32 @@ func testRevoke(t *testing.T, testConfig *config.Config) {
 			request.Header.Add(internalHttp.Authorization, fmt.Sprintf("Basic %s", testTokenCreateBasicAuth("foo", "bar")))
 			request.Header.Add(internalHttp.ContentType, "application/x-www-form-urlencoded")
 
+			var auditBuffer bytes.Buffer
+			audit.Configure(audit.NewWriterSink(&auditBuffer))
+			defer audit.Shutdown()
+
 			revokeHandler.ServeHTTP(rr, request)
 
 			if rr.Code != http.StatusOK {
 				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
 			}
 
+			auditEvent := audit.Event{}
+			auditError := json.Unmarshal(auditBuffer.Bytes(), &auditEvent)
+			if auditError != nil {
+				t.Fatalf("expected audit event, got %s", auditBuffer.String())
+			}
+			if auditEvent.Type != audit.EtTokenRevoked || auditEvent.Outcome != audit.OcSuccess || auditEvent.ClientId != "foo" || auditEvent.Details["token_type"] != string(test.tokenHint) {
+				t.Errorf("audit event did not match, %v", auditEvent)
+			}
+
 			if test.tokenHint == oauth2.ItAccessToken {
-				_, accessTokenExists := tokenManager.GetAccessToken(accessTokenValue)
+				_, accessTokenExists := tokenManager.GetAccessToken(context.Background(), accessTokenValue)
 				if accessTokenExists {
 					t.Errorf("access token should have been revoked")
 				}
 			} else if test.tokenHint == oauth2.ItRefreshToken {
-				_, refreshTokenExists := tokenManager.GetRefreshToken(accessTokenValue)
+				_, refreshTokenExists := tokenManager.GetRefreshToken(context.Background(), accessTokenValue)
 				if refreshTokenExists {
 					t.Errorf("refresh token should have been revoked")
 				}
@@ -278,9 +295,9 @@ func testRevokeWithoutHint(t *testing.T, testConfig *config.Config) {
 			requestValidator := validation.NewRequestValidator()
 			sessionManager := session.GetAuthSessionManagerInstance()
 			tokenManager := token.GetTokenManagerInstance()
-			sessionManager.StartSession(authSession)
+			sessionManager.StartSession(context.Background(), authSession)
 			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
-			accessTokenResponse := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "")
+			accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "", nil)
 
 			revokeHandler := NewRevokeHandler(requestValidator, tokenManager)
 
@@ -307,12 +324,12 @@ func testRevokeWithoutHint(t *testing.T, testConfig *config.Config) {
 			}
 
 			if test.tokenHint == oauth2.ItAccessToken {
-				_, accessTokenExists := tokenManager.GetAccessToken(accessTokenValue)
+				_, accessTokenExists := tokenManager.GetAccessToken(context.Background(), accessTokenValue)
 				if accessTokenExists {
 					t.Errorf("access token should have been revoked")
 				}
 			} else if test.tokenHint == oauth2.ItRefreshToken {
-				_, refreshTokenExists := tokenManager.GetRefreshToken(accessTokenValue)
+				_, refreshTokenExists := tokenManager.GetRefreshToken(context.Background(), accessTokenValue)
 				if refreshTokenExists {
 					t.Errorf("refresh token should have been revoked")
 				}
@@ -355,9 +372,9 @@ func testRevokeDisabled(t *testing.T, testConfig *config.Config) {
 			requestValidator := validation.NewRequestValidator()
 			sessionManager := session.GetAuthSessionManagerInstance()
 			tokenManager := token.GetTokenManagerInstance()
-			sessionManager.StartSession(authSession)
+			sessionManager.StartSession(context.Background(), authSession)
 			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
-			accessTokenResponse := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "")
+			accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "", nil)
 
 			revokeHandler := NewRevokeHandler(requestValidator, tokenManager)
 
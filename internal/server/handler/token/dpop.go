@@ -0,0 +1,54 @@
+package token
+
+import (
+	"errors"
+	"github.com/webishdev/stopnik/internal/crypto"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/manager/dpop"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"net/http"
+)
+
+// validateDPoPProof validates the optional DPoP proof of a token request and returns the JWK thumbprint of its key.
+// When nonces are required, each response provides a new nonce for the next proof.
+// See https://datatracker.ietf.org/doc/html/rfc9449#section-5 and https://datatracker.ietf.org/doc/html/rfc9449#section-8
+func (h *Handler) validateDPoPProof(w http.ResponseWriter, r *http.Request) (string, *oauth2.TokenErrorResponseParameter) {
+	if h.config.Server.DPoP.RequireNonce {
+		w.Header().Set(internalHttp.DPoPNonce, h.dpopManager.NewNonce())
+	}
+	if !dpop.HasProof(r) {
+		return "", nil
+	}
+	jwkThumbprint, proofError := h.dpopManager.ValidateTokenRequestProof(r)
+	if errors.Is(proofError, dpop.ErrUseNonce) {
+		return "", &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtUseDPoPNonce, Description: "DPoP proof requires a nonce"}
+	} else if proofError != nil {
+		return "", &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidDPoPProof}
+	}
+	return jwkThumbprint, nil
+}
+
+// validRefreshTokenBinding checks that a refresh token bound to a DPoP key is only used with a proof of that key.
+func validRefreshTokenBinding(refreshToken *oauth2.RefreshToken, jwkThumbprint string) bool {
+	if refreshToken.Confirmation == nil || refreshToken.Confirmation.JwkThumbprint == "" {
+		return true
+	}
+	return refreshToken.Confirmation.JwkThumbprint == jwkThumbprint
+}
+
+// validAccessTokenBinding checks that an access token presented at the token endpoint, e.g. as subject or actor token,
+// is only used with a proof of its DPoP key and over a connection with its client certificate when it is bound to them.
+// See https://datatracker.ietf.org/doc/html/rfc9449#section-6 and https://datatracker.ietf.org/doc/html/rfc8705#section-3
+func validAccessTokenBinding(r *http.Request, accessToken *oauth2.AccessToken, jwkThumbprint string) bool {
+	if accessToken.Confirmation == nil {
+		return true
+	}
+	if accessToken.Confirmation.JwkThumbprint != "" && accessToken.Confirmation.JwkThumbprint != jwkThumbprint {
+		return false
+	}
+	if accessToken.Confirmation.CertificateThumbprint != "" {
+		certificate, certificateExists := internalHttp.GetClientCertificate(r)
+		return certificateExists && crypto.CertificateThumbprint(certificate) == accessToken.Confirmation.CertificateThumbprint
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package token
+
+import (
+	"cmp"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// subjectToken contains the values of a validated subject token which are carried over to the exchanged token.
+type subjectToken struct {
+	username  string
+	clientId  string
+	scopes    []string
+	actor     *oauth2.Actor
+	expiresAt time.Time
+}
+
+// validateTokenExchange validates a token exchange request for the given client,
+// only tokens issued by STOPnik are accepted as subject and actor token.
+// Sender-constrained tokens require the DPoP proof or client certificate they are bound to.
+// Implements https://datatracker.ietf.org/doc/html/rfc8693#section-2.1
+func (h *Handler) validateTokenExchange(r *http.Request, client *config.Client, jwkThumbprint string) (*token.ExchangeInput, *oauth2.TokenErrorResponseParameter) {
+	if len(client.ExchangeAudiences) == 0 {
+		return nil, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtUnauthorizedClient}
+	}
+
+	requestedTokenType := r.PostFormValue(oauth2.ParameterRequestedTokenType)
+	if requestedTokenType != "" && requestedTokenType != string(oauth2.TtiAccessToken) {
+		return nil, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidRequest, Description: "unsupported requested_token_type"}
+	}
+
+	subject, subjectExists := h.getSubjectToken(r, client, jwkThumbprint, r.PostFormValue(oauth2.ParameterSubjectToken), r.PostFormValue(oauth2.ParameterSubjectTokenType))
+	if !subjectExists {
+		return nil, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidRequest, Description: "invalid subject_token"}
+	}
+
+	// https://datatracker.ietf.org/doc/html/rfc8693#section-4.1
+	actor := subject.actor
+	actorTokenValue := r.PostFormValue(oauth2.ParameterActorToken)
+	actorTokenType := r.PostFormValue(oauth2.ParameterActorTokenType)
+	if actorTokenValue != "" || actorTokenType != "" {
+		actorToken, actorExists := h.getSubjectToken(r, client, jwkThumbprint, actorTokenValue, actorTokenType)
+		if !actorExists {
+			return nil, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidRequest, Description: "invalid actor_token"}
+		}
+		actor = &oauth2.Actor{
+			Subject:  cmp.Or(actorToken.username, actorToken.clientId),
+			ClientId: actorToken.clientId,
+			Actor:    subject.actor,
+		}
+	}
+
+	audience, validAudience := getExchangeAudience(r, client)
+	if !validAudience {
+		return nil, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidTarget}
+	}
+
+	scopes := subject.scopes
+	scopeForm := r.PostFormValue(oauth2.ParameterScope)
+	if scopeForm != "" {
+		scopes = strings.Split(scopeForm, " ")
+		for _, scope := range scopes {
+			if !slices.Contains(subject.scopes, scope) {
+				return nil, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidScope}
+			}
+		}
+	}
+
+	exchangeInput := &token.ExchangeInput{
+		Username:  subject.username,
+		Scopes:    scopes,
+		Audience:  audience,
+		Actor:     actor,
+		ExpiresAt: subject.expiresAt,
+	}
+
+	return exchangeInput, nil
+}
+
+// getSubjectToken resolves a subject or actor token, access tokens are accepted from the client they were issued to
+// or from a client in their audience, while refresh tokens are only accepted from the client they were issued to.
+// Tokens bound to a DPoP key or client certificate must be presented with a proof of that key or over a connection with that certificate.
+func (h *Handler) getSubjectToken(r *http.Request, client *config.Client, jwkThumbprint string, value string, tokenTypeValue string) (*subjectToken, bool) {
+	tokenType, tokenTypeExists := oauth2.TokenTypeIdentifierFromString(tokenTypeValue)
+	if value == "" || !tokenTypeExists {
+		return nil, false
+	}
+
+	if tokenType == oauth2.TtiAccessToken {
+		accessToken, accessTokenExists := h.tokenManager.GetAccessToken(r.Context(), value)
+		if !accessTokenExists || !validAccessTokenBinding(r, accessToken, jwkThumbprint) {
+			return nil, false
+		}
+		if accessToken.ClientId != client.Id && !slices.Contains(accessToken.Audience, client.Id) {
+			return nil, false
+		}
+		return &subjectToken{
+			username:  accessToken.Username,
+			clientId:  accessToken.ClientId,
+			scopes:    accessToken.Scopes,
+			actor:     accessToken.Actor,
+			expiresAt: accessToken.ExpiresAt,
+		}, true
+	} else if tokenType == oauth2.TtiRefreshToken {
+		refreshToken, refreshTokenExists := h.tokenManager.GetRefreshToken(r.Context(), value)
+		if !refreshTokenExists || refreshToken.ClientId != client.Id || !validRefreshTokenBinding(refreshToken, jwkThumbprint) {
+			return nil, false
+		}
+		return &subjectToken{
+			username:  refreshToken.Username,
+			clientId:  refreshToken.ClientId,
+			scopes:    refreshToken.Scopes,
+			expiresAt: refreshToken.ExpiresAt,
+		}, true
+	}
+
+	return nil, false
+}
+
+// getExchangeAudience collects the requested audience and resource values,
+// each of them must be allowed by the client policy.
+// When no target is requested the audience of the client will be used, which must be allowed as well.
+func getExchangeAudience(r *http.Request, client *config.Client) ([]string, bool) {
+	var audience []string
+	audience = append(audience, r.PostForm[oauth2.ParameterAudience]...)
+	for _, resource := range r.PostForm[oauth2.ParameterResource] {
+		// https://datatracker.ietf.org/doc/html/rfc8693#section-2.1
+		resourceUri, parseError := url.Parse(resource)
+		if parseError != nil || !resourceUri.IsAbs() || resourceUri.Fragment != "" {
+			return nil, false
+		}
+		audience = append(audience, resource)
+	}
+
+	if len(audience) == 0 {
+		audience = client.GetAudience()
+	}
+
+	for _, value := range audience {
+		if !client.ValidateExchangeAudience(value) {
+			return nil, false
+		}
+	}
+
+	return audience, true
+}
@@ -0,0 +1,66 @@
+package token
+
+import (
+	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// getGrantInput validates the resource and authorization_details parameters of a token request.
+// Each resource must be configured and, when resources were granted before, be one of the granted resources,
+// see https://datatracker.ietf.org/doc/html/rfc8707#section-2.2
+// Requested authorization details must be valid and, when granted before, equal granted authorization details,
+// see https://datatracker.ietf.org/doc/html/rfc9396#section-6
+// Grants without previously granted values are granted the requested values.
+func (h *Handler) getGrantInput(r *http.Request, grantedResources []string, grantedAuthorizationDetails []oauth2.AuthorizationDetail, scopes []string) (*token.GrantInput, *oauth2.TokenErrorResponseParameter) {
+	requestedResources := r.PostForm[oauth2.ParameterResource]
+	for _, resourceId := range requestedResources {
+		_, resourceExists := h.config.GetResource(resourceId)
+		if !resourceExists || (len(grantedResources) > 0 && !slices.Contains(grantedResources, resourceId)) {
+			return nil, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidTarget, Description: "invalid resource " + resourceId}
+		}
+	}
+
+	var requestedAuthorizationDetails []oauth2.AuthorizationDetail
+	authorizationDetailsValue := r.PostFormValue(oauth2.ParameterAuthorizationDetails)
+	if authorizationDetailsValue != "" {
+		var validAuthorizationDetails bool
+		requestedAuthorizationDetails, validAuthorizationDetails = h.validator.ValidateAuthorizationDetails(authorizationDetailsValue)
+		if !validAuthorizationDetails || (len(grantedAuthorizationDetails) > 0 && !oauth2.ContainsAuthorizationDetails(grantedAuthorizationDetails, requestedAuthorizationDetails)) {
+			return nil, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidAuthorizationDetails}
+		}
+	}
+
+	if len(grantedResources) == 0 {
+		grantedResources = requestedResources
+	}
+
+	if len(grantedAuthorizationDetails) == 0 {
+		grantedAuthorizationDetails = requestedAuthorizationDetails
+	}
+
+	return &token.GrantInput{
+		Resources:                     grantedResources,
+		RequestedResources:            requestedResources,
+		Scopes:                        scopes,
+		AuthorizationDetails:          grantedAuthorizationDetails,
+		RequestedAuthorizationDetails: requestedAuthorizationDetails,
+	}, nil
+}
+
+// getRefreshScopes returns the scopes requested for a refresh, which must not exceed the originally granted scopes.
+// See https://datatracker.ietf.org/doc/html/rfc6749#section-6
+func getRefreshScopes(scopeValue string, granted []string) ([]string, bool) {
+	if scopeValue == "" {
+		return nil, true
+	}
+	scopes := strings.Split(scopeValue, " ")
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return nil, false
+		}
+	}
+	return scopes, true
+}
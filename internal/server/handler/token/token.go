@@ -1,9 +1,15 @@
 package token
 
 import (
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
+	"github.com/webishdev/stopnik/internal/endpoint"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/manager/assertion"
+	"github.com/webishdev/stopnik/internal/manager/dpop"
 	"github.com/webishdev/stopnik/internal/manager/session"
 	"github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/metrics"
 	"github.com/webishdev/stopnik/internal/oauth2"
 	"github.com/webishdev/stopnik/internal/oidc"
 	"github.com/webishdev/stopnik/internal/pkce"
@@ -16,17 +22,23 @@ import (
 )
 
 type Handler struct {
+	config             *config.Config
 	validator          *validation.RequestValidator
 	authSessionManager session.Manager[session.AuthSession]
 	tokenManager       *token.Manager
+	assertionManager   *assertion.Manager
+	dpopManager        *dpop.Manager
 	errorHandler       *error.Handler
 }
 
 func NewTokenHandler(validator *validation.RequestValidator, authSessionManager session.Manager[session.AuthSession], tokenManager *token.Manager) *Handler {
 	return &Handler{
+		config:             config.GetConfigInstance(),
 		validator:          validator,
 		authSessionManager: authSessionManager,
 		tokenManager:       tokenManager,
+		assertionManager:   assertion.GetAssertionManagerInstance(),
+		dpopManager:        dpop.GetDPoPManagerInstance(),
 		errorHandler:       error.NewErrorHandler(),
 	}
 }
@@ -60,19 +72,39 @@ func (h *Handler) handlePostRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !client.ValidateGrantType(grantType) {
+		oauth2.TokenErrorResponseHandler(w, r, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtUnauthorizedClient})
+		return
+	}
+
+	jwkThumbprint, dpopErrorParameter := h.validateDPoPProof(w, r)
+	if dpopErrorParameter != nil {
+		oauth2.TokenErrorResponseHandler(w, r, dpopErrorParameter)
+		return
+	}
+
 	var scopes []string
 	var username string
 	var requestedClaims *oidc.ClaimsParameter
+	var exchangeInput *token.ExchangeInput
+	var grant *assertion.Grant
+	var grantedResources []string
+	var grantedAuthorizationDetails []oauth2.AuthorizationDetail
+	var refreshScopes []string
 	nonce := ""
 	authCode := ""
 	var authTime time.Time
+	var acr string
+	var amr []string
+	var usedRefreshToken *oauth2.RefreshToken
+	var offlineAccess bool
 
 	if grantType == oauth2.GtAuthorizationCode {
 		// https://datatracker.ietf.org/doc/html/rfc6749#section-4.1.3
 		code := r.PostFormValue(oauth2.ParameterCode)
-		authSession, authSessionExists := h.authSessionManager.GetSession(code)
+		authSession, authSessionExists := h.authSessionManager.GetSession(r.Context(), code)
 		if !authSessionExists {
-			h.tokenManager.RevokeAccessTokenByAuthorizationCode(code)
+			h.tokenManager.RevokeAccessTokenByAuthorizationCode(r, code)
 			oauth2.TokenErrorResponseHandler(w, r, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidGrant})
 			return
 		}
@@ -96,8 +128,13 @@ func (h *Handler) handlePostRequest(w http.ResponseWriter, r *http.Request) {
 		nonce = authSession.Nonce
 		requestedClaims = authSession.RequestedClaims
 		authTime = authSession.AuthTime
+		acr = authSession.Acr
+		amr = authSession.Amr
+		offlineAccess = authSession.OfflineConsent
+		grantedResources = authSession.Resources
+		grantedAuthorizationDetails = authSession.AuthorizationDetails
 		authCode = code
-		h.authSessionManager.DeleteSession(authSession.Id)
+		h.authSessionManager.DeleteSession(r.Context(), authSession.Id)
 	} else if grantType == oauth2.GtPassword {
 		// https://datatracker.ietf.org/doc/html/rfc6749#section-4.3.2
 		usernameFrom := r.PostFormValue(oauth2.ParameterUsername)
@@ -122,7 +159,7 @@ func (h *Handler) handlePostRequest(w http.ResponseWriter, r *http.Request) {
 	} else if grantType == oauth2.GtRefreshToken && client.GetRefreshTTL() > 0 {
 		// https://datatracker.ietf.org/doc/html/rfc6749#section-6
 		refreshTokenForm := r.PostFormValue(oauth2.ParameterRefreshToken)
-		refreshToken, refreshTokenExists := h.tokenManager.GetRefreshToken(refreshTokenForm)
+		refreshToken, refreshTokenExists := h.tokenManager.GetRefreshToken(r.Context(), refreshTokenForm)
 		if !refreshTokenExists {
 			oauth2.TokenErrorResponseHandler(w, r, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidRequest})
 			return
@@ -133,15 +170,91 @@ func (h *Handler) handlePostRequest(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if !validRefreshTokenBinding(refreshToken, jwkThumbprint) {
+			oauth2.TokenErrorResponseHandler(w, r, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidDPoPProof})
+			return
+		}
+
+		var validScopes bool
+		refreshScopes, validScopes = getRefreshScopes(r.PostFormValue(oauth2.ParameterScope), refreshToken.Scopes)
+		if !validScopes {
+			oauth2.TokenErrorResponseHandler(w, r, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidScope})
+			return
+		}
+
 		username = refreshToken.Username
 		scopes = refreshToken.Scopes
 		authTime = refreshToken.AuthTime
+		acr = refreshToken.Acr
+		amr = refreshToken.Amr
+		grantedResources = refreshToken.Resources
+		grantedAuthorizationDetails = refreshToken.AuthorizationDetails
+		usedRefreshToken = refreshToken
+		metrics.Refreshes.Inc(metrics.ClientLabel(client.Id, client.IsRegistered()))
+	} else if grantType == oauth2.GtTokenExchange {
+		// https://datatracker.ietf.org/doc/html/rfc8693#section-2.1
+		var errorParameter *oauth2.TokenErrorResponseParameter
+		exchangeInput, errorParameter = h.validateTokenExchange(r, client, jwkThumbprint)
+		if errorParameter != nil {
+			oauth2.TokenErrorResponseHandler(w, r, errorParameter)
+			return
+		}
+
+		username = exchangeInput.Username
+		scopes = exchangeInput.Scopes
+	} else if grantType == oauth2.GtJwtBearer {
+		// https://datatracker.ietf.org/doc/html/rfc7523#section-2.1
+		assertionForm := r.PostFormValue(oauth2.ParameterAssertion)
+		scopeForm := r.PostFormValue(oauth2.ParameterScope)
+
+		var validAssertion bool
+		grant, validAssertion = h.validateAssertion(r, client, assertionForm)
+		if !validAssertion {
+			oauth2.TokenErrorResponseHandler(w, r, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtInvalidGrant})
+			return
+		}
+		scopes = strings.Split(scopeForm, " ")
+		username = grant.Subject
 	} else {
 		oauth2.TokenErrorResponseHandler(w, r, &oauth2.TokenErrorResponseParameter{Error: oauth2.TokenEtUnsupportedGrandType})
 		return
 	}
 
-	accessTokenResponse := h.tokenManager.CreateAccessTokenResponse(r, username, client, &authTime, scopes, requestedClaims, nonce, authCode)
+	log.AddRequestAttributes(r, log.GrantType(string(grantType)), log.Username(username))
+	var accessTokenResponse oauth2.AccessTokenResponse
+	// https://datatracker.ietf.org/doc/html/rfc8705#section-3
+	var certificateThumbprint string
+	if certificate, certificateExists := internalHttp.GetClientCertificate(r); certificateExists && client.IsCertificateBound() {
+		certificateThumbprint = crypto.CertificateThumbprint(certificate)
+	}
+	if exchangeInput != nil {
+		exchangeInput.JwkThumbprint = jwkThumbprint
+		exchangeInput.CertificateThumbprint = certificateThumbprint
+		accessTokenResponse = h.tokenManager.CreateExchangedAccessTokenResponse(r, client, *exchangeInput)
+	} else {
+		grantInput, errorParameter := h.getGrantInput(r, grantedResources, grantedAuthorizationDetails, refreshScopes)
+		if errorParameter != nil {
+			oauth2.TokenErrorResponseHandler(w, r, errorParameter)
+			return
+		}
+		grantInput.JwkThumbprint = jwkThumbprint
+		grantInput.CertificateThumbprint = certificateThumbprint
+		grantInput.Acr = acr
+		grantInput.Amr = amr
+		grantInput.RefreshToken = usedRefreshToken
+		grantInput.OfflineAccess = offlineAccess
+		grantInput.GrantType = grantType
+		if grant != nil {
+			grantInput.AssertionIssuer = grant.Issuer
+		}
+		createdResponse, tokenError := h.tokenManager.CreateAccessTokenResponse(r, username, client, &authTime, scopes, requestedClaims, nonce, authCode, grantInput)
+		if tokenError != nil {
+			h.errorHandler.InternalServerErrorHandler(w, r, tokenError)
+			return
+		}
+		accessTokenResponse = createdResponse
+	}
+	metrics.TokensIssued.Inc(string(grantType), metrics.ClientLabel(client.Id, client.IsRegistered()))
 
 	jsonError := internalHttp.SendJson(accessTokenResponse, w, r)
 	if jsonError != nil {
@@ -149,3 +262,15 @@ func (h *Handler) handlePostRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// validateAssertion validates a JWT authorization grant,
+// the assertion audience must either be the issuer or the token endpoint of STOPnik.
+func (h *Handler) validateAssertion(r *http.Request, client *config.Client, assertionValue string) (*assertion.Grant, bool) {
+	requestData := internalHttp.NewRequestData(r)
+	audience := []string{h.config.GetIssuer(requestData)}
+	urlFromRequest, parseError := requestData.URL()
+	if parseError == nil {
+		audience = append(audience, urlFromRequest.JoinPath(endpoint.Token).String())
+	}
+	return h.assertionManager.ValidateAssertion(r.Context(), assertionValue, audience, client)
+}
@@ -1,11 +1,16 @@
 package token
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
 	"github.com/webishdev/stopnik/internal/endpoint"
 	internalHttp "github.com/webishdev/stopnik/internal/http"
 	"github.com/webishdev/stopnik/internal/manager/session"
@@ -16,13 +21,21 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func Test_Token(t *testing.T) {
 
 	testConfig := &config.Config{
+		Server: config.Server{
+			TrustedIssuers: []config.TrustedIssuer{
+				{Issuer: "https://ci.example.com", PublicKey: "../../../../.test_files/rsa256pub.pem", ServiceAccount: true},
+			},
+		},
 		Clients: []config.Client{
 			{
 				Id:           "foo",
@@ -35,6 +48,20 @@ func Test_Token(t *testing.T) {
 				Redirects:  []string{"https://example.com/callback"},
 				RefreshTTL: 100,
 			},
+			{
+				Id:                "gateway",
+				ClientSecret:      "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
+				ExchangeAudiences: []string{"https://api.example.com", "billing"},
+				AssertionIssuers:  []string{"https://ci.example.com"},
+				GrantTypes:        []oauth2.GrantType{oauth2.GtTokenExchange, oauth2.GtJwtBearer},
+				RefreshTTL:        100,
+			},
+			{
+				Id:         "frontend",
+				Redirects:  []string{"https://example.com/callback"},
+				Audience:   []string{"gateway"},
+				RefreshTTL: 100,
+			},
 		},
 		Users: []config.User{
 			{
@@ -42,6 +69,14 @@ func Test_Token(t *testing.T) {
 				Password: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
 			},
 		},
+		Resources: []config.Resource{
+			{Id: "https://orders.example.com", Scopes: []string{"foo:bar"}},
+			{Id: "https://billing.example.com"},
+		},
+		AuthorizationDetailTypes: []config.AuthorizationDetailType{
+			{Type: "payment_initiation"},
+			{Type: "account_information"},
+		},
 	}
 
 	initializationError := config.Initialize(testConfig)
@@ -50,6 +85,18 @@ func Test_Token(t *testing.T) {
 	}
 
 	testTokenRefreshTokenGrantType(t, testConfig)
+
+	testTokenResourceIndicators(t, testConfig)
+
+	testTokenAuthorizationDetails(t, testConfig)
+
+	testTokenDPoP(t, testConfig)
+
+	testTokenExchangeGrantType(t, testConfig)
+
+	testTokenJwtBearerGrantType(t)
+
+	testTokenGrantTypeNotAllowed(t)
 }
 
 func Test_TokenMissingClientCredentials(t *testing.T) {
@@ -178,7 +225,7 @@ func Test_TokenAuthorizationCodeGrantTypeInvalidPKCE(t *testing.T) {
 	requestValidator := validation.NewRequestValidator()
 	sessionManager := session.GetAuthSessionManagerInstance()
 	tokenManager := token.GetTokenManagerInstance()
-	sessionManager.StartSession(authSession)
+	sessionManager.StartSession(context.Background(), authSession)
 
 	tokenHandler := NewTokenHandler(requestValidator, sessionManager, tokenManager)
 
@@ -249,7 +296,7 @@ func Test_TokenAuthorizationCodeGrantType(t *testing.T) {
 			requestValidator := validation.NewRequestValidator()
 			sessionManager := session.GetAuthSessionManagerInstance()
 			tokenManager := token.GetTokenManagerInstance()
-			sessionManager.StartSession(authSession)
+			sessionManager.StartSession(context.Background(), authSession)
 
 			tokenHandler := NewTokenHandler(requestValidator, sessionManager, tokenManager)
 
@@ -369,10 +416,10 @@ func testTokenRefreshTokenGrantType(t *testing.T, testConfig *config.Config) {
 		requestValidator := validation.NewRequestValidator()
 		sessionManager := session.GetAuthSessionManagerInstance()
 		tokenManager := token.GetTokenManagerInstance()
-		sessionManager.StartSession(authSession)
+		sessionManager.StartSession(context.Background(), authSession)
 
 		request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
-		accessTokenResponse := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "")
+		accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "", nil)
 
 		tokenHandler := NewTokenHandler(requestValidator, sessionManager, tokenManager)
 
@@ -400,6 +447,474 @@ func testTokenRefreshTokenGrantType(t *testing.T, testConfig *config.Config) {
 	})
 }
 
+func testTokenResourceIndicators(t *testing.T, testConfig *config.Config) {
+	client, _ := testConfig.GetClient("foo")
+	user, _ := testConfig.GetUser("foo")
+	scopes := []string{"foo:bar", "moo:abc"}
+	ordersResource := "https://orders.example.com"
+	billingResource := "https://billing.example.com"
+
+	type resourceParameter struct {
+		name             string
+		granted          []string
+		values           []any
+		expectedStatus   int
+		expectedAudience []string
+		expectedScope    string
+	}
+
+	var resourceParameters = []resourceParameter{
+		{"refresh for granted resource", []string{ordersResource, billingResource}, []any{oauth2.ParameterResource, billingResource}, http.StatusOK, []string{billingResource}, ""},
+		{"refresh for resource with allowed scopes", []string{ordersResource, billingResource}, []any{oauth2.ParameterResource, ordersResource}, http.StatusOK, []string{ordersResource}, "foo:bar"},
+		{"refresh with down-scoping", []string{billingResource}, []any{oauth2.ParameterScope, "moo:abc"}, http.StatusOK, []string{billingResource}, "moo:abc"},
+		{"refresh for resource not granted", []string{ordersResource}, []any{oauth2.ParameterResource, billingResource}, http.StatusBadRequest, nil, ""},
+		{"refresh for unknown resource", nil, []any{oauth2.ParameterResource, "https://unknown.example.com"}, http.StatusBadRequest, nil, ""},
+		{"refresh with additional scope", nil, []any{oauth2.ParameterScope, "abc:def"}, http.StatusBadRequest, nil, ""},
+	}
+
+	for _, test := range resourceParameters {
+		testMessage := fmt.Sprintf("Resource indicators %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			requestValidator := validation.NewRequestValidator()
+			sessionManager := session.GetAuthSessionManagerInstance()
+			tokenManager := token.GetTokenManagerInstance()
+
+			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+			accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, scopes, nil, "", "", &token.GrantInput{Resources: test.granted})
+
+			tokenHandler := NewTokenHandler(requestValidator, sessionManager, tokenManager)
+
+			rr := httptest.NewRecorder()
+
+			values := append([]any{
+				oauth2.ParameterGrantType, oauth2.GtRefreshToken,
+				oauth2.ParameterRefreshToken, accessTokenResponse.RefreshTokenValue,
+			}, test.values...)
+			body := strings.NewReader(testCreateBody(values...))
+
+			request = httptest.NewRequest(http.MethodPost, endpoint.Token, body)
+			request.Header.Add(internalHttp.Authorization, fmt.Sprintf("Basic %s", testTokenCreateBasicAuth("foo", "bar")))
+			request.Header.Add(internalHttp.ContentType, "application/x-www-form-urlencoded")
+
+			tokenHandler.ServeHTTP(rr, request)
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+
+			refreshedResponse := oauth2.AccessTokenResponse{}
+			jsonParseError := json.Unmarshal(rr.Body.Bytes(), &refreshedResponse)
+			if jsonParseError != nil {
+				t.Fatal(jsonParseError)
+			}
+
+			if refreshedResponse.Scope != test.expectedScope {
+				t.Errorf("expected scope %s, got %s", test.expectedScope, refreshedResponse.Scope)
+			}
+
+			parsedToken, parseError := jwt.ParseInsecure([]byte(refreshedResponse.AccessTokenValue))
+			if parseError != nil {
+				t.Fatal(parseError)
+			}
+
+			if !reflect.DeepEqual(parsedToken.Audience(), test.expectedAudience) {
+				t.Errorf("expected audience to be %v, got %v", test.expectedAudience, parsedToken.Audience())
+			}
+		})
+	}
+}
+
+func testTokenAuthorizationDetails(t *testing.T, testConfig *config.Config) {
+	client, _ := testConfig.GetClient("foo")
+	user, _ := testConfig.GetUser("foo")
+	payment := oauth2.AuthorizationDetail{"type": "payment_initiation", "currency": "EUR"}
+	account := oauth2.AuthorizationDetail{"type": "account_information"}
+
+	type authorizationDetailsParameter struct {
+		name           string
+		granted        []oauth2.AuthorizationDetail
+		requested      string
+		expectedStatus int
+		expectedType   string
+	}
+
+	var authorizationDetailsParameters = []authorizationDetailsParameter{
+		{"refresh without authorization details", []oauth2.AuthorizationDetail{payment}, "", http.StatusOK, "payment_initiation"},
+		{"refresh for granted authorization details", []oauth2.AuthorizationDetail{payment, account}, `[{"type":"account_information"}]`, http.StatusOK, "account_information"},
+		{"refresh for authorization details not granted", []oauth2.AuthorizationDetail{payment}, `[{"type":"account_information"}]`, http.StatusBadRequest, ""},
+		{"refresh for unknown authorization details type", nil, `[{"type":"unknown"}]`, http.StatusBadRequest, ""},
+		{"refresh for invalid authorization details", nil, `{"type":"payment_initiation"}`, http.StatusBadRequest, ""},
+	}
+
+	for _, test := range authorizationDetailsParameters {
+		testMessage := fmt.Sprintf("Authorization details %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			requestValidator := validation.NewRequestValidator()
+			sessionManager := session.GetAuthSessionManagerInstance()
+			tokenManager := token.GetTokenManagerInstance()
+
+			request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+			accessTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, []string{"foo:bar"}, nil, "", "", &token.GrantInput{AuthorizationDetails: test.granted})
+
+			tokenHandler := NewTokenHandler(requestValidator, sessionManager, tokenManager)
+
+			rr := httptest.NewRecorder()
+
+			values := []any{
+				oauth2.ParameterGrantType, oauth2.GtRefreshToken,
+				oauth2.ParameterRefreshToken, accessTokenResponse.RefreshTokenValue,
+			}
+			if test.requested != "" {
+				values = append(values, oauth2.ParameterAuthorizationDetails, url.QueryEscape(test.requested))
+			}
+			body := strings.NewReader(testCreateBody(values...))
+
+			request = httptest.NewRequest(http.MethodPost, endpoint.Token, body)
+			request.Header.Add(internalHttp.Authorization, fmt.Sprintf("Basic %s", testTokenCreateBasicAuth("foo", "bar")))
+			request.Header.Add(internalHttp.ContentType, "application/x-www-form-urlencoded")
+
+			tokenHandler.ServeHTTP(rr, request)
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+
+			refreshedResponse := oauth2.AccessTokenResponse{}
+			jsonParseError := json.Unmarshal(rr.Body.Bytes(), &refreshedResponse)
+			if jsonParseError != nil {
+				t.Fatal(jsonParseError)
+			}
+
+			if len(refreshedResponse.AuthorizationDetails) != 1 || refreshedResponse.AuthorizationDetails[0].GetType() != test.expectedType {
+				t.Errorf("expected authorization details of type %s, got %v", test.expectedType, refreshedResponse.AuthorizationDetails)
+			}
+
+			parsedToken, parseError := jwt.ParseInsecure([]byte(refreshedResponse.AccessTokenValue))
+			if parseError != nil {
+				t.Fatal(parseError)
+			}
+
+			if _, claimExists := parsedToken.Get(oauth2.ClaimAuthorizationDetails); !claimExists {
+				t.Errorf("expected access token claim %s", oauth2.ClaimAuthorizationDetails)
+			}
+		})
+	}
+}
+
+func testTokenDPoP(t *testing.T, testConfig *config.Config) {
+	client, _ := testConfig.GetClient("foo")
+	user, _ := testConfig.GetUser("foo")
+	tokenUri := "http://example.com" + endpoint.Token
+
+	tokenManager := token.GetTokenManagerInstance()
+	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+	boundResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, []string{"foo:bar"}, nil, "", "", &token.GrantInput{JwkThumbprint: "other"})
+
+	type dpopParameter struct {
+		name              string
+		values            []any
+		proof             string
+		expectedStatus    int
+		expectedError     oauth2.TokenErrorType
+		expectedTokenType oauth2.TokenType
+	}
+
+	var dpopParameters = []dpopParameter{
+		{"client credentials without proof", []any{oauth2.ParameterGrantType, oauth2.GtClientCredentials}, "", http.StatusOK, "", oauth2.TtBearer},
+		{"client credentials with proof", []any{oauth2.ParameterGrantType, oauth2.GtClientCredentials}, testCreateDPoPProof(t, http.MethodPost, tokenUri), http.StatusOK, "", oauth2.TtDPoP},
+		{"client credentials with proof for other method", []any{oauth2.ParameterGrantType, oauth2.GtClientCredentials}, testCreateDPoPProof(t, http.MethodGet, tokenUri), http.StatusBadRequest, oauth2.TokenEtInvalidDPoPProof, ""},
+		{"client credentials with invalid proof", []any{oauth2.ParameterGrantType, oauth2.GtClientCredentials}, "foo", http.StatusBadRequest, oauth2.TokenEtInvalidDPoPProof, ""},
+		{"refresh of bound token without proof", []any{oauth2.ParameterGrantType, oauth2.GtRefreshToken, oauth2.ParameterRefreshToken, boundResponse.RefreshTokenValue}, "", http.StatusBadRequest, oauth2.TokenEtInvalidDPoPProof, ""},
+		{"refresh of bound token with proof of other key", []any{oauth2.ParameterGrantType, oauth2.GtRefreshToken, oauth2.ParameterRefreshToken, boundResponse.RefreshTokenValue}, testCreateDPoPProof(t, http.MethodPost, tokenUri), http.StatusBadRequest, oauth2.TokenEtInvalidDPoPProof, ""},
+	}
+
+	for _, test := range dpopParameters {
+		testMessage := fmt.Sprintf("DPoP %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			requestValidator := validation.NewRequestValidator()
+			sessionManager := session.GetAuthSessionManagerInstance()
+
+			tokenHandler := NewTokenHandler(requestValidator, sessionManager, tokenManager)
+
+			rr := httptest.NewRecorder()
+
+			body := strings.NewReader(testCreateBody(test.values...))
+
+			request := httptest.NewRequest(http.MethodPost, endpoint.Token, body)
+			request.Header.Add(internalHttp.Authorization, fmt.Sprintf("Basic %s", testTokenCreateBasicAuth("foo", "bar")))
+			request.Header.Add(internalHttp.ContentType, "application/x-www-form-urlencoded")
+			if test.proof != "" {
+				request.Header.Add(internalHttp.DPoP, test.proof)
+			}
+
+			tokenHandler.ServeHTTP(rr, request)
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+
+			if test.expectedStatus != http.StatusOK {
+				errorResponse := oauth2.TokenErrorResponseParameter{}
+				jsonParseError := json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+				if jsonParseError != nil {
+					t.Fatal(jsonParseError)
+				}
+				if errorResponse.Error != test.expectedError {
+					t.Errorf("expected error %s, got %s", test.expectedError, errorResponse.Error)
+				}
+				return
+			}
+
+			accessTokenResponse := oauth2.AccessTokenResponse{}
+			jsonParseError := json.Unmarshal(rr.Body.Bytes(), &accessTokenResponse)
+			if jsonParseError != nil {
+				t.Fatal(jsonParseError)
+			}
+
+			if accessTokenResponse.TokenType != test.expectedTokenType {
+				t.Errorf("expected token type %s, got %s", test.expectedTokenType, accessTokenResponse.TokenType)
+			}
+
+			parsedToken, parseError := jwt.ParseInsecure([]byte(accessTokenResponse.AccessTokenValue))
+			if parseError != nil {
+				t.Fatal(parseError)
+			}
+
+			_, confirmationExists := parsedToken.Get(oauth2.ClaimConfirmation)
+			if confirmationExists != (test.expectedTokenType == oauth2.TtDPoP) {
+				t.Errorf("unexpected claim %s for token type %s", oauth2.ClaimConfirmation, test.expectedTokenType)
+			}
+		})
+	}
+}
+
+func testTokenExchangeGrantType(t *testing.T, testConfig *config.Config) {
+	client, _ := testConfig.GetClient("foo")
+	frontendClient, _ := testConfig.GetClient("frontend")
+	gatewayClient, _ := testConfig.GetClient("gateway")
+	user, _ := testConfig.GetUser("foo")
+
+	tokenManager := token.GetTokenManagerInstance()
+	request := httptest.NewRequest(http.MethodPost, endpoint.Token, nil)
+	subjectTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, frontendClient, nil, []string{"foo:bar", "moo:abc"}, nil, "", "", nil)
+	otherTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, client, nil, []string{"foo:bar"}, nil, "", "", nil)
+	actorTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, "", gatewayClient, nil, []string{}, nil, "", "", nil)
+	gatewayTokenResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, gatewayClient, nil, []string{"foo:bar"}, nil, "", "", nil)
+	dpopBoundResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, frontendClient, nil, []string{"foo:bar"}, nil, "", "", &token.GrantInput{JwkThumbprint: "other"})
+	certificateBoundResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, frontendClient, nil, []string{"foo:bar"}, nil, "", "", &token.GrantInput{CertificateThumbprint: "other"})
+	gatewayBoundResponse, _ := tokenManager.CreateAccessTokenResponse(request, user.Username, gatewayClient, nil, []string{"foo:bar"}, nil, "", "", &token.GrantInput{JwkThumbprint: "other"})
+
+	type exchangeParameter struct {
+		name             string
+		clientId         string
+		subjectToken     string
+		subjectTokenType oauth2.TokenTypeIdentifier
+		actorToken       string
+		audience         string
+		scope            string
+		expectedStatus   int
+		expectedError    oauth2.TokenErrorType
+		expectedAudience []string
+		expectedScopes   []string
+		expectedActor    string
+	}
+
+	var exchangeParameters = []exchangeParameter{
+		{"impersonation", "gateway", subjectTokenResponse.AccessTokenValue, oauth2.TtiAccessToken, "", "https://api.example.com", "foo:bar", http.StatusOK, "", []string{"https://api.example.com"}, []string{"foo:bar"}, ""},
+		{"delegation", "gateway", subjectTokenResponse.AccessTokenValue, oauth2.TtiAccessToken, actorTokenResponse.AccessTokenValue, "billing", "", http.StatusOK, "", []string{"billing"}, []string{"foo:bar", "moo:abc"}, "gateway"},
+		{"refresh token subject", "gateway", gatewayTokenResponse.RefreshTokenValue, oauth2.TtiRefreshToken, "", "billing", "", http.StatusOK, "", []string{"billing"}, []string{"foo:bar"}, ""},
+		{"refresh token of other client", "gateway", subjectTokenResponse.RefreshTokenValue, oauth2.TtiRefreshToken, "", "billing", "", http.StatusBadRequest, oauth2.TokenEtInvalidRequest, nil, nil, ""},
+		{"access token of other client", "gateway", otherTokenResponse.AccessTokenValue, oauth2.TtiAccessToken, "", "billing", "", http.StatusBadRequest, oauth2.TokenEtInvalidRequest, nil, nil, ""},
+		{"DPoP bound subject token without proof", "gateway", dpopBoundResponse.AccessTokenValue, oauth2.TtiAccessToken, "", "billing", "", http.StatusBadRequest, oauth2.TokenEtInvalidRequest, nil, nil, ""},
+		{"certificate bound subject token without certificate", "gateway", certificateBoundResponse.AccessTokenValue, oauth2.TtiAccessToken, "", "billing", "", http.StatusBadRequest, oauth2.TokenEtInvalidRequest, nil, nil, ""},
+		{"DPoP bound refresh token without proof", "gateway", gatewayBoundResponse.RefreshTokenValue, oauth2.TtiRefreshToken, "", "billing", "", http.StatusBadRequest, oauth2.TokenEtInvalidRequest, nil, nil, ""},
+		{"DPoP bound actor token without proof", "gateway", subjectTokenResponse.AccessTokenValue, oauth2.TtiAccessToken, gatewayBoundResponse.AccessTokenValue, "billing", "", http.StatusBadRequest, oauth2.TokenEtInvalidRequest, nil, nil, ""},
+		{"default audience not allowed", "gateway", subjectTokenResponse.AccessTokenValue, oauth2.TtiAccessToken, "", "", "", http.StatusBadRequest, oauth2.TokenEtInvalidTarget, nil, nil, ""},
+		{"audience not allowed", "gateway", subjectTokenResponse.AccessTokenValue, oauth2.TtiAccessToken, "", "https://other.example.com", "", http.StatusBadRequest, oauth2.TokenEtInvalidTarget, nil, nil, ""},
+		{"scope not granted", "gateway", subjectTokenResponse.AccessTokenValue, oauth2.TtiAccessToken, "", "billing", "admin", http.StatusBadRequest, oauth2.TokenEtInvalidScope, nil, nil, ""},
+		{"invalid subject token", "gateway", "foo-no-bar", oauth2.TtiAccessToken, "", "billing", "", http.StatusBadRequest, oauth2.TokenEtInvalidRequest, nil, nil, ""},
+		{"unsupported subject token type", "gateway", subjectTokenResponse.AccessTokenValue, oauth2.TtiIdToken, "", "billing", "", http.StatusBadRequest, oauth2.TokenEtInvalidRequest, nil, nil, ""},
+		{"client without policy", "foo", subjectTokenResponse.AccessTokenValue, oauth2.TtiAccessToken, "", "billing", "", http.StatusBadRequest, oauth2.TokenEtUnauthorizedClient, nil, nil, ""},
+	}
+
+	for _, test := range exchangeParameters {
+		testMessage := fmt.Sprintf("Token exchange grant type %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			requestValidator := validation.NewRequestValidator()
+			sessionManager := session.GetAuthSessionManagerInstance()
+
+			tokenHandler := NewTokenHandler(requestValidator, sessionManager, tokenManager)
+
+			rr := httptest.NewRecorder()
+
+			values := []any{
+				oauth2.ParameterGrantType, oauth2.GtTokenExchange,
+				oauth2.ParameterSubjectToken, test.subjectToken,
+				oauth2.ParameterSubjectTokenType, test.subjectTokenType,
+			}
+			if test.actorToken != "" {
+				values = append(values, oauth2.ParameterActorToken, test.actorToken, oauth2.ParameterActorTokenType, oauth2.TtiAccessToken)
+			}
+			if test.audience != "" {
+				values = append(values, oauth2.ParameterAudience, test.audience)
+			}
+			if test.scope != "" {
+				values = append(values, oauth2.ParameterScope, test.scope)
+			}
+			body := strings.NewReader(testCreateBody(values...))
+
+			request := httptest.NewRequest(http.MethodPost, endpoint.Token, body)
+			request.Header.Add(internalHttp.Authorization, fmt.Sprintf("Basic %s", testTokenCreateBasicAuth(test.clientId, "bar")))
+			request.Header.Add(internalHttp.ContentType, "application/x-www-form-urlencoded")
+
+			tokenHandler.ServeHTTP(rr, request)
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+
+			if test.expectedStatus != http.StatusOK {
+				errorResponse := oauth2.TokenErrorResponseParameter{}
+				jsonParseError := json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+				if jsonParseError != nil {
+					t.Fatal(jsonParseError)
+				}
+				if errorResponse.Error != test.expectedError {
+					t.Errorf("error did not match: got %v want %v", errorResponse.Error, test.expectedError)
+				}
+				return
+			}
+
+			accessTokenResponse := oauth2.AccessTokenResponse{}
+			jsonParseError := json.Unmarshal(rr.Body.Bytes(), &accessTokenResponse)
+			if jsonParseError != nil {
+				t.Fatal(jsonParseError)
+			}
+
+			if accessTokenResponse.IssuedTokenType != oauth2.TtiAccessToken || accessTokenResponse.RefreshTokenValue != "" {
+				t.Errorf("issued token type or refresh token did not match, %v", accessTokenResponse)
+			}
+
+			accessToken, exists := tokenManager.GetAccessToken(context.Background(), accessTokenResponse.AccessTokenValue)
+			if !exists {
+				t.Fatal("access token was not found in access token manager")
+			}
+
+			if accessToken.ClientId != test.clientId || accessToken.Username != user.Username {
+				t.Errorf("client or username did not match, %s %s", accessToken.ClientId, accessToken.Username)
+			}
+
+			if !reflect.DeepEqual(accessToken.Audience, test.expectedAudience) || !reflect.DeepEqual(accessToken.Scopes, test.expectedScopes) {
+				t.Errorf("audience or scopes did not match, %v %v", accessToken.Audience, accessToken.Scopes)
+			}
+
+			if test.expectedActor == "" && accessToken.Actor != nil {
+				t.Errorf("actor should not exist, %v", accessToken.Actor)
+			} else if test.expectedActor != "" && (accessToken.Actor == nil || accessToken.Actor.Subject != test.expectedActor) {
+				t.Errorf("actor did not match, %v", accessToken.Actor)
+			}
+		})
+	}
+}
+
+func testTokenJwtBearerGrantType(t *testing.T) {
+	signingPrivateKey, loadError := crypto.LoadPrivateKey("../../../../.test_files/rsa256key.pem")
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+
+	createAssertion := func(audience string) string {
+		assertionToken, buildError := jwt.NewBuilder().
+			Issuer("https://ci.example.com").
+			Subject("deploy-bot").
+			Audience([]string{audience}).
+			JwtID(uuid.NewString()).
+			IssuedAt(time.Now()).
+			Expiration(time.Now().Add(time.Minute)).
+			Build()
+		if buildError != nil {
+			t.Fatal(buildError)
+		}
+		signedAssertion, signError := jwt.Sign(assertionToken, jwt.WithKey(signingPrivateKey.SignatureAlgorithm, signingPrivateKey.PrivateKey))
+		if signError != nil {
+			t.Fatal(signError)
+		}
+		return string(signedAssertion)
+	}
+
+	validAssertion := createAssertion("http://example.com/token")
+
+	type jwtBearerParameter struct {
+		name           string
+		assertion      string
+		expectedStatus int
+	}
+
+	var jwtBearerParameters = []jwtBearerParameter{
+		{"valid assertion", validAssertion, http.StatusOK},
+		{"replayed assertion", validAssertion, http.StatusBadRequest},
+		{"wrong audience", createAssertion("https://other.example.com/token"), http.StatusBadRequest},
+		{"missing assertion", "", http.StatusBadRequest},
+	}
+
+	for _, test := range jwtBearerParameters {
+		testMessage := fmt.Sprintf("JWT bearer grant type %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			requestValidator := validation.NewRequestValidator()
+			sessionManager := session.GetAuthSessionManagerInstance()
+			tokenManager := token.GetTokenManagerInstance()
+
+			tokenHandler := NewTokenHandler(requestValidator, sessionManager, tokenManager)
+
+			rr := httptest.NewRecorder()
+
+			bodyString := testCreateBody(
+				oauth2.ParameterGrantType, oauth2.GtJwtBearer,
+				oauth2.ParameterAssertion, test.assertion,
+				oauth2.ParameterScope, "deploy",
+			)
+			body := strings.NewReader(bodyString)
+
+			request := httptest.NewRequest(http.MethodPost, endpoint.Token, body)
+			request.Header.Add(internalHttp.Authorization, fmt.Sprintf("Basic %s", testTokenCreateBasicAuth("gateway", "bar")))
+			request.Header.Add(internalHttp.ContentType, "application/x-www-form-urlencoded")
+
+			tokenHandler.ServeHTTP(rr, request)
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, test.expectedStatus)
+			}
+
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+
+			accessTokenResponse := oauth2.AccessTokenResponse{}
+			jsonParseError := json.Unmarshal(rr.Body.Bytes(), &accessTokenResponse)
+			if jsonParseError != nil {
+				t.Fatal(jsonParseError)
+			}
+
+			accessToken, exists := tokenManager.GetAccessToken(context.Background(), accessTokenResponse.AccessTokenValue)
+			if !exists {
+				t.Fatal("access token was not found in access token manager")
+			}
+
+			if accessToken.Username != "deploy-bot" || !reflect.DeepEqual(accessToken.Scopes, []string{"deploy"}) {
+				t.Errorf("subject or scopes did not match, %s %v", accessToken.Username, accessToken.Scopes)
+			}
+		})
+	}
+}
+
 func Test_TokenNotAllowedHttpMethods(t *testing.T) {
 	var testInvalidTokenHttpMethods = []string{
 		http.MethodGet,
@@ -424,6 +939,43 @@ func Test_TokenNotAllowedHttpMethods(t *testing.T) {
 	}
 }
 
+func testTokenGrantTypeNotAllowed(t *testing.T) {
+	t.Run("Grant type not allowed for client", func(t *testing.T) {
+		requestValidator := validation.NewRequestValidator()
+		sessionManager := session.GetAuthSessionManagerInstance()
+		tokenManager := token.GetTokenManagerInstance()
+
+		tokenHandler := NewTokenHandler(requestValidator, sessionManager, tokenManager)
+
+		rr := httptest.NewRecorder()
+
+		bodyString := testCreateBody(
+			oauth2.ParameterGrantType, oauth2.GtClientCredentials,
+			oauth2.ParameterScope, "foo:bar",
+		)
+		body := strings.NewReader(bodyString)
+
+		request := httptest.NewRequest(http.MethodPost, endpoint.Token, body)
+		request.Header.Add(internalHttp.Authorization, fmt.Sprintf("Basic %s", testTokenCreateBasicAuth("gateway", "bar")))
+		request.Header.Add(internalHttp.ContentType, "application/x-www-form-urlencoded")
+
+		tokenHandler.ServeHTTP(rr, request)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+		}
+
+		errorResponse := oauth2.TokenErrorResponseParameter{}
+		jsonParseError := json.Unmarshal(rr.Body.Bytes(), &errorResponse)
+		if jsonParseError != nil {
+			t.Fatal(jsonParseError)
+		}
+		if errorResponse.Error != oauth2.TokenEtUnauthorizedClient {
+			t.Errorf("error did not match: got %v want %v", errorResponse.Error, oauth2.TokenEtUnauthorizedClient)
+		}
+	})
+}
+
 func testTokenCreateBasicAuth(username string, password string) string {
 	auth := username + ":" + password
 	return base64.StdEncoding.EncodeToString([]byte(auth))
@@ -450,7 +1002,7 @@ func testTokenValidate(t *testing.T, tokenManager *token.Manager, response *http
 		t.Errorf("access token key was empty")
 	}
 
-	_, exists := tokenManager.GetAccessToken(accessTokenResponse.AccessTokenValue)
+	_, exists := tokenManager.GetAccessToken(context.Background(), accessTokenResponse.AccessTokenValue)
 
 	if !exists {
 		t.Errorf("access token was not found in access token manager")
@@ -473,3 +1025,36 @@ func testCreateBody(values ...any) string {
 	}
 	return result
 }
+
+func testCreateDPoPProof(t *testing.T, method string, uri string) string {
+	signingPrivateKey, loadError := crypto.LoadPrivateKey("../../../../.test_files/ecdsa256key.pem")
+	if loadError != nil {
+		t.Fatal(loadError)
+	}
+
+	publicKey, keyError := jwk.PublicKeyOf(signingPrivateKey.PrivateKey)
+	if keyError != nil {
+		t.Fatal(keyError)
+	}
+
+	headers := jws.NewHeaders()
+	_ = headers.Set(jws.TypeKey, "dpop+jwt")
+	_ = headers.Set(jws.JWKKey, publicKey)
+
+	proofToken, buildError := jwt.NewBuilder().
+		IssuedAt(time.Now()).
+		JwtID(uuid.NewString()).
+		Claim("htm", method).
+		Claim("htu", uri).
+		Build()
+	if buildError != nil {
+		t.Fatal(buildError)
+	}
+
+	signedToken, signError := jwt.Sign(proofToken, jwt.WithKey(signingPrivateKey.SignatureAlgorithm, signingPrivateKey.PrivateKey, jws.WithProtectedHeaders(headers)))
+	if signError != nil {
+		t.Fatal(signError)
+	}
+
+	return string(signedToken)
+}
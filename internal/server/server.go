@@ -2,13 +2,18 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"github.com/google/uuid"
+	"github.com/webishdev/stopnik/internal/audit"
 	"github.com/webishdev/stopnik/internal/config"
 	"github.com/webishdev/stopnik/internal/endpoint"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
 	"github.com/webishdev/stopnik/internal/manager/cookie"
 	"github.com/webishdev/stopnik/internal/manager/key"
 	"github.com/webishdev/stopnik/internal/manager/session"
 	token2 "github.com/webishdev/stopnik/internal/manager/token"
+	"github.com/webishdev/stopnik/internal/metrics"
 	"github.com/webishdev/stopnik/internal/server/handler/account"
 	"github.com/webishdev/stopnik/internal/server/handler/assets"
 	"github.com/webishdev/stopnik/internal/server/handler/authorize"
@@ -18,14 +23,22 @@ import (
 	"github.com/webishdev/stopnik/internal/server/handler/keys"
 	"github.com/webishdev/stopnik/internal/server/handler/logout"
 	"github.com/webishdev/stopnik/internal/server/handler/metadata"
+	metricsHandler "github.com/webishdev/stopnik/internal/server/handler/metrics"
 	"github.com/webishdev/stopnik/internal/server/handler/oidc"
+	"github.com/webishdev/stopnik/internal/server/handler/registration"
 	"github.com/webishdev/stopnik/internal/server/handler/revoke"
 	"github.com/webishdev/stopnik/internal/server/handler/token"
 	"github.com/webishdev/stopnik/internal/server/validation"
+	"github.com/webishdev/stopnik/internal/system"
 	"github.com/webishdev/stopnik/internal/template"
+	"github.com/webishdev/stopnik/internal/tracing"
+	"github.com/webishdev/stopnik/internal/webhook"
 	"github.com/webishdev/stopnik/log"
+	"io"
 	"net"
 	"net/http"
+	"os"
+	"strings"
 	"sync"
 	"time"
 )
@@ -38,11 +51,43 @@ type middlewareHandler struct {
 }
 
 func (mh middlewareHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	recorder := metrics.NewStatusRecorder(w)
+	requestId := getRequestId(r)
+	recorder.Header().Set(internalHttp.XRequestId, requestId)
+	ctx, span := tracing.StartServerSpan(r)
+	r = r.WithContext(log.NewRequestContext(ctx, requestId))
+	var pattern string
 	if mh.assets.Matches(r) {
-		mh.assets.ServeHTTP(w, r)
+		mh.assets.ServeHTTP(recorder, r)
+		pattern = "assets"
 	} else {
-		mh.next.ServeHTTP(w, r)
+		mh.next.ServeHTTP(recorder, r)
+		pattern = r.Pattern
 	}
+	latency := time.Since(start)
+	metrics.ObserveRequest(pattern, r.Method, recorder.Status(), latency)
+	log.AccessLog(r, pattern, recorder.Status(), recorder.Size(), latency)
+	if span != nil {
+		span.SetAttribute(log.KeyRequestId, requestId)
+		span.SetName(strings.TrimSpace(r.Method + " " + pattern))
+		span.SetAttribute("http.route", pattern)
+		span.SetAttribute("http.response.status_code", recorder.Status())
+		span.SetError(tracing.ErrorStatus(recorder.Status()))
+		span.End()
+	}
+}
+
+// getRequestId returns the request id provided by the X-Request-Id header,
+// or a new one when the header is missing or does not contain a reasonable value.
+func getRequestId(r *http.Request) string {
+	requestId := r.Header.Get(internalHttp.XRequestId)
+	if requestId == "" || len(requestId) > 128 || strings.ContainsFunc(requestId, func(c rune) bool {
+		return c < 0x21 || c > 0x7e
+	}) {
+		return uuid.NewString()
+	}
+	return requestId
 }
 
 type StopnikServer struct {
@@ -54,8 +99,10 @@ type StopnikServer struct {
 	idleTimeout       time.Duration
 	httpServer        *http.Server
 	httpsServer       *http.Server
+	metricsServer     *http.Server
 	serve             *ListenAndServe
 	serveTLS          *ListenAndServe
+	serveMetrics      *ListenAndServe
 	rwMutex           *sync.RWMutex
 }
 
@@ -65,7 +112,7 @@ func NewStopnikServer() *StopnikServer {
 		rwMutex.Lock()
 		stopnikServer.httpServer = server
 		rwMutex.Unlock()
-		log.Info("Will accept connections at %s", server.Addr)
+		log.Info("Will accept connections", "addr", server.Addr)
 		return server.Serve(*listener)
 	}
 	listenAndServeTLS := func(stopnikServer *StopnikServer, listener *net.Listener, server *http.Server) error {
@@ -75,7 +122,15 @@ func NewStopnikServer() *StopnikServer {
 		if stopnikServer.config.Server.TLS.Keys.Cert == "" || stopnikServer.config.Server.TLS.Keys.Key == "" {
 			return errors.New("TLS Keys not configured")
 		}
-		log.Info("Will accept TLS connections at %s", server.Addr)
+		if stopnikServer.config.Server.TLS.ClientAuth.Enabled {
+			// Certificates are verified during client authentication, which also allows self-signed certificates
+			// https://datatracker.ietf.org/doc/html/rfc8705#section-2
+			server.TLSConfig = &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				ClientAuth: tls.RequestClientCert,
+			}
+		}
+		log.Info("Will accept TLS connections", "addr", server.Addr)
 		return server.ServeTLS(*listener, stopnikServer.config.Server.TLS.Keys.Cert, stopnikServer.config.Server.TLS.Keys.Key)
 	}
 	return newStopnikServerWithServe(rwMutex, http.NewServeMux(), listenAndServe, listenAndServeTLS)
@@ -83,7 +138,20 @@ func NewStopnikServer() *StopnikServer {
 
 func newStopnikServerWithServe(rwMutex *sync.RWMutex, mux *http.ServeMux, serve ListenAndServe, serveTLS ListenAndServe) *StopnikServer {
 	currentConfig := config.GetConfigInstance()
-	registerHandlers(currentConfig, mux.Handle)
+	configureTracing(currentConfig)
+	configureAudit(currentConfig)
+	configureWebhooks(currentConfig)
+	registerHandlers(currentConfig, func(pattern string, handler http.Handler) {
+		mux.Handle(pattern, tracing.NewHandler(handler))
+	})
+
+	serveMetrics := ListenAndServe(func(stopnikServer *StopnikServer, listener *net.Listener, server *http.Server) error {
+		rwMutex.Lock()
+		stopnikServer.metricsServer = server
+		rwMutex.Unlock()
+		log.Info("Will accept metrics connections", "addr", server.Addr)
+		return server.Serve(*listener)
+	})
 
 	middleware := &middlewareHandler{
 		next:   mux,
@@ -98,6 +166,7 @@ func newStopnikServerWithServe(rwMutex *sync.RWMutex, mux *http.ServeMux, serve
 		idleTimeout:       30 * time.Second,
 		serve:             &serve,
 		serveTLS:          &serveTLS,
+		serveMetrics:      &serveMetrics,
 		rwMutex:           rwMutex,
 	}
 }
@@ -110,10 +179,10 @@ func (stopnikServer *StopnikServer) Start() {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			errorServer := stopnikServer.listenAndServe(stopnikServer.config.Server.Addr, *stopnikServer.serve)
+			errorServer := stopnikServer.listenAndServe(stopnikServer.config.Server.Addr, stopnikServer.middleware, *stopnikServer.serve)
 
 			if errorServer != nil && !errors.Is(errorServer, http.ErrServerClosed) {
-				log.Error("Error starting server: %v", errorServer)
+				log.Error("Error starting server", log.Err(errorServer))
 			}
 		}()
 	}
@@ -122,10 +191,24 @@ func (stopnikServer *StopnikServer) Start() {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			errorServer := stopnikServer.listenAndServe(stopnikServer.config.Server.TLS.Addr, *stopnikServer.serveTLS)
+			errorServer := stopnikServer.listenAndServe(stopnikServer.config.Server.TLS.Addr, stopnikServer.middleware, *stopnikServer.serveTLS)
+
+			if errorServer != nil && !errors.Is(errorServer, http.ErrServerClosed) {
+				log.Error("Error starting TLS server", log.Err(errorServer))
+			}
+		}()
+	}
+
+	if stopnikServer.config.GetMetricsEnabled() && stopnikServer.config.GetMetricsAddr() != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			metricsMux := http.NewServeMux()
+			metricsMux.Handle(stopnikServer.config.GetMetricsEndpoint(), metricsHandler.NewMetricsHandler(metrics.GetRegistryInstance()))
+			errorServer := stopnikServer.listenAndServe(stopnikServer.config.GetMetricsAddr(), metricsMux, *stopnikServer.serveMetrics)
 
 			if errorServer != nil && !errors.Is(errorServer, http.ErrServerClosed) {
-				log.Error("Error starting TLS server: %v", errorServer)
+				log.Error("Error starting metrics server", log.Err(errorServer))
 			}
 		}()
 	}
@@ -144,9 +227,18 @@ func (stopnikServer *StopnikServer) Shutdown() {
 	if stopnikServer.httpsServer != nil {
 		shutdownServer(stopnikServer.httpsServer)
 	}
+
+	if stopnikServer.metricsServer != nil {
+		shutdownServer(stopnikServer.metricsServer)
+	}
+
+	token2.Shutdown()
+	tracing.Shutdown()
+	audit.Shutdown()
+	webhook.Shutdown()
 }
 
-func (stopnikServer *StopnikServer) listenAndServe(addr string, serve func(stopnikServer *StopnikServer, listener *net.Listener, server *http.Server) error) error {
+func (stopnikServer *StopnikServer) listenAndServe(addr string, handler http.Handler, serve func(stopnikServer *StopnikServer, listener *net.Listener, server *http.Server) error) error {
 	listener, listenError := net.Listen("tcp", addr)
 	if listenError != nil {
 		return listenError
@@ -158,7 +250,7 @@ func (stopnikServer *StopnikServer) listenAndServe(addr string, serve func(stopn
 		ReadTimeout:       stopnikServer.readTimeout,
 		WriteTimeout:      stopnikServer.writeTimeout,
 		IdleTimeout:       stopnikServer.idleTimeout,
-		Handler:           stopnikServer.middleware,
+		Handler:           handler,
 	}
 	errorServer := serve(stopnikServer, &listener, httpServer)
 	if errorServer != nil {
@@ -171,10 +263,75 @@ func (stopnikServer *StopnikServer) listenAndServe(addr string, serve func(stopn
 func shutdownServer(server *http.Server) {
 	errorServer := server.Shutdown(context.Background())
 	if errorServer != nil {
-		log.Error("Failed to shutdown server: %v", errorServer)
+		log.Error("Failed to shutdown server", log.Err(errorServer))
 	}
 }
 
+func configureTracing(config *config.Config) {
+	if !config.GetTracingEnabled() {
+		tracing.Shutdown()
+		return
+	}
+	serviceName := config.GetTracingServiceName()
+	if config.GetTracingExporter() == "otlp" {
+		log.Info("Tracing enabled, exporting spans", "endpoint", config.GetTracingEndpoint())
+		tracing.Configure(serviceName, tracing.NewOTLPExporter(config.GetTracingEndpoint(), serviceName))
+	} else {
+		log.Info("Tracing enabled, exporting spans to stdout")
+		tracing.Configure(serviceName, tracing.NewWriterExporter(os.Stdout))
+	}
+}
+
+func configureAudit(config *config.Config) {
+	if !config.GetAuditEnabled() {
+		audit.Shutdown()
+		return
+	}
+	var sinks []audit.Sink
+	if config.Server.Audit.File != "" {
+		fileSink, fileSinkError := audit.NewFileSink(config.Server.Audit.File)
+		if fileSinkError != nil {
+			system.CriticalError(fileSinkError)
+			return
+		}
+		log.Info("Audit enabled, writing events to file", "file", config.Server.Audit.File)
+		sinks = append(sinks, fileSink)
+	}
+	if config.Server.Audit.Webhook != "" {
+		log.Info("Audit enabled, sending events to webhook", "webhook", config.Server.Audit.Webhook)
+		sinks = append(sinks, audit.NewWebhookSink(config.Server.Audit.Webhook, config.GetWebhookQueueSize(), config.GetWebhookMaxRetries()))
+	}
+	audit.Configure(sinks...)
+	audit.Record(nil, &audit.Event{Type: audit.EtConfigLoaded, Outcome: audit.OcSuccess})
+}
+
+func configureWebhooks(config *config.Config) {
+	webhooks := config.Server.Webhooks
+	if len(webhooks.Subscriptions) == 0 {
+		webhook.Shutdown()
+		return
+	}
+	subscriptions := make([]*webhook.Subscription, 0, len(webhooks.Subscriptions))
+	for _, current := range webhooks.Subscriptions {
+		subscription := &webhook.Subscription{Url: current.Url, Secret: current.Secret}
+		for _, event := range current.Events {
+			subscription.Events = append(subscription.Events, webhook.EventType(event))
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	var deadLetters io.Writer
+	if webhooks.DeadLetterFile != "" {
+		deadLetterFile, deadLetterFileError := webhook.OpenDeadLetterFile(webhooks.DeadLetterFile)
+		if deadLetterFileError != nil {
+			system.CriticalError(deadLetterFileError)
+			return
+		}
+		deadLetters = deadLetterFile
+	}
+	log.Info("Webhooks enabled", "subscriptions", len(subscriptions))
+	webhook.Configure(webhook.NewDispatcher(subscriptions, config.GetWebhookQueueSize(), config.GetWebhookMaxRetries(), deadLetters))
+}
+
 func registerHandlers(config *config.Config, handle func(pattern string, handler http.Handler)) {
 	keyManger := key.GetKeyMangerInstance()
 	authSessionManager := session.GetAuthSessionManagerInstance()
@@ -186,7 +343,7 @@ func registerHandlers(config *config.Config, handle func(pattern string, handler
 
 	// Own
 	healthHandler := health.NewHealthHandler(tokenManager)
-	accountHandler := account.NewAccountHandler(requestValidator, cookieManager, loginSessionManager, templateManager)
+	accountHandler := account.NewAccountHandler(requestValidator, cookieManager, loginSessionManager, tokenManager, templateManager)
 	logoutHandler := logout.NewLogoutHandler(cookieManager, loginSessionManager, config.Server.LogoutRedirect)
 
 	// OAuth2
@@ -205,7 +362,7 @@ func registerHandlers(config *config.Config, handle func(pattern string, handler
 	handle(endpoint.Logout, logoutHandler)
 
 	if config.GetForwardAuthEnabled() {
-		log.Info("ForwardAuth enabled with endpoint %s", config.GetForwardAuthEndpoint())
+		log.Info("ForwardAuth enabled", "endpoint", config.GetForwardAuthEndpoint())
 		forwardSessionManager := session.GetForwardSessionManagerInstance()
 		forwardAuthHandler := forwardauth.NewForwardAuthHandler(cookieManager, authSessionManager, forwardSessionManager, loginSessionManager, templateManager)
 		handle(config.GetForwardAuthEndpoint(), forwardAuthHandler)
@@ -221,6 +378,19 @@ func registerHandlers(config *config.Config, handle func(pattern string, handler
 	handle(endpoint.Metadata, metadataHandler)
 	handle(endpoint.Keys, keysHandler)
 
+	if config.Server.Registration.Enabled {
+		log.Info("Dynamic client registration enabled", "endpoint", endpoint.Registration)
+		registrationHandler := registration.NewRegistrationHandler(tokenManager)
+		handle(endpoint.Registration, registrationHandler)
+		handle(endpoint.Registration+"/", registrationHandler)
+	}
+
+	// Metrics, only when not served on a separate listener
+	if config.GetMetricsEnabled() && config.GetMetricsAddr() == "" {
+		log.Info("Metrics enabled", "endpoint", config.GetMetricsEndpoint())
+		handle(config.GetMetricsEndpoint(), metricsHandler.NewMetricsHandler(metrics.GetRegistryInstance()))
+	}
+
 	// Oidc 1.0 Core
 	if config.GetOidc() {
 		discoveryHandler := oidc.NewOidcDiscoveryHandler()
@@ -114,6 +114,45 @@ func Test_Server(t *testing.T) {
 		}
 	})
 
+	t.Run("Register handlers with metrics", func(t *testing.T) {
+		type metricsParameter struct {
+			name             string
+			metrics          config.Metrics
+			expectedHandlers int
+		}
+
+		var metricsParameters = []metricsParameter{
+			{name: "enabled", metrics: config.Metrics{Enabled: true}, expectedHandlers: 10},
+			{name: "separate listener", metrics: config.Metrics{Enabled: true, Addr: ":0"}, expectedHandlers: 9},
+		}
+
+		for _, test := range metricsParameters {
+			metricsConfig := &config.Config{
+				Server: config.Server{
+					Metrics: test.metrics,
+				},
+			}
+			initializationError := config.Initialize(metricsConfig)
+			if initializationError != nil {
+				t.Fatal(initializationError)
+			}
+			patterns := &[]string{}
+			reg := func(pattern string, handler http.Handler) {
+				*patterns = append(*patterns, pattern)
+			}
+			registerHandlers(metricsConfig, reg)
+
+			if len(*patterns) != test.expectedHandlers {
+				t.Errorf("Incorrect number of patterns registered for %s, expected %v got %v", test.name, test.expectedHandlers, len(*patterns))
+			}
+
+			metricsPattern := slices.Contains(*patterns, endpoint.Metrics)
+			if metricsPattern != (test.expectedHandlers == 10) {
+				t.Errorf("Metrics endpoint registration for %s did not match", test.name)
+			}
+		}
+	})
+
 	for _, test := range testConfigParameters {
 		initializationError := config.Initialize(test.config)
 		if initializationError != nil {
@@ -0,0 +1,80 @@
+package validation
+
+import (
+	"crypto/x509"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
+	internalHttp "github.com/webishdev/stopnik/internal/http"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+)
+
+// validateClientCertificate authenticates a client with the TLS client certificate of a request,
+// see https://datatracker.ietf.org/doc/html/rfc8705#section-2
+func (validator *RequestValidator) validateClientCertificate(r *http.Request, client *config.Client) bool {
+	certificate, certificateExists := internalHttp.GetClientCertificate(r)
+	if !certificateExists {
+		log.DebugContext(r.Context(), "Missing client certificate", log.ClientId(client.Id))
+		return false
+	}
+
+	certificateAuthMethod, _ := client.GetCertificateAuthMethod()
+	switch certificateAuthMethod {
+	case oauth2.CamTlsClientAuth:
+		return validator.validCertificateChain(r.TLS.PeerCertificates) && matchesCertificateSubject(certificate, client.ClientCertificate)
+	case oauth2.CamSelfSignedTlsClientAuth:
+		return slices.Contains(client.ClientCertificate.Thumbprints, crypto.CertificateThumbprint(certificate))
+	default:
+		return false
+	}
+}
+
+// validCertificateChain verifies a client certificate and its intermediates against the configured CA,
+// see https://datatracker.ietf.org/doc/html/rfc8705#section-2.1
+func (validator *RequestValidator) validCertificateChain(certificates []*x509.Certificate) bool {
+	if validator.clientCAs == nil {
+		return false
+	}
+	intermediates := x509.NewCertPool()
+	for _, intermediate := range certificates[1:] {
+		intermediates.AddCert(intermediate)
+	}
+	_, verifyError := certificates[0].Verify(x509.VerifyOptions{
+		Roots:         validator.clientCAs,
+		Intermediates: intermediates,
+		CurrentTime:   validator.now(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if verifyError != nil {
+		log.Debug("Invalid client certificate", log.Err(verifyError))
+		return false
+	}
+	return true
+}
+
+// matchesCertificateSubject compares the configured subject DN or SAN value with the certificate,
+// the subject DN uses the string representation of RFC 4514, e.g. CN=foo,O=Example.
+// See https://datatracker.ietf.org/doc/html/rfc8705#section-2.1.2
+func matchesCertificateSubject(certificate *x509.Certificate, clientCertificate config.ClientCertificate) bool {
+	switch {
+	case clientCertificate.SubjectDN != "":
+		return certificate.Subject.String() == clientCertificate.SubjectDN
+	case clientCertificate.SanDNS != "":
+		return slices.Contains(certificate.DNSNames, clientCertificate.SanDNS)
+	case clientCertificate.SanURI != "":
+		return slices.ContainsFunc(certificate.URIs, func(uri *url.URL) bool {
+			return uri.String() == clientCertificate.SanURI
+		})
+	case clientCertificate.SanIP != "":
+		ip := net.ParseIP(clientCertificate.SanIP)
+		return ip != nil && slices.ContainsFunc(certificate.IPAddresses, ip.Equal)
+	case clientCertificate.SanEmail != "":
+		return slices.Contains(certificate.EmailAddresses, clientCertificate.SanEmail)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,154 @@
+package validation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/crypto"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_ValidateClientCertificate(t *testing.T) {
+	caCertificate, caKey := testCreateCertificate(t, "Test CA", nil, nil, nil)
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	writeError := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCertificate.Raw}), 0600)
+	if writeError != nil {
+		t.Fatal(writeError)
+	}
+
+	clientCertificate, _ := testCreateCertificate(t, "foo", []string{"foo.example.com"}, caCertificate, caKey)
+	selfSignedCertificate, _ := testCreateCertificate(t, "foo", []string{"foo.example.com"}, nil, nil)
+	otherSelfSignedCertificate, _ := testCreateCertificate(t, "foo", []string{"foo.example.com"}, nil, nil)
+
+	testConfig := &config.Config{
+		Server: config.Server{
+			TLS: config.TLS{
+				ClientAuth: config.ClientAuth{Enabled: true, CA: caFile},
+			},
+		},
+		Clients: []config.Client{
+			{
+				Id:                "subject",
+				Redirects:         []string{"https://example.com/callback"},
+				ClientCertificate: config.ClientCertificate{SubjectDN: "CN=foo"},
+			},
+			{
+				Id:                "san",
+				Redirects:         []string{"https://example.com/callback"},
+				ClientCertificate: config.ClientCertificate{SanDNS: "foo.example.com"},
+			},
+			{
+				Id:                "other",
+				Redirects:         []string{"https://example.com/callback"},
+				ClientCertificate: config.ClientCertificate{SanDNS: "other.example.com"},
+			},
+			{
+				Id:                "selfsigned",
+				Redirects:         []string{"https://example.com/callback"},
+				ClientCertificate: config.ClientCertificate{Thumbprints: []string{crypto.CertificateThumbprint(selfSignedCertificate)}},
+			},
+		},
+	}
+	initializationError := config.Initialize(testConfig)
+	if initializationError != nil {
+		t.Fatal(initializationError)
+	}
+
+	type certificateParameter struct {
+		name        string
+		clientId    string
+		certificate *x509.Certificate
+		valid       bool
+	}
+
+	var certificateParameters = []certificateParameter{
+		{"subject DN", "subject", clientCertificate, true},
+		{"SAN", "san", clientCertificate, true},
+		{"other SAN", "other", clientCertificate, false},
+		{"subject DN with self-signed certificate", "subject", selfSignedCertificate, false},
+		{"self-signed", "selfsigned", selfSignedCertificate, true},
+		{"other self-signed", "selfsigned", otherSelfSignedCertificate, false},
+		{"self-signed without certificate", "selfsigned", nil, false},
+		{"subject DN without certificate", "subject", nil, false},
+	}
+
+	for _, test := range certificateParameters {
+		testMessage := fmt.Sprintf("Client certificate %s", test.name)
+		t.Run(testMessage, func(t *testing.T) {
+			httpRequest := &http.Request{
+				Method: http.MethodPost,
+				PostForm: map[string][]string{
+					oauth2.ParameterClientId: {test.clientId},
+				},
+			}
+			if test.certificate != nil {
+				httpRequest.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{test.certificate}}
+			}
+
+			requestValidator := NewRequestValidator()
+
+			client, _, valid := requestValidator.ValidateClientCredentials(httpRequest)
+
+			if test.valid != valid {
+				t.Fatalf("result does not match %t != %t", test.valid, valid)
+			}
+
+			if valid && client.Id != test.clientId {
+				t.Errorf("expected client %s, got %s", test.clientId, client.Id)
+			}
+		})
+	}
+}
+
+func testCreateCertificate(t *testing.T, commonName string, dnsNames []string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	privateKey, keyError := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if keyError != nil {
+		t.Fatal(keyError)
+	}
+
+	serialNumber, serialError := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if serialError != nil {
+		t.Fatal(serialError)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = dnsNames == nil
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		parent = template
+		parentKey = privateKey
+	}
+
+	certificateBytes, createError := x509.CreateCertificate(rand.Reader, template, parent, &privateKey.PublicKey, parentKey)
+	if createError != nil {
+		t.Fatal(createError)
+	}
+
+	certificate, parseError := x509.ParseCertificate(certificateBytes)
+	if parseError != nil {
+		t.Fatal(parseError)
+	}
+
+	return certificate, privateKey
+}
@@ -1,23 +1,51 @@
 package validation
 
 import (
+	"crypto/x509"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/webishdev/stopnik/internal/audit"
 	"github.com/webishdev/stopnik/internal/config"
 	"github.com/webishdev/stopnik/internal/crypto"
+	"github.com/webishdev/stopnik/internal/metrics"
 	"github.com/webishdev/stopnik/internal/oauth2"
+	"github.com/webishdev/stopnik/internal/schema"
+	"github.com/webishdev/stopnik/internal/store"
 	"github.com/webishdev/stopnik/internal/system"
+	"github.com/webishdev/stopnik/internal/webhook"
 	"github.com/webishdev/stopnik/log"
 	"net/http"
+	"sync"
 	"time"
 )
 
+// otpStepDuration is the time the last accepted TOTP time step of a user is kept,
+// which covers the time steps accepted because of clock drift.
+const otpStepDuration = 2 * time.Minute
+
 type now func() time.Time
 
 type RequestValidator struct {
 	config             *config.Config
 	now                now
 	serverSecretLoader crypto.ServerSecretLoader
+	clientCAs          *x509.CertPool
+	otpSteps           *store.ExpiringStore[int64]
+}
+
+var otpStepLock = &sync.Mutex{}
+var otpStepSingleton *store.ExpiringStore[int64]
+
+// getOtpStepStore returns the store of the last accepted TOTP time steps, which is shared by all validators.
+func getOtpStepStore() *store.ExpiringStore[int64] {
+	otpStepLock.Lock()
+	defer otpStepLock.Unlock()
+	if otpStepSingleton == nil {
+		otpStepStore := store.NewTimedStore[int64](otpStepDuration)
+		otpStepSingleton = &otpStepStore
+	}
+	return otpStepSingleton
 }
 
 func NewRequestValidator() *RequestValidator {
@@ -26,11 +54,23 @@ func NewRequestValidator() *RequestValidator {
 
 func newRequestValidator(now now) *RequestValidator {
 	currentConfig := config.GetConfigInstance()
-	return &RequestValidator{
+	requestValidator := &RequestValidator{
 		config:             currentConfig,
 		now:                now,
 		serverSecretLoader: crypto.NewServerSecretLoader(),
+		otpSteps:           getOtpStepStore(),
 	}
+
+	clientAuth := currentConfig.Server.TLS.ClientAuth
+	if clientAuth.Enabled && clientAuth.CA != "" {
+		clientCAs, loadError := crypto.LoadCertificatePool(clientAuth.CA)
+		if loadError != nil {
+			system.Error(fmt.Errorf("could not load TLS client authentication CA: %w", loadError))
+		}
+		requestValidator.clientCAs = clientCAs
+	}
+
+	return requestValidator
 }
 
 func (validator *RequestValidator) NewLoginToken(id string) string {
@@ -66,7 +106,7 @@ func (validator *RequestValidator) GetLoginToken(loginToken string) (jwt.Token,
 
 func (validator *RequestValidator) ValidateFormLogin(r *http.Request) (*config.User, *string) {
 	if r.Method == http.MethodPost {
-		log.Debug("Validating user credentials")
+		log.DebugContext(r.Context(), "Validating user credentials")
 
 		username := r.PostFormValue("stopnik_username")
 		password := r.PostFormValue("stopnik_password")
@@ -74,14 +114,18 @@ func (validator *RequestValidator) ValidateFormLogin(r *http.Request) (*config.U
 
 		if username == "" || password == "" || loginToken == "" {
 			loginError := validator.config.GetInvalidCredentialsMessage()
-			log.AccessLogInvalidLogin(r, "Account login failed: %s", loginError)
+			log.AccessLogInvalidLogin(r, "Account login failed", log.Reason(loginError))
+			metrics.Logins.Inc(metrics.ResultFailure)
+			RecordLoginFailure(r, username, "missing_credentials")
 			return nil, &loginError
 		}
 
 		_, tokenError := validator.GetLoginToken(loginToken)
 		if tokenError != nil {
 			loginError := validator.config.GetExpiredLoginMessage()
-			log.AccessLogInvalidLogin(r, "Account login failed: %s", loginError)
+			log.AccessLogInvalidLogin(r, "Account login failed", log.Reason(loginError))
+			metrics.Logins.Inc(metrics.ResultFailure)
+			RecordLoginFailure(r, username, "expired_login")
 			return nil, &loginError
 		}
 
@@ -92,19 +136,68 @@ func (validator *RequestValidator) ValidateFormLogin(r *http.Request) (*config.U
 		user, valid := validator.ValidateUserPassword(username, password)
 		if !valid {
 			loginError := validator.config.GetInvalidCredentialsMessage()
-			log.AccessLogInvalidLogin(r, "Account login failed for user %s: %s", username, loginError)
+			log.AccessLogInvalidLogin(r, "Account login failed", log.Username(username), log.Reason(loginError))
+			metrics.Logins.Inc(metrics.ResultFailure)
+			RecordLoginFailure(r, username, "invalid_credentials")
 			return nil, &loginError
 		}
 
+		log.AddRequestAttributes(r, log.Username(user.Username))
 		return user, nil
 	}
 	loginError := validator.config.GetInvalidCredentialsMessage()
 	return nil, &loginError
 }
 
+// ValidateFormOtp checks the one-time password of a login form against the TOTP secret of the user,
+// a password is only accepted once by rejecting time steps at or before the last accepted one of the user.
+// See https://datatracker.ietf.org/doc/html/rfc6238#section-5.2
+func (validator *RequestValidator) ValidateFormOtp(r *http.Request, user *config.User) bool {
+	otp := r.PostFormValue("stopnik_otp")
+	if otp == "" {
+		return false
+	}
+
+	otpKey, otpKeyError := user.GetOtpKey()
+	if otpKeyError != nil {
+		log.AccessLogInvalidLogin(r, "One-time password invalid", log.Username(user.Username))
+		RecordLoginFailure(r, user.Username, "invalid_otp")
+		return false
+	}
+
+	step, valid := crypto.ValidateTotp(otpKey, otp, validator.now())
+	if !valid {
+		log.AccessLogInvalidLogin(r, "One-time password invalid", log.Username(user.Username))
+		RecordLoginFailure(r, user.Username, "invalid_otp")
+		return false
+	}
+
+	if !validator.useOtpStep(user.Username, step) {
+		log.AccessLogInvalidLogin(r, "One-time password reused", log.Username(user.Username))
+		RecordLoginFailure(r, user.Username, "reused_otp")
+		return false
+	}
+
+	return true
+}
+
+// useOtpStep remembers the time step as last accepted one of the user,
+// returns false when the time step is not after the last accepted one.
+func (validator *RequestValidator) useOtpStep(username string, step int64) bool {
+	otpStepLock.Lock()
+	defer otpStepLock.Unlock()
+	otpSteps := *validator.otpSteps
+	lastStep, exists := otpSteps.Get(username)
+	if exists && step <= *lastStep {
+		return false
+	}
+	otpSteps.Set(username, &step)
+	return true
+}
+
 func (validator *RequestValidator) ValidateClientCredentials(r *http.Request) (*config.Client, bool, bool) {
 	if r.Method == http.MethodPost {
-		log.Debug("Validating client credentials")
+		log.DebugContext(r.Context(), "Validating client credentials")
 		// https://datatracker.ietf.org/doc/html/rfc6749#section-2.3.1
 		clientId, clientSecret, ok := r.BasicAuth()
 		usingFallback := false
@@ -113,7 +206,7 @@ func (validator *RequestValidator) ValidateClientCredentials(r *http.Request) (*
 			clientId = r.PostFormValue(oauth2.ParameterClientId)
 			clientSecret = r.PostFormValue(oauth2.ParameterClientSecret)
 			if clientId != "" {
-				log.Warn("Invalid or missing HTTP Basic authentication, using NOT RECOMMENDED POST from values")
+				log.WarnContext(r.Context(), "Invalid or missing HTTP Basic authentication, using NOT RECOMMENDED POST from values", log.ClientId(clientId))
 				usingFallback = true
 			}
 		}
@@ -127,14 +220,24 @@ func (validator *RequestValidator) ValidateClientCredentials(r *http.Request) (*
 			return nil, usingFallback, false
 		}
 
+		// https://datatracker.ietf.org/doc/html/rfc8705#section-2
+		if _, certificateAuth := client.GetCertificateAuthMethod(); certificateAuth && clientSecret == "" {
+			if !validator.validateClientCertificate(r, client) {
+				return nil, false, false
+			}
+			log.AddRequestAttributes(r, log.ClientId(client.Id))
+			return client, false, true
+		}
+
 		if client.GetClientType() == oauth2.CtPublic && clientSecret == "" {
+			log.AddRequestAttributes(r, log.ClientId(client.Id))
 			return client, usingFallback, true
 		} else if client.GetClientType() == oauth2.CtPublic && clientSecret != "" {
 			return nil, usingFallback, false
 		}
 
 		if !client.PasswordFallbackAllowed && usingFallback {
-			log.Warn("Client password usingFallback denied in configuration for client with id %v", client.Id)
+			log.WarnContext(r.Context(), "Client password fallback denied in configuration", log.ClientId(client.Id))
 			return nil, usingFallback, false
 		}
 
@@ -144,6 +247,7 @@ func (validator *RequestValidator) ValidateClientCredentials(r *http.Request) (*
 			return nil, usingFallback, false
 		}
 
+		log.AddRequestAttributes(r, log.ClientId(client.Id))
 		return client, usingFallback, true
 	}
 	return nil, false, false
@@ -165,3 +269,47 @@ func (validator *RequestValidator) ValidateUserPassword(username string, passwor
 
 	return user, true
 }
+
+// ValidateAuthorizationDetails parses the authorization_details parameter,
+// each entry must be of a configured type and match the schema of the type.
+// See https://datatracker.ietf.org/doc/html/rfc9396#section-5
+func (validator *RequestValidator) ValidateAuthorizationDetails(value string) ([]oauth2.AuthorizationDetail, bool) {
+	authorizationDetails, validAuthorizationDetails := oauth2.ParseAuthorizationDetails(value)
+	if !validAuthorizationDetails {
+		log.Debug("Could not parse parameter", "parameter", oauth2.ParameterAuthorizationDetails)
+		return nil, false
+	}
+
+	for _, authorizationDetail := range authorizationDetails {
+		authorizationDetailType, typeExists := validator.config.GetAuthorizationDetailType(authorizationDetail.GetType())
+		if !typeExists {
+			log.Debug("Unknown authorization details type", "type", authorizationDetail.GetType())
+			return nil, false
+		}
+		schemaError := schema.Validate(authorizationDetailType.Schema, map[string]any(authorizationDetail))
+		if schemaError != nil {
+			log.Debug("Invalid authorization details", "type", authorizationDetail.GetType(), log.Err(schemaError))
+			return nil, false
+		}
+	}
+
+	return authorizationDetails, true
+}
+
+// RecordLoginSuccess records a successful login of the user as metric, audit event and webhook,
+// it must only be called once the login satisfies all requirements, e.g. the required authentication level.
+func RecordLoginSuccess(r *http.Request, user *config.User) {
+	metrics.Logins.Inc(metrics.ResultSuccess)
+	audit.Record(r, &audit.Event{Type: audit.EtLoginSuccess, Outcome: audit.OcSuccess, Username: user.Username})
+	webhook.Publish(r, webhook.EtUserLoggedIn, webhook.Data{Username: user.Username})
+}
+
+// RecordLoginFailure records a failed login of the user as audit event with the reason of the failure.
+func RecordLoginFailure(r *http.Request, username string, reason string) {
+	audit.Record(r, &audit.Event{
+		Type:     audit.EtLoginFailure,
+		Outcome:  audit.OcFailure,
+		Username: username,
+		Details:  map[string]string{"reason": reason},
+	})
+}
@@ -240,6 +240,38 @@ func Test_ValidateClientCredentialsDisabledFallback(t *testing.T) {
 	}
 }
 
+func Test_ValidateAuthorizationDetails(t *testing.T) {
+	createValidationTestConfig(t)
+
+	type authorizationDetailsParameter struct {
+		value string
+		valid bool
+	}
+
+	var authorizationDetailsParameters = []authorizationDetailsParameter{
+		{`[{"type":"payment_initiation","instructedAmount":12.5}]`, true},
+		{`[{"type":"document_sharing","locations":["https://docs.example.com"]}]`, true},
+		{`[{"type":"payment_initiation","instructedAmount":12.5},{"type":"document_sharing"}]`, true},
+		{`[{"type":"payment_initiation"}]`, false},
+		{`[{"type":"payment_initiation","instructedAmount":"12.5"}]`, false},
+		{`[{"type":"account_information"}]`, false},
+		{`{"type":"document_sharing"}`, false},
+	}
+
+	for _, test := range authorizationDetailsParameters {
+		testMessage := fmt.Sprintf("Valid authorization details %s %t", test.value, test.valid)
+		t.Run(testMessage, func(t *testing.T) {
+			requestValidator := NewRequestValidator()
+
+			_, valid := requestValidator.ValidateAuthorizationDetails(test.value)
+
+			if test.valid != valid {
+				t.Errorf("result does not match %t != %t", test.valid, valid)
+			}
+		})
+	}
+}
+
 func createValidationTestConfig(t *testing.T) {
 	testConfig := &config.Config{
 		Clients: []config.Client{
@@ -265,6 +297,21 @@ func createValidationTestConfig(t *testing.T) {
 				Password: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
 			},
 		},
+		AuthorizationDetailTypes: []config.AuthorizationDetailType{
+			{
+				Type: "payment_initiation",
+				Schema: map[string]any{
+					"type":     "object",
+					"required": []any{"instructedAmount"},
+					"properties": map[string]any{
+						"instructedAmount": map[string]any{"type": "number"},
+					},
+				},
+			},
+			{
+				Type: "document_sharing",
+			},
+		},
 	}
 	initializationError := config.Initialize(testConfig)
 	if initializationError != nil {
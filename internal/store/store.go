@@ -1,6 +1,7 @@
 package store
 
 import (
+	"fmt"
 	"github.com/webishdev/stopnik/log"
 	"sync"
 	"time"
@@ -41,6 +42,8 @@ type Store[T any] interface {
 	Get(key string) (*T, bool)
 	// GetValues retrieves all values from the store.
 	GetValues() []*T
+	// Size returns the number of values in the store.
+	Size() int
 }
 
 type ExpiringStore[T any] interface {
@@ -97,7 +100,7 @@ func (ts *timedStore[T]) startCleanUp() {
 
 func (ts *timedStore[T]) cleanUp() {
 	if log.IsDebug() {
-		log.Debug("%s - %T", "Cleaning up", *new(T))
+		log.Debug("Cleaning up", "type", fmt.Sprintf("%T", *new(T)))
 	}
 	if !ts.empty() {
 		now := ts.now()
@@ -131,7 +134,7 @@ func (ts *timedStore[T]) Delete(key string) {
 	ts.mux.Lock()
 	defer ts.mux.Unlock()
 	if log.IsDebug() {
-		log.Debug("Removing %s", key)
+		log.Debug("Removing", "key", key)
 	}
 	delete(ts.storeMap, key)
 }
@@ -172,11 +175,17 @@ func (ts *timedStore[T]) GetValues() []*T {
 	return result
 }
 
+func (ts *timedStore[T]) Size() int {
+	ts.mux.RLock()
+	defer ts.mux.RUnlock()
+	return len(ts.storeMap)
+}
+
 func (s *store[T]) Delete(key string) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
 	if log.IsDebug() {
-		log.Debug("Removing %s", key)
+		log.Debug("Removing", "key", key)
 	}
 	delete(s.storeMap, key)
 }
@@ -205,3 +214,9 @@ func (s *store[T]) GetValues() []*T {
 
 	return values
 }
+
+func (s *store[T]) Size() int {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return len(s.storeMap)
+}
@@ -139,6 +139,10 @@ func Test_ExpiringStore(t *testing.T) {
 		if len(values) != 1 {
 			t.Error("amount of values did not match")
 		}
+
+		if storeWithTimer.Size() != 1 {
+			t.Error("size did not match")
+		}
 	})
 }
 
@@ -185,6 +189,10 @@ func Test_SimpleStoreSetGetAndDelete(t *testing.T) {
 	if len(values) != 1 {
 		t.Error("amount of values did not match")
 	}
+
+	if simpleStore.Size() != 1 {
+		t.Error("size did not match")
+	}
 }
 
 func Test_CreateTimer(t *testing.T) {
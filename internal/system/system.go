@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sync"
 	"syscall"
 	"time"
@@ -52,6 +53,37 @@ func Error(err error) {
 	}
 }
 
+// WriteFile writes data to a temporary file in the directory of the named file and renames it to the named file afterward,
+// therefore the named file either contains the previous or the new data, but never partially written data.
+func WriteFile(name string, data []byte, perm os.FileMode) error {
+	tempFile, createError := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if createError != nil {
+		return createError
+	}
+	tempName := tempFile.Name()
+
+	_, writeError := tempFile.Write(data)
+	if writeError == nil {
+		writeError = tempFile.Sync()
+	}
+	closeError := tempFile.Close()
+	if writeError == nil {
+		writeError = closeError
+	}
+	if writeError == nil {
+		writeError = os.Chmod(tempName, perm)
+	}
+	if writeError == nil {
+		writeError = os.Rename(tempName, name)
+	}
+	if writeError != nil {
+		_ = os.Remove(tempName)
+		return writeError
+	}
+
+	return nil
+}
+
 // ConfigureExit allows to overwrite the used os.Exit function. Used in tests.
 func ConfigureExit(newFunc func(code int)) {
 	exitFunc = newFunc
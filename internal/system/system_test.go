@@ -3,6 +3,7 @@ package system
 import (
 	"errors"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -64,3 +65,44 @@ func Test_StartTime(t *testing.T) {
 		t.Error("start time should match")
 	}
 }
+
+func Test_WriteFile(t *testing.T) {
+	directory := t.TempDir()
+	name := filepath.Join(directory, "data.json")
+
+	for _, data := range []string{"foo", "bar"} {
+		writeError := WriteFile(name, []byte(data), 0o600)
+		if writeError != nil {
+			t.Fatal(writeError)
+		}
+
+		content, readError := os.ReadFile(name)
+		if readError != nil {
+			t.Fatal(readError)
+		}
+		if string(content) != data {
+			t.Errorf("file content did not match: got %s want %s", string(content), data)
+		}
+	}
+
+	info, statError := os.Stat(name)
+	if statError != nil {
+		t.Fatal(statError)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("file permissions did not match: got %v", info.Mode().Perm())
+	}
+
+	entries, readDirError := os.ReadDir(directory)
+	if readDirError != nil {
+		t.Fatal(readDirError)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary files were not removed, got %d entries", len(entries))
+	}
+
+	missingDirectoryError := WriteFile(filepath.Join(directory, "missing", "data.json"), []byte("foo"), 0o600)
+	if missingDirectoryError == nil {
+		t.Error("expected error when writing to a missing directory")
+	}
+}
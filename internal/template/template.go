@@ -3,9 +3,13 @@ package template
 import (
 	"bytes"
 	_ "embed"
+	"encoding/json"
 	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/oauth2"
 	"github.com/webishdev/stopnik/internal/system"
 	"html/template"
+	"net/url"
+	"slices"
 	"sync"
 )
 
@@ -24,13 +28,34 @@ var loginHtml []byte
 //go:embed resources/logout.html
 var logoutHtml []byte
 
+//go:embed resources/select_account.html
+var selectAccountHtml []byte
+
+//go:embed resources/consent.html
+var consentHtml []byte
+
 //go:embed resources/error.html
 var errorHtml []byte
 
+//go:embed resources/form_post.html
+var formPostHtml []byte
+
 type Manager struct {
 	config *config.Config
 }
 
+// formPostParameter is a hidden input of the form post response.
+type formPostParameter struct {
+	Name  string
+	Value string
+}
+
+// authorizationDetail is the displayed representation of oauth2.AuthorizationDetail.
+type authorizationDetail struct {
+	Title string
+	Value string
+}
+
 var templateManagerLock = &sync.Mutex{}
 var templateManagerSingleton *Manager
 
@@ -66,7 +91,10 @@ func addTemplates(main *template.Template) bytes.Buffer {
 	return tpl
 }
 
-func (templateManager *Manager) LoginTemplate(id string, action string, message string) bytes.Buffer {
+// LoginTemplate renders the login page, requested authorization details are displayed to the user.
+// The username is prefilled when provided, the one-time password input is only shown when requested.
+// For requested offline access the user approves or denies the offline access together with the login.
+func (templateManager *Manager) LoginTemplate(id string, action string, message string, username string, otp bool, offlineAccess bool, authorizationDetails ...oauth2.AuthorizationDetail) bytes.Buffer {
 	var tpl bytes.Buffer
 
 	loginTemplate, loginParseError := template.New("login").Parse(string(loginHtml))
@@ -88,9 +116,14 @@ func (templateManager *Manager) LoginTemplate(id string, action string, message
 		FooterText    string
 		ShowMessage   bool
 		Message       string
+		Details       []authorizationDetail
+		Username      string
+		ShowOtp       bool
+		OfflineAccess bool
 	}{
 		Action:        action,
 		Token:         id,
+		Username:      username,
 		HideFooter:    templateManager.config.GetHideFooter(),
 		HideMascot:    templateManager.config.GetHideLogo(),
 		ShowHtmlTitle: templateManager.config.GetHtmlTitle() != "",
@@ -100,6 +133,9 @@ func (templateManager *Manager) LoginTemplate(id string, action string, message
 		FooterText:    templateManager.config.GetFooterText(),
 		ShowMessage:   message != "",
 		Message:       message,
+		Details:       templateManager.getAuthorizationDetails(authorizationDetails),
+		ShowOtp:       otp,
+		OfflineAccess: offlineAccess,
 	}
 
 	templateExecuteError := loginTemplate.Execute(&tpl, data)
@@ -110,7 +146,124 @@ func (templateManager *Manager) LoginTemplate(id string, action string, message
 	return tpl
 }
 
-func (templateManager *Manager) LogoutTemplate(username string, requestURI string) bytes.Buffer {
+// getAuthorizationDetails uses the description of the configured type as title,
+// all other fields of an authorization detail are shown as JSON.
+func (templateManager *Manager) getAuthorizationDetails(authorizationDetails []oauth2.AuthorizationDetail) []authorizationDetail {
+	var result []authorizationDetail
+	for _, currentAuthorizationDetail := range authorizationDetails {
+		title := currentAuthorizationDetail.GetType()
+		authorizationDetailType, typeExists := templateManager.config.GetAuthorizationDetailType(title)
+		if typeExists && authorizationDetailType.Description != "" {
+			title = authorizationDetailType.Description
+		}
+
+		fields := make(map[string]any)
+		for key, value := range currentAuthorizationDetail {
+			if key != "type" {
+				fields[key] = value
+			}
+		}
+		value, marshalError := json.Marshal(fields)
+		if marshalError != nil {
+			system.Error(marshalError)
+		}
+
+		result = append(result, authorizationDetail{Title: title, Value: string(value)})
+	}
+	return result
+}
+
+// SelectAccountTemplate renders the account selection page with the usernames of the current login sessions,
+// see https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest
+func (templateManager *Manager) SelectAccountTemplate(id string, action string, usernames []string) bytes.Buffer {
+	var tpl bytes.Buffer
+
+	selectAccountTemplate, selectAccountParseError := template.New("select_account").Parse(string(selectAccountHtml))
+	if selectAccountParseError != nil {
+		system.Error(selectAccountParseError)
+	}
+
+	addTemplates(selectAccountTemplate)
+
+	data := struct {
+		Action        string
+		Token         string
+		Usernames     []string
+		HideFooter    bool
+		HideMascot    bool
+		ShowHtmlTitle bool
+		HtmlTitle     string
+		ShowTitle     bool
+		Title         string
+		FooterText    string
+	}{
+		Action:        action,
+		Token:         id,
+		Usernames:     usernames,
+		HideFooter:    templateManager.config.GetHideFooter(),
+		HideMascot:    templateManager.config.GetHideLogo(),
+		ShowHtmlTitle: templateManager.config.GetHtmlTitle() != "",
+		HtmlTitle:     templateManager.config.GetHtmlTitle(),
+		ShowTitle:     templateManager.config.GetTitle() != "",
+		Title:         templateManager.config.GetTitle(),
+		FooterText:    templateManager.config.GetFooterText(),
+	}
+
+	templateExecuteError := selectAccountTemplate.Execute(&tpl, data)
+	if templateExecuteError != nil {
+		system.Error(templateExecuteError)
+	}
+
+	return tpl
+}
+
+// ConsentTemplate renders the consent page for offline access requested for the user of the current login session,
+// see https://openid.net/specs/openid-connect-core-1_0.html#OfflineAccess
+func (templateManager *Manager) ConsentTemplate(id string, action string, username string) bytes.Buffer {
+	var tpl bytes.Buffer
+
+	consentTemplate, consentParseError := template.New("consent").Parse(string(consentHtml))
+	if consentParseError != nil {
+		system.Error(consentParseError)
+	}
+
+	addTemplates(consentTemplate)
+
+	data := struct {
+		Action        string
+		Token         string
+		Username      string
+		HideFooter    bool
+		HideMascot    bool
+		ShowHtmlTitle bool
+		HtmlTitle     string
+		ShowTitle     bool
+		Title         string
+		FooterText    string
+	}{
+		Action:        action,
+		Token:         id,
+		Username:      username,
+		HideFooter:    templateManager.config.GetHideFooter(),
+		HideMascot:    templateManager.config.GetHideLogo(),
+		ShowHtmlTitle: templateManager.config.GetHtmlTitle() != "",
+		HtmlTitle:     templateManager.config.GetHtmlTitle(),
+		ShowTitle:     templateManager.config.GetTitle() != "",
+		Title:         templateManager.config.GetTitle(),
+		FooterText:    templateManager.config.GetFooterText(),
+	}
+
+	templateExecuteError := consentTemplate.Execute(&tpl, data)
+	if templateExecuteError != nil {
+		system.Error(templateExecuteError)
+	}
+
+	return tpl
+}
+
+// LogoutTemplate renders the account page of a logged-in user,
+// the clients with offline access can be revoked with the given token.
+func (templateManager *Manager) LogoutTemplate(username string, requestURI string, token string, offlineClientIds []string) bytes.Buffer {
 	var tpl bytes.Buffer
 
 	logoutTemplate, logoutParseError := template.New("logout").Parse(string(logoutHtml))
@@ -123,6 +276,8 @@ func (templateManager *Manager) LogoutTemplate(username string, requestURI strin
 	data := struct {
 		Username      string
 		RequestURI    string
+		Token         string
+		OfflineAccess []string
 		HideFooter    bool
 		HideMascot    bool
 		ShowHtmlTitle bool
@@ -133,6 +288,8 @@ func (templateManager *Manager) LogoutTemplate(username string, requestURI strin
 	}{
 		Username:      username,
 		RequestURI:    requestURI,
+		Token:         token,
+		OfflineAccess: offlineClientIds,
 		HideFooter:    templateManager.config.GetHideFooter(),
 		HideMascot:    templateManager.config.GetHideLogo(),
 		ShowHtmlTitle: templateManager.config.GetHtmlTitle() != "",
@@ -187,3 +344,41 @@ func (templateManager *Manager) ErrorTemplate(message string) bytes.Buffer {
 
 	return tpl
 }
+
+// FormPostTemplate renders a page which submits the parameters to the action with the browser,
+// see https://openid.net/specs/oauth-v2-form-post-response-mode-1_0.html#FormPostResponseMode
+func (templateManager *Manager) FormPostTemplate(action string, parameters url.Values) bytes.Buffer {
+	var tpl bytes.Buffer
+
+	formPostTemplate, formPostParseError := template.New("form_post").Parse(string(formPostHtml))
+	if formPostParseError != nil {
+		system.Error(formPostParseError)
+	}
+
+	var formPostParameters []formPostParameter
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		for _, value := range parameters[name] {
+			formPostParameters = append(formPostParameters, formPostParameter{Name: name, Value: value})
+		}
+	}
+
+	data := struct {
+		Action     string
+		Parameters []formPostParameter
+	}{
+		Action:     action,
+		Parameters: formPostParameters,
+	}
+
+	templateExecuteError := formPostTemplate.Execute(&tpl, data)
+	if templateExecuteError != nil {
+		system.Error(templateExecuteError)
+	}
+
+	return tpl
+}
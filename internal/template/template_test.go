@@ -2,6 +2,8 @@ package template
 
 import (
 	"github.com/webishdev/stopnik/internal/config"
+	"github.com/webishdev/stopnik/internal/oauth2"
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -21,6 +23,9 @@ func Test_Template(t *testing.T) {
 				Password: "d82c4eb5261cb9c8aa9855edd67d1bd10482f41529858d925094d173fa662aa91ff39bc5b188615273484021dfb16fd8284cf684ccf0fc795be3aa2fc1e6c181",
 			},
 		},
+		AuthorizationDetailTypes: []config.AuthorizationDetailType{
+			{Type: "payment_initiation", Description: "Payment initiation"},
+		},
 	}
 
 	initializationError := config.Initialize(testConfig)
@@ -31,7 +36,7 @@ func Test_Template(t *testing.T) {
 	templateManager := GetTemplateManagerInstance()
 
 	t.Run("Login", func(t *testing.T) {
-		loginTemplateBuffer := templateManager.LoginTemplate("foo", "/some/post", "")
+		loginTemplateBuffer := templateManager.LoginTemplate("foo", "/some/post", "", "", false, false)
 
 		result := loginTemplateBuffer.String()
 
@@ -43,8 +48,72 @@ func Test_Template(t *testing.T) {
 		assertContains(t, result, "<input type=\"hidden\" name=\"stopnik_auth_session\" value=\"foo\" />")
 	})
 
+	t.Run("Login with authorization details", func(t *testing.T) {
+		authorizationDetails := []oauth2.AuthorizationDetail{
+			{"type": "payment_initiation", "actions": []any{"initiate"}},
+			{"type": "document_sharing"},
+		}
+		loginTemplateBuffer := templateManager.LoginTemplate("foo", "/some/post", "", "", false, false, authorizationDetails...)
+
+		result := loginTemplateBuffer.String()
+
+		assertContains(t, result, "<strong>Payment initiation</strong>")
+		assertContains(t, result, "{&#34;actions&#34;:[&#34;initiate&#34;]}")
+		assertContains(t, result, "<strong>document_sharing</strong>")
+	})
+
+	t.Run("Login with username and one-time password", func(t *testing.T) {
+		loginTemplateBuffer := templateManager.LoginTemplate("foo", "/some/post", "", "bar", true, false)
+
+		result := loginTemplateBuffer.String()
+
+		assertContains(t, result, "name=\"stopnik_username\" value=\"bar\"")
+		assertContains(t, result, "name=\"stopnik_otp\"")
+	})
+
+	t.Run("Login with offline access", func(t *testing.T) {
+		loginTemplateBuffer := templateManager.LoginTemplate("foo", "/some/post", "", "", false, true)
+
+		result := loginTemplateBuffer.String()
+
+		assertContains(t, result, "Requested offline access")
+		assertContains(t, result, "<select id=\"stopnik_consent\" name=\"stopnik_consent\">")
+	})
+
+	t.Run("Consent", func(t *testing.T) {
+		consentTemplateBuffer := templateManager.ConsentTemplate("foo", "/some/post", "bar")
+
+		result := consentTemplateBuffer.String()
+
+		assertContains(t, result, "<input type=\"hidden\" name=\"stopnik_auth_session\" value=\"foo\" />")
+		assertContains(t, result, "value=\"bar\" readonly")
+		assertContains(t, result, "<button type=\"submit\" name=\"stopnik_consent\" value=\"approve\">")
+		assertContains(t, result, "<button type=\"submit\" name=\"stopnik_consent\" value=\"deny\">")
+	})
+
+	t.Run("Select account", func(t *testing.T) {
+		selectAccountTemplateBuffer := templateManager.SelectAccountTemplate("foo", "/some/post", []string{"bar", "moo"})
+
+		result := selectAccountTemplateBuffer.String()
+
+		assertContains(t, result, "<input type=\"hidden\" name=\"stopnik_auth_session\" value=\"foo\" />")
+		assertContains(t, result, "<button type=\"submit\" name=\"stopnik_account\" value=\"bar\">bar</button>")
+		assertContains(t, result, "<button type=\"submit\" name=\"stopnik_account\" value=\"moo\">moo</button>")
+		assertContains(t, result, "<button type=\"submit\" name=\"stopnik_account\" value=\"\">Use another account</button>")
+	})
+
+	t.Run("Form post", func(t *testing.T) {
+		formPostTemplateBuffer := templateManager.FormPostTemplate("https://example.com/callback", url.Values{"code": {"abc"}, "state": {"x&y"}})
+
+		result := formPostTemplateBuffer.String()
+
+		assertContains(t, result, "<form method=\"POST\" action=\"https://example.com/callback\">")
+		assertContains(t, result, "<input type=\"hidden\" name=\"code\" value=\"abc\" />")
+		assertContains(t, result, "<input type=\"hidden\" name=\"state\" value=\"x&amp;y\" />")
+	})
+
 	t.Run("Logout", func(t *testing.T) {
-		logoutTemplateBuffer := templateManager.LogoutTemplate("foo", "/some/value")
+		logoutTemplateBuffer := templateManager.LogoutTemplate("foo", "/some/value", "", nil)
 
 		result := logoutTemplateBuffer.String()
 
@@ -55,6 +124,16 @@ func Test_Template(t *testing.T) {
 		assertContains(t, result, "<form method=\"POST\" action=\"logout\">")
 		assertContains(t, result, "<input type=\"hidden\" name=\"stopnik_logout_redirect\" value=\"/some/value\" />")
 	})
+
+	t.Run("Logout with offline access", func(t *testing.T) {
+		logoutTemplateBuffer := templateManager.LogoutTemplate("foo", "/some/value", "bar", []string{"moo"})
+
+		result := logoutTemplateBuffer.String()
+
+		assertContains(t, result, "<form method=\"POST\" action=\"account\">")
+		assertContains(t, result, "<input type=\"hidden\" name=\"stopnik_auth_session\" value=\"bar\" />")
+		assertContains(t, result, "<button type=\"submit\" name=\"stopnik_revoke_client\" value=\"moo\">Revoke moo</button>")
+	})
 }
 
 func assertContains(t *testing.T, value string, contains string) {
@@ -0,0 +1,4 @@
+// Package tracing implements a small OpenTelemetry compatible tracer.
+// Incoming W3C trace context is propagated and finished spans can be exported
+// either to stdout or to an OTLP/HTTP collector.
+package tracing
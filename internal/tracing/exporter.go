@@ -0,0 +1,276 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/webishdev/stopnik/log"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const scopeName = "github.com/webishdev/stopnik"
+
+// Span status codes as defined by OpenTelemetry.
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+type attributeValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type attribute struct {
+	Key   string         `json:"key"`
+	Value attributeValue `json:"value"`
+}
+
+type spanStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// spanData is the OTLP/JSON representation of a Span,
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type spanData struct {
+	TraceId           string      `json:"traceId"`
+	SpanId            string      `json:"spanId"`
+	ParentSpanId      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              SpanKind    `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []attribute `json:"attributes,omitempty"`
+	Status            spanStatus  `json:"status"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []spanData `json:"spans"`
+}
+
+type resource struct {
+	Attributes []attribute `json:"attributes"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type writerExporter struct {
+	writer io.Writer
+	mux    *sync.Mutex
+}
+
+type otlpExporter struct {
+	endpoint    string
+	serviceName string
+	client      *http.Client
+	spans       chan *Span
+	stop        chan struct{}
+	done        chan struct{}
+	batchSize   int
+	interval    time.Duration
+	once        *sync.Once
+}
+
+// NewWriterExporter creates an Exporter which writes each finished span as a single line of JSON.
+func NewWriterExporter(writer io.Writer) Exporter {
+	return &writerExporter{
+		writer: writer,
+		mux:    &sync.Mutex{},
+	}
+}
+
+// NewOTLPExporter creates an Exporter which sends batches of finished spans
+// to an OTLP/HTTP collector using the JSON encoding.
+func NewOTLPExporter(endpoint string, serviceName string) Exporter {
+	exporter := &otlpExporter{
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		spans:       make(chan *Span, 2048),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		batchSize:   512,
+		interval:    5 * time.Second,
+		once:        &sync.Once{},
+	}
+	go exporter.run()
+	return exporter
+}
+
+func (exporter *writerExporter) Export(span *Span) {
+	data, marshalError := json.Marshal(span.data())
+	if marshalError != nil {
+		log.Error("Failed to marshal span", log.Err(marshalError))
+		return
+	}
+	exporter.mux.Lock()
+	defer exporter.mux.Unlock()
+	_, writeError := exporter.writer.Write(append(data, '\n'))
+	if writeError != nil {
+		log.Error("Failed to write span", log.Err(writeError))
+	}
+}
+
+func (exporter *writerExporter) Shutdown() {
+	// nothing to flush
+}
+
+func (exporter *otlpExporter) Export(span *Span) {
+	// the spans channel is never closed, spans ending after a shutdown are dropped
+	select {
+	case <-exporter.stop:
+		return
+	default:
+	}
+	select {
+	case exporter.spans <- span:
+	default:
+		log.Warn("Span queue full, dropping span", "span_id", span.spanContext.SpanId.String())
+	}
+}
+
+func (exporter *otlpExporter) Shutdown() {
+	exporter.once.Do(func() {
+		close(exporter.stop)
+		<-exporter.done
+	})
+}
+
+func (exporter *otlpExporter) run() {
+	defer close(exporter.done)
+	ticker := time.NewTicker(exporter.interval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, exporter.batchSize)
+	for {
+		select {
+		case <-exporter.stop:
+			exporter.send(exporter.drain(batch))
+			return
+		case span := <-exporter.spans:
+			batch = append(batch, span)
+			if len(batch) >= exporter.batchSize {
+				exporter.send(batch)
+				batch = make([]*Span, 0, exporter.batchSize)
+			}
+		case <-ticker.C:
+			exporter.send(batch)
+			batch = make([]*Span, 0, exporter.batchSize)
+		}
+	}
+}
+
+// drain appends all queued spans to the batch without blocking.
+func (exporter *otlpExporter) drain(batch []*Span) []*Span {
+	for {
+		select {
+		case span := <-exporter.spans:
+			batch = append(batch, span)
+		default:
+			return batch
+		}
+	}
+}
+
+func (exporter *otlpExporter) send(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+	spans := make([]spanData, 0, len(batch))
+	for _, span := range batch {
+		spans = append(spans, span.data())
+	}
+	request := exportRequest{
+		ResourceSpans: []resourceSpans{
+			{
+				Resource: resource{
+					Attributes: toAttributes(map[string]any{"service.name": exporter.serviceName}),
+				},
+				ScopeSpans: []scopeSpans{
+					{
+						Scope: scope{Name: scopeName},
+						Spans: spans,
+					},
+				},
+			},
+		},
+	}
+	body, marshalError := json.Marshal(request)
+	if marshalError != nil {
+		log.Error("Failed to marshal spans", log.Err(marshalError))
+		return
+	}
+	response, postError := exporter.client.Post(exporter.endpoint, "application/json", bytes.NewReader(body))
+	if postError != nil {
+		log.Error("Failed to export spans", "endpoint", exporter.endpoint, log.Err(postError))
+		return
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode >= http.StatusBadRequest {
+		log.Error("Failed to export spans", "endpoint", exporter.endpoint, "status", response.StatusCode)
+	}
+}
+
+func (span *Span) data() spanData {
+	span.mux.Lock()
+	defer span.mux.Unlock()
+	data := spanData{
+		TraceId:           span.spanContext.TraceId.String(),
+		SpanId:            span.spanContext.SpanId.String(),
+		Name:              span.name,
+		Kind:              span.kind,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		Attributes:        toAttributes(span.attributes),
+		Status:            spanStatus{Code: statusUnset},
+	}
+	if span.parentSpanId.IsValid() {
+		data.ParentSpanId = span.parentSpanId.String()
+	}
+	if span.errorMessage != "" {
+		data.Status = spanStatus{Code: statusError, Message: span.errorMessage}
+	}
+	return data
+}
+
+func toAttributes(values map[string]any) []attribute {
+	attributes := make([]attribute, 0, len(values))
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		var value attributeValue
+		switch v := values[key].(type) {
+		case string:
+			value.StringValue = &v
+		case bool:
+			value.BoolValue = &v
+		case int:
+			intValue := strconv.Itoa(v)
+			value.IntValue = &intValue
+		default:
+			stringValue := fmt.Sprintf("%v", v)
+			value.StringValue = &stringValue
+		}
+		attributes = append(attributes, attribute{Key: key, Value: value})
+	}
+	return attributes
+}
@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_OTLPExporter(t *testing.T) {
+	received := make(chan exportRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request exportRequest
+		decodeError := json.NewDecoder(r.Body).Decode(&request)
+		if decodeError != nil {
+			t.Error(decodeError)
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("expected JSON content type, got %s", r.Header.Get("Content-Type"))
+		}
+		received <- request
+	}))
+	defer collector.Close()
+
+	Configure("my-stopnik", NewOTLPExporter(collector.URL, "my-stopnik"))
+
+	_, serverSpan := StartServerSpan(httptest.NewRequest(http.MethodGet, "/foo", nil))
+	serverSpan.End()
+
+	Shutdown()
+
+	request := <-received
+
+	if len(request.ResourceSpans) != 1 {
+		t.Fatalf("expected one resource span, got %d", len(request.ResourceSpans))
+	}
+
+	resourceAttributes := request.ResourceSpans[0].Resource.Attributes
+	if len(resourceAttributes) != 1 || resourceAttributes[0].Key != "service.name" || *resourceAttributes[0].Value.StringValue != "my-stopnik" {
+		t.Errorf("resource attributes did not match, %v", resourceAttributes)
+	}
+
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 || spans[0].SpanId != serverSpan.SpanContext().SpanId.String() {
+		t.Errorf("exported spans did not match, %v", spans)
+	}
+}
+
+func Test_OTLPExporterExportAfterShutdown(t *testing.T) {
+	exporter := NewOTLPExporter("http://localhost:0", "my-stopnik")
+	Configure("my-stopnik", exporter)
+
+	_, serverSpan := StartServerSpan(httptest.NewRequest(http.MethodGet, "/foo", nil))
+
+	Shutdown()
+
+	serverSpan.End()
+	exporter.Export(serverSpan)
+}
@@ -0,0 +1,295 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceParent is the W3C trace context header, https://www.w3.org/TR/trace-context/
+const TraceParent = "traceparent"
+
+type SpanKind int
+
+// Span kinds as defined by OpenTelemetry.
+const (
+	SkInternal SpanKind = 1
+	SkServer   SpanKind = 2
+)
+
+type TraceId [16]byte
+type SpanId [8]byte
+
+// SpanContext identifies a span inside a trace.
+type SpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+	Sampled bool
+}
+
+// Span represents a single operation inside a trace.
+// All methods can be called on a nil Span, which makes disabled tracing a no-op for callers.
+type Span struct {
+	tracer       *Tracer
+	name         string
+	kind         SpanKind
+	spanContext  SpanContext
+	parentSpanId SpanId
+	start        time.Time
+	end          time.Time
+	attributes   map[string]any
+	errorMessage string
+	mux          *sync.Mutex
+}
+
+// Exporter receives finished spans.
+type Exporter interface {
+	Export(span *Span)
+	Shutdown()
+}
+
+// Tracer creates spans and hands them to an Exporter when they end.
+type Tracer struct {
+	serviceName string
+	exporter    Exporter
+	now         func() time.Time
+}
+
+type spanContextKey struct{}
+
+var tracerLock = &sync.Mutex{}
+var tracerSingleton *Tracer
+
+// Configure sets the Tracer used by STOPnik. Providing a nil Exporter disables tracing.
+func Configure(serviceName string, exporter Exporter) {
+	tracerLock.Lock()
+	defer tracerLock.Unlock()
+	if tracerSingleton != nil {
+		tracerSingleton.exporter.Shutdown()
+		tracerSingleton = nil
+	}
+	if exporter != nil {
+		tracerSingleton = &Tracer{
+			serviceName: serviceName,
+			exporter:    exporter,
+			now:         time.Now,
+		}
+	}
+}
+
+// Shutdown flushes and disables the current Tracer.
+func Shutdown() {
+	Configure("", nil)
+}
+
+// GetTracerInstance returns the current Tracer, nil when tracing is disabled.
+func GetTracerInstance() *Tracer {
+	tracerLock.Lock()
+	defer tracerLock.Unlock()
+	return tracerSingleton
+}
+
+// StartServerSpan starts a new server span for an incoming HTTP request.
+// A valid traceparent header will be used as parent of the new span.
+func StartServerSpan(r *http.Request) (context.Context, *Span) {
+	tracer := GetTracerInstance()
+	if tracer == nil {
+		return r.Context(), nil
+	}
+	parent, parentExists := ParseTraceParent(r.Header.Get(TraceParent))
+	if !parentExists {
+		parent = SpanContext{TraceId: newTraceId(), Sampled: true}
+	}
+	span := tracer.newSpan(r.Method, SkServer, parent)
+	span.SetAttribute("http.request.method", r.Method)
+	span.SetAttribute("url.path", r.URL.Path)
+	return context.WithValue(r.Context(), spanContextKey{}, span), span
+}
+
+// Start starts a new internal span as child of the span found in the given context.
+// When no span exists in the context no span will be started and nil is returned.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	tracer := GetTracerInstance()
+	parent := FromContext(ctx)
+	if tracer == nil || parent == nil {
+		return ctx, nil
+	}
+	span := tracer.newSpan(name, SkInternal, parent.spanContext)
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// FromContext returns the current span from the given context.
+func FromContext(ctx context.Context) *Span {
+	span, ok := ctx.Value(spanContextKey{}).(*Span)
+	if !ok {
+		return nil
+	}
+	return span
+}
+
+// NewHandler wraps the given http.Handler and starts a span named after the handler type for each request.
+func NewHandler(next http.Handler) http.Handler {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", next), "*")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := Start(r.Context(), name)
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer span.End()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ParseTraceParent parses a W3C traceparent header value.
+// Also returns a bool which indicates, whether the value was valid or not.
+func ParseTraceParent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	var spanContext SpanContext
+	traceIdBytes, traceIdError := hex.DecodeString(parts[1])
+	spanIdBytes, spanIdError := hex.DecodeString(parts[2])
+	flags, flagsError := hex.DecodeString(parts[3])
+	if traceIdError != nil || spanIdError != nil || flagsError != nil || len(traceIdBytes) != 16 || len(spanIdBytes) != 8 || len(flags) != 1 {
+		return SpanContext{}, false
+	}
+	copy(spanContext.TraceId[:], traceIdBytes)
+	copy(spanContext.SpanId[:], spanIdBytes)
+	spanContext.Sampled = flags[0]&1 == 1
+	if !spanContext.TraceId.IsValid() || !spanContext.SpanId.IsValid() {
+		return SpanContext{}, false
+	}
+	return spanContext, true
+}
+
+// TraceParent returns the W3C traceparent header value for the SpanContext.
+func (spanContext SpanContext) TraceParent() string {
+	flags := "00"
+	if spanContext.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", spanContext.TraceId, spanContext.SpanId, flags)
+}
+
+func (traceId TraceId) String() string {
+	return hex.EncodeToString(traceId[:])
+}
+
+// IsValid checks whether the TraceId contains at least one non-zero byte.
+func (traceId TraceId) IsValid() bool {
+	return traceId != TraceId{}
+}
+
+func (spanId SpanId) String() string {
+	return hex.EncodeToString(spanId[:])
+}
+
+// IsValid checks whether the SpanId contains at least one non-zero byte.
+func (spanId SpanId) IsValid() bool {
+	return spanId != SpanId{}
+}
+
+// SetName sets the name of the Span.
+func (span *Span) SetName(name string) {
+	if span == nil {
+		return
+	}
+	span.mux.Lock()
+	defer span.mux.Unlock()
+	span.name = name
+}
+
+// SetAttribute sets an attribute on the Span, supported values are strings, bools and integers.
+func (span *Span) SetAttribute(key string, value any) {
+	if span == nil {
+		return
+	}
+	span.mux.Lock()
+	defer span.mux.Unlock()
+	span.attributes[key] = value
+}
+
+// SetError marks the Span as failed with the given error.
+func (span *Span) SetError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+	span.mux.Lock()
+	defer span.mux.Unlock()
+	span.errorMessage = err.Error()
+}
+
+// SpanContext returns the SpanContext of the Span.
+func (span *Span) SpanContext() SpanContext {
+	if span == nil {
+		return SpanContext{}
+	}
+	return span.spanContext
+}
+
+// End finishes the Span and exports it, when sampled.
+// Calling End more than once has no effect.
+func (span *Span) End() {
+	if span == nil {
+		return
+	}
+	span.mux.Lock()
+	if !span.end.IsZero() {
+		span.mux.Unlock()
+		return
+	}
+	span.end = span.tracer.now()
+	span.mux.Unlock()
+	if span.spanContext.Sampled {
+		span.tracer.exporter.Export(span)
+	}
+}
+
+func (tracer *Tracer) newSpan(name string, kind SpanKind, parent SpanContext) *Span {
+	return &Span{
+		tracer: tracer,
+		name:   name,
+		kind:   kind,
+		spanContext: SpanContext{
+			TraceId: parent.TraceId,
+			SpanId:  newSpanId(),
+			Sampled: parent.Sampled,
+		},
+		parentSpanId: parent.SpanId,
+		start:        tracer.now(),
+		attributes:   make(map[string]any),
+		mux:          &sync.Mutex{},
+	}
+}
+
+// ErrorStatus returns an error for HTTP status codes which should mark a server span as failed.
+func ErrorStatus(status int) error {
+	if status >= http.StatusInternalServerError {
+		return errors.New(http.StatusText(status))
+	}
+	return nil
+}
+
+func newTraceId() TraceId {
+	var traceId TraceId
+	for !traceId.IsValid() {
+		_, _ = rand.Read(traceId[:])
+	}
+	return traceId
+}
+
+func newSpanId() SpanId {
+	var spanId SpanId
+	for !spanId.IsValid() {
+		_, _ = rand.Read(spanId[:])
+	}
+	return spanId
+}
@@ -0,0 +1,178 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_ParseTraceParent(t *testing.T) {
+	type parameter struct {
+		value           string
+		valid           bool
+		expectedSampled bool
+	}
+
+	var parameters = []parameter{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-foo", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-foo", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", false, false},
+		{"00-xyz92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"", false, false},
+	}
+
+	for _, test := range parameters {
+		testMessage := fmt.Sprintf("Parse traceparent %s", test.value)
+		t.Run(testMessage, func(t *testing.T) {
+			spanContext, valid := ParseTraceParent(test.value)
+			if valid != test.valid {
+				t.Errorf("expected valid to be %v", test.valid)
+			}
+			if valid && spanContext.Sampled != test.expectedSampled {
+				t.Errorf("expected sampled to be %v", test.expectedSampled)
+			}
+			if valid && test.value[0:2] == "00" && spanContext.TraceParent() != test.value {
+				t.Errorf("expected traceparent %s, got %s", test.value, spanContext.TraceParent())
+			}
+		})
+	}
+}
+
+func Test_NilSpan(t *testing.T) {
+	Shutdown()
+
+	request := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	ctx, serverSpan := StartServerSpan(request)
+	if serverSpan != nil {
+		t.Error("expected no server span when tracing is disabled")
+	}
+
+	_, span := Start(ctx, "foo")
+	if span != nil {
+		t.Error("expected no span when tracing is disabled")
+	}
+
+	span.SetName("bar")
+	span.SetAttribute("foo", "bar")
+	span.SetError(errors.New("foo"))
+	span.End()
+
+	if span.SpanContext().TraceId.IsValid() {
+		t.Error("expected invalid trace id for nil span")
+	}
+}
+
+func Test_Spans(t *testing.T) {
+	var buf bytes.Buffer
+	Configure("stopnik", NewWriterExporter(&buf))
+	defer Shutdown()
+
+	_, orphan := Start(context.Background(), "orphan")
+	if orphan != nil {
+		t.Error("expected no span without parent")
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	request.Header.Set(TraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, serverSpan := StartServerSpan(request)
+	_, childSpan := Start(ctx, "child")
+	childSpan.SetAttribute("client.id", "foo")
+	childSpan.SetError(errors.New("failed"))
+	childSpan.End()
+	childSpan.End()
+	serverSpan.SetName("GET /foo")
+	serverSpan.End()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 exported spans, got %d", len(lines))
+	}
+
+	var child, server spanData
+	if unmarshalError := json.Unmarshal([]byte(lines[0]), &child); unmarshalError != nil {
+		t.Fatal(unmarshalError)
+	}
+	if unmarshalError := json.Unmarshal([]byte(lines[1]), &server); unmarshalError != nil {
+		t.Fatal(unmarshalError)
+	}
+
+	if server.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentSpanId != "00f067aa0ba902b7" {
+		t.Errorf("server span did not continue incoming trace, %v", server)
+	}
+
+	if server.Name != "GET /foo" || server.Kind != SkServer {
+		t.Errorf("server span name or kind did not match, %v", server)
+	}
+
+	if child.TraceId != server.TraceId || child.ParentSpanId != server.SpanId || child.Kind != SkInternal {
+		t.Errorf("child span is not a child of server span, %v", child)
+	}
+
+	if child.Status.Code != statusError || child.Status.Message != "failed" {
+		t.Errorf("child span status did not match, %v", child.Status)
+	}
+
+	if len(child.Attributes) != 1 || child.Attributes[0].Key != "client.id" || *child.Attributes[0].Value.StringValue != "foo" {
+		t.Errorf("child span attributes did not match, %v", child.Attributes)
+	}
+}
+
+func Test_NotSampled(t *testing.T) {
+	var buf bytes.Buffer
+	Configure("stopnik", NewWriterExporter(&buf))
+	defer Shutdown()
+
+	request := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	request.Header.Set(TraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	_, serverSpan := StartServerSpan(request)
+	serverSpan.End()
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no exported spans, got %s", buf.String())
+	}
+}
+
+func Test_Handler(t *testing.T) {
+	var buf bytes.Buffer
+	Configure("stopnik", NewWriterExporter(&buf))
+	defer Shutdown()
+
+	var handlerSpan *Span
+	handler := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerSpan = FromContext(r.Context())
+	}))
+
+	request := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	ctx, serverSpan := StartServerSpan(request)
+	handler.ServeHTTP(httptest.NewRecorder(), request.WithContext(ctx))
+	serverSpan.End()
+
+	if handlerSpan == nil || handlerSpan == serverSpan {
+		t.Fatal("expected handler span")
+	}
+
+	if handlerSpan.name != "http.HandlerFunc" {
+		t.Errorf("expected handler span name http.HandlerFunc, got %s", handlerSpan.name)
+	}
+}
+
+func Test_ErrorStatus(t *testing.T) {
+	if ErrorStatus(http.StatusOK) != nil || ErrorStatus(http.StatusNotFound) != nil {
+		t.Error("expected no error for status below 500")
+	}
+
+	if ErrorStatus(http.StatusInternalServerError) == nil {
+		t.Error("expected error for status 500")
+	}
+}
@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/webishdev/stopnik/log"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type delivery struct {
+	id           string
+	eventType    EventType
+	body         []byte
+	subscription *Subscription
+}
+
+// deadLetter is written as one JSON line for each delivery which failed finally.
+type deadLetter struct {
+	Url      string          `json:"url"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Time     time.Time       `json:"time"`
+	Event    json.RawMessage `json:"event"`
+}
+
+// Dispatcher queues events and delivers them to subscriptions in the background.
+type Dispatcher struct {
+	subscriptions []*Subscription
+	client        *http.Client
+	deliveries    chan *delivery
+	maxAttempts   int
+	backoff       time.Duration
+	deadLetters   io.Writer
+	deadLetterMux *sync.Mutex
+	mux           *sync.RWMutex
+	closed        bool
+	ctx           context.Context
+	cancel        context.CancelFunc
+	workers       *sync.WaitGroup
+	once          *sync.Once
+	now           func() time.Time
+}
+
+// NewDispatcher starts a Dispatcher with a queue of the given size.
+// Each delivery is attempted up to maxRetries + 1 times, failed deliveries are written to deadLetters.
+func NewDispatcher(subscriptions []*Subscription, queueSize int, maxRetries int, deadLetters io.Writer) *Dispatcher {
+	return newDispatcher(subscriptions, queueSize, maxRetries, deadLetters, time.Second, 4)
+}
+
+func newDispatcher(subscriptions []*Subscription, queueSize int, maxRetries int, deadLetters io.Writer, backoff time.Duration, workers int) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	dispatcher := &Dispatcher{
+		subscriptions: subscriptions,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		deliveries:    make(chan *delivery, queueSize),
+		maxAttempts:   maxRetries + 1,
+		backoff:       backoff,
+		deadLetters:   deadLetters,
+		deadLetterMux: &sync.Mutex{},
+		mux:           &sync.RWMutex{},
+		ctx:           ctx,
+		cancel:        cancel,
+		workers:       &sync.WaitGroup{},
+		once:          &sync.Once{},
+		now:           time.Now,
+	}
+	for range workers {
+		dispatcher.workers.Add(1)
+		go dispatcher.run()
+	}
+	return dispatcher
+}
+
+// Publish queues the event for all matching subscriptions.
+// When the queue is full the delivery is written to the dead-letter log instead.
+func (dispatcher *Dispatcher) Publish(event *Event) {
+	dispatcher.Deliver(event.Id, event.Type, event)
+}
+
+// Deliver queues any payload as JSON for all subscriptions matching the event type,
+// it allows other packages to send their own events with the retries and signatures of the Dispatcher.
+func (dispatcher *Dispatcher) Deliver(id string, eventType EventType, payload any) {
+	body, marshalError := json.Marshal(payload)
+	if marshalError != nil {
+		log.Error("Failed to marshal webhook event", "id", id, log.Err(marshalError))
+		return
+	}
+	dispatcher.mux.RLock()
+	defer dispatcher.mux.RUnlock()
+	for _, subscription := range dispatcher.subscriptions {
+		if !subscription.matches(eventType) {
+			continue
+		}
+		current := &delivery{id: id, eventType: eventType, body: body, subscription: subscription}
+		if dispatcher.closed {
+			dispatcher.deadLetter(current, 0, errors.New("dispatcher stopped"))
+			continue
+		}
+		select {
+		case dispatcher.deliveries <- current:
+		default:
+			dispatcher.deadLetter(current, 0, errors.New("queue full"))
+		}
+	}
+}
+
+// Shutdown stops accepting events and waits until queued events are delivered.
+// Deliveries waiting for a retry are written to the dead-letter log.
+func (dispatcher *Dispatcher) Shutdown() {
+	dispatcher.once.Do(func() {
+		dispatcher.mux.Lock()
+		dispatcher.closed = true
+		close(dispatcher.deliveries)
+		dispatcher.mux.Unlock()
+		dispatcher.cancel()
+		dispatcher.workers.Wait()
+		if closer, isCloser := dispatcher.deadLetters.(io.Closer); isCloser {
+			_ = closer.Close()
+		}
+	})
+}
+
+func (dispatcher *Dispatcher) run() {
+	defer dispatcher.workers.Done()
+	for current := range dispatcher.deliveries {
+		dispatcher.deliver(current)
+	}
+}
+
+func (dispatcher *Dispatcher) deliver(current *delivery) {
+	var sendError error
+	for attempt := 1; attempt <= dispatcher.maxAttempts; attempt++ {
+		sendError = dispatcher.send(current)
+		if sendError == nil {
+			return
+		}
+		log.Warn("Webhook delivery failed", "id", current.id, "url", current.subscription.Url, "attempt", attempt, log.Err(sendError))
+		if attempt == dispatcher.maxAttempts {
+			break
+		}
+		timer := time.NewTimer(dispatcher.backoff * time.Duration(1<<(attempt-1)))
+		select {
+		case <-timer.C:
+		case <-dispatcher.ctx.Done():
+			timer.Stop()
+			dispatcher.deadLetter(current, attempt, fmt.Errorf("dispatcher stopped, last error: %w", sendError))
+			return
+		}
+	}
+	dispatcher.deadLetter(current, dispatcher.maxAttempts, sendError)
+}
+
+func (dispatcher *Dispatcher) send(current *delivery) error {
+	request, requestError := http.NewRequest(http.MethodPost, current.subscription.Url, bytes.NewReader(current.body))
+	if requestError != nil {
+		return requestError
+	}
+	timestamp := strconv.FormatInt(dispatcher.now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, string(current.eventType))
+	request.Header.Set(HeaderDelivery, current.id)
+	request.Header.Set(HeaderTimestamp, timestamp)
+	request.Header.Set(HeaderSignature, Sign(current.subscription.Secret, timestamp, current.body))
+
+	response, postError := dispatcher.client.Do(request)
+	if postError != nil {
+		return postError
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	return nil
+}
+
+func (dispatcher *Dispatcher) deadLetter(current *delivery, attempts int, err error) {
+	entry := deadLetter{
+		Url:      current.subscription.Url,
+		Attempts: attempts,
+		Error:    err.Error(),
+		Time:     dispatcher.now().UTC(),
+		Event:    current.body,
+	}
+	data, marshalError := json.Marshal(entry)
+	if marshalError != nil {
+		log.Error("Failed to marshal webhook dead letter", "id", current.id, log.Err(marshalError))
+		return
+	}
+	log.Error("Webhook delivery failed finally", "id", current.id, "url", current.subscription.Url, log.Err(err))
+	if dispatcher.deadLetters == nil {
+		return
+	}
+	dispatcher.deadLetterMux.Lock()
+	defer dispatcher.deadLetterMux.Unlock()
+	_, writeError := dispatcher.deadLetters.Write(append(data, '\n'))
+	if writeError != nil {
+		log.Error("Failed to write webhook dead letter", "id", current.id, log.Err(writeError))
+	}
+}
+
+// Sign returns the value of the signature header, a hex encoded HMAC-SHA256
+// over the timestamp, a dot and the body using the secret of the subscription.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// OpenDeadLetterFile opens the file at the given path for appending dead letters.
+func OpenDeadLetterFile(path string) (io.WriteCloser, error) {
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+}
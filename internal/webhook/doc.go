@@ -0,0 +1,4 @@
+// Package webhook delivers authentication events to configured subscriptions.
+// Payloads are JSON signed with HMAC-SHA256, queued without blocking the request
+// and retried with backoff. Deliveries which finally fail are written to a dead-letter log.
+package webhook
@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"github.com/google/uuid"
+	"github.com/webishdev/stopnik/log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Headers sent with each delivery.
+const (
+	HeaderEvent     = "X-Stopnik-Event"
+	HeaderDelivery  = "X-Stopnik-Delivery"
+	HeaderTimestamp = "X-Stopnik-Timestamp"
+	HeaderSignature = "X-Stopnik-Signature"
+)
+
+type EventType string
+
+const (
+	EtUserLoggedIn  EventType = "user.logged_in"
+	EtTokenIssued   EventType = "token.issued"
+	EtTokenRevoked  EventType = "token.revoked"
+	EtSessionClosed EventType = "session.closed"
+)
+
+// Data contains the event specific values of an Event.
+type Data struct {
+	ClientId  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	GrantType string `json:"grant_type,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+}
+
+// Event is the JSON payload sent to subscriptions.
+type Event struct {
+	Id        string    `json:"id"`
+	Type      EventType `json:"type"`
+	Time      time.Time `json:"time"`
+	RequestId string    `json:"request_id,omitempty"`
+	Data      Data      `json:"data"`
+}
+
+// Subscription receives all events of the given types, or all events when no types are provided.
+type Subscription struct {
+	Url    string
+	Secret string
+	Events []EventType
+}
+
+var dispatcherLock = &sync.Mutex{}
+var dispatcherSingleton *Dispatcher
+
+// Configure sets the Dispatcher used for events. Providing nil disables webhooks.
+func Configure(dispatcher *Dispatcher) {
+	dispatcherLock.Lock()
+	defer dispatcherLock.Unlock()
+	if dispatcherSingleton != nil {
+		dispatcherSingleton.Shutdown()
+	}
+	dispatcherSingleton = dispatcher
+}
+
+// Shutdown delivers queued events and disables webhooks.
+func Shutdown() {
+	Configure(nil)
+}
+
+// GetDispatcherInstance returns the current Dispatcher, nil when webhooks are disabled.
+func GetDispatcherInstance() *Dispatcher {
+	dispatcherLock.Lock()
+	defer dispatcherLock.Unlock()
+	return dispatcherSingleton
+}
+
+// Publish creates an Event and queues it for all matching subscriptions, it never blocks.
+// The request may be nil for events not caused by a request.
+func Publish(r *http.Request, eventType EventType, data Data) {
+	dispatcher := GetDispatcherInstance()
+	if dispatcher == nil {
+		return
+	}
+	event := &Event{
+		Id:   uuid.NewString(),
+		Type: eventType,
+		Time: time.Now().UTC(),
+		Data: data,
+	}
+	if r != nil {
+		event.RequestId = log.GetRequestId(r.Context())
+	}
+	dispatcher.Publish(event)
+}
+
+func (subscription *Subscription) matches(eventType EventType) bool {
+	if len(subscription.Events) == 0 {
+		return true
+	}
+	for _, current := range subscription.Events {
+		if current == eventType {
+			return true
+		}
+	}
+	return false
+}
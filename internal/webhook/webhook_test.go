@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/webishdev/stopnik/log"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Publish(t *testing.T) {
+	type received struct {
+		event     Event
+		signature string
+		timestamp string
+		body      []byte
+	}
+	receivedChannel := make(chan received, 2)
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		event := Event{}
+		unmarshalError := json.Unmarshal(body, &event)
+		if unmarshalError != nil {
+			t.Error(unmarshalError)
+		}
+		if r.Header.Get(HeaderEvent) != string(event.Type) || r.Header.Get(HeaderDelivery) != event.Id {
+			t.Errorf("event headers did not match, %v", r.Header)
+		}
+		receivedChannel <- received{event: event, signature: r.Header.Get(HeaderSignature), timestamp: r.Header.Get(HeaderTimestamp), body: body}
+	}))
+	defer subscriber.Close()
+
+	Configure(NewDispatcher([]*Subscription{
+		{Url: subscriber.URL, Secret: "s3cr3t", Events: []EventType{EtUserLoggedIn}},
+	}, 10, 0, nil))
+
+	request := httptest.NewRequest(http.MethodPost, "/authorize", nil)
+	request = request.WithContext(log.NewRequestContext(request.Context(), "abc-123"))
+
+	Publish(request, EtTokenIssued, Data{ClientId: "foo"})
+	Publish(request, EtUserLoggedIn, Data{Username: "bar"})
+
+	Shutdown()
+
+	if len(receivedChannel) != 1 {
+		t.Fatalf("expected only one subscribed event, got %d", len(receivedChannel))
+	}
+	current := <-receivedChannel
+	if current.event.Type != EtUserLoggedIn || current.event.Data.Username != "bar" || current.event.RequestId != "abc-123" {
+		t.Errorf("event did not match, %v", current.event)
+	}
+	if current.signature != Sign("s3cr3t", current.timestamp, current.body) {
+		t.Errorf("signature did not match, %s", current.signature)
+	}
+	if current.signature == Sign("other", current.timestamp, current.body) {
+		t.Error("signature should depend on secret")
+	}
+}
+
+func Test_Retries(t *testing.T) {
+	attempts := 0
+	delivered := make(chan struct{})
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		close(delivered)
+	}))
+	defer subscriber.Close()
+
+	var deadLetters bytes.Buffer
+	dispatcher := newDispatcher([]*Subscription{{Url: subscriber.URL, Secret: "s3cr3t"}}, 10, 2, &deadLetters, 0, 1)
+
+	dispatcher.Publish(&Event{Id: "1", Type: EtSessionClosed})
+	<-delivered
+	dispatcher.Shutdown()
+
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+	if deadLetters.Len() != 0 {
+		t.Errorf("expected no dead letters, got %s", deadLetters.String())
+	}
+}
+
+func Test_DeadLetters(t *testing.T) {
+	attempts := 0
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer subscriber.Close()
+
+	deadLetters := &channelWriter{lines: make(chan []byte, 1)}
+	dispatcher := newDispatcher([]*Subscription{{Url: subscriber.URL, Secret: "s3cr3t"}}, 10, 1, deadLetters, 0, 1)
+
+	dispatcher.Publish(&Event{Id: "1", Type: EtTokenRevoked})
+	line := <-deadLetters.lines
+	dispatcher.Shutdown()
+
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+
+	entry := deadLetter{}
+	unmarshalError := json.Unmarshal(line, &entry)
+	if unmarshalError != nil {
+		t.Fatalf("expected dead letter, got %s", string(line))
+	}
+	event := Event{}
+	unmarshalError = json.Unmarshal(entry.Event, &event)
+	if unmarshalError != nil {
+		t.Fatalf("expected dead letter event, got %s", string(entry.Event))
+	}
+	if entry.Url != subscriber.URL || entry.Attempts != 2 || event.Id != "1" || entry.Error == "" {
+		t.Errorf("dead letter did not match, %v", entry)
+	}
+}
+
+func Test_QueueFull(t *testing.T) {
+	var deadLetters bytes.Buffer
+	dispatcher := newDispatcher([]*Subscription{{Url: "http://localhost:0", Secret: "s3cr3t"}}, 0, 0, &deadLetters, 0, 0)
+
+	dispatcher.Publish(&Event{Id: "1", Type: EtTokenIssued})
+	dispatcher.Shutdown()
+
+	entry := deadLetter{}
+	unmarshalError := json.Unmarshal(deadLetters.Bytes(), &entry)
+	if unmarshalError != nil {
+		t.Fatalf("expected dead letter, got %s", deadLetters.String())
+	}
+	if entry.Attempts != 0 || entry.Error != "queue full" {
+		t.Errorf("dead letter did not match, %v", entry)
+	}
+}
+
+type channelWriter struct {
+	lines chan []byte
+}
+
+func (writer *channelWriter) Write(p []byte) (int, error) {
+	writer.lines <- append([]byte{}, p...)
+	return len(p), nil
+}
@@ -0,0 +1,240 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Attribute keys used for structured log entries.
+const (
+	KeyRequestId  = "request_id"
+	KeyClientId   = "client_id"
+	KeyUsername   = "username"
+	KeyGrantType  = "grant_type"
+	KeyEndpoint   = "endpoint"
+	KeyStatus     = "status"
+	KeyLatency    = "latency"
+	KeyRemoteAddr = "remote_addr"
+	KeyHost       = "host"
+	KeyMethod     = "method"
+	KeyUrl        = "url"
+	KeySize       = "size"
+)
+
+// requestAttributes collects attributes during a request which are added to the access log entry.
+type requestAttributes struct {
+	requestId  string
+	attributes []slog.Attr
+	mux        *sync.Mutex
+}
+
+type requestAttributesKey struct{}
+
+var accessLogFormat = FormatText
+var accessLogOutput io.Writer = os.Stdout
+
+var accessLogHandler = newDynamicHandler(newHandler(accessLogFormat, accessLogOutput, nil))
+
+var accessLogger = slog.New(accessLogHandler)
+
+// SetAccessLogFormat sets the format of the access log, either text, json or combined.
+// Combined writes the Apache combined log format. Unknown formats will fall back to text.
+func SetAccessLogFormat(format string) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	accessLogFormat = format
+	accessLogHandler.set(newHandler(accessLogFormat, accessLogOutput, nil))
+}
+
+// SetAccessLogOutput sets the writer used for the access log.
+func SetAccessLogOutput(w io.Writer) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	accessLogOutput = w
+	accessLogHandler.set(newHandler(accessLogFormat, accessLogOutput, nil))
+}
+
+// NewRequestContext returns a context which carries the request id and collects
+// attributes added by AddRequestAttributes for the access log entry.
+func NewRequestContext(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestAttributesKey{}, &requestAttributes{
+		requestId: requestId,
+		mux:       &sync.Mutex{},
+	})
+}
+
+// GetRequestId returns the request id from the given context, empty when no id exists.
+func GetRequestId(ctx context.Context) string {
+	current, exists := getRequestAttributes(ctx)
+	if !exists {
+		return ""
+	}
+	return current.requestId
+}
+
+// AddRequestAttributes adds attributes to the access log entry of the given request.
+func AddRequestAttributes(r *http.Request, attrs ...slog.Attr) {
+	current, exists := getRequestAttributes(r.Context())
+	if !exists {
+		return
+	}
+	current.mux.Lock()
+	defer current.mux.Unlock()
+	current.attributes = append(current.attributes, attrs...)
+}
+
+func ClientId(clientId string) slog.Attr {
+	return slog.String(KeyClientId, clientId)
+}
+
+func Username(username string) slog.Attr {
+	return slog.String(KeyUsername, username)
+}
+
+func GrantType(grantType string) slog.Attr {
+	return slog.String(KeyGrantType, grantType)
+}
+
+// AccessLogRequest logs an incoming request, only when debug logging is enabled,
+// because each request is logged by AccessLog when completed.
+func AccessLogRequest(r *http.Request) {
+	if !IsDebug() || isCombined() {
+		return
+	}
+	attrs := append(requestAttrs(r), slog.String(KeyHost, r.Host), slog.String(KeyMethod, r.Method), slog.String(KeyUrl, getUrlFromRequest(r)))
+	accessLogger.LogAttrs(r.Context(), slog.LevelInfo, "Request received", attrs...)
+}
+
+// AccessLog logs a completed request with its status, response size and latency.
+func AccessLog(r *http.Request, endpoint string, status int, size int, latency time.Duration) {
+	if isCombined() {
+		writeCombined(r, status, size, time.Now())
+		return
+	}
+	attrs := append(requestAttrs(r),
+		slog.String(KeyHost, r.Host),
+		slog.String(KeyMethod, r.Method),
+		slog.String(KeyUrl, getUrlFromRequest(r)),
+		slog.String(KeyEndpoint, endpoint),
+		slog.Int(KeyStatus, status),
+		slog.Int(KeySize, size),
+		slog.Duration(KeyLatency, latency),
+	)
+	current, exists := getRequestAttributes(r.Context())
+	if exists {
+		current.mux.Lock()
+		attrs = append(attrs, current.attributes...)
+		current.mux.Unlock()
+	}
+	accessLogger.LogAttrs(r.Context(), slog.LevelInfo, "Request completed", attrs...)
+}
+
+func AccessLogResult(r *http.Request, status int, message string) {
+	attrs := append(requestAttrs(r), slog.String(KeyMethod, r.Method), slog.String(KeyUrl, getUrlFromRequest(r)), slog.Int(KeyStatus, status))
+	resultLogger().LogAttrs(r.Context(), slog.LevelInfo, message, attrs...)
+}
+
+func AccessLogInvalidLogin(r *http.Request, message string, attrs ...slog.Attr) {
+	attrs = append(requestAttrs(r), attrs...)
+	resultLogger().LogAttrs(r.Context(), slog.LevelWarn, message, attrs...)
+}
+
+// resultLogger returns the logger for additional request related entries,
+// these are not part of the Apache combined log format and go to the main log then.
+func resultLogger() *slog.Logger {
+	if isCombined() {
+		return structuredLogger
+	}
+	return accessLogger
+}
+
+func isCombined() bool {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	return accessLogFormat == FormatCombined
+}
+
+func writeCombined(r *http.Request, status int, size int, now time.Time) {
+	username := "-"
+	current, exists := getRequestAttributes(r.Context())
+	if exists {
+		current.mux.Lock()
+		for _, attr := range current.attributes {
+			if attr.Key == KeyUsername && attr.Value.String() != "" {
+				username = attr.Value.String()
+			}
+		}
+		current.mux.Unlock()
+	}
+	sizeValue := "-"
+	if size > 0 {
+		sizeValue = fmt.Sprintf("%d", size)
+	}
+	line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s \"%s\" \"%s\"\n",
+		getHostFromRequest(r), username, now.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method, r.RequestURI, r.Proto, status, sizeValue,
+		orDash(r.Referer()), orDash(r.UserAgent()))
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	_, _ = io.WriteString(accessLogOutput, line)
+}
+
+func requestAttrs(r *http.Request) []slog.Attr {
+	attrs := make([]slog.Attr, 0, 8)
+	requestId := GetRequestId(r.Context())
+	if requestId != "" {
+		attrs = append(attrs, slog.String(KeyRequestId, requestId))
+	}
+	return append(attrs, slog.String(KeyRemoteAddr, getAddrFromRequest(r)))
+}
+
+func getRequestAttributes(ctx context.Context) (*requestAttributes, bool) {
+	current, exists := ctx.Value(requestAttributesKey{}).(*requestAttributes)
+	return current, exists
+}
+
+func getAddrFromRequest(r *http.Request) string {
+	var result []string
+	result = append(result, r.RemoteAddr)
+	forwardedFor := r.Header.Get("X-Forwarded-For")
+	if forwardedFor != "" {
+		result = append(result, "X-Forwarded-For: "+forwardedFor)
+	}
+
+	realIp := r.Header.Get("X-Real-Ip")
+	if realIp != "" {
+		result = append(result, "X-Real-Ip: "+realIp)
+	}
+
+	return strings.Join(result, ", ")
+}
+
+func getHostFromRequest(r *http.Request) string {
+	host, _, splitError := net.SplitHostPort(r.RemoteAddr)
+	if splitError != nil {
+		return orDash(r.RemoteAddr)
+	}
+	return host
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func getUrlFromRequest(r *http.Request) string {
+	if r.URL == nil {
+		return ""
+	}
+	return r.URL.String()
+}
@@ -0,0 +1,82 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_AccessLog(t *testing.T) {
+	defer SetAccessLogOutput(os.Stdout)
+	defer SetAccessLogFormat(FormatText)
+
+	t.Run("JSON format with request attributes", func(t *testing.T) {
+		var buf bytes.Buffer
+		SetAccessLogFormat(FormatJSON)
+		SetAccessLogOutput(&buf)
+
+		request := httptest.NewRequest(http.MethodPost, "/token", nil)
+		request = request.WithContext(NewRequestContext(request.Context(), "abc-123"))
+		AddRequestAttributes(request, ClientId("foo"), GrantType("password"), Username("bar"))
+
+		AccessLog(request, "/token", http.StatusOK, 42, time.Millisecond)
+
+		entry := map[string]any{}
+		unmarshalError := json.Unmarshal(buf.Bytes(), &entry)
+		if unmarshalError != nil {
+			t.Fatalf("Expected JSON log entry, got '%s'", buf.String())
+		}
+
+		expected := map[string]any{
+			KeyRequestId: "abc-123",
+			KeyClientId:  "foo",
+			KeyGrantType: "password",
+			KeyUsername:  "bar",
+			KeyEndpoint:  "/token",
+			KeyMethod:    http.MethodPost,
+			KeyStatus:    float64(http.StatusOK),
+			KeySize:      float64(42),
+		}
+		for key, value := range expected {
+			if entry[key] != value {
+				t.Errorf("Expected %s to be '%v', got '%v'", key, value, entry[key])
+			}
+		}
+	})
+
+	t.Run("Combined format", func(t *testing.T) {
+		var buf bytes.Buffer
+		SetAccessLogFormat(FormatCombined)
+		SetAccessLogOutput(&buf)
+
+		request := httptest.NewRequest(http.MethodGet, "/account?foo=bar", nil)
+		request.Header.Set("User-Agent", "test-agent")
+		request = request.WithContext(NewRequestContext(request.Context(), "abc-123"))
+		AddRequestAttributes(request, Username("bar"))
+
+		AccessLog(request, "/account", http.StatusOK, 0, time.Millisecond)
+
+		value := buf.String()
+		if !strings.HasPrefix(value, "192.0.2.1 - bar [") {
+			t.Errorf("Expected combined log line with host and user, got '%s'", value)
+		}
+		if !strings.HasSuffix(value, "] \"GET /account?foo=bar HTTP/1.1\" 200 - \"-\" \"test-agent\"\n") {
+			t.Errorf("Expected combined log line with request, got '%s'", value)
+		}
+	})
+
+	t.Run("Request attributes without request context", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		AddRequestAttributes(request, ClientId("foo"))
+
+		if GetRequestId(request.Context()) != "" {
+			t.Error("Expected no request id")
+		}
+	})
+}
@@ -1,87 +1,192 @@
 package log
 
 import (
-	"fmt"
+	"context"
+	"io"
 	"log/slog"
-	"net/http"
 	"os"
-	"strings"
+	"sync"
 )
 
+// Supported values for SetFormat and SetAccessLogFormat.
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatCombined = "combined"
+)
+
+// Attribute keys used for structured log entries of the main log.
+const (
+	KeyError  = "error"
+	KeyReason = "reason"
+)
+
+// dynamicHandler delegates to a slog.Handler which can be replaced at runtime,
+// so loggers created once keep working after the format or output changed.
+type dynamicHandler struct {
+	handler slog.Handler
+	mux     *sync.RWMutex
+}
+
 var currentLogLevel slog.Level
+var logLevel = new(slog.LevelVar)
 
-var textHandler = *slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-	//Level: slog.LevelError,
-})
+var outputLock = &sync.Mutex{}
+var logFormat = FormatText
+var logOutput io.Writer = os.Stdout
 
-var accessLogHandler = *slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-	//Level: slog.LevelError,
-})
+var textHandler = newDynamicHandler(newHandler(logFormat, logOutput, &slog.HandlerOptions{Level: logLevel}))
 
-var infoLogger = slog.NewLogLogger(&textHandler, slog.LevelInfo)
-var warnLogger = slog.NewLogLogger(&textHandler, slog.LevelWarn)
-var errorLogger = slog.NewLogLogger(&textHandler, slog.LevelError)
-var debugLogger = slog.NewLogLogger(&textHandler, slog.LevelDebug)
+// requestHandler adds the request id and the attributes collected for a request
+// to entries logged with a request context, see NewRequestContext and AddRequestAttributes.
+type requestHandler struct {
+	slog.Handler
+}
+
+var structuredLogger = slog.New(textHandler)
 
-var accessLogger = slog.NewLogLogger(&accessLogHandler, slog.LevelInfo)
+var logger = slog.New(&requestHandler{Handler: textHandler})
 
 func SetLogLevel(level string) {
 	currentLogLevel = getLogLevelFromString(level)
-	textHandler = *slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
-		Level: currentLogLevel,
-	})
+	logLevel.Set(currentLogLevel)
 }
 
-func Info(format string, v ...any) {
-	infoLogger.Printf(format, v...)
+// SetFormat sets the format of the log output, either text or json.
+// Unknown formats will fall back to text.
+func SetFormat(format string) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	logFormat = format
+	textHandler.set(newHandler(logFormat, logOutput, &slog.HandlerOptions{Level: logLevel}))
 }
 
-func Warn(format string, v ...any) {
-	warnLogger.Printf(format, v...)
+// SetOutput sets the writer used for the log output.
+func SetOutput(w io.Writer) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	logOutput = w
+	textHandler.set(newHandler(logFormat, logOutput, &slog.HandlerOptions{Level: logLevel}))
 }
 
-func Error(format string, v ...any) {
-	errorLogger.Printf(format, v...)
+// Info logs a message at info level, args are key/value pairs or slog.Attr values like for slog.Info.
+func Info(msg string, args ...any) {
+	logger.Info(msg, args...)
 }
 
-func Debug(format string, v ...any) {
-	debugLogger.Printf(format, v...)
+// Warn logs a message at warn level, args are key/value pairs or slog.Attr values like for slog.Warn.
+func Warn(msg string, args ...any) {
+	logger.Warn(msg, args...)
+}
+
+// Error logs a message at error level, args are key/value pairs or slog.Attr values like for slog.Error.
+func Error(msg string, args ...any) {
+	logger.Error(msg, args...)
+}
+
+// Debug logs a message at debug level, args are key/value pairs or slog.Attr values like for slog.Debug.
+func Debug(msg string, args ...any) {
+	logger.Debug(msg, args...)
+}
+
+// InfoContext logs like Info and adds the request id and request attributes found in ctx.
+func InfoContext(ctx context.Context, msg string, args ...any) {
+	logger.InfoContext(ctx, msg, args...)
+}
+
+// WarnContext logs like Warn and adds the request id and request attributes found in ctx.
+func WarnContext(ctx context.Context, msg string, args ...any) {
+	logger.WarnContext(ctx, msg, args...)
+}
+
+// ErrorContext logs like Error and adds the request id and request attributes found in ctx.
+func ErrorContext(ctx context.Context, msg string, args ...any) {
+	logger.ErrorContext(ctx, msg, args...)
+}
+
+// DebugContext logs like Debug and adds the request id and request attributes found in ctx.
+func DebugContext(ctx context.Context, msg string, args ...any) {
+	logger.DebugContext(ctx, msg, args...)
+}
+
+// Err returns the attribute for an error.
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
+
+// Reason returns the attribute for the reason of a failure.
+func Reason(reason string) slog.Attr {
+	return slog.String(KeyReason, reason)
+}
+
+// LogAttrs logs a message with structured attributes instead of a formatted string.
+func LogAttrs(level slog.Level, msg string, attrs ...slog.Attr) {
+	structuredLogger.LogAttrs(context.Background(), level, msg, attrs...)
 }
 
 func IsDebug() bool {
 	return currentLogLevel == slog.LevelDebug
 }
 
-func AccessLogRequest(r *http.Request) {
-	addr := getAddrFromRequest(r)
-	accessLogger.Printf("%s - %s - %s %s", addr, r.Host, r.Method, r.URL)
+func newHandler(format string, w io.Writer, options *slog.HandlerOptions) slog.Handler {
+	if format == FormatJSON {
+		return slog.NewJSONHandler(w, options)
+	}
+	return slog.NewTextHandler(w, options)
 }
 
-func AccessLogResult(r *http.Request, status int, message string) {
-	addr := getAddrFromRequest(r)
-	accessLogger.Printf("%s - %s - %s %s - %d %s", addr, r.Host, r.Method, r.URL, status, message)
+func newDynamicHandler(handler slog.Handler) *dynamicHandler {
+	return &dynamicHandler{
+		handler: handler,
+		mux:     &sync.RWMutex{},
+	}
 }
 
-func AccessLogInvalidLogin(r *http.Request, format string, v ...any) {
-	addr := getAddrFromRequest(r)
-	logEntry := fmt.Sprintf(format, v...)
-	accessLogger.Printf("%s - %s", logEntry, addr)
+func (h *dynamicHandler) set(handler slog.Handler) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.handler = handler
 }
 
-func getAddrFromRequest(r *http.Request) string {
-	var result []string
-	result = append(result, r.RemoteAddr)
-	forwardedFor := r.Header.Get("X-Forwarded-For")
-	if forwardedFor != "" {
-		result = append(result, "X-Forwarded-For: "+forwardedFor)
-	}
+func (h *dynamicHandler) get() slog.Handler {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	return h.handler
+}
 
-	realIp := r.Header.Get("X-Real-Ip")
-	if realIp != "" {
-		result = append(result, "X-Real-Ip: "+realIp)
+func (h *requestHandler) Handle(ctx context.Context, record slog.Record) error {
+	current, exists := getRequestAttributes(ctx)
+	if exists {
+		record.AddAttrs(slog.String(KeyRequestId, current.requestId))
+		current.mux.Lock()
+		record.AddAttrs(current.attributes...)
+		current.mux.Unlock()
 	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *requestHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &requestHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *requestHandler) WithGroup(name string) slog.Handler {
+	return &requestHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+func (h *dynamicHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.get().Enabled(ctx, level)
+}
+
+func (h *dynamicHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.get().Handle(ctx, record)
+}
+
+func (h *dynamicHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.get().WithAttrs(attrs)
+}
 
-	return strings.Join(result, ", ")
+func (h *dynamicHandler) WithGroup(name string) slog.Handler {
+	return h.get().WithGroup(name)
 }
 
 func getLogLevelFromString(level string) slog.Level {
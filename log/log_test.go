@@ -2,8 +2,14 @@ package log
 
 import (
 	"bytes"
-	"log"
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -23,16 +29,17 @@ func Test_Log(t *testing.T) {
 	}
 
 	type loggerTestParameter struct {
-		name   string
-		method func(format string, v ...any)
-		logger *log.Logger
+		name          string
+		method        func(msg string, args ...any)
+		contextMethod func(ctx context.Context, msg string, args ...any)
+		expectedLevel string
 	}
 
 	var loggerTests = []loggerTestParameter{
-		{name: "Info", method: Info, logger: infoLogger},
-		{name: "Debug", method: Debug, logger: debugLogger},
-		{name: "Warn", method: Warn, logger: warnLogger},
-		{name: "Error", method: Error, logger: errorLogger},
+		{name: "Info", method: Info, contextMethod: InfoContext, expectedLevel: "INFO"},
+		{name: "Debug", method: Debug, contextMethod: DebugContext, expectedLevel: "DEBUG"},
+		{name: "Warn", method: Warn, contextMethod: WarnContext, expectedLevel: "WARN"},
+		{name: "Error", method: Error, contextMethod: ErrorContext, expectedLevel: "ERROR"},
 	}
 
 	t.Run("Get log level from string", func(t *testing.T) {
@@ -55,14 +62,32 @@ func Test_Log(t *testing.T) {
 	for _, test := range loggerTests {
 		t.Run(test.name, func(t *testing.T) {
 			var buf bytes.Buffer
-			test.logger.SetOutput(&buf)
+			SetOutput(&buf)
+			defer SetOutput(os.Stdout)
+			SetLogLevel("debug")
+			defer SetLogLevel("info")
 
-			test.method("Hello %s", "world")
+			test.method("Hello", "name", "world", Err(errors.New("failed")))
 
 			value := buf.String()
 
-			if value != "Hello world\n" {
-				t.Errorf("Expected 'Hello world', got '%s'", value)
+			expected := fmt.Sprintf("level=%s msg=Hello name=world error=failed\n", test.expectedLevel)
+			if !strings.HasSuffix(value, expected) {
+				t.Errorf("Expected '%s', got '%s'", expected, value)
+			}
+
+			buf.Reset()
+			request := httptest.NewRequest(http.MethodGet, "/token", nil)
+			request = request.WithContext(NewRequestContext(request.Context(), "abc"))
+			AddRequestAttributes(request, ClientId("foo"))
+
+			test.contextMethod(request.Context(), "Hello", "name", "world")
+
+			value = buf.String()
+
+			expected = fmt.Sprintf("level=%s msg=Hello name=world request_id=abc client_id=foo\n", test.expectedLevel)
+			if !strings.HasSuffix(value, expected) {
+				t.Errorf("Expected '%s', got '%s'", expected, value)
 			}
 		})
 	}
@@ -0,0 +1,106 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.WriteCloser which writes to a file and rotates it
+// when the file would exceed the maximum size. Rotated files get a numeric suffix,
+// the newest one being .1, and only the configured number of backups is kept.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	mux        *sync.Mutex
+}
+
+// NewRotatingFile opens or creates the file at the given path.
+// A maxSize of zero or below disables rotation.
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	rotatingFile := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		mux:        &sync.Mutex{},
+	}
+	openError := rotatingFile.open()
+	if openError != nil {
+		return nil, openError
+	}
+	return rotatingFile, nil
+}
+
+func (rotatingFile *RotatingFile) Write(p []byte) (int, error) {
+	rotatingFile.mux.Lock()
+	defer rotatingFile.mux.Unlock()
+	if rotatingFile.maxSize > 0 && rotatingFile.size > 0 && rotatingFile.size+int64(len(p)) > rotatingFile.maxSize {
+		rotateError := rotatingFile.rotate()
+		if rotateError != nil {
+			return 0, rotateError
+		}
+	}
+	n, writeError := rotatingFile.file.Write(p)
+	rotatingFile.size += int64(n)
+	return n, writeError
+}
+
+func (rotatingFile *RotatingFile) Close() error {
+	rotatingFile.mux.Lock()
+	defer rotatingFile.mux.Unlock()
+	return rotatingFile.file.Close()
+}
+
+func (rotatingFile *RotatingFile) open() error {
+	file, openError := os.OpenFile(rotatingFile.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if openError != nil {
+		return openError
+	}
+	stat, statError := file.Stat()
+	if statError != nil {
+		_ = file.Close()
+		return statError
+	}
+	rotatingFile.file = file
+	rotatingFile.size = stat.Size()
+	return nil
+}
+
+// rotate moves the current file to the backups and opens a new one.
+// The file at the path is opened again in any case, so writing can continue after a failed rotation.
+func (rotatingFile *RotatingFile) rotate() error {
+	closeError := rotatingFile.file.Close()
+	if closeError != nil {
+		return errors.Join(closeError, rotatingFile.open())
+	}
+	return errors.Join(rotatingFile.moveBackups(), rotatingFile.open())
+}
+
+func (rotatingFile *RotatingFile) moveBackups() error {
+	if rotatingFile.maxBackups <= 0 {
+		removeError := os.Remove(rotatingFile.path)
+		if removeError != nil && !os.IsNotExist(removeError) {
+			return removeError
+		}
+		return nil
+	}
+	removeError := os.Remove(rotatingFile.backupName(rotatingFile.maxBackups))
+	if removeError != nil && !os.IsNotExist(removeError) {
+		return removeError
+	}
+	for index := rotatingFile.maxBackups - 1; index > 0; index-- {
+		renameError := os.Rename(rotatingFile.backupName(index), rotatingFile.backupName(index+1))
+		if renameError != nil && !os.IsNotExist(renameError) {
+			return renameError
+		}
+	}
+	return os.Rename(rotatingFile.path, rotatingFile.backupName(1))
+}
+
+func (rotatingFile *RotatingFile) backupName(index int) string {
+	return fmt.Sprintf("%s.%d", rotatingFile.path, index)
+}
@@ -0,0 +1,92 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_RotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stopnik.log")
+
+	rotatingFile, openError := NewRotatingFile(path, 10, 2)
+	if openError != nil {
+		t.Fatalf("Could not open file, %v", openError)
+	}
+	defer func() {
+		_ = rotatingFile.Close()
+	}()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, writeError := rotatingFile.Write([]byte(line))
+		if writeError != nil {
+			t.Fatalf("Could not write to file, %v", writeError)
+		}
+	}
+
+	expectedFiles := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for name, expected := range expectedFiles {
+		content, readError := os.ReadFile(name)
+		if readError != nil {
+			t.Fatalf("Could not read file %s, %v", name, readError)
+		}
+		if string(content) != expected {
+			t.Errorf("Expected '%s' in %s, got '%s'", expected, name, string(content))
+		}
+	}
+
+	_, statError := os.Stat(path + ".3")
+	if !os.IsNotExist(statError) {
+		t.Error("Expected only two backups")
+	}
+}
+
+func Test_RotatingFileRotationError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stopnik.log")
+
+	rotatingFile, openError := NewRotatingFile(path, 10, 1)
+	if openError != nil {
+		t.Fatalf("Could not open file, %v", openError)
+	}
+	defer func() {
+		_ = rotatingFile.Close()
+	}()
+
+	blockingBackup := filepath.Join(path+".1", "blocking")
+	mkdirError := os.MkdirAll(blockingBackup, 0o750)
+	if mkdirError != nil {
+		t.Fatalf("Could not create directory, %v", mkdirError)
+	}
+
+	_, writeError := rotatingFile.Write([]byte("first\n"))
+	if writeError != nil {
+		t.Fatalf("Could not write to file, %v", writeError)
+	}
+
+	_, writeError = rotatingFile.Write([]byte("second\n"))
+	if writeError == nil {
+		t.Error("Expected rotation error")
+	}
+
+	removeError := os.RemoveAll(path + ".1")
+	if removeError != nil {
+		t.Fatalf("Could not remove directory, %v", removeError)
+	}
+
+	_, writeError = rotatingFile.Write([]byte("third\n"))
+	if writeError != nil {
+		t.Fatalf("Expected file to be writable after failed rotation, %v", writeError)
+	}
+
+	content, readError := os.ReadFile(path)
+	if readError != nil {
+		t.Fatalf("Could not read file, %v", readError)
+	}
+	if string(content) != "third\n" {
+		t.Errorf("Expected 'third' in %s, got '%s'", path, string(content))
+	}
+}